@@ -2,11 +2,15 @@ module git
 
 go 1.20
 
+require (
+	github.com/stretchr/testify v1.8.4
+	golang.org/x/crypto v0.9.0
+	gopkg.in/ini.v1 v1.67.0
+)
+
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/stretchr/objx v0.5.1 // indirect
-	github.com/stretchr/testify v1.8.4 // indirect
-	gopkg.in/ini.v1 v1.67.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
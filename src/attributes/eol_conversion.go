@@ -0,0 +1,164 @@
+package attributes
+
+import (
+	"bytes"
+	"runtime"
+)
+
+// Line ending conversions of a file, decided by its text, eol and crlf attributes and core.autocrlf
+const (
+	BINARY     = "binary"     //No conversion
+	TEXT_INPUT = "text-input" //CRLF -> LF when adding. LF in the work tree
+	TEXT_CRLF  = "text-crlf"  //CRLF -> LF when adding. LF -> CRLF when checking out
+	AUTO_INPUT = "auto-input" //Like TEXT_INPUT if the content is detected as text
+	AUTO_CRLF  = "auto-crlf"  //Like TEXT_CRLF if the content is detected as text
+)
+
+// Values of core.autocrlf
+const (
+	AUTOCRLF_FALSE = "false"
+	AUTOCRLF_TRUE  = "true"
+	AUTOCRLF_INPUT = "input"
+)
+
+// GetEolConversion Same rules as git:
+//   - text: converted, with the line ending of eol or core.eol (native by default). text=auto only converts text content
+//   - -text (or binary): not converted, even if eol is set
+//   - eol=lf|crlf without text: same as text with that line ending
+//   - text unspecified: core.autocrlf true converts text content to CRLF and input only normalizes to LF when adding
+//
+// crlf is the deprecated form of text: crlf = text, -crlf = -text and crlf=input = eol=lf
+func GetEolConversion(fileAttributes FileAttributes, autoCrlf string, coreEol string) string {
+	textState := fileAttributes.Get("text")
+	if textState == UNSPECIFIED {
+		textState = fileAttributes.Get("crlf")
+		if textState == "input" {
+			return TEXT_INPUT
+		}
+	}
+	textEolIsCrlf := autoCrlf == AUTOCRLF_TRUE || (autoCrlf != AUTOCRLF_INPUT && isCrlfEol(coreEol))
+
+	conversion := ""
+	switch textState {
+	case SET:
+		conversion = chooseConversion(textEolIsCrlf, TEXT_CRLF, TEXT_INPUT)
+	case UNSET:
+		return BINARY
+	case "auto":
+		conversion = chooseConversion(textEolIsCrlf, AUTO_CRLF, AUTO_INPUT)
+	}
+
+	isAuto := conversion == AUTO_CRLF || conversion == AUTO_INPUT
+	switch fileAttributes.Get("eol") {
+	case "lf":
+		return chooseConversion(isAuto, AUTO_INPUT, TEXT_INPUT)
+	case "crlf":
+		return chooseConversion(isAuto, AUTO_CRLF, TEXT_CRLF)
+	}
+	if conversion != "" {
+		return conversion
+	}
+
+	switch autoCrlf {
+	case AUTOCRLF_TRUE:
+		return AUTO_CRLF
+	case AUTOCRLF_INPUT:
+		return AUTO_INPUT
+	}
+	return BINARY
+}
+
+func chooseConversion(condition bool, ifTrue string, ifFalse string) string {
+	if condition {
+		return ifTrue
+	}
+	return ifFalse
+}
+
+// core.eol: lf, crlf or native (the default), which is CRLF only on Windows
+func isCrlfEol(coreEol string) bool {
+	if coreEol == "" || coreEol == "native" {
+		return runtime.GOOS == "windows"
+	}
+	return coreEol == "crlf"
+}
+
+// ConvertToGit Returns the content as stored in blobs: CRLF line endings are replaced by LF. Lone CRs are kept
+func ConvertToGit(content []byte, conversion string) []byte {
+	if conversion == BINARY {
+		return content
+	}
+
+	stats := gatherTextStats(content)
+	if stats.crlf == 0 || (isAutoConversion(conversion) && stats.isBinary()) {
+		return content
+	}
+
+	return bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n"))
+}
+
+// ConvertToWorkTree Returns the content of a blob as written in the work tree: LF line endings are replaced by CRLF if the
+// conversion uses CRLF. Auto conversions skip binary content and content that already has CR
+func ConvertToWorkTree(content []byte, conversion string) []byte {
+	if conversion != TEXT_CRLF && conversion != AUTO_CRLF {
+		return content
+	}
+
+	stats := gatherTextStats(content)
+	if stats.loneLf == 0 || (isAutoConversion(conversion) && (stats.loneCr > 0 || stats.crlf > 0 || stats.isBinary())) {
+		return content
+	}
+
+	converted := make([]byte, 0, len(content)+stats.loneLf)
+	for i, char := range content {
+		if char == '\n' && (i == 0 || content[i-1] != '\r') {
+			converted = append(converted, '\r')
+		}
+		converted = append(converted, char)
+	}
+
+	return converted
+}
+
+func isAutoConversion(conversion string) bool {
+	return conversion == AUTO_INPUT || conversion == AUTO_CRLF
+}
+
+type textStats struct {
+	loneCr       int
+	loneLf       int
+	crlf         int
+	nul          int
+	printable    int
+	nonPrintable int
+}
+
+// Same heuristic as git: content with NUL, lone CR or more than 1 non printable character every 128 printable ones is binary
+func (s textStats) isBinary() bool {
+	return s.loneCr > 0 || s.nul > 0 || s.printable>>7 < s.nonPrintable
+}
+
+func gatherTextStats(content []byte) textStats {
+	stats := textStats{}
+
+	for i := 0; i < len(content); i++ {
+		switch char := content[i]; {
+		case char == '\r' && i+1 < len(content) && content[i+1] == '\n':
+			stats.crlf++
+			i++
+		case char == '\r':
+			stats.loneCr++
+		case char == '\n':
+			stats.loneLf++
+		case char == 0:
+			stats.nul++
+			stats.nonPrintable++
+		case char == 127 || (char < 32 && char != '\b' && char != '\t' && char != '\033' && char != '\014'):
+			stats.nonPrintable++
+		default:
+			stats.printable++
+		}
+	}
+
+	return stats
+}
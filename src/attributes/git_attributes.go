@@ -0,0 +1,269 @@
+package attributes
+
+import (
+	"git/src/utils"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// States of an attribute. Any other state is the value of the attribute (Ex: eol=lf)
+const (
+	UNSPECIFIED = "unspecified" //Not mentioned by any rule, or reset with !<name>
+	SET         = "set"         //<name>
+	UNSET       = "unset"       //-<name>
+)
+
+const MAX_MACRO_DEPTH = 10
+
+// Assignment State given to an attribute by a rule
+type Assignment struct {
+	Name  string
+	State string
+}
+
+// Rule Line of an attributes file. Format: <pattern> <attribute>... Dir is the directory of the file relative to the work tree
+type Rule struct {
+	Pattern     string
+	Dir         string
+	Assignments []Assignment
+}
+
+// FileReader Returns the content of the .gitattributes of the directory (relative to the work tree, "" for the root) and if it exists
+type FileReader func(dir string) ([]byte, bool, error)
+
+// Attributes Rules of the attribute files in precedence order: the global file (core.attributesFile), the .gitattributes files
+// from the root to the directory of the path and info/attributes. The .gitattributes of each directory are read the first
+// time a path inside it is checked. Macros (Ex: [attr]binary -diff -merge -text) can only be defined in the global file,
+// the root .gitattributes and info/attributes
+type Attributes struct {
+	globalRules []Rule
+	infoRules   []Rule
+	dirRules    map[string][]Rule
+	macros      map[string][]Assignment
+	readFile    FileReader
+}
+
+// FileAttributes States of the specified attributes of a file keyed by name
+type FileAttributes map[string]string
+
+// Get Returns UNSPECIFIED if no rule mentions the attribute
+func (f FileAttributes) Get(name string) string {
+	if state, found := f[name]; found {
+		return state
+	}
+	return UNSPECIFIED
+}
+
+// Names Specified attributes sorted by name
+func (f FileAttributes) Names() []string {
+	names := make([]string, 0, len(f))
+	for name, _ := range f {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// NewAttributes global and info are the content of core.attributesFile and info/attributes, nil if they dont exist
+func NewAttributes(global []byte, info []byte, readFile FileReader) *Attributes {
+	attributes := &Attributes{
+		dirRules: make(map[string][]Rule),
+		macros:   map[string][]Assignment{"binary": {{Name: "diff", State: UNSET}, {Name: "merge", State: UNSET}, {Name: "text", State: UNSET}}},
+		readFile: readFile,
+	}
+	attributes.globalRules = attributes.parse(global, "", true)
+	attributes.infoRules = attributes.parse(info, "", true)
+
+	return attributes
+}
+
+// ParseRules Rules of an attributes file in the directory (relative to the work tree). Macro definitions are skipped
+func ParseRules(content []byte, dir string) []Rule {
+	return NewAttributes(nil, nil, nil).parse(content, dir, false)
+}
+
+// Get Returns the attributes of the file. path is relative to the work tree
+func (a *Attributes) Get(path string) (FileAttributes, error) {
+	path = strings.Trim(filepath.ToSlash(path), "/")
+	rules := append([]Rule{}, a.globalRules...)
+
+	dirs := make([]string, 0)
+	for dir := filepath.Dir(path); dir != "." && dir != "/"; dir = filepath.Dir(dir) {
+		dirs = append(dirs, dir)
+	}
+	dirs = append(dirs, "")
+	for i := len(dirs) - 1; i >= 0; i-- { //From the root to the directory of the file
+		dirRules, err := a.getDirRules(dirs[i])
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, dirRules...)
+	}
+	rules = append(rules, a.infoRules...)
+
+	fileAttributes := make(FileAttributes)
+	for _, rule := range rules {
+		if rule.matches(path) {
+			a.assign(fileAttributes, rule.Assignments, 0)
+		}
+	}
+
+	return fileAttributes, nil
+}
+
+func (a *Attributes) getDirRules(dir string) ([]Rule, error) {
+	if rules, read := a.dirRules[dir]; read {
+		return rules, nil
+	}
+
+	rules := make([]Rule, 0)
+	if a.readFile != nil {
+		content, exists, err := a.readFile(dir)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			rules = a.parse(content, dir, dir == "")
+		}
+	}
+	a.dirRules[dir] = rules
+
+	return rules, nil
+}
+
+// Set macros also assign the attributes of their definition
+func (a *Attributes) assign(fileAttributes FileAttributes, assignments []Assignment, depth int) {
+	for _, assignment := range assignments {
+		if assignment.State == UNSPECIFIED {
+			delete(fileAttributes, assignment.Name)
+		} else {
+			fileAttributes[assignment.Name] = assignment.State
+		}
+
+		if macro, isMacro := a.macros[assignment.Name]; isMacro && assignment.State == SET && depth < MAX_MACRO_DEPTH {
+			a.assign(fileAttributes, macro, depth+1)
+		}
+	}
+}
+
+// Lines: <pattern> <attribute>..., where the attributes are <name>, -<name>, !<name> or <name>=<value>. Patterns can be quoted.
+// Blank lines and lines starting with # are skipped, and so are negative patterns and invalid attribute names
+func (a *Attributes) parse(content []byte, dir string, allowMacros bool) []Rule {
+	rules := make([]Rule, 0)
+
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimLeft(strings.TrimSuffix(line, "\r"), " \t")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		pattern, rest := readPattern(line)
+		assignments := parseAssignments(rest)
+
+		if macroName, isMacro := strings.CutPrefix(pattern, "[attr]"); isMacro {
+			if allowMacros && isValidAttributeName(macroName) {
+				a.macros[macroName] = assignments
+			}
+		} else if pattern != "" && !strings.HasPrefix(pattern, "!") {
+			rules = append(rules, Rule{Pattern: pattern, Dir: dir, Assignments: assignments})
+		}
+	}
+
+	return rules
+}
+
+// Quoted patterns support the escapes \" \\ \t and \n
+func readPattern(line string) (string, string) {
+	if !strings.HasPrefix(line, "\"") {
+		end := strings.IndexAny(line, " \t")
+		if end == -1 {
+			return line, ""
+		}
+		return line[:end], line[end:]
+	}
+
+	var pattern strings.Builder
+	for i := 1; i < len(line); i++ {
+		switch {
+		case line[i] == '"':
+			return pattern.String(), line[i+1:]
+		case line[i] == '\\' && i+1 < len(line):
+			i++
+			pattern.WriteByte(unescapeByte(line[i]))
+		default:
+			pattern.WriteByte(line[i])
+		}
+	}
+
+	return "", "" //Unterminated quote
+}
+
+func unescapeByte(escaped byte) byte {
+	switch escaped {
+	case 't':
+		return '\t'
+	case 'n':
+		return '\n'
+	}
+	return escaped
+}
+
+func parseAssignments(text string) []Assignment {
+	assignments := make([]Assignment, 0)
+
+	for _, field := range strings.Fields(text) {
+		assignment := Assignment{Name: field, State: SET}
+		switch {
+		case strings.HasPrefix(field, "-"):
+			assignment = Assignment{Name: field[1:], State: UNSET}
+		case strings.HasPrefix(field, "!"):
+			assignment = Assignment{Name: field[1:], State: UNSPECIFIED}
+		case strings.Contains(field, "="):
+			name, value, _ := strings.Cut(field, "=")
+			assignment = Assignment{Name: name, State: value}
+		}
+
+		if isValidAttributeName(assignment.Name) {
+			assignments = append(assignments, assignment)
+		}
+	}
+
+	return assignments
+}
+
+// Names have the characters [-._0-9a-zA-Z] and dont start with -
+func isValidAttributeName(name string) bool {
+	if name == "" || name[0] == '-' {
+		return false
+	}
+
+	for _, char := range name {
+		isAlphanumeric := (char >= 'a' && char <= 'z') || (char >= 'A' && char <= 'Z') || (char >= '0' && char <= '9')
+		if !isAlphanumeric && char != '-' && char != '.' && char != '_' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Patterns without / match the name of the file at any depth below the directory of the rule, the rest match the path
+// relative to it. Patterns ending with / only match directories, so they dont match files
+func (r Rule) matches(path string) bool {
+	if r.Dir != "" {
+		var inside bool
+		if path, inside = strings.CutPrefix(path, r.Dir+"/"); !inside {
+			return false
+		}
+	}
+	if strings.HasSuffix(r.Pattern, "/") {
+		return false
+	}
+
+	if !strings.Contains(r.Pattern, "/") {
+		return utils.WildMatch(r.Pattern, filepath.Base(path), false)
+	}
+	return utils.WildMatch(strings.TrimPrefix(r.Pattern, "/"), path, false)
+}
@@ -0,0 +1,70 @@
+package attributes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAttributes_Get(t *testing.T) {
+	files := map[string]string{
+		"":    "# comment\n[attr]generated -diff linguist\n*.txt text eol=lf\n/root.md text\n\"with space.md\" -text\ndocs/** generated\n!negated text\n",
+		"sub": "*.txt !eol -text\n[attr]ignored text\nnested/*.c ignored\n",
+	}
+	readFile := func(dir string) ([]byte, bool, error) {
+		content, exists := files[dir]
+		return []byte(content), exists, nil
+	}
+	attributes := NewAttributes([]byte("*.c text\n"), []byte("*.bin binary\n"), readFile)
+
+	fileAttributes, err := attributes.Get("dir/a.txt")
+	assert.Nil(t, err)
+	assert.Equal(t, FileAttributes{"text": SET, "eol": "lf"}, fileAttributes)
+
+	fileAttributes, _ = attributes.Get("sub/a.txt")
+	assert.Equal(t, FileAttributes{"text": UNSET}, fileAttributes)
+	assert.Equal(t, UNSPECIFIED, fileAttributes.Get("eol"))
+
+	fileAttributes, _ = attributes.Get("sub/nested/a.c")
+	assert.Equal(t, FileAttributes{"text": SET, "ignored": SET}, fileAttributes)
+
+	fileAttributes, _ = attributes.Get("docs/deep/file")
+	assert.Equal(t, FileAttributes{"generated": SET, "diff": UNSET, "linguist": SET}, fileAttributes)
+
+	fileAttributes, _ = attributes.Get("a.bin")
+	assert.Equal(t, []string{"binary", "diff", "merge", "text"}, fileAttributes.Names())
+	assert.Equal(t, UNSET, fileAttributes.Get("merge"))
+
+	fileAttributes, _ = attributes.Get("with space.md")
+	assert.Equal(t, FileAttributes{"text": UNSET}, fileAttributes)
+	fileAttributes, _ = attributes.Get("dir/root.md")
+	assert.Equal(t, FileAttributes{}, fileAttributes)
+	fileAttributes, _ = attributes.Get("negated")
+	assert.Equal(t, FileAttributes{}, fileAttributes)
+}
+
+func TestAttributes_GetEolConversion(t *testing.T) {
+	assert.Equal(t, TEXT_CRLF, GetEolConversion(FileAttributes{"text": SET}, AUTOCRLF_TRUE, ""))
+	assert.Equal(t, TEXT_INPUT, GetEolConversion(FileAttributes{"text": SET}, AUTOCRLF_FALSE, "lf"))
+	assert.Equal(t, TEXT_CRLF, GetEolConversion(FileAttributes{"text": SET}, AUTOCRLF_FALSE, "crlf"))
+	assert.Equal(t, TEXT_CRLF, GetEolConversion(FileAttributes{"eol": "crlf"}, AUTOCRLF_INPUT, ""))
+	assert.Equal(t, AUTO_INPUT, GetEolConversion(FileAttributes{"text": "auto", "eol": "lf"}, AUTOCRLF_TRUE, ""))
+	assert.Equal(t, BINARY, GetEolConversion(FileAttributes{"text": UNSET, "eol": "crlf"}, AUTOCRLF_TRUE, ""))
+	assert.Equal(t, TEXT_INPUT, GetEolConversion(FileAttributes{"crlf": "input"}, AUTOCRLF_TRUE, ""))
+	assert.Equal(t, AUTO_CRLF, GetEolConversion(FileAttributes{}, AUTOCRLF_TRUE, ""))
+	assert.Equal(t, AUTO_INPUT, GetEolConversion(FileAttributes{}, AUTOCRLF_INPUT, ""))
+	assert.Equal(t, BINARY, GetEolConversion(FileAttributes{}, AUTOCRLF_FALSE, "crlf"))
+}
+
+func TestAttributes_ConvertEol(t *testing.T) {
+	assert.Equal(t, "a\nb\rc\n", string(ConvertToGit([]byte("a\r\nb\rc\r\n"), TEXT_INPUT)))
+	assert.Equal(t, "a\r\nb\rc\r\n", string(ConvertToGit([]byte("a\r\nb\rc\r\n"), AUTO_INPUT)))
+	assert.Equal(t, "a\r\n\x00", string(ConvertToGit([]byte("a\r\n\x00"), AUTO_CRLF)))
+	assert.Equal(t, "a\nb\n", string(ConvertToGit([]byte("a\r\nb\r\n"), AUTO_CRLF)))
+	assert.Equal(t, "a\r\n", string(ConvertToGit([]byte("a\r\n"), BINARY)))
+
+	assert.Equal(t, "a\r\nb\r\n", string(ConvertToWorkTree([]byte("a\nb\r\n"), TEXT_CRLF)))
+	assert.Equal(t, "a\nb\r\n", string(ConvertToWorkTree([]byte("a\nb\r\n"), AUTO_CRLF)))
+	assert.Equal(t, "\n\x00", string(ConvertToWorkTree([]byte("\n\x00"), AUTO_CRLF)))
+	assert.Equal(t, "a\n", string(ConvertToWorkTree([]byte("a\n"), TEXT_INPUT)))
+}
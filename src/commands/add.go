@@ -1,13 +1,11 @@
 package commands
 
 import (
-	"crypto/sha1"
-	"encoding/hex"
 	"fmt"
+	"git/src/ignore"
 	"git/src/index"
 	"git/src/repository"
 	"git/src/utils"
-	"io/ioutil"
 	"os"
 	"strings"
 )
@@ -20,10 +18,14 @@ func Add(args []string) {
 	currentPath := utils.CurrentPath()
 	currentRepository, _, err := repository.FindCurrentRepository(currentPath)
 	utils.CheckError(err)
+	utils.CheckError(currentRepository.RequireWorkTree())
 	indexRepository, err := currentRepository.ReadIndex()
 	utils.CheckError(err)
 
 	pathsToAdd := args[2:]
+	gitIgnores, err := currentRepository.ReadGitIgnores()
+	utils.CheckError(err)
+	walk := &addWalk{gitIgnores: gitIgnores, files: make([]fileToStage, 0)}
 
 	for _, pathToAdd := range pathsToAdd {
 		isAbsolute := strings.HasPrefix(pathToAdd, "/")
@@ -31,77 +33,163 @@ func Add(args []string) {
 		if !allSubfilesMode && isAbsolute && !strings.Contains(pathToAdd, currentRepository.WorkTree) {
 			fmt.Println("Cannot add " + pathToAdd + " doesnt belong to repository")
 		}
-		if !allSubfilesMode && !utils.CheckFileOrDirExists(pathToAdd) {
-			fmt.Println("Cannot add " + pathToAdd + " doest exist")
-		}
-
 		pathInRepository := currentRepository.GetPathFileInRepository(pathToAdd)
 
-		if allSubfilesMode {
-			addSubfiles(currentRepository, indexRepository, pathInRepository)
+		if !allSubfilesMode && !utils.CheckFileOrDirExists(pathToAdd) {
+			removeDeletedFromIndex(currentRepository, indexRepository, pathInRepository, pathToAdd)
+		} else if allSubfilesMode {
+			addSubfiles(currentRepository, indexRepository, pathInRepository, walk)
 		} else {
-			add(currentRepository, indexRepository, pathInRepository)
+			add(currentRepository, indexRepository, pathInRepository, walk)
 		}
 	}
+	for _, changedPath := range stageFiles(currentRepository, indexRepository, walk.files) {
+		fmt.Println(changedPath)
+	}
 
 	if err := currentRepository.WriteIndex(indexRepository); err != nil {
 		fmt.Println("Cannot write to INDEX: " + err.Error())
 	}
 }
 
-func addSubfiles(currentRepository *repository.Repository, indexObject *index.IndexObject, pathRelativeRepo string) *index.IndexObject {
+// Paths found while walking the paths to add
+type addWalk struct {
+	gitIgnores map[string]ignore.GitIgnore //Read once, since they are found from the index
+	files      []fileToStage
+}
+
+func addSubfiles(currentRepository *repository.Repository, indexObject *index.IndexObject, pathRelativeRepo string, walk *addWalk) *index.IndexObject {
 	children, _ := os.ReadDir(pathRelativeRepo)
 	for _, child := range children {
-		add(currentRepository, indexObject, utils.Paths(pathRelativeRepo, child.Name()))
+		add(currentRepository, indexObject, utils.Paths(pathRelativeRepo, child.Name()), walk)
 	}
 
 	return indexObject
 }
 
-func add(currentRepository *repository.Repository, indexObject *index.IndexObject, pathRelativeRepo string) {
-	stat, err := os.Stat(pathRelativeRepo)
+func add(currentRepository *repository.Repository, indexObject *index.IndexObject, pathRelativeRepo string, walk *addWalk) {
+	stat, err := os.Lstat(pathRelativeRepo)
 	if err != nil {
 		fmt.Println("Cannot get stat info of file " + pathRelativeRepo)
+		return
 	}
 
-	if ignored, _ := currentRepository.IsIgnored(pathRelativeRepo); ignored {
+	if ignored, _ := currentRepository.IsIgnoredBy(walk.gitIgnores, pathRelativeRepo); ignored {
 		return
 	}
 
-	if stat.IsDir() {
-		addSubfiles(currentRepository, indexObject, pathRelativeRepo)
+	if stat.IsDir() && isNestedRepository(currentRepository, pathRelativeRepo) {
+		addGitlink(indexObject, pathRelativeRepo, currentRepository)
+	} else if stat.IsDir() {
+		addSubfiles(currentRepository, indexObject, pathRelativeRepo, walk)
 	} else {
-		addFile(indexObject, pathRelativeRepo, stat, currentRepository)
+		addFile(indexObject, pathRelativeRepo, stat, currentRepository, &walk.files)
 	}
 }
 
-func addFile(indexObject *index.IndexObject, path string, stat os.FileInfo, currentRepository *repository.Repository) {
+// Files are staged later by stageFiles. Tracked files are only hashed there if their stat info cannot tell if they changed
+func addFile(indexObject *index.IndexObject, path string, stat os.FileInfo, currentRepository *repository.Repository, files *[]fileToStage) {
 	pathRelativeRepo := currentRepository.AbsolutePathToRepositoryPath(path)
 	indexEntry, indexEntryExists := indexObject.Entries[pathRelativeRepo]
 
+	if indexEntryExists && indexEntry.SkipWorktree {
+		fmt.Println("Cannot add " + pathRelativeRepo + " it is outside of the sparse-checkout definition")
+		return
+	}
 	if indexEntryExists {
-		modified := stat.ModTime().UnixNano() > int64(indexEntry.Ctime) || stat.ModTime().UnixNano() > int64(indexEntry.Mtime)
-
-		if modified {
-			fmt.Println(pathRelativeRepo)
-			indexObject.Entries[pathRelativeRepo] = index.CreateIndexEntry(stat, pathRelativeRepo, getSha(pathRelativeRepo))
+		if modified, mustHash := currentRepository.IsWorkTreeFileStatModified(indexObject, indexEntry, stat); modified || mustHash {
+			*files = append(*files, fileToStage{path: path, stat: stat, pathRelativeRepo: pathRelativeRepo, prevIndexEntry: indexEntry, prevIndexEntryExists: true})
 		}
 	} else {
-		fmt.Println(pathRelativeRepo)
-		indexObject.Entries[pathRelativeRepo] = index.CreateIndexEntry(stat, pathRelativeRepo, getSha(pathRelativeRepo))
+		*files = append(*files, fileToStage{path: path, stat: stat, pathRelativeRepo: pathRelativeRepo})
 	}
 }
 
-func getSha(filePath string) string {
-	file, err := os.Open(filePath)
-	defer file.Close()
-	utils.CheckError(err)
+type fileToStage struct {
+	path                 string //Absolute
+	stat                 os.FileInfo
+	pathRelativeRepo     string
+	prevIndexEntry       index.IndexEntry
+	prevIndexEntryExists bool
+}
 
-	bytes, err := ioutil.ReadAll(file)
+// Files are collected while the paths are walked, so their blobs are read, hashed, compressed and written in parallel.
+// The index entries are set afterwards in the order of the files. Unchanged files only get their stat info refreshed.
+// Returns the paths of the files that changed, in the same order
+func stageFiles(currentRepository *repository.Repository, indexObject *index.IndexObject, files []fileToStage) []string {
+	workTreeFiles := make([]repository.WorkTreeFile, 0, len(files))
+	for _, file := range files {
+		workTreeFiles = append(workTreeFiles, repository.WorkTreeFile{Path: file.path, Stat: file.stat})
+	}
+	shas, err := currentRepository.WriteWorkTreeFileBlobs(workTreeFiles)
 	utils.CheckError(err)
 
-	sha1Hasher := sha1.New()
-	sha1Hasher.Write(bytes)
+	changed := make([]string, 0, len(files))
+	for i, file := range files {
+		newIndexEntry := createFileIndexEntry(currentRepository, file.stat, file.pathRelativeRepo, shas[i], file.prevIndexEntry, file.prevIndexEntryExists)
+		if !file.prevIndexEntryExists || newIndexEntry.Sha != file.prevIndexEntry.Sha || newIndexEntry.Mode() != file.prevIndexEntry.Mode() {
+			changed = append(changed, file.pathRelativeRepo)
+		}
+		indexObject.Entries[file.pathRelativeRepo] = newIndexEntry
+	}
+
+	return changed
+}
+
+// If core.filemode or core.symlinks are disabled, the mode already stored in the index is kept, since the file system
+// cannot be trusted. Symlinks are stored as a blob with the target path
+func createFileIndexEntry(currentRepository *repository.Repository, stat os.FileInfo, pathRelativeRepo string, sha string,
+	prevIndexEntry index.IndexEntry, prevIndexEntryExists bool) index.IndexEntry {
+
+	newIndexEntry := index.CreateIndexEntry(stat, pathRelativeRepo, sha)
 
-	return hex.EncodeToString(sha1Hasher.Sum(nil))
+	if !currentRepository.SymlinksEnabled() && prevIndexEntryExists && prevIndexEntry.IsSymlink() && !newIndexEntry.IsSymlink() {
+		newIndexEntry.ModeType, newIndexEntry.ModePerms = prevIndexEntry.ModeType, prevIndexEntry.ModePerms
+	} else if !currentRepository.FileModeEnabled() && !newIndexEntry.IsSymlink() {
+		newIndexEntry.ModePerms = index.MODE_PERMS_REGULAR
+		if prevIndexEntryExists && prevIndexEntry.IsExecutable() {
+			newIndexEntry.ModePerms = index.MODE_PERMS_EXECUTABLE
+		}
+	}
+
+	return newIndexEntry
+}
+
+// Adding a tracked file that has been deleted from the work tree stages its deletion
+func removeDeletedFromIndex(currentRepository *repository.Repository, indexObject *index.IndexObject, path string, pathToAdd string) {
+	pathRelativeRepo := currentRepository.AbsolutePathToRepositoryPath(path)
+	removed := false
+
+	for entryPath, entry := range indexObject.Entries {
+		if entry.SkipWorktree { //Not in the work tree because of the sparse checkout
+			continue
+		}
+		if entryPath == pathRelativeRepo || strings.HasPrefix(entryPath, pathRelativeRepo+"/") {
+			delete(indexObject.Entries, entryPath)
+			removed = true
+		}
+	}
+
+	if !removed {
+		fmt.Println("Cannot add " + pathToAdd + " doest exist")
+	}
+}
+
+// Directories containing a .git (submodules) are added as a gitlink pointing to its checked out commit
+func isNestedRepository(currentRepository *repository.Repository, path string) bool {
+	return path != currentRepository.WorkTree && utils.CheckFileOrDirExists(utils.Path(path, ".git"))
+}
+
+func addGitlink(indexObject *index.IndexObject, path string, currentRepository *repository.Repository) {
+	pathRelativeRepo := currentRepository.AbsolutePathToRepositoryPath(path)
+	checkedOutSha, checkedOut := getSubmoduleCheckedOutCommit(currentRepository, pathRelativeRepo)
+	if !checkedOut {
+		fmt.Println("Cannot add " + pathRelativeRepo + ": nested repository has no commits")
+		return
+	}
+
+	if indexEntry, indexEntryExists := indexObject.Entries[pathRelativeRepo]; !indexEntryExists || indexEntry.Sha != checkedOutSha {
+		fmt.Println(pathRelativeRepo)
+		indexObject.Entries[pathRelativeRepo] = index.CreateGitlinkIndexEntry(pathRelativeRepo, checkedOutSha)
+	}
 }
@@ -0,0 +1,256 @@
+package commands
+
+import (
+	"fmt"
+	"git/src/diff"
+	"git/src/objects"
+	"git/src/repository"
+	"git/src/utils"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const NOT_COMMITTED_NAME = "Not Committed Yet"
+const NOT_COMMITTED_EMAIL = "not.committed.yet"
+
+type blameOptions struct {
+	path      string
+	revision  string //Empty to blame the file of the work tree
+	start     int
+	end       int //-1 until the end of the file
+	porcelain bool
+}
+
+// Blame Shows the commit, author and date that last changed every line of the file. Renames are followed
+// Blame Args: main.go blame [-L <start>,<end>] [--porcelain] <file> [<revision>]
+// Without revision the file of the work tree is blamed. Its changes are shown as "Not Committed Yet"
+func Blame(args []string) {
+	currentRepository, _, err := repository.FindCurrentRepository(utils.CurrentPath())
+	utils.CheckError(err)
+	options := parseBlameOptions(currentRepository, args[2:], "blame")
+
+	lines := getBlameLines(currentRepository, options)
+	if options.porcelain {
+		printBlamePorcelain(lines)
+	} else {
+		printBlame(lines, options.path)
+	}
+}
+
+// Annotate Same as blame with the output of git annotate: <sha>\t(<author>\t<date>\t<line number>)<line>
+// Annotate Args: main.go annotate [-L <start>,<end>] <file> [<revision>]
+func Annotate(args []string) {
+	currentRepository, _, err := repository.FindCurrentRepository(utils.CurrentPath())
+	utils.CheckError(err)
+	options := parseBlameOptions(currentRepository, args[2:], "annotate")
+
+	for _, line := range getBlameLines(currentRepository, options) {
+		author := getBlameAuthor(line)
+		fmt.Printf("%s\t(%10s\t%s\t%d)%s\n", line.Sha[:8], author.Name, author.When.Format(DATE_FORMAT_ISO), line.FinalLine, line.Content)
+	}
+}
+
+func parseBlameOptions(currentRepository *repository.Repository, args []string, command string) blameOptions {
+	options := blameOptions{start: 1, end: -1}
+	positional := make([]string, 0)
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		switch {
+		case arg == "-L" || strings.HasPrefix(arg, "-L"):
+			value := strings.TrimPrefix(arg, "-L")
+			if value == "" {
+				if i+1 >= len(args) {
+					utils.ExitError("Option -L requires a value")
+				}
+				value = args[i+1]
+				i++
+			}
+			options.start, options.end = parseBlameRange(value)
+		case arg == "--porcelain" && command == "blame":
+			options.porcelain = true
+		case arg == "--":
+			positional = append(positional, args[i+1:]...)
+			i = len(args)
+		case strings.HasPrefix(arg, "-"):
+			utils.ExitError("Unknown option " + arg + " for " + command)
+		default:
+			positional = append(positional, arg)
+		}
+	}
+
+	if len(positional) == 0 || len(positional) > 2 {
+		utils.ExitError("Invalid arguments: " + command + " [-L <start>,<end>] <file> [<revision>]")
+	}
+	options.path = toRepositoryPath(currentRepository, positional[0])
+	if len(positional) == 2 {
+		options.revision = positional[1]
+	}
+
+	return options
+}
+
+// Formats: <start>,<end>, <start>,+<lines>, <start>
+func parseBlameRange(value string) (int, int) {
+	parts := strings.SplitN(value, ",", 2)
+	start, err := strconv.Atoi(parts[0])
+	if err != nil || start < 1 {
+		utils.ExitError("fatal: invalid -L range: " + value)
+	}
+	if len(parts) == 1 || parts[1] == "" {
+		return start, -1
+	}
+
+	end, err := strconv.Atoi(strings.TrimPrefix(parts[1], "+"))
+	if err != nil {
+		utils.ExitError("fatal: invalid -L range: " + value)
+	}
+	if strings.HasPrefix(parts[1], "+") {
+		end = start + end - 1
+	}
+	if end < start {
+		start, end = end, start
+	}
+
+	return start, end
+}
+
+// Returns the lines of the range
+func getBlameLines(currentRepository *repository.Repository, options blameOptions) []repository.BlameLine {
+	revision := options.revision
+	var workTreeContent []byte
+	if revision == "" {
+		revision = "HEAD"
+		if !currentRepository.IsBare() {
+			workTreeContent = readBlamedWorkTreeFile(currentRepository, options.path)
+		}
+	}
+
+	sha, _, err := currentRepository.ResolveObjectName(revision, objects.COMMIT)
+	if err != nil {
+		utils.ExitError("fatal: bad revision '" + revision + "'")
+	}
+
+	lines, err := currentRepository.Blame(sha, options.path, workTreeContent)
+	if err != nil {
+		utils.ExitError("fatal: " + strings.Replace(err.Error(), sha, revision, 1))
+	}
+
+	end := options.end
+	if end == -1 {
+		end = len(lines)
+	}
+	if options.start > len(lines) || end > len(lines) {
+		utils.ExitError("fatal: file " + options.path + " has only " + strconv.Itoa(len(lines)) + " lines")
+	}
+
+	return lines[options.start-1 : end]
+}
+
+func readBlamedWorkTreeFile(currentRepository *repository.Repository, path string) []byte {
+	fullPath := utils.Path(currentRepository.WorkTree, path)
+	stat, err := os.Lstat(fullPath)
+	if err != nil {
+		utils.ExitError("fatal: cannot stat path '" + path + "': " + err.Error())
+	}
+
+	content, err := currentRepository.ReadWorkTreeFileBlob(fullPath, stat)
+	utils.CheckError(err)
+
+	return content
+}
+
+// Format: <sha> [<path>] (<author> <date> <line number>) <line>. The path is shown if the file was renamed.
+// Lines of root commits start with ^
+func printBlame(lines []repository.BlameLine, path string) {
+	authorWidth, pathWidth, showPath := 0, 0, false
+	for _, line := range lines {
+		if len(getBlameAuthor(line).Name) > authorWidth {
+			authorWidth = len(getBlameAuthor(line).Name)
+		}
+		if len(line.Path) > pathWidth {
+			pathWidth = len(line.Path)
+		}
+		showPath = showPath || line.Path != path
+	}
+	lineNumberWidth := len(strconv.Itoa(lines[len(lines)-1].FinalLine))
+
+	for _, line := range lines {
+		sha := line.Sha[:8]
+		if line.Boundary {
+			sha = "^" + line.Sha[:7]
+		}
+		if showPath {
+			sha = sha + " " + line.Path + strings.Repeat(" ", pathWidth-len(line.Path))
+		}
+
+		author := getBlameAuthor(line)
+		fmt.Printf("%s (%-*s %s %*d) %s\n", sha, authorWidth, author.Name, author.When.Format(DATE_FORMAT_ISO), lineNumberWidth, line.FinalLine, line.Content)
+	}
+}
+
+// Format of git blame --porcelain. The information of a commit is shown the first time it appears
+func printBlamePorcelain(lines []repository.BlameLine) {
+	shown := make(map[string]bool)
+
+	for i, line := range lines {
+		header := line.Sha + " " + strconv.Itoa(line.OriginalLine) + " " + strconv.Itoa(line.FinalLine)
+		if i == 0 || !isSameBlameGroup(lines[i-1], line) {
+			groupSize := 1
+			for groupSize < len(lines)-i && isSameBlameGroup(lines[i+groupSize-1], lines[i+groupSize]) {
+				groupSize++
+			}
+			header = header + " " + strconv.Itoa(groupSize)
+		}
+		fmt.Println(header)
+
+		if !shown[line.Sha] {
+			shown[line.Sha] = true
+			printBlameCommitInfo(line)
+		}
+		fmt.Println("\t" + line.Content)
+	}
+}
+
+// Lines of a group come from consecutive lines of the same commit
+func isSameBlameGroup(previous repository.BlameLine, line repository.BlameLine) bool {
+	return previous.Sha == line.Sha && previous.OriginalLine+1 == line.OriginalLine
+}
+
+func printBlameCommitInfo(line repository.BlameLine) {
+	author, committer := getBlameAuthor(line), getBlameAuthor(line)
+	summary := "Version of " + line.Path + " from " + line.Path
+	if line.Sha != diff.NULL_SHA {
+		committer = line.Commit.CommitterSignature()
+		summary = line.Commit.Subject()
+	}
+
+	for _, role := range []struct {
+		name      string
+		signature objects.Signature
+	}{{"author", author}, {"committer", committer}} {
+		fmt.Println(role.name + " " + role.signature.Name)
+		fmt.Println(role.name + "-mail <" + role.signature.Email + ">")
+		fmt.Println(role.name + "-time " + strconv.FormatInt(role.signature.When.Unix(), 10))
+		fmt.Println(role.name + "-tz " + role.signature.When.Format("-0700"))
+	}
+	fmt.Println("summary " + summary)
+	if line.Boundary {
+		fmt.Println("boundary")
+	}
+	if line.PreviousSha != "" {
+		fmt.Println("previous " + line.PreviousSha + " " + line.PreviousPath)
+	}
+	fmt.Println("filename " + line.Path)
+}
+
+func getBlameAuthor(line repository.BlameLine) objects.Signature {
+	if line.Sha == diff.NULL_SHA {
+		return objects.CreateSignature(NOT_COMMITTED_NAME, NOT_COMMITTED_EMAIL, time.Now())
+	}
+
+	return line.Commit.AuthorSignature()
+}
@@ -1,31 +1,164 @@
 package commands
 
 import (
+	"bufio"
+	"fmt"
 	"git/src/objects"
 	"git/src/repository"
 	"git/src/utils"
 	"os"
+	"strconv"
 )
 
-// CatFile Takes sha of gitobject and prints its contents
-// CatFile Args: main.go cat-file <sha>
+// CatFile Prints the content, type or size of objects
+// CatFile Args: main.go cat-file <sha> (raw object with its header)
+// CatFile Args: main.go cat-file <type> <object> | -t <object> | -s <object> | -p <object> | -e <object>
+// CatFile Args: main.go cat-file (--batch | --batch-check) [--buffer] (object names are read from stdin, one per line)
+// The batch output is flushed after every object, so it can be used as a coprocess, unless --buffer is given
 func CatFile(args []string) {
-	if len(args) != 3 {
-		utils.ExitError("Invalid arguments cat-file <type> <sha>")
+	if len(args) < 3 || len(args) > 4 {
+		utils.ExitError("Invalid arguments: cat-file (<type> | -t | -s | -p | -e) <object> | (--batch | --batch-check) [--buffer]")
 	}
 
-	sha := args[2]
-	currentPath := utils.CurrentPath()
-	currentRepository, _, err := repository.FindCurrentRepository(currentPath)
-
+	currentRepository, _, err := repository.FindCurrentRepository(utils.CurrentPath())
 	if err != nil {
 		utils.ExitError(err.Error())
 	}
 
-	object, err := currentRepository.ReadObject(sha, objects.ANY)
+	if len(args) == 4 && args[2] == "--buffer" {
+		args = []string{args[0], args[1], args[3], args[2]}
+	}
+	if batchMode := args[2]; batchMode == "--batch" || batchMode == "--batch-check" {
+		if len(args) == 4 && args[3] != "--buffer" {
+			utils.ExitError("Unknown option " + args[3] + " for cat-file " + batchMode)
+		}
+		catFileBatch(currentRepository, batchMode == "--batch", len(args) == 4)
+		return
+	}
+
+	if len(args) == 3 {
+		object, err := currentRepository.ReadObject(args[2], objects.ANY)
+		if err != nil {
+			utils.ExitError("Cannot read object: " + err.Error())
+		}
+		_, _ = os.Stdout.Write(object.Serialize())
+		return
+	}
+
+	option, name := args[2], args[3]
+	if option == "-e" {
+		if _, _, err := currentRepository.ResolveObjectName(name, objects.ANY); err != nil {
+			os.Exit(1)
+		}
+		return
+	}
+
+	sha, _, err := currentRepository.ResolveObjectName(name, objects.ANY)
+	if err != nil {
+		utils.ExitError("fatal: Not a valid object name " + name)
+	}
+
+	switch option {
+	case "-t":
+		object := readRawObject(currentRepository, sha)
+		fmt.Println(object.Type)
+	case "-s":
+		object := readRawObject(currentRepository, sha)
+		fmt.Println(len(object.SerializableGitObject.Serialize()))
+	case "-p":
+		catFilePrettyPrint(currentRepository, sha)
+	default:
+		sha, _, err = currentRepository.ResolveObjectName(name, objects.ObjectType(option))
+		if err != nil {
+			utils.ExitError("fatal: git cat-file " + name + ": bad file")
+		}
+		_, _ = os.Stdout.Write(readRawObject(currentRepository, sha).SerializableGitObject.Serialize())
+	}
+}
+
+// Trees are listed like ls-tree. Other objects are printed as they are stored
+func catFilePrettyPrint(currentRepository *repository.Repository, sha string) {
+	object := readRawObject(currentRepository, sha)
+	if object.Type != objects.TREE {
+		_, _ = os.Stdout.Write(object.SerializableGitObject.Serialize())
+		return
+	}
+
+	for _, entry := range getTreeGitObject(currentRepository, sha).Entries {
+		fmt.Println(formatTreeEntry(currentRepository, entry))
+	}
+}
+
+// Format: <mode> <type> <sha>\t<path> with the mode padded to 6 digits
+func formatTreeEntry(currentRepository *repository.Repository, entry objects.TreeEntry) string {
+	objectType := objects.BLOB
+	if entry.IsDir() {
+		objectType = objects.TREE
+	} else if entry.IsGitlink() {
+		objectType = objects.COMMIT
+	}
+
+	mode := entry.Mode
+	for len(mode) < 6 {
+		mode = "0" + mode
+	}
+
+	return mode + " " + string(objectType) + " " + entry.Sha + "\t" + entry.Path
+}
+
+// For every object name of stdin prints <sha> <type> <size> (and the content if printContent) or <name> missing.
+// Without buffer the output is flushed after every object, since the caller may wait for it before writing the next name
+func catFileBatch(currentRepository *repository.Repository, printContent bool, buffer bool) {
+	scanner := bufio.NewScanner(os.Stdin)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	output := bufio.NewWriter(os.Stdout)
+	defer output.Flush()
+
+	for scanner.Scan() {
+		name := scanner.Text()
+		writeBatchObject(currentRepository, output, name, printContent)
+		if !buffer {
+			utils.CheckError(output.Flush())
+		}
+	}
+	utils.CheckError(scanner.Err())
+}
+
+func writeBatchObject(currentRepository *repository.Repository, output *bufio.Writer, name string, printContent bool) {
+	sha, err := resolveBatchObjectName(currentRepository, name)
+	if err != nil {
+		output.WriteString(name + " missing\n")
+		return
+	}
+	object, err := currentRepository.ReadRawObject(sha)
+	if err != nil {
+		output.WriteString(name + " missing\n")
+		return
+	}
+
+	content := object.SerializableGitObject.Serialize()
+	output.WriteString(sha + " " + string(object.Type) + " " + strconv.Itoa(len(content)) + "\n")
+	if printContent {
+		output.Write(content)
+		output.WriteString("\n")
+	}
+}
+
+// Full shas are not resolved, so reading thousands of objects only opens every object once
+func resolveBatchObjectName(currentRepository *repository.Repository, name string) (string, error) {
+	if currentRepository.HasObject(name) {
+		return name, nil
+	}
+
+	sha, _, err := currentRepository.ResolveObjectName(name, objects.ANY)
+	return sha, err
+}
+
+func readRawObject(currentRepository *repository.Repository, sha string) objects.Object {
+	object, err := currentRepository.ReadRawObject(sha)
 	if err != nil {
 		utils.ExitError("Cannot read object: " + err.Error())
 	}
 
-	_, _ = os.Stdout.Write(object.Serialize())
+	return object
 }
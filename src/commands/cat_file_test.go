@@ -0,0 +1,62 @@
+package commands
+
+import (
+	"bufio"
+	"git/src/objects"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCatFile_BatchFlushesEveryObject(t *testing.T) {
+	currentRepository := createTestRepository(t)
+	sha, err := currentRepository.WriteObject(objects.CreateBlobObject([]byte("content")))
+	assert.Nil(t, err)
+
+	stdinReader, stdinWriter, err := os.Pipe()
+	assert.Nil(t, err)
+	stdoutReader, stdoutWriter, err := os.Pipe()
+	assert.Nil(t, err)
+	stdin, stdout := os.Stdin, os.Stdout
+	os.Stdin, os.Stdout = stdinReader, stdoutWriter
+	t.Cleanup(func() { os.Stdin, os.Stdout = stdin, stdout })
+
+	done := make(chan bool)
+	go func() {
+		catFileBatch(currentRepository, true, false)
+		stdoutWriter.Close()
+		done <- true
+	}()
+
+	//Like a coprocess, every answer is read before writing the next name
+	output := bufio.NewReader(stdoutReader)
+	_, err = stdinWriter.WriteString(sha + "\n")
+	assert.Nil(t, err)
+	assert.Equal(t, sha+" blob 7\n", readLineWithTimeout(t, output))
+	assert.Equal(t, "content\n", readLineWithTimeout(t, output))
+	_, err = stdinWriter.WriteString("missing\n")
+	assert.Nil(t, err)
+	assert.Equal(t, "missing missing\n", readLineWithTimeout(t, output))
+
+	assert.Nil(t, stdinWriter.Close())
+	<-done
+}
+
+// Fails instead of blocking forever if the line is never written
+func readLineWithTimeout(t *testing.T, reader *bufio.Reader) string {
+	line := make(chan string)
+	go func() {
+		read, _ := reader.ReadString('\n')
+		line <- read
+	}()
+
+	select {
+	case read := <-line:
+		return read
+	case <-time.After(5 * time.Second):
+		t.Fatal("no output was flushed")
+		return ""
+	}
+}
@@ -0,0 +1,80 @@
+package commands
+
+import (
+	"fmt"
+	"git/src/attributes"
+	"git/src/repository"
+	"git/src/utils"
+	"strings"
+)
+
+// CheckAttr Prints the state of the attributes of each path: set, unset, unspecified or its value
+// CheckAttr Args: main.go check-attr [-a | --all] [--cached] <attr>... [--] <path>...
+// Without --, the first argument is the attribute and the rest are paths. --all prints all the specified attributes
+// and only takes paths. --cached reads the .gitattributes of the index instead of the work tree
+func CheckAttr(args []string) {
+	all, cached := false, false
+	remaining := make([]string, 0)
+	for i, arg := range args[2:] {
+		if arg == "--" {
+			remaining = append(remaining, args[2+i:]...)
+			break
+		}
+
+		switch arg {
+		case "-a", "--all":
+			all = true
+		case "--cached":
+			cached = true
+		default:
+			if strings.HasPrefix(arg, "-") {
+				utils.ExitError("Unknown option " + arg + " for check-attr")
+			}
+			remaining = append(remaining, arg)
+		}
+	}
+	names, paths := splitCheckAttrArgs(remaining, all)
+	if len(paths) == 0 || (!all && len(names) == 0) {
+		utils.ExitError("Invalid arguments: check-attr [-a | --all] [--cached] <attr>... [--] <path>...")
+	}
+
+	currentRepository, _, err := repository.FindCurrentRepository(utils.CurrentPath())
+	utils.CheckError(err)
+
+	var repositoryAttributes *attributes.Attributes
+	if cached {
+		repositoryAttributes, err = currentRepository.ReadIndexAttributes()
+	} else {
+		repositoryAttributes, err = currentRepository.ReadAttributes()
+	}
+	utils.CheckError(err)
+
+	for _, path := range paths {
+		fileAttributes, err := repositoryAttributes.Get(currentRepository.AbsolutePathToRepositoryPath(currentRepository.GetPathFileInRepository(path)))
+		utils.CheckError(err)
+
+		if all {
+			names = fileAttributes.Names()
+		}
+		for _, name := range names {
+			fmt.Println(path + ": " + name + ": " + fileAttributes.Get(name))
+		}
+	}
+}
+
+// Returns the attribute names and the paths
+func splitCheckAttrArgs(args []string, all bool) ([]string, []string) {
+	for i, arg := range args {
+		if arg == "--" {
+			return args[:i], args[i+1:]
+		}
+	}
+
+	if all {
+		return []string{}, args
+	}
+	if len(args) == 0 {
+		return []string{}, []string{}
+	}
+	return args[:1], args[1:]
+}
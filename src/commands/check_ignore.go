@@ -16,6 +16,7 @@ func CheckIgnore(args []string) {
 	if err != nil {
 		utils.ExitError(err.Error())
 	}
+	utils.CheckError(currentRepository.RequireWorkTree())
 
 	for _, fileNameToCheck := range args[2:] {
 		ignored, err := currentRepository.IsIgnored(fileNameToCheck)
@@ -1,13 +1,18 @@
 package commands
 
 import (
+	"git/src/attributes"
+	"git/src/index"
 	"git/src/objects"
 	"git/src/repository"
+	"git/src/sparse"
 	"git/src/utils"
 	"os"
+	"path/filepath"
 )
 
 // Checkout Args: main.go checkout <sha>
+// The post-checkout hook is run after HEAD is updated. Its exit code becomes the exit code of checkout
 func Checkout(args []string) {
 	if len(args) != 3 {
 		utils.ExitError("Invalid arguments checkout <sha>")
@@ -15,16 +20,40 @@ func Checkout(args []string) {
 
 	currentRepository, repositoryPath, err := repository.FindCurrentRepository(utils.CurrentPath())
 	utils.CheckError(err)
+	utils.CheckError(currentRepository.RequireWorkTree())
 
 	objectNameUnResolved := args[2]
 	sha, isHead, err := currentRepository.ResolveObjectName(objectNameUnResolved, objects.ANY)
 	utils.CheckError(err)
 
+	if worktree, checkedOut := currentRepository.FindWorktreeWithBranch(objectNameUnResolved); isHead && checkedOut && worktree.GitDir != currentRepository.GitDir {
+		utils.ExitError("fatal: '" + objectNameUnResolved + "' is already checked out at '" + worktree.Path + "'")
+	}
+
 	commitGitObjet := getCommitObject(currentRepository, sha)
-	treeObject := getTreeGitObject(currentRepository, commitGitObjet.Tree)
+	previousHead := getHeadSha(currentRepository)
 
-	restoreRecursive(currentRepository, treeObject, repositoryPath)
+	checkoutTree(currentRepository, commitGitObjet.Tree, repositoryPath)
 	updateHead(isHead, currentRepository, sha, objectNameUnResolved)
+
+	runPostCheckoutHook(currentRepository, previousHead, getHeadSha(currentRepository))
+}
+
+// Returns objects.NO_PARENT_COMMIT_SHA if HEAD has no commits
+func getHeadSha(currentRepository *repository.Repository) string {
+	if head, err := currentRepository.ResolveRef("HEAD"); err == nil {
+		return head.Value
+	}
+	return objects.NO_PARENT_COMMIT_SHA
+}
+
+// Branch checkouts pass 1 as the last argument. Checkouts of files, which are not supported yet, pass 0
+func runPostCheckoutHook(currentRepository *repository.Repository, previousHead string, newHead string) {
+	err := currentRepository.RunHook(repository.POST_CHECKOUT_HOOK, repository.HookOptions{Args: []string{previousHead, newHead, "1"}})
+	if hookError, isHookError := err.(repository.HookError); isHookError {
+		os.Exit(hookError.ExitCode)
+	}
+	utils.CheckError(err)
 }
 
 func updateHead(isHead bool, currentRepository *repository.Repository, sha string, objectNameUnResolved string) {
@@ -35,39 +64,118 @@ func updateHead(isHead bool, currentRepository *repository.Repository, sha strin
 	}
 }
 
-func restoreRecursive(currentRepository *repository.Repository, tree objects.TreeObject, currentPath string) {
+// checkoutTree Writes the files of the tree in the work tree and replaces the index entries with them.
+// With sparse checkout, the files not matching the patterns get the skip-worktree bit instead of being written
+func checkoutTree(currentRepository *repository.Repository, treeSha string, workTreePath string) {
+	treeObject := getTreeGitObject(currentRepository, treeSha)
+	indexObject, err := currentRepository.ReadIndex()
+	utils.CheckError(err)
+	previousIndexObject := &index.IndexObject{Version: indexObject.Version, Entries: indexObject.Entries, ModTime: indexObject.ModTime}
+	indexObject.Entries = make(map[string]index.IndexEntry)
+	sparsePatterns, err := currentRepository.ReadSparsePatterns()
+	utils.CheckError(err)
+	fetchMissingBlobs(currentRepository, treeSha, sparsePatterns)
+	treeAttributes, err := currentRepository.ReadTreeAttributes(treeSha)
+	utils.CheckError(err)
+
+	restoreRecursive(currentRepository, treeObject, workTreePath, indexObject, treeAttributes, sparsePatterns)
+	removeSkippedFiles(currentRepository, previousIndexObject, indexObject)
+
+	utils.CheckError(currentRepository.WriteIndex(indexObject))
+}
+
+// Partial clones fetch the missing blobs of the files to check out in a single request, instead of one request per file
+func fetchMissingBlobs(currentRepository *repository.Repository, treeSha string, sparsePatterns *sparse.Patterns) {
+	if !currentRepository.IsPartialClone() {
+		return
+	}
+
+	files, err := currentRepository.ReadTreeFiles(treeSha)
+	utils.CheckError(err)
+	missing := make([]string, 0)
+	for path, entry := range files {
+		if !entry.IsGitlink() && sparsePatterns.Matches(path) && !currentRepository.HasObject(entry.Sha) {
+			missing = append(missing, entry.Sha)
+		}
+	}
+
+	if len(missing) > 0 {
+		utils.CheckError(currentRepository.FetchPromisedObjects(missing))
+	}
+}
+
+func restoreRecursive(currentRepository *repository.Repository, tree objects.TreeObject, currentPath string, indexObject *index.IndexObject,
+	treeAttributes *attributes.Attributes, sparsePatterns *sparse.Patterns) {
 	for _, treeEntry := range tree.Entries {
 		pathEntry := utils.Paths(currentPath, treeEntry.Path)
-		entryExistsInFS := utils.CheckFileOrDirExists(pathEntry)
+		pathRelativeRepo := currentRepository.AbsolutePathToRepositoryPath(pathEntry)
 
-		if !entryExistsInFS {
-			createTreeEntryInFS(treeEntry, pathEntry)
+		if !treeEntry.IsDir() && !sparsePatterns.Matches(pathRelativeRepo) {
+			indexEntry := index.IndexEntry{Sha: treeEntry.Sha, FullPathName: pathRelativeRepo, SkipWorktree: true}
+			utils.CheckError(indexEntry.SetMode(treeEntry.Mode))
+			indexObject.Entries[pathRelativeRepo] = indexEntry
+			continue
 		}
 
 		if !treeEntry.IsDir() {
-			blobGitObject := getBlobObject(currentRepository, treeEntry.Sha)
-			file, err := os.Open(pathEntry)
-			defer file.Close()
-			utils.Check(err, "Cannot open file "+pathEntry)
+			utils.Check(os.MkdirAll(filepath.Dir(pathEntry), 0777), "Cannot create directory: "+filepath.Dir(pathEntry))
+		}
+		if !utils.CheckFileOrDirExists(pathEntry) {
+			createTreeEntryInFS(treeEntry, pathEntry)
+		}
 
-			utils.Check(os.Truncate(pathEntry, 0), "Cannot clear file content: "+pathEntry)
-			_, err = file.Write(blobGitObject.Data)
-			utils.Check(err, "Cannot write to file "+pathEntry)
+		if treeEntry.IsGitlink() { //Submodule content is populated with submodule update
+			indexObject.Entries[pathRelativeRepo] = index.CreateGitlinkIndexEntry(pathRelativeRepo, treeEntry.Sha)
+		} else if !treeEntry.IsDir() {
+			restoreFile(currentRepository, treeEntry, pathEntry, treeAttributes)
 
+			stat, err := os.Lstat(pathEntry)
+			utils.Check(err, "Cannot get stat info of file "+pathEntry)
+			indexEntry := index.CreateIndexEntry(stat, pathRelativeRepo, treeEntry.Sha)
+			utils.CheckError(indexEntry.SetMode(treeEntry.Mode)) //The file system might not support the mode
+			indexObject.Entries[pathRelativeRepo] = indexEntry
 		} else {
 			entryTreeObject := getTreeGitObject(currentRepository, treeEntry.Sha)
-			restoreRecursive(currentRepository, entryTreeObject, pathEntry)
+			restoreRecursive(currentRepository, entryTreeObject, pathEntry, indexObject, treeAttributes, sparsePatterns)
 		}
 	}
 }
 
+// Files are created when they are restored, and directories when they have some file
 func createTreeEntryInFS(treeEntry objects.TreeEntry, fullPathEntry string) {
-	if treeEntry.IsDir() {
+	if treeEntry.IsGitlink() {
 		utils.Check(os.Mkdir(fullPathEntry, os.FileMode(0777)), "Cannot create directory: "+fullPathEntry)
-	} else {
-		file, err := os.Create(fullPathEntry)
-		utils.Check(err, "Cannot create file in "+fullPathEntry)
-		file.Close()
+	}
+}
+
+// Symlinks are written as plain files containing the target if core.symlinks is disabled.
+// The executable bit is only applied if core.filemode is enabled. The content of files is converted as set by their attributes
+func restoreFile(currentRepository *repository.Repository, treeEntry objects.TreeEntry, pathEntry string, treeAttributes *attributes.Attributes) {
+	blobGitObject := getBlobObject(currentRepository, treeEntry.Sha)
+	if stat, err := os.Lstat(pathEntry); err == nil && (stat.Mode()&os.ModeSymlink != 0 || treeEntry.IsSymlink()) {
+		utils.Check(os.Remove(pathEntry), "Cannot remove "+pathEntry)
+	}
+
+	if treeEntry.IsSymlink() && currentRepository.SymlinksEnabled() {
+		utils.Check(os.Symlink(string(blobGitObject.Data), pathEntry), "Cannot create symlink "+pathEntry)
+		return
+	}
+
+	content := blobGitObject.Data
+	if !treeEntry.IsSymlink() {
+		var err error
+		content, err = currentRepository.ConvertToWorkTree(treeAttributes, currentRepository.AbsolutePathToRepositoryPath(pathEntry), content)
+		utils.CheckError(err)
+	}
+
+	utils.Check(os.WriteFile(pathEntry, content, 0666), "Cannot write to file "+pathEntry)
+
+	if currentRepository.FileModeEnabled() && !treeEntry.IsSymlink() {
+		perms := os.FileMode(index.MODE_PERMS_REGULAR)
+		if treeEntry.IsExecutable() {
+			perms = os.FileMode(index.MODE_PERMS_EXECUTABLE)
+		}
+		utils.Check(os.Chmod(pathEntry, perms), "Cannot change mode of "+pathEntry)
 	}
 }
 
@@ -0,0 +1,85 @@
+package commands
+
+import (
+	"fmt"
+	"git/src/protocol"
+	"git/src/repository"
+	"git/src/utils"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Clone Clones a repository served with the smart HTTP protocol (Ex: the serve command) or a local one, and checks out its HEAD
+// With --filter the clone is partial: blob:none omits all the blobs and blob:limit=<n>[kmg] the ones of n bytes or more.
+// They are fetched from the remote when they are needed. Local clones ignore the filter
+// With --depth or --shallow-since the clone is shallow: only the last <depth> commits or the ones after the date are fetched.
+// A new clone has no history to deepen, so --deepen=<n> is the same as --depth=<n>. Local clones ignore them
+// Clone Args: main.go clone [--filter=<filter spec>] [--depth=<n> | --deepen=<n> | --shallow-since=<date>] <repository>
+// [<directory> default: name of the repository]
+func Clone(args []string) {
+	const usage = "Invalid arguments: clone [--filter=<filter spec>] [--depth=<n> | --deepen=<n> | --shallow-since=<date>] <repository> [<directory>]"
+	filterSpec, deepen, positional := "", protocol.DeepenOptions{}, make([]string, 0)
+	options := args[2:]
+	for i := 0; i < len(options); i++ {
+		arg := options[i]
+		if matchesOption(arg, "--filter") {
+			filterSpec = getOptionValue(options, &i, arg)
+		} else if isDeepenOption(arg) {
+			deepen = parseDeepenOption(arg, getOptionValue(options, &i, arg), deepen, usage)
+		} else if strings.HasPrefix(arg, "-") {
+			utils.ExitError(usage)
+		} else {
+			positional = append(positional, arg)
+		}
+	}
+	if len(positional) < 1 || len(positional) > 2 {
+		utils.ExitError(usage)
+	}
+	deepen.Relative = false
+	filter, err := protocol.ParseObjectFilter(filterSpec)
+	utils.CheckError(err)
+
+	url := positional[0]
+	directory := getCloneDirectoryName(url)
+	if len(positional) == 2 {
+		directory = positional[1]
+	}
+	workTreePath, err := filepath.Abs(directory)
+	utils.CheckError(err)
+	if entries, err := os.ReadDir(workTreePath); err == nil && len(entries) > 0 {
+		utils.ExitError("fatal: destination path '" + directory + "' already exists and is not an empty directory.")
+	}
+
+	fmt.Println("Cloning into '" + directory + "'...")
+	var cloned *repository.Repository
+	if repository.IsHttpUrl(url) {
+		cloned, err = repository.CloneHttpRepository(url, workTreePath, utils.Path(workTreePath, ".git"), filter, deepen)
+	} else {
+		if !filter.IsEmpty() {
+			fmt.Fprintln(os.Stderr, "warning: --filter is ignored in local clones")
+		}
+		if !deepen.IsEmpty() {
+			fmt.Fprintln(os.Stderr, "warning: --depth is ignored in local clones")
+		}
+		cloned, err = repository.CloneLocalRepository(url, workTreePath, utils.Path(workTreePath, ".git"))
+	}
+	utils.CheckError(err)
+
+	if head, err := cloned.ResolveRef("HEAD"); err == nil {
+		checkoutTree(cloned, getCommitObject(cloned, head.Value).Tree, cloned.WorkTree)
+	} else {
+		fmt.Println("warning: You appear to have cloned an empty repository.")
+	}
+}
+
+// Last component of the url without .git. Ex: http://localhost:8080/project.git -> project
+func getCloneDirectoryName(url string) string {
+	name := strings.TrimSuffix(strings.TrimRight(url, "/"), "/.git")
+	name = strings.TrimSuffix(name[strings.LastIndex(name, "/")+1:], ".git")
+	if name == "" {
+		utils.ExitError("fatal: cannot guess the directory name, specify a directory")
+	}
+
+	return name
+}
@@ -6,53 +6,296 @@ import (
 	"git/src/objects"
 	"git/src/repository"
 	"git/src/utils"
-	"io/ioutil"
+	"io"
 	"os"
 	"strings"
 )
 
-// Commit Args: main.go commit -m <message...>
-func Commit(args []string) {
-	if len(args) < 4 {
-		utils.ExitError("Invalid arguments: commit -m <message...>")
-	}
+type commitOptions struct {
+	messages    []string
+	messageFile string //-F. "-" reads the message from stdin
+	amend       bool
+	allowEmpty  bool
+	all         bool
+	noVerify    bool //Skips pre-commit and commit-msg hooks
+	sign        bool
+	signingKey  string //Overrides user.signingKey
+}
 
+const (
+	COMMIT_MESSAGE_FILE = "COMMIT_EDITMSG"
+	COMMIT_USAGE        = "Invalid arguments: commit [-a] [--amend] [--allow-empty] [-S[<key>]] (-m <message>... | -F <file>)"
+)
+
+// Commit Args: main.go commit [-a | --all] [--amend] [--allow-empty] [-n | --no-verify] [-S[<key>] | --gpg-sign[=<key>] | --no-gpg-sign]
+// Commit Args: (-m <message>... | -F <file>)
+// Several -m are joined as separate paragraphs. --amend replaces HEAD keeping its parents, author and, without -m or -F, its message.
+// Commits without changes are refused unless --allow-empty is given. Commits are signed with ssh keys if -S is given or
+// commit.gpgSign is enabled. Hooks pre-commit, prepare-commit-msg, commit-msg and post-commit are run like git does.
+// If a merge stopped with conflicts, the commit concludes it: MERGE_HEAD is the second parent and MERGE_MSG the default message
+func Commit(args []string) {
 	currentRepository, _, err := repository.FindCurrentRepository(utils.CurrentPath())
 	if err != nil {
 		utils.ExitError(err.Error())
 	}
+	utils.CheckError(currentRepository.RequireWorkTree())
 
-	if _, detached, _ := currentRepository.GetActiveBranch(); detached {
+	currentBranch, detached, _ := currentRepository.GetActiveBranch()
+	if detached {
 		utils.ExitError("You cannot commit changes while you are in a detached branch. You will have to checkout to head")
 	}
 
-	commitMessage := strings.Trim(strings.Join(args[3:], " "), "\"")
+	options := parseCommitOptions(args[2:], currentRepository.SignByDefault("commit"))
+	headSha := getParentCommit(currentRepository)
+	parents := make([]string, 0)
+	if headSha != objects.NO_PARENT_COMMIT_SHA {
+		parents = append(parents, headSha)
+	}
 
-	index, err := currentRepository.ReadIndex()
+	mergeHead, merging := readMergeHead(currentRepository)
+	if merging {
+		if options.amend {
+			utils.ExitError("fatal: You are in the middle of a merge -- cannot amend.")
+		}
+		parents = append(parents, mergeHead)
+		if len(options.messages) == 0 && options.messageFile == "" {
+			options.messageFile = utils.Path(currentRepository.GitDir, MERGE_MESSAGE_FILE)
+		}
+	}
+	if len(options.messages) == 0 && options.messageFile == "" && !options.amend {
+		utils.ExitError(COMMIT_USAGE)
+	}
+
+	var amendedCommit objects.CommitObject
+	if options.amend {
+		if headSha == objects.NO_PARENT_COMMIT_SHA {
+			utils.ExitError("fatal: You have nothing to amend.")
+		}
+		amendedCommit, err = currentRepository.ReadCommitObject(headSha)
+		utils.CheckError(err)
+		parents = amendedCommit.Parents
+	}
+
+	if options.all {
+		stageTrackedChanges(currentRepository)
+	}
+
+	hookEnv := []string{"GIT_INDEX_FILE=" + utils.Path(currentRepository.GitDir, "index")}
+	if !options.noVerify {
+		exitIfHookFails(currentRepository.RunHook(repository.PRE_COMMIT_HOOK, repository.HookOptions{Env: hookEnv}))
+	}
+
+	repositoryIndex, err := currentRepository.ReadIndex() //The pre-commit hook can change the index
 	utils.CheckError(err)
+	rootTreeSha := createBlobsAndTrees(repositoryIndex.ToTree(), currentRepository)
+	if !options.allowEmpty && !isCommittable(currentRepository, rootTreeSha, parents, len(repositoryIndex.Entries)) {
+		if options.amend {
+			utils.ExitError("You asked to amend the most recent commit, but doing so would make\nit empty. You can repeat your command with --allow-empty, " +
+				"or you can\nremove the commit entirely with \"git reset HEAD^\".")
+		}
+		utils.ExitError("nothing to commit, use \"git add\" to stage changes or --allow-empty")
+	}
 
-	rootTreeSha := createBlobsAndTrees(index.ToTree(), currentRepository)
+	message := getCommitMessage(currentRepository, options, amendedCommit, headSha, hookEnv)
 
-	commitSha := createCommitObject(rootTreeSha, commitMessage, currentRepository)
+	author := currentRepository.GetIdentity(repository.AUTHOR_ROLE).String()
+	if options.amend {
+		author = amendedCommit.Author
+	}
+	commitObject := objects.CreateCommitObjectWithParents(rootTreeSha, parents, author,
+		currentRepository.GetIdentity(repository.COMMITTER_ROLE).String(), message)
+
+	commitSha := writeCommitObject(currentRepository, commitObject, options)
+	err = currentRepository.UpdateRefsAtomically([]repository.RefUpdate{{Name: "refs/heads/" + currentBranch, OldValue: headSha, NewValue: commitSha}})
+	utils.CheckError(err)
+	if merging {
+		removeMergeState(currentRepository)
+	}
+
+	currentRepository.RunHook(repository.POST_COMMIT_HOOK, repository.HookOptions{Env: hookEnv}) //Its exit code doesnt affect the commit
 
 	fmt.Println("Commited changes:", commitSha)
 }
 
-func createCommitObject(treeSha string, commitMessage string, currentRepository *repository.Repository) string {
-	parentCommit := getParentCommit(currentRepository)
-	commitObject := objects.CreateCommitObject(treeSha, parentCommit, "Jaime", commitMessage)
+func parseCommitOptions(args []string, sign bool) commitOptions {
+	options := commitOptions{messages: make([]string, 0), sign: sign}
 
-	commitSha, err := currentRepository.WriteObject(commitObject)
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		switch {
+		case matchesOption(arg, "-m") || matchesOption(arg, "--message"):
+			options.messages = append(options.messages, getOptionValue(args, &i, arg))
+		case strings.HasPrefix(arg, "-m"):
+			options.messages = append(options.messages, arg[2:])
+		case matchesOption(arg, "-F") || matchesOption(arg, "--file"):
+			options.messageFile = getOptionValue(args, &i, arg)
+		case strings.HasPrefix(arg, "-F"):
+			options.messageFile = arg[2:]
+		case arg == "--amend":
+			options.amend = true
+		case arg == "--allow-empty":
+			options.allowEmpty = true
+		case arg == "-a" || arg == "--all":
+			options.all = true
+		case arg == "-n" || arg == "--no-verify":
+			options.noVerify = true
+		case arg == "--no-gpg-sign":
+			options.sign = false
+		case strings.HasPrefix(arg, "-S"):
+			options.sign, options.signingKey = true, arg[2:]
+		case arg == "--gpg-sign" || strings.HasPrefix(arg, "--gpg-sign="):
+			options.sign, options.signingKey = true, strings.TrimPrefix(strings.TrimPrefix(arg, "--gpg-sign"), "=")
+		case strings.HasPrefix(arg, "-"):
+			utils.ExitError("Unknown option " + arg + " for commit")
+		default:
+			utils.ExitError(COMMIT_USAGE)
+		}
+	}
+
+	if len(options.messages) > 0 && options.messageFile != "" {
+		utils.ExitError("fatal: Option -m cannot be combined with -F.")
+	}
+
+	return options
+}
+
+// Returns the commit in MERGE_HEAD and if a merge is in progress
+func readMergeHead(currentRepository *repository.Repository) (string, bool) {
+	content, err := os.ReadFile(utils.Path(currentRepository.GitDir, MERGE_HEAD_FILE))
+	if os.IsNotExist(err) {
+		return "", false
+	}
+	utils.CheckError(err)
+
+	return strings.TrimSpace(string(content)), true
+}
+
+// Stages the modifications and deletions of tracked files (-a). Untracked files are not added
+func stageTrackedChanges(currentRepository *repository.Repository) {
+	repositoryIndex, err := currentRepository.ReadIndex()
+	utils.CheckError(err)
+
+	files := make([]fileToStage, 0)
+	for path, indexEntry := range repositoryIndex.Entries {
+		_, change, _ := getWorkTreeChange(currentRepository, repositoryIndex, indexEntry)
+
+		switch {
+		case change == 'D':
+			delete(repositoryIndex.Entries, path)
+		case change == 'M' && indexEntry.IsGitlink():
+			checkedOutSha, _ := getSubmoduleCheckedOutCommit(currentRepository, path)
+			repositoryIndex.Entries[path] = index.CreateGitlinkIndexEntry(path, checkedOutSha)
+		case change == 'M':
+			fullPath := utils.Path(currentRepository.WorkTree, path)
+			stat, err := os.Lstat(fullPath)
+			utils.CheckError(err)
+			files = append(files, fileToStage{path: fullPath, stat: stat, pathRelativeRepo: path, prevIndexEntry: indexEntry, prevIndexEntryExists: true})
+		}
+	}
+	stageFiles(currentRepository, repositoryIndex, files)
+
+	utils.CheckError(currentRepository.WriteIndex(repositoryIndex))
+}
+
+// A commit is committable if its tree differs from the tree of its first parent. Root commits need some file.
+// Amending a merge commit is always allowed
+func isCommittable(currentRepository *repository.Repository, treeSha string, parents []string, indexEntries int) bool {
+	if len(parents) == 0 {
+		return indexEntries > 0
+	}
+	if len(parents) > 1 {
+		return true
+	}
+
+	parentCommit, err := currentRepository.ReadCommitObject(parents[0])
 	utils.CheckError(err)
+	return parentCommit.Tree != treeSha
+}
+
+// The message is written to .git/COMMIT_EDITMSG, where the prepare-commit-msg and commit-msg hooks can edit it.
+// Then it is cleaned up: trailing whitespace and leading, trailing and repeated blank lines are removed
+func getCommitMessage(currentRepository *repository.Repository, options commitOptions, amendedCommit objects.CommitObject, headSha string, hookEnv []string) string {
+	message, source := "", []string{}
+	switch {
+	case len(options.messages) > 0:
+		message, source = strings.Join(options.messages, "\n\n"), []string{"message"}
+	case options.messageFile != "":
+		message, source = readCommitMessageFile(options.messageFile), []string{"message"}
+	case options.amend:
+		message, source = amendedCommit.Message, []string{"commit", headSha}
+	}
+
+	messagePath := utils.Path(currentRepository.GitDir, COMMIT_MESSAGE_FILE)
+	utils.CheckError(os.WriteFile(messagePath, []byte(withTrailingNewline(message)), 0644))
 
-	currentBranch, _, err := currentRepository.GetActiveBranch()
+	exitIfHookFails(currentRepository.RunHook(repository.PREPARE_COMMIT_MSG_HOOK, repository.HookOptions{Args: append([]string{messagePath}, source...), Env: hookEnv}))
+	if !options.noVerify {
+		exitIfHookFails(currentRepository.RunHook(repository.COMMIT_MSG_HOOK, repository.HookOptions{Args: []string{messagePath}, Env: hookEnv}))
+	}
+
+	editedMessage, err := os.ReadFile(messagePath)
 	utils.CheckError(err)
+	message = cleanupCommitMessage(string(editedMessage))
+	if message == "" {
+		utils.ExitError("Aborting commit due to empty commit message.")
+	}
 
-	file, err := os.OpenFile(utils.Paths(currentRepository.GitDir, "refs", "heads", currentBranch), os.O_WRONLY, 07777)
-	defer file.Close()
+	return message
+}
+
+func readCommitMessageFile(path string) string {
+	var content []byte
+	var err error
+	if path == "-" {
+		content, err = io.ReadAll(os.Stdin)
+	} else {
+		content, err = os.ReadFile(path)
+	}
+	if err != nil {
+		utils.ExitError("fatal: could not read log file '" + path + "': " + err.Error())
+	}
+
+	return string(content)
+}
+
+// cleanupCommitMessage Same as git cleanup mode whitespace. Returns an empty string if there is no content
+func cleanupCommitMessage(message string) string {
+	lines := make([]string, 0)
+	for _, line := range strings.Split(message, "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" && (len(lines) == 0 || lines[len(lines)-1] == "") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// Hooks print their own errors, so the command only exits
+func exitIfHookFails(err error) {
+	if repository.IsHookError(err) {
+		os.Exit(1)
+	}
 	utils.CheckError(err)
+}
+
+func writeCommitObject(currentRepository *repository.Repository, commitObject *objects.Object, options commitOptions) string {
+	if options.sign {
+		signer, err := currentRepository.GetSigningKey(options.signingKey)
+		utils.CheckError(err)
+		commitObject, err = repository.SignCommit(commitObject, signer)
+		utils.CheckError(err)
+	}
 
-	_, err = file.Write([]byte(commitSha))
+	commitSha, err := currentRepository.WriteObject(commitObject)
 	utils.CheckError(err)
 
 	return commitSha
@@ -69,55 +312,35 @@ func getParentCommit(currentRepository *repository.Repository) string {
 	}
 }
 
-func createBlobsAndTrees(node *index.IndexObjectTreeNode, repository *repository.Repository) string {
-	objectsCreated := make(map[string]*index.IndexObjectTreeNode) //Sha -> indexEntry tree node
-
-	for _, child := range node.Children {
-		sha := createAndGetSha(child, repository)
-		objectsCreated[sha] = child
-	}
-
-	return createTreeObject(objectsCreated, node, repository)
-}
-
-func createAndGetSha(node *index.IndexObjectTreeNode, repository *repository.Repository) string {
-	if len(node.Children) == 0 { //is file
-		indexEntryNode := node.Entry
-		file, err := os.Open(indexEntryNode.FullPathName)
-		defer file.Close()
-		utils.CheckError(err)
-		bytes, err := ioutil.ReadAll(file)
-		utils.CheckError(err)
+// Files and submodule commits are already stored when they are staged, so only the trees are written. They are built
+// from the leaves to compute their shas and then written in parallel
+func createBlobsAndTrees(node *index.IndexObjectTreeNode, currentRepository *repository.Repository) string {
+	trees := make([]*objects.Object, 0)
+	rootTreeSha := createTreeObject(node, &trees)
 
-		blobObject := objects.CreateBlobObject(bytes)
-		sha, err := repository.WriteObject(blobObject)
-		utils.CheckError(err)
+	_, err := currentRepository.WriteObjects(trees)
+	utils.CheckError(err)
 
-		return sha
-	} else {
-		return createBlobsAndTrees(node, repository)
-	}
+	return rootTreeSha
 }
 
-func createTreeObject(children map[string]*index.IndexObjectTreeNode, parent *index.IndexObjectTreeNode, repository *repository.Repository) string {
-	treeEntries := make([]objects.TreeEntry, 0)
+// Returns the sha of the tree of the node. Entries are a slice, since files with the same content have the same sha
+func createTreeObject(node *index.IndexObjectTreeNode, trees *[]*objects.Object) string {
+	treeEntries := make([]objects.TreeEntry, 0, len(node.Children))
 
-	for sha, indexEntryTreeNode := range children {
-		a := objects.TreeEntry{
-			Sha:  sha,
-			Path: indexEntryTreeNode.Name,
+	for _, child := range node.Children {
+		if len(child.Children) == 0 {
+			treeEntries = append(treeEntries, objects.TreeEntry{Mode: child.Entry.Mode(), Sha: child.Entry.Sha, Path: child.Name})
+		} else {
+			treeEntries = append(treeEntries, objects.TreeEntry{Mode: objects.TREE_MODE_DIR, Sha: createTreeObject(child, trees), Path: child.Name})
 		}
-
-		treeEntries = append(treeEntries, a)
 	}
 
 	treeObject := &objects.Object{
 		Type:                  objects.TREE,
 		SerializableGitObject: objects.TreeObject{Entries: treeEntries},
 	}
+	*trees = append(*trees, treeObject)
 
-	treeSha, err := repository.WriteObject(treeObject)
-	utils.CheckError(err)
-
-	return treeSha
+	return treeObject.Sha()
 }
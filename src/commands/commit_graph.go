@@ -0,0 +1,26 @@
+package commands
+
+import (
+	"git/src/repository"
+	"git/src/utils"
+)
+
+// CommitGraph Writes or verifies .git/objects/info/commit-graph, used to walk the history without reading commit objects
+// CommitGraph Args: main.go commit-graph (write | verify)
+func CommitGraph(args []string) {
+	if len(args) != 3 {
+		utils.ExitError("Invalid arguments: commit-graph (write | verify)")
+	}
+	currentRepository, _, err := repository.FindCurrentRepository(utils.CurrentPath())
+	utils.CheckError(err)
+
+	switch args[2] {
+	case "write":
+		_, err = currentRepository.WriteCommitGraph()
+		utils.CheckError(err)
+	case "verify":
+		utils.CheckError(currentRepository.VerifyCommitGraph())
+	default:
+		utils.ExitError("Invalid arguments: commit-graph (write | verify)")
+	}
+}
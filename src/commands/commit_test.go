@@ -0,0 +1,139 @@
+package commands
+
+import (
+	"git/src/objects"
+	"git/src/repository"
+	"os"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommit_AmendRootCommit(t *testing.T) {
+	currentRepository := createTestRepository(t)
+	writeTestFile(t, "first.txt", "first\n")
+	commitTestChanges(t, "root", "first.txt")
+	root := readTestCommit(t, currentRepository, getTestHead(t))
+
+	writeTestFile(t, "second.txt", "second\n")
+	Add([]string{"main.go", "add", "second.txt"})
+	Commit([]string{"main.go", "commit", "--amend", "-m", "reworded"})
+	amended := readTestCommit(t, currentRepository, getTestHead(t))
+
+	assert.Empty(t, amended.Parents)
+	assert.Equal(t, "reworded\n", amended.Message)
+	assert.Equal(t, root.Author, amended.Author)
+	assert.Equal(t, []string{"first.txt", "second.txt"}, getTestTreeFiles(t, currentRepository, amended.Tree))
+
+	writeTestFile(t, "first.txt", "changed\n")
+	Add([]string{"main.go", "add", "first.txt"})
+	Commit([]string{"main.go", "commit", "--amend"}) //Keeps the message
+	amendedAgain := readTestCommit(t, currentRepository, getTestHead(t))
+	assert.Empty(t, amendedAgain.Parents)
+	assert.Equal(t, "reworded\n", amendedAgain.Message)
+	assert.NotEqual(t, amended.Tree, amendedAgain.Tree)
+}
+
+func TestCommit_AmendKeepsParents(t *testing.T) {
+	currentRepository := createTestRepository(t)
+	writeTestFile(t, "file.txt", "first\n")
+	commitTestChanges(t, "first", "file.txt")
+	first := getTestHead(t)
+	writeTestFile(t, "file.txt", "second\n")
+	commitTestChanges(t, "second", "file.txt")
+
+	Commit([]string{"main.go", "commit", "--amend", "-m", "second reworded"})
+	amended := readTestCommit(t, currentRepository, getTestHead(t))
+	assert.Equal(t, []string{first}, amended.Parents)
+	assert.Equal(t, "second reworded\n", amended.Message)
+}
+
+func TestCommit_AllowEmpty(t *testing.T) {
+	currentRepository := createTestRepository(t)
+	writeTestFile(t, "file.txt", "file\n")
+	commitTestChanges(t, "first", "file.txt")
+	first := getTestHead(t)
+
+	Commit([]string{"main.go", "commit", "--allow-empty", "-m", "empty"})
+	empty := readTestCommit(t, currentRepository, getTestHead(t))
+
+	assert.Equal(t, []string{first}, empty.Parents)
+	assert.Equal(t, readTestCommit(t, currentRepository, first).Tree, empty.Tree)
+	assert.Equal(t, "empty\n", empty.Message)
+}
+
+func TestCommit_AllStagesModificationsAndDeletions(t *testing.T) {
+	currentRepository := createTestRepository(t)
+	assert.Nil(t, os.Mkdir("dir", os.ModePerm))
+	writeTestFile(t, "modified.txt", "modified\n")
+	writeTestFile(t, "deleted.txt", "deleted\n")
+	writeTestFile(t, "dir/deleted.txt", "deleted\n")
+	writeTestFile(t, "kept.txt", "kept\n")
+	commitTestChanges(t, "first", ".")
+
+	writeTestFile(t, "modified.txt", "modified in the work tree\n")
+	assert.Nil(t, os.Remove("deleted.txt"))
+	assert.Nil(t, os.RemoveAll("dir"))
+	writeTestFile(t, "untracked.txt", "untracked\n")
+	Commit([]string{"main.go", "commit", "-a", "-m", "all"})
+
+	commit := readTestCommit(t, currentRepository, getTestHead(t))
+	assert.Equal(t, []string{"kept.txt", "modified.txt"}, getTestTreeFiles(t, currentRepository, commit.Tree))
+	files, err := currentRepository.ReadTreeFiles(commit.Tree)
+	assert.Nil(t, err)
+	assert.Equal(t, objects.CreateBlobObject([]byte("modified in the work tree\n")).Sha(), files["modified.txt"].Sha)
+	assert.Equal(t, "?? untracked.txt\n", getStatusOutput(t, "--porcelain"))
+}
+
+func TestCommit_MessageFile(t *testing.T) {
+	currentRepository := createTestRepository(t)
+	writeTestFile(t, "file.txt", "first\n")
+	Add([]string{"main.go", "add", "file.txt"})
+	assert.Nil(t, os.MkdirAll("messages", os.ModePerm))
+	writeTestFile(t, "messages/message", "\n\nsubject  \n\n\n\nbody\t\n\n")
+
+	Commit([]string{"main.go", "commit", "-F", "messages/message"})
+	assert.Equal(t, "subject\n\nbody\n", readTestCommit(t, currentRepository, getTestHead(t)).Message)
+
+	writeTestFile(t, "file.txt", "second\n")
+	Add([]string{"main.go", "add", "file.txt"})
+	writeTestFile(t, "messages/message", "from --file\n")
+	Commit([]string{"main.go", "commit", "--file=messages/message"})
+	assert.Equal(t, "from --file\n", readTestCommit(t, currentRepository, getTestHead(t)).Message)
+}
+
+func TestCommit_IsCommittable(t *testing.T) {
+	currentRepository := createTestRepository(t)
+	writeTestFile(t, "file.txt", "first\n")
+	commitTestChanges(t, "first", "file.txt")
+	first := getTestHead(t)
+	firstTree := readTestCommit(t, currentRepository, first).Tree
+	writeTestFile(t, "file.txt", "second\n")
+	commitTestChanges(t, "second", "file.txt")
+	second := getTestHead(t)
+	secondTree := readTestCommit(t, currentRepository, second).Tree
+
+	assert.False(t, isCommittable(currentRepository, firstTree, []string{}, 0))
+	assert.True(t, isCommittable(currentRepository, firstTree, []string{}, 1))
+	assert.False(t, isCommittable(currentRepository, secondTree, []string{second}, 1))
+	assert.True(t, isCommittable(currentRepository, firstTree, []string{second}, 1))
+	assert.True(t, isCommittable(currentRepository, secondTree, []string{second, first}, 1))
+}
+
+func readTestCommit(t *testing.T, currentRepository *repository.Repository, sha string) objects.CommitObject {
+	commit, err := currentRepository.ReadCommitObject(sha)
+	assert.Nil(t, err)
+	return commit
+}
+
+func getTestTreeFiles(t *testing.T, currentRepository *repository.Repository, treeSha string) []string {
+	files, err := currentRepository.ReadTreeFiles(treeSha)
+	assert.Nil(t, err)
+	paths := make([]string, 0, len(files))
+	for path, _ := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
@@ -0,0 +1,369 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"git/src/config"
+	"git/src/repository"
+	"git/src/utils"
+	"os"
+	"regexp"
+	"strings"
+)
+
+const (
+	CONFIG_LIST  = "list"
+	CONFIG_GET   = "get"
+	CONFIG_SET   = "set"
+	CONFIG_UNSET = "unset"
+)
+
+// Exit codes of config, the same as git
+const (
+	CONFIG_EXIT_INVALID_KEY   = 1
+	CONFIG_EXIT_NOT_FOUND     = 1
+	CONFIG_EXIT_NO_SECTION    = 2
+	CONFIG_EXIT_INVALID_FILE  = 3
+	CONFIG_EXIT_WRITE_FAILED  = 4
+	CONFIG_EXIT_CANNOT_UNSET  = 5
+	CONFIG_EXIT_INVALID_REGEX = 6
+)
+
+type configOptions struct {
+	action       string
+	scope        string //Empty: all scopes when reading, local when writing
+	file         string
+	all          bool //get: every value. set: replace every value. unset: remove every value
+	appendValue  bool
+	regexp       bool //get: the name is a regex matched against all keys
+	valuePattern string
+	fixedValue   bool //The value pattern is a string instead of a regex
+	valueType    string
+	defaultValue string
+	hasDefault   bool
+	includes     bool
+	includesSet  bool
+	showOrigin   bool
+	showScope    bool
+	nameOnly     bool
+	arguments    []string
+}
+
+// Config Reads and writes the system (/etc/gitconfig), global (~/.gitconfig), local (.git/config) and worktree (config.worktree) config files
+// Config Args: main.go config list [<scope>] [--show-origin] [--show-scope] [--name-only] [--[no-]includes]
+// Config Args: main.go config get [<scope>] [--all] [--regexp] [--value=<pattern>] [--fixed-value] [--type=<type>] [--default=<value>] <name>
+// Config Args: main.go config set [<scope>] [--all] [--append] [--value=<pattern>] [--fixed-value] [--type=<type>] <name> <value>
+// Config Args: main.go config unset [<scope>] [--all] [--value=<pattern>] [--fixed-value] <name>
+// Config Args: <scope>: --system | --global | --local | --worktree | -f <file> | --file=<file>. <type>: bool, int, bool-or-int or path
+// Config Args: old syntax: main.go config [<scope>] [-l | --get | --get-all | --get-regexp | --add | --replace-all | --unset | --unset-all] <name> [<value> [<value pattern>]]
+// Values of later scopes take precedence. Without scope, values are read from all of them and written to the local file
+func Config(args []string) {
+	options := parseConfigOptions(args[2:])
+	currentRepository, _, err := repository.FindCurrentRepository(utils.CurrentPath())
+	if err != nil && (options.scope == config.LOCAL_SCOPE || options.scope == config.WORKTREE_SCOPE) {
+		utils.ExitError("fatal: --" + options.scope + " can only be used inside a git repository")
+	}
+
+	switch options.action {
+	case CONFIG_LIST:
+		listConfig(readConfigScope(currentRepository, options), options)
+	case CONFIG_GET:
+		getConfig(readConfigScope(currentRepository, options), options)
+	case CONFIG_SET:
+		setConfig(currentRepository, options)
+	case CONFIG_UNSET:
+		unsetConfig(currentRepository, options)
+	}
+}
+
+func parseConfigOptions(args []string) configOptions {
+	options := configOptions{arguments: make([]string, 0)}
+	if len(args) > 0 && (args[0] == CONFIG_LIST || args[0] == CONFIG_GET || args[0] == CONFIG_SET || args[0] == CONFIG_UNSET) {
+		options.action, args = args[0], args[1:]
+	}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		switch {
+		case arg == "--system" || arg == "--global" || arg == "--local" || arg == "--worktree":
+			options.scope = strings.TrimPrefix(arg, "--")
+		case arg == "-f" || matchesOption(arg, "--file"):
+			options.file = getOptionValue(args, &i, arg)
+		case arg == "--all":
+			options.all = true
+		case arg == "--append":
+			options.appendValue = true
+		case arg == "--regexp":
+			options.regexp = true
+		case matchesOption(arg, "--value"):
+			options.valuePattern = getOptionValue(args, &i, arg)
+		case arg == "--fixed-value":
+			options.fixedValue = true
+		case matchesOption(arg, "--type") || matchesOption(arg, "-t"):
+			options.valueType = getOptionValue(args, &i, arg)
+		case arg == "--bool" || arg == "--int" || arg == "--bool-or-int" || arg == "--path":
+			options.valueType = strings.TrimPrefix(arg, "--")
+		case matchesOption(arg, "--default"):
+			options.defaultValue, options.hasDefault = getOptionValue(args, &i, arg), true
+		case arg == "--includes" || arg == "--no-includes":
+			options.includes, options.includesSet = arg == "--includes", true
+		case arg == "--show-origin":
+			options.showOrigin = true
+		case arg == "--show-scope":
+			options.showScope = true
+		case arg == "--name-only":
+			options.nameOnly = true
+		case options.action == "" && (arg == "-l" || arg == "--list"):
+			options.action = CONFIG_LIST
+		case options.action == "" && (arg == "--get" || arg == "--get-all" || arg == "--get-regexp"):
+			options.action, options.all, options.regexp = CONFIG_GET, arg != "--get", arg == "--get-regexp"
+		case options.action == "" && (arg == "--add" || arg == "--replace-all"):
+			options.action, options.appendValue, options.all = CONFIG_SET, arg == "--add", arg == "--replace-all"
+		case options.action == "" && (arg == "--unset" || arg == "--unset-all"):
+			options.action, options.all = CONFIG_UNSET, arg == "--unset-all"
+		case strings.HasPrefix(arg, "-") && arg != "-":
+			utils.ExitError("Unknown option " + arg + " for config")
+		default:
+			options.arguments = append(options.arguments, arg)
+		}
+	}
+
+	return validateConfigOptions(options)
+}
+
+// Without action, the old syntax reads with one argument and writes with two. Old syntax accepts the value pattern as last argument
+func validateConfigOptions(options configOptions) configOptions {
+	if options.action == "" && len(options.arguments) == 1 {
+		options.action = CONFIG_GET
+	} else if options.action == "" {
+		options.action = CONFIG_SET
+	}
+
+	minArguments, maxArguments := map[string]int{CONFIG_LIST: 0, CONFIG_GET: 1, CONFIG_SET: 2, CONFIG_UNSET: 1}[options.action],
+		map[string]int{CONFIG_LIST: 0, CONFIG_GET: 2, CONFIG_SET: 3, CONFIG_UNSET: 2}[options.action]
+	if len(options.arguments) < minArguments || len(options.arguments) > maxArguments {
+		utils.ExitError("Invalid arguments: config (list | get <name> | set <name> <value> | unset <name>)")
+	}
+	if len(options.arguments) == maxArguments && maxArguments > 0 {
+		options.valuePattern = options.arguments[maxArguments-1]
+		options.arguments = options.arguments[:maxArguments-1]
+	}
+	if options.scope != "" && options.file != "" {
+		utils.ExitError("fatal: only one config file at a time")
+	}
+	if options.valueType != "" && options.valueType != config.BOOL_TYPE && options.valueType != config.INT_TYPE &&
+		options.valueType != config.BOOL_OR_INT_TYPE && options.valueType != config.PATH_TYPE {
+		utils.ExitError("fatal: unrecognized --type argument, " + options.valueType)
+	}
+
+	return options
+}
+
+// Includes are followed by default only when reading every scope
+func readConfigScope(currentRepository *repository.Repository, options configOptions) *config.Config {
+	sources, context := config.DefaultSources(), config.IncludeContext{}
+	if currentRepository != nil {
+		sources, context = config.RepositorySources(currentRepository.GitDir, currentRepository.CommonDir),
+			config.IncludeContext{GitDir: currentRepository.GitDir, CommonDir: currentRepository.CommonDir}
+	}
+	includes := options.scope == "" && options.file == ""
+	if options.includesSet {
+		includes = options.includes
+	}
+
+	switch {
+	case options.file != "":
+		sources = []config.Source{{Scope: config.COMMAND_SCOPE, Path: options.file}}
+	case options.scope == config.WORKTREE_SCOPE:
+		path, err := currentRepository.Config.ScopePath(config.WORKTREE_SCOPE)
+		utils.CheckError(err)
+		sources = []config.Source{{Scope: config.WORKTREE_SCOPE, Path: path}}
+	case options.scope != "":
+		sources = config.FilterSources(sources, options.scope)
+	}
+
+	scopeConfig, err := config.Load(sources, includes, context)
+	if err != nil {
+		exitConfigError("fatal: "+err.Error(), CONFIG_EXIT_INVALID_FILE)
+	}
+	return scopeConfig
+}
+
+func listConfig(scopeConfig *config.Config, options configOptions) {
+	for _, entry := range scopeConfig.Entries() {
+		fmt.Println(formatConfigEntry(entry, entry.Value, "=", options))
+	}
+}
+
+// Prints the last value of the key, or every value with --all. With --regexp, the name is a regex and every matching key is printed with its values
+func getConfig(scopeConfig *config.Config, options configOptions) {
+	valueMatcher := getConfigValueMatcher(options)
+	var keyMatcher func(key string) bool
+	if !options.regexp {
+		normalizedKey := normalizeConfigKey(options.arguments[0])
+		keyMatcher = func(key string) bool { return key == normalizedKey }
+	} else {
+		keyRegex, err := regexp.Compile(options.arguments[0])
+		if err != nil {
+			exitConfigError("error: invalid key pattern: "+options.arguments[0], CONFIG_EXIT_INVALID_REGEX)
+		}
+		keyMatcher = keyRegex.MatchString
+	}
+
+	matching := make([]config.Entry, 0)
+	for _, entry := range scopeConfig.Entries() {
+		if keyMatcher(entry.Key) && (valueMatcher == nil || valueMatcher(entry.Value)) {
+			matching = append(matching, entry)
+		}
+	}
+	if len(matching) == 0 && options.hasDefault {
+		matching = append(matching, config.Entry{Key: options.arguments[0], Value: options.defaultValue})
+	}
+	if len(matching) == 0 {
+		os.Exit(CONFIG_EXIT_NOT_FOUND)
+	}
+	if !options.all && !options.regexp {
+		matching = matching[len(matching)-1:]
+	}
+
+	for _, entry := range matching {
+		value, err := config.NormalizeValue(entry, options.valueType)
+		if err != nil {
+			utils.ExitError("fatal: " + err.Error())
+		}
+		if options.regexp {
+			fmt.Println(formatConfigEntry(entry, value, " ", options))
+		} else {
+			fmt.Println(formatConfigEntry(config.Entry{Scope: entry.Scope, Origin: entry.Origin}, value, "", options))
+		}
+	}
+}
+
+// Typed values are stored in their canonical form, except paths that are expanded when read
+func setConfig(currentRepository *repository.Repository, options configOptions) {
+	key, value := normalizeConfigKey(options.arguments[0]), options.arguments[1]
+	if options.valueType != "" && options.valueType != config.PATH_TYPE {
+		normalized, err := config.NormalizeValue(config.Entry{Key: key, Value: value}, options.valueType)
+		if err != nil {
+			utils.ExitError("fatal: " + err.Error())
+		}
+		value = normalized
+	}
+
+	file := readConfigFileToWrite(currentRepository, options)
+	var err error
+	if options.appendValue {
+		err = file.Add(options.arguments[0], value)
+	} else {
+		err = file.Set(options.arguments[0], value, getConfigValueMatcher(options), options.all)
+	}
+	if errors.Is(err, config.ErrMultipleValues) {
+		exitConfigError("warning: "+key+" has multiple values\nerror: cannot overwrite multiple values with a single value\n"+
+			"       Use --value=<pattern>, --append or --all to change "+key+".", CONFIG_EXIT_CANNOT_UNSET)
+	}
+	utils.CheckError(err)
+
+	if err := file.Save(); err != nil {
+		exitConfigError("error: "+err.Error(), CONFIG_EXIT_WRITE_FAILED)
+	}
+}
+
+func unsetConfig(currentRepository *repository.Repository, options configOptions) {
+	key := normalizeConfigKey(options.arguments[0])
+	file := readConfigFileToWrite(currentRepository, options)
+
+	err := file.Unset(options.arguments[0], getConfigValueMatcher(options), options.all)
+	if errors.Is(err, config.ErrNotFound) {
+		os.Exit(CONFIG_EXIT_CANNOT_UNSET)
+	}
+	if errors.Is(err, config.ErrMultipleValues) {
+		exitConfigError("warning: "+key+" has multiple values", CONFIG_EXIT_CANNOT_UNSET)
+	}
+	utils.CheckError(err)
+
+	if err := file.Save(); err != nil {
+		exitConfigError("error: "+err.Error(), CONFIG_EXIT_WRITE_FAILED)
+	}
+}
+
+// --file, or the file of the scope (local by default)
+func readConfigFileToWrite(currentRepository *repository.Repository, options configOptions) *config.File {
+	path := options.file
+	if path == "" {
+		scope := options.scope
+		if scope == "" {
+			scope = config.LOCAL_SCOPE
+		}
+
+		scopeConfig := &config.Config{}
+		if currentRepository != nil {
+			scopeConfig = currentRepository.Config
+		} else if scope == config.LOCAL_SCOPE {
+			utils.ExitError("fatal: not in a git directory")
+		}
+
+		var err error
+		path, err = scopeConfig.ScopePath(scope)
+		utils.CheckError(err)
+	}
+
+	file, err := config.ReadFile(path)
+	if err != nil {
+		exitConfigError("error: "+err.Error(), CONFIG_EXIT_INVALID_FILE)
+	}
+	return file
+}
+
+// Exits with 1 if the key is invalid and 2 if it doesnt have section or name
+func normalizeConfigKey(key string) string {
+	normalized, err := config.NormalizeKey(key)
+	if err != nil && (!strings.Contains(key, ".") || strings.HasSuffix(key, ".")) {
+		exitConfigError("error: "+err.Error(), CONFIG_EXIT_NO_SECTION)
+	} else if err != nil {
+		exitConfigError("error: "+err.Error(), CONFIG_EXIT_INVALID_KEY)
+	}
+
+	return normalized
+}
+
+// Patterns are regexes, negated if they start with "!", or plain strings with --fixed-value
+func getConfigValueMatcher(options configOptions) config.ValueMatcher {
+	pattern := options.valuePattern
+	if pattern == "" {
+		return nil
+	}
+	if options.fixedValue {
+		return func(value string) bool { return value == pattern }
+	}
+
+	negated := strings.HasPrefix(pattern, "!")
+	valueRegex, err := regexp.Compile(strings.TrimPrefix(pattern, "!"))
+	if err != nil {
+		exitConfigError("error: invalid pattern: "+pattern, CONFIG_EXIT_INVALID_REGEX)
+	}
+	return func(value string) bool { return valueRegex.MatchString(value) != negated }
+}
+
+// Format: [<scope>\t][file:<path>\t]<key><separator><value>. Keys without value are printed alone
+func formatConfigEntry(entry config.Entry, value string, separator string, options configOptions) string {
+	prefix := ""
+	if options.showScope {
+		prefix += entry.Scope + "\t"
+	}
+	if options.showOrigin && entry.Origin == "" {
+		prefix += "command line:\t"
+	} else if options.showOrigin {
+		prefix += "file:" + entry.Origin + "\t"
+	}
+
+	if options.nameOnly || (entry.NoValue && options.valueType == "") {
+		return prefix + entry.Key
+	}
+	return prefix + entry.Key + separator + value
+}
+
+func exitConfigError(message string, exitCode int) {
+	fmt.Fprintln(os.Stderr, message)
+	os.Exit(exitCode)
+}
@@ -0,0 +1,316 @@
+package commands
+
+import (
+	"fmt"
+	"git/src/attributes"
+	"git/src/diff"
+	"git/src/index"
+	"git/src/objects"
+	"git/src/repository"
+	"git/src/utils"
+	"os"
+	"strconv"
+	"strings"
+)
+
+type diffOptions struct {
+	cached        bool
+	nameOnly      bool
+	nameStatus    bool
+	commits       []string
+	paths         []string
+	renameOptions diff.RenameOptions
+}
+
+// Diff Shows the changes between the index and the work tree, a commit and the index (--cached), a commit and the work tree, or two commits
+// Diff Args: main.go diff [--cached | --staged] [<commit> [<commit>] | <commit>..<commit>] [--name-only | --name-status]
+// Diff Args: [-M[<n>] | --find-renames[=<n>]] [-C[<n>] | --find-copies[=<n>]] [--no-renames] [-l<n>] [-- <path>...]
+// Renames are detected by default unless diff.renames is false. If it is "copies", copies are detected too.
+// Inexact renames are not detected when there are too many files for diff.renameLimit (-l)
+func Diff(args []string) {
+	currentRepository, _, err := repository.FindCurrentRepository(utils.CurrentPath())
+	utils.CheckError(err)
+	options := parseDiffOptions(args[2:], getConfigRenameOptions(currentRepository, "diff"))
+
+	workTreeContents := make(map[string][]byte) //Sha -> content of modified files of the work tree, which are not stored as objects
+	oldFiles, newFiles := getFilesToDiff(currentRepository, options, workTreeContents)
+	readBlob := getBlobReaderWithWorkTree(currentRepository, workTreeContents)
+
+	changes, err := diff.DetectRenames(diff.DiffFileMaps(oldFiles, newFiles), options.renameOptions, readBlob)
+	utils.CheckError(err)
+	repositoryAttributes, err := currentRepository.ReadAttributes()
+	utils.CheckError(err)
+
+	for _, change := range changes {
+		if !matchesPathspecs(change, options.paths) {
+			continue
+		}
+
+		switch {
+		case options.nameOnly:
+			fmt.Println(change.Path())
+		case options.nameStatus:
+			fmt.Println(diff.FormatNameStatus(change))
+		default:
+			fmt.Print(formatChangePatch(currentRepository, repositoryAttributes, change, readBlob))
+		}
+	}
+}
+
+func parseDiffOptions(args []string, renameOptions diff.RenameOptions) diffOptions {
+	options := diffOptions{commits: make([]string, 0), paths: make([]string, 0), renameOptions: renameOptions}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		switch {
+		case arg == "--":
+			options.paths = append(options.paths, args[i+1:]...)
+			i = len(args)
+		case arg == "--cached" || arg == "--staged":
+			options.cached = true
+		case arg == "--name-only":
+			options.nameOnly = true
+		case arg == "--name-status":
+			options.nameStatus = true
+		case arg == "--no-renames":
+			options.renameOptions.DetectRenames, options.renameOptions.DetectCopies = false, false
+		case strings.HasPrefix(arg, "-M") || strings.HasPrefix(arg, "--find-renames"):
+			options.renameOptions.DetectRenames = true
+			options.renameOptions.RenameThreshold = parseSimilarityThresholdArg(strings.TrimPrefix(strings.TrimPrefix(strings.TrimPrefix(arg, "-M"), "--find-renames"), "="))
+		case strings.HasPrefix(arg, "-C") || strings.HasPrefix(arg, "--find-copies"):
+			options.renameOptions.DetectRenames, options.renameOptions.DetectCopies = true, true
+			options.renameOptions.CopyThreshold = parseSimilarityThresholdArg(strings.TrimPrefix(strings.TrimPrefix(strings.TrimPrefix(arg, "-C"), "--find-copies"), "="))
+		case strings.HasPrefix(arg, "-l") && len(arg) > 2:
+			renameLimit, err := strconv.Atoi(arg[2:])
+			if err != nil {
+				utils.ExitError("Invalid rename limit " + arg[2:])
+			}
+			options.renameOptions.RenameLimit = renameLimit
+		case strings.HasPrefix(arg, "-"):
+			utils.ExitError("Unknown option " + arg + " for diff")
+		case strings.Contains(arg, ".."):
+			options.commits = append(options.commits, strings.SplitN(arg, "..", 2)...)
+		default:
+			options.commits = append(options.commits, arg)
+		}
+	}
+
+	if len(options.commits) > 2 || (options.cached && len(options.commits) > 1) {
+		utils.ExitError("Invalid arguments: diff [--cached] [<commit> [<commit>]] [-- <path>...]")
+	}
+
+	return options
+}
+
+// Returns the old and new version of the files, depending on the number of commits and --cached
+func getFilesToDiff(currentRepository *repository.Repository, options diffOptions, workTreeContents map[string][]byte) (map[string]diff.FileEntry, map[string]diff.FileEntry) {
+	if len(options.commits) == 2 {
+		return getCommitFileEntries(currentRepository, resolveCommit(currentRepository, options.commits[0])),
+			getCommitFileEntries(currentRepository, resolveCommit(currentRepository, options.commits[1]))
+	}
+
+	repositoryIndex, err := currentRepository.ReadIndex()
+	utils.CheckError(err)
+
+	if options.cached {
+		oldFiles := make(map[string]diff.FileEntry)
+		if len(options.commits) == 1 {
+			oldFiles = getCommitFileEntries(currentRepository, resolveCommit(currentRepository, options.commits[0]))
+		} else if head, err := currentRepository.ResolveRef("HEAD"); err == nil {
+			oldFiles = getCommitFileEntries(currentRepository, head.Value)
+		}
+
+		return oldFiles, getIndexFileEntries(repositoryIndex)
+	}
+
+	utils.CheckError(currentRepository.RequireWorkTree())
+	newFiles := getWorkTreeFileEntries(currentRepository, repositoryIndex, workTreeContents)
+	utils.CheckError(currentRepository.WriteIndex(repositoryIndex)) //Stat info refreshed while comparing the work tree
+
+	if len(options.commits) == 1 {
+		return getCommitFileEntries(currentRepository, resolveCommit(currentRepository, options.commits[0])), newFiles
+	}
+
+	return getIndexFileEntries(repositoryIndex), newFiles
+}
+
+func resolveCommit(currentRepository *repository.Repository, name string) string {
+	sha, _, err := currentRepository.ResolveObjectName(name, objects.ANY)
+	utils.CheckError(err)
+
+	return sha
+}
+
+func getCommitFileEntries(currentRepository *repository.Repository, commitSha string) map[string]diff.FileEntry {
+	commitObject, err := currentRepository.ReadCommitObject(commitSha)
+	if err != nil {
+		utils.ExitError("Cannot get commit object from sha: " + commitSha + " error: " + err.Error())
+	}
+
+	return getTreeFileEntries(currentRepository, commitObject.Tree)
+}
+
+func getTreeFileEntries(currentRepository *repository.Repository, treeSha string) map[string]diff.FileEntry {
+	treeFiles, err := currentRepository.ReadTreeFiles(treeSha)
+	if err != nil {
+		utils.ExitError("Cannot get tree object from sha: " + treeSha + " error: " + err.Error())
+	}
+
+	fileEntries := make(map[string]diff.FileEntry)
+	for path, entry := range treeFiles {
+		fileEntries[path] = diff.FileEntry{Mode: entry.Mode, Sha: entry.Sha}
+	}
+
+	return fileEntries
+}
+
+func getIndexFileEntries(repositoryIndex *index.IndexObject) map[string]diff.FileEntry {
+	fileEntries := make(map[string]diff.FileEntry)
+	for path, entry := range repositoryIndex.Entries {
+		fileEntries[path] = diff.FileEntry{Mode: entry.Mode(), Sha: entry.Sha}
+	}
+
+	return fileEntries
+}
+
+// Only files tracked in the index are included. Modified files are hashed and their content is stored in workTreeContents
+func getWorkTreeFileEntries(currentRepository *repository.Repository, repositoryIndex *index.IndexObject, workTreeContents map[string][]byte) map[string]diff.FileEntry {
+	fileEntries := make(map[string]diff.FileEntry)
+
+	for path, indexEntry := range repositoryIndex.Entries {
+		workTreeMode, change, _ := getWorkTreeChange(currentRepository, repositoryIndex, indexEntry)
+
+		switch {
+		case change == 'D':
+			continue
+		case change == 'M' && indexEntry.IsGitlink():
+			checkedOutSha, _ := getSubmoduleCheckedOutCommit(currentRepository, path)
+			fileEntries[path] = diff.FileEntry{Mode: workTreeMode, Sha: checkedOutSha}
+		case change == 'M':
+			fullPath := utils.Path(currentRepository.WorkTree, path)
+			stat, err := os.Lstat(fullPath)
+			utils.CheckError(err)
+			sha, err := currentRepository.HashWorkTreeFile(fullPath, stat)
+			utils.CheckError(err)
+
+			workTreeContents[sha], err = currentRepository.ReadWorkTreeFileBlob(fullPath, stat)
+			utils.CheckError(err)
+			fileEntries[path] = diff.FileEntry{Mode: workTreeMode, Sha: sha}
+		default:
+			fileEntries[path] = diff.FileEntry{Mode: workTreeMode, Sha: indexEntry.Sha}
+		}
+	}
+
+	return fileEntries
+}
+
+func getBlobReader(currentRepository *repository.Repository) diff.BlobReader {
+	return getBlobReaderWithWorkTree(currentRepository, map[string][]byte{})
+}
+
+func getBlobReaderWithWorkTree(currentRepository *repository.Repository, workTreeContents map[string][]byte) diff.BlobReader {
+	return func(sha string) ([]byte, error) {
+		if content, inWorkTree := workTreeContents[sha]; inWorkTree {
+			return content, nil
+		}
+
+		blob, err := currentRepository.ReadBlobObject(sha)
+		return blob.Data, err
+	}
+}
+
+// The binary detection of the content is overridden by the diff attribute of the files
+func formatChangePatch(currentRepository *repository.Repository, repositoryAttributes *attributes.Attributes, change diff.Change, readBlob diff.BlobReader) string {
+	oldContent, newContent := readChangeContent(change.Old, readBlob), readChangeContent(change.New, readBlob)
+	binary := (change.OldPath != "" && isBinaryForDiff(currentRepository, repositoryAttributes, change.OldPath, oldContent)) ||
+		(change.NewPath != "" && isBinaryForDiff(currentRepository, repositoryAttributes, change.NewPath, newContent))
+
+	return diff.FormatPatchWithBinary(change, oldContent, newContent, binary)
+}
+
+// -diff (or binary) is always binary and diff never is. With diff=<driver>, diff.<driver>.binary decides if it is set
+func isBinaryForDiff(currentRepository *repository.Repository, repositoryAttributes *attributes.Attributes, path string, content []byte) bool {
+	fileAttributes, err := repositoryAttributes.Get(path)
+	utils.CheckError(err)
+
+	switch driver := fileAttributes.Get("diff"); driver {
+	case attributes.SET:
+		return false
+	case attributes.UNSET:
+		return true
+	case attributes.UNSPECIFIED:
+		return diff.IsBinary(content)
+	default:
+		binary, err := currentRepository.Config.Bool("diff."+driver+".binary", diff.IsBinary(content))
+		utils.CheckError(err)
+		return binary
+	}
+}
+
+// Submodule commits are not stored in this repository
+func readChangeContent(fileEntry diff.FileEntry, readBlob diff.BlobReader) []byte {
+	if fileEntry.Sha == "" || fileEntry.IsGitlink() {
+		return []byte{}
+	}
+
+	content, err := readBlob(fileEntry.Sha)
+	utils.CheckError(err)
+	return content
+}
+
+// Pathspecs are paths relative to the work tree. A directory matches all the files inside it
+func matchesPathspecs(change diff.Change, pathspecs []string) bool {
+	if len(pathspecs) == 0 {
+		return true
+	}
+
+	for _, path := range []string{change.OldPath, change.NewPath} {
+		if path != "" && matchesPathspec(path, pathspecs) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func matchesPathspec(path string, pathspecs []string) bool {
+	for _, pathspec := range pathspecs {
+		pathspec = strings.TrimSuffix(pathspec, "/")
+		if path == pathspec || strings.HasPrefix(path, pathspec+"/") || pathspec == "." {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Config <command>.renames falls back to diff.renames. Values: true, false or copies
+func getConfigRenameOptions(currentRepository *repository.Repository, command string) diff.RenameOptions {
+	options := diff.DefaultRenameOptions()
+
+	value := currentRepository.Config.String("diff.renames")
+	if commandValue := currentRepository.Config.String(command + ".renames"); commandValue != "" {
+		value = commandValue
+	}
+
+	switch strings.ToLower(value) {
+	case "false", "no", "off", "0":
+		options.DetectRenames = false
+	case "copies", "copy":
+		options.DetectCopies = true
+	}
+
+	renameLimit, err := currentRepository.Config.Int("diff.renameLimit", diff.DEFAULT_RENAME_LIMIT)
+	utils.CheckError(err)
+	options.RenameLimit = int(renameLimit)
+
+	return options
+}
+
+func parseSimilarityThresholdArg(value string) int {
+	threshold, err := diff.ParseSimilarityThreshold(value)
+	utils.CheckError(err)
+
+	return threshold
+}
@@ -0,0 +1,95 @@
+package commands
+
+import (
+	"fmt"
+	"git/src/protocol"
+	"git/src/repository"
+	"git/src/utils"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Fetch Downloads the branches and tags of a remote into refs/remotes/<remote>/ and refs/tags/. Local branches are not updated
+// In shallow repositories --depth=<n> limits the history to the last n commits of every remote branch, --deepen=<n> adds
+// n commits behind the current shallow commits, --shallow-since=<date> fetches the commits after the date and --unshallow
+// fetches the whole history. They need a remote served with the smart HTTP protocol
+// Fetch Args: main.go fetch [--depth=<n> | --deepen=<n> | --shallow-since=<date> | --unshallow] [<remote> default: origin]
+func Fetch(args []string) {
+	const usage = "Invalid arguments: fetch [--depth=<n> | --deepen=<n> | --shallow-since=<date> | --unshallow] [<remote>]"
+	currentRepository, _, err := repository.FindCurrentRepository(utils.CurrentPath())
+	utils.CheckError(err)
+
+	deepen, unshallow, positional := protocol.DeepenOptions{}, false, make([]string, 0)
+	options := args[2:]
+	for i := 0; i < len(options); i++ {
+		arg := options[i]
+		if arg == "--unshallow" {
+			unshallow = true
+		} else if isDeepenOption(arg) {
+			deepen = parseDeepenOption(arg, getOptionValue(options, &i, arg), deepen, usage)
+		} else if strings.HasPrefix(arg, "-") {
+			utils.ExitError(usage)
+		} else {
+			positional = append(positional, arg)
+		}
+	}
+	if len(positional) > 1 || (unshallow && !deepen.IsEmpty()) {
+		utils.ExitError(usage)
+	}
+	if unshallow {
+		if !currentRepository.IsShallow() {
+			utils.ExitError("fatal: --unshallow on a complete repository does not make sense")
+		}
+		deepen = protocol.DeepenOptions{Depth: protocol.INFINITE_DEPTH}
+	}
+
+	remoteName := repository.DEFAULT_REMOTE_NAME
+	if len(positional) == 1 {
+		remoteName = positional[0]
+	}
+	url := currentRepository.Config.String("remote." + remoteName + ".url")
+	if url == "" {
+		utils.ExitError("fatal: '" + remoteName + "' does not appear to be a git repository")
+	}
+
+	if repository.IsHttpUrl(url) {
+		utils.CheckError(currentRepository.FetchHttpRemote(remoteName, deepen))
+		return
+	}
+
+	if !deepen.IsEmpty() {
+		fmt.Fprintln(os.Stderr, "warning: --depth is ignored in local fetches")
+	}
+	source, err := repository.LoadRepository(url)
+	utils.CheckError(err)
+	utils.CheckError(currentRepository.FetchLocal(source, remoteName))
+}
+
+// The value can be given as --depth=<n> or --depth <n>
+func isDeepenOption(arg string) bool {
+	return matchesOption(arg, "--depth") || matchesOption(arg, "--deepen") || matchesOption(arg, "--shallow-since")
+}
+
+// Only one of --depth, --deepen and --shallow-since can be used
+func parseDeepenOption(arg string, value string, deepen protocol.DeepenOptions, usage string) protocol.DeepenOptions {
+	if !deepen.IsEmpty() {
+		utils.ExitError(usage)
+	}
+
+	name, _, _ := strings.Cut(arg, "=")
+	if name == "--shallow-since" {
+		date, err := utils.ParseDate(value, time.Now())
+		if err != nil {
+			utils.ExitError("fatal: invalid date " + value)
+		}
+		return protocol.DeepenOptions{Since: date.Unix()}
+	}
+
+	depth, err := strconv.Atoi(value)
+	if err != nil || depth <= 0 {
+		utils.ExitError("fatal: depth " + value + " is not a positive number")
+	}
+	return protocol.DeepenOptions{Depth: depth, Relative: name == "--deepen"}
+}
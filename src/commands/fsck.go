@@ -0,0 +1,44 @@
+package commands
+
+import (
+	"fmt"
+	"git/src/repository"
+	"git/src/utils"
+	"os"
+	"strings"
+)
+
+// Fsck Verifies the integrity of the objects and the connectivity of the repository
+// Fsck Args: main.go fsck [--unreachable] [--[no-]dangling]
+// Exit code combines: 1 corrupt objects, 2 missing objects, 4 dangling objects, 8 unreachable objects (only with --unreachable)
+func Fsck(args []string) {
+	currentRepository, _, err := repository.FindCurrentRepository(utils.CurrentPath())
+	utils.CheckError(err)
+
+	options := repository.FsckOptions{Dangling: true}
+	for _, arg := range args[2:] {
+		switch arg {
+		case "--unreachable":
+			options.Unreachable = true
+		case "--dangling":
+			options.Dangling = true
+		case "--no-dangling":
+			options.Dangling = false
+		default:
+			utils.ExitError("Invalid arguments: fsck [--unreachable] [--[no-]dangling]")
+		}
+	}
+
+	report, err := currentRepository.Fsck(options)
+	utils.CheckError(err)
+
+	for _, problem := range report.Problems { //Errors and warnings go to stderr like git
+		if strings.HasPrefix(problem.Message, "error") || strings.HasPrefix(problem.Message, "warning") {
+			fmt.Fprintln(os.Stderr, problem.Message)
+		} else {
+			fmt.Println(problem.Message)
+		}
+	}
+
+	os.Exit(report.ExitCode())
+}
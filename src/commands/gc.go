@@ -0,0 +1,32 @@
+package commands
+
+import (
+	"git/src/repository"
+	"git/src/utils"
+)
+
+// Gc Housekeeping of the repository. Loose objects are moved to a new pack and the multi-pack-index of all the packs is
+// rewritten, with reachability bitmaps unless repack.writeBitmaps is false or the repository is shallow or a partial clone.
+// The commit graph is refreshed unless gc.writeCommitGraph is false or the repository is shallow
+// Gc Args: main.go gc
+func Gc(args []string) {
+	if len(args) != 2 {
+		utils.ExitError("Invalid arguments: gc")
+	}
+	currentRepository, _, err := repository.FindCurrentRepository(utils.CurrentPath())
+	utils.CheckError(err)
+
+	packed, err := currentRepository.RepackLooseObjects()
+	utils.CheckError(err)
+	if packed > 0 || currentRepository.HasPacks() {
+		writeBitmap := currentRepository.Config.MustBool("repack.writeBitmaps", true) && !currentRepository.IsShallow() &&
+			!currentRepository.IsPartialClone()
+		_, err = currentRepository.WriteMultiPackIndex(writeBitmap)
+		utils.CheckError(err)
+	}
+
+	if currentRepository.Config.MustBool("gc.writeCommitGraph", true) && !currentRepository.IsShallow() {
+		_, err = currentRepository.WriteCommitGraph()
+		utils.CheckError(err)
+	}
+}
@@ -3,10 +3,26 @@ package commands
 import (
 	"git/src/repository"
 	"git/src/utils"
+	"os"
 )
 
-//Initializes git repository
-func Init() {
-	currentPath := utils.CurrentPath()
-	repository.InitializeRepository(currentPath)
+// Init Initializes git repository
+// Init Args: main.go init [--bare] [directory default: current path]
+func Init(args []string) {
+	bare := false
+	path := utils.CurrentPath()
+
+	for _, arg := range args[2:] {
+		if arg == "--bare" {
+			bare = true
+		} else {
+			path = arg
+		}
+	}
+
+	if !utils.CheckFileOrDirExists(path) {
+		utils.Check(os.MkdirAll(path, os.ModePerm), "Cannot create directory "+path)
+	}
+
+	repository.InitializeRepository(path, bare)
 }
@@ -0,0 +1,255 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"git/src/attributes"
+	"git/src/index"
+	"git/src/lfs"
+	"git/src/objects"
+	"git/src/repository"
+	"git/src/utils"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const LFS_TRACK_ATTRIBUTES = "filter=lfs diff=lfs merge=lfs -text"
+
+// lfsFile File of the index or a commit whose blob is an LFS pointer
+type lfsFile struct {
+	Path    string
+	Sha     string
+	Pointer lfs.Pointer
+}
+
+// Lfs Stores large files in .git/lfs/objects and commits pointer files instead. Files are tracked with the filter=lfs attribute,
+// whose built-in driver is used unless filter.lfs.* is configured
+// Lfs Args: main.go lfs track [<pattern>...]
+// Lfs Args: main.go lfs ls-files [-l | --long] [-s | --size] [-n | --name-only] [<commit>]
+// Lfs Args: main.go lfs pull [<remote>]
+// Missing objects are fetched from a local store: lfs.url, remote.<remote>.lfsurl or remote.<remote>.url (a repository or a directory)
+func Lfs(args []string) {
+	if len(args) < 3 {
+		utils.ExitError("Invalid arguments: lfs (track|ls-files|pull)")
+	}
+
+	currentRepository, _, err := repository.FindCurrentRepository(utils.CurrentPath())
+	utils.CheckError(err)
+	utils.CheckError(currentRepository.RequireWorkTree())
+
+	switch subcommand := args[2]; subcommand {
+	case "track":
+		lfsTrack(currentRepository, args[3:])
+	case "ls-files":
+		lfsLsFiles(currentRepository, args[3:])
+	case "pull":
+		lfsPull(currentRepository, args[3:])
+	default:
+		utils.ExitError("Unknown lfs subcommand " + subcommand)
+	}
+}
+
+// Without patterns, lists the patterns of the root .gitattributes with filter=lfs. Spaces of the patterns are written as [[:space:]]
+func lfsTrack(currentRepository *repository.Repository, patterns []string) {
+	gitAttributesPath := utils.Path(currentRepository.WorkTree, ".gitattributes")
+	content, err := os.ReadFile(gitAttributesPath)
+	if err != nil && !os.IsNotExist(err) {
+		utils.CheckError(err)
+	}
+
+	trackedPatterns := make(map[string]bool)
+	if len(patterns) == 0 {
+		fmt.Println("Listing tracked patterns")
+	}
+	for _, rule := range attributes.ParseRules(content, "") {
+		for _, assignment := range rule.Assignments {
+			if assignment.Name == "filter" && assignment.State == repository.LFS_FILTER_DRIVER {
+				trackedPatterns[rule.Pattern] = true
+				if len(patterns) == 0 {
+					fmt.Println("    " + rule.Pattern + " (.gitattributes)")
+				}
+			}
+		}
+	}
+
+	for _, pattern := range patterns {
+		pattern = strings.ReplaceAll(pattern, " ", "[[:space:]]")
+		if trackedPatterns[pattern] {
+			fmt.Println("\"" + pattern + "\" already supported")
+			continue
+		}
+
+		if len(content) > 0 && !bytes.HasSuffix(content, []byte("\n")) {
+			content = append(content, '\n')
+		}
+		content = append(content, []byte(pattern+" "+LFS_TRACK_ATTRIBUTES+"\n")...)
+		trackedPatterns[pattern] = true
+		fmt.Println("Tracking \"" + pattern + "\"")
+	}
+
+	if len(patterns) > 0 {
+		utils.CheckError(os.WriteFile(gitAttributesPath, content, 0666))
+	}
+}
+
+// Format: <oid (10 characters)> <* if the work tree has the content, - if it has the pointer> <path>
+func lfsLsFiles(currentRepository *repository.Repository, args []string) {
+	long, size, nameOnly, commit := false, false, false, ""
+	for _, arg := range args {
+		switch arg {
+		case "-l", "--long":
+			long = true
+		case "-s", "--size":
+			size = true
+		case "-n", "--name-only":
+			nameOnly = true
+		default:
+			if strings.HasPrefix(arg, "-") || commit != "" {
+				utils.ExitError("Invalid arguments: lfs ls-files [-l | --long] [-s | --size] [-n | --name-only] [<commit>]")
+			}
+			commit = arg
+		}
+	}
+
+	for _, file := range getLfsFiles(currentRepository, commit) {
+		if nameOnly {
+			fmt.Println(file.Path)
+			continue
+		}
+
+		oid, state := file.Pointer.Oid, "-"
+		if !long {
+			oid = oid[:10]
+		}
+		if content, err := os.ReadFile(utils.Path(currentRepository.WorkTree, file.Path)); err == nil {
+			if _, isPointer := lfs.ParsePointer(content); !isPointer {
+				state = "*"
+			}
+		}
+
+		line := oid + " " + state + " " + file.Path
+		if size {
+			line += " (" + formatLfsSize(file.Pointer.Size) + ")"
+		}
+		fmt.Println(line)
+	}
+}
+
+// Fetches the missing objects of the files of the index and replaces the pointers left in the work tree by their content
+func lfsPull(currentRepository *repository.Repository, args []string) {
+	if len(args) > 1 {
+		utils.ExitError("Invalid arguments: lfs pull [<remote>]")
+	}
+	remote := ""
+	if len(args) == 1 {
+		remote = args[0]
+	}
+
+	localStore, failed := currentRepository.LfsStore(), false
+	var remoteStore *lfs.Store
+	files := getLfsFiles(currentRepository, "")
+	for _, file := range files {
+		if localStore.Has(file.Pointer) {
+			continue
+		}
+		if remoteStore == nil {
+			store, err := currentRepository.LfsRemoteStore(remote)
+			utils.CheckError(err)
+			remoteStore = &store
+		}
+
+		fmt.Println("Downloading " + file.Path + " (" + formatLfsSize(file.Pointer.Size) + ")")
+		if err := localStore.Fetch(*remoteStore, file.Pointer); err != nil {
+			fmt.Fprintln(os.Stderr, "error: cannot download "+file.Path+": "+err.Error())
+			failed = true
+		}
+	}
+
+	indexObject, err := currentRepository.ReadIndex()
+	utils.CheckError(err)
+	repositoryAttributes, err := currentRepository.ReadAttributes()
+	utils.CheckError(err)
+	for _, file := range files {
+		fullPath := utils.Path(currentRepository.WorkTree, file.Path)
+		content, err := os.ReadFile(fullPath)
+		if pointer, isPointer := lfs.ParsePointer(content); err != nil || !isPointer || pointer != file.Pointer || !localStore.Has(file.Pointer) {
+			continue
+		}
+
+		blob, err := currentRepository.ReadBlobObject(file.Sha)
+		utils.CheckError(err)
+		content, err = currentRepository.ConvertToWorkTree(repositoryAttributes, file.Path, blob.Data)
+		utils.CheckError(err)
+		utils.CheckError(os.WriteFile(fullPath, content, 0666))
+		refreshIndexEntryStat(indexObject, file.Path, fullPath)
+	}
+	utils.CheckError(currentRepository.WriteIndex(indexObject))
+
+	if failed {
+		os.Exit(2)
+	}
+}
+
+// The content of the entry didnt change, only its stat info
+func refreshIndexEntryStat(indexObject *index.IndexObject, path string, fullPath string) {
+	entry, found := indexObject.Entries[path]
+	stat, err := os.Lstat(fullPath)
+	if !found || err != nil {
+		return
+	}
+
+	refreshedEntry := index.CreateIndexEntry(stat, path, entry.Sha)
+	refreshedEntry.ModeType, refreshedEntry.ModePerms = entry.ModeType, entry.ModePerms
+	indexObject.Entries[path] = refreshedEntry
+}
+
+// Files of the index, or of the commit if it is not empty, sorted by path
+func getLfsFiles(currentRepository *repository.Repository, commit string) []lfsFile {
+	shas := make(map[string]string) //Path -> sha
+	if commit == "" {
+		indexObject, err := currentRepository.ReadIndex()
+		utils.CheckError(err)
+		for path, entry := range indexObject.Entries {
+			if !entry.IsGitlink() && !entry.IsSymlink() {
+				shas[path] = entry.Sha
+			}
+		}
+	} else {
+		for path, entry := range getCommitFileEntries(currentRepository, resolveCommit(currentRepository, commit)) {
+			if entry.Mode == objects.TREE_MODE_FILE || entry.Mode == objects.TREE_MODE_EXECUTABLE {
+				shas[path] = entry.Sha
+			}
+		}
+	}
+
+	files := make([]lfsFile, 0)
+	for path, sha := range shas {
+		blob, err := currentRepository.ReadBlobObject(sha)
+		utils.CheckError(err)
+		if pointer, isPointer := lfs.ParsePointer(blob.Data); isPointer {
+			files = append(files, lfsFile{Path: path, Sha: sha, Pointer: pointer})
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Path < files[j].Path
+	})
+
+	return files
+}
+
+// Decimal units, like git-lfs. Example: 1.5 MB
+func formatLfsSize(size int64) string {
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+	value, unit := float64(size), 0
+	for value >= 1000 && unit < len(units)-1 {
+		value /= 1000
+		unit++
+	}
+
+	if unit == 0 {
+		return strconv.FormatInt(size, 10) + " B"
+	}
+	return strconv.FormatFloat(value, 'f', 1, 64) + " " + units[unit]
+}
@@ -1,66 +1,559 @@
 package commands
 
 import (
-	"fmt"
+	"bufio"
+	"git/src/diff"
 	"git/src/objects"
 	"git/src/repository"
 	"git/src/utils"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 )
 
-// Log Args: main.go log [sha]
-func Log(args []string) {
-	if len(args) < 2 {
-		utils.ExitError("Invalid arguments: log <sha>")
-	}
+type logOptions struct {
+	pretty       string //Builtin format (PRETTY_*) or format string with placeholders
+	template     bool
+	separator    bool //format: puts newlines between commits, tformat: after each commit
+	abbrevCommit bool
+	maxCount     int //-1 if there is no limit
+	since        time.Time
+	until        time.Time
+	authors      []string
+	greps        []string
+	ignoreCase   bool
+	graph        bool
+	all          bool
+	topoOrder    bool
+	decorate     bool
+	signatures   bool
+	follow       bool
+	followedPath string //The single pathspec when follow is set. It is not in paths
+	revisions    []string
+	paths        []string
+}
 
+// Log Shows the commits reachable from the revisions (default HEAD), newest first following every parent
+// Log Args: main.go log [<revision>... | <from>..<to> | ^<revision>] [--all] [--oneline | --pretty=<format> | --format=<format>]
+// Log Args: [-n <n> | -<n> | --max-count=<n>] [--since=<date>] [--until=<date>] [--author=<pattern>] [--grep=<pattern>] [-i]
+// Log Args: [--graph] [--topo-order | --date-order] [--decorate] [--abbrev-commit] [--show-signature] [--follow] [[--] <path>...]
+// Paths can go after -- or, if they exist in the work tree and are not revisions, after the revisions.
+// With --follow there must be a single path. Only the commits that changed the file are shown, and its history continues
+// through renames
+func Log(args []string) {
 	currentRepository, _, err := repository.FindCurrentRepository(utils.CurrentPath())
-	commitSha := getCommitShaToStartIterating(args, currentRepository)
-	currentCommitHasParent := true
-
 	if err != nil {
 		utils.ExitError(err.Error())
 	}
 
-	for currentCommitHasParent {
-		commit := getGitCommitObject(currentRepository, commitSha)
-		if !commit.HasParent() {
-			currentCommitHasParent = false
+	options := parseLogOptions(currentRepository, args[2:])
+	include, exclude := resolveLogRevisions(currentRepository, options)
+
+	revListOptions := repository.RevListOptions{Include: include, Exclude: exclude, TopoOrder: options.topoOrder || options.graph}
+	if !hasLogCommitFilters(options) && options.maxCount > 0 {
+		revListOptions.MaxCount = options.maxCount
+	}
+	commits, err := currentRepository.RevList(revListOptions)
+	utils.CheckError(err)
+
+	shownCommits, walkedParents := filterLogCommits(currentRepository, commits, include, options)
+	decorations := make(map[string][]string)
+	if options.decorate || (options.template && (strings.Contains(options.pretty, "%d") || strings.Contains(options.pretty, "%D"))) {
+		decorations = getDecorations(currentRepository)
+	}
+
+	signatures := make(map[string]string)
+	if options.signatures {
+		signatures = getSignatureVerifications(currentRepository, shownCommits)
+	}
+
+	printLog(shownCommits, walkedParents, options, decorations, signatures)
+}
+
+func parseLogOptions(currentRepository *repository.Repository, args []string) logOptions {
+	options := logOptions{pretty: PRETTY_MEDIUM, maxCount: -1, revisions: make([]string, 0), paths: make([]string, 0)}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		switch {
+		case arg == "--":
+			for _, path := range args[i+1:] {
+				options.paths = append(options.paths, toRepositoryPath(currentRepository, path))
+			}
+			i = len(args)
+		case arg == "--oneline":
+			options.pretty, options.template, options.abbrevCommit = PRETTY_ONELINE, false, true
+		case arg == "--pretty":
+			options.pretty, options.template = PRETTY_MEDIUM, false
+		case strings.HasPrefix(arg, "--pretty=") || strings.HasPrefix(arg, "--format="):
+			parsePrettyOption(&options, arg[strings.Index(arg, "=")+1:])
+		case arg == "--abbrev-commit":
+			options.abbrevCommit = true
+		case arg == "-n" || arg == "--max-count" || strings.HasPrefix(arg, "--max-count="):
+			options.maxCount = parseMaxCount(getOptionValue(args, &i, arg))
+		case regexp.MustCompile("^-[0-9]+$").MatchString(arg):
+			options.maxCount = parseMaxCount(arg[1:])
+		case strings.HasPrefix(arg, "-n"):
+			options.maxCount = parseMaxCount(arg[2:])
+		case matchesOption(arg, "--since") || matchesOption(arg, "--after"):
+			options.since = parseLogDate(getOptionValue(args, &i, arg))
+		case matchesOption(arg, "--until") || matchesOption(arg, "--before"):
+			options.until = parseLogDate(getOptionValue(args, &i, arg))
+		case matchesOption(arg, "--author"):
+			options.authors = append(options.authors, getOptionValue(args, &i, arg))
+		case matchesOption(arg, "--grep"):
+			options.greps = append(options.greps, getOptionValue(args, &i, arg))
+		case arg == "-i" || arg == "--regexp-ignore-case":
+			options.ignoreCase = true
+		case arg == "--graph":
+			options.graph = true
+		case arg == "--all":
+			options.all = true
+		case arg == "--topo-order":
+			options.topoOrder = true
+		case arg == "--date-order":
+			options.topoOrder = false
+		case arg == "--decorate" || arg == "--decorate=short" || arg == "--decorate=full":
+			options.decorate = true
+		case arg == "--no-decorate":
+			options.decorate = false
+		case arg == "--show-signature":
+			options.signatures = true
+		case arg == "--no-show-signature":
+			options.signatures = false
+		case arg == "--follow":
+			options.follow = true
+		case arg == "--no-follow":
+			options.follow = false
+		case strings.HasPrefix(arg, "-") && !strings.HasPrefix(arg, "^"):
+			utils.ExitError("Unknown option " + arg + " for log")
+		case len(options.paths) == 0 && (isLogRevision(currentRepository, arg) || !utils.CheckFileOrDirExists(arg)):
+			options.revisions = append(options.revisions, arg)
+		default: //Once a path is found, like git, every remaining argument is a path
+			options.paths = append(options.paths, toRepositoryPath(currentRepository, arg))
+		}
+	}
+
+	if options.follow {
+		if len(options.paths) != 1 {
+			utils.ExitError("fatal: --follow requires exactly one pathspec")
 		}
+		options.followedPath, options.paths = options.paths[0], make([]string, 0)
+	}
+
+	return options
+}
 
-		printCommit(commit, commitSha)
+// Revisions can be <rev>, ^<rev> or <from>..<to>
+func isLogRevision(currentRepository *repository.Repository, arg string) bool {
+	for _, revision := range strings.SplitN(strings.TrimPrefix(arg, "^"), "..", 2) {
+		if _, _, err := currentRepository.ResolveObjectName(defaultToHead(revision), objects.COMMIT); err != nil {
+			return false
+		}
+	}
+	return true
+}
 
-		commitSha = commit.Parent
+// Values: oneline, short, medium, full, format:<string>, tformat:<string> or a string with placeholders (tformat)
+func parsePrettyOption(options *logOptions, value string) {
+	switch {
+	case value == "" || value == PRETTY_MEDIUM || value == PRETTY_ONELINE || value == PRETTY_SHORT || value == PRETTY_FULL:
+		options.pretty, options.template = value, false
+		if value == "" {
+			options.pretty = PRETTY_MEDIUM
+		}
+	case strings.HasPrefix(value, "format:"):
+		options.pretty, options.template, options.separator = strings.TrimPrefix(value, "format:"), true, true
+	case strings.HasPrefix(value, "tformat:"):
+		options.pretty, options.template, options.separator = strings.TrimPrefix(value, "tformat:"), true, false
+	case strings.Contains(value, "%"):
+		options.pretty, options.template, options.separator = value, true, false
+	default:
+		utils.ExitError("invalid --pretty format: " + value)
 	}
 }
 
-func getCommitShaToStartIterating(args []string, currentRepository *repository.Repository) string {
-	if len(args) >= 3 {
-		return args[2]
-	} else {
-		name, detached, err := currentRepository.GetActiveBranch()
+func parseMaxCount(value string) int {
+	maxCount, err := strconv.Atoi(value)
+	if err != nil || maxCount < 0 {
+		utils.ExitError("Invalid number of commits: " + value)
+	}
+
+	return maxCount
+}
+
+func parseLogDate(value string) time.Time {
+	date, err := utils.ParseDate(value, time.Now())
+	utils.CheckError(err)
+
+	return date
+}
+
+// Paths are relative to the current directory. The returned path is relative to the work tree ("." for the root)
+func toRepositoryPath(currentRepository *repository.Repository, path string) string {
+	absolutePath := currentRepository.GetPathFileInRepository(path)
+	if absolutePath == currentRepository.WorkTree {
+		return "."
+	}
+
+	return currentRepository.AbsolutePathToRepositoryPath(absolutePath)
+}
+
+// Returns the shas of the commits to include and exclude. <from>..<to> excludes the commits reachable from <from>.
+// An empty side of the range is HEAD
+func resolveLogRevisions(currentRepository *repository.Repository, options logOptions) ([]string, []string) {
+	include, exclude := make([]string, 0), make([]string, 0)
+
+	for _, revision := range options.revisions {
+		switch {
+		case strings.Contains(revision, ".."):
+			parts := strings.SplitN(revision, "..", 2)
+			exclude = append(exclude, resolveLogRevision(currentRepository, defaultToHead(parts[0])))
+			include = append(include, resolveLogRevision(currentRepository, defaultToHead(parts[1])))
+		case strings.HasPrefix(revision, "^"):
+			exclude = append(exclude, resolveLogRevision(currentRepository, revision[1:]))
+		default:
+			include = append(include, resolveLogRevision(currentRepository, revision))
+		}
+	}
+
+	if options.all {
+		refs, err := currentRepository.GetAllRefs()
 		utils.CheckError(err)
-		if !detached {
-			sha, _, err := currentRepository.ResolveObjectName(name, objects.ANY)
-			utils.CheckError(err)
-			return sha
-		} else {
-			return name
+		for _, ref := range refs {
+			if sha, _, err := currentRepository.ResolveObjectName(ref.Value, objects.COMMIT); err == nil {
+				include = append(include, sha)
+			}
+		}
+		if head, err := currentRepository.ResolveRef("HEAD"); err == nil {
+			include = append(include, head.Value)
+		}
+	}
+
+	if len(include) == 0 && !options.all {
+		head, err := currentRepository.ResolveRef("HEAD")
+		if err != nil {
+			branch, _, _ := currentRepository.GetActiveBranch()
+			utils.ExitError("fatal: your current branch '" + branch + "' does not have any commits yet")
+		}
+		include = append(include, head.Value)
+	}
+
+	return include, exclude
+}
+
+func defaultToHead(revision string) string {
+	if revision == "" {
+		return "HEAD"
+	}
+	return revision
+}
+
+func resolveLogRevision(currentRepository *repository.Repository, revision string) string {
+	sha, _, err := currentRepository.ResolveObjectName(revision, objects.COMMIT)
+	if err != nil {
+		utils.ExitError("fatal: bad revision '" + revision + "'")
+	}
+
+	return sha
+}
+
+// Without filters the walk can stop after the maximum number of commits
+func hasLogCommitFilters(options logOptions) bool {
+	return !options.since.IsZero() || !options.until.IsZero() || len(options.authors) > 0 || len(options.greps) > 0 ||
+		len(options.paths) > 0 || options.followedPath != ""
+}
+
+// filterLogCommits Applies the date, author, message and path filters and the maximum number of commits. Returns the
+// commits to show and the parents followed from every walked commit. With paths, like git history simplification,
+// only the parent with the same files in the paths is followed from a commit that doesnt change them
+func filterLogCommits(currentRepository *repository.Repository, commits []repository.RevCommit, include []string, options logOptions) ([]repository.RevCommit, map[string][]string) {
+	authors := compileLogPatterns(options.authors, options.ignoreCase)
+	greps := compileLogPatterns(options.greps, options.ignoreCase)
+	treeFiles := make(map[string]map[string]diff.FileEntry) //Cache tree sha -> files of the tree filtered by the paths
+	followedPath := options.followedPath
+
+	//Without paths every listed commit is reached, and the parents are only needed by the graph
+	reached := make(map[string]bool)
+	for _, sha := range include {
+		reached[sha] = true
+	}
+	walkedParents := make(map[string][]string)
+	tracksParents := len(options.paths) > 0 || options.graph
+
+	shown := make([]repository.RevCommit, 0, len(commits))
+	for _, commit := range commits {
+		if options.maxCount != -1 && len(shown) >= options.maxCount {
+			break
+		}
+		if len(options.paths) > 0 && !reached[commit.Sha] {
+			continue
+		}
+
+		if tracksParents {
+			walkedParents[commit.Sha] = commit.Commit.Parents
+		}
+		changesPaths := true
+		if len(options.paths) > 0 {
+			treesameParent, treesame := getTreesameParent(currentRepository, commit.Commit, options.paths, treeFiles)
+			changesPaths = !treesame
+			if treesame && treesameParent != "" {
+				walkedParents[commit.Sha] = []string{treesameParent}
+			}
+			for _, parent := range walkedParents[commit.Sha] {
+				reached[parent] = true
+			}
+		}
+
+		switch {
+		case !options.since.IsZero() && commit.Commit.CommitterSignature().When.Before(options.since):
+			continue
+		case !options.until.IsZero() && commit.Commit.CommitterSignature().When.After(options.until):
+			continue
+		case len(authors) > 0 && !matchesAnyPattern(authors, commit.Commit.AuthorSignature().Identity()):
+			continue
+		case len(greps) > 0 && !matchesAnyPattern(greps, commit.Commit.Message):
+			continue
+		case !changesPaths:
+			continue
+		}
+
+		if options.followedPath != "" {
+			if followedPath == "" {
+				break
+			}
+			pathInParent, changed := getFollowedPathInParent(currentRepository, commit.Commit, followedPath)
+			if !changed {
+				continue
+			}
+			followedPath = pathInParent
+		}
+
+		shown = append(shown, commit)
+	}
+
+	return shown, walkedParents
+}
+
+func compileLogPatterns(patterns []string, ignoreCase bool) []*regexp.Regexp {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		if ignoreCase {
+			pattern = "(?i)" + pattern
+		}
+		regex, err := regexp.Compile(pattern)
+		if err != nil {
+			utils.ExitError("Invalid pattern " + pattern + ": " + err.Error())
+		}
+		compiled = append(compiled, regex)
+	}
+
+	return compiled
+}
+
+func matchesAnyPattern(patterns []*regexp.Regexp, text string) bool {
+	for _, pattern := range patterns {
+		if pattern.MatchString(text) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the first parent with the same files in the paths and if the commit doesnt change them. Root commits dont
+// change the paths if they dont contain them
+func getTreesameParent(currentRepository *repository.Repository, commit objects.CommitObject, paths []string, treeFiles map[string]map[string]diff.FileEntry) (string, bool) {
+	files := getTreeFilesInPaths(currentRepository, commit.Tree, paths, treeFiles)
+	if !commit.HasParent() {
+		return "", len(files) == 0
+	}
+
+	for _, parent := range commit.Parents {
+		parentCommit := getGitCommitObject(currentRepository, parent)
+		if equalFileEntries(files, getTreeFilesInPaths(currentRepository, parentCommit.Tree, paths, treeFiles)) {
+			return parent, true
+		}
+	}
+
+	return "", false
+}
+
+func getTreeFilesInPaths(currentRepository *repository.Repository, treeSha string, paths []string, treeFiles map[string]map[string]diff.FileEntry) map[string]diff.FileEntry {
+	if files, cached := treeFiles[treeSha]; cached {
+		return files
+	}
+
+	files := make(map[string]diff.FileEntry)
+	for path, entry := range getTreeFileEntries(currentRepository, treeSha) {
+		if matchesPathspec(path, paths) {
+			files[path] = entry
+		}
+	}
+	treeFiles[treeSha] = files
+
+	return files
+}
+
+func equalFileEntries(files map[string]diff.FileEntry, otherFiles map[string]diff.FileEntry) bool {
+	if len(files) != len(otherFiles) {
+		return false
+	}
+	for path, entry := range files {
+		if otherEntry, found := otherFiles[path]; !found || otherEntry != entry {
+			return false
+		}
+	}
+	return true
+}
+
+// getFollowedPathInParent Returns the path of the file in the parent commit and if the commit changed it.
+// If the file was added in the commit and it is not a rename, the returned path is empty. A commit deleting the file
+// changes it, and the file is still followed in the parent
+func getFollowedPathInParent(currentRepository *repository.Repository, commit objects.CommitObject, path string) (string, bool) {
+	files := getTreeFileEntries(currentRepository, commit.Tree)
+	parentFiles := make(map[string]diff.FileEntry)
+	if commit.HasParent() {
+		parentFiles = getCommitFileEntries(currentRepository, commit.Parent)
+	}
+
+	file, inCommit := files[path]
+	parentFile, inParent := parentFiles[path]
+	if !inCommit {
+		return path, inParent
+	}
+	if inParent {
+		return path, parentFile != file
+	}
+
+	changes, err := diff.DetectRenames(diff.DiffFileMaps(parentFiles, files), diff.DefaultRenameOptions(), getBlobReader(currentRepository))
+	utils.CheckError(err)
+	for _, change := range changes {
+		if change.Type == diff.RENAMED && change.NewPath == path {
+			return change.OldPath, true
+		}
+	}
+
+	return "", true
+}
+
+func printLog(shownCommits []repository.RevCommit, walkedParents map[string][]string, options logOptions, decorations map[string][]string, signatures map[string]string) {
+	graph := &logGraph{columns: make([]string, 0)}
+	var parentsRewriter func(sha string) []string
+	if options.graph {
+		parentsRewriter = createParentsRewriter(shownCommits, walkedParents)
+	}
+	output := bufio.NewWriter(os.Stdout)
+	defer output.Flush()
+
+	for i, commit := range shownCommits {
+		lines := strings.Split(formatLogCommit(commit, options, decorations[commit.Sha]), "\n")
+		if signature, signed := signatures[commit.Sha]; signed {
+			lines = insertSignatureLines(lines, strings.Split(signature, "\n"), options)
+		}
+		isLast := i == len(shownCommits)-1
+		if !options.template && options.pretty != PRETTY_ONELINE && !isLast {
+			lines = append(lines, "") //Blank line between commits
+		}
+
+		if options.graph {
+			lines = graph.render(commit.Sha, parentsRewriter(commit.Sha), lines)
+		}
+
+		for j, line := range lines {
+			output.WriteString(line)
+			if !options.template || !options.separator || !isLast || j != len(lines)-1 {
+				output.WriteString("\n")
+			}
+		}
+	}
+}
+
+// Returns sha -> verification of the signature of the signed commits
+func getSignatureVerifications(currentRepository *repository.Repository, commits []repository.RevCommit) map[string]string {
+	signatures := make(map[string]string)
+	for _, commit := range commits {
+		if payload, signature := commit.Commit.SignedPayload(); signature != "" {
+			signatures[commit.Sha] = currentRepository.VerifySignature(payload, signature, commit.Commit.CommitterSignature().When).String()
+		}
+	}
+
+	return signatures
+}
+
+// The verification goes after the "commit <sha>" line, or before the commit in oneline and custom formats
+func insertSignatureLines(lines []string, signatureLines []string, options logOptions) []string {
+	if options.template || options.pretty == PRETTY_ONELINE {
+		return append(signatureLines, lines...)
+	}
+
+	return append(append(append(make([]string, 0), lines[0]), signatureLines...), lines[1:]...)
+}
+
+func formatLogCommit(commit repository.RevCommit, options logOptions, decorations []string) string {
+	if options.template {
+		return formatCommitTemplate(options.pretty, commit, decorations)
+	}
+
+	return formatCommitPretty(options.pretty, commit, decorations, options.abbrevCommit)
+}
+
+// createParentsRewriter Returns the parents of a shown commit in the graph. Parents that are not shown are replaced
+// by their nearest shown ancestors through the followed parents
+func createParentsRewriter(shownCommits []repository.RevCommit, walkedParents map[string][]string) func(sha string) []string {
+	shown := make(map[string]bool)
+	for _, commit := range shownCommits {
+		shown[commit.Sha] = true
+	}
+	rewritten := make(map[string][]string) //Cache sha of not shown commit -> its nearest shown ancestors
+
+	var shownAncestors func(sha string) []string
+	shownAncestors = func(sha string) []string {
+		if shown[sha] {
+			return []string{sha}
+		}
+		if ancestors, cached := rewritten[sha]; cached {
+			return ancestors
 		}
+
+		ancestors := make([]string, 0)
+		for _, parent := range walkedParents[sha] {
+			ancestors = appendUnique(ancestors, shownAncestors(parent)...)
+		}
+		rewritten[sha] = ancestors
+
+		return ancestors
+	}
+
+	return func(sha string) []string {
+		parents := make([]string, 0)
+		for _, parent := range walkedParents[sha] {
+			parents = appendUnique(parents, shownAncestors(parent)...)
+		}
+		return parents
 	}
 }
 
-func printCommit(commitObject objects.CommitObject, sha string) {
-	fmt.Println("Commit " + sha)
-	fmt.Println("Author " + commitObject.Author)
-	fmt.Println("\t" + commitObject.Message)
-	fmt.Println("")
+func appendUnique(values []string, toAppend ...string) []string {
+	for _, value := range toAppend {
+		found := false
+		for _, existing := range values {
+			found = found || existing == value
+		}
+		if !found {
+			values = append(values, value)
+		}
+	}
+	return values
 }
 
 func getGitCommitObject(currentRepository *repository.Repository, sha string) objects.CommitObject {
-	gitObject, err := currentRepository.ReadObject(sha, objects.COMMIT)
+	commit, err := currentRepository.ReadCommitObject(sha)
 	if err != nil {
 		utils.ExitError("Object with SHA " + sha + " not found")
 	}
 
-	return gitObject.SerializableGitObject.(objects.CommitObject)
+	return commit
 }
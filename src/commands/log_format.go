@@ -0,0 +1,260 @@
+package commands
+
+import (
+	"git/src/objects"
+	"git/src/repository"
+	"git/src/utils"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const ABBREV_SHA_LENGTH = 7
+
+const (
+	DATE_FORMAT_DEFAULT = "Mon Jan 2 15:04:05 2006 -0700"
+	DATE_FORMAT_ISO     = "2006-01-02 15:04:05 -0700"
+	DATE_FORMAT_SHORT   = "2006-01-02"
+)
+
+// Formats of --pretty and --format. Other values are treated as format strings with placeholders
+const (
+	PRETTY_ONELINE = "oneline"
+	PRETTY_SHORT   = "short"
+	PRETTY_MEDIUM  = "medium"
+	PRETTY_FULL    = "full"
+)
+
+// formatCommitTemplate Expands the placeholders of --format: %H %h %T %t %P %p %an %ae %ad %ar %at %ai %aI %as (and the
+// same for the committer with %c), %s %b %B %d %D %n %% and %x<hex>. Color placeholders (%C...) are ignored
+func formatCommitTemplate(template string, commit repository.RevCommit, decorations []string) string {
+	var result strings.Builder
+
+	for i := 0; i < len(template); i++ {
+		if template[i] != '%' || i+1 >= len(template) {
+			result.WriteByte(template[i])
+			continue
+		}
+
+		consumed, expanded := expandPlaceholder(template[i+1:], commit, decorations)
+		if consumed == 0 {
+			result.WriteByte(template[i])
+			continue
+		}
+		result.WriteString(expanded)
+		i += consumed
+	}
+
+	return result.String()
+}
+
+// Returns the number of bytes of the placeholder (without %) and its value. 0 if it is not a valid placeholder
+func expandPlaceholder(placeholder string, commit repository.RevCommit, decorations []string) (int, string) {
+	switch placeholder[0] {
+	case 'H':
+		return 1, commit.Sha
+	case 'h':
+		return 1, abbreviateSha(commit.Sha)
+	case 'T':
+		return 1, commit.Commit.Tree
+	case 't':
+		return 1, abbreviateSha(commit.Commit.Tree)
+	case 'P':
+		return 1, strings.Join(commit.Commit.Parents, " ")
+	case 'p':
+		return 1, strings.Join(abbreviateShas(commit.Commit.Parents), " ")
+	case 's':
+		return 1, commit.Commit.Subject()
+	case 'b':
+		return 1, withTrailingNewline(commit.Commit.Body())
+	case 'B':
+		return 1, withTrailingNewline(strings.TrimLeft(commit.Commit.Message, "\n"))
+	case 'n':
+		return 1, "\n"
+	case '%':
+		return 1, "%"
+	case 'd':
+		if len(decorations) == 0 {
+			return 1, ""
+		}
+		return 1, " (" + strings.Join(decorations, ", ") + ")"
+	case 'D':
+		return 1, strings.Join(decorations, ", ")
+	case 'a', 'c':
+		if len(placeholder) < 2 {
+			return 0, ""
+		}
+		signature := commit.Commit.AuthorSignature()
+		if placeholder[0] == 'c' {
+			signature = commit.Commit.CommitterSignature()
+		}
+		value, valid := expandSignaturePlaceholder(placeholder[1], signature)
+		if !valid {
+			return 0, ""
+		}
+		return 2, value
+	case 'x':
+		if len(placeholder) < 3 {
+			return 0, ""
+		}
+		value, err := strconv.ParseUint(placeholder[1:3], 16, 8)
+		if err != nil {
+			return 0, ""
+		}
+		return 3, string([]byte{byte(value)})
+	case 'C':
+		if strings.HasPrefix(placeholder, "C(") {
+			if end := strings.Index(placeholder, ")"); end != -1 {
+				return end + 1, ""
+			}
+		}
+		for _, color := range []string{"Creset", "Cred", "Cgreen", "Cblue"} {
+			if strings.HasPrefix(placeholder, color) {
+				return len(color), ""
+			}
+		}
+	}
+
+	return 0, ""
+}
+
+func expandSignaturePlaceholder(field byte, signature objects.Signature) (string, bool) {
+	switch field {
+	case 'n':
+		return signature.Name, true
+	case 'e':
+		return signature.Email, true
+	case 'd':
+		return signature.When.Format(DATE_FORMAT_DEFAULT), true
+	case 'r':
+		return utils.FormatRelativeDate(signature.When, time.Now()), true
+	case 't':
+		return strconv.FormatInt(signature.When.Unix(), 10), true
+	case 'i':
+		return signature.When.Format(DATE_FORMAT_ISO), true
+	case 'I':
+		return signature.When.Format(time.RFC3339), true
+	case 's':
+		return signature.When.Format(DATE_FORMAT_SHORT), true
+	}
+
+	return "", false
+}
+
+// formatCommitPretty Builtin formats of --pretty: oneline, short, medium (default) and full
+func formatCommitPretty(pretty string, commit repository.RevCommit, decorations []string, abbrev bool) string {
+	sha := commit.Sha
+	if abbrev {
+		sha = abbreviateSha(sha)
+	}
+	decoration := ""
+	if len(decorations) > 0 {
+		decoration = " (" + strings.Join(decorations, ", ") + ")"
+	}
+
+	if pretty == PRETTY_ONELINE {
+		return sha + decoration + " " + commit.Commit.Subject()
+	}
+
+	var result strings.Builder
+	result.WriteString("commit " + sha + decoration + "\n")
+	if len(commit.Commit.Parents) > 1 {
+		result.WriteString("Merge: " + strings.Join(abbreviateShas(commit.Commit.Parents), " ") + "\n")
+	}
+
+	author := commit.Commit.AuthorSignature()
+	result.WriteString("Author: " + author.Identity() + "\n")
+	switch pretty {
+	case PRETTY_MEDIUM:
+		result.WriteString("Date:   " + author.When.Format(DATE_FORMAT_DEFAULT) + "\n")
+	case PRETTY_FULL:
+		result.WriteString("Commit: " + commit.Commit.CommitterSignature().Identity() + "\n")
+	}
+
+	message := strings.Trim(commit.Commit.Message, "\n")
+	if pretty == PRETTY_SHORT {
+		message = commit.Commit.Subject()
+	}
+	result.WriteString("\n")
+	for _, line := range strings.Split(message, "\n") {
+		result.WriteString(strings.TrimRight("    "+line, " ") + "\n")
+	}
+
+	return strings.TrimSuffix(result.String(), "\n")
+}
+
+// getDecorations Returns the names pointing to each commit: HEAD -> <branch>, branches, remote branches and tag: <name>.
+// Annotated tags are peeled to the commit they point to
+func getDecorations(currentRepository *repository.Repository) map[string][]string {
+	decorations := make(map[string][]string)
+	refs, err := currentRepository.GetAllRefs()
+	utils.CheckError(err)
+
+	headBranch, detached, _ := currentRepository.GetActiveBranch()
+	refNames := make([]string, 0, len(refs))
+	for refName, _ := range refs {
+		refNames = append(refNames, refName)
+	}
+	sort.Strings(refNames)
+
+	names := make([]string, 0)
+	shas := make([]string, 0)
+	for _, refName := range refNames {
+		var name string
+		switch {
+		case strings.HasPrefix(refName, "refs/heads/"):
+			name = strings.TrimPrefix(refName, "refs/heads/")
+			if !detached && name == headBranch {
+				name = "HEAD -> " + name
+			}
+		case strings.HasPrefix(refName, "refs/tags/"):
+			name = "tag: " + strings.TrimPrefix(refName, "refs/tags/")
+		case strings.HasPrefix(refName, "refs/remotes/"):
+			name = strings.TrimPrefix(refName, "refs/remotes/")
+		default:
+			continue
+		}
+
+		sha, _, err := currentRepository.ResolveObjectName(refs[refName].Value, objects.COMMIT)
+		if err != nil {
+			continue
+		}
+		if strings.HasPrefix(name, "HEAD -> ") {
+			names, shas = append([]string{name}, names...), append([]string{sha}, shas...)
+		} else {
+			names, shas = append(names, name), append(shas, sha)
+		}
+	}
+	if head, err := currentRepository.ResolveRef("HEAD"); err == nil && detached {
+		names, shas = append([]string{"HEAD"}, names...), append([]string{head.Value}, shas...)
+	}
+
+	for i, name := range names {
+		decorations[shas[i]] = append(decorations[shas[i]], name)
+	}
+
+	return decorations
+}
+
+func abbreviateSha(sha string) string {
+	if len(sha) > ABBREV_SHA_LENGTH {
+		return sha[:ABBREV_SHA_LENGTH]
+	}
+	return sha
+}
+
+func abbreviateShas(shas []string) []string {
+	abbreviated := make([]string, len(shas))
+	for i, sha := range shas {
+		abbreviated[i] = abbreviateSha(sha)
+	}
+	return abbreviated
+}
+
+func withTrailingNewline(text string) string {
+	if text == "" || strings.HasSuffix(text, "\n") {
+		return text
+	}
+	return text + "\n"
+}
@@ -0,0 +1,157 @@
+package commands
+
+import "strings"
+
+// logGraph ASCII graph drawn by log --graph. Each column is a line of history, identified by the sha of the next
+// commit expected in it. Columns are drawn 2 characters apart and edges move one column per row with / or \
+type logGraph struct {
+	columns []string
+}
+
+// render Returns the lines of the commit prefixed by the graph. The rows that open the lines of the other parents of
+// a merge, join lines waiting for the same commit or remove the line of a root commit are used as prefix of the next
+// lines of the commit, or are added after them
+func (g *logGraph) render(sha string, parents []string, lines []string) []string {
+	output := g.collapseColumns()
+
+	column := g.findColumn(sha)
+	if column == -1 {
+		g.columns = append(g.columns, sha)
+		column = len(g.columns) - 1
+	}
+	width := 2 * len(g.columns)
+	if len(parents) > 1 {
+		width = 2 * (len(g.columns) + len(parents) - 1)
+	}
+	if len(lines) == 0 {
+		lines = []string{""}
+	}
+	output = append(output, padGraphRow(g.row(column, '*'), width)+lines[0])
+
+	var padding string
+	transitions := make([]string, 0)
+	if len(parents) == 0 {
+		padding = g.row(column, ' ')
+		transitions = g.removeColumn(column)
+	} else {
+		g.columns[column] = parents[0]
+		if len(parents) > 1 {
+			transitions = append(transitions, g.expandRow(column))
+			newColumns := append(append(make([]string, 0), g.columns[:column+1]...), parents[1:]...)
+			g.columns = append(newColumns, g.columns[column+1:]...)
+		}
+		transitions = append(transitions, g.collapseColumns()...)
+		padding = g.row(-1, ' ')
+	}
+
+	for _, line := range lines[1:] {
+		prefix := padding
+		if len(transitions) > 0 {
+			prefix, transitions = transitions[0], transitions[1:]
+		}
+		output = append(output, padGraphRow(prefix, width)+line)
+	}
+	for _, transition := range transitions {
+		output = append(output, padGraphRow(transition, width))
+	}
+
+	return output
+}
+
+func (g *logGraph) findColumn(sha string) int {
+	for i, columnSha := range g.columns {
+		if columnSha == sha {
+			return i
+		}
+	}
+	return -1
+}
+
+// Lines of history waiting for the same commit are joined into the leftmost one, one column per row
+func (g *logGraph) collapseColumns() []string {
+	rows := make([]string, 0)
+
+	for duplicate := g.findDuplicateColumn(); duplicate != -1; duplicate = g.findDuplicateColumn() {
+		row := g.emptyRow()
+		for i := range g.columns {
+			if i < duplicate {
+				row[2*i] = '|'
+			} else {
+				row[2*i-1] = '/'
+			}
+		}
+		rows = append(rows, string(row))
+		g.columns = append(g.columns[:duplicate], g.columns[duplicate+1:]...)
+	}
+
+	return rows
+}
+
+func (g *logGraph) findDuplicateColumn() int {
+	for i, sha := range g.columns {
+		if g.findColumn(sha) < i {
+			return i
+		}
+	}
+	return -1
+}
+
+// Row drawn after removing the column of a root commit. The columns at its right move one column to the left
+func (g *logGraph) removeColumn(column int) []string {
+	rows := make([]string, 0)
+	if column < len(g.columns)-1 {
+		row := g.emptyRow()
+		for i := range g.columns {
+			if i < column {
+				row[2*i] = '|'
+			} else if i > column {
+				row[2*i-1] = '/'
+			}
+		}
+		rows = append(rows, string(row))
+	}
+
+	g.columns = append(g.columns[:column], g.columns[column+1:]...)
+	return rows
+}
+
+// Row drawn after a merge commit. The other parents start at its right and the columns at its right move to the right
+func (g *logGraph) expandRow(column int) string {
+	row := append(g.emptyRow(), ' ', ' ')
+	for i := range g.columns {
+		if i <= column {
+			row[2*i] = '|'
+		} else {
+			row[2*i+1] = '\\'
+		}
+	}
+	row[2*column+1] = '\\'
+
+	return string(row)
+}
+
+// Row with a | for every column. The column mark (if it is not -1) is drawn with markChar instead
+func (g *logGraph) row(mark int, markChar byte) string {
+	row := g.emptyRow()
+	for i := range g.columns {
+		row[2*i] = '|'
+	}
+	if mark != -1 {
+		row[2*mark] = markChar
+	}
+
+	return string(row)
+}
+
+func (g *logGraph) emptyRow() []byte {
+	return []byte(strings.Repeat(" ", 2*len(g.columns)))
+}
+
+// Rows are padded to the width of the commit, with at least one space before the text
+func padGraphRow(row string, width int) string {
+	row = strings.TrimRight(row, " ")
+	if len(row) < width {
+		return row + strings.Repeat(" ", width-len(row))
+	}
+	return row + " "
+}
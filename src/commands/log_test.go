@@ -0,0 +1,103 @@
+package commands
+
+import (
+	"git/src/repository"
+	"git/src/utils"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLog_FollowAcrossRename(t *testing.T) {
+	currentRepository := createTestRepository(t)
+	assert.Nil(t, os.Mkdir("src", os.ModePerm))
+	writeTestFile(t, "src/old.txt", "line1\nline2\nline3\nline4\n")
+	commitTestChanges(t, "add", ".")
+	writeTestFile(t, "other.txt", "other\n")
+	commitTestChanges(t, "other", "other.txt")
+	assert.Nil(t, os.Rename("src/old.txt", "src/moved.txt"))
+	commitTestChanges(t, "rename", "src/old.txt", "src/moved.txt")
+	writeTestFile(t, "src/moved.txt", "line1\nline2\nline3\nline4\nline5\n")
+	commitTestChanges(t, "edit", "src")
+
+	assert.Equal(t, []string{"edit", "rename", "add"}, getLogMessages(currentRepository, "--follow", "--oneline", "src/moved.txt"))
+	assert.Equal(t, []string{"edit", "rename", "add"}, getLogMessages(currentRepository, "--follow", "--oneline", "--", "src/moved.txt"))
+	assert.Equal(t, []string{"edit", "rename"}, getLogMessages(currentRepository, "--oneline", "src/moved.txt"))
+	assert.Equal(t, []string{"rename", "add"}, getLogMessages(currentRepository, "HEAD", "--", "src/old.txt"))
+
+	assert.Nil(t, os.Chdir("src"))
+	assert.Equal(t, []string{"edit", "rename", "add"}, getLogMessages(currentRepository, "HEAD", "--follow", "moved.txt"))
+}
+
+func TestLog_FollowDeletedFile(t *testing.T) {
+	currentRepository := createTestRepository(t)
+	writeTestFile(t, "old.txt", "line1\nline2\nline3\nline4\n")
+	commitTestChanges(t, "add", "old.txt")
+	assert.Nil(t, os.Rename("old.txt", "file.txt"))
+	commitTestChanges(t, "rename", "old.txt", "file.txt")
+	writeTestFile(t, "other.txt", "other\n")
+	commitTestChanges(t, "other", "other.txt")
+	assert.Nil(t, os.Remove("file.txt"))
+	commitTestChanges(t, "delete", "file.txt")
+	writeTestFile(t, "other.txt", "changed\n")
+	commitTestChanges(t, "after", "other.txt")
+
+	assert.Equal(t, []string{"delete", "rename", "add"}, getLogMessages(currentRepository, "--follow", "--", "file.txt"))
+}
+
+func getLogMessages(currentRepository *repository.Repository, args ...string) []string {
+	options := parseLogOptions(currentRepository, args)
+	include, exclude := resolveLogRevisions(currentRepository, options)
+	commits, err := currentRepository.RevList(repository.RevListOptions{Include: include, Exclude: exclude})
+	utils.CheckError(err)
+
+	shownCommits, _ := filterLogCommits(currentRepository, commits, include, options)
+	messages := make([]string, 0, len(shownCommits))
+	for _, commit := range shownCommits {
+		messages = append(messages, strings.TrimSpace(commit.Commit.Message))
+	}
+	return messages
+}
+
+// Creates a repository in a temporary directory, which is the current directory until the test ends. Global and system
+// config files are not read
+func createTestRepository(t *testing.T) *repository.Repository {
+	repositoryPath := t.TempDir()
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	previousPath, err := os.Getwd()
+	assert.Nil(t, err)
+	assert.Nil(t, os.Chdir(repositoryPath))
+	t.Cleanup(func() { os.Chdir(previousPath) })
+
+	return repository.InitializeRepository(repositoryPath, false)
+}
+
+func writeTestFile(t *testing.T, path string, content string) {
+	assert.Nil(t, os.WriteFile(path, []byte(content), 0644))
+}
+
+// Stages the paths with add and commits them
+func commitTestChanges(t *testing.T, message string, paths ...string) {
+	Add(append([]string{"main.go", "add"}, paths...))
+	Commit([]string{"main.go", "commit", "-m", message})
+}
+
+// Returns what run prints to stdout
+func captureTestStdout(t *testing.T, run func()) string {
+	reader, writer, err := os.Pipe()
+	assert.Nil(t, err)
+	stdout := os.Stdout
+	os.Stdout = writer
+	run()
+	os.Stdout = stdout
+	assert.Nil(t, writer.Close())
+
+	output, err := io.ReadAll(reader)
+	assert.Nil(t, err)
+	return string(output)
+}
@@ -18,6 +18,7 @@ func LsFiles(args []string) {
 	if err != nil {
 		utils.ExitError(err.Error())
 	}
+	utils.CheckError(currentRepository.RequireWorkTree())
 
 	index, err := currentRepository.ReadIndex()
 	if err != nil {
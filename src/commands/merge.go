@@ -0,0 +1,425 @@
+package commands
+
+import (
+	"fmt"
+	"git/src/attributes"
+	"git/src/diff"
+	"git/src/index"
+	"git/src/objects"
+	"git/src/repository"
+	"git/src/sparse"
+	"git/src/utils"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	MERGE_HEAD_FILE    = "MERGE_HEAD" //Commit being merged while there are conflicts to resolve
+	MERGE_MESSAGE_FILE = "MERGE_MSG"
+)
+
+type mergeOptions struct {
+	noFastForward   bool
+	fastForwardOnly bool
+	message         string
+	abort           bool
+	commitName      string
+}
+
+// Result of merging the files of two commits with their merge base
+type mergedFiles struct {
+	files     map[string]diff.FileEntry
+	conflicts map[string]string //Path -> kind of conflict
+	contents  map[string][]byte //Path -> content with conflict markers to write in the work tree
+}
+
+// Merge Joins the history of the commit into the current branch. If HEAD is an ancestor of the commit the branch is
+// fast-forwarded, unless --no-ff is given. Otherwise the files are merged with the merge base (the first one if there are
+// several) and a merge commit is created. Conflicting files are written with conflict markers and the commit is left to
+// commit, which uses MERGE_HEAD as second parent. With sparse checkout only the files matching the patterns are written,
+// the rest keep the skip-worktree bit, except the conflicting ones. The post-merge hook is run after a successful merge
+// Merge Args: main.go merge [--no-ff | --ff-only] [-m <message>] <commit>
+// Merge Args: main.go merge --abort
+func Merge(args []string) {
+	options := parseMergeOptions(args[2:])
+
+	currentRepository, _, err := repository.FindCurrentRepository(utils.CurrentPath())
+	utils.CheckError(err)
+	utils.CheckError(currentRepository.RequireWorkTree())
+
+	if options.abort {
+		abortMerge(currentRepository)
+		return
+	}
+	if utils.CheckFileOrDirExists(utils.Path(currentRepository.GitDir, MERGE_HEAD_FILE)) {
+		utils.ExitError("fatal: You have not concluded your merge (MERGE_HEAD exists).\nPlease, commit your changes before you merge.")
+	}
+
+	if !mergeCommit(currentRepository, options) {
+		fmt.Println("Automatic merge failed; fix conflicts and then commit the result.")
+		os.Exit(1)
+	}
+}
+
+func parseMergeOptions(args []string) mergeOptions {
+	const usage = "Invalid arguments: merge [--no-ff | --ff-only] [-m <message>] <commit> | merge --abort"
+	options := mergeOptions{}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		switch {
+		case arg == "--no-ff":
+			options.noFastForward = true
+		case arg == "--ff-only":
+			options.fastForwardOnly = true
+		case arg == "--abort":
+			options.abort = true
+		case matchesOption(arg, "-m") || matchesOption(arg, "--message"):
+			options.message = getOptionValue(args, &i, arg)
+		case strings.HasPrefix(arg, "-m"):
+			options.message = arg[2:]
+		case strings.HasPrefix(arg, "-"):
+			utils.ExitError("Unknown option " + arg + " for merge")
+		case options.commitName != "":
+			utils.ExitError(usage)
+		default:
+			options.commitName = arg
+		}
+	}
+
+	if (options.noFastForward && options.fastForwardOnly) || (options.abort == (options.commitName != "")) {
+		utils.ExitError(usage)
+	}
+
+	return options
+}
+
+// Returns false if there are conflicts
+func mergeCommit(currentRepository *repository.Repository, options mergeOptions) bool {
+	theirs, isBranch, err := currentRepository.ResolveObjectName(options.commitName, objects.COMMIT)
+	if err != nil {
+		utils.ExitError("merge: " + options.commitName + " - not something we can merge")
+	}
+	head := getHeadSha(currentRepository)
+
+	if head != objects.NO_PARENT_COMMIT_SHA {
+		upToDate, err := currentRepository.IsAncestor(theirs, head)
+		utils.CheckError(err)
+		if upToDate {
+			fmt.Println("Already up to date.")
+			return true
+		}
+	}
+
+	fastForward := head == objects.NO_PARENT_COMMIT_SHA
+	if !fastForward {
+		fastForward, err = currentRepository.IsAncestor(head, theirs)
+		utils.CheckError(err)
+	}
+	if fastForward && !options.noFastForward {
+		fastForwardMerge(currentRepository, head, theirs)
+		return true
+	}
+	if options.fastForwardOnly {
+		utils.ExitError("fatal: Not possible to fast-forward, aborting.")
+	}
+
+	mergeBases, err := currentRepository.MergeBases(head, theirs)
+	utils.CheckError(err)
+	if len(mergeBases) == 0 {
+		utils.ExitError("fatal: refusing to merge unrelated histories")
+	}
+
+	message := options.message
+	if message == "" {
+		message = "Merge commit '" + options.commitName + "'"
+		if isBranch {
+			message = "Merge branch '" + options.commitName + "'"
+		}
+	}
+
+	return threeWayMerge(currentRepository, mergeBases[0], head, theirs, options.commitName, cleanupCommitMessage(message))
+}
+
+func fastForwardMerge(currentRepository *repository.Repository, head string, theirs string) {
+	ours := make(map[string]diff.FileEntry)
+	if head != objects.NO_PARENT_COMMIT_SHA {
+		ours = getCommitFileEntries(currentRepository, head)
+	}
+	result := mergedFiles{files: getCommitFileEntries(currentRepository, theirs), conflicts: map[string]string{}, contents: map[string][]byte{}}
+
+	repositoryIndex, err := currentRepository.ReadIndex()
+	utils.CheckError(err)
+	checkMergeLocalChanges(currentRepository, repositoryIndex, ours, result, false)
+	if head != objects.NO_PARENT_COMMIT_SHA {
+		fmt.Println("Updating " + head[:7] + ".." + theirs[:7])
+	}
+	applyMergedFiles(currentRepository, repositoryIndex, ours, result)
+
+	moveHead(currentRepository, theirs)
+	fmt.Println("Fast-forward")
+	currentRepository.RunHook(repository.POST_MERGE_HOOK, repository.HookOptions{Args: []string{"0"}}) //Its exit code doesnt affect the merge
+}
+
+func threeWayMerge(currentRepository *repository.Repository, base string, head string, theirs string, theirsLabel string, message string) bool {
+	ours := getCommitFileEntries(currentRepository, head)
+	result := mergeFiles(currentRepository, getCommitFileEntries(currentRepository, base), ours, getCommitFileEntries(currentRepository, theirs), theirsLabel)
+
+	repositoryIndex, err := currentRepository.ReadIndex()
+	utils.CheckError(err)
+	checkMergeLocalChanges(currentRepository, repositoryIndex, ours, result, true)
+	applyMergedFiles(currentRepository, repositoryIndex, ours, result)
+
+	if len(result.conflicts) > 0 {
+		paths := make([]string, 0, len(result.conflicts))
+		for path, _ := range result.conflicts {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		for _, path := range paths {
+			fmt.Println("CONFLICT (" + result.conflicts[path] + "): Merge conflict in " + path)
+		}
+
+		utils.CheckError(os.WriteFile(utils.Path(currentRepository.GitDir, MERGE_HEAD_FILE), []byte(theirs+"\n"), 0644))
+		utils.CheckError(os.WriteFile(utils.Path(currentRepository.GitDir, MERGE_MESSAGE_FILE), []byte(message), 0644))
+		return false
+	}
+
+	treeSha := createBlobsAndTrees(repositoryIndex.ToTree(), currentRepository)
+	commitObject := objects.CreateCommitObjectWithParents(treeSha, []string{head, theirs}, currentRepository.GetIdentity(repository.AUTHOR_ROLE).String(),
+		currentRepository.GetIdentity(repository.COMMITTER_ROLE).String(), message)
+	commitSha := writeCommitObject(currentRepository, commitObject, commitOptions{sign: currentRepository.SignByDefault("commit")})
+	moveHead(currentRepository, commitSha)
+
+	fmt.Println("Merge made by the three-way strategy.")
+	currentRepository.RunHook(repository.POST_MERGE_HOOK, repository.HookOptions{Args: []string{"0"}})
+	return true
+}
+
+// mergeFiles Takes every file from the side that changed it. Files changed by both sides are merged by lines if they are text
+// files with the same mode, otherwise they conflict and our version is kept. If a side deleted a file the other modified,
+// the modified version is kept
+func mergeFiles(currentRepository *repository.Repository, base map[string]diff.FileEntry, ours map[string]diff.FileEntry,
+	theirs map[string]diff.FileEntry, theirsLabel string) mergedFiles {
+	result := mergedFiles{files: make(map[string]diff.FileEntry), conflicts: make(map[string]string), contents: make(map[string][]byte)}
+	paths := make(map[string]bool)
+	for _, files := range []map[string]diff.FileEntry{base, ours, theirs} {
+		for path, _ := range files {
+			paths[path] = true
+		}
+	}
+
+	for path, _ := range paths {
+		baseEntry, inBase := base[path]
+		oursEntry, inOurs := ours[path]
+		theirsEntry, inTheirs := theirs[path]
+
+		switch {
+		case inOurs == inTheirs && oursEntry == theirsEntry:
+			if inOurs {
+				result.files[path] = oursEntry
+			}
+		case inBase == inTheirs && baseEntry == theirsEntry:
+			if inOurs {
+				result.files[path] = oursEntry
+			}
+		case inBase == inOurs && baseEntry == oursEntry:
+			if inTheirs {
+				result.files[path] = theirsEntry
+			}
+		case !inOurs || !inTheirs:
+			result.conflicts[path] = "modify/delete"
+			result.files[path] = oursEntry
+			if !inOurs {
+				result.files[path] = theirsEntry
+			}
+		default:
+			mergeFileContents(currentRepository, result, path, baseEntry, oursEntry, theirsEntry, theirsLabel)
+		}
+	}
+
+	return result
+}
+
+func mergeFileContents(currentRepository *repository.Repository, result mergedFiles, path string, baseEntry diff.FileEntry, oursEntry diff.FileEntry,
+	theirsEntry diff.FileEntry, theirsLabel string) {
+	result.files[path] = oursEntry
+	mode := oursEntry.Mode
+	if oursEntry.Mode == baseEntry.Mode {
+		mode = theirsEntry.Mode
+	}
+	if oursEntry.Mode != baseEntry.Mode && theirsEntry.Mode != baseEntry.Mode && oursEntry.Mode != theirsEntry.Mode {
+		result.conflicts[path] = "mode"
+		return
+	}
+	if oursEntry.IsGitlink() || theirsEntry.IsGitlink() || mode == objects.TREE_MODE_SYMLINK {
+		result.conflicts[path] = "content"
+		return
+	}
+
+	readBlob := getBlobReader(currentRepository)
+	contents := make([][]byte, 0, 3)
+	for _, entry := range []diff.FileEntry{baseEntry, oursEntry, theirsEntry} {
+		content := []byte{}
+		if entry.Sha != "" {
+			var err error
+			content, err = readBlob(entry.Sha)
+			utils.CheckError(err)
+		}
+		if diff.IsBinary(content) {
+			result.conflicts[path] = "binary"
+			return
+		}
+		contents = append(contents, content)
+	}
+
+	merged, clean := diff.MergeContents(contents[0], contents[1], contents[2], "HEAD", theirsLabel)
+	if !clean {
+		result.conflicts[path] = "content"
+		result.contents[path] = merged
+		return
+	}
+
+	sha, err := currentRepository.WriteObject(objects.CreateBlobObject(merged))
+	utils.CheckError(err)
+	result.files[path] = diff.FileEntry{Mode: mode, Sha: sha}
+}
+
+// checkMergeLocalChanges Exits without changing anything if the merge would overwrite changes to the files it updates, or
+// untracked files. A merge commit is created from the index, so it cant have staged changes at all
+func checkMergeLocalChanges(currentRepository *repository.Repository, repositoryIndex *index.IndexObject, ours map[string]diff.FileEntry,
+	result mergedFiles, requireCleanIndex bool) {
+	sparsePatterns, err := currentRepository.ReadSparsePatterns()
+	utils.CheckError(err)
+	changed, untracked := make([]string, 0), make([]string, 0)
+
+	if requireCleanIndex && !equalFileEntries(ours, getIndexFileEntries(repositoryIndex)) {
+		utils.ExitError("error: Your index contains uncommitted changes.\nPlease commit your changes before you merge.")
+	}
+
+	for _, path := range getMergeUpdatedPaths(ours, result) {
+		indexEntry, tracked := repositoryIndex.Entries[path]
+		oursEntry, inOurs := ours[path]
+		_, inResult := result.files[path]
+
+		switch {
+		case tracked != inOurs || (tracked && (indexEntry.Sha != oursEntry.Sha || indexEntry.Mode() != oursEntry.Mode)):
+			changed = append(changed, path)
+		case tracked:
+			if _, change, _ := getWorkTreeChange(currentRepository, repositoryIndex, indexEntry); change != ' ' {
+				changed = append(changed, path)
+			}
+		case inResult && isMergeWrittenPath(path, result, sparsePatterns) && utils.CheckFileOrDirExists(utils.Path(currentRepository.WorkTree, path)):
+			untracked = append(untracked, path)
+		}
+	}
+
+	if len(changed) > 0 {
+		utils.ExitError("error: Your local changes to the following files would be overwritten by merge:\n\t" + strings.Join(changed, "\n\t") +
+			"\nPlease commit your changes or stash them before you merge.\nAborting")
+	}
+	if len(untracked) > 0 {
+		utils.ExitError("error: The following untracked working tree files would be overwritten by merge:\n\t" + strings.Join(untracked, "\n\t") +
+			"\nPlease move or remove them before you merge.\nAborting")
+	}
+}
+
+// Paths whose index entry or work tree file is changed by the merge, sorted
+func getMergeUpdatedPaths(ours map[string]diff.FileEntry, result mergedFiles) []string {
+	paths := make([]string, 0)
+	for path, entry := range result.files {
+		if oursEntry, inOurs := ours[path]; !inOurs || oursEntry != entry || result.conflicts[path] != "" {
+			paths = append(paths, path)
+		}
+	}
+	for path, _ := range ours {
+		if _, inResult := result.files[path]; !inResult {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	return paths
+}
+
+// Files matching the sparse patterns are written in the work tree. Conflicting files are written too, to be resolved
+func isMergeWrittenPath(path string, result mergedFiles, sparsePatterns *sparse.Patterns) bool {
+	return sparsePatterns.Matches(path) || result.conflicts[path] != ""
+}
+
+// applyMergedFiles Updates the index and the work tree with the files changed by the merge. Files that dont match the sparse
+// patterns only get an index entry with the skip-worktree bit
+func applyMergedFiles(currentRepository *repository.Repository, repositoryIndex *index.IndexObject, ours map[string]diff.FileEntry, result mergedFiles) {
+	sparsePatterns, err := currentRepository.ReadSparsePatterns()
+	utils.CheckError(err)
+	indexAttributes, err := currentRepository.ReadIndexAttributes()
+	utils.CheckError(err)
+
+	for _, path := range getMergeUpdatedPaths(ours, result) {
+		previousEntry, tracked := repositoryIndex.Entries[path]
+		entry, inResult := result.files[path]
+		fullPath := utils.Path(currentRepository.WorkTree, path)
+
+		switch {
+		case !inResult:
+			delete(repositoryIndex.Entries, path)
+			if tracked && !previousEntry.SkipWorktree && !previousEntry.IsGitlink() {
+				removeWorkTreeFile(currentRepository, path)
+			}
+		case entry.IsGitlink():
+			repositoryIndex.Entries[path] = index.CreateGitlinkIndexEntry(path, entry.Sha)
+			if sparsePatterns.Matches(path) {
+				utils.Check(os.MkdirAll(fullPath, 0777), "Cannot create directory: "+fullPath)
+			}
+		case !isMergeWrittenPath(path, result, sparsePatterns):
+			indexEntry := index.IndexEntry{Sha: entry.Sha, FullPathName: path, SkipWorktree: true}
+			utils.CheckError(indexEntry.SetMode(entry.Mode))
+			repositoryIndex.Entries[path] = indexEntry
+			if tracked && !previousEntry.SkipWorktree {
+				removeWorkTreeFile(currentRepository, path)
+			}
+		default:
+			writeMergedFile(currentRepository, path, entry, result.contents[path], indexAttributes)
+			stat, err := os.Lstat(fullPath)
+			utils.Check(err, "Cannot get stat info of file "+fullPath)
+			indexEntry := index.CreateIndexEntry(stat, path, entry.Sha)
+			utils.CheckError(indexEntry.SetMode(entry.Mode))
+			repositoryIndex.Entries[path] = indexEntry
+		}
+	}
+
+	utils.CheckError(currentRepository.WriteIndex(repositoryIndex))
+}
+
+// Files with conflict markers are written as they are, the index keeps our version of them
+func writeMergedFile(currentRepository *repository.Repository, path string, entry diff.FileEntry, conflictContent []byte, indexAttributes *attributes.Attributes) {
+	fullPath := utils.Path(currentRepository.WorkTree, path)
+	utils.Check(os.MkdirAll(filepath.Dir(fullPath), 0777), "Cannot create directory: "+filepath.Dir(fullPath))
+
+	if conflictContent != nil {
+		utils.Check(os.WriteFile(fullPath, conflictContent, 0666), "Cannot write to file "+fullPath)
+		return
+	}
+	restoreFile(currentRepository, objects.TreeEntry{Mode: entry.Mode, Sha: entry.Sha, Path: path}, fullPath, indexAttributes)
+}
+
+// Resets the index and the work tree to HEAD and forgets the merge in progress
+func abortMerge(currentRepository *repository.Repository) {
+	if !utils.CheckFileOrDirExists(utils.Path(currentRepository.GitDir, MERGE_HEAD_FILE)) {
+		utils.ExitError("fatal: There is no merge to abort (MERGE_HEAD missing).")
+	}
+
+	resetIndexAndWorkTree(currentRepository, getCommitObject(currentRepository, getHeadSha(currentRepository)).Tree)
+	removeMergeState(currentRepository)
+}
+
+func removeMergeState(currentRepository *repository.Repository) {
+	for _, name := range []string{MERGE_HEAD_FILE, MERGE_MESSAGE_FILE} {
+		if err := os.Remove(utils.Path(currentRepository.GitDir, name)); err != nil && !os.IsNotExist(err) {
+			utils.CheckError(err)
+		}
+	}
+}
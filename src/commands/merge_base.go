@@ -0,0 +1,65 @@
+package commands
+
+import (
+	"fmt"
+	"git/src/objects"
+	"git/src/repository"
+	"git/src/utils"
+	"os"
+)
+
+// MergeBase Prints the best common ancestor of two commits, or all of them with --all. Exits with 1 if there is none.
+// With --is-ancestor nothing is printed and it exits with 0 if the first commit is an ancestor of the second, 1 otherwise
+// MergeBase Args: main.go merge-base [--all] <commit> <commit>
+// MergeBase Args: main.go merge-base --is-ancestor <commit> <commit>
+func MergeBase(args []string) {
+	const usage = "Invalid arguments: merge-base [--all | --is-ancestor] <commit> <commit>"
+	all, isAncestor, commitNames := false, false, make([]string, 0)
+	for _, arg := range args[2:] {
+		switch {
+		case arg == "-a" || arg == "--all":
+			all = true
+		case arg == "--is-ancestor":
+			isAncestor = true
+		case len(arg) > 1 && arg[0] == '-':
+			utils.ExitError(usage)
+		default:
+			commitNames = append(commitNames, arg)
+		}
+	}
+	if len(commitNames) != 2 || (all && isAncestor) {
+		utils.ExitError(usage)
+	}
+
+	currentRepository, _, err := repository.FindCurrentRepository(utils.CurrentPath())
+	utils.CheckError(err)
+	commits := make([]string, 0, len(commitNames))
+	for _, name := range commitNames {
+		sha, _, err := currentRepository.ResolveObjectName(name, objects.COMMIT)
+		if err != nil {
+			utils.ExitError("fatal: Not a valid commit name " + name)
+		}
+		commits = append(commits, sha)
+	}
+
+	if isAncestor {
+		reachable, err := currentRepository.IsAncestor(commits[0], commits[1])
+		utils.CheckError(err)
+		if !reachable {
+			os.Exit(1)
+		}
+		return
+	}
+
+	mergeBases, err := currentRepository.MergeBases(commits[0], commits[1])
+	utils.CheckError(err)
+	if len(mergeBases) == 0 {
+		os.Exit(1)
+	}
+	if !all {
+		mergeBases = mergeBases[:1]
+	}
+	for _, mergeBase := range mergeBases {
+		fmt.Println(mergeBase)
+	}
+}
@@ -0,0 +1,140 @@
+package commands
+
+import (
+	"git/src/objects"
+	"git/src/repository"
+	"git/src/utils"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMerge_FastForwardSparseCheckout(t *testing.T) {
+	currentRepository := createTestRepository(t)
+	createTestMergeBranches(t, currentRepository)
+	assert.Nil(t, os.MkdirAll(currentRepository.HooksDir(), os.ModePerm))
+	writeTestFile(t, currentRepository.HookPath(repository.POST_MERGE_HOOK), "#!/bin/sh\necho \"$1\" > \"$GIT_DIR/post-merge-args\"\n")
+	assert.Nil(t, os.Chmod(currentRepository.HookPath(repository.POST_MERGE_HOOK), 0755))
+	base := getTestHead(t)
+	SparseCheckout([]string{"main.go", "sparse-checkout", "set", "a"})
+	topic := readTestRef(t, currentRepository, "refs/heads/topic")
+
+	assert.Equal(t, "Updating "+base[:7]+".."+topic[:7]+"\nFast-forward\n", captureTestStdout(t, func() { Merge([]string{"main.go", "merge", "topic"}) }))
+	assert.Equal(t, topic, getTestHead(t))
+	assert.Equal(t, "", getStatusOutput(t, "--porcelain"))
+	assertTestFileContent(t, "a/file.txt", "1\n2\n3\n4\ntheirs\n")
+	assert.False(t, utils.CheckFileOrDirExists("a/other.txt"))
+	assert.False(t, utils.CheckFileOrDirExists("b"))
+	assert.True(t, readTestIndex(t).Entries["b/file.txt"].SkipWorktree)
+	assertTestFileContent(t, ".git/post-merge-args", "0\n")
+
+	assert.Equal(t, "Already up to date.\n", captureTestStdout(t, func() { Merge([]string{"main.go", "merge", base}) }))
+}
+
+func TestMerge_ThreeWaySparseCheckout(t *testing.T) {
+	currentRepository := createTestRepository(t)
+	createTestMergeBranches(t, currentRepository)
+	base := getTestHead(t)
+	writeTestFile(t, "a/file.txt", "1\nours\n3\n4\n5\n")
+	writeTestFile(t, "a/master.txt", "master\n")
+	commitTestChanges(t, "master", "a")
+	master := getTestHead(t)
+	SparseCheckout([]string{"main.go", "sparse-checkout", "set", "a"})
+	topic := readTestRef(t, currentRepository, "refs/heads/topic")
+
+	assert.Equal(t, "Merge made by the three-way strategy.\n", captureTestStdout(t, func() { Merge([]string{"main.go", "merge", "topic"}) }))
+	merge := readTestCommit(t, currentRepository, getTestHead(t))
+	assert.Equal(t, []string{master, topic}, merge.Parents)
+	assert.Equal(t, "Merge branch 'topic'\n", merge.Message)
+	assert.Equal(t, []string{"a/file.txt", "a/master.txt", "b/file.txt"}, getTestTreeFiles(t, currentRepository, merge.Tree))
+	assert.Equal(t, "", getStatusOutput(t, "--porcelain"))
+	assertTestFileContent(t, "a/file.txt", "1\nours\n3\n4\ntheirs\n")
+	assert.False(t, utils.CheckFileOrDirExists("a/other.txt"))
+	assert.False(t, utils.CheckFileOrDirExists("b"))
+	indexObject := readTestIndex(t)
+	assert.True(t, indexObject.Entries["b/file.txt"].SkipWorktree)
+	assert.Equal(t, objects.CreateBlobObject([]byte("b2\n")).Sha(), indexObject.Entries["b/file.txt"].Sha)
+
+	assert.Equal(t, "Already up to date.\n", captureTestStdout(t, func() { assert.True(t, mergeCommit(currentRepository, mergeOptions{commitName: base})) }))
+	assert.Equal(t, merge.Parents, readTestCommit(t, currentRepository, getTestHead(t)).Parents)
+}
+
+func TestMerge_ConflictsOutsideSparseCheckout(t *testing.T) {
+	currentRepository := createTestRepository(t)
+	createTestMergeBranches(t, currentRepository)
+	writeTestFile(t, "b/file.txt", "ours\n")
+	commitTestChanges(t, "master", "b")
+	master := getTestHead(t)
+	SparseCheckout([]string{"main.go", "sparse-checkout", "set", "a"})
+	currentRepository, _, err := repository.FindCurrentRepository(utils.CurrentPath()) //Reads the sparse checkout config
+	assert.Nil(t, err)
+	topic := readTestRef(t, currentRepository, "refs/heads/topic")
+
+	output := captureTestStdout(t, func() { assert.False(t, mergeCommit(currentRepository, mergeOptions{commitName: "topic"})) })
+	assert.Equal(t, "CONFLICT (content): Merge conflict in b/file.txt\n", output)
+	assert.Equal(t, master, getTestHead(t))
+	assertTestFileContent(t, "b/file.txt", "<<<<<<< HEAD\nours\n=======\nb2\n>>>>>>> topic\n")
+	assertTestFileContent(t, "a/file.txt", "1\n2\n3\n4\ntheirs\n")
+	assert.False(t, readTestIndex(t).Entries["b/file.txt"].SkipWorktree)
+	assertTestFileContent(t, ".git/"+MERGE_HEAD_FILE, topic+"\n")
+
+	writeTestFile(t, "b/file.txt", "resolved\n")
+	Add([]string{"main.go", "add", "b/file.txt"})
+	Commit([]string{"main.go", "commit"})
+	merge := readTestCommit(t, currentRepository, getTestHead(t))
+	assert.Equal(t, []string{master, topic}, merge.Parents)
+	assert.Equal(t, "Merge branch 'topic'\n", merge.Message)
+	assert.False(t, utils.CheckFileOrDirExists(".git/"+MERGE_HEAD_FILE))
+	assert.Equal(t, "", getStatusOutput(t, "--porcelain"))
+}
+
+func TestMerge_Abort(t *testing.T) {
+	currentRepository := createTestRepository(t)
+	createTestMergeBranches(t, currentRepository)
+	writeTestFile(t, "a/file.txt", "1\nours\n3\n4\nours\n")
+	commitTestChanges(t, "master", "a")
+	master := getTestHead(t)
+
+	captureTestStdout(t, func() { assert.False(t, mergeCommit(currentRepository, mergeOptions{commitName: "topic"})) })
+	Merge([]string{"main.go", "merge", "--abort"})
+
+	assert.Equal(t, master, getTestHead(t))
+	assert.Equal(t, "", getStatusOutput(t, "--porcelain"))
+	assertTestFileContent(t, "a/file.txt", "1\nours\n3\n4\nours\n")
+	assert.False(t, utils.CheckFileOrDirExists(".git/"+MERGE_HEAD_FILE))
+}
+
+// Commits a/file.txt, a/other.txt and b/file.txt in master, and a topic branch from there that changes the last line of
+// a/file.txt, removes a/other.txt and changes b/file.txt. master stays checked out
+func createTestMergeBranches(t *testing.T, currentRepository *repository.Repository) {
+	assert.Nil(t, os.Mkdir("a", os.ModePerm))
+	assert.Nil(t, os.Mkdir("b", os.ModePerm))
+	writeTestFile(t, "a/file.txt", "1\n2\n3\n4\n5\n")
+	writeTestFile(t, "a/other.txt", "other\n")
+	writeTestFile(t, "b/file.txt", "b1\n")
+	commitTestChanges(t, "base", ".")
+	base := getTestHead(t)
+
+	currentRepository.WriteRef(objects.Reference{NamePath: "heads/topic", Value: base})
+	assert.Nil(t, currentRepository.WriteToHead("ref: refs/heads/topic"))
+	writeTestFile(t, "a/file.txt", "1\n2\n3\n4\ntheirs\n")
+	assert.Nil(t, os.Remove("a/other.txt"))
+	writeTestFile(t, "b/file.txt", "b2\n")
+	commitTestChanges(t, "topic", "a", "a/other.txt", "b")
+
+	assert.Nil(t, currentRepository.WriteToHead("ref: refs/heads/master"))
+	Reset([]string{"main.go", "reset", "--hard"})
+}
+
+func readTestRef(t *testing.T, currentRepository *repository.Repository, name string) string {
+	ref, err := currentRepository.ResolveRef(name)
+	assert.Nil(t, err)
+	return ref.Value
+}
+
+func assertTestFileContent(t *testing.T, path string, expected string) {
+	content, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, string(content))
+}
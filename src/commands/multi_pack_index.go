@@ -0,0 +1,30 @@
+package commands
+
+import (
+	"git/src/repository"
+	"git/src/utils"
+)
+
+// MultiPackIndex Writes or verifies .git/objects/pack/multi-pack-index, used to find packed objects with a single binary
+// search. With --bitmap the reachability bitmaps of the packed objects are written too
+// MultiPackIndex Args: main.go multi-pack-index (write [--bitmap] | verify)
+func MultiPackIndex(args []string) {
+	if len(args) < 3 {
+		utils.ExitError("Invalid arguments: multi-pack-index (write [--bitmap] | verify)")
+	}
+	currentRepository, _, err := repository.FindCurrentRepository(utils.CurrentPath())
+	utils.CheckError(err)
+
+	switch {
+	case args[2] == "write" && len(args) == 3:
+		_, err = currentRepository.WriteMultiPackIndex(false)
+		utils.CheckError(err)
+	case args[2] == "write" && len(args) == 4 && args[3] == "--bitmap":
+		_, err = currentRepository.WriteMultiPackIndex(true)
+		utils.CheckError(err)
+	case args[2] == "verify" && len(args) == 3:
+		utils.CheckError(currentRepository.VerifyMultiPackIndex())
+	default:
+		utils.ExitError("Invalid arguments: multi-pack-index (write [--bitmap] | verify)")
+	}
+}
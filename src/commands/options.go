@@ -0,0 +1,25 @@
+package commands
+
+import (
+	"git/src/utils"
+	"strings"
+)
+
+// matchesOption True if arg is the option name, alone or with its value as name=value
+func matchesOption(arg string, name string) bool {
+	return arg == name || strings.HasPrefix(arg, name+"=")
+}
+
+// getOptionValue Returns the value of an option passed as --name=value or --name value. In the second case i is moved to
+// the value
+func getOptionValue(args []string, i *int, arg string) string {
+	if equals := strings.Index(arg, "="); equals != -1 {
+		return arg[equals+1:]
+	}
+	if *i+1 >= len(args) {
+		utils.ExitError("Option " + arg + " requires a value")
+	}
+
+	*i++
+	return args[*i]
+}
@@ -0,0 +1,115 @@
+package commands
+
+import (
+	"fmt"
+	"git/src/index"
+	"git/src/objects"
+	"git/src/repository"
+	"git/src/utils"
+)
+
+const (
+	RESET_SOFT  = "--soft"  //Only moves the branch
+	RESET_MIXED = "--mixed" //Also resets the index
+	RESET_HARD  = "--hard"  //Also resets the work tree
+)
+
+// Reset Moves the current branch, or HEAD if it is detached, to the commit (HEAD by default). With sparse checkout, the index
+// entries not matching the patterns get the skip-worktree bit, and --hard only writes the matching files. A merge in progress is forgotten
+// Reset Args: main.go reset [--soft | --mixed | --hard] [<commit>]
+func Reset(args []string) {
+	mode, commitName := RESET_MIXED, "HEAD"
+	names := make([]string, 0)
+	for _, arg := range args[2:] {
+		switch {
+		case arg == RESET_SOFT || arg == RESET_MIXED || arg == RESET_HARD:
+			mode = arg
+		case len(arg) > 1 && arg[0] == '-':
+			utils.ExitError("Unknown option " + arg + " for reset")
+		default:
+			names = append(names, arg)
+		}
+	}
+	if len(names) > 1 {
+		utils.ExitError("Invalid arguments: reset [--soft | --mixed | --hard] [<commit>]")
+	} else if len(names) == 1 {
+		commitName = names[0]
+	}
+
+	currentRepository, _, err := repository.FindCurrentRepository(utils.CurrentPath())
+	utils.CheckError(err)
+	utils.CheckError(currentRepository.RequireWorkTree())
+	sha, _, err := currentRepository.ResolveObjectName(commitName, objects.COMMIT)
+	if err != nil {
+		utils.ExitError("fatal: Not a valid commit name " + commitName)
+	}
+	commitObject := getCommitObject(currentRepository, sha)
+
+	switch mode {
+	case RESET_MIXED:
+		resetIndex(currentRepository, commitObject.Tree)
+	case RESET_HARD:
+		resetIndexAndWorkTree(currentRepository, commitObject.Tree)
+	}
+	moveHead(currentRepository, sha)
+	removeMergeState(currentRepository)
+
+	if mode == RESET_HARD {
+		fmt.Println("HEAD is now at " + sha[:7] + " " + commitObject.Subject())
+	}
+}
+
+// Entries whose blob and mode dont change keep their stat info, so the files are not hashed again by status
+func resetIndex(currentRepository *repository.Repository, treeSha string) {
+	files, err := currentRepository.ReadTreeFiles(treeSha)
+	utils.CheckError(err)
+	sparsePatterns, err := currentRepository.ReadSparsePatterns()
+	utils.CheckError(err)
+	indexObject, err := currentRepository.ReadIndex()
+	utils.CheckError(err)
+
+	entries := make(map[string]index.IndexEntry)
+	for path, treeEntry := range files {
+		entry, tracked := indexObject.Entries[path]
+		if !tracked || entry.Sha != treeEntry.Sha || entry.Mode() != treeEntry.Mode {
+			entry = index.IndexEntry{Sha: treeEntry.Sha, FullPathName: path}
+			if treeEntry.IsGitlink() {
+				entry = index.CreateGitlinkIndexEntry(path, treeEntry.Sha)
+			}
+			utils.CheckError(entry.SetMode(treeEntry.Mode))
+		}
+		entry.SkipWorktree = !treeEntry.IsGitlink() && !sparsePatterns.Matches(path)
+		entries[path] = entry
+	}
+	indexObject.Entries = entries
+
+	utils.CheckError(currentRepository.WriteIndex(indexObject))
+}
+
+// Tracked files that are not in the tree are removed. Skip-worktree entries are not in the work tree
+func resetIndexAndWorkTree(currentRepository *repository.Repository, treeSha string) {
+	previousIndexObject, err := currentRepository.ReadIndex()
+	utils.CheckError(err)
+
+	checkoutTree(currentRepository, treeSha, currentRepository.WorkTree)
+
+	indexObject, err := currentRepository.ReadIndex()
+	utils.CheckError(err)
+	for path, entry := range previousIndexObject.Entries {
+		if _, kept := indexObject.Entries[path]; !kept && !entry.SkipWorktree && !entry.IsGitlink() {
+			removeWorkTreeFile(currentRepository, path)
+		}
+	}
+}
+
+func moveHead(currentRepository *repository.Repository, sha string) {
+	branch, detached, err := currentRepository.GetActiveBranch()
+	utils.CheckError(err)
+	if detached {
+		utils.CheckError(currentRepository.WriteToHead(sha))
+		return
+	}
+
+	err = currentRepository.UpdateRefsAtomically([]repository.RefUpdate{{Name: "refs/heads/" + branch, OldValue: getHeadSha(currentRepository), NewValue: sha}})
+	utils.CheckError(err)
+}
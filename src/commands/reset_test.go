@@ -0,0 +1,58 @@
+package commands
+
+import (
+	"git/src/index"
+	"git/src/repository"
+	"git/src/utils"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReset_SparseCheckout(t *testing.T) {
+	createTestRepository(t)
+	assert.Nil(t, os.Mkdir("a", os.ModePerm))
+	assert.Nil(t, os.Mkdir("b", os.ModePerm))
+	writeTestFile(t, "a/file.txt", "a1\n")
+	writeTestFile(t, "b/file.txt", "b1\n")
+	commitTestChanges(t, "first", ".")
+	first := getTestHead(t)
+	writeTestFile(t, "a/file.txt", "a2\n")
+	writeTestFile(t, "a/new.txt", "new\n")
+	writeTestFile(t, "b/file.txt", "b2\n")
+	commitTestChanges(t, "second", ".")
+	second := getTestHead(t)
+	SparseCheckout([]string{"main.go", "sparse-checkout", "set", "a"})
+
+	Reset([]string{"main.go", "reset", first})
+	assert.Equal(t, first, getTestHead(t))
+	assert.Equal(t, " M a/file.txt\n?? a/new.txt\n", getStatusOutput(t, "--porcelain"))
+	assert.False(t, utils.CheckFileOrDirExists("b"))
+	indexObject := readTestIndex(t)
+	assert.True(t, indexObject.Entries["b/file.txt"].SkipWorktree)
+	assert.False(t, indexObject.Entries["a/file.txt"].SkipWorktree)
+
+	Reset([]string{"main.go", "reset", "--hard", second})
+	assert.Equal(t, "", getStatusOutput(t, "--porcelain"))
+	Reset([]string{"main.go", "reset", "--hard", first})
+	assert.Equal(t, "", getStatusOutput(t, "--porcelain"))
+	assert.False(t, utils.CheckFileOrDirExists("a/new.txt"))
+	assert.False(t, utils.CheckFileOrDirExists("b"))
+	content, err := os.ReadFile("a/file.txt")
+	assert.Nil(t, err)
+	assert.Equal(t, "a1\n", string(content))
+
+	Reset([]string{"main.go", "reset", "--soft", second})
+	assert.Equal(t, second, getTestHead(t))
+	assert.Equal(t, "M  a/file.txt\nD  a/new.txt\nM  b/file.txt\n", getStatusOutput(t, "--porcelain"))
+	assert.True(t, readTestIndex(t).Entries["b/file.txt"].SkipWorktree)
+}
+
+func readTestIndex(t *testing.T) *index.IndexObject {
+	currentRepository, _, err := repository.FindCurrentRepository(utils.CurrentPath())
+	assert.Nil(t, err)
+	indexObject, err := currentRepository.ReadIndex()
+	assert.Nil(t, err)
+	return indexObject
+}
@@ -0,0 +1,31 @@
+package commands
+
+import (
+	"fmt"
+	"git/src/server"
+	"git/src/utils"
+	"net/http"
+	"strconv"
+)
+
+// Serve Serves every repository under directory using the git smart HTTP protocol. Ex: git clone http://localhost:8080/<repository>
+// Serve Args: main.go serve [--port <port>] [directory default: current path]
+func Serve(args []string) {
+	port := 8080
+	rootPath := utils.CurrentPath()
+
+	for i := 2; i < len(args); i++ {
+		if args[i] == "--port" && i+1 < len(args) {
+			parsedPort, err := strconv.Atoi(args[i+1])
+			utils.Check(err, "Invalid port "+args[i+1])
+			port = parsedPort
+			i++
+		} else {
+			rootPath = args[i]
+		}
+	}
+
+	fmt.Println("Serving repositories in " + rootPath + " on http://localhost:" + strconv.Itoa(port))
+
+	utils.CheckError(http.ListenAndServe(":"+strconv.Itoa(port), server.CreateServer(rootPath)))
+}
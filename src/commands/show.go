@@ -0,0 +1,110 @@
+package commands
+
+import (
+	"fmt"
+	"git/src/diff"
+	"git/src/objects"
+	"git/src/repository"
+	"git/src/utils"
+	"os"
+	"strings"
+)
+
+// Show Shows objects: commits with their patch against the first parent, annotated tags followed by their target,
+// trees as a list of names and blobs as their content. Merge commits are shown without patch
+// Show Args: main.go show [-s | --no-patch] [<object> | <revision>:<path>]... (default HEAD)
+func Show(args []string) {
+	currentRepository, _, err := repository.FindCurrentRepository(utils.CurrentPath())
+	utils.CheckError(err)
+
+	names, showPatch := make([]string, 0), true
+	for _, arg := range args[2:] {
+		switch {
+		case arg == "-s" || arg == "--no-patch":
+			showPatch = false
+		case strings.HasPrefix(arg, "-"):
+			utils.ExitError("Unknown option " + arg + " for show")
+		default:
+			names = append(names, arg)
+		}
+	}
+	if len(names) == 0 {
+		names = append(names, "HEAD")
+	}
+
+	for i, name := range names {
+		if i > 0 {
+			fmt.Println()
+		}
+		sha, _, err := currentRepository.ResolveObjectName(name, objects.ANY)
+		if err != nil {
+			utils.ExitError("fatal: bad revision '" + name + "'")
+		}
+		showObject(currentRepository, name, sha, showPatch)
+	}
+}
+
+func showObject(currentRepository *repository.Repository, name string, sha string, showPatch bool) {
+	object, err := currentRepository.ReadObject(sha, objects.ANY)
+	if err != nil {
+		utils.ExitError("Cannot read object: " + err.Error())
+	}
+
+	switch object.Type {
+	case objects.COMMIT:
+		commit, err := currentRepository.ReadCommitObject(sha) //Shallow commits have no parents
+		utils.CheckError(err)
+		showCommit(currentRepository, repository.RevCommit{Sha: sha, Commit: commit}, showPatch)
+	case objects.TAG:
+		tag := object.SerializableGitObject.(objects.TagObject)
+		fmt.Println("tag " + tag.Tag)
+		if tag.Tagger != "" {
+			tagger := tag.TaggerSignature()
+			fmt.Println("Tagger: " + tagger.Identity())
+			fmt.Println("Date:   " + tagger.When.Format(DATE_FORMAT_DEFAULT))
+		}
+		fmt.Println()
+		if tag.Message != "" {
+			fmt.Println(strings.TrimRight(tag.Message, "\n"))
+			fmt.Println()
+		}
+		showObject(currentRepository, tag.Object, tag.Object, showPatch)
+	case objects.TREE:
+		fmt.Println("tree " + name)
+		fmt.Println()
+		for _, entry := range object.SerializableGitObject.(objects.TreeObject).Entries {
+			if entry.IsDir() {
+				fmt.Println(entry.Path + "/")
+			} else {
+				fmt.Println(entry.Path)
+			}
+		}
+	case objects.BLOB:
+		_, _ = os.Stdout.Write(object.SerializableGitObject.(objects.BlobObject).Data)
+	}
+}
+
+func showCommit(currentRepository *repository.Repository, commit repository.RevCommit, showPatch bool) {
+	fmt.Println(formatCommitPretty(PRETTY_MEDIUM, commit, []string{}, false))
+	if !showPatch || len(commit.Commit.Parents) > 1 {
+		return
+	}
+
+	oldFiles := make(map[string]diff.FileEntry)
+	if commit.Commit.HasParent() {
+		oldFiles = getCommitFileEntries(currentRepository, commit.Commit.Parent)
+	}
+	readBlob := getBlobReader(currentRepository)
+	changes, err := diff.DetectRenames(diff.DiffFileMaps(oldFiles, getTreeFileEntries(currentRepository, commit.Commit.Tree)), getConfigRenameOptions(currentRepository, "diff"), readBlob)
+	utils.CheckError(err)
+
+	repositoryAttributes, err := currentRepository.ReadAttributes()
+	utils.CheckError(err)
+
+	if len(changes) > 0 {
+		fmt.Println()
+	}
+	for _, change := range changes {
+		fmt.Print(formatChangePatch(currentRepository, repositoryAttributes, change, readBlob))
+	}
+}
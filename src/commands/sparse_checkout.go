@@ -0,0 +1,220 @@
+package commands
+
+import (
+	"fmt"
+	"git/src/index"
+	"git/src/objects"
+	"git/src/repository"
+	"git/src/sparse"
+	"git/src/utils"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SparseCheckout Only checks out the files matching the patterns of .git/info/sparse-checkout. The rest keep their index
+// entries with the skip-worktree bit, so status doesnt report them as deleted. In cone mode (default) the patterns are directories
+// SparseCheckout Args: main.go sparse-checkout init [--cone | --no-cone]
+// SparseCheckout Args: main.go sparse-checkout set [--cone | --no-cone] <directory | pattern>...
+// SparseCheckout Args: main.go sparse-checkout add <directory | pattern>...
+// SparseCheckout Args: main.go sparse-checkout list
+// SparseCheckout Args: main.go sparse-checkout disable
+func SparseCheckout(args []string) {
+	if len(args) < 3 {
+		utils.ExitError("Invalid arguments: sparse-checkout (init|set|add|list|disable)")
+	}
+
+	currentRepository, _, err := repository.FindCurrentRepository(utils.CurrentPath())
+	utils.CheckError(err)
+	utils.CheckError(currentRepository.RequireWorkTree())
+
+	switch subcommand := args[2]; subcommand {
+	case "init":
+		sparseCheckoutInit(currentRepository, args[3:])
+	case "set":
+		sparseCheckoutSet(currentRepository, args[3:])
+	case "add":
+		sparseCheckoutAdd(currentRepository, args[3:])
+	case "list":
+		sparseCheckoutList(currentRepository)
+	case "disable":
+		sparseCheckoutDisable(currentRepository)
+	default:
+		utils.ExitError("Unknown sparse-checkout subcommand " + subcommand)
+	}
+}
+
+// Existing patterns are kept. Without them, only the files at the root are checked out
+func sparseCheckoutInit(currentRepository *repository.Repository, args []string) {
+	cone, args := parseConeOption(currentRepository, args)
+	if len(args) > 0 {
+		utils.ExitError("Invalid arguments: sparse-checkout init [--cone | --no-cone]")
+	}
+
+	utils.CheckError(currentRepository.SetSparseCheckoutConfig(true, cone))
+	if !utils.CheckFileOrDirExists(currentRepository.SparseCheckoutPath()) {
+		utils.CheckError(currentRepository.WriteSparsePatterns(sparse.ConePatterns(nil)))
+	}
+
+	reapplySparsePatterns(currentRepository)
+}
+
+func sparseCheckoutSet(currentRepository *repository.Repository, args []string) {
+	cone, args := parseConeOption(currentRepository, args)
+
+	utils.CheckError(currentRepository.SetSparseCheckoutConfig(true, cone))
+	utils.CheckError(currentRepository.WriteSparsePatterns(createSparsePatterns(cone, args)))
+
+	reapplySparsePatterns(currentRepository)
+}
+
+func sparseCheckoutAdd(currentRepository *repository.Repository, args []string) {
+	if len(args) == 0 {
+		utils.ExitError("Invalid arguments: sparse-checkout add <directory | pattern>...")
+	}
+	patterns := readEnabledSparsePatterns(currentRepository)
+
+	utils.CheckError(currentRepository.WriteSparsePatterns(createSparsePatterns(patterns.Cone, append(patterns.Dirs(), args...))))
+
+	reapplySparsePatterns(currentRepository)
+}
+
+func sparseCheckoutList(currentRepository *repository.Repository) {
+	for _, line := range readEnabledSparsePatterns(currentRepository).Dirs() {
+		fmt.Println(line)
+	}
+}
+
+// All the files are checked out again. The patterns file is kept for a later init
+func sparseCheckoutDisable(currentRepository *repository.Repository) {
+	indexObject, err := currentRepository.ReadIndex()
+	utils.CheckError(err)
+	applySparsePatterns(currentRepository, indexObject, nil)
+	utils.CheckError(currentRepository.WriteIndex(indexObject))
+
+	utils.CheckError(currentRepository.SetSparseCheckoutConfig(false, false))
+}
+
+// Without --cone or --no-cone the current mode is kept, cone by default
+func parseConeOption(currentRepository *repository.Repository, args []string) (bool, []string) {
+	cone := currentRepository.Config.MustBool("core.sparseCheckoutCone", true)
+
+	rest := make([]string, 0, len(args))
+	for _, arg := range args {
+		switch arg {
+		case "--cone":
+			cone = true
+		case "--no-cone":
+			cone = false
+		default:
+			rest = append(rest, arg)
+		}
+	}
+
+	return cone, rest
+}
+
+func createSparsePatterns(cone bool, args []string) *sparse.Patterns {
+	if cone {
+		return sparse.ConePatterns(args)
+	}
+	return sparse.Parse([]byte(strings.Join(args, "\n")), false)
+}
+
+func readEnabledSparsePatterns(currentRepository *repository.Repository) *sparse.Patterns {
+	patterns, err := currentRepository.ReadSparsePatterns()
+	utils.CheckError(err)
+	if patterns == nil {
+		utils.ExitError("fatal: this worktree is not sparse")
+	}
+
+	return patterns
+}
+
+func reapplySparsePatterns(currentRepository *repository.Repository) {
+	patterns, err := currentRepository.ReadSparsePatterns()
+	utils.CheckError(err)
+	indexObject, err := currentRepository.ReadIndex()
+	utils.CheckError(err)
+
+	applySparsePatterns(currentRepository, indexObject, patterns)
+	utils.CheckError(currentRepository.WriteIndex(indexObject))
+}
+
+// applySparsePatterns Writes the skipped files that match the patterns and removes the checked out files that dont.
+// Modified files are not removed, they keep being checked out
+func applySparsePatterns(currentRepository *repository.Repository, indexObject *index.IndexObject, patterns *sparse.Patterns) {
+	indexAttributes, err := currentRepository.ReadIndexAttributes()
+	utils.CheckError(err)
+
+	for path, entry := range indexObject.Entries {
+		matches := patterns.Matches(path)
+		fullPath := utils.Path(currentRepository.WorkTree, path)
+
+		if matches && entry.SkipWorktree {
+			utils.Check(os.MkdirAll(filepath.Dir(fullPath), 0777), "Cannot create directory: "+filepath.Dir(fullPath))
+			if entry.IsGitlink() {
+				utils.Check(os.MkdirAll(fullPath, 0777), "Cannot create directory: "+fullPath)
+				indexObject.Entries[path] = index.CreateGitlinkIndexEntry(path, entry.Sha)
+				continue
+			}
+
+			restoreFile(currentRepository, objects.TreeEntry{Mode: entry.Mode(), Sha: entry.Sha, Path: path}, fullPath, indexAttributes)
+			entry.SkipWorktree = false
+			indexObject.Entries[path] = entry
+			refreshIndexEntryStat(indexObject, path, fullPath)
+		} else if !matches && !entry.SkipWorktree && !entry.IsGitlink() {
+			modified, err := currentRepository.IsWorkTreeFileModified(indexObject, entry)
+			utils.CheckError(err)
+			if modified && utils.CheckFileOrDirExists(fullPath) {
+				fmt.Fprintln(os.Stderr, "warning: not removing "+path+" from the work tree, it has local changes")
+				continue
+			}
+
+			removeWorkTreeFile(currentRepository, path)
+			entry.SkipWorktree = true
+			indexObject.Entries[path] = entry
+		}
+	}
+}
+
+// removeSkippedFiles Removes the files left in the work tree by a previous checkout whose new entries are skipped. Files with
+// local changes are kept, and so they lose the skip-worktree bit
+func removeSkippedFiles(currentRepository *repository.Repository, previousIndexObject *index.IndexObject, indexObject *index.IndexObject) {
+	for path, entry := range indexObject.Entries {
+		if !entry.SkipWorktree {
+			continue
+		}
+		if _, err := os.Lstat(utils.Path(currentRepository.WorkTree, path)); err != nil {
+			continue
+		}
+
+		previousEntry, tracked := previousIndexObject.Entries[path]
+		if tracked && !previousEntry.SkipWorktree {
+			modified, err := currentRepository.IsWorkTreeFileModified(previousIndexObject, previousEntry)
+			utils.CheckError(err)
+			if !modified {
+				removeWorkTreeFile(currentRepository, path)
+				continue
+			}
+		}
+
+		fmt.Fprintln(os.Stderr, "warning: not removing "+path+" from the work tree, it has local changes")
+		entry.SkipWorktree = false
+		indexObject.Entries[path] = entry
+	}
+}
+
+// Empty parent directories are removed too
+func removeWorkTreeFile(currentRepository *repository.Repository, path string) {
+	fullPath := utils.Path(currentRepository.WorkTree, path)
+	if err := os.Remove(fullPath); err != nil && !os.IsNotExist(err) {
+		utils.Check(err, "Cannot remove "+fullPath)
+	}
+
+	for dir := filepath.Dir(fullPath); dir != currentRepository.WorkTree && strings.HasPrefix(dir, currentRepository.WorkTree); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil { //Not empty
+			break
+		}
+	}
+}
@@ -2,126 +2,595 @@ package commands
 
 import (
 	"fmt"
+	"git/src/diff"
+	"git/src/ignore"
 	"git/src/index"
-	"git/src/objects"
 	"git/src/repository"
 	"git/src/utils"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
 )
 
-func Status() {
+const (
+	UNTRACKED_FILES_NO     = "no"
+	UNTRACKED_FILES_NORMAL = "normal" //Untracked directories are shown as a single entry
+	UNTRACKED_FILES_ALL    = "all"
+
+	NULL_MODE = "000000"
+	NULL_SHA  = "0000000000000000000000000000000000000000"
+)
+
+type statusOptions struct {
+	porcelain      string //"" human readable, "short", "v1" or "v2"
+	nullTerminated bool
+	showBranch     bool
+	untrackedFiles string
+	showIgnored    bool
+	quotePath      bool //core.quotePath
+	renameOptions  diff.RenameOptions
+}
+
+type statusEntry struct {
+	Path     string
+	OrigPath string //Only set in renames
+	Staged   byte   //X: ' ' unmodified, 'A' added, 'M' modified, 'D' deleted, 'R' renamed
+	Unstaged byte   //Y: ' ' unmodified, 'M' modified, 'D' deleted
+	Score    int    //Similarity of renames (0-100)
+
+	HeadMode     string
+	IndexMode    string
+	WorkTreeMode string
+	HeadSha      string
+	IndexSha     string
+
+	Submodule              bool
+	SubmoduleCommitChanged bool
+}
+
+type branchStatus struct {
+	HeadSha      string //Empty if the branch is unborn
+	Branch       string //Empty if HEAD is detached
+	Upstream     string
+	UpstreamGone bool
+	Ahead        int
+	Behind       int
+}
+
+type statusResult struct {
+	Branch    branchStatus
+	Entries   []statusEntry //Sorted by path
+	Untracked []string      //Directories end with "/"
+	Ignored   []string
+}
+
+// Status Args: main.go status [-s | --short | --porcelain[=v1|v2]] [-z] [-b | --branch] [-u[<mode>] | --untracked-files[=<mode>]]
+// Status Args: [--ignored] [--no-renames | --find-renames[=<n>]]
+// Untracked modes: no, normal, all. Short options can be combined like -sb or -suno. The short format is the porcelain v1
+// one. Paths are quoted as set by core.quotePath. Staged renames are detected unless status.renames is false. If it is "copies", copies are detected too
+func Status(args []string) {
 	currentRepository, _, err := repository.FindCurrentRepository(utils.CurrentPath())
 	if err != nil {
 		utils.ExitError(err.Error())
 	}
+	options := parseStatusOptions(args[2:], getConfigRenameOptions(currentRepository, "status"))
+	options.quotePath = currentRepository.Config.MustBool("core.quotePath", true)
+	utils.CheckError(currentRepository.RequireWorkTree())
 	repositoryIndex, err := currentRepository.ReadIndex()
 	if err != nil {
-		utils.ExitError("No commits haven been made in this repository")
+		utils.ExitError("Cannot read index: " + err.Error())
+	}
+
+	result := getStatus(currentRepository, repositoryIndex, options)
+
+	switch options.porcelain {
+	case "short", "v1":
+		printStatusPorcelainV1(result, options)
+	case "v2":
+		printStatusPorcelainV2(result, options)
+	default:
+		printStatusHumanReadable(result)
 	}
 
-	printBranchStatus(currentRepository)
-	printChangesBetweenHeadAndIndex(currentRepository, repositoryIndex)
-	printChangesBetweenWorktreeAndIndex(currentRepository, repositoryIndex)
+	//Stat info of files that have been hashed and were unchanged is refreshed, so they dont need to be hashed again
+	utils.CheckError(currentRepository.WriteIndex(repositoryIndex))
 }
 
-// files not in stagging area
-func printChangesBetweenWorktreeAndIndex(repository *repository.Repository, index *index.IndexObject) {
-	fmt.Println("Changes not stagged for commit:")
+func parseStatusOptions(args []string, renameOptions diff.RenameOptions) statusOptions {
+	options := statusOptions{untrackedFiles: UNTRACKED_FILES_NORMAL, renameOptions: renameOptions}
 
-	fileNamesInWorkTree := utils.GetAllSubfiles(repository.WorkTree)
+	for _, arg := range expandStatusShortOptions(args) {
+		switch {
+		case arg == "-s" || arg == "--short":
+			options.porcelain = "short"
+		case arg == "--porcelain" || arg == "--porcelain=v1" || arg == "--porcelain=1":
+			options.porcelain = "v1"
+		case arg == "--porcelain=v2" || arg == "--porcelain=2":
+			options.porcelain = "v2"
+		case arg == "-z":
+			options.nullTerminated = true
+		case arg == "-b" || arg == "--branch":
+			options.showBranch = true
+		case arg == "-u" || arg == "--untracked-files":
+			options.untrackedFiles = UNTRACKED_FILES_ALL
+		case strings.HasPrefix(arg, "-u") || strings.HasPrefix(arg, "--untracked-files="):
+			options.untrackedFiles = strings.TrimPrefix(strings.TrimPrefix(arg, "--untracked-files="), "-u")
+		case arg == "--ignored" || arg == "--ignored=traditional" || arg == "--ignored=matching":
+			options.showIgnored = true
+		case arg == "--ignored=no":
+			options.showIgnored = false
+		case arg == "--no-renames":
+			options.renameOptions.DetectRenames, options.renameOptions.DetectCopies = false, false
+		case arg == "--find-renames" || strings.HasPrefix(arg, "--find-renames="):
+			options.renameOptions.DetectRenames = true
+			options.renameOptions.RenameThreshold = parseSimilarityThresholdArg(strings.TrimPrefix(strings.TrimPrefix(arg, "--find-renames"), "="))
+		default:
+			utils.ExitError("Unknown option " + arg + " for status")
+		}
+	}
 
-	for _, entry := range index.Entries {
-		fileExists := utils.CheckFileOrDirExists(entry.FullPathName)
+	if options.untrackedFiles != UNTRACKED_FILES_NO && options.untrackedFiles != UNTRACKED_FILES_NORMAL && options.untrackedFiles != UNTRACKED_FILES_ALL {
+		utils.ExitError("Invalid untracked files mode '" + options.untrackedFiles + "'")
+	}
+	if options.nullTerminated && options.porcelain == "" {
+		options.porcelain = "v1"
+	}
 
-		if fileExists {
-			stats, err := os.Stat(entry.FullPathName)
-			utils.Check(err, "Cannot get stats from file "+entry.FullPathName)
-			if stats.ModTime().UnixNano() > int64(entry.Ctime) || stats.ModTime().UnixNano() > int64(entry.Mtime) {
-				fmt.Println("modified " + entry.FullPathName)
-			}
+	return options
+}
+
+// Splits combined short options like -sbz into -s -b -z. The rest of the option after -u is its mode
+func expandStatusShortOptions(args []string) []string {
+	expanded := make([]string, 0, len(args))
+	for _, arg := range args {
+		if len(arg) <= 2 || arg[0] != '-' || arg[1] == '-' {
+			expanded = append(expanded, arg)
+			continue
 		}
-		if !fileExists {
-			fmt.Println(" deleted " + entry.FullPathName)
+		for i := 1; i < len(arg); i++ {
+			if arg[i] == 'u' {
+				expanded = append(expanded, "-"+arg[i:])
+				break
+			}
+			expanded = append(expanded, "-"+string(arg[i]))
 		}
+	}
 
-		if _, contained := fileNamesInWorkTree[entry.FullPathName]; contained {
-			delete(fileNamesInWorkTree, entry.FullPathName)
-		}
+	return expanded
+}
+
+func getStatus(currentRepository *repository.Repository, repositoryIndex *index.IndexObject, options statusOptions) statusResult {
+	branch := getBranchStatus(currentRepository)
+
+	headFiles := make(map[string]diff.FileEntry)
+	if branch.HeadSha != "" {
+		headFiles = getCommitFileEntries(currentRepository, branch.HeadSha)
 	}
 
-	fmt.Println("\nUntracked files:")
-	for untrackedFilePath, _ := range fileNamesInWorkTree {
-		fmt.Println(" ", untrackedFilePath)
+	entries := getTrackedChanges(currentRepository, headFiles, repositoryIndex, options.renameOptions)
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+
+	untracked, ignored := make([]string, 0), make([]string, 0)
+	if options.untrackedFiles != UNTRACKED_FILES_NO || options.showIgnored {
+		untracked, ignored = getUntrackedAndIgnoredFiles(currentRepository, repositoryIndex, options.untrackedFiles)
+	}
+	if options.untrackedFiles == UNTRACKED_FILES_NO {
+		untracked = make([]string, 0)
+	}
+	if !options.showIgnored {
+		ignored = make([]string, 0)
 	}
+
+	return statusResult{Branch: branch, Entries: entries, Untracked: untracked, Ignored: ignored}
 }
 
-// Stagging area compared to head
-func printChangesBetweenHeadAndIndex(repository *repository.Repository, index *index.IndexObject) {
-	fmt.Println("Changes to be commited:")
+func getBranchStatus(currentRepository *repository.Repository) branchStatus {
+	status := branchStatus{}
+
+	branchName, detached, err := currentRepository.GetActiveBranch()
+	utils.CheckError(err)
+	if !detached {
+		status.Branch = branchName
+	}
+
+	head, err := currentRepository.ResolveRef("HEAD")
+	if err != nil && !repository.IsErrorTypeNoCommitError(err) {
+		utils.ExitError("Cannot get HEAD reference: " + err.Error())
+	} else if err == nil {
+		status.HeadSha = head.Value
+	}
+
+	if upstream, hasUpstream := currentRepository.GetUpstream(branchName); !detached && hasUpstream {
+		status.Upstream = upstream.ShortName()
+		upstreamRef, err := currentRepository.ResolveRef(upstream.RefName)
+
+		if err != nil {
+			status.UpstreamGone = true
+		} else if status.HeadSha != "" {
+			status.Ahead, status.Behind, err = currentRepository.CountAheadBehind(status.HeadSha, upstreamRef.Value)
+			utils.CheckError(err)
+		}
+	}
 
-	treeObjectMapHead := getTreeObjectMapFromHEAD(repository)
+	return status
+}
+
+// Compares HEAD with the index (X) and the index with the work tree (Y)
+func getTrackedChanges(currentRepository *repository.Repository, headFiles map[string]diff.FileEntry, repositoryIndex *index.IndexObject,
+	renameOptions diff.RenameOptions) []statusEntry {
+
+	stagedChanges, err := diff.DetectRenames(diff.DiffFileMaps(headFiles, getIndexFileEntries(repositoryIndex)), renameOptions, getBlobReader(currentRepository))
+	utils.CheckError(err)
 
-	for _, indexEntry := range index.Entries {
-		shaInHead, containedInHead := treeObjectMapHead[indexEntry.FullPathName]
-		if containedInHead && shaInHead != indexEntry.Sha {
-			fmt.Println(" modified " + indexEntry.FullPathName)
+	entriesByPath := make(map[string]*statusEntry)
+	for _, change := range stagedChanges {
+		entry := &statusEntry{Path: change.Path(), Staged: byte(change.Type), Unstaged: ' ', Score: change.Score,
+			HeadMode: NULL_MODE, HeadSha: NULL_SHA, IndexMode: NULL_MODE, IndexSha: NULL_SHA, WorkTreeMode: NULL_MODE}
+		if change.Type == diff.RENAMED || change.Type == diff.COPIED {
+			entry.OrigPath = change.OldPath
 		}
-		if containedInHead {
-			delete(treeObjectMapHead, indexEntry.FullPathName)
+		if change.Type != diff.ADDED {
+			entry.HeadMode, entry.HeadSha = change.Old.Mode, change.Old.Sha
 		}
-		if !containedInHead {
-			fmt.Println(" added " + indexEntry.FullPathName)
+		if change.Type != diff.DELETED {
+			entry.IndexMode, entry.IndexSha = change.New.Mode, change.New.Sha
+		}
+		entry.Submodule = change.Old.IsGitlink() || change.New.IsGitlink()
+
+		entriesByPath[entry.Path] = entry
+	}
+
+	for path, indexEntry := range repositoryIndex.Entries {
+		workTreeMode, unstaged, submoduleCommitChanged := getWorkTreeChange(currentRepository, repositoryIndex, indexEntry)
+		entry, stagedChange := entriesByPath[path]
+
+		if !stagedChange && unstaged == ' ' {
+			continue
+		} else if !stagedChange { //Unchanged between HEAD and the index
+			entry = &statusEntry{Path: path, Staged: ' ', HeadMode: indexEntry.Mode(), HeadSha: indexEntry.Sha,
+				IndexMode: indexEntry.Mode(), IndexSha: indexEntry.Sha, Submodule: indexEntry.IsGitlink()}
+			entriesByPath[path] = entry
 		}
+
+		entry.Unstaged, entry.WorkTreeMode, entry.SubmoduleCommitChanged = unstaged, workTreeMode, submoduleCommitChanged
 	}
 
-	for key, _ := range treeObjectMapHead {
-		fmt.Println(" deleted " + key)
+	entries := make([]statusEntry, 0, len(entriesByPath))
+	for _, entry := range entriesByPath {
+		entries = append(entries, *entry)
 	}
+
+	return entries
 }
 
-func getTreeObjectMapFromHEAD(repository *repository.Repository) map[string]string {
-	treeHeadCommitSha, _, err := repository.ResolveObjectName("HEAD", objects.TREE)
-	if err != nil {
-		utils.ExitError("Cannot get HEAD reference: " + err.Error())
+// Returns the mode in the work tree, the Y code and if the commit checked out in the submodule differs from the index one.
+// Files excluded by the sparse checkout (skip-worktree) are not compared, they are not expected in the work tree
+func getWorkTreeChange(currentRepository *repository.Repository, repositoryIndex *index.IndexObject, indexEntry index.IndexEntry) (string, byte, bool) {
+	if indexEntry.SkipWorktree {
+		return indexEntry.Mode(), ' ', false
 	}
-	treeObject, err := repository.ReadTreeObject(treeHeadCommitSha)
+	stat, err := os.Lstat(utils.Path(currentRepository.WorkTree, indexEntry.FullPathName))
 	if err != nil {
-		utils.ExitError("Cannot get tree object from sha : " + treeHeadCommitSha + " error: " + err.Error())
+		return NULL_MODE, 'D', false
 	}
 
-	results := make(map[string]string)
-	getTreeObjectMapFromHEADRecursive(repository, treeObject.Entries, "", results)
+	if indexEntry.IsGitlink() { //Submodules are compared by the commit checked out in them
+		checkedOutSha, checkedOut := getSubmoduleCheckedOutCommit(currentRepository, indexEntry.FullPathName)
+		if checkedOut && checkedOutSha != indexEntry.Sha {
+			return indexEntry.Mode(), 'M', true
+		}
+		return indexEntry.Mode(), ' ', false
+	}
 
-	return results
+	workTreeMode := indexEntry.Mode()
+	if actualEntry := index.CreateIndexEntry(stat, indexEntry.FullPathName, ""); currentRepository.FileModeEnabled() || actualEntry.IsSymlink() != indexEntry.IsSymlink() {
+		workTreeMode = actualEntry.Mode()
+	}
+
+	modified, err := currentRepository.IsWorkTreeFileModified(repositoryIndex, indexEntry)
+	utils.Check(err, "Cannot get stats from file "+indexEntry.FullPathName)
+	if modified {
+		return workTreeMode, 'M', false
+	}
+
+	return workTreeMode, ' ', false
+}
+
+// Submodules, .git and ignored directories are not traversed
+func getUntrackedAndIgnoredFiles(currentRepository *repository.Repository, repositoryIndex *index.IndexObject, untrackedFiles string) ([]string, []string) {
+	trackedDirs := make(map[string]bool)
+	for path, _ := range repositoryIndex.Entries {
+		for dir := parentDir(path); dir != ""; dir = parentDir(dir) {
+			trackedDirs[dir] = true
+		}
+	}
+
+	gitIgnores, err := currentRepository.ReadGitIgnores()
+	utils.CheckError(err)
+	walk := &untrackedWalk{repositoryIndex: repositoryIndex, trackedDirs: trackedDirs, gitIgnores: gitIgnores}
+
+	untracked, ignored := make([]string, 0), make([]string, 0)
+	getUntrackedAndIgnoredFilesRecursive(currentRepository, walk, "", untrackedFiles, &untracked, &ignored)
+	sort.Strings(untracked)
+	sort.Strings(ignored)
+
+	return untracked, ignored
 }
 
-func getTreeObjectMapFromHEADRecursive(repository *repository.Repository, actualTreeEntries []objects.TreeEntry, prevPath string, results map[string]string) {
-	for _, actualTreeEntry := range actualTreeEntries {
-		actualPath := utils.Path(prevPath, actualTreeEntry.Path)
+// State shared by the whole walk of the work tree
+type untrackedWalk struct {
+	repositoryIndex *index.IndexObject
+	trackedDirs     map[string]bool             //Directories with some tracked file, always traversed
+	gitIgnores      map[string]ignore.GitIgnore //Read once, since they are found from the index
+}
+
+func getUntrackedAndIgnoredFilesRecursive(currentRepository *repository.Repository, walk *untrackedWalk, dirPath string, untrackedFiles string,
+	untracked *[]string, ignored *[]string) {
+
+	children, err := os.ReadDir(utils.Path(currentRepository.WorkTree, dirPath))
+	utils.Check(err, "Cannot read directory "+dirPath)
+
+	for _, child := range children {
+		childPath := utils.Path(dirPath, child.Name())
+		if dirPath == "" {
+			childPath = child.Name()
+		}
+		if child.Name() == ".git" {
+			continue
+		}
+		if _, tracked := walk.repositoryIndex.Entries[childPath]; tracked {
+			continue
+		}
+
+		if childIgnored, err := currentRepository.IsIgnoredBy(walk.gitIgnores, childPath); err == nil && childIgnored {
+			*ignored = append(*ignored, toStatusPath(childPath, child.IsDir()))
+			continue
+		}
 
-		if actualTreeEntry.IsDir() {
-			subTreeObject, err := repository.ReadTreeObject(actualTreeEntry.Sha)
-			if err != nil {
-				utils.ExitError("Cannot get tree object from sha : " + actualTreeEntry.Path + " error: " + err.Error())
+		if !child.IsDir() {
+			*untracked = append(*untracked, childPath)
+		} else if walk.trackedDirs[childPath] || untrackedFiles == UNTRACKED_FILES_ALL {
+			getUntrackedAndIgnoredFilesRecursive(currentRepository, walk, childPath, untrackedFiles, untracked, ignored)
+		} else {
+			childUntracked, childIgnored := make([]string, 0), make([]string, 0)
+			getUntrackedAndIgnoredFilesRecursive(currentRepository, walk, childPath, UNTRACKED_FILES_NORMAL, &childUntracked, &childIgnored)
+			if len(childUntracked) > 0 {
+				*untracked = append(*untracked, childPath+"/")
 			}
+			*ignored = append(*ignored, childIgnored...)
+		}
+	}
+}
+
+func toStatusPath(path string, isDir bool) string {
+	if isDir {
+		return path + "/"
+	}
+	return path
+}
+
+// Returns "" for files in the root of the work tree
+func parentDir(path string) string {
+	if lastSlash := strings.LastIndex(path, "/"); lastSlash != -1 {
+		return path[:lastSlash]
+	}
+	return ""
+}
 
-			getTreeObjectMapFromHEADRecursive(repository, subTreeObject.Entries, actualPath, results)
+// Format: XY PATH or XY ORIG_PATH -> PATH. With -z: XY PATH\0ORIG_PATH\0
+func printStatusPorcelainV1(result statusResult, options statusOptions) {
+	terminator := getStatusTerminator(options)
+
+	if options.showBranch {
+		fmt.Print("## " + formatBranchHeaderV1(result.Branch) + terminator)
+	}
+
+	for _, entry := range result.Entries {
+		if entry.OrigPath == "" {
+			fmt.Print(string(entry.Staged) + string(entry.Unstaged) + " " + formatStatusPath(entry.Path, options) + terminator)
+		} else if options.nullTerminated {
+			fmt.Print(string(entry.Staged) + string(entry.Unstaged) + " " + entry.Path + terminator + entry.OrigPath + terminator)
 		} else {
-			results[actualPath] = actualTreeEntry.Sha
+			fmt.Print(string(entry.Staged) + string(entry.Unstaged) + " " + formatStatusPath(entry.OrigPath, options) + " -> " + formatStatusPath(entry.Path, options) + terminator)
 		}
 	}
+	for _, untracked := range result.Untracked {
+		fmt.Print("?? " + formatStatusPath(untracked, options) + terminator)
+	}
+	for _, ignored := range result.Ignored {
+		fmt.Print("!! " + formatStatusPath(ignored, options) + terminator)
+	}
 }
 
-func printBranchStatus(repository *repository.Repository) {
-	branchName, detatched, err := repository.GetActiveBranch()
-	if err != nil {
-		utils.ExitError(err.Error())
+func formatBranchHeaderV1(branch branchStatus) string {
+	if branch.Branch == "" {
+		return "HEAD (no branch)"
+	}
+	if branch.HeadSha == "" {
+		return "No commits yet on " + branch.Branch
+	}
+	if branch.Upstream == "" {
+		return branch.Branch
+	}
+
+	header := branch.Branch + "..." + branch.Upstream
+	switch {
+	case branch.UpstreamGone:
+		header = header + " [gone]"
+	case branch.Ahead > 0 && branch.Behind > 0:
+		header = header + " [ahead " + strconv.Itoa(branch.Ahead) + ", behind " + strconv.Itoa(branch.Behind) + "]"
+	case branch.Ahead > 0:
+		header = header + " [ahead " + strconv.Itoa(branch.Ahead) + "]"
+	case branch.Behind > 0:
+		header = header + " [behind " + strconv.Itoa(branch.Behind) + "]"
+	}
+
+	return header
+}
+
+// Formats:
+// 1 XY <sub> <mH> <mI> <mW> <hH> <hI> <path>
+// 2 XY <sub> <mH> <mI> <mW> <hH> <hI> <X><score> <path><sep><origPath>
+// ? <path>
+// ! <path>
+func printStatusPorcelainV2(result statusResult, options statusOptions) {
+	terminator := getStatusTerminator(options)
+
+	if options.showBranch {
+		for _, header := range formatBranchHeadersV2(result.Branch) {
+			fmt.Print("# " + header + terminator)
+		}
+	}
+
+	for _, entry := range result.Entries {
+		fields := []string{toPorcelainV2Code(entry.Staged) + toPorcelainV2Code(entry.Unstaged), formatSubmoduleState(entry),
+			entry.HeadMode, entry.IndexMode, entry.WorkTreeMode, entry.HeadSha, entry.IndexSha}
+
+		if entry.OrigPath == "" {
+			fmt.Print("1 " + strings.Join(fields, " ") + " " + formatStatusPath(entry.Path, options) + terminator)
+		} else {
+			separator := "\t"
+			if options.nullTerminated {
+				separator = terminator
+			}
+			fmt.Print("2 " + strings.Join(fields, " ") + " " + string(entry.Staged) + strconv.Itoa(entry.Score) + " " +
+				formatStatusPath(entry.Path, options) + separator + formatStatusPath(entry.OrigPath, options) + terminator)
+		}
+	}
+	for _, untracked := range result.Untracked {
+		fmt.Print("? " + formatStatusPath(untracked, options) + terminator)
+	}
+	for _, ignored := range result.Ignored {
+		fmt.Print("! " + formatStatusPath(ignored, options) + terminator)
+	}
+}
+
+func formatBranchHeadersV2(branch branchStatus) []string {
+	headers := make([]string, 0)
+
+	if branch.HeadSha == "" {
+		headers = append(headers, "branch.oid (initial)")
+	} else {
+		headers = append(headers, "branch.oid "+branch.HeadSha)
+	}
+	if branch.Branch == "" {
+		headers = append(headers, "branch.head (detached)")
+	} else {
+		headers = append(headers, "branch.head "+branch.Branch)
+	}
+	if branch.Upstream != "" {
+		headers = append(headers, "branch.upstream "+branch.Upstream)
+	}
+	if branch.Upstream != "" && !branch.UpstreamGone {
+		headers = append(headers, "branch.ab +"+strconv.Itoa(branch.Ahead)+" -"+strconv.Itoa(branch.Behind))
+	}
+
+	return headers
+}
+
+func toPorcelainV2Code(code byte) string {
+	if code == ' ' {
+		return "."
+	}
+	return string(code)
+}
+
+// N... for files. For submodules S<C><M><U>, only commit changes (C) are detected
+func formatSubmoduleState(entry statusEntry) string {
+	if !entry.Submodule {
+		return "N..."
+	}
+	if entry.SubmoduleCommitChanged {
+		return "SC.."
+	}
+	return "S..."
+}
+
+func getStatusTerminator(options statusOptions) string {
+	if options.nullTerminated {
+		return "\x00"
 	}
+	return "\n"
+}
+
+// Paths with special characters are quoted like git does, unless -z is used. Non ASCII bytes are only quoted if
+// core.quotePath is enabled
+func formatStatusPath(path string, options statusOptions) string {
+	if options.nullTerminated {
+		return path
+	}
+	return utils.QuotePath(path, options.quotePath)
+}
 
-	if detatched {
-		fmt.Println("HEAD detached at", detatched)
-		os.Exit(1)
+func printStatusHumanReadable(result statusResult) {
+	printBranchStatus(result.Branch)
+
+	fmt.Println("Changes to be commited:")
+	for _, entry := range result.Entries {
+		switch entry.Staged {
+		case 'A':
+			fmt.Println(" added " + entry.Path)
+		case 'M':
+			fmt.Println(" modified " + entry.Path)
+		case 'D':
+			fmt.Println(" deleted " + entry.Path)
+		case 'R':
+			fmt.Println(" renamed " + entry.OrigPath + " -> " + entry.Path)
+		}
+	}
+
+	fmt.Println("\nChanges not stagged for commit:")
+	for _, entry := range result.Entries {
+		switch {
+		case entry.Unstaged == 'M' && entry.SubmoduleCommitChanged:
+			fmt.Println(" modified " + entry.Path + " (new commits)")
+		case entry.Unstaged == 'M':
+			fmt.Println(" modified " + entry.Path)
+		case entry.Unstaged == 'D':
+			fmt.Println(" deleted " + entry.Path)
+		}
+	}
+
+	fmt.Println("\nUntracked files:")
+	for _, untracked := range result.Untracked {
+		fmt.Println(" ", untracked)
+	}
+
+	if len(result.Ignored) > 0 {
+		fmt.Println("\nIgnored files:")
+		for _, ignored := range result.Ignored {
+			fmt.Println(" ", ignored)
+		}
+	}
+}
+
+func printBranchStatus(branch branchStatus) {
+	if branch.Branch == "" && len(branch.HeadSha) >= 7 {
+		fmt.Println("HEAD detached at", branch.HeadSha[:7])
+	} else if branch.Branch == "" {
+		fmt.Println("HEAD detached")
 	} else {
-		fmt.Println("On branch", branchName)
+		fmt.Println("On branch", branch.Branch)
+	}
+
+	if branch.HeadSha == "" {
+		fmt.Println("\nNo commits yet")
+	}
+
+	switch {
+	case branch.Upstream == "":
+	case branch.UpstreamGone:
+		fmt.Println("Your branch is based on '" + branch.Upstream + "', but the upstream is gone.")
+	case branch.Ahead > 0 && branch.Behind > 0:
+		fmt.Println("Your branch and '" + branch.Upstream + "' have diverged, and have " + strconv.Itoa(branch.Ahead) + " and " +
+			strconv.Itoa(branch.Behind) + " different commits each, respectively.")
+	case branch.Ahead > 0:
+		fmt.Println("Your branch is ahead of '" + branch.Upstream + "' by " + strconv.Itoa(branch.Ahead) + " commit(s).")
+	case branch.Behind > 0:
+		fmt.Println("Your branch is behind '" + branch.Upstream + "' by " + strconv.Itoa(branch.Behind) + " commit(s).")
+	default:
+		fmt.Println("Your branch is up to date with '" + branch.Upstream + "'.")
 	}
+	fmt.Println()
 }
@@ -0,0 +1,193 @@
+package commands
+
+import (
+	"git/src/config"
+	"git/src/diff"
+	"git/src/objects"
+	"git/src/repository"
+	"git/src/utils"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatus_ShortCodes(t *testing.T) {
+	createTestRepository(t)
+	writeTestFile(t, ".gitignore", "*.log\n")
+	writeTestFile(t, "modified.txt", "modified\n")
+	writeTestFile(t, "staged.txt", "staged\n")
+	writeTestFile(t, "deleted.txt", "deleted\n")
+	writeTestFile(t, "removed.txt", "removed\n")
+	commitTestChanges(t, "first", ".")
+
+	writeTestFile(t, "modified.txt", "modified in the work tree\n")
+	writeTestFile(t, "staged.txt", "staged in the index\n")
+	writeTestFile(t, "added.txt", "added\n")
+	assert.Nil(t, os.Remove("removed.txt"))
+	Add([]string{"main.go", "add", "staged.txt", "added.txt", "removed.txt"})
+	writeTestFile(t, "added.txt", "added and modified\n")
+	assert.Nil(t, os.Remove("deleted.txt"))
+	writeTestFile(t, "untracked.txt", "untracked\n")
+	assert.Nil(t, os.Mkdir("dir", os.ModePerm))
+	writeTestFile(t, "dir/untracked.txt", "untracked\n")
+	writeTestFile(t, "debug.log", "ignored\n")
+
+	assert.Equal(t, "AM added.txt\n"+
+		" D deleted.txt\n"+
+		" M modified.txt\n"+
+		"D  removed.txt\n"+
+		"M  staged.txt\n"+
+		"?? dir/\n"+
+		"?? untracked.txt\n"+
+		"!! debug.log\n", getStatusOutput(t, "--porcelain", "--ignored"))
+	assert.Equal(t, "?? dir/untracked.txt\n?? untracked.txt\n", filterStatusLines(getStatusOutput(t, "-s", "-uall"), "??"))
+	assert.Equal(t, "", filterStatusLines(getStatusOutput(t, "-s", "-uno"), "??"))
+}
+
+func TestStatus_PorcelainV2Records(t *testing.T) {
+	currentRepository := createTestRepository(t)
+	writeTestFile(t, ".gitignore", "*.log\n")
+	writeTestFile(t, "old.txt", "some content that is renamed\n")
+	writeTestFile(t, "modified.txt", "modified\n")
+	commitTestChanges(t, "first", ".")
+	repositoryIndex, err := currentRepository.ReadIndex()
+	assert.Nil(t, err)
+	oldSha, modifiedSha := repositoryIndex.Entries["old.txt"].Sha, repositoryIndex.Entries["modified.txt"].Sha
+
+	assert.Nil(t, os.Rename("old.txt", "new.txt"))
+	Add([]string{"main.go", "add", "old.txt", "new.txt"})
+	writeTestFile(t, "modified.txt", "modified in the work tree\n")
+	writeTestFile(t, "untracked.txt", "untracked\n")
+	writeTestFile(t, "debug.log", "ignored\n")
+
+	assert.Equal(t, "1 .M N... 100644 100644 100644 "+modifiedSha+" "+modifiedSha+" modified.txt\n"+
+		"2 R. N... 100644 100644 100644 "+oldSha+" "+oldSha+" R100 new.txt\told.txt\n"+
+		"? untracked.txt\n"+
+		"! debug.log\n", getStatusOutput(t, "--porcelain=v2", "--ignored"))
+	assert.Equal(t, "1 .M N... 100644 100644 100644 "+modifiedSha+" "+modifiedSha+" modified.txt\n"+
+		"1 A. N... 000000 100644 100644 "+NULL_SHA+" "+oldSha+" new.txt\n"+
+		"1 D. N... 100644 000000 000000 "+oldSha+" "+NULL_SHA+" old.txt\n"+
+		"? untracked.txt\n", getStatusOutput(t, "--porcelain=v2", "--no-renames"))
+}
+
+func TestStatus_NullTerminated(t *testing.T) {
+	currentRepository := createTestRepository(t)
+	writeTestFile(t, "old.txt", "some content that is renamed\n")
+	commitTestChanges(t, "first", ".")
+	repositoryIndex, err := currentRepository.ReadIndex()
+	assert.Nil(t, err)
+	oldSha := repositoryIndex.Entries["old.txt"].Sha
+
+	assert.Nil(t, os.Rename("old.txt", "new.txt"))
+	Add([]string{"main.go", "add", "old.txt", "new.txt"})
+	writeTestFile(t, "tab\tname.txt", "untracked\n")
+
+	assert.Equal(t, "R  old.txt -> new.txt\n?? \"tab\\tname.txt\"\n", getStatusOutput(t, "--porcelain"))
+	assert.Equal(t, "R  new.txt\x00old.txt\x00?? tab\tname.txt\x00", getStatusOutput(t, "-z"))
+	assert.Equal(t, "2 R. N... 100644 100644 100644 "+oldSha+" "+oldSha+" R100 new.txt\x00old.txt\x00? tab\tname.txt\x00",
+		getStatusOutput(t, "--porcelain=v2", "-z"))
+}
+
+func TestStatus_QuotedPaths(t *testing.T) {
+	currentRepository := createTestRepository(t)
+	writeTestFile(t, "caf\u00e9.txt", "non ascii\n")
+	writeTestFile(t, "\x01control\x7f.txt", "control\n")
+	writeTestFile(t, "quote\"back\\slash\r.txt", "escaped\n")
+
+	assert.Equal(t, "?? \"\\001control\\177.txt\"\n?? \"caf\\303\\251.txt\"\n?? \"quote\\\"back\\\\slash\\r.txt\"\n",
+		getStatusOutput(t, "--porcelain"))
+	assert.Equal(t, "? \"\\001control\\177.txt\"\n? \"caf\\303\\251.txt\"\n? \"quote\\\"back\\\\slash\\r.txt\"\n",
+		getStatusOutput(t, "--porcelain=v2"))
+	assert.Equal(t, "?? \x01control\x7f.txt\x00?? caf\u00e9.txt\x00?? quote\"back\\slash\r.txt\x00", getStatusOutput(t, "-z"))
+
+	assert.Nil(t, currentRepository.Config.Set(config.LOCAL_SCOPE, "core.quotePath", "false"))
+	assert.Equal(t, "?? \"\\001control\\177.txt\"\n?? caf\u00e9.txt\n?? \"quote\\\"back\\\\slash\\r.txt\"\n",
+		getStatusOutput(t, "-s"))
+}
+
+func TestStatus_BranchHeaders(t *testing.T) {
+	currentRepository := createTestRepository(t)
+	writeTestFile(t, "file.txt", "first\n")
+	commitTestChanges(t, "first", "file.txt")
+	first := getTestHead(t)
+	writeTestFile(t, "file.txt", "second\n")
+	commitTestChanges(t, "second", "file.txt")
+	second := getTestHead(t)
+
+	assert.Equal(t, "## master\n", getStatusOutput(t, "-sb"))
+	assert.Nil(t, currentRepository.SetUpstream("master", "origin", "refs/heads/master"))
+	assert.Equal(t, "## master...origin/master [gone]\n", getStatusOutput(t, "-sb"))
+	assert.Equal(t, "# branch.oid "+second+"\n# branch.head master\n# branch.upstream origin/master\n", getStatusOutput(t, "--porcelain=v2", "-b"))
+
+	currentRepository.WriteRef(objects.Reference{NamePath: "remotes/origin/master", Value: first})
+	assert.Equal(t, "## master...origin/master [ahead 1]\n", getStatusOutput(t, "-sb"))
+	assert.Equal(t, "# branch.oid "+second+"\n# branch.head master\n# branch.upstream origin/master\n# branch.ab +1 -0\n",
+		getStatusOutput(t, "--porcelain=v2", "--branch"))
+
+	currentRepository.WriteRef(objects.Reference{NamePath: "remotes/origin/master", Value: second})
+	currentRepository.WriteRef(objects.Reference{NamePath: "heads/master", Value: first})
+	writeTestFile(t, "file.txt", "third\n")
+	commitTestChanges(t, "third", "file.txt")
+	assert.Equal(t, "## master...origin/master [ahead 1, behind 1]\n", getStatusOutput(t, "-sb"))
+	assert.Equal(t, "# branch.ab +1 -1\n", filterStatusLines(getStatusOutput(t, "--porcelain=v2", "-b"), "# branch.ab"))
+}
+
+func TestStatus_UnbornAndDetached(t *testing.T) {
+	createTestRepository(t)
+	writeTestFile(t, "file.txt", "file\n")
+
+	assert.Equal(t, "## No commits yet on master\n?? file.txt\n", getStatusOutput(t, "-s", "-b"))
+	assert.Equal(t, "# branch.oid (initial)\n# branch.head master\n? file.txt\n", getStatusOutput(t, "--porcelain=v2", "-b"))
+	Add([]string{"main.go", "add", "file.txt"})
+	assert.Equal(t, "## No commits yet on master\nA  file.txt\n", getStatusOutput(t, "-sb"))
+
+	Commit([]string{"main.go", "commit", "-m", "first"})
+	head := getTestHead(t)
+	writeTestFile(t, ".git/HEAD", head+"\n")
+	assert.Equal(t, "## HEAD (no branch)\n", getStatusOutput(t, "-sb"))
+	assert.Equal(t, "# branch.oid "+head+"\n# branch.head (detached)\n", getStatusOutput(t, "--porcelain=v2", "-b"))
+}
+
+func TestParseStatusOptions_CombinedShortOptions(t *testing.T) {
+	options := parseStatusOptions([]string{"-sb"}, diff.DefaultRenameOptions())
+	assert.Equal(t, "short", options.porcelain)
+	assert.True(t, options.showBranch)
+
+	options = parseStatusOptions([]string{"-sbzuno"}, diff.DefaultRenameOptions())
+	assert.Equal(t, "short", options.porcelain)
+	assert.True(t, options.showBranch)
+	assert.True(t, options.nullTerminated)
+	assert.Equal(t, UNTRACKED_FILES_NO, options.untrackedFiles)
+
+	options = parseStatusOptions([]string{"-z", "--porcelain=v2", "-buall"}, diff.DefaultRenameOptions())
+	assert.Equal(t, "v2", options.porcelain)
+	assert.True(t, options.showBranch)
+	assert.Equal(t, UNTRACKED_FILES_ALL, options.untrackedFiles)
+
+	assert.Equal(t, []string{"-s", "-b", "-unormal", "--short", "-z"}, expandStatusShortOptions([]string{"-sbunormal", "--short", "-z"}))
+}
+
+// Runs status with the args and returns what it prints
+func getStatusOutput(t *testing.T, args ...string) string {
+	return captureTestStdout(t, func() { Status(append([]string{"main.go", "status"}, args...)) })
+}
+
+func filterStatusLines(output string, prefix string) string {
+	filtered := ""
+	for _, line := range strings.SplitAfter(output, "\n") {
+		if strings.HasPrefix(line, prefix) {
+			filtered = filtered + line
+		}
+	}
+	return filtered
+}
+
+func getTestHead(t *testing.T) string {
+	currentRepository, _, err := repository.FindCurrentRepository(utils.CurrentPath())
+	assert.Nil(t, err)
+	head, err := currentRepository.ResolveRef("HEAD")
+	assert.Nil(t, err)
+	return head.Value
+}
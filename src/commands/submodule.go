@@ -0,0 +1,226 @@
+package commands
+
+import (
+	"fmt"
+	"git/src/config"
+	"git/src/index"
+	"git/src/repository"
+	"git/src/submodule"
+	"git/src/utils"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Submodule Manages repositories nested in the work tree. The commit of every submodule is stored as a gitlink entry (mode 160000)
+// Submodule Args: main.go submodule add <url> [path]
+// Submodule Args: main.go submodule init
+// Submodule Args: main.go submodule update [--init]
+// Submodule Args: main.go submodule status
+func Submodule(args []string) {
+	if len(args) < 3 {
+		utils.ExitError("Invalid arguments: submodule (add|init|update|status)")
+	}
+
+	currentRepository, _, err := repository.FindCurrentRepository(utils.CurrentPath())
+	utils.CheckError(err)
+	utils.CheckError(currentRepository.RequireWorkTree())
+
+	switch subcommand := args[2]; subcommand {
+	case "add":
+		submoduleAdd(currentRepository, args[3:])
+	case "init":
+		submoduleInit(currentRepository)
+	case "update":
+		if len(args) > 3 && args[3] == "--init" {
+			submoduleInit(currentRepository)
+		}
+		submoduleUpdate(currentRepository)
+	case "status":
+		submoduleStatus(currentRepository)
+	default:
+		utils.ExitError("Unknown submodule subcommand " + subcommand)
+	}
+}
+
+func submoduleAdd(currentRepository *repository.Repository, args []string) {
+	if len(args) < 1 || len(args) > 2 {
+		utils.ExitError("Invalid arguments: submodule add <url> [path]")
+	}
+
+	url := toAbsoluteUrl(utils.CurrentPath(), args[0])
+	path := strings.TrimSuffix(filepath.Base(url), ".git")
+	if len(args) == 2 {
+		path = currentRepository.AbsolutePathToRepositoryPath(currentRepository.GetPathFileInRepository(args[1]))
+	}
+
+	indexObject, err := currentRepository.ReadIndex()
+	utils.CheckError(err)
+	if _, alreadyInIndex := indexObject.Entries[path]; alreadyInIndex {
+		utils.ExitError("fatal: '" + path + "' already exists in the index")
+	}
+
+	submoduleRepository := cloneSubmodule(currentRepository, submodule.Submodule{Name: path, Path: path, Url: url})
+	head, err := submoduleRepository.ResolveRef("HEAD")
+	if err != nil {
+		utils.ExitError("fatal: You appear to have cloned an empty repository: " + url)
+	}
+	checkoutSubmodule(submoduleRepository, head.Value)
+
+	gitModules := readGitModules(currentRepository)
+	gitModules.Add(submodule.Submodule{Name: path, Path: path, Url: args[0]})
+	gitModulesPath := utils.Path(currentRepository.WorkTree, submodule.GIT_MODULES_FILE_NAME)
+	utils.CheckError(os.WriteFile(gitModulesPath, gitModules.Serialize(), 0666))
+
+	registerSubmoduleUrl(currentRepository, path, url)
+
+	indexObject.Entries[path] = index.CreateGitlinkIndexEntry(path, head.Value)
+	gitModulesStat, err := os.Stat(gitModulesPath)
+	utils.CheckError(err)
+	files := make([]fileToStage, 0)
+	addFile(indexObject, gitModulesPath, gitModulesStat, currentRepository, &files)
+	for _, changedPath := range stageFiles(currentRepository, indexObject, files) {
+		fmt.Println(changedPath)
+	}
+	utils.CheckError(currentRepository.WriteIndex(indexObject))
+}
+
+func submoduleInit(currentRepository *repository.Repository) {
+	for _, actualSubmodule := range readGitModules(currentRepository).Submodules {
+		if getSubmoduleUrl(currentRepository, actualSubmodule.Name) != "" {
+			continue
+		}
+
+		url := toAbsoluteUrl(currentRepository.WorkTree, actualSubmodule.Url)
+		registerSubmoduleUrl(currentRepository, actualSubmodule.Name, url)
+		fmt.Println("Submodule '" + actualSubmodule.Name + "' (" + url + ") registered for path '" + actualSubmodule.Path + "'")
+	}
+}
+
+// Only initialized submodules (with url in config) are updated to the commit recorded in the index
+func submoduleUpdate(currentRepository *repository.Repository) {
+	indexObject, err := currentRepository.ReadIndex()
+	utils.CheckError(err)
+
+	for _, actualSubmodule := range readGitModules(currentRepository).Submodules {
+		url := getSubmoduleUrl(currentRepository, actualSubmodule.Name)
+		entry, inIndex := indexObject.Entries[actualSubmodule.Path]
+		if url == "" || !inIndex || !entry.IsGitlink() {
+			continue
+		}
+
+		actualSubmodule.Url = url
+		submoduleRepository := cloneSubmodule(currentRepository, actualSubmodule)
+		if !submoduleRepository.HasObject(entry.Sha) {
+			source, err := repository.LoadRepository(url)
+			utils.CheckError(err)
+			utils.CheckError(submoduleRepository.FetchLocal(source, repository.DEFAULT_REMOTE_NAME))
+		}
+
+		checkoutSubmodule(submoduleRepository, entry.Sha)
+		utils.CheckError(submoduleRepository.WriteToHead(entry.Sha + "\n"))
+
+		fmt.Println("Submodule path '" + actualSubmodule.Path + "': checked out '" + entry.Sha + "'")
+	}
+}
+
+// Prefixes: "-" not checked out, "+" checked out commit differs from the recorded one, " " up to date
+func submoduleStatus(currentRepository *repository.Repository) {
+	indexObject, err := currentRepository.ReadIndex()
+	utils.CheckError(err)
+
+	for _, actualSubmodule := range readGitModules(currentRepository).Submodules {
+		entry, inIndex := indexObject.Entries[actualSubmodule.Path]
+		if !inIndex || !entry.IsGitlink() {
+			continue
+		}
+
+		checkedOutSha, checkedOut := getSubmoduleCheckedOutCommit(currentRepository, actualSubmodule.Path)
+		switch {
+		case !checkedOut:
+			fmt.Println("-" + entry.Sha + " " + actualSubmodule.Path)
+		case checkedOutSha != entry.Sha:
+			fmt.Println("+" + checkedOutSha + " " + actualSubmodule.Path)
+		default:
+			fmt.Println(" " + entry.Sha + " " + actualSubmodule.Path)
+		}
+	}
+}
+
+// Clones the submodule if its git dir (<git dir>/modules/<name>) doesnt exist yet
+func cloneSubmodule(currentRepository *repository.Repository, actualSubmodule submodule.Submodule) *repository.Repository {
+	submoduleGitDir := utils.Paths(currentRepository.CommonDir, "modules", actualSubmodule.Name)
+	submoduleWorkTree := utils.Path(currentRepository.WorkTree, actualSubmodule.Path)
+
+	if utils.CheckFileOrDirExists(submoduleGitDir) {
+		if !utils.CheckFileOrDirExists(utils.Path(submoduleWorkTree, ".git")) {
+			utils.CheckError(os.MkdirAll(submoduleWorkTree, os.ModePerm))
+			utils.CheckError(os.WriteFile(utils.Path(submoduleWorkTree, ".git"), []byte("gitdir: "+submoduleGitDir+"\n"), 0666))
+		}
+
+		submoduleRepository, err := repository.LoadRepository(submoduleWorkTree)
+		utils.CheckError(err)
+		return submoduleRepository
+	}
+
+	submoduleRepository, err := repository.CloneLocalRepository(actualSubmodule.Url, submoduleWorkTree, submoduleGitDir)
+	utils.CheckError(err)
+
+	return submoduleRepository
+}
+
+func checkoutSubmodule(submoduleRepository *repository.Repository, commitSha string) {
+	commitObject := getCommitObject(submoduleRepository, commitSha)
+	checkoutTree(submoduleRepository, commitObject.Tree, submoduleRepository.WorkTree)
+}
+
+// getSubmoduleCheckedOutCommit Returns false if the submodule is not checked out
+func getSubmoduleCheckedOutCommit(currentRepository *repository.Repository, path string) (string, bool) {
+	submoduleWorkTree := utils.Path(currentRepository.WorkTree, path)
+	if !utils.CheckFileOrDirExists(utils.Path(submoduleWorkTree, ".git")) {
+		return "", false
+	}
+
+	submoduleRepository, err := repository.LoadRepository(submoduleWorkTree)
+	if err != nil {
+		return "", false
+	}
+	head, err := submoduleRepository.ResolveRef("HEAD")
+	if err != nil {
+		return "", false
+	}
+
+	return head.Value, true
+}
+
+func readGitModules(currentRepository *repository.Repository) submodule.GitModules {
+	file, err := os.Open(utils.Path(currentRepository.WorkTree, submodule.GIT_MODULES_FILE_NAME))
+	if err != nil {
+		return submodule.GitModules{}
+	}
+	defer file.Close()
+
+	gitModules, err := submodule.Deserialize(file)
+	if err != nil {
+		utils.ExitError("Invalid .gitmodules file: " + err.Error())
+	}
+
+	return gitModules
+}
+
+func getSubmoduleUrl(currentRepository *repository.Repository, name string) string {
+	return currentRepository.Config.String("submodule." + name + ".url")
+}
+
+func registerSubmoduleUrl(currentRepository *repository.Repository, name string, url string) {
+	utils.CheckError(currentRepository.Config.Set(config.LOCAL_SCOPE, "submodule."+name+".url", url))
+}
+
+// Only local repositories are supported, so urls are paths
+func toAbsoluteUrl(basePath string, url string) string {
+	if filepath.IsAbs(url) {
+		return url
+	}
+
+	return utils.Path(basePath, url)
+}
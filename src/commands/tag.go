@@ -5,74 +5,272 @@ import (
 	"git/src/objects"
 	"git/src/repository"
 	"git/src/utils"
+	"os"
+	"sort"
+	"strconv"
 	"strings"
 )
 
-// Tag List tags: main.go tag
-// Tag Create tag object: main.go tag -a <name> [object default: HEAD]
-func Tag(args []string) {
-	if len(args) < 2 {
-		utils.ExitError("Invalid arguments: tag [-a] [NANE]")
-	}
+type tagOptions struct {
+	list       bool
+	delete     bool
+	annotate   bool
+	force      bool
+	sign       bool
+	signingKey string //Overrides user.signingKey
+	messages   []string
+	lines      int //Lines of the message shown when listing (-n). 0 to hide the message
+	contains   string
+	arguments  []string
+}
 
+// Tag List tags: main.go tag [-l | --list] [-n[<lines>]] [--contains <commit>] [<pattern>...]
+// Tag Create lightweight tag: main.go tag [-f] <name> [<object> default: HEAD]
+// Tag Create annotated tag: main.go tag [-f] (-a | -s | -u <key> | -m <message>) [-m <message>]... <name> [<object>]
+// Tag Signed tags (-s, -u or tag.gpgSign) are signed with ssh keys
+// Tag Delete tags: main.go tag -d <name>...
+func Tag(args []string) {
 	currentRepository, _, err := repository.FindCurrentRepository(utils.CurrentPath())
 	if err != nil {
 		utils.ExitError(err.Error())
 	}
 
-	if len(args) == 2 { //main.go tag -> List all tags
-		listTags(currentRepository)
-	} else {
-		createTagObject := args[2] == "-a"
-		tagName := args[3]
-		object := extractObjectFromArgs(args)
+	options := parseTagOptions(args[2:], currentRepository.SignByDefault("tag"))
 
-		createTag(currentRepository, tagName, object, createTagObject)
+	switch {
+	case options.delete:
+		deleteTags(currentRepository, options.arguments)
+	case options.list || len(options.arguments) == 0:
+		listTags(currentRepository, options)
+	default:
+		object := "HEAD"
+		if len(options.arguments) > 2 {
+			utils.ExitError("Invalid arguments: tag [-a] [-m <message>] <name> [<object>]")
+		} else if len(options.arguments) == 2 {
+			object = options.arguments[1]
+		}
+		createTag(currentRepository, options.arguments[0], object, options)
 	}
 }
 
-func createTag(repository *repository.Repository, name string, refValue string, createTagObject bool) {
-	resolvedHashRefValue, _, err := repository.ResolveObjectName(refValue, objects.ANY)
+func parseTagOptions(args []string, sign bool) tagOptions {
+	options := tagOptions{arguments: make([]string, 0), messages: make([]string, 0), sign: sign}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		switch {
+		case arg == "-l" || arg == "--list":
+			options.list = true
+		case arg == "-d" || arg == "--delete":
+			options.delete = true
+		case arg == "-a" || arg == "--annotate":
+			options.annotate = true
+		case arg == "-f" || arg == "--force":
+			options.force = true
+		case arg == "-s" || arg == "--sign":
+			options.sign, options.annotate = true, true
+		case arg == "--no-sign":
+			options.sign = false
+		case matchesOption(arg, "-u") || matchesOption(arg, "--local-user"):
+			options.signingKey = getOptionValue(args, &i, arg)
+			options.sign, options.annotate = true, true
+		case matchesOption(arg, "-m") || matchesOption(arg, "--message"):
+			options.messages = append(options.messages, getOptionValue(args, &i, arg))
+			options.annotate = true
+		case strings.HasPrefix(arg, "-m"):
+			options.messages = append(options.messages, arg[2:])
+			options.annotate = true
+		case matchesOption(arg, "--contains"):
+			options.contains = getOptionValue(args, &i, arg)
+			options.list = true
+		case arg == "-n":
+			options.lines, options.list = 1, true
+		case strings.HasPrefix(arg, "-n"):
+			lines, err := strconv.Atoi(arg[2:])
+			if err != nil {
+				utils.ExitError("Invalid number of lines: " + arg[2:])
+			}
+			options.lines, options.list = lines, true
+		case strings.HasPrefix(arg, "-"):
+			utils.ExitError("Unknown option " + arg + " for tag")
+		default:
+			options.arguments = append(options.arguments, arg)
+		}
+	}
+
+	return options
+}
+
+func createTag(currentRepository *repository.Repository, name string, refValue string, options tagOptions) {
+	if !isValidTagName(name) {
+		utils.ExitError("fatal: '" + name + "' is not a valid tag name.")
+	}
+
+	resolvedHashRefValue, _, err := currentRepository.ResolveObjectName(refValue, objects.ANY)
 	if err != nil {
-		utils.ExitError(err.Error())
+		utils.ExitError("fatal: Failed to resolve '" + refValue + "' as a valid ref.")
 	}
 
-	tagNamePath := utils.Path("tags", name)
+	refName := "refs/tags/" + name
+	oldValue := objects.NO_PARENT_COMMIT_SHA
+	if ref, err := currentRepository.ResolveRef(refName); err == nil {
+		if !options.force {
+			utils.ExitError("fatal: tag '" + name + "' already exists")
+		}
+		oldValue = ref.Value
+	}
 
-	if createTagObject {
-		tagObject := objects.CreateTagObject(resolvedHashRefValue, tagNamePath, "Jaime Polidura <jaime.polidura@gmail.com>")
+	newValue := resolvedHashRefValue
+	if options.annotate {
+		if len(options.messages) == 0 {
+			utils.ExitError("fatal: no tag message given, use -m <message>")
+		}
 
-		if shaObjectTagWritten, err := repository.WriteObject(tagObject); err == nil {
-			repository.WriteRef(objects.Reference{NamePath: tagNamePath, Value: shaObjectTagWritten})
-		} else {
+		taggedObject, err := currentRepository.ReadObject(resolvedHashRefValue, objects.ANY)
+		utils.CheckError(err)
+		tagger := currentRepository.GetIdentity(repository.COMMITTER_ROLE)
+		message := strings.Join(options.messages, "\n\n") + "\n"
+
+		tagObject := objects.CreateTagObject(resolvedHashRefValue, taggedObject.Type, name, tagger.String(), message)
+		if options.sign {
+			signer, err := currentRepository.GetSigningKey(options.signingKey)
+			utils.CheckError(err)
+			tagObject, err = repository.SignTag(tagObject, signer)
+			utils.CheckError(err)
+		}
+
+		newValue, err = currentRepository.WriteObject(tagObject)
+		if err != nil {
 			utils.ExitError("Cannot create tag: " + err.Error())
 		}
-	} else {
-		repository.WriteRef(objects.Reference{NamePath: tagNamePath, Value: resolvedHashRefValue})
+	}
+
+	err = currentRepository.UpdateRefsAtomically([]repository.RefUpdate{{Name: refName, OldValue: oldValue, NewValue: newValue}})
+	utils.CheckError(err)
+	if oldValue != objects.NO_PARENT_COMMIT_SHA && oldValue != newValue {
+		fmt.Println("Updated tag '" + name + "' (was " + abbreviateSha(oldValue) + ")")
+	}
+}
+
+func isValidTagName(name string) bool {
+	return name != "" && !strings.HasPrefix(name, "-") && !strings.HasSuffix(name, ".lock") && !strings.HasSuffix(name, "/") &&
+		!strings.Contains(name, "..") && !strings.ContainsAny(name, " ~^:?*[\\")
+}
+
+func deleteTags(currentRepository *repository.Repository, names []string) {
+	if len(names) == 0 {
+		utils.ExitError("Invalid arguments: tag -d <name>...")
+	}
+
+	failed := false
+	for _, name := range names {
+		ref, err := currentRepository.ResolveRef("refs/tags/" + name)
+		if err != nil {
+			fmt.Println("error: tag '" + name + "' not found.")
+			failed = true
+			continue
+		}
+
+		err = currentRepository.UpdateRefsAtomically([]repository.RefUpdate{{Name: "refs/tags/" + name, OldValue: ref.Value, NewValue: objects.NO_PARENT_COMMIT_SHA}})
+		utils.CheckError(err)
+		fmt.Println("Deleted tag '" + name + "' (was " + abbreviateSha(ref.Value) + ")")
+	}
+
+	if failed {
+		os.Exit(1)
 	}
 }
 
-func listTags(repository *repository.Repository) {
-	tags, err := repository.GetAllRefs()
+// Tags are sorted by name. Patterns are globs matched against the name
+func listTags(currentRepository *repository.Repository, options tagOptions) {
+	refs, err := currentRepository.GetAllRefs()
 	if err != nil {
 		utils.ExitError("Cannot get references: " + err.Error())
 	}
-	if len(tags) == 0 {
-		fmt.Println("Not tags found for this repository")
-		return
+
+	containedReachable := make(map[string]map[string]bool) //Cache tag sha -> commits reachable from it
+	containedSha := ""
+	if options.contains != "" {
+		containedSha, _, err = currentRepository.ResolveObjectName(options.contains, objects.COMMIT)
+		if err != nil {
+			utils.ExitError("error: malformed object name " + options.contains)
+		}
+	}
+
+	names := make([]string, 0)
+	for refPath, _ := range refs {
+		if strings.HasPrefix(refPath, "refs/tags/") {
+			names = append(names, strings.TrimPrefix(refPath, "refs/tags/"))
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		value := refs["refs/tags/"+name].Value
+		if !matchesTagPatterns(name, options.arguments) {
+			continue
+		}
+		if containedSha != "" && !tagContainsCommit(currentRepository, value, containedSha, containedReachable) {
+			continue
+		}
+
+		if options.lines == 0 {
+			fmt.Println(name)
+		} else {
+			printTagWithMessage(currentRepository, name, value, options.lines)
+		}
 	}
+}
 
-	for refPath, refValue := range tags {
-		if strings.Contains(refPath, "tags") {
-			fmt.Println(refValue.NamePath + " => " + refValue.Value)
+// Like git, "*" also matches "/" in tag names
+func matchesTagPatterns(name string, patterns []string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if utils.WildMatchText(pattern, name, false) {
+			return true
 		}
 	}
+	return false
 }
 
-func extractObjectFromArgs(args []string) string {
-	if len(args) == 5 {
-		return args[4]
-	} else {
-		return "HEAD"
+func tagContainsCommit(currentRepository *repository.Repository, tagValue string, commitSha string, reachableCache map[string]map[string]bool) bool {
+	tagCommit, _, err := currentRepository.ResolveObjectName(tagValue, objects.COMMIT)
+	if err != nil { //Tags of trees or blobs
+		return false
+	}
+
+	if _, cached := reachableCache[tagCommit]; !cached {
+		reachable, err := currentRepository.GetReachableCommits(tagCommit)
+		utils.CheckError(err)
+		reachableCache[tagCommit] = reachable
+	}
+
+	return reachableCache[tagCommit][commitSha]
+}
+
+// Format: <name padded to 15> <first lines of the message>. Lightweight tags show the message of the commit
+func printTagWithMessage(currentRepository *repository.Repository, name string, value string, lines int) {
+	object, err := currentRepository.ReadObject(value, objects.ANY)
+	utils.CheckError(err)
+
+	message := ""
+	switch object.Type {
+	case objects.TAG:
+		message = object.SerializableGitObject.(objects.TagObject).Message
+	case objects.COMMIT:
+		message = object.SerializableGitObject.(objects.CommitObject).Message
+	}
+
+	messageLines := strings.Split(strings.Trim(message, "\n"), "\n")
+	if len(messageLines) > lines {
+		messageLines = messageLines[:lines]
+	}
+
+	fmt.Println(strings.TrimRight(fmt.Sprintf("%-15s %s", name, messageLines[0]), " "))
+	for _, line := range messageLines[1:] {
+		fmt.Println("    " + line)
 	}
 }
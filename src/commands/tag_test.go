@@ -0,0 +1,27 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTag_MatchesTagPatterns(t *testing.T) {
+	assert.True(t, matchesTagPatterns("release/1.0", []string{}))
+	assert.True(t, matchesTagPatterns("release/1.0", []string{"release*"}))
+	assert.True(t, matchesTagPatterns("release/1.0", []string{"v*", "*/1.?"}))
+	assert.True(t, matchesTagPatterns("release-2", []string{"release-[0-9]"}))
+	assert.False(t, matchesTagPatterns("release/1.0", []string{"v*", "release"}))
+	assert.False(t, matchesTagPatterns("v1.0", []string{"release*"}))
+}
+
+func TestTag_ListWithSlashInName(t *testing.T) {
+	createTestRepository(t)
+	writeTestFile(t, "file.txt", "file\n")
+	commitTestChanges(t, "first", "file.txt")
+	Tag([]string{"main.go", "tag", "release/1.0"})
+	Tag([]string{"main.go", "tag", "release-2.0"})
+	Tag([]string{"main.go", "tag", "v1.0"})
+
+	assert.Equal(t, "release-2.0\nrelease/1.0\n", captureTestStdout(t, func() { Tag([]string{"main.go", "tag", "-l", "release*"}) }))
+}
@@ -0,0 +1,87 @@
+package commands
+
+import (
+	"fmt"
+	"git/src/objects"
+	"git/src/repository"
+	"git/src/utils"
+	"os"
+	"time"
+)
+
+// VerifyCommit Checks the ssh signature of the commits against gpg.ssh.allowedSignersFile. Exits with 1 if any signature is not good
+// VerifyCommit Args: main.go verify-commit [-v | --verbose] <commit>...
+func VerifyCommit(args []string) {
+	verifyObjects(args, objects.COMMIT, "verify-commit")
+}
+
+// VerifyTag Checks the ssh signature of the tags against gpg.ssh.allowedSignersFile. Exits with 1 if any signature is not good
+// VerifyTag Args: main.go verify-tag [-v | --verbose] <tag>...
+func VerifyTag(args []string) {
+	verifyObjects(args, objects.TAG, "verify-tag")
+}
+
+func verifyObjects(args []string, objectType objects.ObjectType, command string) {
+	currentRepository, _, err := repository.FindCurrentRepository(utils.CurrentPath())
+	utils.CheckError(err)
+
+	verbose := false
+	names := make([]string, 0)
+	for _, arg := range args[2:] {
+		if arg == "-v" || arg == "--verbose" {
+			verbose = true
+		} else {
+			names = append(names, arg)
+		}
+	}
+	if len(names) == 0 {
+		utils.ExitError("Invalid arguments: " + command + " [-v] <object>...")
+	}
+
+	allGood := true
+	for _, name := range names {
+		if !verifyObject(currentRepository, name, objectType, verbose) {
+			allGood = false
+		}
+	}
+	if !allGood {
+		os.Exit(1)
+	}
+}
+
+// The verification is printed to stderr, like git does with the output of ssh-keygen
+func verifyObject(currentRepository *repository.Repository, name string, objectType objects.ObjectType, verbose bool) bool {
+	sha, _, err := currentRepository.ResolveObjectName(name, objectType)
+	if err != nil {
+		utils.ExitError("error: " + name + ": cannot verify a non-" + string(objectType) + " object")
+	}
+	object, err := currentRepository.ReadObject(sha, objectType)
+	utils.CheckError(err)
+
+	if verbose {
+		fmt.Print(string(object.SerializableGitObject.Serialize()))
+	}
+
+	payload, signature, signatureTime := getSignedPayload(object)
+	if signature == "" {
+		fmt.Fprintln(os.Stderr, "error: "+name+": no signature found")
+		return false
+	}
+
+	verification := currentRepository.VerifySignature(payload, signature, signatureTime)
+	fmt.Fprintln(os.Stderr, verification.String())
+	return verification.Good
+}
+
+// Returns an empty signature if the object is not signed. The time of the signature is the committer or tagger date
+func getSignedPayload(object objects.Object) ([]byte, string, time.Time) {
+	switch gitObject := object.SerializableGitObject.(type) {
+	case objects.CommitObject:
+		payload, signature := gitObject.SignedPayload()
+		return payload, signature, gitObject.CommitterSignature().When
+	case objects.TagObject:
+		payload, signature := gitObject.SignedPayload()
+		return payload, signature, gitObject.TaggerSignature().When
+	}
+	return nil, "", time.Time{}
+}
@@ -0,0 +1,183 @@
+package commands
+
+import (
+	"fmt"
+	"git/src/index"
+	"git/src/objects"
+	"git/src/repository"
+	"git/src/utils"
+	"os"
+	"path/filepath"
+)
+
+// Worktree Manages multiple work trees sharing the same repository
+// Worktree Args: main.go worktree add [-b <new branch>] <path> [commit-ish default: new branch named as path]
+// Worktree Args: main.go worktree list
+// Worktree Args: main.go worktree remove [--force] <path>
+// Worktree Args: main.go worktree prune
+func Worktree(args []string) {
+	if len(args) < 3 {
+		utils.ExitError("Invalid arguments: worktree (add|list|remove|prune)")
+	}
+
+	currentRepository, _, err := repository.FindCurrentRepository(utils.CurrentPath())
+	utils.CheckError(err)
+
+	switch subcommand := args[2]; subcommand {
+	case "add":
+		worktreeAdd(currentRepository, args[3:])
+	case "list":
+		worktreeList(currentRepository)
+	case "remove":
+		worktreeRemove(currentRepository, args[3:])
+	case "prune":
+		worktreePrune(currentRepository)
+	default:
+		utils.ExitError("Unknown worktree subcommand " + subcommand)
+	}
+}
+
+func worktreeAdd(currentRepository *repository.Repository, args []string) {
+	newBranch := ""
+	if len(args) >= 2 && args[0] == "-b" {
+		newBranch = args[1]
+		args = args[2:]
+	}
+	if len(args) < 1 || len(args) > 2 {
+		utils.ExitError("Invalid arguments: worktree add [-b <new branch>] <path> [commit-ish]")
+	}
+
+	path := args[0]
+	commitIsh := "HEAD"
+	if len(args) == 2 {
+		commitIsh = args[1]
+	} else if newBranch == "" {
+		newBranch = filepath.Base(path)
+	}
+
+	commitSha, isBranch, err := currentRepository.ResolveObjectName(commitIsh, objects.COMMIT)
+	utils.CheckError(err)
+
+	head := commitSha
+	if newBranch != "" {
+		if _, err := currentRepository.ResolveRef("refs/heads/" + newBranch); err == nil {
+			utils.ExitError("fatal: a branch named '" + newBranch + "' already exists")
+		}
+		head = "ref: refs/heads/" + newBranch
+	} else if isBranch && commitIsh != "HEAD" {
+		if worktree, checkedOut := currentRepository.FindWorktreeWithBranch(commitIsh); checkedOut {
+			utils.ExitError("fatal: '" + commitIsh + "' is already checked out at '" + worktree.Path + "'")
+		}
+		head = "ref: refs/heads/" + commitIsh
+	}
+
+	worktreeRepository, err := currentRepository.AddWorktree(path, head)
+	utils.CheckError(err)
+
+	if newBranch != "" {
+		utils.CheckError(worktreeRepository.UpdateRefsAtomically([]repository.RefUpdate{
+			{Name: "refs/heads/" + newBranch, OldValue: objects.NO_PARENT_COMMIT_SHA, NewValue: commitSha},
+		}))
+	}
+
+	commitObject := getCommitObject(worktreeRepository, commitSha)
+	checkoutTree(worktreeRepository, commitObject.Tree, worktreeRepository.WorkTree)
+
+	fmt.Println("Preparing worktree " + worktreeRepository.WorkTree + " (HEAD is now at " + commitSha[:7] + ")")
+	runPostCheckoutHook(worktreeRepository, objects.NO_PARENT_COMMIT_SHA, commitSha)
+}
+
+func worktreeList(currentRepository *repository.Repository) {
+	worktrees, err := currentRepository.ListWorktrees()
+	utils.CheckError(err)
+
+	for _, worktree := range worktrees {
+		if worktree.Bare {
+			fmt.Println(worktree.Path + " (bare)")
+			continue
+		}
+
+		sha := "0000000"
+		if worktreeRepository, err := repository.LoadRepository(worktree.Path); err == nil {
+			if head, err := worktreeRepository.ResolveRef("HEAD"); err == nil {
+				sha = head.Value[:7]
+			}
+		}
+
+		description := "(detached HEAD)"
+		if branch, isBranch := worktree.GetBranch(); isBranch {
+			description = "[" + branch + "]"
+		}
+		if worktree.Prunable {
+			description = description + " prunable"
+		}
+
+		fmt.Println(worktree.Path + " " + sha + " " + description)
+	}
+}
+
+func worktreeRemove(currentRepository *repository.Repository, args []string) {
+	force := false
+	if len(args) >= 1 && args[0] == "--force" {
+		force = true
+		args = args[1:]
+	}
+	if len(args) != 1 {
+		utils.ExitError("Invalid arguments: worktree remove [--force] <path>")
+	}
+
+	worktree, err := currentRepository.FindWorktreeByPath(args[0])
+	utils.CheckError(err)
+
+	if !force && !worktree.Prunable {
+		worktreeRepository, err := repository.LoadRepository(worktree.Path)
+		utils.CheckError(err)
+		if hasLocalChanges(worktreeRepository) {
+			utils.ExitError("fatal: '" + args[0] + "' contains modified or untracked files, use --force to delete it")
+		}
+	}
+
+	utils.CheckError(currentRepository.RemoveWorktree(worktree))
+}
+
+// Files not present in the index or whose size or modification time doesnt match the index
+func hasLocalChanges(worktreeRepository *repository.Repository) bool {
+	indexObject, err := worktreeRepository.ReadIndex()
+	utils.CheckError(err)
+
+	filesInWorkTree := utils.GetAllSubfiles(worktreeRepository.WorkTree)
+	delete(filesInWorkTree, ".git")
+
+	for pathInRepository, _ := range filesInWorkTree {
+		entry, tracked := indexObject.Entries[pathInRepository]
+		if !tracked || (!entry.SkipWorktree && isModified(worktreeRepository, entry)) {
+			return true
+		}
+	}
+
+	checkedOutEntries := 0
+	for _, entry := range indexObject.Entries {
+		if !entry.SkipWorktree {
+			checkedOutEntries++
+		}
+	}
+	return len(filesInWorkTree) != checkedOutEntries
+}
+
+func isModified(currentRepository *repository.Repository, entry index.IndexEntry) bool {
+	stat, err := os.Stat(utils.Path(currentRepository.WorkTree, entry.FullPathName))
+	if err != nil {
+		return true
+	}
+
+	return stat.Size() != int64(entry.Fsize) || stat.ModTime().UnixNano() > int64(entry.Mtime)
+}
+
+func worktreePrune(currentRepository *repository.Repository) {
+	pruned, err := currentRepository.PruneWorktrees()
+	utils.CheckError(err)
+
+	for _, name := range pruned {
+		fmt.Println("Removing worktrees/" + name + ": gitdir file points to non-existent location")
+	}
+}
@@ -0,0 +1,347 @@
+package commitgraph
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"strconv"
+)
+
+// Commit graph format (same as git, version 1):
+// "CGPH" <version byte> <hash version byte> <number of chunks byte> <number of base graphs byte>
+// <chunk table: (number of chunks + 1) * (<chunk id 4 bytes> <offset uint64>), the last id is 0 and its offset is the end>
+// <chunks> <sha1 of all previous bytes>
+//
+// OIDF: 256 uint32, number of commits whose first byte of sha is <= i
+// OIDL: sorted shas of the commits, 20 bytes each
+// CDAT: for every commit: <tree sha 20 bytes> <first parent position uint32> <second parent position uint32>
+// <generation (30 bits) and commit time (34 bits) uint64>
+// EDGE: parents of octopus merges after the first one. Their second parent is the index of the first edge with the
+// EXTRA_EDGES_NEEDED bit. The last edge of each merge has the LAST_EDGE bit
+// Unknown chunks (Ex: generation data or bloom filters written by git) are ignored
+
+const (
+	SIGNATURE         = "CGPH"
+	VERSION           = 1
+	HASH_VERSION_SHA1 = 1
+
+	OID_FANOUT_CHUNK  = "OIDF"
+	OID_LOOKUP_CHUNK  = "OIDL"
+	COMMIT_DATA_CHUNK = "CDAT"
+	EXTRA_EDGE_CHUNK  = "EDGE"
+
+	NO_PARENT          = 0x70000000
+	EXTRA_EDGES_NEEDED = 0x80000000
+	LAST_EDGE          = 0x80000000
+
+	// GENERATION_NUMBER_MAX Generations bigger than this are stored as this value
+	GENERATION_NUMBER_MAX = 0x3FFFFFFF
+)
+
+const (
+	headerSize         = 8
+	chunkTableItemSize = 12
+	shaSize            = 20
+	commitDataSize     = shaSize + 16
+)
+
+// Commit Data of a commit stored in the graph. Generation is the topological level: 1 for root commits, otherwise one more
+// than the biggest generation of the parents. A commit cannot reach commits with the same or a bigger generation
+type Commit struct {
+	Sha        string
+	Tree       string
+	Parents    []string
+	Generation uint32
+	CommitTime int64 //Unix timestamp of the committer date
+}
+
+type chunk struct {
+	id   string
+	data []byte
+}
+
+type CommitGraph struct {
+	fanout        []byte
+	lookup        []byte //Sorted shas, 20 bytes each. They are encoded when needed, so opening a big graph is cheap
+	numberCommits int
+	commitData    []byte
+	extraEdges    []byte
+}
+
+// Serialize Every parent must be included in commits, so the graph is closed under reachability
+func Serialize(commits []Commit) ([]byte, error) {
+	sorted := append(make([]Commit, 0, len(commits)), commits...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Sha < sorted[j].Sha })
+	positions := make(map[string]uint32, len(sorted))
+	for i, commit := range sorted {
+		if _, duplicated := positions[commit.Sha]; duplicated {
+			return nil, errors.New("duplicated commit " + commit.Sha + " in commit graph")
+		}
+		positions[commit.Sha] = uint32(i)
+	}
+
+	lookup := make([]byte, 0, len(sorted)*shaSize)
+	commitData := make([]byte, 0, len(sorted)*commitDataSize)
+	extraEdges := make([]byte, 0)
+	var firstByteCounts [256]uint32
+	for _, commit := range sorted {
+		sha, err := decodeSha(commit.Sha)
+		if err != nil {
+			return nil, err
+		}
+		tree, err := decodeSha(commit.Tree)
+		if err != nil {
+			return nil, err
+		}
+		parentPositions := make([]uint32, 0, len(commit.Parents))
+		for _, parent := range commit.Parents {
+			position, found := positions[parent]
+			if !found {
+				return nil, errors.New("parent " + parent + " of commit " + commit.Sha + " is not in the commit graph")
+			}
+			parentPositions = append(parentPositions, position)
+		}
+
+		firstByteCounts[sha[0]]++
+		lookup = append(lookup, sha...)
+		commitData = append(commitData, tree...)
+		switch len(parentPositions) {
+		case 0:
+			commitData = binary.BigEndian.AppendUint32(commitData, NO_PARENT)
+			commitData = binary.BigEndian.AppendUint32(commitData, NO_PARENT)
+		case 1:
+			commitData = binary.BigEndian.AppendUint32(commitData, parentPositions[0])
+			commitData = binary.BigEndian.AppendUint32(commitData, NO_PARENT)
+		case 2:
+			commitData = binary.BigEndian.AppendUint32(commitData, parentPositions[0])
+			commitData = binary.BigEndian.AppendUint32(commitData, parentPositions[1])
+		default:
+			commitData = binary.BigEndian.AppendUint32(commitData, parentPositions[0])
+			commitData = binary.BigEndian.AppendUint32(commitData, EXTRA_EDGES_NEEDED|uint32(len(extraEdges)/4))
+			for j, position := range parentPositions[1:] {
+				if j == len(parentPositions)-2 {
+					position |= LAST_EDGE
+				}
+				extraEdges = binary.BigEndian.AppendUint32(extraEdges, position)
+			}
+		}
+
+		generation := commit.Generation
+		if generation > GENERATION_NUMBER_MAX {
+			generation = GENERATION_NUMBER_MAX
+		}
+		commitTime := uint64(commit.CommitTime) & (1<<34 - 1)
+		commitData = binary.BigEndian.AppendUint32(commitData, generation<<2|uint32(commitTime>>32))
+		commitData = binary.BigEndian.AppendUint32(commitData, uint32(commitTime))
+	}
+
+	fanout := make([]byte, 0, 256*4)
+	count := uint32(0)
+	for _, firstByteCount := range firstByteCounts {
+		count += firstByteCount
+		fanout = binary.BigEndian.AppendUint32(fanout, count)
+	}
+
+	chunks := []chunk{{OID_FANOUT_CHUNK, fanout}, {OID_LOOKUP_CHUNK, lookup}, {COMMIT_DATA_CHUNK, commitData}}
+	if len(extraEdges) > 0 {
+		chunks = append(chunks, chunk{EXTRA_EDGE_CHUNK, extraEdges})
+	}
+
+	var buffer bytes.Buffer
+	buffer.WriteString(SIGNATURE)
+	buffer.Write([]byte{VERSION, HASH_VERSION_SHA1, byte(len(chunks)), 0})
+	offset := uint64(headerSize + (len(chunks)+1)*chunkTableItemSize)
+	for _, item := range chunks {
+		buffer.WriteString(item.id)
+		buffer.Write(binary.BigEndian.AppendUint64(nil, offset))
+		offset += uint64(len(item.data))
+	}
+	buffer.Write([]byte{0, 0, 0, 0})
+	buffer.Write(binary.BigEndian.AppendUint64(nil, offset))
+	for _, item := range chunks {
+		buffer.Write(item.data)
+	}
+
+	checksum := sha1.Sum(buffer.Bytes())
+	buffer.Write(checksum[:])
+	return buffer.Bytes(), nil
+}
+
+// Deserialize The checksum is verified
+func Deserialize(content []byte) (*CommitGraph, error) {
+	if len(content) < headerSize+chunkTableItemSize+shaSize || string(content[:4]) != SIGNATURE {
+		return nil, errors.New("commit-graph signature does not match")
+	}
+	if content[4] != VERSION {
+		return nil, errors.New("commit-graph version " + strconv.Itoa(int(content[4])) + " is not supported")
+	}
+	if content[5] != HASH_VERSION_SHA1 {
+		return nil, errors.New("commit-graph hash version " + strconv.Itoa(int(content[5])) + " is not supported")
+	}
+	if content[7] != 0 {
+		return nil, errors.New("split commit-graphs are not supported")
+	}
+	checksum := sha1.Sum(content[:len(content)-shaSize])
+	if !bytes.Equal(checksum[:], content[len(content)-shaSize:]) {
+		return nil, errors.New("commit-graph checksum does not match")
+	}
+
+	chunks, err := readChunkTable(content, int(content[6]))
+	if err != nil {
+		return nil, err
+	}
+	for _, required := range []string{OID_FANOUT_CHUNK, OID_LOOKUP_CHUNK, COMMIT_DATA_CHUNK} {
+		if _, found := chunks[required]; !found {
+			return nil, errors.New("commit-graph is missing the " + required + " chunk")
+		}
+	}
+
+	lookup := chunks[OID_LOOKUP_CHUNK]
+	if len(chunks[OID_FANOUT_CHUNK]) != 256*4 {
+		return nil, errors.New("commit-graph fanout has an invalid size")
+	}
+	numberCommits := int(binary.BigEndian.Uint32(chunks[OID_FANOUT_CHUNK][255*4:]))
+	if len(lookup) != numberCommits*shaSize || len(chunks[COMMIT_DATA_CHUNK]) != numberCommits*commitDataSize {
+		return nil, errors.New("commit-graph chunks have invalid sizes")
+	}
+
+	for i := 1; i < 256; i++ {
+		if binary.BigEndian.Uint32(chunks[OID_FANOUT_CHUNK][(i-1)*4:]) > binary.BigEndian.Uint32(chunks[OID_FANOUT_CHUNK][i*4:]) {
+			return nil, errors.New("commit-graph fanout is not sorted")
+		}
+	}
+
+	return &CommitGraph{fanout: chunks[OID_FANOUT_CHUNK], lookup: lookup, numberCommits: numberCommits,
+		commitData: chunks[COMMIT_DATA_CHUNK], extraEdges: chunks[EXTRA_EDGE_CHUNK]}, nil
+}
+
+// Chunk id -> content
+func readChunkTable(content []byte, numberChunks int) (map[string][]byte, error) {
+	chunks := make(map[string][]byte)
+	end := uint64(len(content) - shaSize)
+	if len(content) < headerSize+(numberChunks+1)*chunkTableItemSize {
+		return nil, errors.New("commit-graph chunk table is truncated")
+	}
+
+	for i := 0; i < numberChunks; i++ {
+		item := content[headerSize+i*chunkTableItemSize:]
+		nextItem := content[headerSize+(i+1)*chunkTableItemSize:]
+		start, chunkEnd := binary.BigEndian.Uint64(item[4:12]), binary.BigEndian.Uint64(nextItem[4:12])
+		if start > chunkEnd || chunkEnd > end {
+			return nil, errors.New("commit-graph chunk " + string(item[:4]) + " has an invalid offset")
+		}
+		chunks[string(item[:4])] = content[start:chunkEnd]
+	}
+
+	return chunks, nil
+}
+
+func (g *CommitGraph) Len() int {
+	return g.numberCommits
+}
+
+// Shas Sorted shas of all the commits in the graph
+func (g *CommitGraph) Shas() []string {
+	shas := make([]string, g.numberCommits)
+	for i := range shas {
+		shas[i] = hex.EncodeToString(g.rawShaAt(i))
+	}
+	return shas
+}
+
+// Lookup Binary search of the sha in the graph. Only the commits with the same first byte are searched
+func (g *CommitGraph) Lookup(sha string) (Commit, bool) {
+	var decoded [shaSize]byte
+	if len(sha) != 2*shaSize {
+		return Commit{}, false
+	}
+	if _, err := hex.Decode(decoded[:], []byte(sha)); err != nil {
+		return Commit{}, false
+	}
+
+	start, end := 0, int(binary.BigEndian.Uint32(g.fanout[int(decoded[0])*4:]))
+	if decoded[0] > 0 {
+		start = int(binary.BigEndian.Uint32(g.fanout[(int(decoded[0])-1)*4:]))
+	}
+	position := start + sort.Search(end-start, func(i int) bool {
+		return bytes.Compare(g.rawShaAt(start+i), decoded[:]) >= 0
+	})
+	if position == end || !bytes.Equal(g.rawShaAt(position), decoded[:]) {
+		return Commit{}, false
+	}
+
+	commit, err := g.commitAt(position)
+	return commit, err == nil
+}
+
+func (g *CommitGraph) commitAt(position int) (Commit, error) {
+	data := g.commitData[position*commitDataSize : (position+1)*commitDataSize]
+	commit := Commit{Sha: hex.EncodeToString(g.rawShaAt(position)), Tree: hex.EncodeToString(data[:shaSize]), Parents: make([]string, 0, 2)}
+
+	firstParent, secondParent := binary.BigEndian.Uint32(data[20:24]), binary.BigEndian.Uint32(data[24:28])
+	if firstParent != NO_PARENT {
+		parent, err := g.shaAt(firstParent)
+		if err != nil {
+			return commit, err
+		}
+		commit.Parents = append(commit.Parents, parent)
+	}
+	if secondParent&EXTRA_EDGES_NEEDED != 0 {
+		extraParents, err := g.readExtraEdges(secondParent &^ EXTRA_EDGES_NEEDED)
+		if err != nil {
+			return commit, err
+		}
+		commit.Parents = append(commit.Parents, extraParents...)
+	} else if secondParent != NO_PARENT {
+		parent, err := g.shaAt(secondParent)
+		if err != nil {
+			return commit, err
+		}
+		commit.Parents = append(commit.Parents, parent)
+	}
+
+	generationAndTime := binary.BigEndian.Uint64(data[28:36])
+	commit.Generation = uint32(generationAndTime >> 34)
+	commit.CommitTime = int64(generationAndTime & (1<<34 - 1))
+	return commit, nil
+}
+
+func (g *CommitGraph) readExtraEdges(start uint32) ([]string, error) {
+	parents := make([]string, 0)
+	for i := int(start); ; i++ {
+		if (i+1)*4 > len(g.extraEdges) {
+			return nil, errors.New("commit-graph extra edges are truncated")
+		}
+		edge := binary.BigEndian.Uint32(g.extraEdges[i*4:])
+		parent, err := g.shaAt(edge &^ LAST_EDGE)
+		if err != nil {
+			return nil, err
+		}
+		parents = append(parents, parent)
+		if edge&LAST_EDGE != 0 {
+			return parents, nil
+		}
+	}
+}
+
+func (g *CommitGraph) shaAt(position uint32) (string, error) {
+	if int(position) >= g.numberCommits {
+		return "", errors.New("commit-graph parent position " + strconv.Itoa(int(position)) + " is out of range")
+	}
+	return hex.EncodeToString(g.rawShaAt(int(position))), nil
+}
+
+func (g *CommitGraph) rawShaAt(position int) []byte {
+	return g.lookup[position*shaSize : (position+1)*shaSize]
+}
+
+func decodeSha(sha string) ([]byte, error) {
+	decoded, err := hex.DecodeString(sha)
+	if err != nil || len(decoded) != shaSize {
+		return nil, errors.New("invalid sha " + sha)
+	}
+	return decoded, nil
+}
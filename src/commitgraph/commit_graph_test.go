@@ -0,0 +1,44 @@
+package commitgraph
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommitGraph_SerializeAndDeserialize(t *testing.T) {
+	tree := "4b825dc642cb6eb9a060e54bf8d69288fbee4904"
+	root := Commit{Sha: strings.Repeat("a", 40), Tree: tree, Parents: []string{}, Generation: 1, CommitTime: 1 << 33}
+	first := Commit{Sha: strings.Repeat("0", 40), Tree: tree, Parents: []string{root.Sha}, Generation: 2, CommitTime: 10}
+	second := Commit{Sha: strings.Repeat("f", 40), Tree: tree, Parents: []string{root.Sha}, Generation: 2, CommitTime: 11}
+	third := Commit{Sha: strings.Repeat("5", 40), Tree: tree, Parents: []string{root.Sha}, Generation: 2, CommitTime: 12}
+	octopus := Commit{Sha: strings.Repeat("c", 40), Tree: tree, Parents: []string{first.Sha, second.Sha, third.Sha}, Generation: 3, CommitTime: 13}
+
+	content, err := Serialize([]Commit{octopus, root, first, second, third})
+	assert.Nil(t, err)
+	graph, err := Deserialize(content)
+	assert.Nil(t, err)
+
+	assert.Equal(t, 5, graph.Len())
+	assert.Equal(t, []string{first.Sha, third.Sha, root.Sha, octopus.Sha, second.Sha}, graph.Shas())
+	for _, commit := range []Commit{root, first, second, third, octopus} {
+		found, isFound := graph.Lookup(commit.Sha)
+		assert.True(t, isFound)
+		assert.Equal(t, commit, found)
+	}
+	_, isFound := graph.Lookup(strings.Repeat("b", 40))
+	assert.False(t, isFound)
+
+	content[len(content)-1] ^= 0xff
+	_, err = Deserialize(content)
+	assert.NotNil(t, err)
+}
+
+func TestCommitGraph_SerializeMissingParent(t *testing.T) {
+	commit := Commit{Sha: strings.Repeat("a", 40), Tree: strings.Repeat("b", 40), Parents: []string{strings.Repeat("c", 40)}, Generation: 2}
+
+	_, err := Serialize([]Commit{commit})
+
+	assert.NotNil(t, err)
+}
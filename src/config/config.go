@@ -0,0 +1,361 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+)
+
+// Scopes of the config files in precedence order. Values of later scopes override the earlier ones
+const (
+	SYSTEM_SCOPE   = "system"
+	GLOBAL_SCOPE   = "global"
+	LOCAL_SCOPE    = "local"
+	WORKTREE_SCOPE = "worktree"
+	COMMAND_SCOPE  = "command" //GIT_CONFIG_COUNT, GIT_CONFIG_KEY_<n> and GIT_CONFIG_VALUE_<n> environment variables
+)
+
+// Types of NormalizeValue
+const (
+	BOOL_TYPE        = "bool"
+	INT_TYPE         = "int"
+	BOOL_OR_INT_TYPE = "bool-or-int"
+	PATH_TYPE        = "path"
+)
+
+var ErrNotFound = errors.New("key not found")
+var ErrMultipleValues = errors.New("key has multiple values")
+
+// ValueMatcher Selects some values of a multi-valued key. Nil selects all of them
+type ValueMatcher func(value string) bool
+
+// Entry Value of a key. Keys have the format <section>[.<subsection>].<name>. Section and name are case insensitive and stored in lowercase
+type Entry struct {
+	Key     string
+	Value   string
+	NoValue bool //Keys without "=" in the file. They are true booleans. Example: [core] bare
+	Scope   string
+	Origin  string //Path of the file. Empty for command scope
+}
+
+// Config Entries of the config files in precedence order, so the last value of a key is the one used
+type Config struct {
+	entries  []Entry
+	sources  []Source
+	includes bool
+	context  IncludeContext
+}
+
+// Get Returns the last value of the key
+func (c *Config) Get(key string) (string, bool) {
+	entry, found := c.getEntry(key)
+	return entry.Value, found
+}
+
+// String Returns the last value of the key, or "" if it is not set
+func (c *Config) String(key string) string {
+	value, _ := c.Get(key)
+	return value
+}
+
+// MustString Returns defaultValue if the key is not set
+func (c *Config) MustString(key string, defaultValue string) string {
+	if value, found := c.Get(key); found {
+		return value
+	}
+	return defaultValue
+}
+
+// GetAll Values of a multi-valued key in precedence order
+func (c *Config) GetAll(key string) []string {
+	values := make([]string, 0)
+	for _, entry := range c.GetAllEntries(key) {
+		values = append(values, entry.Value)
+	}
+
+	return values
+}
+
+func (c *Config) GetAllEntries(key string) []Entry {
+	canonicalKey, err := NormalizeKey(key)
+	entries := make([]Entry, 0)
+	if err != nil {
+		return entries
+	}
+
+	for _, entry := range c.entries {
+		if entry.Key == canonicalKey {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries
+}
+
+// Entries All the entries in precedence order
+func (c *Config) Entries() []Entry {
+	return c.entries
+}
+
+// Bool Returns defaultValue if the key is not set. Values: true, yes, on, 1 or a key without value, and false, no, off, 0 or ""
+func (c *Config) Bool(key string, defaultValue bool) (bool, error) {
+	entry, found := c.getEntry(key)
+	if !found {
+		return defaultValue, nil
+	}
+
+	value, err := ParseEntryBool(entry)
+	if err != nil {
+		return defaultValue, errors.New("bad boolean config value '" + entry.Value + "' for '" + entry.Key + "'")
+	}
+	return value, nil
+}
+
+// MustBool Invalid values are ignored
+func (c *Config) MustBool(key string, defaultValue bool) bool {
+	value, err := c.Bool(key, defaultValue)
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// Int Returns defaultValue if the key is not set. Values can have the suffixes k, m and g (Ex: 1k = 1024)
+func (c *Config) Int(key string, defaultValue int64) (int64, error) {
+	entry, found := c.getEntry(key)
+	if !found {
+		return defaultValue, nil
+	}
+
+	value, err := ParseInt(entry.Value)
+	if err != nil {
+		return defaultValue, errors.New("bad numeric config value '" + entry.Value + "' for '" + entry.Key + "': " + err.Error())
+	}
+	return value, nil
+}
+
+// Path Returns the value with ~/ and ~user/ expanded, or "" if it is not set
+func (c *Config) Path(key string) (string, error) {
+	value, found := c.Get(key)
+	if !found {
+		return "", nil
+	}
+
+	return ExpandPath(value)
+}
+
+func (c *Config) getEntry(key string) (Entry, bool) {
+	entries := c.GetAllEntries(key)
+	if len(entries) == 0 {
+		return Entry{}, false
+	}
+
+	return entries[len(entries)-1], true
+}
+
+// Set Writes key = value in the file of the scope replacing its current value. The config is read again, so the new value is visible
+func (c *Config) Set(scope string, key string, value string) error {
+	return c.editScopeFile(scope, func(file *File) error {
+		return file.Set(key, value, nil, false)
+	})
+}
+
+// Unset Removes the key from the file of the scope
+func (c *Config) Unset(scope string, key string) error {
+	return c.editScopeFile(scope, func(file *File) error {
+		return file.Unset(key, nil, false)
+	})
+}
+
+func (c *Config) editScopeFile(scope string, edit func(file *File) error) error {
+	path, err := c.ScopePath(scope)
+	if err != nil {
+		return err
+	}
+	file, err := ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := edit(file); err != nil {
+		return err
+	}
+	if err := file.Save(); err != nil {
+		return err
+	}
+
+	return c.Reload()
+}
+
+// Reload Reads the config files again
+func (c *Config) Reload() error {
+	reloaded, err := Load(c.sources, c.includes, c.context)
+	if err != nil {
+		return err
+	}
+
+	c.entries = reloaded.entries
+	return nil
+}
+
+// NormalizeKey Returns the key with section and name in lowercase. The subsection keeps its case
+func NormalizeKey(key string) (string, error) {
+	section, subsection, name, err := splitKey(key)
+	if err != nil {
+		return "", err
+	}
+
+	return joinKey(strings.ToLower(section), subsection, strings.ToLower(name)), nil
+}
+
+// Section is until the first dot and name after the last one. Example: remote.my.origin.url -> remote, my.origin, url
+func splitKey(key string) (string, string, string, error) {
+	firstDot, lastDot := strings.Index(key, "."), strings.LastIndex(key, ".")
+	if firstDot <= 0 || lastDot == len(key)-1 {
+		return "", "", "", errors.New("key does not contain a section: " + key)
+	}
+
+	section, name, subsection := key[:firstDot], key[lastDot+1:], ""
+	if firstDot != lastDot {
+		subsection = key[firstDot+1 : lastDot]
+	}
+
+	for i := 0; i < len(section); i++ {
+		if !isKeyChar(section[i]) {
+			return "", "", "", errors.New("invalid key: " + key)
+		}
+	}
+	for i := 0; i < len(name); i++ {
+		if !isKeyChar(name[i]) || (i == 0 && !isAlpha(name[i])) {
+			return "", "", "", errors.New("invalid key: " + key)
+		}
+	}
+	if strings.Contains(subsection, "\n") {
+		return "", "", "", errors.New("invalid key (newline): " + key)
+	}
+
+	return section, subsection, name, nil
+}
+
+func joinKey(section string, subsection string, name string) string {
+	if subsection == "" {
+		return section + "." + name
+	}
+	return section + "." + subsection + "." + name
+}
+
+// ParseBool Values: true, yes, on, 1 and false, no, off, 0, "". Case insensitive
+func ParseBool(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "true", "yes", "on", "1":
+		return true, nil
+	case "false", "no", "off", "0", "":
+		return false, nil
+	}
+
+	if number, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return number != 0, nil
+	}
+	return false, errors.New("invalid boolean " + value)
+}
+
+// ParseEntryBool Keys without value are true
+func ParseEntryBool(entry Entry) (bool, error) {
+	if entry.NoValue {
+		return true, nil
+	}
+	return ParseBool(entry.Value)
+}
+
+// ParseInt Suffixes k, m and g multiply by 1024, 1024^2 and 1024^3
+func ParseInt(value string) (int64, error) {
+	multiplier := int64(1)
+	trimmed := strings.TrimSpace(value)
+	if trimmed != "" {
+		switch strings.ToLower(trimmed[len(trimmed)-1:]) {
+		case "k":
+			multiplier = 1024
+		case "m":
+			multiplier = 1024 * 1024
+		case "g":
+			multiplier = 1024 * 1024 * 1024
+		}
+		if multiplier != 1 {
+			trimmed = trimmed[:len(trimmed)-1]
+		}
+	}
+
+	number, err := strconv.ParseInt(trimmed, 10, 64)
+	if err != nil {
+		if numError, isNumError := err.(*strconv.NumError); isNumError && numError.Err == strconv.ErrRange {
+			return 0, errors.New("out of range")
+		}
+		return 0, errors.New("invalid unit")
+	}
+	if number > (1<<63-1)/multiplier || number < (-1<<63)/multiplier {
+		return 0, errors.New("out of range")
+	}
+
+	return number * multiplier, nil
+}
+
+// NormalizeValue Returns the canonical form of a value of the type: true or false for bools, the number without suffix
+// for ints and the expanded path for paths. Bool-or-int values are ints if they are numbers
+func NormalizeValue(entry Entry, valueType string) (string, error) {
+	switch valueType {
+	case "":
+		return entry.Value, nil
+	case BOOL_TYPE:
+		value, err := ParseEntryBool(entry)
+		if err != nil {
+			return "", errors.New("bad boolean config value '" + entry.Value + "' for '" + entry.Key + "'")
+		}
+		return strconv.FormatBool(value), nil
+	case INT_TYPE:
+		value, err := ParseInt(entry.Value)
+		if err != nil {
+			return "", errors.New("bad numeric config value '" + entry.Value + "' for '" + entry.Key + "': " + err.Error())
+		}
+		return strconv.FormatInt(value, 10), nil
+	case BOOL_OR_INT_TYPE:
+		if value, err := ParseInt(entry.Value); err == nil && !entry.NoValue {
+			return strconv.FormatInt(value, 10), nil
+		}
+		return NormalizeValue(entry, BOOL_TYPE)
+	case PATH_TYPE:
+		return ExpandPath(entry.Value)
+	}
+
+	return "", errors.New("unrecognized --type argument, " + valueType)
+}
+
+// ExpandPath Expands ~/ to the home directory and ~user/ to the home of user
+func ExpandPath(value string) (string, error) {
+	if !strings.HasPrefix(value, "~") {
+		return value, nil
+	}
+
+	userName, rest, _ := strings.Cut(value[1:], "/")
+	home, err := getHomeDir(userName)
+	if err != nil {
+		return "", errors.New("failed to expand user dir in: '" + value + "'")
+	}
+	if rest == "" && !strings.Contains(value, "/") {
+		return home, nil
+	}
+
+	return home + "/" + rest, nil
+}
+
+func getHomeDir(userName string) (string, error) {
+	if userName == "" {
+		return os.UserHomeDir()
+	}
+
+	account, err := user.Lookup(userName)
+	if err != nil {
+		return "", err
+	}
+	return account.HomeDir, nil
+}
@@ -0,0 +1,446 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// File Config file in git format. Lines are kept as they are, so edits preserve comments and layout. Format:
+// [section]
+//
+//	name = value
+//
+// [section "subsection"]
+//
+//	name = "value with ; or #" ; comment
+type File struct {
+	Path  string
+	lines []string
+}
+
+// Key or section header found while parsing a file. Values can span several lines ending with "\"
+type fileItem struct {
+	section    string //Lowercase
+	subsection string
+	name       string //Lowercase. Empty for section headers
+	value      string
+	noValue    bool
+	start      int    //First line
+	end        int    //Line after the last one
+	header     string //Text of the section header when the key is on the same line. Example: [core] bare = true
+}
+
+func (i fileItem) key() string {
+	return joinKey(i.section, i.subsection, i.name)
+}
+
+// ReadFile A file that doesnt exist is read as empty and created when saved
+func ReadFile(path string) (*File, error) {
+	content, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	file := &File{Path: path, lines: make([]string, 0)}
+	if len(content) > 0 {
+		file.lines = strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	}
+
+	_, err = file.parse()
+	return file, err
+}
+
+// Entries Keys of the file in order. Includes are not followed
+func (f *File) Entries() []Entry {
+	items, _ := f.parse()
+	entries := make([]Entry, 0)
+	for _, item := range items {
+		if item.name != "" {
+			entries = append(entries, Entry{Key: item.key(), Value: item.value, NoValue: item.noValue, Origin: f.Path})
+		}
+	}
+
+	return entries
+}
+
+// Set Replaces the values of key accepted by matcher (all of them if nil) with a single value. If there are several, all has to be true.
+// The key is added if none is accepted
+func (f *File) Set(key string, value string, matcher ValueMatcher, all bool) error {
+	section, subsection, name, err := splitKey(key)
+	if err != nil {
+		return err
+	}
+	items, err := f.parse()
+	if err != nil {
+		return err
+	}
+
+	matching := f.findMatching(items, key, matcher)
+	if len(matching) > 1 && !all {
+		return ErrMultipleValues
+	}
+	if len(matching) == 0 {
+		f.add(items, section, subsection, name, value)
+		return nil
+	}
+
+	for i := len(matching) - 1; i > 0; i-- {
+		f.removeItem(matching[i])
+	}
+	f.replaceItem(matching[0], formatKeyLine(name, value))
+	return nil
+}
+
+// Add Adds a new value to key, keeping the existing ones. Used by multi-valued keys
+func (f *File) Add(key string, value string) error {
+	section, subsection, name, err := splitKey(key)
+	if err != nil {
+		return err
+	}
+	items, err := f.parse()
+	if err != nil {
+		return err
+	}
+
+	f.add(items, section, subsection, name, value)
+	return nil
+}
+
+// Unset Removes the values of key accepted by matcher (all of them if nil). If there are several, all has to be true.
+// Sections left without keys or comments are removed
+func (f *File) Unset(key string, matcher ValueMatcher, all bool) error {
+	if _, _, _, err := splitKey(key); err != nil {
+		return err
+	}
+	items, err := f.parse()
+	if err != nil {
+		return err
+	}
+
+	matching := f.findMatching(items, key, matcher)
+	if len(matching) == 0 {
+		return ErrNotFound
+	}
+	if len(matching) > 1 && !all {
+		return ErrMultipleValues
+	}
+
+	for i := len(matching) - 1; i >= 0; i-- {
+		f.removeItem(matching[i])
+	}
+	f.removeEmptySections(matching[0].section, matching[0].subsection)
+	return nil
+}
+
+// Save Writes the file through <path>.lock, so readers never see it half written
+func (f *File) Save() error {
+	if err := os.MkdirAll(filepath.Dir(f.Path), os.ModePerm); err != nil {
+		return err
+	}
+
+	lockPath := f.Path + ".lock"
+	lockFile, err := os.OpenFile(lockPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return errors.New("could not lock config file " + f.Path + ": " + err.Error())
+	}
+
+	content := ""
+	if len(f.lines) > 0 {
+		content = strings.Join(f.lines, "\n") + "\n"
+	}
+	_, err = lockFile.WriteString(content)
+	if closeErr := lockFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(lockPath, f.Path)
+	}
+	if err != nil {
+		os.Remove(lockPath)
+	}
+
+	return err
+}
+
+// Items accepted by matcher in the order of the file
+func (f *File) findMatching(items []fileItem, key string, matcher ValueMatcher) []fileItem {
+	canonicalKey, _ := NormalizeKey(key)
+	matching := make([]fileItem, 0)
+	for _, item := range items {
+		if item.name != "" && item.key() == canonicalKey && (matcher == nil || matcher(item.value)) {
+			matching = append(matching, item)
+		}
+	}
+
+	return matching
+}
+
+// New keys go after the last key of the last matching section. If there is no section, it is appended to the file
+func (f *File) add(items []fileItem, section string, subsection string, name string, value string) {
+	insertAt := -1
+	for _, item := range items {
+		if item.section == strings.ToLower(section) && item.subsection == subsection {
+			insertAt = item.end
+		}
+	}
+
+	if insertAt == -1 {
+		f.lines = append(f.lines, formatSectionHeader(section, subsection), formatKeyLine(name, value))
+		return
+	}
+	f.lines = append(f.lines[:insertAt], append([]string{formatKeyLine(name, value)}, f.lines[insertAt:]...)...)
+}
+
+func (f *File) replaceItem(item fileItem, line string) {
+	replacement := []string{line}
+	if item.header != "" {
+		replacement = []string{item.header, line}
+	}
+
+	f.lines = append(f.lines[:item.start], append(replacement, f.lines[item.end:]...)...)
+}
+
+func (f *File) removeItem(item fileItem) {
+	replacement := []string{}
+	if item.header != "" {
+		replacement = []string{item.header}
+	}
+
+	f.lines = append(f.lines[:item.start], append(replacement, f.lines[item.end:]...)...)
+}
+
+// A section is empty if there are only blank lines until the next section header
+func (f *File) removeEmptySections(section string, subsection string) {
+	items, err := f.parse()
+	if err != nil {
+		return
+	}
+
+	for i := len(items) - 1; i >= 0; i-- {
+		if items[i].name != "" || items[i].section != section || items[i].subsection != subsection ||
+			(i+1 < len(items) && items[i+1].name != "") {
+			continue
+		}
+		end := len(f.lines)
+		if i+1 < len(items) {
+			end = items[i+1].start
+		}
+		if isBlank(f.lines[items[i].end:end]) && strings.HasSuffix(strings.TrimSpace(f.lines[items[i].start]), "]") {
+			f.lines = append(f.lines[:items[i].start], f.lines[end:]...)
+		}
+	}
+}
+
+func isBlank(lines []string) bool {
+	for _, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *File) parse() ([]fileItem, error) {
+	parser := fileParser{lines: f.lines, items: make([]fileItem, 0)}
+	if err := parser.parse(); err != nil {
+		return nil, errors.New("bad config line " + strconv.Itoa(parser.line+1) + " in file " + f.Path)
+	}
+
+	return parser.items, nil
+}
+
+type fileParser struct {
+	lines      []string
+	items      []fileItem
+	line       int
+	section    string
+	subsection string
+}
+
+func (p *fileParser) parse() error {
+	for ; p.line < len(p.lines); p.line++ {
+		text := strings.TrimLeft(p.lines[p.line], " \t")
+		header := ""
+
+		if strings.HasPrefix(text, "[") {
+			end, err := p.parseSectionHeader(text)
+			if err != nil {
+				return err
+			}
+			p.items = append(p.items, fileItem{section: p.section, subsection: p.subsection, start: p.line, end: p.line + 1})
+			header, text = strings.TrimRight(p.lines[p.line][:len(p.lines[p.line])-len(text)+end], " \t"), strings.TrimLeft(text[end:], " \t")
+		}
+		if text == "" || text[0] == '#' || text[0] == ';' {
+			continue
+		}
+
+		if err := p.parseKey(text, header); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Formats: [section], [section "subsection"] and the deprecated [section.subsection]. Returns the length of the header
+func (p *fileParser) parseSectionHeader(text string) (int, error) {
+	i := 1
+	for i < len(text) && (isKeyChar(text[i]) || text[i] == '.') {
+		i++
+	}
+	section := strings.ToLower(text[1:i])
+	if section == "" {
+		return 0, errors.New("invalid section")
+	}
+
+	if i < len(text) && text[i] == ']' {
+		p.section, p.subsection = section, ""
+		if dot := strings.Index(section, "."); dot != -1 {
+			p.section, p.subsection = section[:dot], section[dot+1:]
+		}
+		return i + 1, nil
+	}
+
+	for i < len(text) && (text[i] == ' ' || text[i] == '\t') {
+		i++
+	}
+	if i >= len(text) || text[i] != '"' || strings.Contains(section, ".") {
+		return 0, errors.New("invalid section")
+	}
+
+	var subsection strings.Builder
+	for i++; i < len(text) && text[i] != '"'; i++ {
+		if text[i] == '\\' && i+1 < len(text) {
+			i++
+		}
+		subsection.WriteByte(text[i])
+	}
+	if i+1 >= len(text) || text[i+1] != ']' {
+		return 0, errors.New("invalid section")
+	}
+
+	p.section, p.subsection = section, subsection.String()
+	return i + 2, nil
+}
+
+// Format: name [= value]. Keys without value are true booleans
+func (p *fileParser) parseKey(text string, header string) error {
+	if p.section == "" || !isAlpha(text[0]) {
+		return errors.New("invalid key")
+	}
+
+	i := 0
+	for i < len(text) && isKeyChar(text[i]) {
+		i++
+	}
+	item := fileItem{section: p.section, subsection: p.subsection, name: strings.ToLower(text[:i]), start: p.line, header: header}
+	rest := strings.TrimLeft(text[i:], " \t")
+
+	switch {
+	case rest == "" || rest[0] == '#' || rest[0] == ';':
+		item.noValue = true
+	case rest[0] == '=':
+		value, err := p.parseValue(rest[1:])
+		if err != nil {
+			return err
+		}
+		item.value = value
+	default:
+		return errors.New("invalid key")
+	}
+
+	item.end = p.line + 1
+	p.items = append(p.items, item)
+	return nil
+}
+
+// Spaces around the value are removed unless quoted. A "\" at the end of a line continues the value in the next one.
+// Escapes: \\, \", \n, \t and \b
+func (p *fileParser) parseValue(text string) (string, error) {
+	var value strings.Builder
+	quoted, pendingSpaces := false, 0
+
+	for i := 0; ; i++ {
+		if i >= len(text) {
+			if quoted {
+				return "", errors.New("unterminated quote")
+			}
+			return value.String(), nil
+		}
+
+		char := text[i]
+		switch {
+		case (char == ' ' || char == '\t') && !quoted:
+			if value.Len() > 0 {
+				pendingSpaces++
+			}
+			continue
+		case (char == '#' || char == ';') && !quoted:
+			i = len(text) - 1
+			continue
+		}
+
+		for ; pendingSpaces > 0; pendingSpaces-- {
+			value.WriteByte(' ')
+		}
+
+		switch char {
+		case '"':
+			quoted = !quoted
+		case '\\':
+			if i+1 >= len(text) { //Continuation line
+				if p.line+1 >= len(p.lines) {
+					return "", errors.New("unexpected end of file")
+				}
+				p.line++
+				text, i = p.lines[p.line], -1
+				continue
+			}
+			i++
+			switch text[i] {
+			case '\\', '"':
+				value.WriteByte(text[i])
+			case 'n':
+				value.WriteByte('\n')
+			case 't':
+				value.WriteByte('\t')
+			case 'b':
+				value.WriteByte('\b')
+			default:
+				return "", errors.New("invalid escape")
+			}
+		default:
+			value.WriteByte(char)
+		}
+	}
+}
+
+// Values are quoted if they start or end with spaces or contain comment characters
+func formatKeyLine(name string, value string) string {
+	quote := ""
+	if strings.HasPrefix(value, " ") || strings.HasSuffix(value, " ") || strings.ContainsAny(value, "#;") {
+		quote = "\""
+	}
+
+	escaped := strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "\n", "\\n", "\t", "\\t", "\b", "\\b").Replace(value)
+	return "\t" + name + " = " + quote + escaped + quote
+}
+
+func formatSectionHeader(section string, subsection string) string {
+	if subsection == "" {
+		return "[" + strings.ToLower(section) + "]"
+	}
+
+	return "[" + strings.ToLower(section) + " \"" + strings.NewReplacer("\\", "\\\\", "\"", "\\\"").Replace(subsection) + "\"]"
+}
+
+func isAlpha(char byte) bool {
+	return (char >= 'a' && char <= 'z') || (char >= 'A' && char <= 'Z')
+}
+
+func isKeyChar(char byte) bool {
+	return isAlpha(char) || (char >= '0' && char <= '9') || char == '-'
+}
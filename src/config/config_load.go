@@ -0,0 +1,288 @@
+package config
+
+import (
+	"errors"
+	"git/src/utils"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const MAX_INCLUDE_DEPTH = 10
+
+const DEFAULT_SYSTEM_CONFIG_PATH = "/etc/gitconfig"
+
+// Source Config file read by Load. Files that dont exist are skipped. The source of COMMAND_SCOPE has no path, it reads the environment
+type Source struct {
+	Scope string
+	Path  string
+}
+
+// IncludeContext Repository whose state is checked by includeIf conditions. Empty outside repositories
+type IncludeContext struct {
+	GitDir    string
+	CommonDir string
+}
+
+// DefaultSources System and global config files and the environment. Used outside repositories
+func DefaultSources() []Source {
+	return append(append(systemSources(), globalSources()...), Source{Scope: COMMAND_SCOPE})
+}
+
+// RepositorySources Default sources plus <common dir>/config and, if extensions.worktreeConfig is true, <git dir>/config.worktree
+func RepositorySources(gitDir string, commonDir string) []Source {
+	sources := append(systemSources(), globalSources()...)
+	localPath := utils.Path(commonDir, "config")
+	sources = append(sources, Source{Scope: LOCAL_SCOPE, Path: localPath})
+
+	if localFile, err := ReadFile(localPath); err == nil {
+		localConfig := Config{entries: localFile.Entries()}
+		if localConfig.MustBool("extensions.worktreeConfig", false) {
+			sources = append(sources, Source{Scope: WORKTREE_SCOPE, Path: utils.Path(gitDir, "config.worktree")})
+		}
+	}
+
+	return append(sources, Source{Scope: COMMAND_SCOPE})
+}
+
+// FilterSources Sources of a single scope
+func FilterSources(sources []Source, scope string) []Source {
+	filtered := make([]Source, 0)
+	for _, source := range sources {
+		if source.Scope == scope {
+			filtered = append(filtered, source)
+		}
+	}
+
+	return filtered
+}
+
+// Load Reads the sources in order. If includes is true, the files of include.path and includeIf.<condition>.path
+// are read just after the key, as if their content was in its place. Relative paths are relative to the file with the key.
+// Conditions: gitdir:<pattern>, gitdir/i:<pattern> (case insensitive) and onbranch:<pattern>
+func Load(sources []Source, includes bool, context IncludeContext) (*Config, error) {
+	loaded := &Config{entries: make([]Entry, 0), sources: sources, includes: includes, context: context}
+
+	for _, source := range sources {
+		var err error
+		if source.Path == "" {
+			err = loaded.readEnvironment()
+		} else {
+			err = loaded.readFile(source.Scope, source.Path, 0)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return loaded, nil
+}
+
+// LoadFile Reads a single file, without following includes
+func LoadFile(path string, scope string) (*Config, error) {
+	return Load([]Source{{Scope: scope, Path: path}}, false, IncludeContext{})
+}
+
+// ScopePath File where the values of a scope are written. Global values go to ~/.gitconfig unless only the XDG file exists.
+// Without extensions.worktreeConfig, worktree values go to the local file if there are no linked work trees
+func (c *Config) ScopePath(scope string) (string, error) {
+	switch scope {
+	case SYSTEM_SCOPE:
+		return systemPath(), nil
+	case GLOBAL_SCOPE:
+		globalPaths := globalSources()
+		for i := len(globalPaths) - 1; i >= 0; i-- {
+			if utils.CheckFileOrDirExists(globalPaths[i].Path) {
+				return globalPaths[i].Path, nil
+			}
+		}
+		return globalPaths[len(globalPaths)-1].Path, nil
+	case LOCAL_SCOPE, WORKTREE_SCOPE:
+		if c.context.CommonDir == "" {
+			return "", errors.New("--" + scope + " can only be used inside a git repository")
+		}
+	default:
+		return "", errors.New("invalid config scope " + scope)
+	}
+
+	if worktreeSources := FilterSources(c.sources, WORKTREE_SCOPE); scope == WORKTREE_SCOPE && len(worktreeSources) > 0 {
+		return worktreeSources[0].Path, nil
+	}
+	if linkedWorktrees, _ := os.ReadDir(utils.Path(c.context.CommonDir, "worktrees")); scope == WORKTREE_SCOPE && len(linkedWorktrees) > 0 {
+		return "", errors.New("--worktree cannot be used with multiple working trees unless the config extension worktreeConfig is enabled")
+	}
+
+	return utils.Path(c.context.CommonDir, "config"), nil
+}
+
+// GIT_CONFIG_SYSTEM replaces /etc/gitconfig. GIT_CONFIG_NOSYSTEM skips it
+func systemSources() []Source {
+	if noSystem, _ := ParseBool(os.Getenv("GIT_CONFIG_NOSYSTEM")); noSystem {
+		return []Source{}
+	}
+
+	return []Source{{Scope: SYSTEM_SCOPE, Path: systemPath()}}
+}
+
+func systemPath() string {
+	if path := os.Getenv("GIT_CONFIG_SYSTEM"); path != "" {
+		return path
+	}
+	return DEFAULT_SYSTEM_CONFIG_PATH
+}
+
+// GIT_CONFIG_GLOBAL replaces both $XDG_CONFIG_HOME/git/config (default ~/.config/git/config) and ~/.gitconfig
+func globalSources() []Source {
+	if path := os.Getenv("GIT_CONFIG_GLOBAL"); path != "" {
+		return []Source{{Scope: GLOBAL_SCOPE, Path: path}}
+	}
+
+	home, _ := os.UserHomeDir()
+	xdgConfigHome := os.Getenv("XDG_CONFIG_HOME")
+	if xdgConfigHome == "" {
+		xdgConfigHome = utils.Path(home, ".config")
+	}
+
+	return []Source{
+		{Scope: GLOBAL_SCOPE, Path: utils.Paths(xdgConfigHome, "git", "config")},
+		{Scope: GLOBAL_SCOPE, Path: utils.Path(home, ".gitconfig")},
+	}
+}
+
+func (c *Config) readFile(scope string, path string, depth int) error {
+	if depth > MAX_INCLUDE_DEPTH {
+		return errors.New("exceeded maximum include depth (" + strconv.Itoa(MAX_INCLUDE_DEPTH) + ") while including " + path)
+	}
+
+	file, err := ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range file.Entries() {
+		entry.Scope = scope
+		c.entries = append(c.entries, entry)
+
+		if !c.includes {
+			continue
+		}
+		if includePath, include := c.getIncludePath(entry, path); include {
+			if err := c.readFile(scope, includePath, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Format: GIT_CONFIG_COUNT=<n>, GIT_CONFIG_KEY_<i>=<key> and GIT_CONFIG_VALUE_<i>=<value> for i in 0..n-1
+func (c *Config) readEnvironment() error {
+	countText := os.Getenv("GIT_CONFIG_COUNT")
+	if countText == "" {
+		return nil
+	}
+	count, err := strconv.Atoi(countText)
+	if err != nil || count < 0 {
+		return errors.New("bogus count in GIT_CONFIG_COUNT")
+	}
+
+	for i := 0; i < count; i++ {
+		key, found := os.LookupEnv("GIT_CONFIG_KEY_" + strconv.Itoa(i))
+		if !found {
+			return errors.New("missing config key GIT_CONFIG_KEY_" + strconv.Itoa(i))
+		}
+		value, found := os.LookupEnv("GIT_CONFIG_VALUE_" + strconv.Itoa(i))
+		if !found {
+			return errors.New("missing config value GIT_CONFIG_VALUE_" + strconv.Itoa(i))
+		}
+		canonicalKey, err := NormalizeKey(key)
+		if err != nil {
+			return err
+		}
+
+		c.entries = append(c.entries, Entry{Key: canonicalKey, Value: value, Scope: COMMAND_SCOPE})
+	}
+
+	return nil
+}
+
+// Keys: include.path and includeIf.<condition>.path
+func (c *Config) getIncludePath(entry Entry, configPath string) (string, bool) {
+	if entry.NoValue || entry.Value == "" {
+		return "", false
+	}
+
+	if entry.Key != "include.path" {
+		condition, isIncludeIf := strings.CutPrefix(entry.Key, "includeif.")
+		condition, hasPath := strings.CutSuffix(condition, ".path")
+		if !isIncludeIf || !hasPath || !c.matchesIncludeCondition(condition, configPath) {
+			return "", false
+		}
+	}
+
+	path, err := ExpandPath(entry.Value)
+	if err != nil {
+		return "", false
+	}
+	if !filepath.IsAbs(path) {
+		path = utils.Path(filepath.Dir(configPath), path)
+	}
+
+	return path, true
+}
+
+func (c *Config) matchesIncludeCondition(condition string, configPath string) bool {
+	if c.context.GitDir == "" {
+		return false
+	}
+
+	if pattern, found := strings.CutPrefix(condition, "gitdir:"); found {
+		return c.matchesGitDir(pattern, configPath, false)
+	}
+	if pattern, found := strings.CutPrefix(condition, "gitdir/i:"); found {
+		return c.matchesGitDir(pattern, configPath, true)
+	}
+	if pattern, found := strings.CutPrefix(condition, "onbranch:"); found {
+		head, err := os.ReadFile(utils.Path(c.context.GitDir, "HEAD"))
+		branch, onBranch := strings.CutPrefix(utils.SanitizePath(string(head)), "ref: refs/heads/")
+		if strings.HasSuffix(pattern, "/") {
+			pattern += "**"
+		}
+		return err == nil && onBranch && utils.WildMatch(pattern, branch, false)
+	}
+
+	return false
+}
+
+// Patterns starting with ./ are relative to the config file. Patterns not starting with / match at any depth (**/ is prepended)
+// and patterns ending with / match everything inside (** is appended)
+func (c *Config) matchesGitDir(pattern string, configPath string, ignoreCase bool) bool {
+	pattern, err := ExpandPath(pattern)
+	if err != nil {
+		return false
+	}
+
+	switch {
+	case strings.HasPrefix(pattern, "./"):
+		pattern = filepath.Dir(configPath) + pattern[1:]
+	case !filepath.IsAbs(pattern):
+		pattern = "**/" + pattern
+	}
+	if strings.HasSuffix(pattern, "/") {
+		pattern += "**"
+	}
+
+	gitDirs := []string{filepath.Clean(c.context.GitDir)}
+	if realGitDir, err := filepath.EvalSymlinks(c.context.GitDir); err == nil && realGitDir != gitDirs[0] {
+		gitDirs = append(gitDirs, realGitDir)
+	}
+	for _, gitDir := range gitDirs {
+		if utils.WildMatch(pattern, gitDir, ignoreCase) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,152 @@
+package config
+
+import (
+	"git/src/utils"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeConfigFile(t *testing.T, path string, content string) {
+	assert.Nil(t, os.MkdirAll(utils.Path(path, ".."), os.ModePerm))
+	assert.Nil(t, os.WriteFile(path, []byte(content), 0644))
+}
+
+func TestFile_Parse(t *testing.T) {
+	path := utils.Path(t.TempDir(), "config")
+	writeConfigFile(t, path, "# comment\n[Core]\n\tBare\n\tname = \" spaced \" ; comment\n[remote \"Origin\"] url = a\\\n b\n"+
+		"[branch.Main]\n\tescaped = \"a\\\"b\\tc\" # comment\n\tempty =\n")
+
+	file, err := ReadFile(path)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []Entry{
+		{Key: "core.bare", NoValue: true, Origin: path},
+		{Key: "core.name", Value: " spaced ", Origin: path},
+		{Key: "remote.Origin.url", Value: "a b", Origin: path},
+		{Key: "branch.main.escaped", Value: "a\"b\tc", Origin: path},
+		{Key: "branch.main.empty", Value: "", Origin: path},
+	}, file.Entries())
+
+	writeConfigFile(t, path, "[core]\n\tname = \"unterminated\n")
+	_, err = ReadFile(path)
+	assert.Equal(t, "bad config line 2 in file "+path, err.Error())
+}
+
+func TestFile_Edit(t *testing.T) {
+	path := utils.Path(t.TempDir(), "config")
+	writeConfigFile(t, path, "[core]\n\tbare = false ; keep\n[remote \"origin\"]\n\tfetch = a\n\tfetch = b\n# comment\n")
+	file, err := ReadFile(path)
+	assert.Nil(t, err)
+
+	assert.Nil(t, file.Set("core.filemode", "true", nil, false))
+	assert.Nil(t, file.Set("CORE.Bare", "true", nil, false))
+	assert.Equal(t, ErrMultipleValues, file.Set("remote.origin.fetch", "c", nil, false))
+	assert.Nil(t, file.Set("remote.origin.fetch", "c", func(value string) bool { return value == "b" }, false))
+	assert.Nil(t, file.Add("remote.origin.fetch", "d"))
+	assert.Nil(t, file.Set("user.name", "a ; b", nil, false))
+	assert.Nil(t, file.Save())
+
+	content, _ := os.ReadFile(path)
+	assert.Equal(t, "[core]\n\tBare = true\n\tfilemode = true\n[remote \"origin\"]\n\tfetch = a\n\tfetch = c\n\tfetch = d\n# comment\n"+
+		"[user]\n\tname = \"a ; b\"\n", string(content))
+
+	assert.Equal(t, ErrNotFound, file.Unset("user.email", nil, false))
+	assert.Nil(t, file.Unset("user.name", nil, false))
+	assert.Equal(t, ErrMultipleValues, file.Unset("remote.origin.fetch", nil, false))
+	assert.Nil(t, file.Unset("remote.origin.fetch", nil, true))
+	assert.Nil(t, file.Save())
+
+	content, _ = os.ReadFile(path)
+	assert.Equal(t, "[core]\n\tBare = true\n\tfilemode = true\n[remote \"origin\"]\n# comment\n", string(content))
+}
+
+func TestConfig_Precedence(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("GIT_CONFIG_GLOBAL", "")
+	t.Setenv("GIT_CONFIG_SYSTEM", utils.Path(home, "system"))
+	t.Setenv("GIT_CONFIG_COUNT", "1")
+	t.Setenv("GIT_CONFIG_KEY_0", "command.Value")
+	t.Setenv("GIT_CONFIG_VALUE_0", "from environment")
+	gitDir := utils.Path(t.TempDir(), ".git")
+
+	writeConfigFile(t, utils.Path(home, "system"), "[user]\n\tname = system\n\temail = system@mail\n")
+	writeConfigFile(t, utils.Paths(home, ".config", "git", "config"), "[user]\n\temail = xdg@mail\n\tname = xdg\n")
+	writeConfigFile(t, utils.Path(home, ".gitconfig"), "[user]\n\tname = global\n[core]\n\tcompression = 1k\n\tbare\n")
+	writeConfigFile(t, utils.Path(gitDir, "config"), "[user]\n\tname = local\n[core]\n\tbare = off\n\tpath = ~/hooks\n")
+
+	repositoryConfig, err := Load(RepositorySources(gitDir, gitDir), true, IncludeContext{GitDir: gitDir, CommonDir: gitDir})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "local", repositoryConfig.String("user.name"))
+	assert.Equal(t, "xdg@mail", repositoryConfig.String("USER.EMAIL"))
+	assert.Equal(t, []string{"system", "xdg", "global", "local"}, repositoryConfig.GetAll("user.name"))
+	assert.Equal(t, "from environment", repositoryConfig.String("command.value"))
+	assert.False(t, repositoryConfig.MustBool("core.bare", true))
+	compression, err := repositoryConfig.Int("core.compression", 0)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1024), compression)
+	path, err := repositoryConfig.Path("core.path")
+	assert.Nil(t, err)
+	assert.Equal(t, utils.Path(home, "hooks"), path)
+
+	globalPath, err := repositoryConfig.ScopePath(GLOBAL_SCOPE)
+	assert.Nil(t, err)
+	assert.Equal(t, utils.Path(home, ".gitconfig"), globalPath)
+	assert.Nil(t, repositoryConfig.Set(LOCAL_SCOPE, "user.name", "changed"))
+	assert.Equal(t, "changed", repositoryConfig.String("user.name"))
+}
+
+func TestConfig_Includes(t *testing.T) {
+	root := t.TempDir()
+	gitDir := utils.Paths(root, "work", "project", ".git")
+	writeConfigFile(t, utils.Path(gitDir, "HEAD"), "ref: refs/heads/feature/x\n")
+	writeConfigFile(t, utils.Path(gitDir, "config"), "[include]\n\tpath = ../../included\n[includeIf \"gitdir:work/\"]\n\tpath = "+
+		utils.Path(root, "work-config")+"\n[includeIf \"gitdir:/other/\"]\n\tpath = "+utils.Path(root, "other-config")+
+		"\n[includeIf \"onbranch:feature/\"]\n\tpath = "+utils.Path(root, "branch-config")+"\n[user]\n\tname = local\n")
+	writeConfigFile(t, utils.Paths(root, "work", "included"), "[user]\n\tname = included\n\temail = included@mail\n")
+	writeConfigFile(t, utils.Path(root, "work-config"), "[user]\n\temail = work@mail\n")
+	writeConfigFile(t, utils.Path(root, "other-config"), "[user]\n\temail = other@mail\n")
+	writeConfigFile(t, utils.Path(root, "branch-config"), "[branch]\n\tconfig = yes\n")
+	sources := []Source{{Scope: LOCAL_SCOPE, Path: utils.Path(gitDir, "config")}}
+
+	repositoryConfig, err := Load(sources, true, IncludeContext{GitDir: gitDir, CommonDir: gitDir})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "local", repositoryConfig.String("user.name"))
+	assert.Equal(t, "work@mail", repositoryConfig.String("user.email"))
+	assert.True(t, repositoryConfig.MustBool("branch.config", false))
+	assert.Equal(t, utils.Paths(root, "work", "included"), repositoryConfig.GetAllEntries("user.email")[0].Origin)
+
+	withoutIncludes, err := Load(sources, false, IncludeContext{GitDir: gitDir, CommonDir: gitDir})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{}, withoutIncludes.GetAll("user.email"))
+}
+
+func TestConfig_NormalizeValue(t *testing.T) {
+	value, err := NormalizeValue(Entry{Value: "On"}, BOOL_TYPE)
+	assert.Nil(t, err)
+	assert.Equal(t, "true", value)
+	value, err = NormalizeValue(Entry{NoValue: true}, BOOL_OR_INT_TYPE)
+	assert.Nil(t, err)
+	assert.Equal(t, "true", value)
+	value, err = NormalizeValue(Entry{Value: "2m"}, BOOL_OR_INT_TYPE)
+	assert.Nil(t, err)
+	assert.Equal(t, "2097152", value)
+
+	_, err = NormalizeValue(Entry{Key: "a.b", Value: "maybe"}, BOOL_TYPE)
+	assert.Equal(t, "bad boolean config value 'maybe' for 'a.b'", err.Error())
+	_, err = NormalizeValue(Entry{Key: "a.b", Value: "1x"}, INT_TYPE)
+	assert.Equal(t, "bad numeric config value '1x' for 'a.b': invalid unit", err.Error())
+
+	key, err := NormalizeKey("Remote.Origin.URL")
+	assert.Nil(t, err)
+	assert.Equal(t, "remote.Origin.url", key)
+	_, err = NormalizeKey("nosection")
+	assert.NotNil(t, err)
+	_, err = NormalizeKey("a.1b")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,73 @@
+package diff
+
+import "sort"
+
+type ChangeType byte
+
+const (
+	ADDED    ChangeType = 'A'
+	MODIFIED ChangeType = 'M'
+	DELETED  ChangeType = 'D'
+	RENAMED  ChangeType = 'R'
+	COPIED   ChangeType = 'C'
+)
+
+const GITLINK_MODE = "160000"
+
+// FileEntry Version of a file in a tree, the index or the work tree
+type FileEntry struct {
+	Mode string
+	Sha  string
+}
+
+func (f FileEntry) IsGitlink() bool {
+	return f.Mode == GITLINK_MODE
+}
+
+// Change OldPath is empty in additions and NewPath in deletions. Old and New are zero values if the file doesnt exist on that side
+type Change struct {
+	Type    ChangeType
+	OldPath string
+	NewPath string
+	Old     FileEntry
+	New     FileEntry
+	Score   int //Similarity (0-100) of renames and copies
+}
+
+// Path Returns the path of the file after the change, or before it if it was deleted
+func (c Change) Path() string {
+	if c.Type == DELETED {
+		return c.OldPath
+	}
+	return c.NewPath
+}
+
+// DiffFileMaps Compares two versions of a set of files keyed by path. Changes are sorted by path
+func DiffFileMaps(oldFiles map[string]FileEntry, newFiles map[string]FileEntry) []Change {
+	changes := make([]Change, 0)
+
+	for path, newEntry := range newFiles {
+		oldEntry, existedBefore := oldFiles[path]
+
+		if !existedBefore {
+			changes = append(changes, Change{Type: ADDED, NewPath: path, New: newEntry})
+		} else if oldEntry != newEntry {
+			changes = append(changes, Change{Type: MODIFIED, OldPath: path, NewPath: path, Old: oldEntry, New: newEntry})
+		}
+	}
+	for path, oldEntry := range oldFiles {
+		if _, existsAfter := newFiles[path]; !existsAfter {
+			changes = append(changes, Change{Type: DELETED, OldPath: path, Old: oldEntry})
+		}
+	}
+
+	sortChanges(changes)
+
+	return changes
+}
+
+func sortChanges(changes []Change) {
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].Path() < changes[j].Path()
+	})
+}
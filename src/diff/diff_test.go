@@ -0,0 +1,173 @@
+package diff
+
+import (
+	"errors"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff_Similarity(t *testing.T) {
+	assert.Equal(t, 100, Similarity([]byte("a\nb\n"), []byte("b\na\n")))
+	assert.Equal(t, 50, Similarity([]byte("a\nb\n"), []byte("a\nc\n")))
+	assert.Equal(t, 0, Similarity([]byte("a\n"), []byte("")))
+	assert.Equal(t, 100, Similarity([]byte(""), []byte("")))
+}
+
+func TestDiff_ParseSimilarityThreshold(t *testing.T) {
+	threshold, err := ParseSimilarityThreshold("90%")
+	assert.Nil(t, err)
+	assert.Equal(t, 90, threshold)
+
+	threshold, err = ParseSimilarityThreshold("5")
+	assert.Nil(t, err)
+	assert.Equal(t, 50, threshold)
+
+	threshold, err = ParseSimilarityThreshold("05")
+	assert.Nil(t, err)
+	assert.Equal(t, 5, threshold)
+
+	_, err = ParseSimilarityThreshold("abc")
+	assert.NotNil(t, err)
+}
+
+func TestDiff_DetectRenames(t *testing.T) {
+	blobs := map[string][]byte{
+		"1": []byte("line 1\nline 2\nline 3\nline 4\n"),
+		"2": []byte("line 1\nline 2\nline 3\nline 5\n"),
+		"3": []byte("other\n"),
+		"4": []byte("completely different\n"),
+	}
+	readBlob := func(sha string) ([]byte, error) {
+		if content, found := blobs[sha]; found {
+			return content, nil
+		}
+		return nil, errors.New("not found")
+	}
+
+	changes := DiffFileMaps(
+		map[string]FileEntry{"old.txt": {Mode: "100644", Sha: "1"}, "same.txt": {Mode: "100644", Sha: "3"}, "gone.txt": {Mode: "100644", Sha: "4"}},
+		map[string]FileEntry{"new.txt": {Mode: "100644", Sha: "2"}, "moved/same.txt": {Mode: "100644", Sha: "3"}},
+	)
+
+	renamed, err := DetectRenames(changes, DefaultRenameOptions(), readBlob)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(renamed))
+	assert.Equal(t, Change{Type: DELETED, OldPath: "gone.txt", Old: FileEntry{Mode: "100644", Sha: "4"}}, renamed[0])
+	assert.Equal(t, "R100\tsame.txt\tmoved/same.txt", FormatNameStatus(renamed[1]))
+	assert.Equal(t, "R075\told.txt\tnew.txt", FormatNameStatus(renamed[2]))
+
+	options := DefaultRenameOptions()
+	options.RenameThreshold = 80
+	renamed, err = DetectRenames(changes, options, readBlob)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(renamed))
+	assert.Equal(t, ADDED, renamed[2].Type)
+}
+
+func TestDiff_DetectRenamesLimit(t *testing.T) {
+	readBlob := func(sha string) ([]byte, error) {
+		return map[string][]byte{"1": []byte("a\nb\nc\n"), "2": []byte("a\nb\nd\n"), "3": []byte("x\ny\nz\n"), "4": []byte("x\ny\nw\n"), "5": []byte("same\n")}[sha], nil
+	}
+	changes := DiffFileMaps(
+		map[string]FileEntry{"a.txt": {Mode: "100644", Sha: "1"}, "x.txt": {Mode: "100644", Sha: "3"}, "same.txt": {Mode: "100644", Sha: "5"}},
+		map[string]FileEntry{"b.txt": {Mode: "100644", Sha: "2"}, "y.txt": {Mode: "100644", Sha: "4"}, "moved.txt": {Mode: "100644", Sha: "5"}},
+	)
+	options := DefaultRenameOptions()
+	options.RenameLimit = 2 //Exact renames are not counted, 2 sources x 2 destinations fits
+
+	renamed, err := DetectRenames(changes, options, readBlob)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(renamed))
+
+	options.RenameLimit = 1
+	stderr := os.Stderr
+	reader, writer, err := os.Pipe()
+	assert.Nil(t, err)
+	os.Stderr = writer
+	renamed, err = DetectRenames(changes, options, readBlob)
+	os.Stderr = stderr
+	assert.Nil(t, writer.Close())
+	warning, _ := io.ReadAll(reader)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"D\ta.txt", "A\tb.txt", "R100\tsame.txt\tmoved.txt", "D\tx.txt", "A\ty.txt"}, formatTestNameStatus(renamed))
+	assert.Equal(t, "warning: exhaustive rename detection was skipped due to too many files.\n"+
+		"warning: you may want to set your diff.renameLimit variable to at least 2 and retry the command.\n", string(warning))
+}
+
+func formatTestNameStatus(changes []Change) []string {
+	lines := make([]string, 0, len(changes))
+	for _, change := range changes {
+		lines = append(lines, FormatNameStatus(change))
+	}
+	return lines
+}
+
+func TestDiff_DetectCopies(t *testing.T) {
+	readBlob := func(sha string) ([]byte, error) {
+		return map[string][]byte{"1": []byte("a\nb\n"), "2": []byte("a\nb\nc\n"), "3": []byte("a\nb\n")}[sha], nil
+	}
+	changes := DiffFileMaps(
+		map[string]FileEntry{"original.txt": {Mode: "100644", Sha: "1"}},
+		map[string]FileEntry{"original.txt": {Mode: "100644", Sha: "2"}, "copy.txt": {Mode: "100644", Sha: "3"}},
+	)
+	options := DefaultRenameOptions()
+	options.DetectCopies = true
+
+	copied, err := DetectRenames(changes, options, readBlob)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(copied))
+	assert.Equal(t, "C100\toriginal.txt\tcopy.txt", FormatNameStatus(copied[0]))
+	assert.Equal(t, MODIFIED, copied[1].Type)
+}
+
+func TestDiff_UnifiedDiff(t *testing.T) {
+	oldContent := []byte("1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n")
+	newContent := []byte("1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\n11")
+
+	assert.Equal(t, "@@ -1,6 +1,6 @@\n 1\n 2\n-3\n+three\n 4\n 5\n 6\n"+
+		"@@ -8,3 +8,4 @@\n 8\n 9\n 10\n+11\n\\ No newline at end of file\n", UnifiedDiff(oldContent, newContent, 3))
+	assert.Equal(t, "", UnifiedDiff(oldContent, oldContent, 3))
+	assert.Equal(t, "@@ -0,0 +1 @@\n+a\n", UnifiedDiff([]byte(""), []byte("a\n"), 3))
+}
+
+func TestDiff_MapLines(t *testing.T) {
+	assert.Equal(t, []int{0, -1, 2, -1}, MapLines([]byte("a\nb\nc\n"), []byte("a\nx\nc\nd\n")))
+	assert.Equal(t, []string{"a", "b"}, SplitLines([]byte("a\nb")))
+}
+
+func TestDiff_FormatPatch(t *testing.T) {
+	change := Change{Type: RENAMED, OldPath: "a.txt", NewPath: "b.txt", Score: 66,
+		Old: FileEntry{Mode: "100644", Sha: "1111111111"}, New: FileEntry{Mode: "100755", Sha: "2222222222"}}
+
+	patch := FormatPatch(change, []byte("a\nb\nc\n"), []byte("a\nb\nd\n"))
+
+	assert.Equal(t, "diff --git a/a.txt b/b.txt\nold mode 100644\nnew mode 100755\nsimilarity index 66%\nrename from a.txt\nrename to b.txt\n"+
+		"index 1111111..2222222\n--- a/a.txt\n+++ b/b.txt\n@@ -1,3 +1,3 @@\n a\n b\n-c\n+d\n", patch)
+}
+
+func TestDiff_MergeContents(t *testing.T) {
+	base := []byte("1\n2\n3\n4\n5\n")
+
+	merged, clean := MergeContents(base, []byte("1\nours\n3\n4\n5\n"), []byte("1\n2\n3\n4\ntheirs\n6\n"), "HEAD", "topic")
+	assert.True(t, clean)
+	assert.Equal(t, "1\nours\n3\n4\ntheirs\n6\n", string(merged))
+
+	merged, clean = MergeContents(base, []byte("1\nsame\n3\n4\n5\n"), []byte("1\nsame\n3\n4\n5\n"), "HEAD", "topic")
+	assert.True(t, clean)
+	assert.Equal(t, "1\nsame\n3\n4\n5\n", string(merged))
+
+	merged, clean = MergeContents(base, []byte("1\nours\n3\n4\nfive\n"), []byte("1\ntheirs\n3\n4"), "HEAD", "topic")
+	assert.False(t, clean)
+	assert.Equal(t, "1\n<<<<<<< HEAD\nours\n=======\ntheirs\n>>>>>>> topic\n3\n<<<<<<< HEAD\n4\nfive\n=======\n4\n>>>>>>> topic\n", string(merged))
+
+	merged, clean = MergeContents([]byte(""), []byte("added\n"), []byte("other\n"), "HEAD", "topic")
+	assert.False(t, clean)
+	assert.Equal(t, "<<<<<<< HEAD\nadded\n=======\nother\n>>>>>>> topic\n", string(merged))
+}
@@ -0,0 +1,99 @@
+package diff
+
+import (
+	"bytes"
+	"strings"
+)
+
+const (
+	CONFLICT_MARKER_OURS   = "<<<<<<<"
+	CONFLICT_MARKER_SEP    = "======="
+	CONFLICT_MARKER_THEIRS = ">>>>>>>"
+)
+
+// MergeContents Three-way merge of the lines of both versions of a file (diff3). The regions between the lines that neither
+// side changed are taken from the side that changed them. If both sides changed a region differently it is written between
+// conflict markers with the labels, and the merge is not clean
+func MergeContents(base []byte, ours []byte, theirs []byte, oursLabel string, theirsLabel string) ([]byte, bool) {
+	baseLines, oursLines, theirsLines := splitLines(base), splitLines(ours), splitLines(theirs)
+	oursMatches := matchBaseLines(base, ours, len(baseLines))
+	theirsMatches := matchBaseLines(base, theirs, len(baseLines))
+
+	var result bytes.Buffer
+	clean := true
+	b, o, t := 0, 0, 0
+	for b < len(baseLines) || o < len(oursLines) || t < len(theirsLines) {
+		if b < len(baseLines) && oursMatches[b] == o && theirsMatches[b] == t { //Unchanged line
+			result.WriteString(baseLines[b])
+			b, o, t = b+1, o+1, t+1
+			continue
+		}
+
+		//The changed region ends at the next line kept by both sides
+		baseEnd, oursEnd, theirsEnd := len(baseLines), len(oursLines), len(theirsLines)
+		for i := b; i < len(baseLines); i++ {
+			if oursMatches[i] != -1 && theirsMatches[i] != -1 {
+				baseEnd, oursEnd, theirsEnd = i, oursMatches[i], theirsMatches[i]
+				break
+			}
+		}
+		baseRegion, oursRegion, theirsRegion := baseLines[b:baseEnd], oursLines[o:oursEnd], theirsLines[t:theirsEnd]
+
+		switch {
+		case equalLines(oursRegion, baseRegion):
+			writeLines(&result, theirsRegion)
+		case equalLines(theirsRegion, baseRegion) || equalLines(oursRegion, theirsRegion):
+			writeLines(&result, oursRegion)
+		default:
+			clean = false
+			result.WriteString(CONFLICT_MARKER_OURS + " " + oursLabel + "\n")
+			writeConflictLines(&result, oursRegion)
+			result.WriteString(CONFLICT_MARKER_SEP + "\n")
+			writeConflictLines(&result, theirsRegion)
+			result.WriteString(CONFLICT_MARKER_THEIRS + " " + theirsLabel + "\n")
+		}
+		b, o, t = baseEnd, oursEnd, theirsEnd
+	}
+
+	return result.Bytes(), clean
+}
+
+// Returns for every line of base the index of the same line in the other content, or -1 if it was removed
+func matchBaseLines(base []byte, other []byte, baseLength int) []int {
+	matches := make([]int, baseLength)
+	for i := range matches {
+		matches[i] = -1
+	}
+	for otherIndex, baseIndex := range MapLines(base, other) {
+		if baseIndex != -1 {
+			matches[baseIndex] = otherIndex
+		}
+	}
+	return matches
+}
+
+func equalLines(lines []string, otherLines []string) bool {
+	if len(lines) != len(otherLines) {
+		return false
+	}
+	for i, line := range lines {
+		if line != otherLines[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func writeLines(result *bytes.Buffer, lines []string) {
+	for _, line := range lines {
+		result.WriteString(line)
+	}
+}
+
+// The markers must start a line, even if the region is the end of a file without a trailing line break
+func writeConflictLines(result *bytes.Buffer, lines []string) {
+	writeLines(result, lines)
+	if len(lines) > 0 && !strings.HasSuffix(lines[len(lines)-1], "\n") {
+		result.WriteString("\n")
+	}
+}
@@ -0,0 +1,117 @@
+package diff
+
+import (
+	"strconv"
+	"strings"
+)
+
+const NULL_SHA = "0000000000000000000000000000000000000000"
+
+// FormatPatch Returns the change in git patch format (diff --git ...). Gitlinks are shown as "Subproject commit <sha>" lines
+func FormatPatch(change Change, oldContent []byte, newContent []byte) string {
+	return FormatPatchWithBinary(change, oldContent, newContent, IsBinary(oldContent) || IsBinary(newContent))
+}
+
+// FormatPatchWithBinary Same as FormatPatch, but binary decides if the content diff is replaced by "Binary files ... differ"
+func FormatPatchWithBinary(change Change, oldContent []byte, newContent []byte, binary bool) string {
+	var result strings.Builder
+	oldPath, newPath := change.OldPath, change.NewPath
+	if change.Type == ADDED {
+		oldPath = newPath
+	} else if change.Type == DELETED {
+		newPath = oldPath
+	}
+
+	result.WriteString("diff --git a/" + oldPath + " b/" + newPath + "\n")
+	writeExtendedHeaders(&result, change)
+
+	if change.Old.IsGitlink() {
+		oldContent = []byte("Subproject commit " + change.Old.Sha + "\n")
+	}
+	if change.New.IsGitlink() {
+		newContent = []byte("Subproject commit " + change.New.Sha + "\n")
+	}
+
+	if change.Old.Sha == change.New.Sha { //Pure renames, copies and mode changes dont have content diff
+		return result.String()
+	}
+
+	oldName, newName := "a/"+oldPath, "b/"+newPath
+	if change.Type == ADDED {
+		oldName = "/dev/null"
+	} else if change.Type == DELETED {
+		newName = "/dev/null"
+	}
+
+	if binary && !change.Old.IsGitlink() && !change.New.IsGitlink() {
+		result.WriteString("Binary files " + oldName + " and " + newName + " differ\n")
+		return result.String()
+	}
+
+	result.WriteString("--- " + oldName + "\n")
+	result.WriteString("+++ " + newName + "\n")
+	result.WriteString(UnifiedDiff(oldContent, newContent, DEFAULT_CONTEXT_LINES))
+
+	return result.String()
+}
+
+func writeExtendedHeaders(result *strings.Builder, change Change) {
+	switch change.Type {
+	case ADDED:
+		result.WriteString("new file mode " + change.New.Mode + "\n")
+	case DELETED:
+		result.WriteString("deleted file mode " + change.Old.Mode + "\n")
+	default:
+		if change.Old.Mode != change.New.Mode {
+			result.WriteString("old mode " + change.Old.Mode + "\n")
+			result.WriteString("new mode " + change.New.Mode + "\n")
+		}
+	}
+
+	if change.Type == RENAMED || change.Type == COPIED {
+		verb := "rename"
+		if change.Type == COPIED {
+			verb = "copy"
+		}
+		result.WriteString("similarity index " + strconv.Itoa(change.Score) + "%\n")
+		result.WriteString(verb + " from " + change.OldPath + "\n")
+		result.WriteString(verb + " to " + change.NewPath + "\n")
+	}
+
+	if change.Old.Sha == change.New.Sha {
+		return
+	}
+
+	indexLine := "index " + abbreviateSha(change.Old.Sha) + ".." + abbreviateSha(change.New.Sha)
+	if change.Old.Mode == change.New.Mode {
+		indexLine = indexLine + " " + change.New.Mode
+	}
+	result.WriteString(indexLine + "\n")
+}
+
+// FormatNameStatus Format: <status>\t<path> or <status><score>\t<old path>\t<new path> for renames and copies
+func FormatNameStatus(change Change) string {
+	if change.Type == RENAMED || change.Type == COPIED {
+		return string(change.Type) + formatScore(change.Score) + "\t" + change.OldPath + "\t" + change.NewPath
+	}
+
+	return string(change.Type) + "\t" + change.Path()
+}
+
+func formatScore(score int) string {
+	formatted := strconv.Itoa(score)
+	for len(formatted) < 3 {
+		formatted = "0" + formatted
+	}
+	return formatted
+}
+
+func abbreviateSha(sha string) string {
+	if sha == "" {
+		sha = NULL_SHA
+	}
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
@@ -0,0 +1,286 @@
+package diff
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	DEFAULT_SIMILARITY_THRESHOLD = 50
+	DEFAULT_RENAME_LIMIT         = 1000 //Same as diff.renameLimit in git
+)
+
+// BlobReader Returns the content of the blob with the given sha
+type BlobReader func(sha string) ([]byte, error)
+
+type RenameOptions struct {
+	DetectRenames   bool
+	RenameThreshold int //Minimum similarity (0-100) to consider a deleted and an added file a rename
+	DetectCopies    bool
+	CopyThreshold   int
+	RenameLimit     int //If sources x destinations exceeds RenameLimit², only exact renames are detected. 0 is unlimited
+}
+
+func DefaultRenameOptions() RenameOptions {
+	return RenameOptions{
+		DetectRenames:   true,
+		RenameThreshold: DEFAULT_SIMILARITY_THRESHOLD,
+		CopyThreshold:   DEFAULT_SIMILARITY_THRESHOLD,
+		RenameLimit:     DEFAULT_RENAME_LIMIT,
+	}
+}
+
+// ParseSimilarityThreshold Same format as git -M<n>: "90%" is 90, otherwise the digits are the decimal part of a fraction: "5" is 50, "05" is 5.
+// Empty value returns the default threshold
+func ParseSimilarityThreshold(value string) (int, error) {
+	if value == "" {
+		return DEFAULT_SIMILARITY_THRESHOLD, nil
+	}
+
+	if strings.HasSuffix(value, "%") {
+		percentage, err := strconv.Atoi(strings.TrimSuffix(value, "%"))
+		if err != nil || percentage < 0 || percentage > 100 {
+			return 0, errors.New("Invalid similarity threshold " + value)
+		}
+		return percentage, nil
+	}
+
+	fraction, err := strconv.ParseFloat("0."+value, 64)
+	if err != nil || strings.ContainsAny(value, ".-+") {
+		return 0, errors.New("Invalid similarity threshold " + value)
+	}
+
+	return int(fraction*100 + 0.5), nil
+}
+
+type renameCandidate struct {
+	addedIndex   int
+	deletedIndex int
+	score        int
+}
+
+// DetectRenames Pairs deleted and added files with the same content (exact sha) first, and then the most similar ones
+// whose similarity is at least the threshold. If copies are detected, added files are also compared with the old version
+// of modified and deleted files. Gitlinks are never paired. Returned changes are sorted by path.
+// Comparing contents is quadratic, so like git, if there are too many files for the rename limit only exact renames are
+// detected and a warning is printed
+func DetectRenames(changes []Change, options RenameOptions, readBlob BlobReader) ([]Change, error) {
+	if !options.DetectRenames && !options.DetectCopies {
+		return changes, nil
+	}
+
+	result := append(make([]Change, 0, len(changes)), changes...)
+	renamedDeleted := make(map[int]bool) //Index of deleted change -> already used as rename source
+	pairedAdded := make(map[int]bool)
+
+	if options.DetectRenames {
+		pairExactRenames(result, renamedDeleted, pairedAdded)
+	}
+
+	if sources, destinations := countRenameCandidates(result, options, renamedDeleted, pairedAdded); exceedsRenameLimit(sources, destinations, options.RenameLimit) {
+		required := sources
+		if destinations > sources {
+			required = destinations
+		}
+		fmt.Fprintln(os.Stderr, "warning: exhaustive rename detection was skipped due to too many files.")
+		fmt.Fprintln(os.Stderr, "warning: you may want to set your diff.renameLimit variable to at least "+strconv.Itoa(required)+" and retry the command.")
+	} else {
+		if err := detectSimilarRenamesAndCopies(result, options, readBlob, renamedDeleted, pairedAdded); err != nil {
+			return nil, err
+		}
+	}
+
+	withoutRenamedDeleted := make([]Change, 0, len(result))
+	for i, change := range result {
+		if !renamedDeleted[i] {
+			withoutRenamedDeleted = append(withoutRenamedDeleted, change)
+		}
+	}
+
+	sortChanges(withoutRenamedDeleted)
+
+	return withoutRenamedDeleted, nil
+}
+
+func detectSimilarRenamesAndCopies(result []Change, options RenameOptions, readBlob BlobReader, renamedDeleted map[int]bool, pairedAdded map[int]bool) error {
+	if options.DetectRenames {
+		if err := pairSimilarRenames(result, options.RenameThreshold, readBlob, renamedDeleted, pairedAdded); err != nil {
+			return err
+		}
+	}
+	if options.DetectCopies {
+		return pairCopies(result, options.CopyThreshold, readBlob, pairedAdded)
+	}
+
+	return nil
+}
+
+// Sources are the deleted files not renamed yet (and the modified ones if copies are detected), destinations the added
+// files not paired yet
+func countRenameCandidates(changes []Change, options RenameOptions, renamedDeleted map[int]bool, pairedAdded map[int]bool) (int, int) {
+	sources, destinations := 0, 0
+	for i, change := range changes {
+		switch {
+		case change.Type == ADDED && !change.New.IsGitlink() && !pairedAdded[i]:
+			destinations++
+		case change.Type == DELETED && !change.Old.IsGitlink() && !renamedDeleted[i]:
+			sources++
+		case change.Type == MODIFIED && !change.Old.IsGitlink() && options.DetectCopies:
+			sources++
+		}
+	}
+	return sources, destinations
+}
+
+func exceedsRenameLimit(sources int, destinations int, limit int) bool {
+	return limit > 0 && int64(sources)*int64(destinations) > int64(limit)*int64(limit)
+}
+
+func pairExactRenames(changes []Change, renamedDeleted map[int]bool, pairedAdded map[int]bool) {
+	deletedBySha := make(map[string][]int)
+	for i, change := range changes {
+		if change.Type == DELETED && !change.Old.IsGitlink() {
+			deletedBySha[change.Old.Sha] = append(deletedBySha[change.Old.Sha], i)
+		}
+	}
+
+	for i, change := range changes {
+		if change.Type != ADDED || change.New.IsGitlink() {
+			continue
+		}
+
+		for _, deletedIndex := range deletedBySha[change.New.Sha] {
+			if !renamedDeleted[deletedIndex] {
+				changes[i] = toRenameOrCopy(RENAMED, changes[deletedIndex], change, 100)
+				renamedDeleted[deletedIndex] = true
+				pairedAdded[i] = true
+				break
+			}
+		}
+	}
+}
+
+func pairSimilarRenames(changes []Change, threshold int, readBlob BlobReader, renamedDeleted map[int]bool, pairedAdded map[int]bool) error {
+	candidates := make([]renameCandidate, 0)
+	contents := make(map[string][]byte)
+
+	for addedIndex, added := range changes {
+		if added.Type != ADDED || added.New.IsGitlink() || pairedAdded[addedIndex] {
+			continue
+		}
+
+		for deletedIndex, deleted := range changes {
+			if deleted.Type != DELETED || deleted.Old.IsGitlink() || renamedDeleted[deletedIndex] {
+				continue
+			}
+
+			score, err := getSimilarity(deleted.Old.Sha, added.New.Sha, readBlob, contents)
+			if err != nil {
+				return err
+			}
+			if score >= threshold {
+				candidates = append(candidates, renameCandidate{addedIndex: addedIndex, deletedIndex: deletedIndex, score: score})
+			}
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].score > candidates[j].score
+	})
+
+	for _, candidate := range candidates {
+		if pairedAdded[candidate.addedIndex] || renamedDeleted[candidate.deletedIndex] {
+			continue
+		}
+
+		changes[candidate.addedIndex] = toRenameOrCopy(RENAMED, changes[candidate.deletedIndex], changes[candidate.addedIndex], candidate.score)
+		renamedDeleted[candidate.deletedIndex] = true
+		pairedAdded[candidate.addedIndex] = true
+	}
+
+	return nil
+}
+
+// Sources of copies are the old versions of modified, deleted and renamed files
+func pairCopies(changes []Change, threshold int, readBlob BlobReader, pairedAdded map[int]bool) error {
+	contents := make(map[string][]byte)
+	sources := make([]Change, 0)
+	for _, change := range changes {
+		if (change.Type == MODIFIED || change.Type == DELETED) && !change.Old.IsGitlink() {
+			sources = append(sources, change)
+		} else if change.Type == RENAMED {
+			sources = append(sources, Change{Type: DELETED, OldPath: change.OldPath, Old: change.Old})
+		}
+	}
+
+	for addedIndex, added := range changes {
+		if added.Type != ADDED || added.New.IsGitlink() || pairedAdded[addedIndex] {
+			continue
+		}
+
+		bestScore, bestSource := -1, Change{}
+		for _, source := range sources {
+			score, err := getSimilarity(source.Old.Sha, added.New.Sha, readBlob, contents)
+			if err != nil {
+				return err
+			}
+			if score >= threshold && score > bestScore {
+				bestScore, bestSource = score, source
+			}
+		}
+
+		if bestScore != -1 {
+			changes[addedIndex] = toRenameOrCopy(COPIED, bestSource, added, bestScore)
+			pairedAdded[addedIndex] = true
+		}
+	}
+
+	return nil
+}
+
+func toRenameOrCopy(changeType ChangeType, source Change, added Change, score int) Change {
+	return Change{
+		Type:    changeType,
+		OldPath: source.OldPath,
+		NewPath: added.NewPath,
+		Old:     source.Old,
+		New:     added.New,
+		Score:   score,
+	}
+}
+
+// Contents are cached by sha since every file is compared with multiple candidates
+func getSimilarity(oldSha string, newSha string, readBlob BlobReader, contents map[string][]byte) (int, error) {
+	if oldSha == newSha {
+		return 100, nil
+	}
+
+	oldContent, err := readCachedBlob(oldSha, readBlob, contents)
+	if err != nil {
+		return 0, err
+	}
+	newContent, err := readCachedBlob(newSha, readBlob, contents)
+	if err != nil {
+		return 0, err
+	}
+
+	return Similarity(oldContent, newContent), nil
+}
+
+func readCachedBlob(sha string, readBlob BlobReader, contents map[string][]byte) ([]byte, error) {
+	if content, cached := contents[sha]; cached {
+		return content, nil
+	}
+
+	content, err := readBlob(sha)
+	if err != nil {
+		return nil, err
+	}
+	contents[sha] = content
+
+	return content, nil
+}
@@ -0,0 +1,45 @@
+package diff
+
+import "bytes"
+
+// Similarity Returns the percentage (0-100) of content shared by a and b relative to the biggest of them.
+// Content is compared by lines, which are weighted by their length, regardless of their order
+func Similarity(a []byte, b []byte) int {
+	maxSize := len(a)
+	if len(b) > maxSize {
+		maxSize = len(b)
+	}
+	if maxSize == 0 {
+		return 100
+	}
+
+	linesCountA := countLines(a)
+	commonBytes := 0
+
+	for line, countB := range countLines(b) {
+		countA := linesCountA[line]
+		if countB < countA {
+			countA = countB
+		}
+		commonBytes = commonBytes + countA*len(line)
+	}
+
+	return commonBytes * 100 / maxSize
+}
+
+// Line (with its line break) -> number of times it appears
+func countLines(content []byte) map[string]int {
+	counts := make(map[string]int)
+
+	for len(content) > 0 {
+		lineEnd := bytes.IndexByte(content, '\n') + 1
+		if lineEnd == 0 {
+			lineEnd = len(content)
+		}
+
+		counts[string(content[:lineEnd])]++
+		content = content[lineEnd:]
+	}
+
+	return counts
+}
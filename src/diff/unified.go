@@ -0,0 +1,239 @@
+package diff
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+)
+
+const DEFAULT_CONTEXT_LINES = 3
+
+type lineOperation struct {
+	kind byte //' ' kept, '-' removed, '+' added
+	line string
+}
+
+// UnifiedDiff Returns the hunks (@@ -a,b +c,d @@) of the line diff between both contents. Empty if they are equal
+func UnifiedDiff(oldContent []byte, newContent []byte, contextLines int) string {
+	operations := diffLines(splitLines(oldContent), splitLines(newContent))
+	var result strings.Builder
+
+	for start := 0; start < len(operations); {
+		firstChange := findNextChange(operations, start)
+		if firstChange == -1 {
+			break
+		}
+
+		hunkStart := firstChange - contextLines
+		if hunkStart < start {
+			hunkStart = start
+		}
+		hunkEnd := findHunkEnd(operations, firstChange, contextLines)
+
+		writeHunk(&result, operations, hunkStart, hunkEnd)
+		start = hunkEnd
+	}
+
+	return result.String()
+}
+
+// MapLines Returns for every line of newContent the index of the same line in oldContent, or -1 if it was added
+func MapLines(oldContent []byte, newContent []byte) []int {
+	mapping := make([]int, 0)
+	oldIndex := 0
+
+	for _, operation := range diffLines(splitLines(oldContent), splitLines(newContent)) {
+		switch operation.kind {
+		case ' ':
+			mapping = append(mapping, oldIndex)
+			oldIndex++
+		case '-':
+			oldIndex++
+		case '+':
+			mapping = append(mapping, -1)
+		}
+	}
+
+	return mapping
+}
+
+// SplitLines Returns the lines of the content without their line break
+func SplitLines(content []byte) []string {
+	lines := splitLines(content)
+	for i, line := range lines {
+		lines[i] = strings.TrimSuffix(line, "\n")
+	}
+	return lines
+}
+
+// IsBinary Same heuristic as git: the content has a NUL byte in its first 8000 bytes
+func IsBinary(content []byte) bool {
+	if len(content) > 8000 {
+		content = content[:8000]
+	}
+	return bytes.IndexByte(content, 0) != -1
+}
+
+func findNextChange(operations []lineOperation, from int) int {
+	for i := from; i < len(operations); i++ {
+		if operations[i].kind != ' ' {
+			return i
+		}
+	}
+	return -1
+}
+
+// Hunks are merged while the unchanged lines between changes are at most 2 * contextLines
+func findHunkEnd(operations []lineOperation, firstChange int, contextLines int) int {
+	lastChange := firstChange
+
+	for i := firstChange + 1; i < len(operations); i++ {
+		if operations[i].kind != ' ' {
+			if i-lastChange-1 > 2*contextLines {
+				break
+			}
+			lastChange = i
+		}
+	}
+
+	hunkEnd := lastChange + 1 + contextLines
+	if hunkEnd > len(operations) {
+		hunkEnd = len(operations)
+	}
+	return hunkEnd
+}
+
+func writeHunk(result *strings.Builder, operations []lineOperation, hunkStart int, hunkEnd int) {
+	oldStart, newStart := 1, 1
+	for _, operation := range operations[:hunkStart] {
+		if operation.kind != '+' {
+			oldStart++
+		}
+		if operation.kind != '-' {
+			newStart++
+		}
+	}
+
+	oldLength, newLength := 0, 0
+	for _, operation := range operations[hunkStart:hunkEnd] {
+		if operation.kind != '+' {
+			oldLength++
+		}
+		if operation.kind != '-' {
+			newLength++
+		}
+	}
+
+	result.WriteString("@@ -" + formatHunkRange(oldStart, oldLength) + " +" + formatHunkRange(newStart, newLength) + " @@\n")
+
+	for _, operation := range operations[hunkStart:hunkEnd] {
+		result.WriteByte(operation.kind)
+		result.WriteString(operation.line)
+		if !strings.HasSuffix(operation.line, "\n") {
+			result.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+// Empty ranges start at the line before them
+func formatHunkRange(start int, length int) string {
+	if length == 0 {
+		return strconv.Itoa(start-1) + ",0"
+	}
+	if length == 1 {
+		return strconv.Itoa(start)
+	}
+	return strconv.Itoa(start) + "," + strconv.Itoa(length)
+}
+
+// Lines keep their line break, so a missing one at the end of the file is detected as a change
+func splitLines(content []byte) []string {
+	lines := make([]string, 0)
+
+	for len(content) > 0 {
+		lineEnd := bytes.IndexByte(content, '\n') + 1
+		if lineEnd == 0 {
+			lineEnd = len(content)
+		}
+
+		lines = append(lines, string(content[:lineEnd]))
+		content = content[lineEnd:]
+	}
+
+	return lines
+}
+
+// Myers algorithm. Finds the shortest edit script by exploring diagonals k = x - y with d edits,
+// keeping every step to recover the path backwards
+func diffLines(oldLines []string, newLines []string) []lineOperation {
+	n, m := len(oldLines), len(newLines)
+	max := n + m
+	offset := max + 1
+	furthest := make([]int, 2*max+2) //Furthest x reached in every diagonal
+	trace := make([][]int, 0)
+
+	for d := 0; d <= max; d++ {
+		trace = append(trace, append([]int(nil), furthest...))
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && furthest[offset+k-1] < furthest[offset+k+1]) {
+				x = furthest[offset+k+1] //Insertion
+			} else {
+				x = furthest[offset+k-1] + 1 //Deletion
+			}
+			y := x - k
+
+			for x < n && y < m && oldLines[x] == newLines[y] {
+				x, y = x+1, y+1
+			}
+			furthest[offset+k] = x
+
+			if x >= n && y >= m {
+				return backtrackEdits(trace, oldLines, newLines, d, offset)
+			}
+		}
+	}
+
+	return nil
+}
+
+func backtrackEdits(trace [][]int, oldLines []string, newLines []string, editDistance int, offset int) []lineOperation {
+	operations := make([]lineOperation, 0)
+	x, y := len(oldLines), len(newLines)
+
+	for d := editDistance; d >= 0; d-- {
+		furthest := trace[d]
+		k := x - y
+
+		var prevK int
+		if k == -d || (k != d && furthest[offset+k-1] < furthest[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := furthest[offset+prevK]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			operations = append(operations, lineOperation{kind: ' ', line: oldLines[x-1]})
+			x, y = x-1, y-1
+		}
+
+		if d > 0 {
+			if x == prevX {
+				operations = append(operations, lineOperation{kind: '+', line: newLines[y-1]})
+			} else {
+				operations = append(operations, lineOperation{kind: '-', line: oldLines[x-1]})
+			}
+		}
+
+		x, y = prevX, prevY
+	}
+
+	for i, j := 0, len(operations)-1; i < j; i, j = i+1, j-1 {
+		operations[i], operations[j] = operations[j], operations[i]
+	}
+
+	return operations
+}
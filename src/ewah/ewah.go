@@ -0,0 +1,138 @@
+package ewah
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// EWAH compressed bitmap. Words are groups of 64 bits, the bit i of the bitmap is the bit i%64 of the word i/64.
+// The compressed words are a sequence of: <marker word> <literal words...>
+// Marker word: <literal words count: 31 bits><run length: 32 bits><run bit: 1 bit>. The run is a sequence of words with
+// every bit equal to the run bit, followed by the literal words, which are copied as they are
+// Serialized format: <bit size uint32> <words count uint32> <words N * uint64> <position of the last marker word uint32>
+
+const (
+	maxRunLength     = 1<<32 - 1
+	maxLiteralLength = 1<<31 - 1
+)
+
+type Bitmap struct {
+	BitSize int
+	Words   []uint64 //Compressed words
+}
+
+// Compress bits has a word for every 64 bits of bitSize
+func Compress(bits []uint64, bitSize int) *Bitmap {
+	words := make([]uint64, 0)
+	for i := 0; i < len(bits); {
+		runBit, runLength := uint64(0), 0
+		if isCleanWord(bits[i]) {
+			runBit = bits[i] & 1
+			for i < len(bits) && bits[i] == -runBit && runLength < maxRunLength {
+				i++
+				runLength++
+			}
+		}
+
+		literalsStart := i
+		for i < len(bits) && !isCleanWord(bits[i]) && i-literalsStart < maxLiteralLength {
+			i++
+		}
+
+		words = append(words, uint64(i-literalsStart)<<33|uint64(runLength)<<1|runBit)
+		words = append(words, bits[literalsStart:i]...)
+	}
+	if len(words) == 0 {
+		words = append(words, 0)
+	}
+
+	return &Bitmap{BitSize: bitSize, Words: words}
+}
+
+func isCleanWord(word uint64) bool {
+	return word == 0 || word == ^uint64(0)
+}
+
+// Decompress Returns a word for every 64 bits of the bitmap. Bits after BitSize are not set
+func (b *Bitmap) Decompress() []uint64 {
+	bits := make([]uint64, 0, (b.BitSize+63)/64)
+	b.eachMarker(func(runBit uint64, runLength int, literals []uint64) {
+		for j := 0; j < runLength; j++ {
+			bits = append(bits, -runBit)
+		}
+		bits = append(bits, literals...)
+	})
+
+	wordsCount := (b.BitSize + 63) / 64
+	for len(bits) < wordsCount {
+		bits = append(bits, 0)
+	}
+	bits = bits[:wordsCount]
+	if remainder := b.BitSize % 64; remainder != 0 {
+		bits[wordsCount-1] &= 1<<remainder - 1
+	}
+	return bits
+}
+
+// OrInto Sets in bits every bit set in the bitmap. Words after the end of bits are ignored
+func (b *Bitmap) OrInto(bits []uint64) {
+	position := 0
+	b.eachMarker(func(runBit uint64, runLength int, literals []uint64) {
+		for j := position; runBit == 1 && j < position+runLength && j < len(bits); j++ {
+			bits[j] = ^uint64(0)
+		}
+		position += runLength
+		for j, literal := range literals {
+			if position+j < len(bits) {
+				bits[position+j] |= literal
+			}
+		}
+		position += len(literals)
+	})
+}
+
+func (b *Bitmap) eachMarker(callback func(runBit uint64, runLength int, literals []uint64)) {
+	for i := 0; i < len(b.Words); {
+		marker := b.Words[i]
+		literalsCount := int(marker >> 33)
+		literalsEnd := i + 1 + literalsCount
+		if literalsEnd > len(b.Words) {
+			literalsEnd = len(b.Words)
+		}
+		callback(marker&1, int(marker>>1&maxRunLength), b.Words[i+1:literalsEnd])
+		i = literalsEnd
+	}
+}
+
+func (b *Bitmap) Serialize() []byte {
+	result := binary.BigEndian.AppendUint32(nil, uint32(b.BitSize))
+	result = binary.BigEndian.AppendUint32(result, uint32(len(b.Words)))
+
+	lastMarker := 0
+	for i := 0; i < len(b.Words); i += 1 + int(b.Words[i]>>33) {
+		lastMarker = i
+	}
+	for _, word := range b.Words {
+		result = binary.BigEndian.AppendUint64(result, word)
+	}
+	return binary.BigEndian.AppendUint32(result, uint32(lastMarker))
+}
+
+// Deserialize Returns the bitmap and the number of bytes read
+func Deserialize(content []byte) (*Bitmap, int, error) {
+	if len(content) < 8 {
+		return nil, 0, errors.New("EWAH bitmap is truncated")
+	}
+	bitSize := int(binary.BigEndian.Uint32(content))
+	wordsCount := int(binary.BigEndian.Uint32(content[4:]))
+	length := 8 + wordsCount*8 + 4
+	if wordsCount < 0 || len(content) < length {
+		return nil, 0, errors.New("EWAH bitmap is truncated")
+	}
+
+	words := make([]uint64, wordsCount)
+	for i := range words {
+		words[i] = binary.BigEndian.Uint64(content[8+i*8:])
+	}
+	return &Bitmap{BitSize: bitSize, Words: words}, length, nil
+}
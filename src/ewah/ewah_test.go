@@ -0,0 +1,43 @@
+package ewah
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEwah_CompressAndDecompress(t *testing.T) {
+	bits := make([]uint64, 200)
+	for i := 10; i < 150; i++ {
+		bits[i] = ^uint64(0)
+	}
+	bits[3], bits[160], bits[199] = 0x5, 0xff00, 0x1
+
+	bitmap := Compress(bits, 200*64-10)
+
+	assert.Equal(t, []uint64{1<<33 | 3<<1, 0x5, 6 << 1, 140<<1 | 1, 1<<33 | 10<<1, 0xff00, 1<<33 | 38<<1, 0x1}, bitmap.Words)
+	assert.Equal(t, bits, bitmap.Decompress())
+
+	serialized := bitmap.Serialize()
+	deserialized, length, err := Deserialize(append(serialized, 0xaa))
+	assert.Nil(t, err)
+	assert.Equal(t, len(serialized), length)
+	assert.Equal(t, bitmap, deserialized)
+	assert.Equal(t, uint32(6), uint32(serialized[len(serialized)-1])) //Last marker word
+}
+
+func TestEwah_OrInto(t *testing.T) {
+	bitmap := Compress([]uint64{0, ^uint64(0), 0x10}, 3*64)
+	bits := []uint64{0x1, 0x2, 0x1}
+
+	bitmap.OrInto(bits)
+
+	assert.Equal(t, []uint64{0x1, ^uint64(0), 0x11}, bits)
+}
+
+func TestEwah_Empty(t *testing.T) {
+	bitmap := Compress([]uint64{}, 0)
+
+	assert.Equal(t, []uint64{0}, bitmap.Words)
+	assert.Equal(t, []uint64{}, bitmap.Decompress())
+}
@@ -13,9 +13,17 @@ type GitIgnore struct {
 	ignoredRules []string
 }
 
+// IsIgnored fileName is relative to the directory of the .gitignore. Rules without "/" are matched against the name of the file,
+// the rest against the whole path
 func (i *GitIgnore) IsIgnored(fileName string) (bool, error) {
 	for _, ignoredRule := range i.ignoredRules {
-		matchesIgnoreRule, err := filepath.Match(ignoredRule, fileName)
+		ignoredRule = strings.TrimSuffix(ignoredRule, "/")
+		toMatch := fileName
+		if !strings.Contains(ignoredRule, "/") {
+			toMatch = filepath.Base(fileName)
+		}
+
+		matchesIgnoreRule, err := filepath.Match(strings.TrimPrefix(ignoredRule, "/"), toMatch)
 		if err != nil {
 			return false, errors.New("Error while matching gitignore with " + fileName + " with pattern" + ignoredRule)
 		}
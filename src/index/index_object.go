@@ -2,14 +2,17 @@ package index
 
 import (
 	"encoding/binary"
+	"errors"
 	"io"
 	"io/ioutil"
 	"os"
+	"strconv"
 )
 
 type IndexObject struct {
 	Version uint32
 	Entries map[string]IndexEntry
+	ModTime uint64 //Nanoseconds. Modification time of the index file when it was read. Not serialized
 }
 
 type IndexEntry struct {
@@ -24,39 +27,121 @@ type IndexEntry struct {
 	Fsize        uint32
 	Sha          string
 	FullPathName string
+	SkipWorktree bool //Not checked out because of sparse checkout. Status ignores the file
 }
 
+const (
+	MODE_TYPE_REGULAR = 0x08 //0b1000
+	MODE_TYPE_SYMLINK = 0x0A //0b1010 Blob content is the target of the link
+	MODE_TYPE_GITLINK = 0x0E //0b1110 Commit of a submodule
+
+	MODE_PERMS_REGULAR    = 0644
+	MODE_PERMS_EXECUTABLE = 0755
+)
+
+// EXTENDED_FLAGS_VERSION Since this version entries have 16 bits of flags after the sha, like git index v3.
+// Indexes are written with it only if some entry has flags
+const EXTENDED_FLAGS_VERSION = 3
+
+const FLAG_SKIP_WORKTREE = 0x4000
+
+// CreateIndexEntry Mode type and perms are taken from stats, so it should be obtained with os.Lstat to detect symlinks
 func CreateIndexEntry(stats os.FileInfo, pathRelativeRepo string, sha string) IndexEntry {
+	modeType, modePerms := getModeFromFileInfo(stats)
+	statData := getStatData(stats)
+
 	return IndexEntry{
-		Ctime:        uint64(stats.ModTime().UnixNano()),
-		Mtime:        uint64(stats.ModTime().UnixNano()),
-		Dev:          0,
-		Ino:          0,
-		ModeType:     0x08,
-		ModePerms:    0x01A,
-		Uid:          1,
-		Gid:          1,
-		Fsize:        uint32(stats.Size()),
+		Ctime:        statData.ctime,
+		Mtime:        statData.mtime,
+		Dev:          statData.dev,
+		Ino:          statData.ino,
+		ModeType:     modeType,
+		ModePerms:    modePerms,
+		Uid:          statData.uid,
+		Gid:          statData.gid,
+		Fsize:        statData.size,
 		Sha:          sha,
 		FullPathName: pathRelativeRepo,
 	}
 }
 
+// Git only keeps the executable bit of regular files
+func getModeFromFileInfo(stats os.FileInfo) (uint32, uint32) {
+	if stats.Mode()&os.ModeSymlink != 0 {
+		return MODE_TYPE_SYMLINK, 0
+	}
+	if stats.Mode().Perm()&0111 != 0 {
+		return MODE_TYPE_REGULAR, MODE_PERMS_EXECUTABLE
+	}
+
+	return MODE_TYPE_REGULAR, MODE_PERMS_REGULAR
+}
+
+// CreateGitlinkIndexEntry sha is the commit checked out in the submodule
+func CreateGitlinkIndexEntry(pathRelativeRepo string, sha string) IndexEntry {
+	return IndexEntry{
+		ModeType:     MODE_TYPE_GITLINK,
+		ModePerms:    0,
+		Sha:          sha,
+		FullPathName: pathRelativeRepo,
+	}
+}
+
+func (self *IndexEntry) IsGitlink() bool {
+	return self.ModeType == MODE_TYPE_GITLINK
+}
+
+func (self *IndexEntry) IsSymlink() bool {
+	return self.ModeType == MODE_TYPE_SYMLINK
+}
+
+func (self *IndexEntry) IsExecutable() bool {
+	return self.ModeType == MODE_TYPE_REGULAR && self.ModePerms == MODE_PERMS_EXECUTABLE
+}
+
+// Mode Returns the mode in octal used in tree entries. Example: 100644, 100755, 120000, 160000
+func (self *IndexEntry) Mode() string {
+	modePerms := self.ModePerms
+	if self.ModeType == MODE_TYPE_REGULAR && !self.IsExecutable() { //Old entries were written with invalid perms
+		modePerms = MODE_PERMS_REGULAR
+	}
+
+	return strconv.FormatUint(uint64(self.ModeType<<12|modePerms), 8)
+}
+
+// SetMode mode is in octal, as stored in tree entries
+func (self *IndexEntry) SetMode(mode string) error {
+	modeValue, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil {
+		return errors.New("Invalid mode " + mode + " for " + self.FullPathName)
+	}
+
+	self.ModeType = uint32(modeValue) >> 12
+	self.ModePerms = uint32(modeValue) & 0x1FF
+	return nil
+}
+
 func (self *IndexObject) Serialize() []byte {
 	bytes := make([]byte, 0)
+	version := self.Version
+	for _, entry := range self.Entries {
+		if entry.SkipWorktree && version < EXTENDED_FLAGS_VERSION {
+			version = EXTENDED_FLAGS_VERSION
+		}
+	}
 
-	bytes = binary.BigEndian.AppendUint32(bytes, self.Version)
+	bytes = binary.BigEndian.AppendUint32(bytes, version)
 	bytes = binary.BigEndian.AppendUint32(bytes, uint32(len(self.Entries)))
 
 	for _, entry := range self.Entries {
-		serializedEntryBytes := entry.Serialize()
+		serializedEntryBytes := entry.Serialize(version)
 		bytes = append(bytes, serializedEntryBytes...)
 	}
 
 	return bytes
 }
 
-func (self *IndexEntry) Serialize() []byte {
+func (self *IndexEntry) Serialize(version uint32) []byte {
 	bytes := make([]byte, 0)
 
 	bytes = binary.BigEndian.AppendUint64(bytes, self.Ctime)
@@ -70,6 +155,13 @@ func (self *IndexEntry) Serialize() []byte {
 	bytes = binary.BigEndian.AppendUint32(bytes, self.Fsize)
 
 	bytes = append(bytes, []byte(self.Sha)...)
+	if version >= EXTENDED_FLAGS_VERSION {
+		flags := uint16(0)
+		if self.SkipWorktree {
+			flags |= FLAG_SKIP_WORKTREE
+		}
+		bytes = binary.BigEndian.AppendUint16(bytes, flags)
+	}
 
 	bytes = binary.BigEndian.AppendUint16(bytes, uint16(len(self.FullPathName)))
 	bytes = append(bytes, []byte(self.FullPathName)...)
@@ -87,6 +179,9 @@ func Deserialize(reader io.Reader) (*IndexObject, error) {
 		return &IndexObject{Version: 0, Entries: make(map[string]IndexEntry)}, nil
 	}
 
+	if len(allBytes) < 8 {
+		return nil, errors.New("index file is truncated")
+	}
 	version := binary.BigEndian.Uint32(allBytes[:4])
 	count := binary.BigEndian.Uint32(allBytes[4:8])
 
@@ -94,8 +189,17 @@ func Deserialize(reader io.Reader) (*IndexObject, error) {
 	content := allBytes[8:]
 	offset := 0
 
+	flagsLength := 0
+	if version >= EXTENDED_FLAGS_VERSION {
+		flagsLength = 2
+	}
+
 	for i := 0; i < int(count); i++ {
-		entry, newOffset := deserializeIndexEntry(content, offset)
+		nameOffset := offset + 80 + flagsLength
+		if nameOffset+2 > len(content) || nameOffset+2+int(binary.BigEndian.Uint16(content[nameOffset:nameOffset+2])) > len(content) {
+			return nil, errors.New("index entry " + strconv.Itoa(i) + " is truncated or has an unknown format")
+		}
+		entry, newOffset := deserializeIndexEntry(content, offset, flagsLength > 0)
 		offset = newOffset
 		entries[entry.FullPathName] = entry
 	}
@@ -103,7 +207,7 @@ func Deserialize(reader io.Reader) (*IndexObject, error) {
 	return &IndexObject{Version: version, Entries: entries}, nil
 }
 
-func deserializeIndexEntry(content []byte, offset int) (IndexEntry, int) {
+func deserializeIndexEntry(content []byte, offset int, hasFlags bool) (IndexEntry, int) {
 	ctime := binary.BigEndian.Uint64(content[offset : offset+8])
 	mtime := binary.BigEndian.Uint64(content[offset+8 : offset+16])
 
@@ -116,6 +220,11 @@ func deserializeIndexEntry(content []byte, offset int) (IndexEntry, int) {
 	gid := binary.BigEndian.Uint32(content[offset+32 : offset+36])
 	fsize := binary.BigEndian.Uint32(content[offset+36 : offset+40])
 	sha := string(content[offset+40 : offset+80])
+	flags := uint16(0)
+	if hasFlags {
+		flags = binary.BigEndian.Uint16(content[offset+80 : offset+82])
+		offset += 2
+	}
 	nameLength := binary.BigEndian.Uint16(content[offset+80 : offset+82])
 	name := string(content[offset+82 : offset+82+int(nameLength)])
 
@@ -133,5 +242,6 @@ func deserializeIndexEntry(content []byte, offset int) (IndexEntry, int) {
 		Fsize:        fsize,
 		Sha:          sha,
 		FullPathName: name,
+		SkipWorktree: flags&FLAG_SKIP_WORKTREE != 0,
 	}, offset
 }
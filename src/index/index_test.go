@@ -1,6 +1,8 @@
 package index
 
 import (
+	"bytes"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -56,3 +58,46 @@ func TestIndex_ToTree(t *testing.T) {
 	assert.Equal(t, childSrcCasaAlgo.Entry.FullPathName, "src/casa/algo.go")
 	assert.Equal(t, len(childSrcCasaAlgo.Children), 0)
 }
+
+func TestIndexEntry_Mode(t *testing.T) {
+	regular := IndexEntry{ModeType: MODE_TYPE_REGULAR, ModePerms: MODE_PERMS_REGULAR}
+	executable := IndexEntry{ModeType: MODE_TYPE_REGULAR, ModePerms: MODE_PERMS_EXECUTABLE}
+	symlink := IndexEntry{ModeType: MODE_TYPE_SYMLINK}
+	gitlink := CreateGitlinkIndexEntry("lib", "")
+
+	assert.Equal(t, "100644", regular.Mode())
+	assert.Equal(t, "100755", executable.Mode())
+	assert.Equal(t, "120000", symlink.Mode())
+	assert.Equal(t, "160000", gitlink.Mode())
+	assert.True(t, executable.IsExecutable())
+	assert.False(t, regular.IsExecutable())
+
+	entry := IndexEntry{}
+	assert.Nil(t, entry.SetMode("100755"))
+	assert.Equal(t, executable.ModeType, entry.ModeType)
+	assert.Equal(t, executable.ModePerms, entry.ModePerms)
+	assert.Nil(t, entry.SetMode("120000"))
+	assert.True(t, entry.IsSymlink())
+	assert.NotNil(t, entry.SetMode("abc"))
+}
+
+func TestIndexObject_SkipWorktree(t *testing.T) {
+	indexObject := IndexObject{Entries: map[string]IndexEntry{
+		"a.txt":     {ModeType: MODE_TYPE_REGULAR, ModePerms: MODE_PERMS_REGULAR, Sha: strings.Repeat("a", 40), FullPathName: "a.txt"},
+		"dir/b.txt": {ModeType: MODE_TYPE_REGULAR, ModePerms: MODE_PERMS_REGULAR, Sha: strings.Repeat("b", 40), FullPathName: "dir/b.txt"},
+	}}
+
+	deserialized, err := Deserialize(bytes.NewReader(indexObject.Serialize()))
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(0), deserialized.Version)
+	assert.Equal(t, indexObject.Entries, deserialized.Entries)
+
+	entry := indexObject.Entries["dir/b.txt"]
+	entry.SkipWorktree = true
+	indexObject.Entries["dir/b.txt"] = entry
+	deserialized, err = Deserialize(bytes.NewReader(indexObject.Serialize()))
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(EXTENDED_FLAGS_VERSION), deserialized.Version)
+	assert.Equal(t, indexObject.Entries, deserialized.Entries)
+	assert.False(t, deserialized.Entries["a.txt"].SkipWorktree)
+}
@@ -0,0 +1,39 @@
+package index
+
+import "os"
+
+// Stat info stored in the index to detect changes in the work tree without reading the files
+type statData struct {
+	ctime uint64 //Nanoseconds
+	mtime uint64 //Nanoseconds
+	dev   uint32
+	ino   uint32
+	uid   uint32
+	gid   uint32
+	size  uint32
+}
+
+// Used when the platform doesnt expose the full stat info. Ctime is not available, so mtime is used
+func getStatDataFromFileInfo(stats os.FileInfo) statData {
+	return statData{
+		ctime: uint64(stats.ModTime().UnixNano()),
+		mtime: uint64(stats.ModTime().UnixNano()),
+		size:  uint32(stats.Size()),
+	}
+}
+
+// MatchesStat Returns true if the stat info stored in the entry is the same as the file one. The mode is not compared
+func (self *IndexEntry) MatchesStat(stats os.FileInfo) bool {
+	actual := getStatData(stats)
+
+	return self.Ctime == actual.ctime && self.Mtime == actual.mtime &&
+		self.Dev == actual.dev && self.Ino == actual.ino &&
+		self.Uid == actual.uid && self.Gid == actual.gid &&
+		self.Fsize == actual.size
+}
+
+// IsRacy Returns true if the file might have been modified in the same instant the index was written, after the entry
+// was stored. In that case, the stat info cannot be trusted and the file has to be hashed
+func (self *IndexEntry) IsRacy(indexModTime uint64) bool {
+	return indexModTime == 0 || self.Mtime >= indexModTime
+}
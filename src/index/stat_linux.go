@@ -0,0 +1,25 @@
+//go:build linux
+
+package index
+
+import (
+	"os"
+	"syscall"
+)
+
+func getStatData(stats os.FileInfo) statData {
+	sys, ok := stats.Sys().(*syscall.Stat_t)
+	if !ok {
+		return getStatDataFromFileInfo(stats)
+	}
+
+	return statData{
+		ctime: uint64(sys.Ctim.Sec)*1e9 + uint64(sys.Ctim.Nsec),
+		mtime: uint64(sys.Mtim.Sec)*1e9 + uint64(sys.Mtim.Nsec),
+		dev:   uint32(sys.Dev),
+		ino:   uint32(sys.Ino),
+		uid:   sys.Uid,
+		gid:   sys.Gid,
+		size:  uint32(sys.Size),
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package index
+
+import "os"
+
+func getStatData(stats os.FileInfo) statData {
+	return getStatDataFromFileInfo(stats)
+}
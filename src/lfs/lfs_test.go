@@ -0,0 +1,46 @@
+package lfs
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLfs_Pointer(t *testing.T) {
+	pointer := CreatePointer([]byte("content\n"))
+	serialized := pointer.Serialize()
+
+	assert.Equal(t, "version https://git-lfs.github.com/spec/v1\noid sha256:"+
+		"434728a410a78f56fc1b5899c3593436e61ab0c731e9072d95e96db290205e53\nsize 8\n", string(serialized))
+	parsed, isPointer := ParsePointer(serialized)
+	assert.True(t, isPointer)
+	assert.Equal(t, pointer, parsed)
+
+	_, isPointer = ParsePointer([]byte("version https://git-lfs.github.com/spec/v1\nsize 8\noid sha256:" + pointer.Oid + "\n"))
+	assert.False(t, isPointer)
+	_, isPointer = ParsePointer([]byte("version https://git-lfs.github.com/spec/v1\noid sha256:abc\nsize 8\n"))
+	assert.False(t, isPointer)
+	_, isPointer = ParsePointer([]byte("content\n"))
+	assert.False(t, isPointer)
+}
+
+func TestLfs_Store(t *testing.T) {
+	local, remote := Store{Dir: t.TempDir()}, Store{Dir: t.TempDir()}
+
+	pointer, err := remote.Write([]byte("large"))
+	assert.Nil(t, err)
+	assert.True(t, remote.Has(pointer))
+	assert.False(t, local.Has(pointer))
+
+	assert.Nil(t, local.Fetch(remote, pointer))
+	content, err := local.Read(pointer)
+	assert.Nil(t, err)
+	assert.Equal(t, "large", string(content))
+
+	assert.Nil(t, os.WriteFile(local.Path(pointer.Oid), []byte("LARGE"), 0644))
+	_, err = local.Read(pointer)
+	assert.NotNil(t, err)
+	_, err = local.Read(CreatePointer([]byte("missing")))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,72 @@
+package lfs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+)
+
+const POINTER_VERSION = "https://git-lfs.github.com/spec/v1"
+
+// MAX_POINTER_SIZE Bigger blobs are never parsed as pointers
+const MAX_POINTER_SIZE = 1024
+
+// Pointer Stored in the blob instead of the content of the file. Format:
+//
+//	version https://git-lfs.github.com/spec/v1
+//	oid sha256:<sha256 of the content>
+//	size <size of the content>
+type Pointer struct {
+	Oid  string
+	Size int64
+}
+
+func CreatePointer(content []byte) Pointer {
+	oid := sha256.Sum256(content)
+	return Pointer{Oid: hex.EncodeToString(oid[:]), Size: int64(len(content))}
+}
+
+func (p Pointer) Serialize() []byte {
+	return []byte("version " + POINTER_VERSION + "\noid sha256:" + p.Oid + "\nsize " + strconv.FormatInt(p.Size, 10) + "\n")
+}
+
+// ParsePointer Returns false if the content is not a pointer. The version has to be the first key and the rest are sorted.
+// Unknown keys are allowed
+func ParsePointer(content []byte) (Pointer, bool) {
+	if len(content) > MAX_POINTER_SIZE || len(content) == 0 || content[len(content)-1] != '\n' {
+		return Pointer{}, false
+	}
+
+	pointer, sizeFound := Pointer{}, false
+	lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	if lines[0] != "version "+POINTER_VERSION {
+		return Pointer{}, false
+	}
+
+	previousKey := ""
+	for _, line := range lines[1:] {
+		key, value, found := strings.Cut(line, " ")
+		if !found || key <= previousKey {
+			return Pointer{}, false
+		}
+		previousKey = key
+
+		switch key {
+		case "oid":
+			oid, isSha256 := strings.CutPrefix(value, "sha256:")
+			if _, err := hex.DecodeString(oid); !isSha256 || err != nil || len(oid) != 2*sha256.Size {
+				return Pointer{}, false
+			}
+			pointer.Oid = oid
+		case "size":
+			size, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || size < 0 {
+				return Pointer{}, false
+			}
+			pointer.Size, sizeFound = size, true
+		}
+	}
+
+	return pointer, pointer.Oid != "" && sizeFound
+}
@@ -0,0 +1,78 @@
+package lfs
+
+import (
+	"errors"
+	"git/src/utils"
+	"os"
+	"path/filepath"
+)
+
+// Store Directory with the content of the files, stored in <dir>/<oid[0:2]>/<oid[2:4]>/<oid>. The store of a repository is
+// <common dir>/lfs/objects
+type Store struct {
+	Dir string
+}
+
+func (s Store) Path(oid string) string {
+	return utils.Paths(s.Dir, oid[0:2], oid[2:4], oid)
+}
+
+func (s Store) Has(pointer Pointer) bool {
+	stat, err := os.Stat(s.Path(pointer.Oid))
+	return err == nil && stat.Size() == pointer.Size
+}
+
+// Read Returns an error if the object is missing or its content doesnt match the pointer
+func (s Store) Read(pointer Pointer) ([]byte, error) {
+	content, err := os.ReadFile(s.Path(pointer.Oid))
+	if os.IsNotExist(err) {
+		return nil, errors.New("LFS object " + pointer.Oid + " not found in " + s.Dir)
+	} else if err != nil {
+		return nil, err
+	}
+
+	if CreatePointer(content) != pointer {
+		return nil, errors.New("LFS object " + pointer.Oid + " in " + s.Dir + " is corrupt")
+	}
+	return content, nil
+}
+
+// Write Stores the content and returns its pointer. The object is written to a temporary file and renamed, so it is never
+// seen incomplete
+func (s Store) Write(content []byte) (Pointer, error) {
+	pointer := CreatePointer(content)
+	if s.Has(pointer) {
+		return pointer, nil
+	}
+
+	path := s.Path(pointer.Oid)
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return Pointer{}, err
+	}
+	temporaryFile, err := os.CreateTemp(filepath.Dir(path), "tmp-"+pointer.Oid)
+	if err != nil {
+		return Pointer{}, err
+	}
+	defer os.Remove(temporaryFile.Name())
+
+	_, err = temporaryFile.Write(content)
+	if closeErr := temporaryFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return Pointer{}, err
+	}
+
+	return pointer, os.Rename(temporaryFile.Name(), path)
+}
+
+// Fetch Copies the object from the other store, checking its content
+func (s Store) Fetch(from Store, pointer Pointer) error {
+	content, err := from.Read(pointer)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.Write(content)
+	return err
+}
@@ -14,31 +14,75 @@ func main() {
 
 	switch command := os.Args[1]; command {
 	case "init":
-		commands.Init()
+		commands.Init(os.Args)
 	case "cat-file":
 		commands.CatFile(os.Args)
 	case "hash-object":
 		commands.HashObject(os.Args)
 	case "check-ignore":
 		commands.CheckIgnore(os.Args)
+	case "check-attr":
+		commands.CheckAttr(os.Args)
 	case "log":
 		commands.Log(os.Args)
+	case "show":
+		commands.Show(os.Args)
+	case "blame":
+		commands.Blame(os.Args)
+	case "annotate":
+		commands.Annotate(os.Args)
 	case "ls-tree":
 		commands.LsTree(os.Args)
 	case "checkout":
 		commands.Checkout(os.Args)
 	case "tag":
 		commands.Tag(os.Args)
+	case "verify-commit":
+		commands.VerifyCommit(os.Args)
+	case "verify-tag":
+		commands.VerifyTag(os.Args)
+	case "fsck":
+		commands.Fsck(os.Args)
+	case "config":
+		commands.Config(os.Args)
 	case "ls-files":
 		commands.LsFiles(os.Args)
 	case "status":
-		commands.Status()
+		commands.Status(os.Args)
+	case "diff":
+		commands.Diff(os.Args)
 	case "rev-parse":
 		commands.RevParse(os.Args)
 	case "add":
 		commands.Add(os.Args)
 	case "commit":
 		commands.Commit(os.Args)
+	case "reset":
+		commands.Reset(os.Args)
+	case "merge":
+		commands.Merge(os.Args)
+	case "commit-graph":
+		commands.CommitGraph(os.Args)
+	case "merge-base":
+		commands.MergeBase(os.Args)
+	case "multi-pack-index":
+		commands.MultiPackIndex(os.Args)
+	case "gc":
+		commands.Gc(os.Args)
+	case "clone":
+		commands.Clone(os.Args)
+	case "fetch":
+		commands.Fetch(os.Args)
+	case "serve":
+		commands.Serve(os.Args)
+	case "worktree":
+		commands.Worktree(os.Args)
+	case "submodule":
+		commands.Submodule(os.Args)
+	case "lfs":
+		commands.Lfs(os.Args)
+	case "sparse-checkout":
+		commands.SparseCheckout(os.Args)
 	default:
 		panic("Unknown command")
 	}
@@ -0,0 +1,134 @@
+package midx
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"git/src/ewah"
+	"sort"
+	"strconv"
+)
+
+// Reachability bitmap format (same as git, version 1):
+// "BITM" <version uint16> <options uint16> <number of entries uint32> <checksum of the multi-pack-index 20 bytes>
+// <commits, trees, blobs and tags EWAH bitmaps> <entries> [<name hash cache>] [<lookup table>] <sha1 of all previous bytes>
+// Bit i of every bitmap is the object i of the pseudo-pack order.
+// Type bitmaps: objects of each type. Entry: <position of the commit in the multi-pack-index uint32> <xor offset byte>
+// <flags byte> <EWAH bitmap of every object reachable from the commit>. If the xor offset is not 0 the bitmap is xored
+// with the one of the entry that many positions before
+
+const (
+	BITMAP_SIGNATURE = "BITM"
+	BITMAP_VERSION   = 1
+
+	BITMAP_OPT_FULL_DAG = 0x1 //Bitmaps contain every reachable object. Required
+)
+
+const bitmapHeaderSize = 12 + shaSize
+
+// Bitmaps Entries are keyed by the position of their commit in the multi-pack-index
+type Bitmaps struct {
+	Checksum []byte
+	Commits  *ewah.Bitmap
+	Trees    *ewah.Bitmap
+	Blobs    *ewah.Bitmap
+	Tags     *ewah.Bitmap
+	Entries  map[int]*ewah.Bitmap
+}
+
+// SerializeBitmaps Entries are written sorted by position, without xor
+func SerializeBitmaps(bitmaps *Bitmaps) []byte {
+	positions := make([]int, 0, len(bitmaps.Entries))
+	for position, _ := range bitmaps.Entries {
+		positions = append(positions, position)
+	}
+	sort.Ints(positions)
+
+	var buffer bytes.Buffer
+	buffer.WriteString(BITMAP_SIGNATURE)
+	buffer.Write(binary.BigEndian.AppendUint16(nil, BITMAP_VERSION))
+	buffer.Write(binary.BigEndian.AppendUint16(nil, BITMAP_OPT_FULL_DAG))
+	buffer.Write(binary.BigEndian.AppendUint32(nil, uint32(len(positions))))
+	buffer.Write(bitmaps.Checksum)
+	for _, typeBitmap := range []*ewah.Bitmap{bitmaps.Commits, bitmaps.Trees, bitmaps.Blobs, bitmaps.Tags} {
+		buffer.Write(typeBitmap.Serialize())
+	}
+	for _, position := range positions {
+		buffer.Write(binary.BigEndian.AppendUint32(nil, uint32(position)))
+		buffer.Write([]byte{0, 0})
+		buffer.Write(bitmaps.Entries[position].Serialize())
+	}
+
+	checksum := sha1.Sum(buffer.Bytes())
+	buffer.Write(checksum[:])
+	return buffer.Bytes()
+}
+
+// DeserializeBitmaps The checksum is verified. The name hash cache and the lookup table are ignored
+func DeserializeBitmaps(content []byte) (*Bitmaps, error) {
+	if len(content) < bitmapHeaderSize+shaSize || string(content[:4]) != BITMAP_SIGNATURE {
+		return nil, errors.New("bitmap signature does not match")
+	}
+	if version := binary.BigEndian.Uint16(content[4:6]); version != BITMAP_VERSION {
+		return nil, errors.New("bitmap version " + strconv.Itoa(int(version)) + " is not supported")
+	}
+	if options := binary.BigEndian.Uint16(content[6:8]); options&BITMAP_OPT_FULL_DAG == 0 {
+		return nil, errors.New("bitmaps without the full DAG option are not supported")
+	}
+	checksum := sha1.Sum(content[:len(content)-shaSize])
+	if !bytes.Equal(checksum[:], content[len(content)-shaSize:]) {
+		return nil, errors.New("bitmap checksum does not match")
+	}
+
+	numberEntries := int(binary.BigEndian.Uint32(content[8:12]))
+	bitmaps := &Bitmaps{Checksum: content[12:bitmapHeaderSize], Entries: make(map[int]*ewah.Bitmap, numberEntries)}
+	data := content[bitmapHeaderSize : len(content)-shaSize]
+
+	typeBitmaps := make([]*ewah.Bitmap, 4)
+	for i := range typeBitmaps {
+		typeBitmap, length, err := ewah.Deserialize(data)
+		if err != nil {
+			return nil, err
+		}
+		typeBitmaps[i], data = typeBitmap, data[length:]
+	}
+	bitmaps.Commits, bitmaps.Trees, bitmaps.Blobs, bitmaps.Tags = typeBitmaps[0], typeBitmaps[1], typeBitmaps[2], typeBitmaps[3]
+
+	entryPositions := make([]int, 0, numberEntries)
+	for i := 0; i < numberEntries; i++ {
+		if len(data) < 6 {
+			return nil, errors.New("bitmap entries are truncated")
+		}
+		position, xorOffset := int(binary.BigEndian.Uint32(data)), int(data[4])
+		entry, length, err := ewah.Deserialize(data[6:])
+		if err != nil {
+			return nil, err
+		}
+		data = data[6+length:]
+
+		if xorOffset > i {
+			return nil, errors.New("bitmap entry " + strconv.Itoa(i) + " has an invalid xor offset")
+		}
+		if xorOffset > 0 {
+			entry = xorBitmaps(entry, bitmaps.Entries[entryPositions[i-xorOffset]])
+		}
+		bitmaps.Entries[position] = entry
+		entryPositions = append(entryPositions, position)
+	}
+
+	return bitmaps, nil
+}
+
+func xorBitmaps(first *ewah.Bitmap, second *ewah.Bitmap) *ewah.Bitmap {
+	bitSize := first.BitSize
+	if second.BitSize > bitSize {
+		bitSize = second.BitSize
+	}
+	firstBits, secondBits := (&ewah.Bitmap{BitSize: bitSize, Words: first.Words}).Decompress(),
+		(&ewah.Bitmap{BitSize: bitSize, Words: second.Words}).Decompress()
+	for i := range firstBits {
+		firstBits[i] ^= secondBits[i]
+	}
+	return ewah.Compress(firstBits, bitSize)
+}
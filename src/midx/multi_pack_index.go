@@ -0,0 +1,353 @@
+package midx
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Multi-pack-index format (same as git, version 1):
+// "MIDX" <version byte> <hash version byte> <number of chunks byte> <number of base files byte> <number of packs uint32>
+// <chunk table: (number of chunks + 1) * (<chunk id 4 bytes> <offset uint64>), the last id is 0 and its offset is the end>
+// <chunks> <sha1 of all previous bytes>
+//
+// PNAM: sorted names of the pack indexes, each one ending with a 0 byte. Padded with zeros to a multiple of 4 bytes
+// OIDF: 256 uint32, number of objects whose first byte of sha is <= i
+// OIDL: sorted shas of the objects, 20 bytes each
+// OOFF: for every object: <position of its pack in PNAM uint32> <offset uint32>. If the most significant bit of the
+// offset is set, the rest of the bits are the position of the offset in LOFF
+// LOFF: offsets that dont fit in 31 bits, uint64 each
+// RIDX: positions in OIDL of the objects sorted in pseudo-pack order: objects of the preferred pack first, then by pack
+// and offset. Bitmaps use this order
+// An object stored in several packs is only indexed once
+
+const (
+	SIGNATURE         = "MIDX"
+	VERSION           = 1
+	HASH_VERSION_SHA1 = 1
+
+	PACK_NAMES_CHUNK    = "PNAM"
+	OID_FANOUT_CHUNK    = "OIDF"
+	OID_LOOKUP_CHUNK    = "OIDL"
+	OBJECT_OFFSET_CHUNK = "OOFF"
+	LARGE_OFFSET_CHUNK  = "LOFF"
+	REVERSE_INDEX_CHUNK = "RIDX"
+
+	LARGE_OFFSET_NEEDED = 0x80000000
+)
+
+const (
+	headerSize         = 12
+	chunkTableItemSize = 12
+	shaSize            = 20
+	objectOffsetSize   = 8
+)
+
+// Object Location of an object in the packs of the multi-pack-index
+type Object struct {
+	Sha    string
+	PackId uint32 //Position of the pack in the sorted pack names
+	Offset int64
+}
+
+type chunk struct {
+	id   string
+	data []byte
+}
+
+type MultiPackIndex struct {
+	PackNames     []string
+	Checksum      []byte
+	fanout        []byte
+	lookup        []byte //Sorted shas, 20 bytes each. They are encoded when needed, so opening a big index is cheap
+	numberObjects int
+	objectOffsets []byte
+	largeOffsets  []byte
+	reverseIndex  []byte
+}
+
+// Serialize packNames must be sorted and objects cannot be duplicated. Objects of the preferred pack are the first ones of
+// the pseudo-pack order
+func Serialize(packNames []string, objects []Object, preferredPack uint32) ([]byte, error) {
+	if !sort.StringsAreSorted(packNames) {
+		return nil, errors.New("multi-pack-index pack names are not sorted")
+	}
+	sorted := append(make([]Object, 0, len(objects)), objects...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Sha < sorted[j].Sha })
+
+	packNamesData := make([]byte, 0)
+	for _, name := range packNames {
+		packNamesData = append(append(packNamesData, name...), 0)
+	}
+	for len(packNamesData)%4 != 0 {
+		packNamesData = append(packNamesData, 0)
+	}
+
+	lookup := make([]byte, 0, len(sorted)*shaSize)
+	objectOffsets := make([]byte, 0, len(sorted)*objectOffsetSize)
+	largeOffsets := make([]byte, 0)
+	var firstByteCounts [256]uint32
+	for i, object := range sorted {
+		if i > 0 && sorted[i-1].Sha == object.Sha {
+			return nil, errors.New("duplicated object " + object.Sha + " in multi-pack-index")
+		}
+		if int(object.PackId) >= len(packNames) {
+			return nil, errors.New("object " + object.Sha + " is in an unknown pack")
+		}
+		sha, err := decodeSha(object.Sha)
+		if err != nil {
+			return nil, err
+		}
+
+		firstByteCounts[sha[0]]++
+		lookup = append(lookup, sha...)
+		objectOffsets = binary.BigEndian.AppendUint32(objectOffsets, object.PackId)
+		if object.Offset < LARGE_OFFSET_NEEDED {
+			objectOffsets = binary.BigEndian.AppendUint32(objectOffsets, uint32(object.Offset))
+		} else {
+			objectOffsets = binary.BigEndian.AppendUint32(objectOffsets, LARGE_OFFSET_NEEDED|uint32(len(largeOffsets)/8))
+			largeOffsets = binary.BigEndian.AppendUint64(largeOffsets, uint64(object.Offset))
+		}
+	}
+
+	fanout := make([]byte, 0, 256*4)
+	count := uint32(0)
+	for _, firstByteCount := range firstByteCounts {
+		count += firstByteCount
+		fanout = binary.BigEndian.AppendUint32(fanout, count)
+	}
+
+	pseudoPackOrder := make([]int, len(sorted))
+	for i := range pseudoPackOrder {
+		pseudoPackOrder[i] = i
+	}
+	sort.Slice(pseudoPackOrder, func(i, j int) bool {
+		first, second := sorted[pseudoPackOrder[i]], sorted[pseudoPackOrder[j]]
+		if (first.PackId == preferredPack) != (second.PackId == preferredPack) {
+			return first.PackId == preferredPack
+		}
+		if first.PackId != second.PackId {
+			return first.PackId < second.PackId
+		}
+		return first.Offset < second.Offset
+	})
+	reverseIndex := make([]byte, 0, len(sorted)*4)
+	for _, position := range pseudoPackOrder {
+		reverseIndex = binary.BigEndian.AppendUint32(reverseIndex, uint32(position))
+	}
+
+	chunks := []chunk{{PACK_NAMES_CHUNK, packNamesData}, {OID_FANOUT_CHUNK, fanout}, {OID_LOOKUP_CHUNK, lookup},
+		{OBJECT_OFFSET_CHUNK, objectOffsets}}
+	if len(largeOffsets) > 0 {
+		chunks = append(chunks, chunk{LARGE_OFFSET_CHUNK, largeOffsets})
+	}
+	chunks = append(chunks, chunk{REVERSE_INDEX_CHUNK, reverseIndex})
+
+	var buffer bytes.Buffer
+	buffer.WriteString(SIGNATURE)
+	buffer.Write([]byte{VERSION, HASH_VERSION_SHA1, byte(len(chunks)), 0})
+	buffer.Write(binary.BigEndian.AppendUint32(nil, uint32(len(packNames))))
+	offset := uint64(headerSize + (len(chunks)+1)*chunkTableItemSize)
+	for _, item := range chunks {
+		buffer.WriteString(item.id)
+		buffer.Write(binary.BigEndian.AppendUint64(nil, offset))
+		offset += uint64(len(item.data))
+	}
+	buffer.Write([]byte{0, 0, 0, 0})
+	buffer.Write(binary.BigEndian.AppendUint64(nil, offset))
+	for _, item := range chunks {
+		buffer.Write(item.data)
+	}
+
+	checksum := sha1.Sum(buffer.Bytes())
+	buffer.Write(checksum[:])
+	return buffer.Bytes(), nil
+}
+
+// Deserialize The checksum is verified
+func Deserialize(content []byte) (*MultiPackIndex, error) {
+	if len(content) < headerSize+chunkTableItemSize+shaSize || string(content[:4]) != SIGNATURE {
+		return nil, errors.New("multi-pack-index signature does not match")
+	}
+	if content[4] != VERSION {
+		return nil, errors.New("multi-pack-index version " + strconv.Itoa(int(content[4])) + " is not supported")
+	}
+	if content[5] != HASH_VERSION_SHA1 {
+		return nil, errors.New("multi-pack-index hash version " + strconv.Itoa(int(content[5])) + " is not supported")
+	}
+	if content[7] != 0 {
+		return nil, errors.New("incremental multi-pack-indexes are not supported")
+	}
+	checksum := sha1.Sum(content[:len(content)-shaSize])
+	if !bytes.Equal(checksum[:], content[len(content)-shaSize:]) {
+		return nil, errors.New("multi-pack-index checksum does not match")
+	}
+
+	chunks, err := readChunkTable(content, int(content[6]))
+	if err != nil {
+		return nil, err
+	}
+	for _, required := range []string{PACK_NAMES_CHUNK, OID_FANOUT_CHUNK, OID_LOOKUP_CHUNK, OBJECT_OFFSET_CHUNK} {
+		if _, found := chunks[required]; !found {
+			return nil, errors.New("multi-pack-index is missing the " + required + " chunk")
+		}
+	}
+
+	numberPacks := int(binary.BigEndian.Uint32(content[8:12]))
+	packNames := make([]string, 0, numberPacks)
+	for names := chunks[PACK_NAMES_CHUNK]; len(packNames) < numberPacks; {
+		end := bytes.IndexByte(names, 0)
+		if end <= 0 {
+			return nil, errors.New("multi-pack-index pack names are truncated")
+		}
+		packNames = append(packNames, string(names[:end]))
+		names = names[end+1:]
+	}
+	if !sort.StringsAreSorted(packNames) {
+		return nil, errors.New("multi-pack-index pack names are not sorted")
+	}
+
+	lookup := chunks[OID_LOOKUP_CHUNK]
+	if len(chunks[OID_FANOUT_CHUNK]) != 256*4 {
+		return nil, errors.New("multi-pack-index fanout has an invalid size")
+	}
+	numberObjects := int(binary.BigEndian.Uint32(chunks[OID_FANOUT_CHUNK][255*4:]))
+	if len(lookup) != numberObjects*shaSize || len(chunks[OBJECT_OFFSET_CHUNK]) != numberObjects*objectOffsetSize {
+		return nil, errors.New("multi-pack-index chunks have invalid sizes")
+	}
+	for i := 1; i < 256; i++ {
+		if binary.BigEndian.Uint32(chunks[OID_FANOUT_CHUNK][(i-1)*4:]) > binary.BigEndian.Uint32(chunks[OID_FANOUT_CHUNK][i*4:]) {
+			return nil, errors.New("multi-pack-index fanout is not sorted")
+		}
+	}
+	if reverseIndex, found := chunks[REVERSE_INDEX_CHUNK]; found && len(reverseIndex) != numberObjects*4 {
+		return nil, errors.New("multi-pack-index reverse index has an invalid size")
+	}
+
+	return &MultiPackIndex{PackNames: packNames, Checksum: content[len(content)-shaSize:], fanout: chunks[OID_FANOUT_CHUNK],
+		lookup: lookup, numberObjects: numberObjects, objectOffsets: chunks[OBJECT_OFFSET_CHUNK],
+		largeOffsets: chunks[LARGE_OFFSET_CHUNK], reverseIndex: chunks[REVERSE_INDEX_CHUNK]}, nil
+}
+
+// Chunk id -> content
+func readChunkTable(content []byte, numberChunks int) (map[string][]byte, error) {
+	chunks := make(map[string][]byte)
+	end := uint64(len(content) - shaSize)
+	if len(content) < headerSize+(numberChunks+1)*chunkTableItemSize {
+		return nil, errors.New("multi-pack-index chunk table is truncated")
+	}
+
+	for i := 0; i < numberChunks; i++ {
+		item := content[headerSize+i*chunkTableItemSize:]
+		nextItem := content[headerSize+(i+1)*chunkTableItemSize:]
+		start, chunkEnd := binary.BigEndian.Uint64(item[4:12]), binary.BigEndian.Uint64(nextItem[4:12])
+		if start > chunkEnd || chunkEnd > end {
+			return nil, errors.New("multi-pack-index chunk " + string(item[:4]) + " has an invalid offset")
+		}
+		chunks[string(item[:4])] = content[start:chunkEnd]
+	}
+
+	return chunks, nil
+}
+
+func (m *MultiPackIndex) Len() int {
+	return m.numberObjects
+}
+
+// Position Position of the object in the sorted shas. Only the objects with the same first byte are searched
+func (m *MultiPackIndex) Position(sha string) (int, bool) {
+	var decoded [shaSize]byte
+	if len(sha) != 2*shaSize {
+		return 0, false
+	}
+	if _, err := hex.Decode(decoded[:], []byte(sha)); err != nil {
+		return 0, false
+	}
+
+	start, end := 0, int(binary.BigEndian.Uint32(m.fanout[int(decoded[0])*4:]))
+	if decoded[0] > 0 {
+		start = int(binary.BigEndian.Uint32(m.fanout[(int(decoded[0])-1)*4:]))
+	}
+	position := start + sort.Search(end-start, func(i int) bool {
+		return bytes.Compare(m.rawShaAt(start+i), decoded[:]) >= 0
+	})
+	return position, position < end && bytes.Equal(m.rawShaAt(position), decoded[:])
+}
+
+func (m *MultiPackIndex) ShaAt(position int) string {
+	return hex.EncodeToString(m.rawShaAt(position))
+}
+
+func (m *MultiPackIndex) rawShaAt(position int) []byte {
+	return m.lookup[position*shaSize : (position+1)*shaSize]
+}
+
+// Lookup Binary search of the sha in the index
+func (m *MultiPackIndex) Lookup(sha string) (Object, bool) {
+	position, found := m.Position(sha)
+	if !found {
+		return Object{}, false
+	}
+
+	object, err := m.ObjectAt(position)
+	return object, err == nil
+}
+
+func (m *MultiPackIndex) ObjectAt(position int) (Object, error) {
+	data := m.objectOffsets[position*objectOffsetSize : (position+1)*objectOffsetSize]
+	object := Object{Sha: m.ShaAt(position), PackId: binary.BigEndian.Uint32(data[:4])}
+	if int(object.PackId) >= len(m.PackNames) {
+		return object, errors.New("multi-pack-index pack position " + strconv.Itoa(int(object.PackId)) + " is out of range")
+	}
+
+	offset := binary.BigEndian.Uint32(data[4:8])
+	if offset&LARGE_OFFSET_NEEDED == 0 || m.largeOffsets == nil {
+		object.Offset = int64(offset)
+		return object, nil
+	}
+	largePosition := int(offset&^LARGE_OFFSET_NEEDED) * 8
+	if largePosition+8 > len(m.largeOffsets) {
+		return object, errors.New("multi-pack-index large offset is out of range")
+	}
+	object.Offset = int64(binary.BigEndian.Uint64(m.largeOffsets[largePosition:]))
+	return object, nil
+}
+
+// ShasWithPrefix Used to resolve abbreviated shas
+func (m *MultiPackIndex) ShasWithPrefix(prefix string) []string {
+	result := make([]string, 0)
+	position := sort.Search(m.numberObjects, func(i int) bool { return m.ShaAt(i) >= prefix })
+	for ; position < m.numberObjects && strings.HasPrefix(m.ShaAt(position), prefix); position++ {
+		result = append(result, m.ShaAt(position))
+	}
+	return result
+}
+
+// PseudoPackOrder Positions of the objects sorted in pseudo-pack order. False if the index has no reverse index
+func (m *MultiPackIndex) PseudoPackOrder() ([]int, bool) {
+	if m.reverseIndex == nil {
+		return nil, false
+	}
+
+	order := make([]int, m.numberObjects)
+	for i := range order {
+		order[i] = int(binary.BigEndian.Uint32(m.reverseIndex[i*4:]))
+		if order[i] >= m.numberObjects {
+			return nil, false
+		}
+	}
+	return order, true
+}
+
+func decodeSha(sha string) ([]byte, error) {
+	decoded, err := hex.DecodeString(sha)
+	if err != nil || len(decoded) != shaSize {
+		return nil, errors.New("invalid sha " + sha)
+	}
+	return decoded, nil
+}
@@ -0,0 +1,77 @@
+package midx
+
+import (
+	"crypto/sha1"
+	"git/src/ewah"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMultiPackIndex_SerializeAndDeserialize(t *testing.T) {
+	packNames := []string{"pack-a.idx", "pack-b.idx"}
+	first := Object{Sha: strings.Repeat("f", 40), PackId: 0, Offset: 12}
+	second := Object{Sha: strings.Repeat("0", 40), PackId: 1, Offset: 12}
+	third := Object{Sha: strings.Repeat("a", 40), PackId: 1, Offset: 1 << 33}
+	fourth := Object{Sha: strings.Repeat("0", 39) + "1", PackId: 1, Offset: 100}
+
+	content, err := Serialize(packNames, []Object{first, second, third, fourth}, 1)
+	assert.Nil(t, err)
+	multiPackIndex, err := Deserialize(content)
+	assert.Nil(t, err)
+
+	assert.Equal(t, packNames, multiPackIndex.PackNames)
+	assert.Equal(t, 4, multiPackIndex.Len())
+	for _, object := range []Object{first, second, third, fourth} {
+		found, isFound := multiPackIndex.Lookup(object.Sha)
+		assert.True(t, isFound)
+		assert.Equal(t, object, found)
+	}
+	_, isFound := multiPackIndex.Lookup(strings.Repeat("b", 40))
+	assert.False(t, isFound)
+	assert.Equal(t, []string{second.Sha, fourth.Sha}, multiPackIndex.ShasWithPrefix("000"))
+
+	order, hasOrder := multiPackIndex.PseudoPackOrder()
+	assert.True(t, hasOrder)
+	assert.Equal(t, []int{0, 1, 2, 3}, order) //Preferred pack by offset, then the other pack
+
+	content[len(content)-1] ^= 0xff
+	_, err = Deserialize(content)
+	assert.NotNil(t, err)
+}
+
+func TestMultiPackIndex_SerializeDuplicatedObject(t *testing.T) {
+	object := Object{Sha: strings.Repeat("a", 40), PackId: 0, Offset: 12}
+
+	_, err := Serialize([]string{"pack-a.idx"}, []Object{object, object}, 0)
+
+	assert.NotNil(t, err)
+}
+
+func TestMultiPackIndex_Bitmaps(t *testing.T) {
+	bitmaps := &Bitmaps{
+		Checksum: []byte(strings.Repeat("c", 20)),
+		Commits:  ewah.Compress([]uint64{0x3}, 5),
+		Trees:    ewah.Compress([]uint64{0x4}, 5),
+		Blobs:    ewah.Compress([]uint64{0x18}, 5),
+		Tags:     ewah.Compress([]uint64{0}, 5),
+		Entries:  map[int]*ewah.Bitmap{3: ewah.Compress([]uint64{0x1d}, 5), 1: ewah.Compress([]uint64{0x1f}, 5)},
+	}
+
+	content := SerializeBitmaps(bitmaps)
+	deserialized, err := DeserializeBitmaps(content)
+	assert.Nil(t, err)
+	assert.Equal(t, bitmaps, deserialized)
+
+	// The second entry is stored xored with the first one
+	xored := ewah.Compress([]uint64{0x1d ^ 0x1f}, 5).Serialize()
+	start := len(content) - 20 - len(xored)
+	content = append(append(append([]byte{}, content[:start-2]...), 1, 0), xored...)
+	content = append(content, make([]byte, 20)...)
+	checksum := sha1.Sum(content[:len(content)-20])
+	copy(content[len(content)-20:], checksum[:])
+	deserialized, err = DeserializeBitmaps(content)
+	assert.Nil(t, err)
+	assert.Equal(t, []uint64{0x1d}, deserialized.Entries[3].Decompress())
+}
@@ -3,33 +3,53 @@ package objects
 import (
 	"errors"
 	"git/src/utils"
+	"strings"
 )
 
 const NO_PARENT_COMMIT_SHA = "0000000000000000000000000000000000000000"
 
 type CommitObject struct {
 	Tree      string
-	Parent    string
+	Parent    string //First parent. NO_PARENT_COMMIT_SHA if it is the root commit
+	Parents   []string
 	Author    string
 	Committer string
+	Signature string //Armored signature of the gpgsig header. Empty if the commit is not signed
 	Message   string
 
 	keyValue *utils.NavigationMap[string, string]
+	headers  string //Headers of deserialized commits. keyValue is parsed from them when the commit is serialized
 }
 
-func CreateCommitObject(treeSha string, parent string, author string, message string) *Object {
+func CreateCommitObject(treeSha string, parent string, author string, committer string, message string) *Object {
+	parents := make([]string, 0)
+	if parent != NO_PARENT_COMMIT_SHA {
+		parents = append(parents, parent)
+	}
+
+	return CreateCommitObjectWithParents(treeSha, parents, author, committer, message)
+}
+
+// CreateCommitObjectWithParents Merge commits have several parents. Root commits have none
+func CreateCommitObjectWithParents(treeSha string, parents []string, author string, committer string, message string) *Object {
 	commitObject := CommitObject{
 		Tree:      treeSha,
-		Parent:    parent,
+		Parent:    NO_PARENT_COMMIT_SHA,
+		Parents:   append(make([]string, 0), parents...),
 		Author:    author,
-		Committer: author,
+		Committer: committer,
 		Message:   message,
 		keyValue:  utils.CreateNavigationMap[string, string](),
 	}
+	if len(parents) > 0 {
+		commitObject.Parent = parents[0]
+	}
 	commitObject.keyValue.Put("tree", treeSha)
-	commitObject.keyValue.Put("parent", parent)
+	for _, parent := range parents {
+		commitObject.keyValue.Put("parent", parent)
+	}
 	commitObject.keyValue.Put("author", author)
-	commitObject.keyValue.Put("committer", author)
+	commitObject.keyValue.Put("committer", committer)
 
 	return &Object{
 		Type:                  COMMIT,
@@ -37,28 +57,124 @@ func CreateCommitObject(treeSha string, parent string, author string, message st
 	}
 }
 
+const COMMIT_SIGNATURE_KEY = "gpgsig"
+
+// SignCommitObject Returns the commit with the signature of its payload in the gpgsig header
+func SignCommitObject(commit *Object, signature string) *Object {
+	commitObject := commit.SerializableGitObject.(CommitObject)
+	commitObject.keyValue = commitObject.getKeyValue()
+	commitObject.keyValue.Put(COMMIT_SIGNATURE_KEY, strings.TrimSuffix(signature, "\n"))
+	commitObject.Signature = signature
+
+	return &Object{Type: COMMIT, SerializableGitObject: commitObject}
+}
+
+// SignedPayload Returns the data covered by the signature (the commit without the gpgsig header) and the signature
+func (c CommitObject) SignedPayload() ([]byte, string) {
+	return append(keyValueListSerializeWithout(c.getKeyValue(), COMMIT_SIGNATURE_KEY), []byte(c.Message)...), c.Signature
+}
+
 func (c CommitObject) HasParent() bool {
 	return c.Parent != NO_PARENT_COMMIT_SHA
 }
 
+func (c CommitObject) AuthorSignature() Signature {
+	return ParseSignature(c.Author)
+}
+
+func (c CommitObject) CommitterSignature() Signature {
+	return ParseSignature(c.Committer)
+}
+
+// CommitTime Unix timestamp of the committer. History walks sort the commits by it
+func (c CommitObject) CommitTime() int64 {
+	return ParseSignatureTimestamp(c.Committer)
+}
+
+// Subject First line of the message
+func (c CommitObject) Subject() string {
+	return strings.SplitN(strings.TrimLeft(c.Message, "\n"), "\n", 2)[0]
+}
+
+// Body Message without the subject and the blank lines after it
+func (c CommitObject) Body() string {
+	parts := strings.SplitN(strings.TrimLeft(c.Message, "\n"), "\n", 2)
+	if len(parts) < 2 {
+		return ""
+	}
+	return strings.TrimLeft(parts[1], "\n")
+}
+
+// History walks read a lot of commits, so the headers are not parsed into keyValue. The fields are substrings of the
+// commit, so usually a single string is allocated for it
 func deserializeCommitObject(toDeserialize []byte) (CommitObject, error) {
-	deserializedKeyValue, remainingData := keyValueListDeserialize(toDeserialize)
-	if allContained := deserializedKeyValue.ContainsAll("tree", "parent", "author", "committer"); !allContained {
+	data := string(toDeserialize)
+	commitObject := CommitObject{Parent: NO_PARENT_COMMIT_SHA}
+	hasTree, hasAuthor, hasCommitter, hasSignature := false, false, false, false
+
+	offset := 0
+	for offset < len(data) {
+		indexEndKey := strings.IndexByte(data[offset:], ' ')
+		indexEndValue := strings.IndexByte(data[offset:], '\n')
+		if indexEndValue <= indexEndKey || indexEndKey <= 0 { //Blank line -> end of key/value
+			break
+		}
+		key, value := data[offset:offset+indexEndKey], data[offset+indexEndKey+1:offset+indexEndValue]
+		offset += indexEndValue + 1
+
+		for offset < len(data) && data[offset] == ' ' { //Continuation lines of the value
+			indexEndLine := strings.IndexByte(data[offset:], '\n')
+			if indexEndLine == -1 {
+				indexEndLine = len(data) - offset
+			}
+			value = value + "\n" + data[offset+1:offset+indexEndLine]
+			offset += indexEndLine + 1
+		}
+
+		switch { //Like Get, the first value of the keys is used
+		case key == "parent":
+			commitObject.Parents = append(commitObject.Parents, value)
+		case key == "tree" && !hasTree:
+			commitObject.Tree, hasTree = value, true
+		case key == "author" && !hasAuthor:
+			commitObject.Author, hasAuthor = value, true
+		case key == "committer" && !hasCommitter:
+			commitObject.Committer, hasCommitter = value, true
+		case key == COMMIT_SIGNATURE_KEY && !hasSignature:
+			commitObject.Signature, hasSignature = getSignatureValue(value), true
+		}
+	}
+	if !hasTree || !hasAuthor || !hasCommitter {
 		return CommitObject{}, errors.New("invalid key value format. Missing fields")
 	}
 
-	commitObject := CommitObject{
-		Tree:      deserializedKeyValue.Get("tree"),
-		Parent:    deserializedKeyValue.Get("parent"),
-		Author:    deserializedKeyValue.Get("author"),
-		Committer: deserializedKeyValue.Get("committer"),
-		Message:   string(remainingData),
-		keyValue:  deserializedKeyValue,
+	if len(commitObject.Parents) > 0 {
+		commitObject.Parent = commitObject.Parents[0]
+	}
+	if offset+1 <= len(data) {
+		commitObject.headers, commitObject.Message = data[:offset], data[offset+1:]
+	} else {
+		commitObject.headers = data
 	}
 
 	return commitObject, nil
 }
 
 func (c CommitObject) Serialize() []byte {
-	return append(keyValueListSerialize(c.keyValue), []byte(c.Message)...)
+	return append(keyValueListSerialize(c.getKeyValue()), []byte(c.Message)...)
+}
+
+func (c CommitObject) getKeyValue() *utils.NavigationMap[string, string] {
+	if c.keyValue != nil {
+		return c.keyValue
+	}
+	keyValue, _ := keyValueListDeserialize([]byte(c.headers))
+	return keyValue
+}
+
+func getSignatureValue(value string) string {
+	if value == "" {
+		return ""
+	}
+	return value + "\n"
 }
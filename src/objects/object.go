@@ -1,11 +1,12 @@
 package objects
 
 import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
 	"errors"
-	"fmt"
 	"git/src/utils"
 	"io"
-	"io/ioutil"
 	"strconv"
 	"strings"
 )
@@ -29,6 +30,22 @@ type SerializableGitObject interface {
 	Serialize() []byte
 }
 
+// RawObject Object whose body is kept as it is. Used when objects are received from other repositories
+type RawObject struct {
+	Data []byte
+}
+
+func CreateRawObject(objectType ObjectType, data []byte) *Object {
+	return &Object{
+		SerializableGitObject: RawObject{Data: data},
+		Type:                  objectType,
+	}
+}
+
+func (r RawObject) Serialize() []byte {
+	return r.Data
+}
+
 func (o Object) Serialize() []byte {
 	serialized := o.SerializableGitObject.Serialize()
 	header := []byte(string(o.Type) + " " + strconv.Itoa(len(serialized)) + string('\x00'))
@@ -36,55 +53,93 @@ func (o Object) Serialize() []byte {
 	return append(header, serialized...)
 }
 
+// Sha Returns the sha of the serialized object (header included) without writing it
+func (o Object) Sha() string {
+	sha1Hasher := sha1.New()
+	sha1Hasher.Write(o.Serialize())
+
+	return hex.EncodeToString(sha1Hasher.Sum(nil))
+}
+
 func DeserializeObject(reader io.Reader) (Object, error) {
 	commonObject, pendingToDeserialize, err := deserializeObjectCommonHeader(reader)
 
 	if err != nil {
-		return *commonObject, err
+		return Object{}, err
 	}
 
-	fmt.Println("----------------------------")
-	fmt.Println(string(pendingToDeserialize))
+	return DeserializeObjectBody(commonObject.Type, pendingToDeserialize)
+}
 
+// DeserializeObjectBody Parses the body (everything after the header) of an object whose type is already known
+func DeserializeObjectBody(objectType ObjectType, body []byte) (Object, error) {
 	var gitObject SerializableGitObject
-	switch commonObject.Type {
+	var err error
+	switch objectType {
 	case BLOB:
-		gitObject, err = deserializeBlobObject(pendingToDeserialize)
+		gitObject, err = deserializeBlobObject(body)
 	case COMMIT:
-		gitObject, err = deserializeCommitObject(pendingToDeserialize)
+		gitObject, err = deserializeCommitObject(body)
 	case TREE:
-		gitObject, err = deserializeTreeObject(pendingToDeserialize)
+		gitObject, err = deserializeTreeObject(body)
 	case TAG:
-		gitObject, err = deserializeTagObject(pendingToDeserialize)
+		gitObject, err = deserializeTagObject(body)
+	}
+
+	return Object{SerializableGitObject: gitObject, Type: objectType}, err
+}
+
+// DeserializeRawObject Same as DeserializeObject but the body is not parsed
+func DeserializeRawObject(reader io.Reader) (Object, error) {
+	commonObject, pendingToDeserialize, err := deserializeObjectCommonHeader(reader)
+	if err != nil {
+		return Object{}, err
 	}
 
-	commonObject.SerializableGitObject = gitObject
+	commonObject.SerializableGitObject = RawObject{Data: pendingToDeserialize}
 
-	return *commonObject, err
+	return *commonObject, nil
 }
 
+// The header (<type> <size>\0) is read first, so the body is read into a buffer of its size instead of a growing one.
+// The stream is read until its end, so compressed readers verify their checksum
 func deserializeObjectCommonHeader(reader io.Reader) (*Object, []byte, error) {
-	bytesDecompressed, err := ioutil.ReadAll(reader)
-	if err != nil {
+	var header [64]byte //Longer than any header
+	headerLength, err := io.ReadFull(reader, header[:])
+	if err != nil && err != io.ErrUnexpectedEOF {
 		return nil, []byte{}, err
 	}
 
-	objectTypeBytes, offset, err := utils.ReadUntil(bytesDecompressed, 0, 32)
-	if err != nil {
-		return nil, []byte{}, err
+	headerEnd := bytes.IndexByte(header[:headerLength], 0)
+	typeEnd := bytes.IndexByte(header[:headerLength], ' ')
+	if headerEnd == -1 || typeEnd == -1 || typeEnd > headerEnd {
+		return nil, []byte{}, errors.New("Invalid object header")
 	}
-	objectType, err := getObjectTypeByString(string(objectTypeBytes))
+	objectType, err := getObjectTypeByString(string(header[:typeEnd]))
 	if err != nil {
 		return nil, []byte{}, err
 	}
+	size, err := strconv.Atoi(string(header[typeEnd+1 : headerEnd]))
+	if err != nil || size < 0 {
+		return nil, []byte{}, errors.New("Invalid object size " + string(header[typeEnd+1:headerEnd]))
+	}
 
-	_, offset, err = utils.ReadUntil(bytesDecompressed, offset, 0)
-	if err != nil {
+	read := header[headerEnd+1 : headerLength]
+	if len(read) > size {
+		return nil, []byte{}, errors.New("Object is longer than its size " + strconv.Itoa(size))
+	}
+	body := make([]byte, size)
+	copy(body, read)
+	if _, err := io.ReadFull(reader, body[len(read):]); err != nil {
+		return nil, []byte{}, errors.New("Object is shorter than its size " + strconv.Itoa(size))
+	}
+	if extra, err := reader.Read(header[:1]); extra > 0 {
+		return nil, []byte{}, errors.New("Object is longer than its size " + strconv.Itoa(size))
+	} else if err != nil && err != io.EOF {
 		return nil, []byte{}, err
 	}
-	restData := bytesDecompressed[offset:]
 
-	return &Object{Type: objectType}, restData, nil
+	return &Object{Type: objectType}, body, nil
 }
 
 func getObjectTypeByString(objectTypeString string) (ObjectType, error) {
@@ -102,15 +157,22 @@ func getObjectTypeByString(objectTypeString string) (ObjectType, error) {
 	}
 }
 
+// Values with line breaks (Ex: gpgsig) are written in several lines. The lines after the first one start with a space
 func keyValueListSerialize(kvMap *utils.NavigationMap[string, string]) []byte {
-	result := ""
+	return keyValueListSerializeWithout(kvMap, "")
+}
 
-	for _, key := range kvMap.Keys() {
-		value := kvMap.Get(key)
-		result = result + key + " " + value + "\n"
-	}
+// keyValueListSerializeWithout Serializes every key except excludedKey. Used to get the payload of signed objects
+func keyValueListSerializeWithout(kvMap *utils.NavigationMap[string, string], excludedKey string) []byte {
+	var result strings.Builder
+
+	kvMap.ForEach(func(key string, value string) {
+		if key != excludedKey {
+			result.WriteString(key + " " + strings.ReplaceAll(value, "\n", "\n ") + "\n")
+		}
+	})
 
-	return []byte(result + "\n")
+	return []byte(result.String() + "\n")
 }
 
 func keyValueListDeserialize(bytes []byte) (*utils.NavigationMap[string, string], []byte) {
@@ -121,13 +183,25 @@ func keyValueListParserDeserializeRecursive(bytes []byte, offset int, parsed *ut
 	indexEndKey := utils.FindIndex(bytes, offset, 32)
 	indexEndValue := utils.FindIndex(bytes, offset, 10)
 
-	if indexEndValue > indexEndKey && indexEndKey > 0 && indexEndValue > 0 {
+	if indexEndValue > indexEndKey && indexEndKey > offset && indexEndValue > 0 {
 		key := string(bytes[offset:indexEndKey])
 		value := string(bytes[indexEndKey+1 : indexEndValue])
+
+		for indexEndValue+1 < len(bytes) && bytes[indexEndValue+1] == ' ' { //Continuation lines of the value
+			indexEndLine := utils.FindIndex(bytes, indexEndValue+1, 10)
+			if indexEndLine == -1 {
+				indexEndLine = len(bytes)
+			}
+			value = value + "\n" + string(bytes[indexEndValue+2:indexEndLine])
+			indexEndValue = indexEndLine
+		}
 		parsed.Put(key, value)
 
 		return keyValueListParserDeserializeRecursive(bytes, indexEndValue+1, parsed)
 	} else { //Blank line -> end of key/value
+		if offset+1 > len(bytes) {
+			return parsed, []byte{}
+		}
 		return parsed, bytes[offset+1:]
 	}
 }
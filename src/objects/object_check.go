@@ -0,0 +1,245 @@
+package objects
+
+import (
+	"bytes"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ObjectLink Object referenced by other object. Used to check the connectivity of the repository
+type ObjectLink struct {
+	Sha  string
+	Type ObjectType
+}
+
+// ObjectProblem Syntax problem found by CheckObject. Ids are the same as git fsck message ids (Ex: missingAuthor)
+type ObjectProblem struct {
+	Id      string
+	Message string
+	Warning bool //Warnings dont make the object corrupt
+}
+
+func (p ObjectProblem) String() string {
+	return p.Id + ": " + p.Message
+}
+
+var shaRegex = regexp.MustCompile("^[0-9a-f]{40}$")
+
+// Format: Name <email> <unix timestamp> <timezone +hhmm>
+var identityDateRegex = regexp.MustCompile(`^[0-9]+ [+-][0-9]{4}$`)
+
+// SplitObjectHeader Returns the type and the body of a decompressed object. Format: <type> <size>\x00<body>
+func SplitObjectHeader(decompressed []byte) (ObjectType, []byte, error) {
+	headerEnd := bytes.IndexByte(decompressed, 0)
+	if headerEnd == -1 {
+		return "", nil, errors.New("invalid object header")
+	}
+
+	typeName, sizeText, found := strings.Cut(string(decompressed[:headerEnd]), " ")
+	objectType, err := getObjectTypeByString(typeName)
+	if !found || err != nil || typeName != string(objectType) {
+		return "", nil, errors.New("invalid object type " + typeName)
+	}
+	size, err := strconv.Atoi(sizeText)
+	if err != nil || size != len(decompressed)-headerEnd-1 {
+		return "", nil, errors.New("object size " + sizeText + " doesnt match its content")
+	}
+
+	return objectType, decompressed[headerEnd+1:], nil
+}
+
+// CheckObject Validates the syntax of the object body like git fsck does and returns the objects it points to.
+// Blobs have no syntax. Submodule commits of trees are not included in the links, since they belong to other repository
+func CheckObject(objectType ObjectType, body []byte) ([]ObjectLink, []ObjectProblem) {
+	switch objectType {
+	case TREE:
+		return checkTree(body)
+	case COMMIT:
+		return checkCommit(body)
+	case TAG:
+		return checkTag(body)
+	}
+	return []ObjectLink{}, []ObjectProblem{}
+}
+
+func checkTree(body []byte) ([]ObjectLink, []ObjectProblem) {
+	links, problems := make([]ObjectLink, 0), make([]ObjectProblem, 0)
+	previousSortName, names := "", make(map[string]bool)
+
+	for offset := 0; offset < len(body); {
+		entry, newOffset, err := deserializeTreeObjectEntry(body, offset)
+		if err != nil {
+			return links, append(problems, ObjectProblem{Id: "badTree", Message: "cannot be parsed as a tree"})
+		}
+		offset = newOffset
+		problems = append(problems, checkTreeEntry(entry)...)
+
+		if names[entry.Path] {
+			problems = append(problems, ObjectProblem{Id: "duplicateEntries", Message: "contains duplicate file entries"})
+		} else if sortName := entry.formatPathToSort(); previousSortName != "" && sortName < previousSortName {
+			problems = append(problems, ObjectProblem{Id: "treeNotSorted", Message: "not properly sorted"})
+		}
+		names[entry.Path], previousSortName = true, entry.formatPathToSort()
+
+		switch {
+		case entry.IsGitlink():
+		case entry.IsDir():
+			links = append(links, ObjectLink{Sha: entry.Sha, Type: TREE})
+		default:
+			links = append(links, ObjectLink{Sha: entry.Sha, Type: BLOB})
+		}
+	}
+
+	return links, problems
+}
+
+func checkTreeEntry(entry TreeEntry) []ObjectProblem {
+	problems := make([]ObjectProblem, 0)
+
+	switch {
+	case entry.Path == "":
+		problems = append(problems, ObjectProblem{Id: "emptyName", Message: "contains empty pathname", Warning: true})
+	case entry.Path == ".":
+		problems = append(problems, ObjectProblem{Id: "hasDot", Message: "contains '.'", Warning: true})
+	case entry.Path == "..":
+		problems = append(problems, ObjectProblem{Id: "hasDotdot", Message: "contains '..'", Warning: true})
+	case strings.EqualFold(entry.Path, ".git"):
+		problems = append(problems, ObjectProblem{Id: "hasDotgit", Message: "contains '.git'", Warning: true})
+	case strings.Contains(entry.Path, "/"):
+		problems = append(problems, ObjectProblem{Id: "fullPathname", Message: "contains full pathnames", Warning: true})
+	}
+
+	switch {
+	case strings.HasPrefix(entry.Mode, "0"):
+		problems = append(problems, ObjectProblem{Id: "zeroPaddedFilemode", Message: "contains zero-padded file modes", Warning: true})
+	case entry.Mode != TREE_MODE_DIR && entry.Mode != TREE_MODE_FILE && entry.Mode != TREE_MODE_EXECUTABLE &&
+		entry.Mode != TREE_MODE_SYMLINK && entry.Mode != TREE_MODE_GITLINK:
+		problems = append(problems, ObjectProblem{Id: "badFilemode", Message: "contains bad file modes", Warning: true})
+	}
+
+	if entry.Sha == NO_PARENT_COMMIT_SHA {
+		problems = append(problems, ObjectProblem{Id: "nullSha1", Message: "contains entries pointing to null sha1"})
+	}
+
+	return problems
+}
+
+func checkCommit(body []byte) ([]ObjectLink, []ObjectProblem) {
+	links := make([]ObjectLink, 0)
+	headers, problem, valid := readCheckedHeaders(body)
+	if !valid {
+		return links, []ObjectProblem{problem}
+	}
+
+	if len(headers) == 0 || headers[0].key != "tree" {
+		return links, []ObjectProblem{{Id: "missingTree", Message: "invalid format - expected 'tree' line"}}
+	}
+	if !shaRegex.MatchString(headers[0].value) {
+		return links, []ObjectProblem{{Id: "badTreeSha1", Message: "invalid 'tree' line format - bad sha1"}}
+	}
+	links = append(links, ObjectLink{Sha: headers[0].value, Type: TREE})
+
+	i := 1
+	for ; i < len(headers) && headers[i].key == "parent"; i++ {
+		if !shaRegex.MatchString(headers[i].value) {
+			return links, []ObjectProblem{{Id: "badParentSha1", Message: "invalid 'parent' line format - bad sha1"}}
+		}
+		links = append(links, ObjectLink{Sha: headers[i].value, Type: COMMIT})
+	}
+
+	for _, role := range []string{"author", "committer"} {
+		if i >= len(headers) || headers[i].key != role {
+			return links, []ObjectProblem{{Id: "missing" + strings.ToUpper(role[:1]) + role[1:], Message: "invalid format - expected '" + role + "' line"}}
+		}
+		if problem, valid := checkIdentity(headers[i].value); !valid {
+			return links, []ObjectProblem{problem}
+		}
+		i++
+	}
+
+	return links, []ObjectProblem{}
+}
+
+func checkTag(body []byte) ([]ObjectLink, []ObjectProblem) {
+	links := make([]ObjectLink, 0)
+	headers, problem, valid := readCheckedHeaders(body)
+	if !valid {
+		return links, []ObjectProblem{problem}
+	}
+
+	if len(headers) == 0 || headers[0].key != "object" {
+		return links, []ObjectProblem{{Id: "missingObject", Message: "invalid format - expected 'object' line"}}
+	}
+	if !shaRegex.MatchString(headers[0].value) {
+		return links, []ObjectProblem{{Id: "badObjectSha1", Message: "invalid 'object' line format - bad sha1"}}
+	}
+	if len(headers) < 2 || headers[1].key != "type" {
+		return links, []ObjectProblem{{Id: "missingTypeEntry", Message: "invalid format - expected 'type' line"}}
+	}
+	objectType, err := getObjectTypeByString(headers[1].value)
+	if err != nil || string(objectType) != headers[1].value {
+		return links, []ObjectProblem{{Id: "badType", Message: "invalid 'type' value"}}
+	}
+	links = append(links, ObjectLink{Sha: headers[0].value, Type: objectType})
+
+	if len(headers) < 3 || headers[2].key != "tag" {
+		return links, []ObjectProblem{{Id: "missingTagEntry", Message: "invalid format - expected 'tag' line"}}
+	}
+	if len(headers) < 4 || headers[3].key != "tagger" {
+		return links, []ObjectProblem{{Id: "missingTaggerEntry", Message: "invalid format - expected 'tagger' line", Warning: true}}
+	}
+	if problem, valid := checkIdentity(headers[3].value); !valid {
+		return links, []ObjectProblem{problem}
+	}
+
+	return links, []ObjectProblem{}
+}
+
+type checkedHeader struct {
+	key   string
+	value string
+}
+
+// Header lines until the blank line before the message. Lines starting with a space continue the previous value
+func readCheckedHeaders(body []byte) ([]checkedHeader, ObjectProblem, bool) {
+	headers := make([]checkedHeader, 0)
+	if nulOffset := bytes.IndexByte(body, 0); nulOffset != -1 {
+		return headers, ObjectProblem{Id: "nulInHeader", Message: "unterminated header: NUL at offset " + strconv.Itoa(nulOffset)}, false
+	}
+
+	end := bytes.Index(body, []byte("\n\n"))
+	if end == -1 && !bytes.HasSuffix(body, []byte("\n")) {
+		return headers, ObjectProblem{Id: "unterminatedHeader", Message: "unterminated header"}, false
+	} else if end == -1 {
+		end = len(body) - 1
+	}
+
+	for _, line := range strings.Split(string(body[:end]), "\n") {
+		if strings.HasPrefix(line, " ") && len(headers) > 0 {
+			headers[len(headers)-1].value += "\n" + line[1:]
+			continue
+		}
+		key, value, _ := strings.Cut(line, " ")
+		headers = append(headers, checkedHeader{key: key, value: value})
+	}
+
+	return headers, ObjectProblem{}, true
+}
+
+func checkIdentity(value string) (ObjectProblem, bool) {
+	emailStart, emailEnd := strings.Index(value, "<"), strings.Index(value, ">")
+	switch {
+	case emailStart == -1:
+		return ObjectProblem{Id: "missingEmail", Message: "invalid author/committer line - missing email"}, false
+	case emailStart > 0 && value[emailStart-1] != ' ':
+		return ObjectProblem{Id: "missingSpaceBeforeEmail", Message: "invalid author/committer line - missing space before email"}, false
+	case emailEnd < emailStart || strings.Contains(value[emailStart+1:emailEnd], "<"):
+		return ObjectProblem{Id: "badEmail", Message: "invalid author/committer line - bad email"}, false
+	case !identityDateRegex.MatchString(strings.TrimPrefix(value[emailEnd+1:], " ")) || !strings.HasPrefix(value[emailEnd+1:], " "):
+		return ObjectProblem{Id: "badDate", Message: "invalid author/committer line - bad date"}, false
+	}
+
+	return ObjectProblem{}, true
+}
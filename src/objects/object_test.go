@@ -2,7 +2,9 @@ package objects
 
 import (
 	"bytes"
+	"encoding/hex"
 	"fmt"
+	"git/src/signing"
 	"git/src/utils"
 	"testing"
 
@@ -10,32 +12,32 @@ import (
 )
 
 func TestTreeObject_Serialize(t *testing.T) {
-	expectedBytes := []byte("tree 94" + string('\x00') + "README.md" + string('\x00') + "saaa5f1sf15fa5f1s15ssaaa5f1sf15fa5f1s15ssrc" + string('\x00') + "a5fa5f1s1sa5fa5f1s1sa5a5f1sf1sa5a5f1sf1s")
+	expectedBytes := []byte("tree 67" + string('\x00') + "100644 README.md" + string('\x00') + "\x1a\x2b\x3c\x4d\x5e\x6f\x70\x81\x92\xa3\xb4\xc5\xd6\xe7\xf8\x09\x1a\x2b\x3c\x4d" +
+		"40000 src" + string('\x00') + "\xa5\xfa\x5f\x1a\x1b\xa5\xfa\x5f\x1a\x1b\xa5\xa5\xf1\xaf\x1a\xa5\xa5\xf1\xaf\x1a")
 	object := Object{
 		Type: TREE,
 		SerializableGitObject: TreeObject{
 			Entries: []TreeEntry{
-				{Sha: "a5fa5f1s1sa5fa5f1s1sa5a5f1sf1sa5a5f1sf1s", Path: "src"},
-				{Sha: "saaa5f1sf15fa5f1s15ssaaa5f1sf15fa5f1s15s", Path: "README.md"},
+				{Mode: TREE_MODE_DIR, Sha: "a5fa5f1a1ba5fa5f1a1ba5a5f1af1aa5a5f1af1a", Path: "src"},
+				{Mode: TREE_MODE_FILE, Sha: "1a2b3c4d5e6f708192a3b4c5d6e7f8091a2b3c4d", Path: "README.md"},
 			},
 		},
 	}
 
 	serialized := object.Serialize()
 
-	fmt.Println(string(serialized))
-
-	assert.Equal(t, serialized, expectedBytes)
+	assert.Equal(t, expectedBytes, serialized)
 }
 
 func TestTreeObject_TreeDeserialize(t *testing.T) {
-	serializedBytes := []byte("tree 96" + string('\x00') + "README.md" + string('\x00') + "saaa5f1sf15fa5f1s15ssaaa5f1sf15fa5f1s15ssrc" + string('\x00') + "a5fa5f1s1sa5fa5f1s1sa5a5f1sf1sa5a5f1sf1s")
+	serializedBytes := []byte("tree 67" + string('\x00') + "100644 README.md" + string('\x00') + "\x1a\x2b\x3c\x4d\x5e\x6f\x70\x81\x92\xa3\xb4\xc5\xd6\xe7\xf8\x09\x1a\x2b\x3c\x4d" +
+		"40000 src" + string('\x00') + "\xa5\xfa\x5f\x1a\x1b\xa5\xfa\x5f\x1a\x1b\xa5\xa5\xf1\xaf\x1a\xa5\xa5\xf1\xaf\x1a")
 	expectedObject := Object{
 		Type: TREE,
 		SerializableGitObject: TreeObject{
 			Entries: []TreeEntry{
-				{Sha: "saaa5f1sf15fa5f1s15ssaaa5f1sf15fa5f1s15s", Path: "README.md"},
-				{Sha: "a5fa5f1s1sa5fa5f1s1sa5a5f1sf1sa5a5f1sf1s", Path: "src"},
+				{Mode: TREE_MODE_FILE, Sha: "1a2b3c4d5e6f708192a3b4c5d6e7f8091a2b3c4d", Path: "README.md"},
+				{Mode: TREE_MODE_DIR, Sha: "a5fa5f1a1ba5fa5f1a1ba5a5f1af1aa5a5f1af1a", Path: "src"},
 			},
 		},
 	}
@@ -44,6 +46,7 @@ func TestTreeObject_TreeDeserialize(t *testing.T) {
 
 	assert.Nil(t, err)
 	assert.Equal(t, expectedObject, actualObject)
+	assert.True(t, actualObject.SerializableGitObject.(TreeObject).Entries[1].IsDir())
 }
 
 func TestCommitObject_Deserialize(t *testing.T) {
@@ -58,7 +61,79 @@ func TestCommitObject_Deserialize(t *testing.T) {
 	assert.Equal(t, actualObject.SerializableGitObject.(CommitObject).Author, "Thibault Polge <thibault@thb.lt> 1527025023 +0200")
 	assert.Equal(t, actualObject.SerializableGitObject.(CommitObject).Committer, "Thibault Polge <thibault@thb.lt> 1527025044 +0200")
 	assert.Equal(t, actualObject.SerializableGitObject.(CommitObject).Message, "Create first commit")
-	assert.Equal(t, actualObject.SerializableGitObject.(CommitObject).keyValue.Keys(), []string{"tree", "parent", "author", "committer"})
+	assert.Equal(t, actualObject.SerializableGitObject.(CommitObject).getKeyValue().Keys(), []string{"tree", "parent", "author", "committer"})
+
+	_, err = DeserializeObject(bytes.NewReader(append(serializedBytes, '\n')))
+	assert.EqualError(t, err, "Object is longer than its size 231")
+	_, err = DeserializeObject(bytes.NewReader(serializedBytes[:len(serializedBytes)-1]))
+	assert.EqualError(t, err, "Object is shorter than its size 231")
+}
+
+func TestCommitObject_DeserializeMergeAndRootCommits(t *testing.T) {
+	mergeCommitBytes := []byte("commit 231" + string('\x00') + "tree 29ff16c9c14e2652b22f8b78bb08a5a07930c147\nparent 206941306e8a8af65b66eaaaea388a7ae24d49a0\n" +
+		"parent 1111111111111111111111111111111111111111\nauthor Jaime <j@j.com> 1527025023 +0200\ncommitter Jaime <j@j.com> 1527025044 +0200\n\nMerge")
+
+	mergeCommit, err := DeserializeObject(bytes.NewReader(mergeCommitBytes))
+
+	assert.Nil(t, err)
+	assert.Equal(t, "206941306e8a8af65b66eaaaea388a7ae24d49a0", mergeCommit.SerializableGitObject.(CommitObject).Parent)
+	assert.Equal(t, []string{"206941306e8a8af65b66eaaaea388a7ae24d49a0", "1111111111111111111111111111111111111111"}, mergeCommit.SerializableGitObject.(CommitObject).Parents)
+	assert.Equal(t, mergeCommitBytes, mergeCommit.Serialize())
+
+	rootCommit := CreateCommitObject("29ff16c9c14e2652b22f8b78bb08a5a07930c147", NO_PARENT_COMMIT_SHA, "Jaime <j@j.com> 1527025023 +0200", "Jaime <j@j.com> 1527025023 +0200", "Root")
+
+	assert.False(t, rootCommit.SerializableGitObject.(CommitObject).HasParent())
+	assert.NotContains(t, string(rootCommit.Serialize()), "parent")
+}
+
+func TestCommitObject_DeserializeExtraHeaders(t *testing.T) {
+	body := "tree 29ff16c9c14e2652b22f8b78bb08a5a07930c147\nauthor Jaime <j@j.com> 1527025023 +0200\n" +
+		"committer Jaime <j@j.com> 1527025044 +0200\nencoding ISO-8859-1\nmergetag object 1111111111111111111111111111111111111111\n" +
+		" type commit\n tag v1\n\nWith extra headers\n"
+
+	commit, err := DeserializeObjectBody(COMMIT, []byte(body))
+
+	assert.Nil(t, err)
+	assert.Equal(t, NO_PARENT_COMMIT_SHA, commit.SerializableGitObject.(CommitObject).Parent)
+	assert.Equal(t, "Jaime <j@j.com> 1527025044 +0200", commit.SerializableGitObject.(CommitObject).Committer)
+	assert.Equal(t, "With extra headers\n", commit.SerializableGitObject.(CommitObject).Message)
+	assert.Equal(t, "object 1111111111111111111111111111111111111111\ntype commit\ntag v1", commit.SerializableGitObject.(CommitObject).getKeyValue().Get("mergetag"))
+	assert.Equal(t, body, string(commit.SerializableGitObject.Serialize()))
+
+	_, err = DeserializeObjectBody(COMMIT, []byte("tree 29ff16c9c14e2652b22f8b78bb08a5a07930c147\n\nNo author"))
+	assert.NotNil(t, err)
+}
+
+func TestSignature_Parse(t *testing.T) {
+	signature := ParseSignature("Thibault Polge <thibault@thb.lt> 1527025023 +0200")
+
+	assert.Equal(t, "Thibault Polge", signature.Name)
+	assert.Equal(t, "thibault@thb.lt", signature.Email)
+	assert.Equal(t, int64(1527025023), signature.When.Unix())
+	assert.Equal(t, "+0200", signature.When.Format("-0700"))
+	assert.Equal(t, "Thibault Polge <thibault@thb.lt> 1527025023 +0200", signature.String())
+	assert.Equal(t, int64(1527025023), ParseSignatureTimestamp("Thibault Polge <thibault@thb.lt> 1527025023 +0200"))
+
+	oldSignature := ParseSignature("Jaime")
+	assert.Equal(t, "Jaime", oldSignature.Name)
+	assert.True(t, oldSignature.When.IsZero())
+	assert.Equal(t, oldSignature.When.Unix(), ParseSignatureTimestamp("Jaime"))
+}
+
+func TestTagObject_SerializeDeserialize(t *testing.T) {
+	tag := CreateTagObject("206941306e8a8af65b66eaaaea388a7ae24d49a0", COMMIT, "v1.0", "Jaime <j@j.com> 1527025023 +0200", "Release 1.0\n")
+	expectedBytes := []byte("tag 122" + string('\x00') + "object 206941306e8a8af65b66eaaaea388a7ae24d49a0\ntype commit\ntag v1.0\n" +
+		"tagger Jaime <j@j.com> 1527025023 +0200\n\nRelease 1.0\n")
+
+	assert.Equal(t, expectedBytes, tag.Serialize())
+
+	deserialized, err := DeserializeObject(bytes.NewReader(expectedBytes))
+	assert.Nil(t, err)
+	assert.Equal(t, "206941306e8a8af65b66eaaaea388a7ae24d49a0", deserialized.SerializableGitObject.(TagObject).Object)
+	assert.Equal(t, COMMIT, deserialized.SerializableGitObject.(TagObject).ObjectType)
+	assert.Equal(t, "v1.0", deserialized.SerializableGitObject.(TagObject).Tag)
+	assert.Equal(t, "Release 1.0\n", deserialized.SerializableGitObject.(TagObject).Message)
+	assert.Equal(t, expectedBytes, deserialized.Serialize())
 }
 
 func TestCommitObject_Serialize(t *testing.T) {
@@ -114,3 +189,60 @@ func TestKeyValueListSerialize(t *testing.T) {
 
 	assert.Equal(t, bytes, keyValueListSerialize(parsed))
 }
+
+func TestCommitObject_SignedPayload(t *testing.T) {
+	commit := CreateCommitObject("29ff16c9c14e2652b22f8b78bb08a5a07930c147", NO_PARENT_COMMIT_SHA, "Jaime <j@j.com> 1527025023 +0200",
+		"Jaime <j@j.com> 1527025023 +0200", "Signed")
+	payload, _ := commit.SerializableGitObject.(CommitObject).SignedPayload()
+	signed := SignCommitObject(commit, signing.SIGNATURE_BEGIN+"\nabc\n"+signing.SIGNATURE_END+"\n")
+
+	deserialized, err := DeserializeObject(bytes.NewReader(signed.Serialize()))
+	assert.Nil(t, err)
+	assert.Contains(t, string(signed.Serialize()), "gpgsig "+signing.SIGNATURE_BEGIN+"\n abc\n "+signing.SIGNATURE_END+"\n\nSigned")
+
+	deserializedPayload, signature := deserialized.SerializableGitObject.(CommitObject).SignedPayload()
+	assert.Equal(t, payload, deserializedPayload)
+	assert.Equal(t, signing.SIGNATURE_BEGIN+"\nabc\n"+signing.SIGNATURE_END+"\n", signature)
+	assert.Equal(t, signed.Serialize(), deserialized.Serialize())
+}
+
+func TestTagObject_SignedPayload(t *testing.T) {
+	tag := CreateTagObject("206941306e8a8af65b66eaaaea388a7ae24d49a0", COMMIT, "v1.0", "Jaime <j@j.com> 1527025023 +0200", "Release 1.0\n")
+	payload, signature := tag.SerializableGitObject.(TagObject).SignedPayload()
+	assert.Equal(t, "", signature)
+
+	signed := SignTagObject(tag, signing.SIGNATURE_BEGIN+"\nabc\n")
+	signedPayload, signature := signed.SerializableGitObject.(TagObject).SignedPayload()
+	assert.Equal(t, payload, signedPayload)
+	assert.Equal(t, signing.SIGNATURE_BEGIN+"\nabc\n", signature)
+}
+
+func TestObject_CheckObject(t *testing.T) {
+	tree := "4b825dc642cb6eb9a060e54bf8d69288fbee4904"
+	links, problems := CheckObject(COMMIT, []byte("tree "+tree+"\nparent "+tree+"\nauthor A <a@a> 1 +0000\ncommitter A <a@a> 1 +0000\n\nmessage\n"))
+	assert.Equal(t, []ObjectLink{{Sha: tree, Type: TREE}, {Sha: tree, Type: COMMIT}}, links)
+	assert.Empty(t, problems)
+
+	_, problems = CheckObject(COMMIT, []byte("tree "+tree+"\nauthor A a@a 1 +0000\ncommitter A <a@a> 1 +0000\n\nmessage\n"))
+	assert.Equal(t, "missingEmail: invalid author/committer line - missing email", problems[0].String())
+
+	_, problems = CheckObject(COMMIT, []byte("parent "+tree+"\n\nmessage\n"))
+	assert.Equal(t, "missingTree", problems[0].Id)
+
+	links, problems = CheckObject(TAG, []byte("object "+tree+"\ntype tree\ntag v1\n\nmessage\n"))
+	assert.Equal(t, []ObjectLink{{Sha: tree, Type: TREE}}, links)
+	assert.Equal(t, []ObjectProblem{{Id: "missingTaggerEntry", Message: "invalid format - expected 'tagger' line", Warning: true}}, problems)
+
+	rawSha, _ := hex.DecodeString(tree)
+	unsorted := []byte("100644 b\x00" + string(rawSha) + "40000 a\x00" + string(rawSha))
+	links, problems = CheckObject(TREE, unsorted)
+	assert.Equal(t, []ObjectLink{{Sha: tree, Type: BLOB}, {Sha: tree, Type: TREE}}, links)
+	assert.Equal(t, "treeNotSorted", problems[0].Id)
+
+	objectType, body, err := SplitObjectHeader([]byte("blob 3\x00abc"))
+	assert.Nil(t, err)
+	assert.Equal(t, BLOB, objectType)
+	assert.Equal(t, []byte("abc"), body)
+	_, _, err = SplitObjectHeader([]byte("blob 4\x00abc"))
+	assert.NotNil(t, err)
+}
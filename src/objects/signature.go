@@ -0,0 +1,97 @@
+package objects
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Signature Identity and date stored in author, committer and tagger. Format: Name <email> <unix timestamp> <timezone +hhmm>
+type Signature struct {
+	Name  string
+	Email string
+	When  time.Time
+}
+
+func CreateSignature(name string, email string, when time.Time) Signature {
+	return Signature{Name: name, Email: email, When: when}
+}
+
+// ParseSignature Missing parts are left empty. Old commits only stored the name
+func ParseSignature(value string) Signature {
+	emailStart := strings.Index(value, "<")
+	emailEnd := strings.Index(value, ">")
+	if emailStart == -1 || emailEnd < emailStart {
+		return Signature{Name: strings.TrimSpace(value)}
+	}
+
+	signature := Signature{
+		Name:  strings.TrimSpace(value[:emailStart]),
+		Email: value[emailStart+1 : emailEnd],
+	}
+
+	dateFields := strings.Fields(value[emailEnd+1:])
+	if len(dateFields) == 0 {
+		return signature
+	}
+	timestamp, err := strconv.ParseInt(dateFields[0], 10, 64)
+	if err != nil {
+		return signature
+	}
+
+	location := time.UTC
+	if len(dateFields) > 1 {
+		location = parseTimezone(dateFields[1])
+	}
+	signature.When = time.Unix(timestamp, 0).In(location)
+
+	return signature
+}
+
+// ParseSignatureTimestamp Unix timestamp of the signature, the same as ParseSignature(value).When.Unix(). History walks
+// only sort by it, so the name and the timezone are not parsed
+func ParseSignatureTimestamp(value string) int64 {
+	emailStart := strings.Index(value, "<")
+	emailEnd := strings.Index(value, ">")
+	if emailStart == -1 || emailEnd < emailStart {
+		return time.Time{}.Unix()
+	}
+
+	date := strings.TrimLeft(value[emailEnd+1:], " ")
+	if dateEnd := strings.IndexByte(date, ' '); dateEnd != -1 {
+		date = date[:dateEnd]
+	}
+	timestamp, err := strconv.ParseInt(date, 10, 64)
+	if err != nil {
+		return time.Time{}.Unix()
+	}
+	return timestamp
+}
+
+// Example: +0200, -0130
+func parseTimezone(timezone string) *time.Location {
+	if len(timezone) != 5 {
+		return time.UTC
+	}
+	hours, errHours := strconv.Atoi(timezone[1:3])
+	minutes, errMinutes := strconv.Atoi(timezone[3:5])
+	if errHours != nil || errMinutes != nil {
+		return time.UTC
+	}
+
+	offset := hours*3600 + minutes*60
+	if timezone[0] == '-' {
+		offset = -offset
+	}
+
+	return time.FixedZone(timezone, offset)
+}
+
+func (s Signature) String() string {
+	return s.Name + " <" + s.Email + "> " + strconv.FormatInt(s.When.Unix(), 10) + " " + s.When.Format("-0700")
+}
+
+// Identity Format: Name <email>
+func (s Signature) Identity() string {
+	return s.Name + " <" + s.Email + ">"
+}
@@ -2,7 +2,9 @@ package objects
 
 import (
 	"errors"
+	"git/src/signing"
 	"git/src/utils"
+	"strings"
 )
 
 type Reference struct {
@@ -10,47 +12,85 @@ type Reference struct {
 	Value    string
 }
 
+// TagObject Annotated tag. Format: object <sha>\ntype <type>\ntag <name>\ntagger <signature>\n\n<message>
 type TagObject struct {
-	ObjectTag string
-	Tagger    string
-	Tag       string
+	Object     string //Sha of the tagged object
+	ObjectType ObjectType
+	Tag        string //Name of the tag without refs/tags/
+	Tagger     string
+	Message    string
 
 	keyValue *utils.NavigationMap[string, string]
 }
 
-func CreateTagObject(objectTag string, tag string, tagger string) *Object {
+func CreateTagObject(object string, objectType ObjectType, tag string, tagger string, message string) *Object {
 	keyValue := utils.CreateNavigationMap[string, string]()
-	keyValue.Put("objectTag", objectTag)
-	keyValue.Put("tagger", tagger)
+	keyValue.Put("object", object)
+	keyValue.Put("type", string(objectType))
 	keyValue.Put("tag", tag)
+	keyValue.Put("tagger", tagger)
 
 	return &Object{
 		Type: TAG,
 		SerializableGitObject: TagObject{
-			ObjectTag: objectTag,
-			Tag:       tag,
-			Tagger:    tagger,
-			keyValue:  keyValue,
+			Object:     object,
+			ObjectType: objectType,
+			Tag:        tag,
+			Tagger:     tagger,
+			Message:    message,
+			keyValue:   keyValue,
 		},
 	}
 }
 
+// SignTagObject Returns the tag with the signature of its payload appended to the message
+func SignTagObject(tag *Object, signature string) *Object {
+	tagObject := tag.SerializableGitObject.(TagObject)
+	tagObject.Message = tagObject.Message + signature
+
+	return &Object{Type: TAG, SerializableGitObject: tagObject}
+}
+
+// SignedPayload Returns the data covered by the signature (the tag without the signature at the end of the message)
+// and the signature. The signature is empty if the tag is not signed
+func (t TagObject) SignedPayload() ([]byte, string) {
+	serialized := t.Serialize()
+	signatureStart := strings.LastIndex(t.Message, signing.SIGNATURE_BEGIN)
+	if signatureStart == -1 {
+		return serialized, ""
+	}
+
+	payloadLength := len(serialized) - len(t.Message) + signatureStart
+	return serialized[:payloadLength], t.Message[signatureStart:]
+}
+
+func (t TagObject) TaggerSignature() Signature {
+	return ParseSignature(t.Tagger)
+}
+
 func deserializeTagObject(toDeserialize []byte) (TagObject, error) {
-	deserializedKeyValue, _ := keyValueListDeserialize(toDeserialize)
-	if allContained := deserializedKeyValue.ContainsAll("objectTag", "tagger", "tag"); !allContained {
+	deserializedKeyValue, remainingData := keyValueListDeserialize(toDeserialize)
+	if allContained := deserializedKeyValue.ContainsAll("tag"); !allContained {
 		return TagObject{}, errors.New("Invalid key value format. Missing fields")
 	}
 
+	object := deserializedKeyValue.Get("object")
+	if !deserializedKeyValue.ContainsAll("object") { //Tags created by older versions
+		object = deserializedKeyValue.Get("objectTag")
+	}
+
 	tagObject := TagObject{
-		ObjectTag: deserializedKeyValue.Get("objectTag"),
-		Tagger:    deserializedKeyValue.Get("tagger"),
-		Tag:       deserializedKeyValue.Get("tag"),
-		keyValue:  deserializedKeyValue,
+		Object:     object,
+		ObjectType: ObjectType(deserializedKeyValue.Get("type")),
+		Tag:        deserializedKeyValue.Get("tag"),
+		Tagger:     deserializedKeyValue.Get("tagger"),
+		Message:    string(remainingData),
+		keyValue:   deserializedKeyValue,
 	}
 
 	return tagObject, nil
 }
 
-func (c TagObject) Serialize() []byte {
-	return keyValueListSerialize(c.keyValue)
+func (t TagObject) Serialize() []byte {
+	return append(keyValueListSerialize(t.keyValue), []byte(t.Message)...)
 }
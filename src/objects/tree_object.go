@@ -2,9 +2,18 @@ package objects
 
 import (
 	"bytes"
+	"encoding/hex"
+	"errors"
 	"git/src/utils"
 	"sort"
-	"strings"
+)
+
+const (
+	TREE_MODE_DIR        = "40000"
+	TREE_MODE_FILE       = "100644"
+	TREE_MODE_EXECUTABLE = "100755"
+	TREE_MODE_SYMLINK    = "120000" //Blob content is the target of the link
+	TREE_MODE_GITLINK    = "160000" //Submodule. Sha is a commit of other repository
 )
 
 type TreeObject struct {
@@ -12,6 +21,7 @@ type TreeObject struct {
 }
 
 type TreeEntry struct {
+	Mode string
 	Sha  string
 	Path string
 }
@@ -36,8 +46,10 @@ func (t TreeObject) sortEntries() {
 	})
 }
 
+// Format: <mode> <path>\x00<sha in binary (20 bytes)>
 func (t TreeEntry) serialize() []byte {
-	return []byte(t.Path + "\x00" + t.Sha)
+	shaBytes, _ := hex.DecodeString(t.Sha)
+	return append([]byte(t.Mode+" "+t.Path+"\x00"), shaBytes...)
 }
 
 func (t TreeEntry) formatPathToSort() string {
@@ -49,7 +61,19 @@ func (t TreeEntry) formatPathToSort() string {
 }
 
 func (t TreeEntry) IsDir() bool {
-	return strings.HasPrefix(t.Path, "10")
+	return t.Mode == TREE_MODE_DIR
+}
+
+func (t TreeEntry) IsGitlink() bool {
+	return t.Mode == TREE_MODE_GITLINK
+}
+
+func (t TreeEntry) IsSymlink() bool {
+	return t.Mode == TREE_MODE_SYMLINK
+}
+
+func (t TreeEntry) IsExecutable() bool {
+	return t.Mode == TREE_MODE_EXECUTABLE
 }
 
 func deserializeTreeObject(toDeserialize []byte) (TreeObject, error) {
@@ -69,16 +93,24 @@ func deserializeTreeObject(toDeserialize []byte) (TreeObject, error) {
 }
 
 func deserializeTreeObjectEntry(bytes []byte, offset int) (TreeEntry, int, error) {
+	modeBytes, offset, err := utils.ReadUntil(bytes, offset, ' ')
+	if err != nil {
+		return TreeEntry{}, -1, err
+	}
 	pathBytes, offset, err := utils.ReadUntil(bytes, offset, 0)
 	if err != nil {
 		return TreeEntry{}, -1, err
 	}
-	shaBytes := bytes[offset : offset+40]
+	if offset+20 > len(bytes) {
+		return TreeEntry{}, -1, errors.New("Invalid tree entry: sha is truncated for " + string(pathBytes))
+	}
+	shaBytes := bytes[offset : offset+20]
 
-	offset = offset + 40
+	offset = offset + 20
 
 	return TreeEntry{
-		Sha:  string(shaBytes),
+		Mode: string(modeBytes),
+		Sha:  hex.EncodeToString(shaBytes),
 		Path: string(pathBytes),
 	}, offset, nil
 }
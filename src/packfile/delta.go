@@ -0,0 +1,83 @@
+package packfile
+
+import "errors"
+
+// ApplyDelta Delta format: <base size varint><result size varint><instructions...>
+// Instruction with MSB set: copy from base. Lower 4 bits indicate which offset bytes follow, next 3 bits which size bytes follow
+// Instruction with MSB unset: insert the next <instruction> bytes
+func ApplyDelta(base []byte, delta []byte) ([]byte, error) {
+	baseSize, offset := readDeltaSize(delta, 0)
+	if int(baseSize) != len(base) {
+		return nil, errors.New("Delta base size doesnt match")
+	}
+	resultSize, offset := readDeltaSize(delta, offset)
+	result := make([]byte, 0, resultSize)
+
+	for offset < len(delta) {
+		instruction := delta[offset]
+		offset++
+
+		if instruction&0x80 != 0 {
+			copyOffset, copySize := 0, 0
+			for i := 0; i < 4; i++ {
+				if instruction&(1<<i) != 0 {
+					if offset >= len(delta) {
+						return nil, errors.New("Truncated delta copy instruction")
+					}
+					copyOffset |= int(delta[offset]) << (8 * i)
+					offset++
+				}
+			}
+			for i := 0; i < 3; i++ {
+				if instruction&(1<<(4+i)) != 0 {
+					if offset >= len(delta) {
+						return nil, errors.New("Truncated delta copy instruction")
+					}
+					copySize |= int(delta[offset]) << (8 * i)
+					offset++
+				}
+			}
+			if copySize == 0 {
+				copySize = 0x10000
+			}
+			if copyOffset+copySize > len(base) {
+				return nil, errors.New("Delta copy instruction out of base bounds")
+			}
+
+			result = append(result, base[copyOffset:copyOffset+copySize]...)
+		} else if instruction != 0 {
+			if offset+int(instruction) > len(delta) {
+				return nil, errors.New("Truncated delta insert instruction")
+			}
+
+			result = append(result, delta[offset:offset+int(instruction)]...)
+			offset += int(instruction)
+		} else {
+			return nil, errors.New("Invalid delta instruction 0")
+		}
+	}
+
+	if uint64(len(result)) != resultSize {
+		return nil, errors.New("Delta result size doesnt match")
+	}
+
+	return result, nil
+}
+
+func readDeltaSize(delta []byte, offset int) (uint64, int) {
+	var size uint64
+	shift := uint(0)
+
+	for offset < len(delta) {
+		actual := delta[offset]
+		offset++
+		size |= uint64(actual&0x7f) << shift
+		shift += 7
+
+		if actual&0x80 == 0 {
+			break
+		}
+	}
+
+	return size, offset
+}
@@ -0,0 +1,153 @@
+package packfile
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"hash/crc32"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Pack index (.idx) version 2 format: "\377tOc" <version uint32 = 2> <fanout 256 * uint32> <shas N * 20 bytes>
+// <crc32 N * uint32> <offsets N * uint32> <large offsets M * uint64> <pack checksum 20 bytes> <sha1 of all previous bytes>
+// Fanout entry i is the number of objects whose first byte is <= i. Offsets with the most significant bit set are the
+// position of the offset in the large offsets table
+
+var packIndexSignature = []byte{0xff, 't', 'O', 'c'}
+
+const packIndexLargeOffset = 0x80000000
+
+type PackIndexEntry struct {
+	Sha    string
+	Offset int64
+	Crc32  uint32 //Of the compressed object as stored in the pack, with its header
+}
+
+// PackIndex Entries are sorted by sha
+type PackIndex struct {
+	Entries      []PackIndexEntry
+	PackChecksum []byte
+}
+
+// IndexPack Creates the index of a packfile. Every object is read, so deltas must be resolvable inside the pack
+func IndexPack(packBytes []byte) (*PackIndex, error) {
+	state, err := readPack(packBytes, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]PackIndexEntry, 0, len(state.byOffset))
+	for offset, packObject := range state.byOffset {
+		end := state.ends[offset]
+		entries = append(entries, PackIndexEntry{Sha: packObject.Sha, Offset: offset, Crc32: crc32.ChecksumIEEE(packBytes[offset:end])})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Sha < entries[j].Sha })
+
+	return &PackIndex{Entries: entries, PackChecksum: append([]byte{}, packBytes[len(packBytes)-20:]...)}, nil
+}
+
+func (i *PackIndex) Serialize() []byte {
+	result := append([]byte{}, packIndexSignature...)
+	result = binary.BigEndian.AppendUint32(result, 2)
+
+	fanout := make([]uint32, 256)
+	for _, entry := range i.Entries {
+		firstByte, _ := strconv.ParseUint(entry.Sha[:2], 16, 8)
+		fanout[firstByte]++
+	}
+	total := uint32(0)
+	for _, count := range fanout {
+		total += count
+		result = binary.BigEndian.AppendUint32(result, total)
+	}
+
+	for _, entry := range i.Entries {
+		sha, _ := hex.DecodeString(entry.Sha)
+		result = append(result, sha...)
+	}
+	for _, entry := range i.Entries {
+		result = binary.BigEndian.AppendUint32(result, entry.Crc32)
+	}
+	largeOffsets := make([]byte, 0)
+	for _, entry := range i.Entries {
+		if entry.Offset < packIndexLargeOffset {
+			result = binary.BigEndian.AppendUint32(result, uint32(entry.Offset))
+		} else {
+			result = binary.BigEndian.AppendUint32(result, packIndexLargeOffset|uint32(len(largeOffsets)/8))
+			largeOffsets = binary.BigEndian.AppendUint64(largeOffsets, uint64(entry.Offset))
+		}
+	}
+	result = append(result, largeOffsets...)
+	result = append(result, i.PackChecksum...)
+
+	checksum := sha1.Sum(result)
+	return append(result, checksum[:]...)
+}
+
+func DeserializePackIndex(content []byte) (*PackIndex, error) {
+	if len(content) < 8+256*4+40 || !bytes.Equal(content[:4], packIndexSignature) {
+		return nil, errors.New("Invalid pack index signature")
+	}
+	if version := binary.BigEndian.Uint32(content[4:8]); version != 2 {
+		return nil, errors.New("Unsupported pack index version " + strconv.Itoa(int(version)))
+	}
+	checksum := sha1.Sum(content[:len(content)-20])
+	if !bytes.Equal(checksum[:], content[len(content)-20:]) {
+		return nil, errors.New("Pack index checksum doesnt match")
+	}
+
+	numberObjects := int(binary.BigEndian.Uint32(content[8+255*4:]))
+	shasStart := 8 + 256*4
+	crcsStart := shasStart + numberObjects*20
+	offsetsStart := crcsStart + numberObjects*4
+	largeOffsetsStart := offsetsStart + numberObjects*4
+	if largeOffsetsStart+40 > len(content) {
+		return nil, errors.New("Pack index is truncated")
+	}
+
+	entries := make([]PackIndexEntry, numberObjects)
+	for i := 0; i < numberObjects; i++ {
+		offset := int64(binary.BigEndian.Uint32(content[offsetsStart+i*4:]))
+		if offset&packIndexLargeOffset != 0 {
+			position := largeOffsetsStart + int(offset&^packIndexLargeOffset)*8
+			if position+8 > len(content)-40 {
+				return nil, errors.New("Pack index large offset out of bounds")
+			}
+			offset = int64(binary.BigEndian.Uint64(content[position:]))
+		}
+		entries[i] = PackIndexEntry{
+			Sha:    hex.EncodeToString(content[shasStart+i*20 : shasStart+(i+1)*20]),
+			Offset: offset,
+			Crc32:  binary.BigEndian.Uint32(content[crcsStart+i*4:]),
+		}
+		if i > 0 && entries[i-1].Sha >= entries[i].Sha {
+			return nil, errors.New("Pack index shas are not sorted")
+		}
+	}
+
+	packChecksum := append([]byte{}, content[len(content)-40:len(content)-20]...)
+	return &PackIndex{Entries: entries, PackChecksum: packChecksum}, nil
+}
+
+// Lookup Returns the offset in the pack of the object
+func (i *PackIndex) Lookup(sha string) (int64, bool) {
+	position := sort.Search(len(i.Entries), func(j int) bool { return i.Entries[j].Sha >= sha })
+	if position < len(i.Entries) && i.Entries[position].Sha == sha {
+		return i.Entries[position].Offset, true
+	}
+	return 0, false
+}
+
+// ShasWithPrefix Used to resolve abbreviated shas
+func (i *PackIndex) ShasWithPrefix(prefix string) []string {
+	result := make([]string, 0)
+	position := sort.Search(len(i.Entries), func(j int) bool { return i.Entries[j].Sha >= prefix })
+	for ; position < len(i.Entries) && strings.HasPrefix(i.Entries[position].Sha, prefix); position++ {
+		result = append(result, i.Entries[position].Sha)
+	}
+	return result
+}
@@ -0,0 +1,77 @@
+package packfile
+
+import (
+	"bytes"
+	"git/src/objects"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPackIndex_IndexAndReadPack(t *testing.T) {
+	base := CreatePackObject(objects.BLOB, []byte("hello world\n"))
+	// Copy "hello " from base (offset 0, size 6) and insert "git\n"
+	delta := []byte{12, 10, 0x80 | 0x10, 6, 4, 'g', 'i', 't', '\n'}
+	deltified := CreatePackObject(objects.BLOB, []byte("hello git\n"))
+
+	var pack bytes.Buffer
+	WritePack(&pack, []PackObject{base})
+	packBytes := pack.Bytes()[:len(pack.Bytes())-20]
+	packBytes[11] = 2 //Number of objects
+	deltaOffset := len(packBytes)
+	packBytes = append(packBytes, encodeTypeAndSize(packTypeOfsDelta, len(delta))...)
+	packBytes = append(packBytes, byte(deltaOffset-12))
+	packBytes = append(packBytes, compress(delta)...)
+	packBytes = appendChecksum(packBytes)
+
+	index, err := IndexPack(packBytes)
+	assert.Nil(t, err)
+	deserialized, err := DeserializePackIndex(index.Serialize())
+	assert.Nil(t, err)
+	assert.Equal(t, index, deserialized)
+	assert.Equal(t, packBytes[len(packBytes)-20:], index.PackChecksum)
+	baseOffset, found := index.Lookup(base.Sha)
+	assert.True(t, found)
+	assert.Equal(t, int64(12), baseOffset)
+	deltifiedOffset, found := index.Lookup(deltified.Sha)
+	assert.True(t, found)
+	assert.Equal(t, int64(deltaOffset), deltifiedOffset)
+	assert.Equal(t, []string{base.Sha}, index.ShasWithPrefix(base.Sha[:4]))
+
+	packPath := filepath.Join(t.TempDir(), "pack.pack")
+	os.WriteFile(packPath, packBytes, 0644)
+	openedPack, err := OpenPack(packPath)
+	assert.Nil(t, err)
+	defer openedPack.Close()
+
+	objectType, data, err := openedPack.ReadObject(deltifiedOffset, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, objects.BLOB, objectType)
+	assert.Equal(t, deltified.Data, data)
+	objectType, err = openedPack.ReadObjectType(deltifiedOffset, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, objects.BLOB, objectType)
+	_, _, err = openedPack.ReadObject(13, nil)
+	assert.NotNil(t, err)
+}
+
+func TestPackIndex_LargeOffsets(t *testing.T) {
+	index := &PackIndex{
+		Entries: []PackIndexEntry{
+			{Sha: "0000000000000000000000000000000000000001", Offset: 1 << 32, Crc32: 1},
+			{Sha: "ff00000000000000000000000000000000000000", Offset: 12, Crc32: 2},
+		},
+		PackChecksum: make([]byte, 20),
+	}
+
+	serialized := index.Serialize()
+	deserialized, err := DeserializePackIndex(serialized)
+
+	assert.Nil(t, err)
+	assert.Equal(t, index, deserialized)
+	serialized[len(serialized)-1] ^= 0xff
+	_, err = DeserializePackIndex(serialized)
+	assert.NotNil(t, err)
+}
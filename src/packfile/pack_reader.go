@@ -0,0 +1,166 @@
+package packfile
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"git/src/objects"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// Bases of deltas are usually shared by many objects, so the last ones read are kept
+const packBaseCacheSize = 1024
+
+// Pack Packfile stored in objects/pack. Objects are read by their offset, which is found in the index of the pack
+type Pack struct {
+	file      *os.File
+	size      int64
+	baseCache map[int64]PackObject
+	mutex     sync.Mutex
+}
+
+func OpenPack(path string) (*Pack, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	header := make([]byte, 12)
+	if _, err := file.ReadAt(header, 0); err != nil || string(header[:4]) != "PACK" || stat.Size() < 32 {
+		file.Close()
+		return nil, errors.New("Invalid packfile signature: " + path)
+	}
+	if version := binary.BigEndian.Uint32(header[4:8]); version != 2 && version != 3 {
+		file.Close()
+		return nil, errors.New("Unsupported packfile version " + strconv.Itoa(int(version)) + ": " + path)
+	}
+
+	return &Pack{file: file, size: stat.Size(), baseCache: make(map[int64]PackObject)}, nil
+}
+
+func (p *Pack) Close() error {
+	return p.file.Close()
+}
+
+// ReadObject Reads the object at the offset applying its deltas. Bases of REF_DELTA objects are found with resolveBase
+func (p *Pack) ReadObject(offset int64, resolveBase BaseResolver) (objects.ObjectType, []byte, error) {
+	reader, packType, size, err := p.openEntry(offset)
+	if err != nil {
+		return "", nil, err
+	}
+	defer entryReaders.Put(reader)
+
+	var base PackObject
+	switch packType {
+	case packTypeOfsDelta:
+		relativeOffset, err := readOffsetDeltaBase(reader)
+		if err != nil {
+			return "", nil, err
+		}
+		if base, err = p.readBase(offset-relativeOffset, resolveBase); err != nil {
+			return "", nil, err
+		}
+	case packTypeRefDelta:
+		baseSha := make([]byte, 20)
+		if _, err := io.ReadFull(reader, baseSha); err != nil {
+			return "", nil, err
+		}
+		found := false
+		if resolveBase != nil {
+			base, found = resolveBase(hex.EncodeToString(baseSha))
+		}
+		if !found {
+			return "", nil, errors.New("Base object " + hex.EncodeToString(baseSha) + " of delta not found")
+		}
+	}
+
+	data, err := readZlibData(reader, size)
+	if err != nil {
+		return "", nil, err
+	}
+	if packType != packTypeOfsDelta && packType != packTypeRefDelta {
+		objectType, err := packTypeToObjectType(packType)
+		return objectType, data, err
+	}
+
+	data, err = ApplyDelta(base.Data, data)
+	return base.Type, data, err
+}
+
+// ReadObjectType Only the headers of the delta chain are read
+func (p *Pack) ReadObjectType(offset int64, resolveBase BaseResolver) (objects.ObjectType, error) {
+	for {
+		reader, packType, _, err := p.openEntry(offset)
+		if err != nil {
+			return "", err
+		}
+		var relativeOffset int64
+		if packType == packTypeOfsDelta {
+			relativeOffset, err = readOffsetDeltaBase(reader)
+		}
+		entryReaders.Put(reader)
+
+		switch packType {
+		case packTypeOfsDelta:
+			if err != nil {
+				return "", err
+			}
+			offset -= relativeOffset
+		case packTypeRefDelta:
+			objectType, _, err := p.ReadObject(offset, resolveBase)
+			return objectType, err
+		default:
+			return packTypeToObjectType(packType)
+		}
+	}
+}
+
+var entryReaders = sync.Pool{New: func() any { return bufio.NewReaderSize(nil, 512) }}
+
+// Returns the reader of the entry after its type and size. Once the entry is read the reader is put back in entryReaders
+func (p *Pack) openEntry(offset int64) (*bufio.Reader, int, int, error) {
+	if offset < 12 || offset >= p.size-20 {
+		return nil, 0, 0, errors.New("Invalid packfile offset " + strconv.FormatInt(offset, 10))
+	}
+
+	reader := entryReaders.Get().(*bufio.Reader)
+	reader.Reset(io.NewSectionReader(p.file, offset, p.size-20-offset))
+	packType, size, err := readTypeAndSize(reader)
+	if err != nil {
+		entryReaders.Put(reader)
+		return nil, 0, 0, err
+	}
+	return reader, packType, size, nil
+}
+
+func (p *Pack) readBase(offset int64, resolveBase BaseResolver) (PackObject, error) {
+	p.mutex.Lock()
+	base, cached := p.baseCache[offset]
+	p.mutex.Unlock()
+	if cached {
+		return base, nil
+	}
+
+	objectType, data, err := p.ReadObject(offset, resolveBase)
+	if err != nil {
+		return PackObject{}, err
+	}
+	base = PackObject{Type: objectType, Data: data}
+
+	p.mutex.Lock()
+	if len(p.baseCache) >= packBaseCacheSize {
+		p.baseCache = make(map[int64]PackObject)
+	}
+	p.baseCache[offset] = base
+	p.mutex.Unlock()
+	return base, nil
+}
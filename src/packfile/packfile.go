@@ -0,0 +1,364 @@
+package packfile
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"git/src/objects"
+	"io"
+	"strconv"
+	"sync"
+)
+
+// Packfile format: "PACK" <version uint32> <number objects uint32> <objects...> <sha1 of all previous bytes>
+// Every object: <type and size varint><zlib compressed data>. Deltified objects contain their base before the data
+
+const (
+	packTypeCommit   = 1
+	packTypeTree     = 2
+	packTypeBlob     = 3
+	packTypeTag      = 4
+	packTypeOfsDelta = 6
+	packTypeRefDelta = 7
+)
+
+type PackObject struct {
+	Type objects.ObjectType
+	Sha  string
+	Data []byte
+}
+
+// BaseResolver Used to get the base objects of deltas that are not included in the packfile (thin packs)
+type BaseResolver func(sha string) (PackObject, bool)
+
+func CreatePackObject(objectType objects.ObjectType, data []byte) PackObject {
+	serialized := objects.CreateRawObject(objectType, data).Serialize()
+	sha1Hasher := sha1.New()
+	sha1Hasher.Write(serialized)
+
+	return PackObject{
+		Type: objectType,
+		Sha:  hex.EncodeToString(sha1Hasher.Sum(nil)),
+		Data: data,
+	}
+}
+
+// WritePack All objects are written without deltas
+func WritePack(writer io.Writer, packObjects []PackObject) error {
+	sha1Hasher := sha1.New()
+	multiWriter := io.MultiWriter(writer, sha1Hasher)
+
+	header := []byte("PACK")
+	header = binary.BigEndian.AppendUint32(header, 2)
+	header = binary.BigEndian.AppendUint32(header, uint32(len(packObjects)))
+	if _, err := multiWriter.Write(header); err != nil {
+		return err
+	}
+
+	for _, packObject := range packObjects {
+		if err := writePackObject(multiWriter, packObject); err != nil {
+			return err
+		}
+	}
+
+	_, err := writer.Write(sha1Hasher.Sum(nil))
+	return err
+}
+
+func writePackObject(writer io.Writer, packObject PackObject) error {
+	packType, err := objectTypeToPackType(packObject.Type)
+	if err != nil {
+		return err
+	}
+
+	var compressedBuffer bytes.Buffer
+	zlibWriter := zlib.NewWriter(&compressedBuffer)
+	if _, err := zlibWriter.Write(packObject.Data); err != nil {
+		return err
+	}
+	if err := zlibWriter.Close(); err != nil {
+		return err
+	}
+
+	if _, err := writer.Write(encodeTypeAndSize(packType, len(packObject.Data))); err != nil {
+		return err
+	}
+	_, err = writer.Write(compressedBuffer.Bytes())
+	return err
+}
+
+// First byte: <1 bit more><3 bits type><4 bits size>. Next bytes: <1 bit more><7 bits size>
+func encodeTypeAndSize(packType int, size int) []byte {
+	result := make([]byte, 0)
+	actual := byte(packType<<4) | byte(size&0x0f)
+	size >>= 4
+
+	for size > 0 {
+		result = append(result, actual|0x80)
+		actual = byte(size & 0x7f)
+		size >>= 7
+	}
+
+	return append(result, actual)
+}
+
+// ReadPack Reads all objects of the packfile resolving deltas
+func ReadPack(reader io.Reader, resolveBase BaseResolver) ([]PackObject, error) {
+	packBytes, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	state, err := readPack(packBytes, resolveBase)
+	if err != nil {
+		return nil, err
+	}
+	return state.objectsInOrder, nil
+}
+
+func readPack(packBytes []byte, resolveBase BaseResolver) (*readPackState, error) {
+	if len(packBytes) < 32 || string(packBytes[:4]) != "PACK" {
+		return nil, errors.New("Invalid packfile signature")
+	}
+	if version := binary.BigEndian.Uint32(packBytes[4:8]); version != 2 && version != 3 {
+		return nil, errors.New("Unsupported packfile version " + strconv.Itoa(int(version)))
+	}
+
+	checksum := sha1.Sum(packBytes[:len(packBytes)-20])
+	if !bytes.Equal(checksum[:], packBytes[len(packBytes)-20:]) {
+		return nil, errors.New("Packfile checksum doesnt match")
+	}
+
+	numberObjects := binary.BigEndian.Uint32(packBytes[8:12])
+	content := bytes.NewReader(packBytes[:len(packBytes)-20])
+	content.Seek(12, io.SeekStart)
+
+	state := &readPackState{
+		bySha:       make(map[string]PackObject),
+		byOffset:    make(map[int64]PackObject),
+		ends:        make(map[int64]int64),
+		resolveBase: resolveBase,
+	}
+	pendingDeltas := make([]pendingDelta, 0)
+
+	for i := 0; i < int(numberObjects); i++ {
+		objectOffset := int64(content.Size()) - int64(content.Len())
+		packType, size, err := readTypeAndSize(content)
+		if err != nil {
+			return nil, err
+		}
+
+		delta := pendingDelta{offset: objectOffset, packType: packType}
+		switch packType {
+		case packTypeOfsDelta:
+			relativeOffset, err := readOffsetDeltaBase(content)
+			if err != nil {
+				return nil, err
+			}
+			delta.baseOffset = objectOffset - relativeOffset
+		case packTypeRefDelta:
+			baseSha := make([]byte, 20)
+			if _, err := io.ReadFull(content, baseSha); err != nil {
+				return nil, err
+			}
+			delta.baseSha = hex.EncodeToString(baseSha)
+		}
+
+		data, err := readZlibData(content, size)
+		if err != nil {
+			return nil, err
+		}
+		state.ends[objectOffset] = int64(content.Size()) - int64(content.Len())
+
+		if packType == packTypeOfsDelta || packType == packTypeRefDelta {
+			delta.data = data
+			pendingDeltas = append(pendingDeltas, delta)
+			continue
+		}
+
+		objectType, err := packTypeToObjectType(packType)
+		if err != nil {
+			return nil, err
+		}
+
+		state.add(objectOffset, CreatePackObject(objectType, data))
+	}
+
+	if err := state.resolveDeltas(pendingDeltas); err != nil {
+		return nil, err
+	}
+
+	return state, nil
+}
+
+type pendingDelta struct {
+	offset     int64
+	packType   int
+	baseOffset int64
+	baseSha    string
+	data       []byte
+}
+
+type readPackState struct {
+	objectsInOrder []PackObject
+	bySha          map[string]PackObject
+	byOffset       map[int64]PackObject
+	ends           map[int64]int64 //Offset where the compressed data of the object at each offset ends
+	resolveBase    BaseResolver
+}
+
+func (s *readPackState) add(offset int64, packObject PackObject) {
+	s.objectsInOrder = append(s.objectsInOrder, packObject)
+	s.bySha[packObject.Sha] = packObject
+	s.byOffset[offset] = packObject
+}
+
+// Deltas can point to other deltas, so they are resolved until no progress is made
+func (s *readPackState) resolveDeltas(pendingDeltas []pendingDelta) error {
+	for len(pendingDeltas) > 0 {
+		stillPending := make([]pendingDelta, 0)
+
+		for _, delta := range pendingDeltas {
+			base, found := s.findBase(delta)
+			if !found {
+				stillPending = append(stillPending, delta)
+				continue
+			}
+
+			data, err := ApplyDelta(base.Data, delta.data)
+			if err != nil {
+				return err
+			}
+
+			s.add(delta.offset, CreatePackObject(base.Type, data))
+		}
+
+		if len(stillPending) == len(pendingDeltas) {
+			return errors.New("Cannot resolve " + strconv.Itoa(len(stillPending)) + " deltas. Base objects not found")
+		}
+
+		pendingDeltas = stillPending
+	}
+
+	return nil
+}
+
+func (s *readPackState) findBase(delta pendingDelta) (PackObject, bool) {
+	if delta.packType == packTypeOfsDelta {
+		base, found := s.byOffset[delta.baseOffset]
+		return base, found
+	}
+	if base, found := s.bySha[delta.baseSha]; found {
+		return base, true
+	}
+	if s.resolveBase != nil {
+		return s.resolveBase(delta.baseSha)
+	}
+
+	return PackObject{}, false
+}
+
+func readTypeAndSize(reader io.ByteReader) (int, int, error) {
+	actual, err := reader.ReadByte()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	packType := int(actual>>4) & 0x07
+	size := int(actual & 0x0f)
+	shift := 4
+
+	for actual&0x80 != 0 {
+		if actual, err = reader.ReadByte(); err != nil {
+			return 0, 0, err
+		}
+		size |= int(actual&0x7f) << shift
+		shift += 7
+	}
+
+	return packType, size, nil
+}
+
+// Offset encoding used by OFS_DELTA. Every continuation byte adds one to avoid redundant encodings
+func readOffsetDeltaBase(reader io.ByteReader) (int64, error) {
+	actual, err := reader.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+
+	offset := int64(actual & 0x7f)
+	for actual&0x80 != 0 {
+		if actual, err = reader.ReadByte(); err != nil {
+			return 0, err
+		}
+		offset = ((offset + 1) << 7) | int64(actual&0x7f)
+	}
+
+	return offset, nil
+}
+
+var zlibReaders sync.Pool
+
+// The reader implements io.ByteReader so the zlib reader doesnt read more than the compressed stream. The stream is read
+// until its end, so its checksum is verified. Zlib readers are reused, since most objects are small
+func readZlibData(reader flate.Reader, size int) ([]byte, error) {
+	zlibReader, isPooled := zlibReaders.Get().(io.ReadCloser)
+	if !isPooled || zlibReader.(zlib.Resetter).Reset(reader, nil) != nil {
+		var err error
+		if zlibReader, err = zlib.NewReader(reader); err != nil {
+			return nil, err
+		}
+	}
+	defer zlibReaders.Put(zlibReader)
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(zlibReader, data); err != nil {
+		return nil, err
+	}
+	var extra [1]byte
+	for {
+		read, err := zlibReader.Read(extra[:])
+		if read > 0 {
+			return nil, errors.New("Packfile object is bigger than its size " + strconv.Itoa(size))
+		}
+		if err == io.EOF {
+			return data, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func objectTypeToPackType(objectType objects.ObjectType) (int, error) {
+	switch objectType {
+	case objects.COMMIT:
+		return packTypeCommit, nil
+	case objects.TREE:
+		return packTypeTree, nil
+	case objects.BLOB:
+		return packTypeBlob, nil
+	case objects.TAG:
+		return packTypeTag, nil
+	default:
+		return 0, errors.New("Object type " + string(objectType) + " cannot be packed")
+	}
+}
+
+func packTypeToObjectType(packType int) (objects.ObjectType, error) {
+	switch packType {
+	case packTypeCommit:
+		return objects.COMMIT, nil
+	case packTypeTree:
+		return objects.TREE, nil
+	case packTypeBlob:
+		return objects.BLOB, nil
+	case packTypeTag:
+		return objects.TAG, nil
+	default:
+		return "", errors.New("Unknown packfile object type " + strconv.Itoa(packType))
+	}
+}
@@ -0,0 +1,79 @@
+package packfile
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/hex"
+	"git/src/objects"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPackfile_WriteAndRead(t *testing.T) {
+	blob := CreatePackObject(objects.BLOB, []byte("hello world\n"))
+	bigBlob := CreatePackObject(objects.BLOB, bytes.Repeat([]byte("a"), 5000))
+
+	var buffer bytes.Buffer
+	err := WritePack(&buffer, []PackObject{blob, bigBlob})
+	assert.Nil(t, err)
+
+	readObjects, err := ReadPack(bytes.NewReader(buffer.Bytes()), nil)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []PackObject{blob, bigBlob}, readObjects)
+	assert.Equal(t, "3b18e512dba79e4c8300dd08aeb37f8e728b8dad", blob.Sha)
+}
+
+func TestPackfile_ReadRefDeltaWithExternalBase(t *testing.T) {
+	base := CreatePackObject(objects.BLOB, []byte("hello world\n"))
+	// Copy "hello " from base (offset 0, size 6) and insert "git\n"
+	delta := []byte{12, 10, 0x80 | 0x10, 6, 4, 'g', 'i', 't', '\n'}
+	baseShaBytes, _ := hex.DecodeString(base.Sha)
+
+	var pack bytes.Buffer
+	WritePack(&pack, []PackObject{})
+	packBytes := pack.Bytes()[:len(pack.Bytes())-20]
+	packBytes[11] = 1 //Number of objects
+	packBytes = append(packBytes, encodeTypeAndSize(packTypeRefDelta, len(delta))...)
+	packBytes = append(packBytes, baseShaBytes...)
+	packBytes = append(packBytes, compress(delta)...)
+	packBytes = appendChecksum(packBytes)
+
+	readObjects, err := ReadPack(bytes.NewReader(packBytes), func(sha string) (PackObject, bool) {
+		return base, sha == base.Sha
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(readObjects))
+	assert.Equal(t, []byte("hello git\n"), readObjects[0].Data)
+	assert.Equal(t, objects.BLOB, readObjects[0].Type)
+
+	_, err = ReadPack(bytes.NewReader(packBytes), nil)
+	assert.NotNil(t, err)
+}
+
+func TestPackfile_ReadInvalidChecksum(t *testing.T) {
+	var buffer bytes.Buffer
+	WritePack(&buffer, []PackObject{CreatePackObject(objects.BLOB, []byte("a"))})
+	corrupted := buffer.Bytes()
+	corrupted[len(corrupted)-1] ^= 0xff
+
+	_, err := ReadPack(bytes.NewReader(corrupted), nil)
+
+	assert.NotNil(t, err)
+}
+
+func compress(data []byte) []byte {
+	var buffer bytes.Buffer
+	zlibWriter := zlib.NewWriter(&buffer)
+	zlibWriter.Write(data)
+	zlibWriter.Close()
+	return buffer.Bytes()
+}
+
+func appendChecksum(packBytes []byte) []byte {
+	checksum := sha1.Sum(packBytes)
+	return append(packBytes, checksum[:]...)
+}
@@ -0,0 +1,22 @@
+package protocol
+
+// INFINITE_DEPTH Depth requested to complete the history of a shallow repository (fetch --unshallow)
+const INFINITE_DEPTH = 0x7fffffff
+
+// DeepenOptions Shallow requests of upload-pack (shallow capability). Sent after the wants as:
+//
+//	deepen <depth>          only the commits at most depth - 1 generations from the wants
+//	deepen-since <time>     only the commits newer than the timestamp
+//
+// With the deepen-relative capability in the first want, depth is counted from the shallow commits of the client.
+// The server answers with the new shallow commits of the client (shallow <sha>) and the ones that are not shallow
+// anymore (unshallow <sha>) before the ACK or NAK
+type DeepenOptions struct {
+	Depth    int
+	Since    int64 //Unix timestamp. 0 if not set
+	Relative bool
+}
+
+func (d DeepenOptions) IsEmpty() bool {
+	return d.Depth <= 0 && d.Since == 0
+}
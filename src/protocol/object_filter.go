@@ -0,0 +1,61 @@
+package protocol
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// ObjectFilter Objects omitted by the server in partial clones (filter capability of upload-pack). Format of the spec:
+//
+//	blob:none            no blobs
+//	blob:limit=<n>[kmg]  no blobs of n bytes or more
+//
+// Objects explicitly wanted by the client are always sent
+type ObjectFilter struct {
+	Spec      string //Empty if objects are not filtered
+	BlobLimit int64  //Blobs of this size or bigger are omitted. 0 omits all of them, -1 none
+}
+
+func ParseObjectFilter(spec string) (ObjectFilter, error) {
+	if spec == "" {
+		return ObjectFilter{BlobLimit: -1}, nil
+	}
+	if spec == "blob:none" {
+		return ObjectFilter{Spec: spec, BlobLimit: 0}, nil
+	}
+
+	limit, isLimit := strings.CutPrefix(spec, "blob:limit=")
+	if !isLimit || limit == "" {
+		return ObjectFilter{}, errors.New("invalid filter-spec '" + spec + "'")
+	}
+
+	multiplier := int64(1)
+	switch limit[len(limit)-1] {
+	case 'k', 'K':
+		multiplier = 1024
+	case 'm', 'M':
+		multiplier = 1024 * 1024
+	case 'g', 'G':
+		multiplier = 1024 * 1024 * 1024
+	}
+	if multiplier > 1 {
+		limit = limit[:len(limit)-1]
+	}
+
+	size, err := strconv.ParseInt(limit, 10, 64)
+	if err != nil || size < 0 {
+		return ObjectFilter{}, errors.New("invalid filter-spec '" + spec + "'")
+	}
+
+	return ObjectFilter{Spec: spec, BlobLimit: size * multiplier}, nil
+}
+
+func (f ObjectFilter) IsEmpty() bool {
+	return f.Spec == ""
+}
+
+// IncludesBlob Returns false if the blob of the size is omitted
+func (f ObjectFilter) IncludesBlob(size int) bool {
+	return f.IsEmpty() || f.BlobLimit < 0 || int64(size) < f.BlobLimit
+}
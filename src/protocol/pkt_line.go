@@ -0,0 +1,91 @@
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+const MAX_PKT_LINE_DATA_LENGTH = 65516
+
+// FLUSH_PKT Marks the end of a section
+const FLUSH_PKT = "0000"
+
+// PktLine Line of the git wire protocol. Format: <4 hex digits length including themselves><data>
+// A flush packet (0000) is represented with Flush = true
+type PktLine struct {
+	Data  []byte
+	Flush bool
+}
+
+func EncodePktLine(data []byte) []byte {
+	return append([]byte(fmt.Sprintf("%04x", len(data)+4)), data...)
+}
+
+func EncodePktLineString(line string) []byte {
+	return EncodePktLine([]byte(line))
+}
+
+func WritePktLine(writer io.Writer, line string) error {
+	_, err := writer.Write(EncodePktLineString(line))
+	return err
+}
+
+func WriteFlush(writer io.Writer) error {
+	_, err := writer.Write([]byte(FLUSH_PKT))
+	return err
+}
+
+func ReadPktLine(reader io.Reader) (PktLine, error) {
+	lengthBytes := make([]byte, 4)
+	if _, err := io.ReadFull(reader, lengthBytes); err != nil {
+		return PktLine{}, err
+	}
+
+	length, err := strconv.ParseUint(string(lengthBytes), 16, 16)
+	if err != nil {
+		return PktLine{}, errors.New("Invalid pkt-line length: " + string(lengthBytes))
+	}
+	if length == 0 {
+		return PktLine{Flush: true}, nil
+	}
+	if length < 4 {
+		return PktLine{}, errors.New("Invalid pkt-line length: " + string(lengthBytes))
+	}
+
+	data := make([]byte, length-4)
+	if _, err := io.ReadFull(reader, data); err != nil {
+		return PktLine{}, err
+	}
+
+	return PktLine{Data: data}, nil
+}
+
+// ReadPktLinesUntilFlush Reads pkt-lines until a flush packet or EOF is found. Trailing \n of every line is removed
+func ReadPktLinesUntilFlush(reader io.Reader) ([]string, error) {
+	lines := make([]string, 0)
+
+	for {
+		line, err := ReadPktLine(reader)
+		if err == io.EOF {
+			return lines, nil
+		}
+		if err != nil {
+			return lines, err
+		}
+		if line.Flush {
+			return lines, nil
+		}
+
+		lines = append(lines, line.String())
+	}
+}
+
+func (p PktLine) String() string {
+	if len(p.Data) > 0 && p.Data[len(p.Data)-1] == '\n' {
+		return string(p.Data[:len(p.Data)-1])
+	}
+
+	return string(p.Data)
+}
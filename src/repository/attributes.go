@@ -0,0 +1,168 @@
+package repository
+
+import (
+	"git/src/attributes"
+	"git/src/utils"
+	"os"
+	"strings"
+)
+
+// ReadAttributes Reads the .gitattributes files of the work tree. Files deleted from the work tree are read from the index.
+// Bare repositories only have the global and info/attributes rules
+func (r *Repository) ReadAttributes() (*attributes.Attributes, error) {
+	var indexEntries map[string]string //Path -> sha. Read the first time a .gitattributes is not in the work tree
+
+	return r.newAttributes(func(dir string) ([]byte, bool, error) {
+		if r.WorkTree == "" {
+			return nil, false, nil
+		}
+
+		path := gitAttributesPath(dir)
+		content, err := os.ReadFile(utils.Path(r.WorkTree, path))
+		if err == nil {
+			return content, true, nil
+		} else if !os.IsNotExist(err) {
+			return nil, false, err
+		}
+
+		if indexEntries == nil {
+			indexObject, err := r.ReadIndex()
+			if err != nil {
+				return nil, false, err
+			}
+			indexEntries = make(map[string]string)
+			for entryPath, entry := range indexObject.Entries {
+				indexEntries[entryPath] = entry.Sha
+			}
+		}
+
+		return r.readAttributesBlob(indexEntries[path])
+	})
+}
+
+// ReadIndexAttributes Reads the .gitattributes files of the index, ignoring the work tree
+func (r *Repository) ReadIndexAttributes() (*attributes.Attributes, error) {
+	indexObject, err := r.ReadIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	return r.newAttributes(func(dir string) ([]byte, bool, error) {
+		return r.readAttributesBlob(indexObject.Entries[gitAttributesPath(dir)].Sha)
+	})
+}
+
+// ReadTreeAttributes Reads the .gitattributes files of the tree. Used while checking it out, since the work tree still has the old ones
+func (r *Repository) ReadTreeAttributes(treeSha string) (*attributes.Attributes, error) {
+	return r.newAttributes(func(dir string) ([]byte, bool, error) {
+		entry, err := r.FindTreeEntry(treeSha, gitAttributesPath(dir))
+		if err != nil || entry.IsDir() {
+			return nil, false, nil
+		}
+		return r.readAttributesBlob(entry.Sha)
+	})
+}
+
+// GetEolConversion Line ending conversion of the file, from its attributes, core.autocrlf and core.eol
+func (r *Repository) GetEolConversion(fileAttributes attributes.FileAttributes) string {
+	autoCrlf := attributes.AUTOCRLF_FALSE
+	if value := r.Config.String("core.autocrlf"); strings.ToLower(value) == attributes.AUTOCRLF_INPUT {
+		autoCrlf = attributes.AUTOCRLF_INPUT
+	} else if r.Config.MustBool("core.autocrlf", false) {
+		autoCrlf = attributes.AUTOCRLF_TRUE
+	}
+
+	return attributes.GetEolConversion(fileAttributes, autoCrlf, strings.ToLower(r.Config.String("core.eol")))
+}
+
+// ConvertToGit Applies the conversions of the file (path relative to the work tree) to content read from the work tree:
+// the clean filter and then the line ending conversion
+func (r *Repository) ConvertToGit(repositoryAttributes *attributes.Attributes, path string, content []byte) ([]byte, error) {
+	fileAttributes, err := repositoryAttributes.Get(path)
+	if err != nil {
+		return nil, err
+	}
+
+	filtered, err := r.applyFilter(fileAttributes, path, content, CLEAN_FILTER)
+	if err != nil {
+		return nil, err
+	}
+	return attributes.ConvertToGit(filtered, r.GetEolConversion(fileAttributes)), nil
+}
+
+// ConvertToWorkTree Applies the conversions of the file (path relative to the work tree) to the content of its blob, in the
+// reverse order of ConvertToGit: the line ending conversion and then the smudge filter
+func (r *Repository) ConvertToWorkTree(repositoryAttributes *attributes.Attributes, path string, content []byte) ([]byte, error) {
+	fileAttributes, err := repositoryAttributes.Get(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.applyFilter(fileAttributes, path, attributes.ConvertToWorkTree(content, r.GetEolConversion(fileAttributes)), SMUDGE_FILTER)
+}
+
+// Global rules come from core.attributesFile, by default $XDG_CONFIG_HOME/git/attributes (~/.config/git/attributes)
+func (r *Repository) newAttributes(readFile attributes.FileReader) (*attributes.Attributes, error) {
+	globalPath, err := r.Config.Path("core.attributesFile")
+	if err != nil {
+		return nil, err
+	}
+	if globalPath == "" {
+		globalPath = defaultGlobalAttributesPath()
+	}
+
+	global, err := readOptionalFile(globalPath)
+	if err != nil {
+		return nil, err
+	}
+	info, err := readOptionalFile(utils.Paths(r.CommonDir, "info", "attributes"))
+	if err != nil {
+		return nil, err
+	}
+
+	return attributes.NewAttributes(global, info, readFile), nil
+}
+
+func (r *Repository) readAttributesBlob(sha string) ([]byte, bool, error) {
+	if sha == "" {
+		return nil, false, nil
+	}
+
+	blob, err := r.ReadBlobObject(sha)
+	if err != nil {
+		return nil, false, err
+	}
+	return blob.Data, true, nil
+}
+
+func gitAttributesPath(dir string) string {
+	if dir == "" {
+		return ".gitattributes"
+	}
+	return utils.Path(dir, ".gitattributes")
+}
+
+func defaultGlobalAttributesPath() string {
+	if xdgConfigHome := os.Getenv("XDG_CONFIG_HOME"); xdgConfigHome != "" {
+		return utils.Paths(xdgConfigHome, "git", "attributes")
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return utils.Paths(home, ".config", "git", "attributes")
+}
+
+// Files that dont exist are read as nil
+func readOptionalFile(path string) ([]byte, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return content, err
+}
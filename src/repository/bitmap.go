@@ -0,0 +1,293 @@
+package repository
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"git/src/ewah"
+	"git/src/midx"
+	"git/src/objects"
+	"git/src/utils"
+	"os"
+)
+
+// BITMAP_COMMIT_INTERVAL Besides the refs, one of every this many commits has a bitmap. Walks to find the reachable objects
+// of any other commit stop at the closest commits with bitmaps
+const BITMAP_COMMIT_INTERVAL = 100
+
+var errNotInMultiPackIndex = errors.New("object is not in the multi-pack-index")
+
+// Bitmaps of the multi-pack-index. Bits are positions in the pseudo-pack order
+type reachabilityIndex struct {
+	multiPackIndex *midx.MultiPackIndex
+	order          []int                //Bit -> position in the multi-pack-index
+	bitPositions   []int                //Position in the multi-pack-index -> bit
+	bitmaps        map[int]*ewah.Bitmap //Position of the commit in the multi-pack-index -> objects reachable from it
+}
+
+func newReachabilityIndex(multiPackIndex *midx.MultiPackIndex, order []int) *reachabilityIndex {
+	bitPositions := make([]int, len(order))
+	for bit, position := range order {
+		bitPositions[position] = bit
+	}
+	return &reachabilityIndex{multiPackIndex: multiPackIndex, order: order, bitPositions: bitPositions, bitmaps: make(map[int]*ewah.Bitmap)}
+}
+
+// multi-pack-index-<checksum of the multi-pack-index>.bitmap
+func (r *Repository) multiPackIndexBitmapPath(multiPackIndex *midx.MultiPackIndex) string {
+	return utils.Path(r.PackDir(), "multi-pack-index-"+hex.EncodeToString(multiPackIndex.Checksum)+".bitmap")
+}
+
+func (r *Repository) readReachabilityIndex(multiPackIndex *midx.MultiPackIndex) (*reachabilityIndex, error) {
+	order, hasOrder := multiPackIndex.PseudoPackOrder()
+	if !hasOrder {
+		return nil, errors.New("multi-pack-index has no reverse index")
+	}
+	content, err := os.ReadFile(r.multiPackIndexBitmapPath(multiPackIndex))
+	if err != nil {
+		return nil, err
+	}
+	bitmaps, err := midx.DeserializeBitmaps(content)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(bitmaps.Checksum, multiPackIndex.Checksum) {
+		return nil, errors.New("bitmap checksum does not match the multi-pack-index")
+	}
+
+	index := newReachabilityIndex(multiPackIndex, order)
+	for position, bitmap := range bitmaps.Entries {
+		if position >= multiPackIndex.Len() {
+			return nil, errors.New("bitmap of an object out of the multi-pack-index")
+		}
+		index.bitmaps[position] = bitmap
+	}
+	return index, nil
+}
+
+// The bitmaps are read once. They are not used if the repository is shallow or pack.useBitmaps is false
+func (r *Repository) getReachabilityIndex() *reachabilityIndex {
+	store := r.readPacks()
+	if store.reachabilityRead {
+		return store.reachability
+	}
+	store.reachabilityRead = true
+
+	if store.multiPackIndex == nil || !r.Config.MustBool("pack.useBitmaps", true) || r.IsShallow() {
+		return nil
+	}
+	if _, err := os.Stat(r.multiPackIndexBitmapPath(store.multiPackIndex)); err != nil {
+		return nil
+	}
+	index, err := r.readReachabilityIndex(store.multiPackIndex)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "warning: ignoring "+r.multiPackIndexBitmapPath(store.multiPackIndex)+": "+err.Error())
+		return nil
+	}
+
+	store.reachability = index
+	return index
+}
+
+// ReachableObjectsWithBitmaps Objects reachable from the wants and not from the haves, in pseudo-pack order. Returns false
+// if there are no bitmaps or any of the objects is not in the multi-pack-index, so the objects must be found walking the graph
+func (r *Repository) ReachableObjectsWithBitmaps(wants []string, haves []string) ([]string, bool, error) {
+	index := r.getReachabilityIndex()
+	if index == nil {
+		return nil, false, nil
+	}
+
+	wantBits, err := r.getReachableBits(index, wants)
+	if err == nil {
+		var haveBits []uint64
+		if haveBits, err = r.getReachableBits(index, haves); err == nil {
+			for i := range wantBits {
+				wantBits[i] &^= haveBits[i]
+			}
+		}
+	}
+	if err == errNotInMultiPackIndex {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	result := make([]string, 0)
+	for bit := 0; bit < len(index.order); bit++ {
+		if wantBits[bit/64]&(1<<(bit%64)) != 0 {
+			result = append(result, index.multiPackIndex.ShaAt(index.order[bit]))
+		}
+	}
+	return result, true, nil
+}
+
+type bitmapWalkItem struct {
+	sha  string
+	blob bool //Blobs are not read, their type is known from the tree
+}
+
+// Every object reachable from the tips. The walk stops at the commits with bitmaps and at the objects already found,
+// whose reachable objects are already set
+func (r *Repository) getReachableBits(index *reachabilityIndex, tips []string) ([]uint64, error) {
+	bits := make([]uint64, (len(index.order)+63)/64)
+	pending := make([]bitmapWalkItem, 0, len(tips))
+	for _, sha := range tips {
+		pending = append(pending, bitmapWalkItem{sha: sha})
+	}
+
+	for len(pending) > 0 {
+		item := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+
+		position, found := index.multiPackIndex.Position(item.sha)
+		if !found {
+			return nil, errNotInMultiPackIndex
+		}
+		bit := index.bitPositions[position]
+		if bits[bit/64]&(1<<(bit%64)) != 0 {
+			continue
+		}
+		if bitmap, hasBitmap := index.bitmaps[position]; hasBitmap {
+			bitmap.OrInto(bits)
+			continue
+		}
+		bits[bit/64] |= 1 << (bit % 64)
+		if item.blob {
+			continue
+		}
+
+		rawObject, err := r.ReadRawObject(item.sha)
+		if err != nil {
+			return nil, err
+		}
+		parsedObject, err := objects.DeserializeObjectBody(rawObject.Type, rawObject.SerializableGitObject.Serialize())
+		if err != nil {
+			return nil, err
+		}
+
+		switch parsedObject.Type {
+		case objects.COMMIT:
+			commit := parsedObject.SerializableGitObject.(objects.CommitObject)
+			pending = append(pending, bitmapWalkItem{sha: commit.Tree})
+			for _, parent := range commit.Parents {
+				pending = append(pending, bitmapWalkItem{sha: parent})
+			}
+		case objects.TREE:
+			for _, entry := range parsedObject.SerializableGitObject.(objects.TreeObject).Entries {
+				if !entry.IsGitlink() { //Submodule commits belong to other repositories
+					pending = append(pending, bitmapWalkItem{sha: entry.Sha, blob: !entry.IsDir()})
+				}
+			}
+		case objects.TAG:
+			pending = append(pending, bitmapWalkItem{sha: parsedObject.SerializableGitObject.(objects.TagObject).Object})
+		}
+	}
+
+	return bits, nil
+}
+
+// Bitmaps are written for the commits pointed by the refs and one of every BITMAP_COMMIT_INTERVAL commits. They are
+// calculated from the oldest, so the walk of each commit stops at the previous bitmaps
+func (r *Repository) writeMultiPackIndexBitmap() error {
+	if r.IsShallow() {
+		return errors.New("cannot write bitmaps in a shallow repository")
+	}
+	multiPackIndex := r.readPacks().multiPackIndex
+	if multiPackIndex == nil {
+		return errors.New("cannot write bitmaps without a multi-pack-index")
+	}
+	order, _ := multiPackIndex.PseudoPackOrder()
+	index := newReachabilityIndex(multiPackIndex, order)
+
+	tips, err := r.getCommitGraphTips()
+	if err != nil {
+		return err
+	}
+	commits, err := r.getCommitsParentsFirst(tips)
+	if err != nil {
+		return err
+	}
+	isTip := make(map[string]bool)
+	for _, tip := range tips {
+		isTip[tip] = true
+	}
+
+	for i, commit := range commits {
+		if !isTip[commit] && (i+1)%BITMAP_COMMIT_INTERVAL != 0 {
+			continue
+		}
+		bits, err := r.getReachableBits(index, []string{commit})
+		if err == errNotInMultiPackIndex {
+			return errors.New("cannot write bitmaps: objects reachable from " + commit + " are not in the multi-pack-index")
+		}
+		if err != nil {
+			return err
+		}
+		position, _ := multiPackIndex.Position(commit)
+		index.bitmaps[position] = ewah.Compress(bits, len(order))
+	}
+
+	typeBits := map[objects.ObjectType][]uint64{}
+	for _, objectType := range []objects.ObjectType{objects.COMMIT, objects.TREE, objects.BLOB, objects.TAG} {
+		typeBits[objectType] = make([]uint64, (len(order)+63)/64)
+	}
+	for bit, position := range order {
+		objectType, err := r.readPackedObjectType(multiPackIndex.ShaAt(position))
+		if err != nil {
+			return err
+		}
+		typeBits[objectType][bit/64] |= 1 << (bit % 64)
+	}
+
+	content := midx.SerializeBitmaps(&midx.Bitmaps{
+		Checksum: multiPackIndex.Checksum,
+		Commits:  ewah.Compress(typeBits[objects.COMMIT], len(order)),
+		Trees:    ewah.Compress(typeBits[objects.TREE], len(order)),
+		Blobs:    ewah.Compress(typeBits[objects.BLOB], len(order)),
+		Tags:     ewah.Compress(typeBits[objects.TAG], len(order)),
+		Entries:  index.bitmaps,
+	})
+	return writeFileAtomically(r.multiPackIndexBitmapPath(multiPackIndex), content)
+}
+
+// Every commit reachable from the tips, with the parents of each commit before it
+func (r *Repository) getCommitsParentsFirst(tips []string) ([]string, error) {
+	const (
+		pendingState = iota
+		walkingState
+		doneState
+	)
+	states := make(map[string]int)
+	result := make([]string, 0)
+	stack := make([]string, 0, len(tips))
+	for i := len(tips) - 1; i >= 0; i-- {
+		stack = append(stack, tips[i])
+	}
+
+	for len(stack) > 0 {
+		sha := stack[len(stack)-1]
+		switch states[sha] {
+		case doneState:
+			stack = stack[:len(stack)-1]
+		case walkingState:
+			states[sha] = doneState
+			result = append(result, sha)
+			stack = stack[:len(stack)-1]
+		default:
+			states[sha] = walkingState
+			node, err := r.ReadCommitNode(sha)
+			if err != nil {
+				return nil, err
+			}
+			for _, parent := range node.Parents {
+				if states[parent] == pendingState {
+					stack = append(stack, parent)
+				}
+			}
+		}
+	}
+
+	return result, nil
+}
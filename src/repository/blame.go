@@ -0,0 +1,218 @@
+package repository
+
+import (
+	"container/heap"
+	"errors"
+	"git/src/diff"
+	"git/src/objects"
+)
+
+// BlameLine Commit that introduced a line of the blamed file. Lines of the work tree that are not committed have NULL sha
+type BlameLine struct {
+	Sha          string
+	Commit       objects.CommitObject
+	Path         string //Path of the file in the commit, it can be different if the file was renamed
+	OriginalLine int    //Line number in the file of the commit, starting at 1
+	FinalLine    int
+	Content      string
+	Boundary     bool   //Root commit, its lines cannot be passed to an older commit
+	PreviousSha  string //First parent that contains the file. Empty if there is none
+	PreviousPath string
+}
+
+// blameSuspect Commit that may have introduced the lines, before checking its parents
+type blameSuspect struct {
+	path  string
+	lines map[int]int //Line index of the final file -> line index in the file of the suspect
+}
+
+// Blame Returns the commit that introduced every line of the file at path in commitSha. Commits are visited newest first
+// and the lines that are unchanged in a parent (according to the line diff) are passed to it. Renames are followed.
+// If workTreeContent is not nil it is blamed instead, and its lines that are not in commitSha are not committed
+func (r *Repository) Blame(commitSha string, path string, workTreeContent []byte) ([]BlameLine, error) {
+	treeFiles := make(map[string]map[string]objects.TreeEntry) //Cache tree sha -> files
+	commit, err := r.ReadCommitObject(commitSha)
+	if err != nil {
+		return nil, err
+	}
+	files, err := r.readBlameTreeFiles(commit.Tree, treeFiles)
+	if err != nil {
+		return nil, err
+	}
+	entry, found := files[path]
+	if !found {
+		return nil, errors.New("no such path '" + path + "' in " + commitSha)
+	}
+	content, err := r.ReadBlobObject(entry.Sha)
+	if err != nil {
+		return nil, err
+	}
+
+	finalContent := content.Data
+	if workTreeContent != nil {
+		finalContent = workTreeContent
+	}
+	finalLines := diff.SplitLines(finalContent)
+	result := make([]BlameLine, len(finalLines))
+
+	suspectLines := make(map[int]int)
+	for finalLine, commitLine := range diff.MapLines(content.Data, finalContent) {
+		if commitLine == -1 {
+			result[finalLine] = BlameLine{Sha: diff.NULL_SHA, Path: path, OriginalLine: finalLine + 1, PreviousSha: commitSha, PreviousPath: path}
+		} else {
+			suspectLines[finalLine] = commitLine
+		}
+	}
+
+	queue := &commitQueue{}
+	suspects := make(map[string]map[string]*blameSuspect) //Commit sha -> path -> suspect
+	addSuspect := func(sha string, commit objects.CommitObject, path string, lines map[int]int) {
+		if _, queued := suspects[sha]; !queued {
+			suspects[sha] = make(map[string]*blameSuspect)
+			heap.Push(queue, &queuedCommit{RevCommit: RevCommit{Sha: sha, Commit: commit}, when: commit.CommitTime(), order: len(suspects)})
+		}
+		if suspects[sha][path] == nil {
+			suspects[sha][path] = &blameSuspect{path: path, lines: make(map[int]int)}
+		}
+		for finalLine, line := range lines {
+			suspects[sha][path].lines[finalLine] = line
+		}
+	}
+	if len(suspectLines) > 0 {
+		addSuspect(commitSha, commit, path, suspectLines)
+	}
+
+	for queue.Len() > 0 {
+		actual := heap.Pop(queue).(*queuedCommit)
+		for _, suspect := range suspects[actual.Sha] {
+			blamed, err := r.passBlameToParents(actual.RevCommit, suspect, treeFiles, addSuspect)
+			if err != nil {
+				return nil, err
+			}
+			for finalLine, _ := range suspect.lines {
+				result[finalLine] = blamed
+				result[finalLine].OriginalLine = suspect.lines[finalLine] + 1
+			}
+		}
+		delete(suspects, actual.Sha)
+	}
+
+	for i, line := range finalLines {
+		result[i].FinalLine = i + 1
+		result[i].Content = line
+	}
+
+	return result, nil
+}
+
+// Lines found in a parent are removed from the suspect and added to the parent. Returns the blame of the remaining lines
+func (r *Repository) passBlameToParents(suspectCommit RevCommit, suspect *blameSuspect, treeFiles map[string]map[string]objects.TreeEntry,
+	addSuspect func(sha string, commit objects.CommitObject, path string, lines map[int]int)) (BlameLine, error) {
+	blamed := BlameLine{Sha: suspectCommit.Sha, Commit: suspectCommit.Commit, Path: suspect.path, Boundary: !suspectCommit.Commit.HasParent()}
+
+	files, err := r.readBlameTreeFiles(suspectCommit.Commit.Tree, treeFiles)
+	if err != nil {
+		return BlameLine{}, err
+	}
+	entry := files[suspect.path]
+
+	for _, parentSha := range suspectCommit.Commit.Parents {
+		if len(suspect.lines) == 0 {
+			break
+		}
+
+		parent, err := r.ReadCommitObject(parentSha)
+		if err != nil {
+			return BlameLine{}, err
+		}
+		parentFiles, err := r.readBlameTreeFiles(parent.Tree, treeFiles)
+		if err != nil {
+			return BlameLine{}, err
+		}
+		parentPath, err := r.findPathInParent(parentFiles, files, suspect.path)
+		if err != nil {
+			return BlameLine{}, err
+		}
+		if parentPath == "" {
+			continue
+		}
+		if blamed.PreviousSha == "" {
+			blamed.PreviousSha, blamed.PreviousPath = parentSha, parentPath
+		}
+
+		parentEntry := parentFiles[parentPath]
+		if parentEntry.Sha == entry.Sha {
+			addSuspect(parentSha, parent, parentPath, suspect.lines)
+			suspect.lines = make(map[int]int)
+			break
+		}
+
+		parentBlob, err := r.ReadBlobObject(parentEntry.Sha)
+		if err != nil {
+			return BlameLine{}, err
+		}
+		blob, err := r.ReadBlobObject(entry.Sha)
+		if err != nil {
+			return BlameLine{}, err
+		}
+
+		mapping := diff.MapLines(parentBlob.Data, blob.Data)
+		passed := make(map[int]int)
+		for finalLine, line := range suspect.lines {
+			if mapping[line] != -1 {
+				passed[finalLine] = mapping[line]
+				delete(suspect.lines, finalLine)
+			}
+		}
+		if len(passed) > 0 {
+			addSuspect(parentSha, parent, parentPath, passed)
+		}
+	}
+
+	return blamed, nil
+}
+
+// Returns the path of the file in the parent, following renames. Empty if the file is not in the parent
+func (r *Repository) findPathInParent(parentFiles map[string]objects.TreeEntry, files map[string]objects.TreeEntry, path string) (string, error) {
+	if _, found := parentFiles[path]; found {
+		return path, nil
+	}
+
+	readBlob := func(sha string) ([]byte, error) {
+		blob, err := r.ReadBlobObject(sha)
+		return blob.Data, err
+	}
+	changes, err := diff.DetectRenames(diff.DiffFileMaps(toDiffFileEntries(parentFiles), toDiffFileEntries(files)), diff.DefaultRenameOptions(), readBlob)
+	if err != nil {
+		return "", err
+	}
+	for _, change := range changes {
+		if change.Type == diff.RENAMED && change.NewPath == path {
+			return change.OldPath, nil
+		}
+	}
+
+	return "", nil
+}
+
+func (r *Repository) readBlameTreeFiles(treeSha string, treeFiles map[string]map[string]objects.TreeEntry) (map[string]objects.TreeEntry, error) {
+	if files, cached := treeFiles[treeSha]; cached {
+		return files, nil
+	}
+
+	files, err := r.ReadTreeFiles(treeSha)
+	if err != nil {
+		return nil, err
+	}
+	treeFiles[treeSha] = files
+
+	return files, nil
+}
+
+func toDiffFileEntries(files map[string]objects.TreeEntry) map[string]diff.FileEntry {
+	fileEntries := make(map[string]diff.FileEntry)
+	for path, entry := range files {
+		fileEntries[path] = diff.FileEntry{Mode: entry.Mode, Sha: entry.Sha}
+	}
+	return fileEntries
+}
@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"fmt"
+	"git/src/config"
+	"git/src/protocol"
+	"os"
+	"sort"
+	"strings"
+)
+
+// CloneHttpRepository Clones the repository served at url with the smart HTTP protocol. Refs are stored as in CloneLocalRepository.
+// With a filter the clone is partial: the server omits the filtered blobs and origin is recorded as the promisor remote, so
+// they are fetched when they are read. With deepen the clone is shallow: the history is cut and .git/shallow lists the
+// commits whose parents were not fetched. Files are not checked out
+func CloneHttpRepository(url string, workTreePath string, gitDir string, filter protocol.ObjectFilter, deepen protocol.DeepenOptions) (*Repository, error) {
+	remoteRefs, err := DiscoverRemoteRefs(url)
+	if err != nil {
+		return nil, err
+	}
+
+	cloned := initializeClonedRepository(workTreePath, gitDir)
+	if err := cloned.addRemote(DEFAULT_REMOTE_NAME, url); err != nil {
+		return nil, err
+	}
+
+	if !filter.IsEmpty() && !remoteRefs.Capabilities["filter"] {
+		fmt.Fprintln(os.Stderr, "warning: filtering not recognized by server, ignoring")
+		filter, _ = protocol.ParseObjectFilter("")
+	}
+	if !deepen.IsEmpty() && !remoteRefs.Capabilities["shallow"] {
+		fmt.Fprintln(os.Stderr, "warning: shallow clones are not supported by server, ignoring")
+		deepen = protocol.DeepenOptions{}
+	}
+	if !filter.IsEmpty() {
+		if err := cloned.setPromisorRemote(DEFAULT_REMOTE_NAME, filter); err != nil {
+			return nil, err
+		}
+	}
+
+	wants := getClonedRefsShas(remoteRefs.Refs)
+	if len(wants) == 0 { //Empty repository
+		return cloned, nil
+	}
+
+	packObjects, shallowUpdate, err := cloned.FetchRemotePack(url, wants, nil, filter, deepen)
+	if err != nil {
+		return nil, err
+	}
+	if err := cloned.WritePackObjects(packObjects); err != nil {
+		return nil, err
+	}
+	if err := cloned.UpdateShallowCommits(shallowUpdate); err != nil {
+		return nil, err
+	}
+	cloned.writeRemoteRefs(DEFAULT_REMOTE_NAME, remoteRefs.Refs)
+
+	if remoteRefs.HeadSha == "" {
+		return cloned, nil
+	}
+	return cloned, cloned.writeClonedHead(DEFAULT_REMOTE_NAME, remoteRefs.HeadBranch, remoteRefs.HeadSha)
+}
+
+// Shas of the branches and tags, sorted and without duplicates
+func getClonedRefsShas(refs map[string]string) []string {
+	unique := make(map[string]bool)
+	for refName, sha := range refs {
+		if strings.HasPrefix(refName, "refs/heads/") || strings.HasPrefix(refName, "refs/tags/") {
+			unique[sha] = true
+		}
+	}
+
+	shas := make([]string, 0, len(unique))
+	for sha, _ := range unique {
+		shas = append(shas, sha)
+	}
+	sort.Strings(shas)
+
+	return shas
+}
+
+// Partial clones need repository format version 1, since extensions.partialClone changes how missing objects are handled
+func (r *Repository) setPromisorRemote(remoteName string, filter protocol.ObjectFilter) error {
+	values := [][2]string{
+		{"core.repositoryformatversion", "1"},
+		{"extensions.partialClone", remoteName},
+		{"remote." + remoteName + ".promisor", "true"},
+		{"remote." + remoteName + ".partialclonefilter", filter.Spec},
+	}
+
+	for _, value := range values {
+		if err := r.Config.Set(config.LOCAL_SCOPE, value[0], value[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package repository
+
+import (
+	"git/src/config"
+	"git/src/objects"
+	"git/src/utils"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const DEFAULT_REMOTE_NAME = "origin"
+
+// CloneLocalRepository Clones the repository located in sourcePath (in the same filesystem) to workTreePath, storing its git dir in gitDir.
+// Source branches are stored in refs/remotes/origin/ and the branch checked out in the source is created locally. Files are not checked out
+func CloneLocalRepository(sourcePath string, workTreePath string, gitDir string) (*Repository, error) {
+	source, err := LoadRepository(sourcePath)
+	if err != nil {
+		return nil, err
+	}
+
+	cloned := initializeClonedRepository(workTreePath, gitDir)
+
+	absoluteSourcePath, _ := filepath.Abs(sourcePath)
+	if err := cloned.addRemote(DEFAULT_REMOTE_NAME, absoluteSourcePath); err != nil {
+		return nil, err
+	}
+
+	if err := cloned.FetchLocal(source, DEFAULT_REMOTE_NAME); err != nil {
+		return nil, err
+	}
+
+	sourceHead, err := source.ResolveRef("HEAD")
+	if err != nil { //Empty repository
+		return cloned, nil
+	}
+
+	branch, detached, _ := source.GetActiveBranch()
+	if detached {
+		branch = ""
+	}
+	return cloned, cloned.writeClonedHead(DEFAULT_REMOTE_NAME, branch, sourceHead.Value)
+}
+
+// The git dir is only separated from the work tree if it is not <work tree>/.git. Ex: submodules
+func initializeClonedRepository(workTreePath string, gitDir string) *Repository {
+	if gitDir != utils.Path(workTreePath, ".git") {
+		return InitializeRepositoryWithSeparateGitDir(workTreePath, gitDir)
+	}
+
+	utils.Check(os.MkdirAll(workTreePath, os.ModePerm), "Cannot create "+workTreePath)
+	return InitializeRepository(workTreePath, false)
+}
+
+func (r *Repository) addRemote(remoteName string, url string) error {
+	if err := r.Config.Set(config.LOCAL_SCOPE, "remote."+remoteName+".url", url); err != nil {
+		return err
+	}
+	return r.Config.Set(config.LOCAL_SCOPE, "remote."+remoteName+".fetch", "+refs/heads/*:refs/remotes/"+remoteName+"/*")
+}
+
+// writeClonedHead Creates the branch checked out in the remote tracking it. If branch is empty, HEAD is detached at headSha
+func (r *Repository) writeClonedHead(remoteName string, branch string, headSha string) error {
+	if branch == "" {
+		return r.WriteToHead(headSha + "\n")
+	}
+
+	r.WriteRef(objects.Reference{NamePath: "heads/" + branch, Value: headSha})
+	if err := r.SetUpstream(branch, remoteName, "refs/heads/"+branch); err != nil {
+		return err
+	}
+	return r.WriteToHead("ref: refs/heads/" + branch + "\n")
+}
+
+// FetchLocal Copies the objects of source that are not present in this repository.
+// Source branches are written in refs/remotes/<remote name>/ and source tags in refs/tags/.
+// If source is shallow, its shallow commits that were not present here are also shallow, since their parents are not copied
+func (r *Repository) FetchLocal(source *Repository, remoteName string) error {
+	sourceShallowCommits, err := source.ReadShallowCommits()
+	if err != nil {
+		return err
+	}
+	newShallowCommits := make([]string, 0)
+	for _, sha := range sortedShaSet(sourceShallowCommits) {
+		if !r.HasObject(sha) {
+			newShallowCommits = append(newShallowCommits, sha)
+		}
+	}
+
+	if err := r.copyObjectsFrom(source); err != nil {
+		return err
+	}
+	if err := r.UpdateShallowCommits(ShallowUpdate{Shallows: newShallowCommits}); err != nil {
+		return err
+	}
+
+	sourceRefs, err := source.GetAllRefs()
+	if err != nil {
+		return err
+	}
+
+	refs := make(map[string]string)
+	for refName, ref := range sourceRefs {
+		refs[refName] = ref.Value
+	}
+	r.writeRemoteRefs(remoteName, refs)
+
+	return nil
+}
+
+// Branches are written in refs/remotes/<remote name>/ and tags in refs/tags/. Other refs are ignored
+func (r *Repository) writeRemoteRefs(remoteName string, refs map[string]string) {
+	for refName, sha := range refs {
+		if strings.HasPrefix(refName, "refs/heads/") {
+			r.WriteRef(objects.Reference{NamePath: "remotes/" + remoteName + "/" + strings.TrimPrefix(refName, "refs/heads/"), Value: sha})
+		} else if strings.HasPrefix(refName, "refs/tags/") {
+			r.WriteRef(objects.Reference{NamePath: strings.TrimPrefix(refName, "refs/"), Value: sha})
+		}
+	}
+}
+
+// Loose objects are already compressed and packs are indexed, so they are copied as they are. Other files in the object
+// directories, like temporary files left by interrupted writes, are skipped
+func (r *Repository) copyObjectsFrom(source *Repository) error {
+	sourceObjectsPath := utils.Path(source.CommonDir, "objects")
+	prefixDirs, err := os.ReadDir(sourceObjectsPath)
+	if err != nil {
+		return err
+	}
+	if err := r.copyPacksFrom(source); err != nil {
+		return err
+	}
+
+	for _, prefixDir := range prefixDirs {
+		if !prefixDir.IsDir() || len(prefixDir.Name()) != 2 || !isHex(prefixDir.Name()) {
+			continue
+		}
+
+		objectFiles, err := os.ReadDir(utils.Path(sourceObjectsPath, prefixDir.Name()))
+		if err != nil {
+			return err
+		}
+
+		for _, objectFile := range objectFiles {
+			if objectFile.IsDir() || len(objectFile.Name()) != 38 || !isHex(objectFile.Name()) || r.HasObject(prefixDir.Name()+objectFile.Name()) {
+				continue
+			}
+
+			content, err := os.ReadFile(utils.Paths(sourceObjectsPath, prefixDir.Name(), objectFile.Name()))
+			if err != nil {
+				return err
+			}
+
+			destinationPath := utils.Paths(r.CommonDir, "objects", prefixDir.Name(), objectFile.Name())
+			if err := os.MkdirAll(filepath.Dir(destinationPath), os.ModePerm); err != nil {
+				return err
+			}
+			if err := os.WriteFile(destinationPath, content, 0444); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// The index of each pack is copied after it, so the pack is not used before it is complete
+func (r *Repository) copyPacksFrom(source *Repository) error {
+	indexPaths, err := filepath.Glob(utils.Path(source.PackDir(), "pack-*.idx"))
+	if err != nil || len(indexPaths) == 0 {
+		return err
+	}
+
+	for _, indexPath := range indexPaths {
+		for _, path := range []string{packPathOfIndex(indexPath), indexPath} {
+			destinationPath := utils.Path(r.PackDir(), filepath.Base(path))
+			if utils.CheckFileOrDirExists(destinationPath) {
+				continue
+			}
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			if err := writeFileAtomically(destinationPath, content); err != nil {
+				return err
+			}
+		}
+	}
+
+	r.resetPacks()
+	return nil
+}
@@ -0,0 +1,195 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"git/src/commitgraph"
+	"git/src/objects"
+	"git/src/utils"
+	"os"
+	"strings"
+)
+
+// CommitNode Parents and committer date of a commit, enough to walk the history. Generation is 0 if it is unknown
+type CommitNode struct {
+	Parents    []string
+	CommitTime int64
+	Generation uint32
+}
+
+func (r *Repository) CommitGraphPath() string {
+	return utils.Paths(r.CommonDir, "objects", "info", "commit-graph")
+}
+
+// ReadCommitNode Uses the commit graph if the commit is in it, so the commit object is not read. Commits created after
+// the graph was written are read from their objects
+func (r *Repository) ReadCommitNode(sha string) (CommitNode, error) {
+	node, _, err := r.readCommitNodeOrObject(sha)
+	return node, err
+}
+
+// Also returns the commit object if it was read because the commit is not in the graph, so it is not read twice
+func (r *Repository) readCommitNodeOrObject(sha string) (CommitNode, *objects.CommitObject, error) {
+	if graph := r.readCommitGraph(); graph != nil {
+		if commit, found := graph.Lookup(sha); found {
+			return CommitNode{Parents: commit.Parents, CommitTime: commit.CommitTime, Generation: commit.Generation}, nil, nil
+		}
+	}
+
+	commit, err := r.ReadCommitObject(sha)
+	if err != nil {
+		return CommitNode{}, nil, err
+	}
+	return CommitNode{Parents: commit.Parents, CommitTime: commit.CommitTime()}, &commit, nil
+}
+
+// The graph is read once. It is not used if core.commitGraph is false or the repository is shallow, since the parents of
+// the shallow commits in the graph could be different. Invalid graphs are ignored with a warning
+func (r *Repository) readCommitGraph() *commitgraph.CommitGraph {
+	if r.commitGraphRead {
+		return r.commitGraph
+	}
+	r.commitGraphRead = true
+
+	if !r.Config.MustBool("core.commitGraph", true) || r.IsShallow() {
+		return nil
+	}
+	content, err := os.ReadFile(r.CommitGraphPath())
+	if err != nil {
+		return nil
+	}
+	graph, err := commitgraph.Deserialize(content)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "warning: ignoring "+r.CommitGraphPath()+": "+err.Error())
+		return nil
+	}
+
+	r.commitGraph = graph
+	return graph
+}
+
+// WriteCommitGraph Replaces the commit graph with all the commits reachable from the refs and HEAD. Returns the number
+// of commits written
+func (r *Repository) WriteCommitGraph() (int, error) {
+	if r.IsShallow() {
+		return 0, errors.New("cannot write a commit-graph in a shallow repository")
+	}
+
+	tips, err := r.getCommitGraphTips()
+	if err != nil {
+		return 0, err
+	}
+	commits, err := r.getCommitGraphCommits(tips)
+	if err != nil {
+		return 0, err
+	}
+	content, err := commitgraph.Serialize(commits)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := writeFileAtomically(r.CommitGraphPath(), content); err != nil {
+		return 0, err
+	}
+
+	r.commitGraph, r.commitGraphRead = nil, false
+	return len(commits), nil
+}
+
+// Commits pointed by HEAD and the refs. Annotated tags are peeled and refs to other objects are ignored
+func (r *Repository) getCommitGraphTips() ([]string, error) {
+	refs, err := r.GetAllRefs()
+	if err != nil {
+		return nil, err
+	}
+	shas := make([]string, 0, len(refs)+1)
+	for _, ref := range refs {
+		shas = append(shas, ref.Value)
+	}
+	if head, err := r.ResolveRef("HEAD"); err == nil {
+		shas = append(shas, head.Value)
+	}
+
+	tips := make([]string, 0, len(shas))
+	for _, sha := range shas {
+		if commitSha, _, err := r.ResolveObjectName(sha, objects.COMMIT); err == nil {
+			tips = append(tips, commitSha)
+		}
+	}
+	return tips, nil
+}
+
+// Reads every commit reachable from the tips and calculates its generation once the ones of its parents are known
+func (r *Repository) getCommitGraphCommits(tips []string) ([]commitgraph.Commit, error) {
+	commits := make(map[string]*commitgraph.Commit)
+	pending := append(make([]string, 0, len(tips)), tips...)
+
+	for len(pending) > 0 {
+		sha := pending[len(pending)-1]
+		commit, read := commits[sha]
+		if !read {
+			commitObject, err := r.ReadCommitObject(sha)
+			if err != nil {
+				return nil, err
+			}
+			commit = &commitgraph.Commit{Sha: sha, Tree: commitObject.Tree, Parents: commitObject.Parents,
+				CommitTime: commitObject.CommitTime()}
+			commits[sha] = commit
+		}
+		if commit.Generation != 0 {
+			pending = pending[:len(pending)-1]
+			continue
+		}
+
+		generation, parentsPending := uint32(1), false
+		for _, parent := range commit.Parents {
+			if parentCommit, found := commits[parent]; !found || parentCommit.Generation == 0 {
+				pending = append(pending, parent)
+				parentsPending = true
+			} else if parentCommit.Generation >= generation {
+				generation = parentCommit.Generation + 1
+			}
+		}
+		if !parentsPending {
+			commit.Generation = generation
+			pending = pending[:len(pending)-1]
+		}
+	}
+
+	result := make([]commitgraph.Commit, 0, len(commits))
+	for _, commit := range commits {
+		result = append(result, *commit)
+	}
+	return result, nil
+}
+
+// VerifyCommitGraph Checks that every commit of the graph matches its object and that generations are consistent
+func (r *Repository) VerifyCommitGraph() error {
+	content, err := os.ReadFile(r.CommitGraphPath())
+	if err != nil {
+		return err
+	}
+	graph, err := commitgraph.Deserialize(content)
+	if err != nil {
+		return err
+	}
+
+	for _, sha := range graph.Shas() {
+		commit, _ := graph.Lookup(sha)
+		commitObject, err := r.ReadCommitObject(sha)
+		if err != nil {
+			return errors.New("commit-graph commit " + sha + " cannot be read: " + err.Error())
+		}
+		if commit.Tree != commitObject.Tree || commit.CommitTime != commitObject.CommitTime() ||
+			strings.Join(commit.Parents, " ") != strings.Join(commitObject.Parents, " ") {
+			return errors.New("commit-graph data of commit " + sha + " does not match its object")
+		}
+		for _, parent := range commit.Parents {
+			parentCommit, _ := graph.Lookup(parent)
+			if parentCommit.Generation >= commit.Generation && commit.Generation != commitgraph.GENERATION_NUMBER_MAX {
+				return errors.New("commit-graph generation of commit " + sha + " is not bigger than the one of its parent " + parent)
+			}
+		}
+	}
+	return nil
+}
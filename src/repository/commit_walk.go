@@ -0,0 +1,52 @@
+package repository
+
+// GetReachableCommits Returns the shas of all the commits reachable from sha following every parent, including sha.
+// Commit objects are not read if they are in the commit graph
+func (r *Repository) GetReachableCommits(sha string) (map[string]bool, error) {
+	reachable := make(map[string]bool)
+	pending := []string{sha}
+
+	for len(pending) > 0 {
+		actualSha := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if reachable[actualSha] {
+			continue
+		}
+
+		node, err := r.ReadCommitNode(actualSha)
+		if err != nil {
+			return nil, err
+		}
+
+		reachable[actualSha] = true
+		pending = append(pending, node.Parents...)
+	}
+
+	return reachable, nil
+}
+
+// CountAheadBehind Returns the number of commits reachable from sha that are not reachable from otherSha (ahead) and vice versa (behind)
+func (r *Repository) CountAheadBehind(sha string, otherSha string) (int, int, error) {
+	reachable, err := r.GetReachableCommits(sha)
+	if err != nil {
+		return 0, 0, err
+	}
+	otherReachable, err := r.GetReachableCommits(otherSha)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	ahead, behind := 0, 0
+	for commitSha, _ := range reachable {
+		if !otherReachable[commitSha] {
+			ahead++
+		}
+	}
+	for commitSha, _ := range otherReachable {
+		if !reachable[commitSha] {
+			behind++
+		}
+	}
+
+	return ahead, behind, nil
+}
@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"errors"
+	"git/src/protocol"
+)
+
+// FetchHttpRemote Fetches the branches and tags of a remote served with the smart HTTP protocol. They are written as in FetchLocal.
+// The partial clone filter of the remote is reused. With deepen the history of a shallow repository is deepened or cut, so
+// every remote tip is requested even if it is already present
+func (r *Repository) FetchHttpRemote(remoteName string, deepen protocol.DeepenOptions) error {
+	url := r.Config.String("remote." + remoteName + ".url")
+	if url == "" {
+		return errors.New("'" + remoteName + "' does not appear to be a git repository")
+	}
+	if !IsHttpUrl(url) {
+		return errors.New("remote " + remoteName + " has no http url")
+	}
+
+	remoteRefs, err := DiscoverRemoteRefs(url)
+	if err != nil {
+		return err
+	}
+	if !deepen.IsEmpty() && !remoteRefs.Capabilities["shallow"] {
+		return errors.New("Server does not support shallow clients")
+	}
+	if deepen.Relative && !remoteRefs.Capabilities["deepen-relative"] {
+		return errors.New("Server does not support --deepen")
+	}
+	if deepen.Since != 0 && !remoteRefs.Capabilities["deepen-since"] {
+		return errors.New("Server does not support --shallow-since")
+	}
+
+	filter, _ := protocol.ParseObjectFilter("")
+	if r.PromisorRemote() == remoteName && remoteRefs.Capabilities["filter"] {
+		if filter, err = protocol.ParseObjectFilter(r.Config.String("remote." + remoteName + ".partialclonefilter")); err != nil {
+			return err
+		}
+	}
+
+	wants := make([]string, 0)
+	for _, sha := range getClonedRefsShas(remoteRefs.Refs) {
+		if !deepen.IsEmpty() || !r.HasObject(sha) {
+			wants = append(wants, sha)
+		}
+	}
+	if len(wants) > 0 {
+		packObjects, shallowUpdate, err := r.FetchRemotePack(url, wants, r.getLocalRefsShas(), filter, deepen)
+		if err != nil {
+			return err
+		}
+		if err := r.WritePackObjects(packObjects); err != nil {
+			return err
+		}
+		if err := r.UpdateShallowCommits(shallowUpdate); err != nil {
+			return err
+		}
+	}
+
+	r.writeRemoteRefs(remoteName, remoteRefs.Refs)
+	return nil
+}
+
+// Shas of every local ref (branches, remote branches and tags), sorted and without duplicates. They are sent as haves
+func (r *Repository) getLocalRefsShas() []string {
+	refs, err := r.GetAllRefs()
+	if err != nil {
+		return nil
+	}
+
+	unique := make(map[string]bool)
+	for _, ref := range refs {
+		unique[ref.Value] = true
+	}
+	return sortedShaSet(unique)
+}
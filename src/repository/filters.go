@@ -0,0 +1,260 @@
+package repository
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"git/src/attributes"
+	"git/src/protocol"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Directions of a filter driver
+const (
+	CLEAN_FILTER  = "clean"  //Work tree -> blob
+	SMUDGE_FILTER = "smudge" //Blob -> work tree
+)
+
+const LFS_FILTER_DRIVER = "lfs"
+
+// filterProcess Long running filter (filter.<driver>.process) started the first time it is used and kept until the command exits.
+// Protocol (pkt-lines), after a handshake where both sides send their name, version=2 and capabilities:
+//
+//	command=<clean | smudge>, pathname=<path>, flush, <content>, flush
+//	<- status=success, flush, <filtered content>, flush, [status=<success | error>], flush
+type filterProcess struct {
+	command      *exec.Cmd
+	stdin        io.WriteCloser
+	stdout       *bufio.Reader
+	capabilities map[string]bool
+}
+
+// filter.<driver>.clean, filter.<driver>.smudge and filter.<driver>.process commands of the filter attribute. The driver lfs is
+// built-in if it is not configured. Failed filters are skipped with an error message unless filter.<driver>.required is true
+func (r *Repository) applyFilter(fileAttributes attributes.FileAttributes, path string, content []byte, direction string) ([]byte, error) {
+	driver := fileAttributes.Get("filter")
+	if driver == attributes.SET || driver == attributes.UNSET || driver == attributes.UNSPECIFIED {
+		return content, nil
+	}
+
+	processCommand := r.Config.String("filter." + driver + ".process")
+	command := r.Config.String("filter." + driver + "." + direction)
+	required := r.Config.MustBool("filter."+driver+".required", false)
+
+	var filtered []byte
+	var err error
+	switch {
+	case processCommand != "":
+		filtered, err = r.runFilterProcess(processCommand, path, content, direction)
+	case command != "":
+		filtered, err = r.runFilterCommand(command, path, content)
+	case driver == LFS_FILTER_DRIVER:
+		filtered, err = r.applyLfsFilter(content, direction)
+	case required:
+		err = errors.New("filter." + driver + "." + direction + " is not set")
+	default:
+		return content, nil
+	}
+
+	if err != nil && required {
+		return nil, errors.New(path + ": " + direction + " filter '" + driver + "' failed: " + err.Error())
+	} else if err != nil {
+		fmt.Fprintln(os.Stderr, "error: "+direction+" filter '"+driver+"' failed for "+path+": "+err.Error())
+		return content, nil
+	}
+
+	return filtered, nil
+}
+
+// The command is run by the shell from the root of the work tree, with %f replaced by the quoted path of the file
+func (r *Repository) runFilterCommand(command string, path string, content []byte) ([]byte, error) {
+	command = strings.ReplaceAll(command, "%f", quoteShellArg(path))
+
+	var stdout bytes.Buffer
+	shellCommand := exec.Command("sh", "-c", command)
+	shellCommand.Dir = r.hookWorkingDir()
+	shellCommand.Env = append(os.Environ(), "GIT_DIR="+r.GitDir)
+	shellCommand.Stdin = bytes.NewReader(content)
+	shellCommand.Stdout, shellCommand.Stderr = &stdout, os.Stderr
+
+	if err := shellCommand.Run(); err != nil {
+		return nil, errors.New("external filter '" + command + "' failed: " + err.Error())
+	}
+	return stdout.Bytes(), nil
+}
+
+// filterStatusError The process could not filter the file (status=error) or wont filter any more files (status=abort)
+type filterStatusError struct {
+	status string
+}
+
+func (e filterStatusError) Error() string {
+	return "filter process returned status " + e.status
+}
+
+func (r *Repository) runFilterProcess(command string, path string, content []byte, direction string) ([]byte, error) {
+	process, err := r.getFilterProcess(command)
+	if err != nil {
+		return nil, err
+	}
+	if !process.capabilities[direction] {
+		return content, nil
+	}
+
+	filtered, err := process.filter(path, content, direction)
+	var statusError filterStatusError
+	if errors.As(err, &statusError) && statusError.status == "abort" {
+		delete(process.capabilities, direction) //The process doesnt want more files of this direction
+	} else if err != nil && !errors.As(err, &statusError) {
+		r.stopFilterProcess(command)
+	}
+
+	return filtered, err
+}
+
+func (r *Repository) getFilterProcess(command string) (*filterProcess, error) {
+	if process, started := r.filterProcesses[command]; started {
+		return process, nil
+	}
+
+	process, err := startFilterProcess(command, r.hookWorkingDir(), r.GitDir)
+	if err != nil {
+		return nil, errors.New("cannot start filter process '" + command + "': " + err.Error())
+	}
+	if r.filterProcesses == nil {
+		r.filterProcesses = make(map[string]*filterProcess)
+	}
+	r.filterProcesses[command] = process
+
+	return process, nil
+}
+
+func (r *Repository) stopFilterProcess(command string) {
+	if process, started := r.filterProcesses[command]; started {
+		process.stdin.Close()
+		_ = process.command.Wait()
+		delete(r.filterProcesses, command)
+	}
+}
+
+func startFilterProcess(command string, workingDir string, gitDir string) (*filterProcess, error) {
+	shellCommand := exec.Command("sh", "-c", command)
+	shellCommand.Dir = workingDir
+	shellCommand.Env = append(os.Environ(), "GIT_DIR="+gitDir)
+	shellCommand.Stderr = os.Stderr
+	stdin, err := shellCommand.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := shellCommand.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := shellCommand.Start(); err != nil {
+		return nil, err
+	}
+
+	process := &filterProcess{command: shellCommand, stdin: stdin, stdout: bufio.NewReader(stdout), capabilities: make(map[string]bool)}
+	if err := process.handshake(); err != nil {
+		stdin.Close()
+		_ = shellCommand.Wait()
+		return nil, err
+	}
+
+	return process, nil
+}
+
+func (p *filterProcess) handshake() error {
+	if err := p.writeLines("git-filter-client", "version=2"); err != nil {
+		return err
+	}
+	lines, err := protocol.ReadPktLinesUntilFlush(p.stdout)
+	if err != nil {
+		return err
+	}
+	if len(lines) < 2 || lines[0] != "git-filter-server" || lines[1] != "version=2" {
+		return errors.New("unexpected filter process handshake: " + strings.Join(lines, ", "))
+	}
+
+	if err := p.writeLines("capability="+CLEAN_FILTER, "capability="+SMUDGE_FILTER); err != nil {
+		return err
+	}
+	capabilities, err := protocol.ReadPktLinesUntilFlush(p.stdout)
+	if err != nil {
+		return err
+	}
+	for _, capability := range capabilities {
+		if name, found := strings.CutPrefix(capability, "capability="); found {
+			p.capabilities[name] = true
+		}
+	}
+
+	return nil
+}
+
+func (p *filterProcess) filter(path string, content []byte, direction string) ([]byte, error) {
+	if err := p.writeLines("command="+direction, "pathname="+path); err != nil {
+		return nil, err
+	}
+	for offset := 0; offset < len(content); offset += protocol.MAX_PKT_LINE_DATA_LENGTH {
+		end := offset + protocol.MAX_PKT_LINE_DATA_LENGTH
+		if end > len(content) {
+			end = len(content)
+		}
+		if _, err := p.stdin.Write(protocol.EncodePktLine(content[offset:end])); err != nil {
+			return nil, err
+		}
+	}
+	if err := protocol.WriteFlush(p.stdin); err != nil {
+		return nil, err
+	}
+
+	if err := p.readStatus(); err != nil {
+		return nil, err
+	}
+	var filtered bytes.Buffer
+	for {
+		line, err := protocol.ReadPktLine(p.stdout)
+		if err != nil {
+			return nil, err
+		}
+		if line.Flush {
+			break
+		}
+		filtered.Write(line.Data)
+	}
+
+	return filtered.Bytes(), p.readStatus() //An empty list keeps the status
+}
+
+// Returns filterStatusError unless the status is success or not sent
+func (p *filterProcess) readStatus() error {
+	lines, err := protocol.ReadPktLinesUntilFlush(p.stdout)
+	if err != nil {
+		return err
+	}
+
+	for _, line := range lines {
+		if status, found := strings.CutPrefix(line, "status="); found && status != "success" {
+			return filterStatusError{status: status}
+		}
+	}
+	return nil
+}
+
+func (p *filterProcess) writeLines(lines ...string) error {
+	for _, line := range lines {
+		if err := protocol.WritePktLine(p.stdin, line+"\n"); err != nil {
+			return err
+		}
+	}
+	return protocol.WriteFlush(p.stdin)
+}
+
+func quoteShellArg(arg string) string {
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
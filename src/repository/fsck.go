@@ -0,0 +1,393 @@
+package repository
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/hex"
+	"git/src/objects"
+	"git/src/packfile"
+	"git/src/utils"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Fsck exit codes. They are combined when several kinds of problems are found
+const (
+	FSCK_CORRUPT     = 1 //Objects that cannot be decompressed, dont match their sha or have invalid syntax
+	FSCK_MISSING     = 2 //Objects that are reachable but dont exist
+	FSCK_DANGLING    = 4 //Unreachable objects not referenced by other unreachable objects
+	FSCK_UNREACHABLE = 8 //Objects not reachable from refs, HEADs, indexes or reflogs. Only with FsckOptions.Unreachable
+)
+
+type FsckOptions struct {
+	Dangling    bool
+	Unreachable bool
+}
+
+// FsckProblem Line of the fsck report. Kind is one of FSCK_*. Warnings have kind 0
+type FsckProblem struct {
+	Kind    int
+	Message string
+}
+
+type FsckReport struct {
+	Problems []FsckProblem
+}
+
+// ExitCode Combination of the kinds of problems found. 0 if the repository is fine
+func (r FsckReport) ExitCode() int {
+	exitCode := 0
+	for _, problem := range r.Problems {
+		exitCode |= problem.Kind
+	}
+	return exitCode
+}
+
+type fsckObject struct {
+	objectType objects.ObjectType
+	links      []objects.ObjectLink
+}
+
+type fsckState struct {
+	repository *Repository
+	objects    map[string]fsckObject //Only valid objects
+	report     *FsckReport
+}
+
+// Fsck Decompresses and hashes every loose and packed object, validates its syntax and walks the objects reachable
+// from refs, HEAD of every worktree, indexes and reflogs reporting missing, dangling and unreachable objects
+func (r *Repository) Fsck(options FsckOptions) (FsckReport, error) {
+	state := &fsckState{repository: r, objects: make(map[string]fsckObject), report: &FsckReport{Problems: make([]FsckProblem, 0)}}
+
+	if err := state.checkLooseObjects(); err != nil {
+		return FsckReport{}, err
+	}
+	if err := state.checkPacks(); err != nil {
+		return FsckReport{}, err
+	}
+
+	roots, err := state.getRoots()
+	if err != nil {
+		return FsckReport{}, err
+	}
+	reachable := state.walkReachable(roots)
+	state.reportUnreachable(reachable, options)
+
+	return *state.report, nil
+}
+
+func (s *fsckState) addProblem(kind int, message string) {
+	s.report.Problems = append(s.report.Problems, FsckProblem{Kind: kind, Message: message})
+}
+
+func (s *fsckState) checkLooseObjects() error {
+	shas, err := s.repository.looseObjectShas()
+	if err != nil {
+		return err
+	}
+
+	for _, sha := range shas {
+		s.checkLooseObject(sha, utils.Paths(s.repository.CommonDir, "objects", sha[:2], sha[2:]))
+	}
+	return nil
+}
+
+func (s *fsckState) checkLooseObject(sha string, path string) {
+	compressed, err := os.ReadFile(path)
+	if err != nil {
+		s.addProblem(FSCK_CORRUPT, "error: "+sha+": cannot read object file "+path+": "+err.Error())
+		return
+	}
+	if len(compressed) == 0 {
+		s.addProblem(FSCK_CORRUPT, "error: object file "+path+" is empty")
+		return
+	}
+
+	zlibReader, err := zlib.NewReader(bytes.NewReader(compressed))
+	var decompressed []byte
+	if err == nil {
+		decompressed, err = io.ReadAll(zlibReader)
+	}
+	if err != nil {
+		s.addProblem(FSCK_CORRUPT, "error: "+sha+": object corrupt or missing: "+path+" ("+err.Error()+")")
+		return
+	}
+
+	if actualSha := sha1.Sum(decompressed); hex.EncodeToString(actualSha[:]) != sha {
+		s.addProblem(FSCK_CORRUPT, "error: hash mismatch for "+path+" (expected "+sha+")")
+		return
+	}
+
+	objectType, body, err := objects.SplitObjectHeader(decompressed)
+	if err != nil {
+		s.addProblem(FSCK_CORRUPT, "error: "+sha+": object corrupt: "+err.Error())
+		return
+	}
+
+	s.checkObject(sha, objectType, body)
+}
+
+// Packs are fully read, so every object is decompressed, its deltas applied and hashed. The checksum of the pack is verified
+func (s *fsckState) checkPacks() error {
+	packPaths, err := filepath.Glob(utils.Paths(s.repository.CommonDir, "objects", "pack", "*.pack"))
+	if err != nil {
+		return err
+	}
+
+	for _, packPath := range packPaths {
+		packFile, err := os.Open(packPath)
+		if err != nil {
+			return err
+		}
+		packObjects, err := packfile.ReadPack(packFile, nil)
+		packFile.Close()
+		if err != nil {
+			s.addProblem(FSCK_CORRUPT, "error: "+packPath+": "+err.Error())
+			continue
+		}
+
+		for _, packObject := range packObjects {
+			if _, checked := s.objects[packObject.Sha]; !checked {
+				s.checkObject(packObject.Sha, packObject.Type, packObject.Data)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *fsckState) checkObject(sha string, objectType objects.ObjectType, body []byte) {
+	links, problems := objects.CheckObject(objectType, body)
+
+	corrupt := false
+	for _, problem := range problems {
+		if problem.Warning {
+			s.addProblem(0, "warning in "+string(objectType)+" "+sha+": "+problem.String())
+		} else {
+			s.addProblem(FSCK_CORRUPT, "error in "+string(objectType)+" "+sha+": "+problem.String())
+			corrupt = true
+		}
+	}
+
+	if !corrupt {
+		s.objects[sha] = fsckObject{objectType: objectType, links: links}
+	}
+}
+
+// Roots of the reachability walk: refs, HEAD, index entries and reflog entries of every worktree
+func (s *fsckState) getRoots() ([]objects.ObjectLink, error) {
+	roots := make([]objects.ObjectLink, 0)
+
+	refs, err := s.repository.GetAllRefs()
+	if err != nil {
+		return nil, err
+	}
+	refNames := make([]string, 0, len(refs))
+	for refName, _ := range refs {
+		refNames = append(refNames, refName)
+	}
+	sort.Strings(refNames)
+	for _, refName := range refNames {
+		roots = append(roots, objects.ObjectLink{Sha: refs[refName].Value, Type: objects.ANY})
+	}
+
+	roots = append(roots, s.getReflogRoots(utils.Path(s.repository.CommonDir, "logs"))...)
+
+	worktrees, err := s.repository.ListWorktrees()
+	if err != nil {
+		return nil, err
+	}
+	for _, worktree := range worktrees {
+		worktreeRepository, err := loadRepositoryFromGitDir(worktree.GitDir, worktree.Path)
+		if err != nil || worktree.Prunable {
+			continue
+		}
+		if head, err := worktreeRepository.ResolveRef("HEAD"); err == nil {
+			roots = append(roots, objects.ObjectLink{Sha: head.Value, Type: objects.COMMIT})
+		}
+		if worktreeRepository.GitDir != s.repository.CommonDir {
+			roots = append(roots, s.getReflogRoots(utils.Paths(worktreeRepository.GitDir, "logs", "HEAD"))...)
+		}
+		if !worktree.Bare {
+			roots = append(roots, s.getIndexRoots(worktreeRepository)...)
+		}
+	}
+
+	return roots, nil
+}
+
+// Reflog line format: <old sha> <new sha> <identity>\t<message>
+func (s *fsckState) getReflogRoots(path string) []objects.ObjectLink {
+	roots := make([]objects.ObjectLink, 0)
+
+	filepath.Walk(path, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return nil
+		}
+		file, err := os.Open(filePath)
+		if err != nil {
+			return nil
+		}
+		defer file.Close()
+
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) > 2 {
+				fields = fields[:2]
+			}
+			for _, sha := range fields {
+				if sha != objects.NO_PARENT_COMMIT_SHA && len(sha) == 40 {
+					roots = append(roots, objects.ObjectLink{Sha: sha, Type: objects.COMMIT})
+				}
+			}
+		}
+		return nil
+	})
+
+	return roots
+}
+
+// Submodule commits of the index are not stored in this repository
+func (s *fsckState) getIndexRoots(worktreeRepository *Repository) []objects.ObjectLink {
+	roots := make([]objects.ObjectLink, 0)
+	if !utils.CheckFileOrDirExists(utils.Path(worktreeRepository.GitDir, "index")) {
+		return roots
+	}
+
+	repositoryIndex, err := worktreeRepository.ReadIndex()
+	if err != nil {
+		s.addProblem(FSCK_CORRUPT, "error: cannot read index of "+worktreeRepository.GitDir+": "+err.Error())
+		return roots
+	}
+
+	paths := make([]string, 0, len(repositoryIndex.Entries))
+	for path, _ := range repositoryIndex.Entries {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		if entry := repositoryIndex.Entries[path]; !entry.IsGitlink() {
+			roots = append(roots, objects.ObjectLink{Sha: entry.Sha, Type: objects.BLOB})
+		}
+	}
+
+	return roots
+}
+
+// Broken links are reported once per object pointing to a missing one. Objects of a different type than expected are corrupt.
+// In partial clones missing blobs are promised by the promisor remote, so they are not reported. Shallow commits are roots,
+// their parents are expected to be missing
+func (s *fsckState) walkReachable(roots []objects.ObjectLink) map[string]bool {
+	reachable := make(map[string]bool)
+	reportedMissing := make(map[string]bool)
+	partialClone := s.repository.IsPartialClone()
+
+	pending := make([]objects.ObjectLink, 0, len(roots))
+	for _, root := range roots {
+		if !s.hasObject(root.Sha) && !reportedMissing[root.Sha] {
+			reportedMissing[root.Sha] = true
+			s.addProblem(FSCK_MISSING, "missing "+typeName(root.Type)+" "+root.Sha)
+			continue
+		}
+		pending = append(pending, root)
+	}
+
+	for len(pending) > 0 {
+		actual := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if reachable[actual.Sha] {
+			continue
+		}
+		reachable[actual.Sha] = true
+
+		object, valid := s.objects[actual.Sha]
+		if !valid { //Corrupt objects are already reported
+			continue
+		}
+		if actual.Type != objects.ANY && actual.Type != object.objectType {
+			s.addProblem(FSCK_CORRUPT, "error: object "+actual.Sha+" is a "+string(object.objectType)+", not a "+string(actual.Type))
+		}
+
+		for _, link := range object.links {
+			if s.hasObject(link.Sha) {
+				pending = append(pending, link)
+				continue
+			}
+			if partialClone && link.Type == objects.BLOB {
+				continue
+			}
+			if link.Type == objects.COMMIT && object.objectType == objects.COMMIT && s.repository.IsShallowCommit(actual.Sha) {
+				continue
+			}
+			s.addProblem(FSCK_MISSING, "broken link from "+typeName(object.objectType)+" "+actual.Sha+"\n              to "+typeName(link.Type)+" "+link.Sha)
+			if !reportedMissing[link.Sha] {
+				reportedMissing[link.Sha] = true
+				s.addProblem(FSCK_MISSING, "missing "+typeName(link.Type)+" "+link.Sha)
+			}
+		}
+	}
+
+	return reachable
+}
+
+// Objects that are corrupt exist, so they are not reported as missing too
+func (s *fsckState) hasObject(sha string) bool {
+	if _, valid := s.objects[sha]; valid {
+		return true
+	}
+	return s.repository.HasObject(sha)
+}
+
+// Dangling objects are the unreachable ones that no other unreachable object points to (tips of unreachable history)
+func (s *fsckState) reportUnreachable(reachable map[string]bool, options FsckOptions) {
+	unreachable := make([]string, 0)
+	referenced := make(map[string]bool)
+	for sha, object := range s.objects {
+		if reachable[sha] {
+			continue
+		}
+		unreachable = append(unreachable, sha)
+		for _, link := range object.links {
+			referenced[link.Sha] = true
+		}
+	}
+	sort.Strings(unreachable)
+
+	for _, sha := range unreachable {
+		objectType := string(s.objects[sha].objectType)
+		if options.Unreachable {
+			s.addProblem(FSCK_UNREACHABLE, "unreachable "+objectType+" "+sha)
+		}
+		if options.Dangling && !referenced[sha] {
+			s.addProblem(FSCK_DANGLING, "dangling "+objectType+" "+sha)
+		}
+	}
+}
+
+// Checked without decoding it, history walks check the sha of every commit they read
+func isHex(text string) bool {
+	if len(text)%2 != 0 {
+		return false
+	}
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') && (c < 'A' || c > 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+// Refs can point to any type of object
+func typeName(objectType objects.ObjectType) string {
+	if objectType == objects.ANY {
+		return "object"
+	}
+	return string(objectType)
+}
@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"git/src/utils"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const HOOKS_DIR = "hooks"
+
+// Hooks and their arguments (githooks(5)). Hooks marked with stdin receive a line per ref: <old sha> <new sha> <ref name>
+const (
+	PRE_COMMIT_HOOK            = "pre-commit"            //No arguments
+	PREPARE_COMMIT_MSG_HOOK    = "prepare-commit-msg"    //<message file> [<source> [<sha>]]
+	COMMIT_MSG_HOOK            = "commit-msg"            //<message file>
+	POST_COMMIT_HOOK           = "post-commit"           //No arguments
+	POST_CHECKOUT_HOOK         = "post-checkout"         //<old HEAD> <new HEAD> <1 if branch checkout, 0 if file checkout>
+	POST_MERGE_HOOK            = "post-merge"            //<1 if squash merge, 0 otherwise>
+	PRE_REBASE_HOOK            = "pre-rebase"            //<upstream> [<branch>]. Not run yet, there is no rebase
+	PRE_PUSH_HOOK              = "pre-push"              //<remote name> <remote url>. Stdin: <local ref> <local sha> <remote ref> <remote sha>. Not run yet, there is no push
+	PRE_RECEIVE_HOOK           = "pre-receive"           //No arguments. Stdin
+	UPDATE_HOOK                = "update"                //<ref name> <old sha> <new sha>
+	POST_RECEIVE_HOOK          = "post-receive"          //No arguments. Stdin
+	REFERENCE_TRANSACTION_HOOK = "reference-transaction" //<prepared | committed | aborted>. Stdin
+)
+
+// HookOptions Arguments, input and extra environment of a hook. Output defaults to stderr
+type HookOptions struct {
+	Args   []string
+	Env    []string
+	Stdin  string
+	Output io.Writer
+}
+
+// HookError The hook exited with a non-zero code
+type HookError struct {
+	Name     string
+	ExitCode int
+}
+
+func (e HookError) Error() string {
+	return "hook " + e.Name + " failed with exit code " + strconv.Itoa(e.ExitCode)
+}
+
+func IsHookError(err error) bool {
+	var hookError HookError
+	return errors.As(err, &hookError)
+}
+
+// HooksDir Directory in core.hooksPath, relative to the root of the work tree, or <common dir>/hooks shared by all the worktrees
+func (r *Repository) HooksDir() string {
+	hooksPath, _ := r.Config.Path("core.hooksPath")
+	switch {
+	case hooksPath == "":
+		return utils.Path(r.CommonDir, HOOKS_DIR)
+	case filepath.IsAbs(hooksPath):
+		return hooksPath
+	default:
+		return filepath.Join(r.hookWorkingDir(), hooksPath)
+	}
+}
+
+func (r *Repository) HookPath(name string) string {
+	return utils.Path(r.HooksDir(), name)
+}
+
+// RunHook Runs the hook from the root of the work tree (GitDir in bare repositories) with GIT_DIR set. Missing hooks
+// succeed. Hooks that are not executable are ignored with a hint, like git does. Returns HookError if the hook exits with
+// non-zero code, so the operation can be aborted
+func (r *Repository) RunHook(name string, options HookOptions) error {
+	path := r.HookPath(name)
+	stat, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if stat.IsDir() {
+		return nil
+	}
+	if stat.Mode()&0111 == 0 {
+		fmt.Fprintln(os.Stderr, "hint: The '"+path+"' hook was ignored because it's not set as executable.")
+		return nil
+	}
+
+	command := exec.Command(path, options.Args...)
+	command.Dir = r.hookWorkingDir()
+	command.Env = append(append(os.Environ(), "GIT_DIR="+r.GitDir), options.Env...)
+	command.Stdin = strings.NewReader(options.Stdin)
+	command.Stdout, command.Stderr = os.Stderr, os.Stderr
+	if options.Output != nil {
+		command.Stdout, command.Stderr = options.Output, options.Output
+	}
+
+	err = command.Run()
+	var exitError *exec.ExitError
+	if errors.As(err, &exitError) {
+		return HookError{Name: name, ExitCode: exitError.ExitCode()}
+	}
+
+	return err
+}
+
+// FormatRefUpdatesHookInput Stdin of pre-receive, post-receive and reference-transaction: <old sha> <new sha> <ref name> per line
+func FormatRefUpdatesHookInput(updates []RefUpdate) string {
+	var input strings.Builder
+	for _, update := range updates {
+		input.WriteString(update.OldValue + " " + update.NewValue + " " + update.Name + "\n")
+	}
+	return input.String()
+}
+
+func (r *Repository) hookWorkingDir() string {
+	if r.IsBare() {
+		return r.GitDir
+	}
+	return r.WorkTree
+}
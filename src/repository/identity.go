@@ -0,0 +1,38 @@
+package repository
+
+import (
+	"git/src/objects"
+	"os"
+	"time"
+)
+
+const DEFAULT_IDENTITY_NAME = "Jaime"
+
+const (
+	AUTHOR_ROLE    = "AUTHOR"
+	COMMITTER_ROLE = "COMMITTER"
+)
+
+// GetIdentity Returns the signature used in new commits for role (AUTHOR_ROLE or COMMITTER_ROLE).
+// Environment variables GIT_<role>_NAME, GIT_<role>_EMAIL and GIT_<role>_DATE take precedence over user.name and user.email
+func (r *Repository) GetIdentity(role string) objects.Signature {
+	name := os.Getenv("GIT_" + role + "_NAME")
+	if name == "" {
+		name = r.Config.MustString("user.name", DEFAULT_IDENTITY_NAME)
+	}
+	email := os.Getenv("GIT_" + role + "_EMAIL")
+	if email == "" {
+		email = r.Config.String("user.email")
+	}
+
+	when := time.Now()
+	if date := os.Getenv("GIT_" + role + "_DATE"); date != "" {
+		if parsed := objects.ParseSignature("<> " + date); !parsed.When.IsZero() {
+			when = parsed.When
+		} else if parsed, err := time.Parse(time.RFC3339, date); err == nil {
+			when = parsed
+		}
+	}
+
+	return objects.CreateSignature(name, email, when)
+}
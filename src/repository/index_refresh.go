@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"git/src/index"
+	"git/src/objects"
+	"git/src/utils"
+	"os"
+)
+
+// IsWorkTreeFileModified Returns true if the file of the entry in the work tree differs from the index or doesnt exist.
+// Entries with the skip-worktree bit are never modified.
+// Files are only hashed when the stat info doesnt match or cannot be trusted (racy). If the hash confirms that the file
+// is unchanged, the stat info of the entry is refreshed in indexObject, so it has to be written to be persisted
+func (r *Repository) IsWorkTreeFileModified(indexObject *index.IndexObject, entry index.IndexEntry) (bool, error) {
+	if entry.IsGitlink() || entry.SkipWorktree { //Submodules are compared by their checked out commit
+		return false, nil
+	}
+
+	path := utils.Path(r.WorkTree, entry.FullPathName)
+	stat, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return true, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	if modified, mustHash := r.IsWorkTreeFileStatModified(indexObject, entry, stat); !mustHash {
+		return modified, nil
+	}
+
+	sha, err := r.HashWorkTreeFile(path, stat)
+	if err != nil {
+		return false, err
+	}
+	if sha != entry.Sha {
+		return true, nil
+	}
+
+	refreshedEntry := index.CreateIndexEntry(stat, entry.FullPathName, entry.Sha)
+	refreshedEntry.ModeType, refreshedEntry.ModePerms = entry.ModeType, entry.ModePerms
+	indexObject.Entries[entry.FullPathName] = refreshedEntry
+
+	return false, nil
+}
+
+// IsWorkTreeFileStatModified Same as IsWorkTreeFileModified without hashing the file. Returns true in mustHash if the stat
+// info cannot tell whether the file is modified
+func (r *Repository) IsWorkTreeFileStatModified(indexObject *index.IndexObject, entry index.IndexEntry, stat os.FileInfo) (bool, bool) {
+	if r.isModeModified(entry, stat) {
+		return true, false
+	}
+	if entry.MatchesStat(stat) && !entry.IsRacy(indexObject.ModTime) {
+		return false, false
+	}
+	return false, true
+}
+
+// Symlink and executable bit changes are ignored if core.symlinks or core.filemode are disabled
+func (r *Repository) isModeModified(entry index.IndexEntry, stat os.FileInfo) bool {
+	actualEntry := index.CreateIndexEntry(stat, entry.FullPathName, entry.Sha)
+
+	if actualEntry.IsSymlink() != entry.IsSymlink() {
+		return r.SymlinksEnabled() || actualEntry.IsSymlink()
+	}
+
+	return !entry.IsSymlink() && r.FileModeEnabled() && actualEntry.IsExecutable() != entry.IsExecutable()
+}
+
+// HashWorkTreeFile Returns the sha of the blob that would be created from the file. Symlinks are hashed by their target
+func (r *Repository) HashWorkTreeFile(path string, stat os.FileInfo) (string, error) {
+	content, err := r.ReadWorkTreeFileBlob(path, stat)
+	if err != nil {
+		return "", err
+	}
+
+	return objects.CreateBlobObject(content).Sha(), nil
+}
+
+// WriteWorkTreeFileBlob Stores the file as a blob object and returns its sha
+func (r *Repository) WriteWorkTreeFileBlob(path string, stat os.FileInfo) (string, error) {
+	content, err := r.ReadWorkTreeFileBlob(path, stat)
+	if err != nil {
+		return "", err
+	}
+
+	return r.WriteObject(objects.CreateBlobObject(content))
+}
+
+// ReadWorkTreeFileBlob Returns the content of the blob that would be created from the file: its content with the conversions
+// of its attributes applied (Ex: CRLF -> LF). Symlinks are not converted
+func (r *Repository) ReadWorkTreeFileBlob(path string, stat os.FileInfo) ([]byte, error) {
+	content, err := ReadWorkTreeFile(path, stat)
+	if err != nil || stat.Mode()&os.ModeSymlink != 0 {
+		return content, err
+	}
+
+	repositoryAttributes, err := r.ReadAttributes()
+	if err != nil {
+		return nil, err
+	}
+	return r.ConvertToGit(repositoryAttributes, r.AbsolutePathToRepositoryPath(path), content)
+}
+
+// ReadWorkTreeFile Returns the content of the file without conversions. The content of symlinks is their target
+func ReadWorkTreeFile(path string, stat os.FileInfo) ([]byte, error) {
+	if stat.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(path)
+		return []byte(target), err
+	}
+
+	return os.ReadFile(path)
+}
@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"errors"
+	"git/src/lfs"
+	"git/src/utils"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LfsStore Local store of the content of the files tracked with filter=lfs
+func (r *Repository) LfsStore() lfs.Store {
+	return lfs.Store{Dir: utils.Paths(r.CommonDir, "lfs", "objects")}
+}
+
+// LfsRemoteStore Store where missing objects are fetched from: lfs.url, remote.<remote>.lfsurl or remote.<remote>.url (origin
+// by default). If the url is a local repository its store is used, otherwise the url is a directory with the layout of a store
+func (r *Repository) LfsRemoteStore(remote string) (lfs.Store, error) {
+	if remote == "" {
+		remote = DEFAULT_REMOTE_NAME
+	}
+
+	url := r.Config.String("lfs.url")
+	if url == "" {
+		url = r.Config.MustString("remote."+remote+".lfsurl", r.Config.String("remote."+remote+".url"))
+	}
+	if url == "" {
+		return lfs.Store{}, errors.New("no LFS store: set lfs.url or the url of remote " + remote)
+	}
+
+	path := strings.TrimPrefix(url, "file://")
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(r.hookWorkingDir(), path)
+	}
+	if stat, err := os.Stat(path); err != nil || !stat.IsDir() {
+		return lfs.Store{}, errors.New("LFS store " + url + " is not a local directory")
+	}
+
+	if remoteRepository, err := LoadRepository(path); err == nil {
+		return remoteRepository.LfsStore(), nil
+	}
+	return lfs.Store{Dir: path}, nil
+}
+
+// Clean stores the content and returns its pointer. Smudge returns the content of the pointer, fetching it from the remote store
+// if it is missing. If it cannot be fetched, or GIT_LFS_SKIP_SMUDGE is set, the pointer is left in the work tree
+func (r *Repository) applyLfsFilter(content []byte, direction string) ([]byte, error) {
+	pointer, isPointer := lfs.ParsePointer(content)
+
+	if direction == CLEAN_FILTER {
+		if isPointer {
+			return content, nil
+		}
+		pointer, err := r.LfsStore().Write(content)
+		return pointer.Serialize(), err
+	}
+
+	if !isPointer || os.Getenv("GIT_LFS_SKIP_SMUDGE") == "1" {
+		return content, nil
+	}
+	if !r.LfsStore().Has(pointer) {
+		remoteStore, err := r.LfsRemoteStore("")
+		if err != nil || r.LfsStore().Fetch(remoteStore, pointer) != nil {
+			return content, nil
+		}
+	}
+
+	return r.LfsStore().Read(pointer)
+}
@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"container/heap"
+	"sort"
+)
+
+const (
+	reachableFromFirst = 1 << iota
+	reachableFromSecond
+	staleCommit //Reachable from a common ancestor already found, so it cannot be a best one
+)
+
+// MergeBases Best common ancestors of the commits: common ancestors that are not reachable from other common ancestors.
+// Newest first. Like git, the commits are painted newest first from both sides, and the walk stops once every queued
+// commit is reachable from a common ancestor. Shallow commits are roots, and the commit graph is used if present
+func (r *Repository) MergeBases(first string, second string) ([]string, error) {
+	if first == second {
+		return []string{first}, nil
+	}
+
+	flags := make(map[string]int)
+	commitTimes := make(map[string]int64)
+	queue := &commitQueue{}
+	push := func(sha string, flag int) error {
+		node, err := r.ReadCommitNode(sha)
+		if err != nil {
+			return err
+		}
+		flags[sha] |= flag
+		commitTimes[sha] = node.CommitTime
+		heap.Push(queue, &queuedCommit{RevCommit: RevCommit{Sha: sha}, parents: node.Parents, when: node.CommitTime, order: queue.Len()})
+		return nil
+	}
+	if err := push(first, reachableFromFirst); err != nil {
+		return nil, err
+	}
+	if err := push(second, reachableFromSecond); err != nil {
+		return nil, err
+	}
+
+	candidates := make([]string, 0)
+	for hasNonStaleCommits(*queue, flags) {
+		actual := heap.Pop(queue).(*queuedCommit)
+		flag := flags[actual.Sha] & (reachableFromFirst | reachableFromSecond | staleCommit)
+		if flag == reachableFromFirst|reachableFromSecond {
+			candidates = append(candidates, actual.Sha)
+			flag |= staleCommit
+			flags[actual.Sha] |= staleCommit
+		}
+
+		for _, parent := range actual.parents {
+			if flags[parent]&flag == flag {
+				continue
+			}
+			if err := push(parent, flag); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	result := make([]string, 0, len(candidates))
+	for i, candidate := range candidates {
+		redundant := false
+		for j, other := range candidates {
+			if i == j {
+				continue
+			}
+			reachable, err := r.IsAncestor(candidate, other)
+			if err != nil {
+				return nil, err
+			}
+			if reachable {
+				redundant = true
+				break
+			}
+		}
+		if !redundant {
+			result = append(result, candidate)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return commitTimes[result[i]] > commitTimes[result[j]]
+	})
+
+	return result, nil
+}
+
+func hasNonStaleCommits(queue commitQueue, flags map[string]int) bool {
+	for _, queued := range queue {
+		if flags[queued.Sha]&staleCommit == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAncestor Returns true if ancestor is reachable from commit, or is the same commit. With the commit graph, commits
+// with a lower generation than the ancestor are not walked, since they cannot reach it
+func (r *Repository) IsAncestor(ancestor string, commit string) (bool, error) {
+	ancestorNode, err := r.ReadCommitNode(ancestor)
+	if err != nil {
+		return false, err
+	}
+
+	visited := map[string]bool{commit: true}
+	pending := []string{commit}
+	for len(pending) > 0 {
+		sha := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if sha == ancestor {
+			return true, nil
+		}
+
+		node, err := r.ReadCommitNode(sha)
+		if err != nil {
+			return false, err
+		}
+		if node.Generation != 0 && node.Generation < ancestorNode.Generation {
+			continue
+		}
+		for _, parent := range node.Parents {
+			if !visited[parent] {
+				visited[parent] = true
+				pending = append(pending, parent)
+			}
+		}
+	}
+
+	return false, nil
+}
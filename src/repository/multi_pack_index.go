@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"errors"
+	"git/src/midx"
+	"git/src/packfile"
+	"git/src/utils"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+func (r *Repository) MultiPackIndexPath() string {
+	return utils.Path(r.PackDir(), "multi-pack-index")
+}
+
+// WriteMultiPackIndex Replaces the multi-pack-index with one of all the packs. The pack with more objects is the preferred
+// one: objects stored in several packs are taken from it. Returns the number of objects indexed
+func (r *Repository) WriteMultiPackIndex(writeBitmap bool) (int, error) {
+	indexPaths, err := filepath.Glob(utils.Path(r.PackDir(), "pack-*.idx"))
+	if err != nil {
+		return 0, err
+	}
+	if len(indexPaths) == 0 {
+		return 0, errors.New("no pack files to index")
+	}
+	sort.Strings(indexPaths)
+
+	packNames := make([]string, 0, len(indexPaths))
+	indexes := make([]*packfile.PackIndex, 0, len(indexPaths))
+	preferredPack := 0
+	for i, indexPath := range indexPaths {
+		index, err := readPackIndexFile(indexPath)
+		if err != nil {
+			return 0, errors.New(indexPath + ": " + err.Error())
+		}
+		packNames = append(packNames, filepath.Base(indexPath))
+		indexes = append(indexes, index)
+		if len(index.Entries) > len(indexes[preferredPack].Entries) {
+			preferredPack = i
+		}
+	}
+
+	packOrder := []int{preferredPack}
+	for i, _ := range indexes {
+		if i != preferredPack {
+			packOrder = append(packOrder, i)
+		}
+	}
+	indexedObjects := make([]midx.Object, 0, len(indexes[preferredPack].Entries))
+	indexed := make(map[string]bool, len(indexes[preferredPack].Entries))
+	for _, packId := range packOrder {
+		for _, entry := range indexes[packId].Entries {
+			if !indexed[entry.Sha] {
+				indexed[entry.Sha] = true
+				indexedObjects = append(indexedObjects, midx.Object{Sha: entry.Sha, PackId: uint32(packId), Offset: entry.Offset})
+			}
+		}
+	}
+
+	content, err := midx.Serialize(packNames, indexedObjects, uint32(preferredPack))
+	if err != nil {
+		return 0, err
+	}
+	if err := writeFileAtomically(r.MultiPackIndexPath(), content); err != nil {
+		return 0, err
+	}
+	r.resetPacks()
+
+	oldBitmaps, _ := filepath.Glob(utils.Path(r.PackDir(), "multi-pack-index-*.bitmap"))
+	for _, oldBitmap := range oldBitmaps {
+		os.Remove(oldBitmap)
+	}
+	if writeBitmap {
+		if err := r.writeMultiPackIndexBitmap(); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(indexedObjects), nil
+}
+
+// VerifyMultiPackIndex Checks that the multi-pack-index has every object of its packs at the offsets of their indexes and
+// that its bitmap, if any, can be read
+func (r *Repository) VerifyMultiPackIndex() error {
+	content, err := os.ReadFile(r.MultiPackIndexPath())
+	if err != nil {
+		return err
+	}
+	multiPackIndex, err := midx.Deserialize(content)
+	if err != nil {
+		return err
+	}
+
+	indexes := make([]*packfile.PackIndex, 0, len(multiPackIndex.PackNames))
+	for _, name := range multiPackIndex.PackNames {
+		if !utils.CheckFileOrDirExists(packPathOfIndex(utils.Path(r.PackDir(), name))) {
+			return errors.New("multi-pack-index pack " + name + " does not exist")
+		}
+		index, err := readPackIndexFile(utils.Path(r.PackDir(), name))
+		if err != nil {
+			return errors.New(name + ": " + err.Error())
+		}
+		indexes = append(indexes, index)
+	}
+
+	for position := 0; position < multiPackIndex.Len(); position++ {
+		object, err := multiPackIndex.ObjectAt(position)
+		if err != nil {
+			return err
+		}
+		if offset, found := indexes[object.PackId].Lookup(object.Sha); !found || offset != object.Offset {
+			return errors.New("incorrect object offset for " + object.Sha + " in pack " + multiPackIndex.PackNames[object.PackId])
+		}
+	}
+	for packId, index := range indexes {
+		for _, entry := range index.Entries {
+			if _, found := multiPackIndex.Lookup(entry.Sha); !found {
+				return errors.New("object " + entry.Sha + " of pack " + multiPackIndex.PackNames[packId] + " is not in the multi-pack-index")
+			}
+		}
+	}
+
+	if _, err := os.Stat(r.multiPackIndexBitmapPath(multiPackIndex)); err == nil {
+		if _, err := r.readReachabilityIndex(multiPackIndex); err != nil {
+			return err
+		}
+	}
+	if order, hasOrder := multiPackIndex.PseudoPackOrder(); hasOrder {
+		seen := make([]bool, len(order))
+		for _, position := range order {
+			if seen[position] {
+				return errors.New("multi-pack-index reverse index has the position " + strconv.Itoa(position) + " twice")
+			}
+			seen[position] = true
+		}
+	}
+	return nil
+}
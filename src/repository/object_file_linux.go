@@ -0,0 +1,44 @@
+//go:build linux
+
+package repository
+
+import (
+	"io"
+	"os"
+	"syscall"
+)
+
+// objectFile Loose object file read with plain syscalls. os.Open stats every file to know if the poller can be used for it,
+// which is an extra syscall for every object read by history walks
+type objectFile int
+
+func openObjectFilePath(path string) (io.ReadCloser, error) {
+	for {
+		fd, err := syscall.Open(path, syscall.O_RDONLY|syscall.O_CLOEXEC, 0)
+		if err == syscall.EINTR {
+			continue
+		} else if err != nil {
+			return nil, &os.PathError{Op: "open", Path: path, Err: err}
+		}
+		return objectFile(fd), nil
+	}
+}
+
+func (f objectFile) Read(p []byte) (int, error) {
+	for {
+		n, err := syscall.Read(int(f), p)
+		switch {
+		case err == syscall.EINTR:
+			continue
+		case err != nil:
+			return 0, &os.PathError{Op: "read", Err: err}
+		case n == 0 && len(p) > 0:
+			return 0, io.EOF
+		}
+		return n, nil
+	}
+}
+
+func (f objectFile) Close() error {
+	return syscall.Close(int(f))
+}
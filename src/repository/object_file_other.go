@@ -0,0 +1,12 @@
+//go:build !linux
+
+package repository
+
+import (
+	"io"
+	"os"
+)
+
+func openObjectFilePath(path string) (io.ReadCloser, error) {
+	return os.Open(path)
+}
@@ -0,0 +1,233 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"git/src/midx"
+	"git/src/objects"
+	"git/src/packfile"
+	"git/src/utils"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Packed objects are found with the multi-pack-index, so a lookup is a single binary search. Packs written after the
+// multi-pack-index (or all of them if there is none) are searched with their own index
+type packStore struct {
+	multiPackIndex   *midx.MultiPackIndex
+	midxPacks        []*packfile.Pack   //Opened when an object of the pack is read. Same positions as the pack names
+	packs            []*storedPack      //Packs that are not in the multi-pack-index
+	reachability     *reachabilityIndex //Bitmaps of the multi-pack-index. Nil if there are none
+	reachabilityRead bool
+}
+
+type storedPack struct {
+	path  string
+	index *packfile.PackIndex
+	pack  *packfile.Pack //Nil until an object of the pack is read
+}
+
+func (r *Repository) PackDir() string {
+	return utils.Paths(r.CommonDir, "objects", "pack")
+}
+
+func (r *Repository) HasPacks() bool {
+	indexPaths, _ := filepath.Glob(utils.Path(r.PackDir(), "pack-*.idx"))
+	return len(indexPaths) > 0
+}
+
+// The pack directory is read once. Invalid indexes are ignored with a warning
+func (r *Repository) readPacks() *packStore {
+	if r.packs != nil {
+		return r.packs
+	}
+	r.packs = &packStore{}
+
+	inMultiPackIndex := make(map[string]bool)
+	if multiPackIndex := r.readMultiPackIndex(); multiPackIndex != nil {
+		r.packs.multiPackIndex = multiPackIndex
+		r.packs.midxPacks = make([]*packfile.Pack, len(multiPackIndex.PackNames))
+		for _, name := range multiPackIndex.PackNames {
+			inMultiPackIndex[name] = true
+		}
+	}
+
+	indexPaths, _ := filepath.Glob(utils.Path(r.PackDir(), "pack-*.idx"))
+	sort.Strings(indexPaths)
+	for _, indexPath := range indexPaths {
+		if inMultiPackIndex[filepath.Base(indexPath)] {
+			continue
+		}
+		index, err := readPackIndexFile(indexPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "warning: ignoring "+indexPath+": "+err.Error())
+			continue
+		}
+		r.packs.packs = append(r.packs.packs, &storedPack{path: packPathOfIndex(indexPath), index: index})
+	}
+
+	return r.packs
+}
+
+// The multi-pack-index is not used if core.multiPackIndex is false, it is invalid or any of its packs is missing
+func (r *Repository) readMultiPackIndex() *midx.MultiPackIndex {
+	if !r.Config.MustBool("core.multiPackIndex", true) {
+		return nil
+	}
+	content, err := os.ReadFile(r.MultiPackIndexPath())
+	if err != nil {
+		return nil
+	}
+
+	multiPackIndex, err := midx.Deserialize(content)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "warning: ignoring "+r.MultiPackIndexPath()+": "+err.Error())
+		return nil
+	}
+	for _, name := range multiPackIndex.PackNames {
+		if !utils.CheckFileOrDirExists(packPathOfIndex(utils.Path(r.PackDir(), name))) {
+			fmt.Fprintln(os.Stderr, "warning: ignoring "+r.MultiPackIndexPath()+": pack "+name+" does not exist")
+			return nil
+		}
+	}
+	return multiPackIndex
+}
+
+func readPackIndexFile(indexPath string) (*packfile.PackIndex, error) {
+	content, err := os.ReadFile(indexPath)
+	if err != nil {
+		return nil, err
+	}
+	return packfile.DeserializePackIndex(content)
+}
+
+// pack-<sha>.idx -> pack-<sha>.pack
+func packPathOfIndex(indexPath string) string {
+	return strings.TrimSuffix(indexPath, ".idx") + ".pack"
+}
+
+// Closes the packs so the pack directory is read again. Used after packs or the multi-pack-index are written
+func (r *Repository) resetPacks() {
+	if r.packs == nil {
+		return
+	}
+	for _, pack := range r.packs.midxPacks {
+		if pack != nil {
+			pack.Close()
+		}
+	}
+	for _, stored := range r.packs.packs {
+		if stored.pack != nil {
+			stored.pack.Close()
+		}
+	}
+	r.packs = nil
+}
+
+// Returns the pack that contains the object and its offset
+func (r *Repository) findPackedObject(sha string) (*packfile.Pack, int64, bool, error) {
+	store := r.readPacks()
+
+	if store.multiPackIndex != nil {
+		if object, found := store.multiPackIndex.Lookup(sha); found {
+			if store.midxPacks[object.PackId] == nil {
+				pack, err := packfile.OpenPack(packPathOfIndex(utils.Path(r.PackDir(), store.multiPackIndex.PackNames[object.PackId])))
+				if err != nil {
+					return nil, 0, false, err
+				}
+				store.midxPacks[object.PackId] = pack
+			}
+			return store.midxPacks[object.PackId], object.Offset, true, nil
+		}
+	}
+
+	for _, stored := range store.packs {
+		if offset, found := stored.index.Lookup(sha); found {
+			if stored.pack == nil {
+				pack, err := packfile.OpenPack(stored.path)
+				if err != nil {
+					return nil, 0, false, err
+				}
+				stored.pack = pack
+			}
+			return stored.pack, offset, true, nil
+		}
+	}
+
+	return nil, 0, false, nil
+}
+
+// Only the indexes are searched, no pack is opened, so it can be called from several goroutines once the packs are read
+func (r *Repository) hasPackedObject(sha string) bool {
+	store := r.readPacks()
+	if store.multiPackIndex != nil {
+		if _, found := store.multiPackIndex.Lookup(sha); found {
+			return true
+		}
+	}
+	for _, stored := range store.packs {
+		if _, found := stored.index.Lookup(sha); found {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns false if the object is not packed
+func (r *Repository) readPackedObject(sha string) (objects.ObjectType, []byte, bool, error) {
+	pack, offset, found, err := r.findPackedObject(sha)
+	if !found || err != nil {
+		return "", nil, found, err
+	}
+
+	objectType, data, err := pack.ReadObject(offset, r.resolvePackedBase)
+	if err != nil {
+		return "", nil, true, errors.New("cannot read packed object " + sha + ": " + err.Error())
+	}
+	return objectType, data, true, nil
+}
+
+// Bases of REF_DELTA objects can be in any pack
+func (r *Repository) resolvePackedBase(sha string) (packfile.PackObject, bool) {
+	objectType, data, found, err := r.readPackedObject(sha)
+	if !found || err != nil {
+		return packfile.PackObject{}, false
+	}
+	return packfile.PackObject{Type: objectType, Sha: sha, Data: data}, true
+}
+
+func (r *Repository) readPackedObjectType(sha string) (objects.ObjectType, error) {
+	pack, offset, found, err := r.findPackedObject(sha)
+	if err != nil {
+		return "", err
+	}
+	if !found {
+		return "", errors.New("object " + sha + " is not packed")
+	}
+	return pack.ReadObjectType(offset, r.resolvePackedBase)
+}
+
+// Used to resolve abbreviated shas
+func (r *Repository) packedShasWithPrefix(prefix string) []string {
+	store := r.readPacks()
+
+	result := make([]string, 0)
+	if store.multiPackIndex != nil {
+		result = append(result, store.multiPackIndex.ShasWithPrefix(prefix)...)
+	}
+	for _, stored := range store.packs {
+		result = append(result, stored.index.ShasWithPrefix(prefix)...)
+	}
+	return result
+}
+
+func containsString(values []string, value string) bool {
+	for _, actual := range values {
+		if actual == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"git/src/objects"
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// WorkTreeFile File of the work tree to store as a blob. The path is absolute
+type WorkTreeFile struct {
+	Path string
+	Stat os.FileInfo
+}
+
+// Number of goroutines that read, hash, compress and write objects at the same time
+var objectWriters = runtime.NumCPU()
+
+// WriteWorkTreeFileBlobs Same as WriteWorkTreeFileBlob for every file, reading, hashing, compressing and writing them in
+// parallel. Returns the shas in the order of the files. The attributes are read once. Their lookup and the clean filters
+// are serialized, since they cache rules and filter processes receive one file at a time
+func (r *Repository) WriteWorkTreeFileBlobs(files []WorkTreeFile) ([]string, error) {
+	repositoryAttributes, err := r.ReadAttributes()
+	if err != nil {
+		return nil, err
+	}
+	r.readPacks() //The workers only search the indexes once they are read
+
+	var conversionMutex sync.Mutex
+	shas := make([]string, len(files))
+	err = runInParallel(len(files), func(i int) error {
+		content, err := ReadWorkTreeFile(files[i].Path, files[i].Stat)
+		if err != nil {
+			return err
+		}
+		if files[i].Stat.Mode()&os.ModeSymlink == 0 {
+			conversionMutex.Lock()
+			content, err = r.ConvertToGit(repositoryAttributes, r.AbsolutePathToRepositoryPath(files[i].Path), content)
+			conversionMutex.Unlock()
+			if err != nil {
+				return err
+			}
+		}
+
+		shas[i], err = r.WriteObject(objects.CreateBlobObject(content))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return shas, nil
+}
+
+// WriteObjects Same as WriteObject for every object, compressing and writing them in parallel. Returns the shas in the
+// order of the objects
+func (r *Repository) WriteObjects(objectsToWrite []*objects.Object) ([]string, error) {
+	r.readPacks()
+
+	shas := make([]string, len(objectsToWrite))
+	err := runInParallel(len(objectsToWrite), func(i int) error {
+		var err error
+		shas[i], err = r.WriteObject(objectsToWrite[i])
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return shas, nil
+}
+
+// Calls work with every index from 0 to count - 1 using at most objectWriters goroutines. No more work is started after
+// an error. The error of the lowest index is returned, so it doesnt depend on the scheduling
+func runInParallel(count int, work func(i int) error) error {
+	workers := objectWriters
+	if workers > count {
+		workers = count
+	}
+
+	errs := make([]error, count)
+	var next int64 = -1
+	var failed atomic.Bool
+	var waitGroup sync.WaitGroup
+	for worker := 0; worker < workers; worker++ {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			for !failed.Load() {
+				i := int(atomic.AddInt64(&next, 1))
+				if i >= count {
+					return
+				}
+				if errs[i] = work(i); errs[i] != nil {
+					failed.Store(true)
+				}
+			}
+		}()
+	}
+	waitGroup.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"errors"
+	"git/src/protocol"
+	"strings"
+)
+
+// PromisorRemote Remote that promises to have the objects omitted by a partial clone: extensions.partialClone or the first
+// remote.<name>.promisor. Empty if the repository is not a partial clone
+func (r *Repository) PromisorRemote() string {
+	if remote := r.Config.String("extensions.partialClone"); remote != "" {
+		return remote
+	}
+
+	for _, entry := range r.Config.Entries() {
+		remote, isPromisor := strings.CutSuffix(entry.Key, ".promisor")
+		if remote, isRemote := strings.CutPrefix(remote, "remote."); isRemote && isPromisor && r.Config.MustBool(entry.Key, false) {
+			return remote
+		}
+	}
+	return ""
+}
+
+// IsPartialClone Objects missing in partial clones are not errors, they are fetched from the promisor remote when read
+func (r *Repository) IsPartialClone() bool {
+	return r.PromisorRemote() != ""
+}
+
+// FetchPromisedObjects Fetches the objects from the promisor remote in a single request, without filter. Objects already
+// present are skipped. Fetches started while another one is running (Ex: resolving deltas) fail, to avoid recursion
+func (r *Repository) FetchPromisedObjects(shas []string) error {
+	remote := r.PromisorRemote()
+	if remote == "" {
+		return errors.New("not a partial clone")
+	}
+	url := r.Config.String("remote." + remote + ".url")
+	if !IsHttpUrl(url) {
+		return errors.New("promisor remote " + remote + " has no http url")
+	}
+	if r.fetchingPromised {
+		return errors.New("already fetching from promisor remote " + remote)
+	}
+
+	missing := make([]string, 0, len(shas))
+	for _, sha := range shas {
+		if !r.HasObject(sha) {
+			missing = append(missing, sha)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	r.fetchingPromised = true
+	defer func() { r.fetchingPromised = false }()
+
+	noFilter, _ := protocol.ParseObjectFilter("")
+	packObjects, _, err := r.FetchRemotePack(url, missing, nil, noFilter, protocol.DeepenOptions{})
+	if err != nil {
+		return errors.New("cannot fetch missing objects from promisor remote " + remote + ": " + err.Error())
+	}
+	return r.WritePackObjects(packObjects)
+}
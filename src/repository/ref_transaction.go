@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"git/src/objects"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// RefUpdate OldValue and NewValue equal to objects.NO_PARENT_COMMIT_SHA mean that the ref doesnt exist / has to be deleted
+type RefUpdate struct {
+	Name     string //Ex: refs/heads/master
+	OldValue string
+	NewValue string
+}
+
+func (u RefUpdate) IsDelete() bool {
+	return u.NewValue == objects.NO_PARENT_COMMIT_SHA
+}
+
+type RefUpdateError struct {
+	Name   string
+	Reason string
+}
+
+func (e RefUpdateError) Error() string {
+	return "Cannot update " + e.Name + ": " + e.Reason
+}
+
+// UpdateRefsAtomically Either all refs are updated or none of them. Every ref is locked with a <ref>.lock file,
+// its old value is checked and only when all of them are locked and verified the lock files are renamed. If a rename
+// fails, the refs already updated are restored to their old values. If some of them cannot be restored, the error says
+// which ones and the aborted hook is not run, since the transaction was partially committed.
+// The reference-transaction hook is run when the refs are prepared, committed or aborted. It can abort prepared updates
+func (r *Repository) UpdateRefsAtomically(updates []RefUpdate) error {
+	lockedFiles := make([]string, 0)
+	hookInput, prepared := FormatRefUpdatesHookInput(updates), false
+	releaseLocks := func() {
+		for _, lockedFile := range lockedFiles {
+			os.Remove(lockedFile)
+		}
+		if prepared {
+			r.RunHook(REFERENCE_TRANSACTION_HOOK, HookOptions{Args: []string{"aborted"}, Stdin: hookInput})
+		}
+	}
+
+	for _, update := range updates {
+		lockFilePath, err := r.lockRef(update)
+		if err != nil {
+			releaseLocks()
+			return err
+		}
+
+		lockedFiles = append(lockedFiles, lockFilePath)
+
+		if err := r.checkRefOldValue(update); err != nil {
+			releaseLocks()
+			return err
+		}
+	}
+
+	prepared = true
+	if err := r.RunHook(REFERENCE_TRANSACTION_HOOK, HookOptions{Args: []string{"prepared"}, Stdin: hookInput}); err != nil {
+		releaseLocks()
+		return RefUpdateError{Name: updates[0].Name, Reason: "reference-transaction hook declined the update"}
+	}
+
+	for i, update := range updates {
+		if err := r.applyRefUpdate(update, lockedFiles[i]); err != nil {
+			reason := err.Error()
+			if notRestored := r.restoreRefs(updates[:i]); len(notRestored) > 0 {
+				reason = reason + ". These refs were updated and could not be restored: " + strings.Join(notRestored, ", ")
+				prepared = false
+			}
+			releaseLocks()
+			return RefUpdateError{Name: update.Name, Reason: reason}
+		}
+	}
+	r.RunHook(REFERENCE_TRANSACTION_HOOK, HookOptions{Args: []string{"committed"}, Stdin: hookInput})
+
+	return nil
+}
+
+// Renames the lock file to the ref, or deletes the ref and its lock file
+func (r *Repository) applyRefUpdate(update RefUpdate, lockFilePath string) error {
+	refPath := r.RefPath(update.Name)
+
+	if update.IsDelete() {
+		if err := os.Remove(refPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		os.Remove(lockFilePath)
+		return nil
+	}
+
+	return os.Rename(lockFilePath, refPath)
+}
+
+// Writes back the old values of refs already updated, deleting the created ones. Returns the refs that cannot be restored
+func (r *Repository) restoreRefs(appliedUpdates []RefUpdate) []string {
+	notRestored := make([]string, 0)
+	for _, update := range appliedUpdates {
+		restore := RefUpdate{Name: update.Name, OldValue: update.NewValue, NewValue: update.OldValue}
+		lockFilePath, err := r.lockRef(restore)
+		if err != nil {
+			notRestored = append(notRestored, update.Name)
+			continue
+		}
+		if err := r.applyRefUpdate(restore, lockFilePath); err != nil {
+			os.Remove(lockFilePath)
+			notRestored = append(notRestored, update.Name)
+		}
+	}
+
+	return notRestored
+}
+
+func (r *Repository) lockRef(update RefUpdate) (string, error) {
+	refPath := r.RefPath(update.Name)
+	lockFilePath := refPath + ".lock"
+
+	if err := os.MkdirAll(filepath.Dir(refPath), os.ModePerm); err != nil {
+		return "", RefUpdateError{Name: update.Name, Reason: err.Error()}
+	}
+
+	lockFile, err := os.OpenFile(lockFilePath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0666)
+	if err != nil {
+		return "", RefUpdateError{Name: update.Name, Reason: "cannot lock ref"}
+	}
+	defer lockFile.Close()
+
+	if !update.IsDelete() {
+		if _, err := lockFile.Write([]byte(update.NewValue + "\n")); err != nil {
+			os.Remove(lockFilePath)
+			return "", RefUpdateError{Name: update.Name, Reason: err.Error()}
+		}
+	}
+
+	return lockFilePath, nil
+}
+
+func (r *Repository) checkRefOldValue(update RefUpdate) error {
+	actualValue := objects.NO_PARENT_COMMIT_SHA
+	if ref, err := r.ResolveRef(update.Name); err == nil {
+		actualValue = ref.Value
+	} else if !IsErrorTypeNoCommitError(err) {
+		return err
+	}
+
+	if actualValue != update.OldValue {
+		return RefUpdateError{Name: update.Name, Reason: "stale info, expected " + update.OldValue + " but found " + actualValue}
+	}
+
+	return nil
+}
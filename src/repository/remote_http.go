@@ -0,0 +1,191 @@
+package repository
+
+import (
+	"bytes"
+	"errors"
+	"git/src/objects"
+	"git/src/packfile"
+	"git/src/protocol"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const UPLOAD_PACK_SERVICE = "git-upload-pack"
+
+// RemoteRefs Refs advertised by the upload-pack service of a remote
+type RemoteRefs struct {
+	Refs         map[string]string //Ref name -> sha. Peeled tags (^{}) are not included
+	HeadSha      string            //Empty if the remote has no commits
+	HeadBranch   string            //Branch HEAD points to (symref capability). Empty if it is detached or unknown
+	Capabilities map[string]bool   //Capabilities without value. Example: filter
+}
+
+func IsHttpUrl(url string) bool {
+	return strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://")
+}
+
+// DiscoverRemoteRefs Reads <url>/info/refs?service=git-upload-pack (smart HTTP protocol version 0)
+func DiscoverRemoteRefs(url string) (RemoteRefs, error) {
+	response, err := http.Get(strings.TrimSuffix(url, "/") + "/info/refs?service=" + UPLOAD_PACK_SERVICE)
+	if err != nil {
+		return RemoteRefs{}, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return RemoteRefs{}, errors.New("repository '" + url + "' not found: " + response.Status)
+	}
+
+	serviceLines, err := protocol.ReadPktLinesUntilFlush(response.Body)
+	if err != nil {
+		return RemoteRefs{}, err
+	}
+	if len(serviceLines) != 1 || serviceLines[0] != "# service="+UPLOAD_PACK_SERVICE {
+		return RemoteRefs{}, errors.New(url + " does not support the smart HTTP protocol")
+	}
+	lines, err := protocol.ReadPktLinesUntilFlush(response.Body)
+	if err != nil {
+		return RemoteRefs{}, err
+	}
+
+	remoteRefs := RemoteRefs{Refs: make(map[string]string), Capabilities: make(map[string]bool)}
+	for i, line := range lines {
+		if i == 0 {
+			var capabilities string
+			line, capabilities, _ = strings.Cut(line, "\x00")
+			remoteRefs.parseCapabilities(capabilities)
+		}
+
+		sha, name, found := strings.Cut(line, " ")
+		if !found || sha == objects.NO_PARENT_COMMIT_SHA || strings.HasSuffix(name, "^{}") {
+			continue
+		}
+		if name == "HEAD" {
+			remoteRefs.HeadSha = sha
+		} else {
+			remoteRefs.Refs[name] = sha
+		}
+	}
+
+	return remoteRefs, nil
+}
+
+func (r *RemoteRefs) parseCapabilities(capabilities string) {
+	for _, capability := range strings.Fields(capabilities) {
+		if target, isSymref := strings.CutPrefix(capability, "symref=HEAD:"); isSymref {
+			r.HeadBranch = strings.TrimPrefix(target, "refs/heads/")
+		} else if !strings.Contains(capability, "=") {
+			r.Capabilities[capability] = true
+		}
+	}
+}
+
+// FetchRemotePack Asks the upload-pack service of url for the objects reachable from wants and not from haves. Deltas
+// against objects not included in the pack are resolved with the objects of this repository
+// If deepen is not empty the history is limited, and the changes of the shallow commits are returned. They must be applied
+// with UpdateShallowCommits once the objects are written
+func (r *Repository) FetchRemotePack(url string, wants []string, haves []string, filter protocol.ObjectFilter,
+	deepen protocol.DeepenOptions) ([]packfile.PackObject, ShallowUpdate, error) {
+	shallowUpdate := ShallowUpdate{Shallows: make([]string, 0), Unshallows: make([]string, 0)}
+	shallowCommits, err := r.ReadShallowCommits()
+	if err != nil {
+		return nil, shallowUpdate, err
+	}
+
+	var request bytes.Buffer
+	for i, want := range wants {
+		capabilities := ""
+		if i == 0 && deepen.Relative {
+			capabilities = " ofs-delta deepen-relative agent=git/jaime"
+		} else if i == 0 {
+			capabilities = " ofs-delta agent=git/jaime"
+		}
+		protocol.WritePktLine(&request, "want "+want+capabilities+"\n")
+	}
+	for _, shallow := range sortedShaSet(shallowCommits) {
+		protocol.WritePktLine(&request, "shallow "+shallow+"\n")
+	}
+	if deepen.Depth > 0 {
+		protocol.WritePktLine(&request, "deepen "+strconv.Itoa(deepen.Depth)+"\n")
+	}
+	if deepen.Since != 0 {
+		protocol.WritePktLine(&request, "deepen-since "+strconv.FormatInt(deepen.Since, 10)+"\n")
+	}
+	if !filter.IsEmpty() {
+		protocol.WritePktLine(&request, "filter "+filter.Spec+"\n")
+	}
+	protocol.WriteFlush(&request)
+	for _, have := range haves {
+		protocol.WritePktLine(&request, "have "+have+"\n")
+	}
+	protocol.WritePktLine(&request, "done\n")
+
+	response, err := http.Post(strings.TrimSuffix(url, "/")+"/"+UPLOAD_PACK_SERVICE, "application/x-"+UPLOAD_PACK_SERVICE+"-request", &request)
+	if err != nil {
+		return nil, shallowUpdate, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, shallowUpdate, errors.New("fetch from " + url + " failed: " + response.Status)
+	}
+
+	if !deepen.IsEmpty() {
+		if shallowUpdate, err = readShallowUpdate(response.Body); err != nil {
+			return nil, shallowUpdate, err
+		}
+	}
+
+	acknowledgement, err := protocol.ReadPktLine(response.Body)
+	if err != nil {
+		return nil, shallowUpdate, err
+	}
+	if ack := acknowledgement.String(); ack != "NAK" && !strings.HasPrefix(ack, "ACK ") {
+		return nil, shallowUpdate, errors.New("unexpected upload-pack response: " + ack)
+	}
+
+	packObjects, err := packfile.ReadPack(response.Body, func(sha string) (packfile.PackObject, bool) {
+		rawObject, err := r.ReadRawObject(sha)
+		if err != nil {
+			return packfile.PackObject{}, false
+		}
+		return packfile.PackObject{Type: rawObject.Type, Sha: sha, Data: rawObject.SerializableGitObject.Serialize()}, true
+	})
+	return packObjects, shallowUpdate, err
+}
+
+// Format: [shallow <sha>\n ...] [unshallow <sha>\n ...] 0000
+func readShallowUpdate(reader io.Reader) (ShallowUpdate, error) {
+	shallowUpdate := ShallowUpdate{Shallows: make([]string, 0), Unshallows: make([]string, 0)}
+	lines, err := protocol.ReadPktLinesUntilFlush(reader)
+	if err != nil {
+		return shallowUpdate, err
+	}
+
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 2 && fields[0] == "shallow":
+			shallowUpdate.Shallows = append(shallowUpdate.Shallows, fields[1])
+		case len(fields) == 2 && fields[0] == "unshallow":
+			shallowUpdate.Unshallows = append(shallowUpdate.Unshallows, fields[1])
+		default:
+			return shallowUpdate, errors.New("unexpected upload-pack shallow response: " + line)
+		}
+	}
+	return shallowUpdate, nil
+}
+
+// WritePackObjects Stores the objects that are not present yet as loose objects
+func (r *Repository) WritePackObjects(packObjects []packfile.PackObject) error {
+	for _, packObject := range packObjects {
+		if r.HasObject(packObject.Sha) {
+			continue
+		}
+		if _, err := r.WriteObject(objects.CreateRawObject(packObject.Type, packObject.Data)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
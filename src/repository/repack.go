@@ -0,0 +1,177 @@
+package repository
+
+import (
+	"bytes"
+	"encoding/hex"
+	"git/src/objects"
+	"git/src/packfile"
+	"git/src/utils"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// RepackLooseObjects Moves every loose object to a new pack in objects/pack. Returns the number of objects packed
+func (r *Repository) RepackLooseObjects() (int, error) {
+	shas, err := r.looseObjectShas()
+	if err != nil || len(shas) == 0 {
+		return 0, err
+	}
+
+	packObjects := make(map[string]packfile.PackObject, len(shas))
+	for _, sha := range shas {
+		rawObject, err := r.ReadRawObject(sha)
+		if err != nil {
+			return 0, err
+		}
+		packObjects[sha] = packfile.PackObject{Type: rawObject.Type, Sha: sha, Data: rawObject.SerializableGitObject.Serialize()}
+	}
+
+	var pack bytes.Buffer
+	if err := packfile.WritePack(&pack, getPackOrder(packObjects)); err != nil {
+		return 0, err
+	}
+	index, err := packfile.IndexPack(pack.Bytes())
+	if err != nil {
+		return 0, err
+	}
+
+	if err := os.MkdirAll(r.PackDir(), os.ModePerm); err != nil {
+		return 0, err
+	}
+	packPath := utils.Path(r.PackDir(), "pack-"+hex.EncodeToString(index.PackChecksum)+".pack")
+	if err := writeFileAtomically(packPath, pack.Bytes()); err != nil {
+		return 0, err
+	}
+	if err := writeFileAtomically(packPath[:len(packPath)-len(".pack")]+".idx", index.Serialize()); err != nil { //The pack is not used until its index exists
+		return 0, err
+	}
+	r.resetPacks()
+
+	for _, sha := range shas {
+		os.Remove(utils.Paths(r.CommonDir, "objects", sha[:2], sha[2:]))
+		os.Remove(utils.Paths(r.CommonDir, "objects", sha[:2])) //Only removed once it is empty
+	}
+	return len(shas), nil
+}
+
+// Loose objects are stored in objects/<first 2 chars of sha>/<remaining 38 chars>
+func (r *Repository) looseObjectShas() ([]string, error) {
+	objectsDir := utils.Path(r.CommonDir, "objects")
+	prefixDirs, err := os.ReadDir(objectsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	shas := make([]string, 0)
+	for _, prefixDir := range prefixDirs {
+		if !prefixDir.IsDir() || len(prefixDir.Name()) != 2 || !isHex(prefixDir.Name()) {
+			continue
+		}
+		files, err := os.ReadDir(utils.Path(objectsDir, prefixDir.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			if sha := prefixDir.Name() + file.Name(); len(sha) == 40 && isHex(sha) {
+				shas = append(shas, sha)
+			}
+		}
+	}
+	return shas, nil
+}
+
+// Same order as git: tags, commits from the newest one, then trees and blobs in the order they are found from those
+// commits, so the objects of recent commits are together and reachability bitmaps compress well. Objects that are not
+// found from the commits go at the end
+func getPackOrder(packObjects map[string]packfile.PackObject) []packfile.PackObject {
+	tags, commits := make([]string, 0), make([]string, 0)
+	commitTimes := make(map[string]int64)
+	for sha, packObject := range packObjects {
+		switch packObject.Type {
+		case objects.TAG:
+			tags = append(tags, sha)
+		case objects.COMMIT:
+			if parsed, err := objects.DeserializeObjectBody(objects.COMMIT, packObject.Data); err == nil {
+				commitTimes[sha] = parsed.SerializableGitObject.(objects.CommitObject).CommitTime()
+			}
+			commits = append(commits, sha)
+		}
+	}
+	sort.Strings(tags)
+	sort.Slice(commits, func(i, j int) bool {
+		if commitTimes[commits[i]] != commitTimes[commits[j]] {
+			return commitTimes[commits[i]] > commitTimes[commits[j]]
+		}
+		return commits[i] < commits[j]
+	})
+
+	order := make([]packfile.PackObject, 0, len(packObjects))
+	added := make(map[string]bool, len(packObjects))
+	add := func(sha string) bool {
+		packObject, found := packObjects[sha]
+		if !found || added[sha] {
+			return false
+		}
+		order = append(order, packObject)
+		added[sha] = true
+		return true
+	}
+
+	for _, sha := range append(tags, commits...) {
+		add(sha)
+	}
+	for _, sha := range commits {
+		if parsed, err := objects.DeserializeObjectBody(objects.COMMIT, packObjects[sha].Data); err == nil {
+			addTreeInPackOrder(parsed.SerializableGitObject.(objects.CommitObject).Tree, packObjects, add)
+		}
+	}
+
+	remaining := make([]string, 0)
+	for sha, _ := range packObjects {
+		if !added[sha] {
+			remaining = append(remaining, sha)
+		}
+	}
+	sort.Strings(remaining)
+	for _, sha := range remaining {
+		add(sha)
+	}
+	return order
+}
+
+// Subtrees already added are not walked again, since their content was added with them
+func addTreeInPackOrder(treeSha string, packObjects map[string]packfile.PackObject, add func(sha string) bool) {
+	if !add(treeSha) {
+		return
+	}
+	parsed, err := objects.DeserializeObjectBody(objects.TREE, packObjects[treeSha].Data)
+	if err != nil {
+		return
+	}
+
+	for _, entry := range parsed.SerializableGitObject.(objects.TreeObject).Entries {
+		if entry.IsDir() {
+			addTreeInPackOrder(entry.Sha, packObjects, add)
+		} else if !entry.IsGitlink() {
+			add(entry.Sha)
+		}
+	}
+}
+
+// The content is written to a lock file that is renamed, so readers never see a partial file
+func writeFileAtomically(path string, content []byte) error {
+	lockPath := path + ".lock"
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+	if err := os.WriteFile(lockPath, content, 0444); err != nil {
+		os.Remove(lockPath)
+		return err
+	}
+	if err := os.Rename(lockPath, path); err != nil {
+		os.Remove(lockPath)
+		return err
+	}
+	return nil
+}
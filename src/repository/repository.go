@@ -1,66 +1,100 @@
 package repository
 
 import (
-	"bytes"
+	"bufio"
 	"compress/zlib"
 	"crypto/sha1"
 	"encoding/hex"
 	"errors"
+	"git/src/commitgraph"
+	"git/src/config"
 	"git/src/ignore"
 	"git/src/index"
 	"git/src/objects"
 	"git/src/utils"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
-
-	"gopkg.in/ini.v1"
+	"sync"
 )
 
 type Repository struct {
-	WorkTree string
-	GitDir   string
-	Config   *ini.File
+	WorkTree  string
+	GitDir    string //HEAD and index. In linked work trees: <CommonDir>/worktrees/<name>
+	CommonDir string //objects, refs and config. Shared between all work trees
+	Config    *config.Config
+
+	filterProcesses  map[string]*filterProcess //Started filter.<driver>.process commands keyed by command
+	fetchingPromised bool                      //A missing object is being fetched from the promisor remote
+	shallowCommits   map[string]bool           //Content of the shallow file. Nil until it is read
+	commitGraph      *commitgraph.CommitGraph  //Nil if there is no usable commit graph
+	commitGraphRead  bool
+	packs            *packStore //Indexes of objects/pack. Nil until they are read
+	objectsDir       string     //<CommonDir>/objects. Empty until a loose object is read
 }
 
+// WriteObject Stores the object as a loose object unless it is already stored, loose or packed. It is compressed to a
+// temporary file that is renamed, so readers never see a partial object and concurrent writes of the same object are safe
 func (r *Repository) WriteObject(object *objects.Object) (string, error) {
 	serializeData := object.Serialize()
 	sha1Hasher := sha1.New()
 	sha1Hasher.Write(serializeData)
 	shaHex := hex.EncodeToString(sha1Hasher.Sum(nil))
 	prefix, remainder := shaHex[:2], shaHex[2:]
-	objectPath := utils.Paths(r.GitDir, "objects", prefix, remainder)
+	objectPath := utils.Paths(r.CommonDir, "objects", prefix, remainder)
 
+	if utils.CheckFileOrDirExists(objectPath) || r.hasPackedObject(shaHex) {
+		return shaHex, nil
+	}
 	if err := os.MkdirAll(filepath.Dir(objectPath), os.ModePerm); err != nil {
 		return "", err
 	}
 
-	file, err := os.Create(objectPath)
+	file, err := os.CreateTemp(filepath.Dir(objectPath), "tmp_obj_")
 	if err != nil {
 		return "", err
 	}
-	defer file.Close()
-
-	var compressedBuffer bytes.Buffer
-	zlibWriter := zlib.NewWriter(&compressedBuffer)
-	defer zlibWriter.Close()
-
-	if _, err = zlibWriter.Write(serializeData); err != nil {
+	if err := writeCompressed(file, serializeData); err != nil {
+		file.Close()
+		os.Remove(file.Name())
 		return "", err
 	}
-
-	zlibWriter.Flush()
-
-	if err := zlibWriter.Close(); err != nil {
+	if err := file.Close(); err != nil {
+		os.Remove(file.Name())
 		return "", err
 	}
-
-	if _, err = file.Write(compressedBuffer.Bytes()); err != nil {
+	if err := os.Chmod(file.Name(), 0444); err != nil {
+		os.Remove(file.Name())
 		return "", err
+	}
+	if err := os.Rename(file.Name(), objectPath); err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+
+	return shaHex, nil
+}
+
+var zlibWriters sync.Pool
+
+// The zlib writers are reused like the readers, since allocating their state for every object is slower than compressing
+// small objects
+func writeCompressed(writer io.Writer, data []byte) error {
+	zlibWriter, isPooled := zlibWriters.Get().(*zlib.Writer)
+	if isPooled {
+		zlibWriter.Reset(writer)
 	} else {
-		return shaHex, nil
+		zlibWriter = zlib.NewWriter(writer)
 	}
+	defer zlibWriters.Put(zlibWriter)
+
+	if _, err := zlibWriter.Write(data); err != nil {
+		return err
+	}
+	return zlibWriter.Close()
 }
 
 func (r *Repository) ReadTreeObject(hash string) (objects.TreeObject, error) {
@@ -81,13 +115,36 @@ func (r *Repository) ReadBlobObject(hash string) (objects.BlobObject, error) {
 	return gitObject.SerializableGitObject.(objects.BlobObject), nil
 }
 
+// ReadCommitObject Shallow commits are returned without parents, since they are not in the repository. Their serialization
+// is not changed
 func (r *Repository) ReadCommitObject(hash string) (objects.CommitObject, error) {
-	gitObject, err := r.ReadObject(hash, objects.COMMIT)
+	sha, gitObject, err := r.readCommitGitObject(hash)
 	if err != nil {
 		return objects.CommitObject{}, err
 	}
 
-	return gitObject.SerializableGitObject.(objects.CommitObject), nil
+	commit := gitObject.SerializableGitObject.(objects.CommitObject)
+	if r.IsShallowCommit(sha) {
+		commit.Parent, commit.Parents = objects.NO_PARENT_COMMIT_SHA, []string{}
+	}
+	return commit, nil
+}
+
+// Full shas of commits are read once, since history walks read a lot of them. Other names are resolved first
+// (Ex: branches or annotated tags)
+func (r *Repository) readCommitGitObject(hash string) (string, objects.Object, error) {
+	if len(hash) == 40 && isHex(hash) {
+		if gitObject, err := r.readObjectByResolvedName(hash); err == nil && gitObject.Type == objects.COMMIT {
+			return hash, gitObject, nil
+		}
+	}
+
+	sha, _, err := r.ResolveObjectName(hash, objects.COMMIT)
+	if err != nil {
+		return "", objects.Object{}, err
+	}
+	gitObject, err := r.readObjectByResolvedName(sha)
+	return sha, gitObject, err
 }
 
 func (r *Repository) ReadObject(unresolvedHash string, reqType objects.ObjectType) (objects.Object, error) {
@@ -98,27 +155,123 @@ func (r *Repository) ReadObject(unresolvedHash string, reqType objects.ObjectTyp
 	}
 }
 
-func (r *Repository) readObjectByResolvedName(resolvedHash string) (objects.Object, error) {
-	prefix, remainder := resolvedHash[:2], resolvedHash[2:]
-	objectPath := utils.Paths(r.GitDir, "objects", prefix, remainder)
-	objectFile, err := os.Open(objectPath)
+// ReadRawObject Reads an object by its full sha without parsing its body
+func (r *Repository) ReadRawObject(resolvedHash string) (objects.Object, error) {
+	if objectType, data, packed, err := r.readPackedObject(resolvedHash); packed || err != nil {
+		if err != nil {
+			return objects.Object{}, err
+		}
+		return *objects.CreateRawObject(objectType, data), nil
+	}
+
+	objectFile, err := r.openObjectFile(resolvedHash)
+	if err != nil {
+		return objects.Object{}, err
+	}
 	defer objectFile.Close()
+
+	objectFileZlibReader, err := newZlibReader(objectFile)
+	if err != nil {
+		return objects.Object{}, err
+	}
+	defer releaseZlibReader(objectFileZlibReader)
+
+	return objects.DeserializeRawObject(objectFileZlibReader)
+}
+
+var zlibReaders sync.Pool
+
+// zlibObjectReader Objects are small, so the zlib readers (and their 32KB windows) are reused instead of allocated for
+// every object. The object file is buffered by a reused reader too, otherwise zlib allocates a buffer for every file
+type zlibObjectReader struct {
+	zlibReader io.ReadCloser
+	buffered   *bufio.Reader
+}
+
+func newZlibReader(reader io.Reader) (*zlibObjectReader, error) {
+	pooled, isPooled := zlibReaders.Get().(*zlibObjectReader)
+	if !isPooled {
+		pooled = &zlibObjectReader{buffered: bufio.NewReader(nil)}
+	}
+	pooled.buffered.Reset(reader)
+
+	var err error
+	if pooled.zlibReader == nil {
+		pooled.zlibReader, err = zlib.NewReader(pooled.buffered)
+	} else {
+		err = pooled.zlibReader.(zlib.Resetter).Reset(pooled.buffered, nil)
+	}
 	if err != nil {
-		return objects.Object{}, errors.New("Cannot open object file: " + resolvedHash)
+		pooled.zlibReader = nil
+		return nil, err
+	}
+	return pooled, nil
+}
+
+func (z *zlibObjectReader) Read(p []byte) (int, error) {
+	return z.zlibReader.Read(p)
+}
+
+func releaseZlibReader(reader *zlibObjectReader) {
+	reader.zlibReader.Close()
+	reader.buffered.Reset(nil)
+	zlibReaders.Put(reader)
+}
+
+func (r *Repository) HasObject(resolvedHash string) bool {
+	if !utils.IsValidGitHash(resolvedHash) || len(resolvedHash) != 40 {
+		return false
+	}
+
+	return utils.CheckFileOrDirExists(utils.Paths(r.CommonDir, "objects", resolvedHash[:2], resolvedHash[2:])) ||
+		r.hasPackedObject(resolvedHash)
+}
+
+func (r *Repository) openObjectFile(resolvedHash string) (io.ReadCloser, error) {
+	if len(resolvedHash) < 3 {
+		return nil, errors.New("Invalid object name: " + resolvedHash)
+	}
+
+	//Joined by hand, cleaning the path of every object shows up when many loose objects are read
+	if r.objectsDir == "" {
+		r.objectsDir = utils.Paths(r.CommonDir, "objects")
+	}
+	separator := string(filepath.Separator)
+	objectPath := r.objectsDir + separator + resolvedHash[:2] + separator + resolvedHash[2:]
+	objectFile, err := openObjectFilePath(objectPath)
+	if os.IsNotExist(err) && len(resolvedHash) == 40 && r.IsPartialClone() {
+		if fetchErr := r.FetchPromisedObjects([]string{resolvedHash}); fetchErr != nil {
+			return nil, fetchErr
+		}
+		objectFile, err = openObjectFilePath(objectPath)
 	}
-	objectFileState, err := objectFile.Stat()
 	if err != nil {
-		return objects.Object{}, errors.New("Cannot get stat from object file: " + resolvedHash)
+		return nil, errors.New("Cannot open object file: " + resolvedHash)
 	}
-	if objectFileState.IsDir() {
-		return objects.Object{}, errors.New("Object file: " + resolvedHash + " cannot be a dir")
+
+	return objectFile, nil
+}
+
+// Packs are searched first, as most objects of big repositories are packed
+func (r *Repository) readObjectByResolvedName(resolvedHash string) (objects.Object, error) {
+	if objectType, data, packed, err := r.readPackedObject(resolvedHash); packed || err != nil {
+		if err != nil {
+			return objects.Object{}, err
+		}
+		return objects.DeserializeObjectBody(objectType, data)
+	}
+
+	objectFile, err := r.openObjectFile(resolvedHash)
+	if err != nil {
+		return objects.Object{}, err
 	}
+	defer objectFile.Close()
 
-	objectFileZlibReader, err := zlib.NewReader(objectFile)
+	objectFileZlibReader, err := newZlibReader(objectFile)
 	if err != nil {
 		return objects.Object{}, err
 	}
-	defer objectFileZlibReader.Close()
+	defer releaseZlibReader(objectFileZlibReader)
 
 	return objects.DeserializeObject(objectFileZlibReader)
 }
@@ -126,15 +279,25 @@ func (r *Repository) readObjectByResolvedName(resolvedHash string) (objects.Obje
 func (r *Repository) ReadIndex() (*index.IndexObject, error) {
 	utils.CreateFileIfNotExists(r.GitDir, "index")
 
-	if file, err := os.Open(utils.Path(r.GitDir, "index")); err == nil {
-		return index.Deserialize(file)
-	} else {
+	file, err := os.Open(utils.Path(r.GitDir, "index"))
+	if err != nil {
 		return nil, err
 	}
+	defer file.Close()
+
+	indexObject, err := index.Deserialize(file)
+	if err != nil || indexObject == nil {
+		return indexObject, err
+	}
+	if stat, err := file.Stat(); err == nil {
+		indexObject.ModTime = uint64(stat.ModTime().UnixNano())
+	}
+
+	return indexObject, nil
 }
 
 func (r *Repository) WriteIndex(index *index.IndexObject) error {
-	if err := os.Remove(utils.Paths(r.GitDir, "index")); err != nil {
+	if err := os.Remove(utils.Paths(r.GitDir, "index")); err != nil && !os.IsNotExist(err) {
 		return err
 	}
 
@@ -161,18 +324,26 @@ func (r *Repository) GetPathFileInRepository(path string) string {
 }
 
 func (r *Repository) WriteRef(reference objects.Reference) {
-	utils.CreateFileIfNotExistsWithContent(utils.Paths(r.GitDir, "refs"), reference.NamePath, reference.Value+"\n")
+	utils.Check(os.MkdirAll(filepath.Dir(utils.Paths(r.CommonDir, "refs", reference.NamePath)), os.ModePerm), "Cannot create ref "+reference.NamePath)
+	utils.CreateFileIfNotExistsWithContent(utils.Paths(r.CommonDir, "refs"), reference.NamePath, reference.Value+"\n")
 }
 
-func (r *Repository) IsIgnored(pathInRepository string) (bool, error) {
-	if strings.Contains(pathInRepository, ".git/") {
-		return true, nil
-	}
-
-	gitIgnores, err := r.readGitIgnores(pathInRepository)
+// IsIgnored path can be absolute or relative to the work tree
+func (r *Repository) IsIgnored(path string) (bool, error) {
+	gitIgnores, err := r.ReadGitIgnores()
 	if err != nil {
 		return false, err
 	}
+	return r.IsIgnoredBy(gitIgnores, path)
+}
+
+// IsIgnoredBy Same as IsIgnored with the .gitignore files already read, to check many paths without reading the index
+// for every one
+func (r *Repository) IsIgnoredBy(gitIgnores map[string]ignore.GitIgnore, path string) (bool, error) {
+	pathInRepository := r.AbsolutePathToRepositoryPath(path)
+	if pathInRepository == ".git" || strings.HasSuffix(pathInRepository, "/.git") || strings.Contains(pathInRepository, ".git/") {
+		return true, nil
+	}
 	if len(gitIgnores) == 0 {
 		return false, nil
 	}
@@ -181,7 +352,7 @@ func (r *Repository) IsIgnored(pathInRepository string) (bool, error) {
 
 	for {
 		if gitIgnore, gitIgnoreExists := gitIgnores[parent]; gitIgnoreExists {
-			matchesSomeIgnore, err := gitIgnore.IsIgnored(pathInRepository)
+			matchesSomeIgnore, err := gitIgnore.IsIgnored(strings.TrimPrefix(pathInRepository, parent+"/"))
 
 			if err != nil {
 				return false, err
@@ -190,7 +361,7 @@ func (r *Repository) IsIgnored(pathInRepository string) (bool, error) {
 				return true, nil
 			}
 		}
-		if parent == "" {
+		if parent == "" || parent == "." || parent == "/" {
 			break
 		}
 
@@ -200,7 +371,8 @@ func (r *Repository) IsIgnored(pathInRepository string) (bool, error) {
 	return false, nil
 }
 
-func (r *Repository) readGitIgnores(pathInRepository string) (map[string]ignore.GitIgnore, error) {
+// ReadGitIgnores The .gitignore files of the index keyed by their directory
+func (r *Repository) ReadGitIgnores() (map[string]ignore.GitIgnore, error) {
 	index, err := r.ReadIndex()
 	if err != nil {
 		return nil, err
@@ -210,11 +382,14 @@ func (r *Repository) readGitIgnores(pathInRepository string) (map[string]ignore.
 
 	for _, entry := range index.Entries {
 		if entry.FullPathName == ".gitignore" || strings.HasSuffix(entry.FullPathName, "/.gitignore") {
-			file, err := os.Open(entry.FullPathName)
-			if err != nil {
+			file, err := os.Open(utils.Path(r.WorkTree, entry.FullPathName))
+			if os.IsNotExist(err) {
+				continue
+			} else if err != nil {
 				return nil, err
 			}
 			gitIgnore, err := ignore.Deserialize(file)
+			file.Close()
 			if err != nil {
 				return nil, err
 			}
@@ -226,12 +401,21 @@ func (r *Repository) readGitIgnores(pathInRepository string) (map[string]ignore.
 	return gitIgnores, nil
 }
 
+// RefPath Refs under refs/ are shared between work trees. Other refs like HEAD belong to the current work tree
+func (r *Repository) RefPath(namePath string) string {
+	if strings.HasPrefix(namePath, "refs/") {
+		return utils.Path(r.CommonDir, namePath)
+	}
+
+	return utils.Path(r.GitDir, namePath)
+}
+
 func (r *Repository) ResolveRef(namePath string) (objects.Reference, error) {
 	return r.resolveRefRecursive(namePath)
 }
 
 func (r *Repository) resolveRefRecursive(namePath string) (objects.Reference, error) {
-	file, err := os.Open(utils.Path(r.GitDir, namePath))
+	file, err := os.Open(r.RefPath(namePath))
 	defer file.Close()
 	if err != nil {
 		return objects.Reference{}, NoCommits{}
@@ -251,12 +435,12 @@ func (r *Repository) resolveRefRecursive(namePath string) (objects.Reference, er
 		nextRefPath := strings.Split(stringRef, " ")[1]
 		return r.resolveRefRecursive(utils.SanitizePath(nextRefPath))
 	} else {
-		return objects.Reference{NamePath: namePath, Value: stringRef}, nil
+		return objects.Reference{NamePath: namePath, Value: utils.SanitizePath(stringRef)}, nil
 	}
 }
 
 func (r *Repository) GetAllRefs() (map[string]objects.Reference, error) {
-	refsPath := utils.Paths(r.GitDir, "/refs")
+	refsPath := utils.Paths(r.CommonDir, "/refs")
 	result := make(map[string]objects.Reference)
 
 	err := r.readRefsRecursive(result, refsPath)
@@ -274,8 +458,9 @@ func (r *Repository) readRefsRecursive(result map[string]objects.Reference, dirP
 		filePath := utils.Paths(dirPath, file.Name())
 
 		if !file.IsDir() {
-			if resolvedRef, err := r.ResolveRef(filePath); err != nil {
-				result[filePath] = resolvedRef
+			refName := utils.RemovePrefix(filePath, r.CommonDir+"/")
+			if resolvedRef, err := r.ResolveRef(refName); err == nil {
+				result[refName] = resolvedRef
 			}
 		} else {
 			r.readRefsRecursive(result, filePath)
@@ -285,7 +470,14 @@ func (r *Repository) readRefsRecursive(result map[string]objects.Reference, dirP
 	return nil
 }
 
+// ResolveObjectName Returns the sha of the object with the name (sha prefix, tag, branch, HEAD or <revision>:<path>) and if it is
+// a branch. Annotated tags are peeled until an object of reqObjectType is found. The suffix ^{<type>} peels to the type
+// and ^{} peels annotated tags to the tagged object
 func (r *Repository) ResolveObjectName(name string, reqObjectType objects.ObjectType) (string, bool, error) {
+	if strings.HasSuffix(name, "}") && strings.Contains(name, "^{") {
+		return r.resolvePeeledObjectName(name, reqObjectType)
+	}
+
 	candidatesHash, isHead, err := r.getCandidatesResolveObjectName(name)
 	if err != nil {
 		return "", false, err
@@ -308,7 +500,7 @@ func (r *Repository) ResolveObjectName(name string, reqObjectType objects.Object
 		}
 
 		if candidateObject.Type == objects.TAG {
-			candidateHash = candidateObject.SerializableGitObject.(objects.TagObject).ObjectTag
+			candidateHash = candidateObject.SerializableGitObject.(objects.TagObject).Object
 		} else if candidateObject.Type == objects.COMMIT && reqObjectType == objects.TREE {
 			candidateHash = candidateObject.SerializableGitObject.(objects.CommitObject).Tree
 		} else {
@@ -317,7 +509,39 @@ func (r *Repository) ResolveObjectName(name string, reqObjectType objects.Object
 	}
 }
 
+// Format: <name>^{<type>} or <name>^{}
+func (r *Repository) resolvePeeledObjectName(name string, reqObjectType objects.ObjectType) (string, bool, error) {
+	separator := strings.LastIndex(name, "^{")
+	baseName, peelType := name[:separator], objects.ObjectType(name[separator+2:len(name)-1])
+
+	if peelType != "" {
+		sha, _, err := r.ResolveObjectName(baseName, peelType)
+		if err != nil {
+			return "", false, err
+		}
+		return r.ResolveObjectName(sha, reqObjectType)
+	}
+
+	sha, _, err := r.ResolveObjectName(baseName, objects.ANY)
+	for err == nil {
+		object, readErr := r.readObjectByResolvedName(sha)
+		if readErr != nil {
+			return "", false, readErr
+		}
+		if object.Type != objects.TAG {
+			return r.ResolveObjectName(sha, reqObjectType)
+		}
+		sha = object.SerializableGitObject.(objects.TagObject).Object
+	}
+
+	return "", false, err
+}
+
 func (r *Repository) getCandidatesResolveObjectName(objectName string) ([]string, bool, error) {
+	if revision, path, isPath := strings.Cut(objectName, ":"); isPath {
+		sha, err := r.resolvePathInRevision(revision, path)
+		return []string{sha}, false, err
+	}
 	if strings.ToUpper(objectName) == "HEAD" {
 		headHash, err := r.ResolveRef(objectName)
 		return []string{headHash.Value}, true, err
@@ -325,10 +549,12 @@ func (r *Repository) getCandidatesResolveObjectName(objectName string) ([]string
 
 	candidatesHash := make([]string, 0)
 
-	if utils.IsValidGitHash(objectName) {
+	if len(objectName) == 40 && utils.IsValidGitHash(objectName) && r.HasObject(objectName) { //Avoids listing the directory
+		candidatesHash = append(candidatesHash, objectName)
+	} else if utils.IsValidGitHash(objectName) {
 		prefix := objectName[:2]
 		remaining := objectName[2:]
-		pathPrefix := utils.Paths(r.GitDir, "objects", prefix)
+		pathPrefix := utils.Paths(r.CommonDir, "objects", prefix)
 		if utils.CheckFileOrDirExists(pathPrefix) {
 			dirs, _ := os.ReadDir(pathPrefix)
 			for _, file := range dirs {
@@ -337,6 +563,16 @@ func (r *Repository) getCandidatesResolveObjectName(objectName string) ([]string
 				}
 			}
 		}
+		looseCandidates := len(candidatesHash)
+		for _, sha := range r.packedShasWithPrefix(objectName) {
+			if !containsString(candidatesHash[:looseCandidates], sha) { //Objects can be both loose and packed
+				candidatesHash = append(candidatesHash, sha)
+			}
+		}
+	}
+
+	if len(candidatesHash) == 0 && len(objectName) == 40 && utils.IsValidGitHash(objectName) && r.IsPartialClone() {
+		candidatesHash = append(candidatesHash, objectName) //Fetched from the promisor remote when it is read
 	}
 
 	candidateIsHead := false
@@ -358,8 +594,34 @@ func (r *Repository) getCandidatesResolveObjectName(objectName string) ([]string
 
 }
 
+// Format: <revision>:<path> is the file or directory in the tree of the revision. :<path> is the file in the index
+func (r *Repository) resolvePathInRevision(revision string, path string) (string, error) {
+	if revision == "" {
+		index, err := r.ReadIndex()
+		if err != nil {
+			return "", err
+		}
+		entry, found := index.Entries[path]
+		if !found {
+			return "", errors.New("path '" + path + "' does not exist in the index")
+		}
+		return entry.Sha, nil
+	}
+
+	treeSha, _, err := r.ResolveObjectName(revision, objects.TREE)
+	if err != nil {
+		return "", err
+	}
+	entry, err := r.FindTreeEntry(treeSha, path)
+	if err != nil {
+		return "", errors.New("path '" + path + "' does not exist in '" + revision + "'")
+	}
+
+	return entry.Sha, nil
+}
+
 func (r *Repository) WriteToHead(value string) error {
-	file, err := os.OpenFile(utils.Paths(r.GitDir, "HEAD"), os.O_WRONLY, 0777)
+	file, err := os.OpenFile(utils.Paths(r.GitDir, "HEAD"), os.O_WRONLY|os.O_TRUNC, 0777)
 	defer file.Close()
 	utils.CheckError(err)
 	_, err = file.Write([]byte(value))
@@ -386,67 +648,225 @@ func (r *Repository) GetActiveBranch() (_name string, _detached bool, _err error
 	}
 }
 
+const NOT_A_WORK_TREE_ERROR = "fatal: this operation must be run in a work tree"
+
+// IsBare Bare repositories dont have work tree. GitDir contains directly HEAD, objects/, refs/...
+func (r *Repository) IsBare() bool {
+	return r.WorkTree == ""
+}
+
+// RequireWorkTree Used by commands that read or write files of the work tree
+func (r *Repository) RequireWorkTree() error {
+	if r.IsBare() {
+		return errors.New(NOT_A_WORK_TREE_ERROR)
+	}
+
+	return nil
+}
+
+// FindCurrentRepository Returns the repository, and its work tree path (git dir path if the repository is bare).
+// GIT_DIR and GIT_WORK_TREE environment variables take precedence over discovery
 func FindCurrentRepository(currentPath string) (*Repository, string, error) {
+	if gitDirEnv := os.Getenv("GIT_DIR"); gitDirEnv != "" {
+		return findRepositoryFromGitDirEnv(currentPath, gitDirEnv)
+	}
+
 	paths := strings.Split(currentPath, string(filepath.Separator))
 
 	for i := 0; i < len(paths); i++ {
 		actualPath := "/" + utils.Paths(paths[:len(paths)-i]...)
 
-		if _, err := os.Open(utils.Path(actualPath, ".git")); err == nil {
-			return CreateRepositoryObject(actualPath), actualPath, nil
+		if utils.CheckFileOrDirExists(utils.Path(actualPath, ".git")) || isBareRepository(actualPath) {
+			currentRepository := CreateRepositoryObject(actualPath)
+			if workTreeEnv := os.Getenv("GIT_WORK_TREE"); workTreeEnv != "" {
+				currentRepository.WorkTree = absolutePath(currentPath, workTreeEnv)
+			}
+
+			return currentRepository, currentRepository.getRootPath(), nil
 		}
 	}
 
 	return nil, "", errors.New("fatal: not a git repository (or any of the parent directories): .git")
 }
 
+// Without GIT_WORK_TREE, the work tree is the current path unless the repository is bare
+func findRepositoryFromGitDirEnv(currentPath string, gitDirEnv string) (*Repository, string, error) {
+	gitDir := absolutePath(currentPath, gitDirEnv)
+	workTree := currentPath
+
+	if workTreeEnv := os.Getenv("GIT_WORK_TREE"); workTreeEnv != "" {
+		workTree = absolutePath(currentPath, workTreeEnv)
+	} else if isBareRepository(gitDir) {
+		workTree = ""
+	}
+
+	currentRepository, err := loadRepositoryFromGitDir(gitDir, workTree)
+	if err != nil {
+		return nil, "", errors.New("fatal: not a git repository: " + gitDirEnv)
+	}
+
+	return currentRepository, currentRepository.getRootPath(), nil
+}
+
+func (r *Repository) getRootPath() string {
+	if r.IsBare() {
+		return r.GitDir
+	}
+
+	return r.WorkTree
+}
+
+func absolutePath(currentPath string, path string) string {
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+
+	return utils.Path(currentPath, path)
+}
+
+// A directory with HEAD, objects/ and refs/ whose config has core.bare = true
+func isBareRepository(path string) bool {
+	if !utils.CheckFileOrDirExists(utils.Path(path, "HEAD")) || !utils.CheckFileOrDirExists(utils.Path(path, "objects")) ||
+		!utils.CheckFileOrDirExists(utils.Path(path, "refs")) {
+		return false
+	}
+
+	localConfig, err := config.LoadFile(utils.Path(path, "config"), config.LOCAL_SCOPE)
+	return err == nil && localConfig.MustBool("core.bare", false)
+}
+
 func CreateRepositoryObject(path string) *Repository {
-	workTree := path
-	gitDir := utils.Path(path, ".git")
+	repository, err := LoadRepository(path)
+	utils.CheckError(err)
 
-	gitPathFile, err := os.Open(gitDir)
-	defer gitPathFile.Close()
-	utils.Check(err, "Cannot open .git directory")
+	return repository
+}
 
-	gitPathFileStat, err := gitPathFile.Stat()
-	utils.Check(err, "Cannot call Stat of .git directory")
+// LoadRepository Same as CreateRepositoryObject but returns the error instead of exiting. Used by long-running commands like serve
+// path can be a work tree containing .git (directory or "gitdir: <path>" file of linked work trees) or a bare repository
+func LoadRepository(path string) (*Repository, error) {
+	gitDir := utils.Path(path, ".git")
 
+	gitPathFileStat, err := os.Stat(gitDir)
+	if err != nil && isBareRepository(path) {
+		return loadRepositoryFromGitDir(path, "")
+	}
+	if err != nil {
+		return nil, errors.New("Cannot open .git directory")
+	}
 	if !gitPathFileStat.IsDir() {
-		utils.ExitError(".git is not a directory")
+		if gitDir, err = readGitDirFile(gitDir); err != nil {
+			return nil, err
+		}
 	}
 
-	configFile, err := ini.Load(utils.Path(gitDir, "config"))
-	utils.Check(err, "Cannot open config ini file in .git")
+	return loadRepositoryFromGitDir(gitDir, path)
+}
 
-	version, err := configFile.Section("core").Key("repositoryformatversion").Int()
+// Format: gitdir: <path>. The path can be relative to the directory containing the .git file
+func readGitDirFile(gitFilePath string) (string, error) {
+	content, err := os.ReadFile(gitFilePath)
+	if err != nil {
+		return "", err
+	}
 
-	if err != nil || version != 0 {
-		utils.ExitError("Cannot get version in config file in .git")
+	contentString := utils.SanitizePath(string(content))
+	if !strings.HasPrefix(contentString, "gitdir: ") {
+		return "", errors.New("Invalid .git file format in " + gitFilePath)
 	}
 
-	return &Repository{
-		WorkTree: workTree,
-		GitDir:   gitDir,
-		Config:   configFile,
+	return absolutePath(filepath.Dir(gitFilePath), strings.TrimPrefix(contentString, "gitdir: ")), nil
+}
+
+// Linked work trees git dirs contain a commondir file pointing to the shared git dir
+func loadRepositoryFromGitDir(gitDir string, workTree string) (*Repository, error) {
+	commonDir := gitDir
+	if commonDirContent, err := os.ReadFile(utils.Path(gitDir, "commondir")); err == nil {
+		commonDir = absolutePath(gitDir, utils.SanitizePath(string(commonDirContent)))
 	}
+
+	repositoryConfig, err := loadConfig(gitDir, commonDir)
+	if err != nil {
+		return nil, errors.New("Cannot read config of " + commonDir + ": " + err.Error())
+	}
+
+	//Version 1 only adds extensions, like extensions.worktreeConfig
+	version, err := repositoryConfig.Int("core.repositoryformatversion", -1)
+	if err != nil || (version != 0 && version != 1) {
+		return nil, errors.New("Cannot get version in config file in " + commonDir)
+	}
+
+	return &Repository{
+		WorkTree:  workTree,
+		GitDir:    gitDir,
+		CommonDir: commonDir,
+		Config:    repositoryConfig,
+	}, nil
 }
 
-func InitializeRepository(workTreePath string) *Repository {
-	gitDir := utils.Path(workTreePath, ".git")
+// System, global, local and worktree config files, following includes
+func loadConfig(gitDir string, commonDir string) (*config.Config, error) {
+	return config.Load(config.RepositorySources(gitDir, commonDir), true, config.IncludeContext{GitDir: gitDir, CommonDir: commonDir})
+}
 
-	workDirFile, err := os.Open(workTreePath)
-	utils.Check(err, "Cannot open "+workTreePath)
+// InitializeRepository If bare, path will be the git dir. Otherwise, the git dir will be path/.git
+func InitializeRepository(path string, bare bool) *Repository {
+	workTreePath := path
+	gitDir := utils.Path(path, ".git")
+	if bare {
+		workTreePath = ""
+		gitDir = path
+	}
+
+	workDirFile, err := os.Open(path)
+	utils.Check(err, "Cannot open "+path)
 	defer workDirFile.Close()
 
 	stat, err := workDirFile.Stat()
-	utils.Check(err, "Cannot get stat from "+workTreePath)
+	utils.Check(err, "Cannot get stat from "+path)
 
 	if !stat.IsDir() {
-		utils.ExitError(workTreePath + "is not a directory")
+		utils.ExitError(path + "is not a directory")
+	}
+
+	if !bare {
+		utils.CreateDirIfNotExists(workTreePath, ".git")
 	}
+	initializeGitDir(gitDir, bare)
+
+	repositoryConfig, err := loadConfig(gitDir, gitDir)
+	utils.CheckError(err)
+
+	return &Repository{
+		WorkTree:  workTreePath,
+		GitDir:    gitDir,
+		CommonDir: gitDir,
+		Config:    repositoryConfig,
+	}
+}
 
-	utils.CreateDirIfNotExists(workTreePath, ".git")
+// InitializeRepositoryWithSeparateGitDir The work tree will contain a .git file pointing to gitDir.
+// Used by submodules, whose git dir is <superproject git dir>/modules/<name>
+func InitializeRepositoryWithSeparateGitDir(workTreePath string, gitDir string) *Repository {
+	utils.Check(os.MkdirAll(gitDir, os.ModePerm), "Cannot create "+gitDir)
+	utils.Check(os.MkdirAll(workTreePath, os.ModePerm), "Cannot create "+workTreePath)
+	utils.CreateFileIfNotExistsWithContent(workTreePath, ".git", "gitdir: "+gitDir+"\n")
+	initializeGitDir(gitDir, false)
+
+	repositoryConfig, err := loadConfig(gitDir, gitDir)
+	utils.CheckError(err)
+
+	return &Repository{
+		WorkTree:  workTreePath,
+		GitDir:    gitDir,
+		CommonDir: gitDir,
+		Config:    repositoryConfig,
+	}
+}
+
+func initializeGitDir(gitDir string, bare bool) {
 	utils.CreateDirIfNotExists(gitDir, "branches")
+	utils.CreateDirIfNotExists(gitDir, "objects")
 	utils.CreateDirIfNotExists(gitDir, "refs")
 	utils.CreateDirIfNotExists(utils.Path(gitDir, "refs"), "heads")
 	utils.CreateDirIfNotExists(utils.Path(gitDir, "refs"), "tags")
@@ -455,26 +875,25 @@ func InitializeRepository(workTreePath string) *Repository {
 	utils.CreateFileIfNotExistsWithContent(gitDir, "description", "Unnamed repository; edit this file 'description' to name the repository.\n")
 	utils.CreateFileIfNotExistsWithContent(gitDir, "HEAD", "ref: refs/heads/master\n")
 
-	utils.CreateFileIfNotExists(gitDir, "config")
-	config, err := ini.Load(utils.Path(gitDir, "config"))
-	utils.Check(err, "Cannot open config in .git")
-
-	addDefaultConfigToIniFile(config)
+	writeDefaultConfig(utils.Path(gitDir, "config"), bare)
+}
 
-	return &Repository{
-		WorkTree: workTreePath,
-		GitDir:   gitDir,
-		Config:   config,
-	}
+// FileModeEnabled core.filemode If false, the executable bit of the files in the work tree is ignored
+func (r *Repository) FileModeEnabled() bool {
+	return r.Config.MustBool("core.filemode", true)
 }
 
-func addDefaultConfigToIniFile(iniFile *ini.File) {
-	section, err := iniFile.NewSection("core")
-	utils.Check(err, "Cannot create core section in ini file")
+// SymlinksEnabled core.symlinks If false, symlinks are checked out as plain files containing the target
+func (r *Repository) SymlinksEnabled() bool {
+	return r.Config.MustBool("core.symlinks", true)
+}
 
-	section.NewKey("repositoryformatversion", "0")
-	section.NewKey("filemode", "fals")
-	section.NewKey("bare", "false")
+func writeDefaultConfig(configPath string, bare bool) {
+	configFile, err := config.ReadFile(configPath)
+	utils.Check(err, "Cannot open config in "+configPath)
 
-	iniFile.SaveTo("./.git/config")
+	utils.CheckError(configFile.Set("core.repositoryformatversion", "0", nil, false))
+	utils.CheckError(configFile.Set("core.filemode", "true", nil, false))
+	utils.CheckError(configFile.Set("core.bare", strconv.FormatBool(bare), nil, false))
+	utils.CheckError(configFile.Save())
 }
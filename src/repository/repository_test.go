@@ -0,0 +1,630 @@
+package repository
+
+import (
+	"git/src/config"
+	"git/src/index"
+	"git/src/lfs"
+	"git/src/objects"
+	"git/src/sparse"
+	"git/src/utils"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepository_FindBareRepository(t *testing.T) {
+	barePath := utils.Path(t.TempDir(), "repo.git")
+	assert.Nil(t, os.Mkdir(barePath, os.ModePerm))
+	InitializeRepository(barePath, true)
+	assert.Nil(t, os.Mkdir(utils.Path(barePath, "hooks"), os.ModePerm))
+
+	currentRepository, rootPath, err := FindCurrentRepository(utils.Path(barePath, "hooks"))
+
+	assert.Nil(t, err)
+	assert.Equal(t, barePath, rootPath)
+	assert.Equal(t, barePath, currentRepository.GitDir)
+	assert.True(t, currentRepository.IsBare())
+	assert.EqualError(t, currentRepository.RequireWorkTree(), NOT_A_WORK_TREE_ERROR)
+}
+
+func TestRepository_FindRepositoryWithGitDirAndWorkTreeEnv(t *testing.T) {
+	repositoryPath := t.TempDir()
+	otherPath := t.TempDir()
+	InitializeRepository(repositoryPath, false)
+
+	t.Setenv("GIT_DIR", utils.Path(repositoryPath, ".git"))
+	currentRepository, rootPath, err := FindCurrentRepository(otherPath)
+
+	assert.Nil(t, err)
+	assert.Equal(t, otherPath, rootPath)
+	assert.Equal(t, utils.Path(repositoryPath, ".git"), currentRepository.GitDir)
+	assert.Nil(t, currentRepository.RequireWorkTree())
+
+	t.Setenv("GIT_WORK_TREE", repositoryPath)
+	currentRepository, _, err = FindCurrentRepository(otherPath)
+
+	assert.Nil(t, err)
+	assert.Equal(t, repositoryPath, currentRepository.WorkTree)
+}
+
+func TestRepository_LinkedWorktree(t *testing.T) {
+	mainPath := t.TempDir()
+	worktreePath := utils.Path(t.TempDir(), "feature")
+	mainRepository := InitializeRepository(mainPath, false)
+
+	worktreeRepository, err := mainRepository.AddWorktree(worktreePath, "ref: refs/heads/feature")
+
+	assert.Nil(t, err)
+	assert.Equal(t, utils.Paths(mainPath, ".git", "worktrees", "feature"), worktreeRepository.GitDir)
+	assert.Equal(t, utils.Path(mainPath, ".git"), worktreeRepository.CommonDir)
+
+	foundRepository, rootPath, err := FindCurrentRepository(worktreePath)
+	assert.Nil(t, err)
+	assert.Equal(t, worktreePath, rootPath)
+	assert.Equal(t, worktreeRepository.GitDir, foundRepository.GitDir)
+	assert.Equal(t, utils.Paths(mainPath, ".git", "refs", "heads", "master"), foundRepository.RefPath("refs/heads/master"))
+	assert.Equal(t, utils.Path(worktreeRepository.GitDir, "HEAD"), foundRepository.RefPath("HEAD"))
+
+	worktree, checkedOut := mainRepository.FindWorktreeWithBranch("feature")
+	assert.True(t, checkedOut)
+	assert.Equal(t, worktreePath, worktree.Path)
+
+	assert.Nil(t, os.RemoveAll(worktreePath))
+	pruned, err := mainRepository.PruneWorktrees()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"feature"}, pruned)
+
+	worktrees, err := mainRepository.ListWorktrees()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(worktrees))
+	assert.True(t, worktrees[0].Main)
+}
+
+func TestRepository_IsWorkTreeFileModified(t *testing.T) {
+	repositoryPath := t.TempDir()
+	currentRepository := InitializeRepository(repositoryPath, false)
+	filePath := utils.Path(repositoryPath, "a.txt")
+	assert.Nil(t, os.WriteFile(filePath, []byte("hello"), 0666))
+
+	stat, err := os.Lstat(filePath)
+	assert.Nil(t, err)
+	sha, err := currentRepository.HashWorkTreeFile(filePath, stat)
+	assert.Nil(t, err)
+	assert.Equal(t, "b6fc4c620b67d95f953a5c1c1230aaab5db5a1b0", sha)
+
+	entry := index.CreateIndexEntry(stat, "a.txt", sha)
+	entry.Ino = entry.Ino + 1
+	indexObject := &index.IndexObject{Entries: map[string]index.IndexEntry{"a.txt": entry}, ModTime: entry.Mtime + 1}
+
+	modified, err := currentRepository.IsWorkTreeFileModified(indexObject, entry)
+	assert.Nil(t, err)
+	assert.False(t, modified)
+	racyEntry := indexObject.Entries["a.txt"]
+	assert.True(t, racyEntry.MatchesStat(stat))
+
+	indexObject.ModTime = racyEntry.Mtime
+	assert.Nil(t, os.WriteFile(filePath, []byte("world"), 0666))
+	assert.Nil(t, os.Chtimes(filePath, stat.ModTime(), stat.ModTime()))
+
+	modified, err = currentRepository.IsWorkTreeFileModified(indexObject, racyEntry)
+	assert.Nil(t, err)
+	assert.True(t, modified)
+
+	assert.Nil(t, os.Remove(filePath))
+	modified, err = currentRepository.IsWorkTreeFileModified(indexObject, racyEntry)
+	assert.Nil(t, err)
+	assert.True(t, modified)
+
+	racyEntry.SkipWorktree = true
+	modified, err = currentRepository.IsWorkTreeFileModified(indexObject, racyEntry)
+	assert.Nil(t, err)
+	assert.False(t, modified)
+}
+
+func TestRepository_SparseCheckoutConfig(t *testing.T) {
+	mainPath := t.TempDir()
+	mainRepository := InitializeRepository(mainPath, false)
+	worktreeRepository, err := mainRepository.AddWorktree(utils.Path(t.TempDir(), "feature"), "ref: refs/heads/feature")
+	assert.Nil(t, err)
+
+	patterns, err := worktreeRepository.ReadSparsePatterns()
+	assert.Nil(t, err)
+	assert.Nil(t, patterns)
+
+	assert.Nil(t, worktreeRepository.SetSparseCheckoutConfig(true, true))
+	assert.Nil(t, worktreeRepository.WriteSparsePatterns(sparse.ConePatterns([]string{"src"})))
+	assert.True(t, worktreeRepository.Config.MustBool("extensions.worktreeConfig", false))
+	patterns, err = worktreeRepository.ReadSparsePatterns()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"src"}, patterns.Dirs())
+	assert.False(t, patterns.Matches("docs/a.md"))
+
+	mainRepository, err = LoadRepository(mainPath)
+	assert.Nil(t, err)
+	patterns, err = mainRepository.ReadSparsePatterns()
+	assert.Nil(t, err)
+	assert.Nil(t, patterns)
+
+	assert.Nil(t, worktreeRepository.SetSparseCheckoutConfig(false, false))
+	patterns, err = worktreeRepository.ReadSparsePatterns()
+	assert.Nil(t, err)
+	assert.Nil(t, patterns)
+}
+
+func TestRepository_ReadWorkTreeFileBlob(t *testing.T) {
+	repositoryPath := t.TempDir()
+	currentRepository := InitializeRepository(repositoryPath, false)
+	assert.Nil(t, os.MkdirAll(utils.Path(repositoryPath, "sub"), os.ModePerm))
+	assert.Nil(t, os.WriteFile(utils.Path(repositoryPath, ".gitattributes"), []byte("*.txt text\n"), 0666))
+	assert.Nil(t, os.WriteFile(utils.Paths(repositoryPath, "sub", ".gitattributes"), []byte("*.txt -text\n"), 0666))
+	assert.Nil(t, os.WriteFile(utils.Path(repositoryPath, "a.txt"), []byte("a\r\n"), 0666))
+	assert.Nil(t, os.WriteFile(utils.Paths(repositoryPath, "sub", "b.txt"), []byte("b\r\n"), 0666))
+	assert.Nil(t, os.WriteFile(utils.Path(repositoryPath, "c.md"), []byte("c\r\n"), 0666))
+
+	readBlob := func(path string) string {
+		fullPath := utils.Path(repositoryPath, path)
+		stat, err := os.Lstat(fullPath)
+		assert.Nil(t, err)
+		content, err := currentRepository.ReadWorkTreeFileBlob(fullPath, stat)
+		assert.Nil(t, err)
+		return string(content)
+	}
+	assert.Equal(t, "a\n", readBlob("a.txt"))
+	assert.Equal(t, "b\r\n", readBlob("sub/b.txt"))
+	assert.Equal(t, "c\r\n", readBlob("c.md"))
+
+	assert.Nil(t, currentRepository.Config.Set(config.LOCAL_SCOPE, "core.autocrlf", "true"))
+	assert.Equal(t, "c\n", readBlob("c.md"))
+
+	repositoryAttributes, err := currentRepository.ReadAttributes()
+	assert.Nil(t, err)
+	content, err := currentRepository.ConvertToWorkTree(repositoryAttributes, "c.md", []byte("c\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, "c\r\n", string(content))
+}
+
+func TestRepository_Filters(t *testing.T) {
+	repositoryPath := t.TempDir()
+	currentRepository := InitializeRepository(repositoryPath, false)
+	assert.Nil(t, os.WriteFile(utils.Path(repositoryPath, ".gitattributes"), []byte("*.rot filter=rot\n*.bin filter=lfs -text\n"), 0666))
+	assert.Nil(t, currentRepository.Config.Set(config.LOCAL_SCOPE, "filter.rot.clean", "tr a-z n-za-m"))
+	assert.Nil(t, currentRepository.Config.Set(config.LOCAL_SCOPE, "filter.rot.smudge", "tr a-z n-za-m"))
+	repositoryAttributes, err := currentRepository.ReadAttributes()
+	assert.Nil(t, err)
+
+	content, err := currentRepository.ConvertToGit(repositoryAttributes, "a.rot", []byte("hello\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, "uryyb\n", string(content))
+	content, err = currentRepository.ConvertToWorkTree(repositoryAttributes, "a.rot", content)
+	assert.Nil(t, err)
+	assert.Equal(t, "hello\n", string(content))
+
+	pointer, err := currentRepository.ConvertToGit(repositoryAttributes, "a.bin", []byte("large\r\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, string(lfs.CreatePointer([]byte("large\r\n")).Serialize()), string(pointer))
+	content, err = currentRepository.ConvertToWorkTree(repositoryAttributes, "a.bin", pointer)
+	assert.Nil(t, err)
+	assert.Equal(t, "large\r\n", string(content))
+
+	assert.Nil(t, os.RemoveAll(currentRepository.LfsStore().Dir))
+	content, err = currentRepository.ConvertToWorkTree(repositoryAttributes, "a.bin", pointer)
+	assert.Nil(t, err)
+	assert.Equal(t, pointer, content)
+
+	assert.Nil(t, currentRepository.Config.Set(config.LOCAL_SCOPE, "filter.rot.clean", "false"))
+	assert.Nil(t, currentRepository.Config.Set(config.LOCAL_SCOPE, "filter.rot.required", "true"))
+	_, err = currentRepository.ConvertToGit(repositoryAttributes, "a.rot", []byte("hello\n"))
+	assert.NotNil(t, err)
+}
+
+func TestRepository_WriteWorkTreeFileBlobs(t *testing.T) {
+	repositoryPath := t.TempDir()
+	currentRepository := InitializeRepository(repositoryPath, false)
+	assert.Nil(t, os.WriteFile(utils.Path(repositoryPath, ".gitattributes"), []byte("*.rot filter=rot\n*.txt text\n"), 0666))
+	assert.Nil(t, currentRepository.Config.Set(config.LOCAL_SCOPE, "filter.rot.clean", "tr a-z n-za-m"))
+	defaultWriters := objectWriters
+	objectWriters = 4
+	defer func() { objectWriters = defaultWriters }()
+
+	contents := map[string]string{"a.txt": "same\r\n", "b.txt": "same\n", "c.rot": "hello\n", "d.md": "d\r\n", "e.md": "e"}
+	files, expectedShas := make([]WorkTreeFile, 0), make([]string, 0)
+	for _, name := range []string{"a.txt", "b.txt", "c.rot", "d.md", "e.md"} {
+		path := utils.Path(repositoryPath, name)
+		assert.Nil(t, os.WriteFile(path, []byte(contents[name]), 0666))
+		stat, err := os.Lstat(path)
+		assert.Nil(t, err)
+		files = append(files, WorkTreeFile{Path: path, Stat: stat})
+		expectedSha, err := currentRepository.HashWorkTreeFile(path, stat)
+		assert.Nil(t, err)
+		expectedShas = append(expectedShas, expectedSha)
+	}
+
+	shas, err := currentRepository.WriteWorkTreeFileBlobs(files)
+	assert.Nil(t, err)
+	assert.Equal(t, expectedShas, shas)
+	assert.Equal(t, shas[0], shas[1])
+	blob, err := currentRepository.ReadObject(shas[2], objects.BLOB)
+	assert.Nil(t, err)
+	assert.Equal(t, "uryyb\n", string(blob.SerializableGitObject.Serialize()))
+
+	shas, err = currentRepository.WriteWorkTreeFileBlobs(files) //Already stored
+	assert.Nil(t, err)
+	assert.Equal(t, expectedShas, shas)
+	looseShas, err := currentRepository.looseObjectShas()
+	assert.Nil(t, err)
+	assert.Len(t, looseShas, 4)
+
+	assert.Nil(t, os.Remove(files[3].Path))
+	_, err = currentRepository.WriteWorkTreeFileBlobs(files)
+	assert.NotNil(t, err)
+}
+
+func TestRepository_RevList(t *testing.T) {
+	currentRepository := InitializeRepository(t.TempDir(), false)
+	writeCommit := func(date int, parents ...string) string {
+		signature := "Jaime <j@j.com> " + strconv.Itoa(date) + " +0000"
+		commit := objects.CreateCommitObjectWithParents("4b825dc642cb6eb9a060e54bf8d69288fbee4904", parents, signature, signature, "commit")
+		sha, err := currentRepository.WriteObject(commit)
+		assert.Nil(t, err)
+		return sha
+	}
+
+	root := writeCommit(1)
+	main1 := writeCommit(2, root)
+	feature := writeCommit(3, root)
+	main2 := writeCommit(4, main1)
+	merge := writeCommit(5, main2, feature)
+
+	commits, err := currentRepository.RevList(RevListOptions{Include: []string{merge}})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{merge, main2, feature, main1, root}, revCommitShas(commits))
+
+	commits, err = currentRepository.RevList(RevListOptions{Include: []string{merge}, TopoOrder: true})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{merge, feature, main2, main1, root}, revCommitShas(commits))
+
+	commits, err = currentRepository.RevList(RevListOptions{Include: []string{merge}, Exclude: []string{main1}})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{merge, main2, feature}, revCommitShas(commits))
+}
+
+func TestRepository_MergeBases(t *testing.T) {
+	currentRepository := InitializeRepository(t.TempDir(), false)
+	writeCommit := func(date int, parents ...string) string {
+		signature := "Jaime <j@j.com> " + strconv.Itoa(date) + " +0000"
+		commit := objects.CreateCommitObjectWithParents("4b825dc642cb6eb9a060e54bf8d69288fbee4904", parents, signature, signature, "commit")
+		sha, err := currentRepository.WriteObject(commit)
+		assert.Nil(t, err)
+		return sha
+	}
+
+	root := writeCommit(1)
+	left := writeCommit(2, root)
+	right := writeCommit(3, root)
+	leftMerge := writeCommit(4, left, right) //Criss-cross merge: both sides merge the other
+	rightMerge := writeCommit(5, right, left)
+	leftTip := writeCommit(6, leftMerge)
+	unrelated := writeCommit(7)
+	currentRepository.WriteRef(objects.Reference{NamePath: "heads/master", Value: leftTip})
+	currentRepository.WriteRef(objects.Reference{NamePath: "heads/other", Value: rightMerge})
+
+	assertMergeBases := func() {
+		mergeBases, err := currentRepository.MergeBases(leftTip, rightMerge)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{right, left}, mergeBases)
+		mergeBases, err = currentRepository.MergeBases(leftTip, left)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{left}, mergeBases)
+		mergeBases, err = currentRepository.MergeBases(leftTip, unrelated)
+		assert.Nil(t, err)
+		assert.Empty(t, mergeBases)
+
+		isAncestor, err := currentRepository.IsAncestor(root, leftTip)
+		assert.Nil(t, err)
+		assert.True(t, isAncestor)
+		isAncestor, err = currentRepository.IsAncestor(rightMerge, leftTip)
+		assert.Nil(t, err)
+		assert.False(t, isAncestor)
+	}
+	assertMergeBases()
+
+	_, err := currentRepository.WriteCommitGraph()
+	assert.Nil(t, err)
+	currentRepository, err = LoadRepository(currentRepository.WorkTree)
+	assert.Nil(t, err)
+	assert.NotNil(t, currentRepository.readCommitGraph())
+	assertMergeBases()
+}
+
+func TestRepository_CommitGraph(t *testing.T) {
+	currentRepository := InitializeRepository(t.TempDir(), false)
+	writeCommit := func(date int, parents ...string) string {
+		signature := "Jaime <j@j.com> " + strconv.Itoa(date) + " +0000"
+		commit := objects.CreateCommitObjectWithParents("4b825dc642cb6eb9a060e54bf8d69288fbee4904", parents, signature, signature, "commit")
+		sha, err := currentRepository.WriteObject(commit)
+		assert.Nil(t, err)
+		return sha
+	}
+
+	root := writeCommit(1)
+	main1 := writeCommit(2, root)
+	feature := writeCommit(3, root)
+	merge := writeCommit(4, main1, feature)
+	currentRepository.WriteRef(objects.Reference{NamePath: "heads/master", Value: merge})
+
+	count, err := currentRepository.WriteCommitGraph()
+	assert.Nil(t, err)
+	assert.Equal(t, 4, count)
+	assert.Nil(t, currentRepository.VerifyCommitGraph())
+
+	node, err := currentRepository.ReadCommitNode(merge)
+	assert.Nil(t, err)
+	assert.Equal(t, CommitNode{Parents: []string{main1, feature}, CommitTime: 4, Generation: 3}, node)
+
+	after := writeCommit(5, merge) //Not in the graph, read from its object
+	commits, err := currentRepository.RevList(RevListOptions{Include: []string{after}, Exclude: []string{main1}})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{after, merge, feature}, revCommitShas(commits))
+	assert.Equal(t, "commit", commits[1].Commit.Message)
+}
+
+// Lists a linear history of 100k loose commits, like log --oneline without a commit graph. It should take less than 1s
+func BenchmarkRepository_RevListLooseCommits(b *testing.B) {
+	currentRepository := InitializeRepository(b.TempDir(), false)
+	parents := make([]string, 0)
+	for i := 1; i <= 100000; i++ {
+		signature := "Jaime <j@j.com> " + strconv.Itoa(i) + " +0000"
+		commit := objects.CreateCommitObjectWithParents("4b825dc642cb6eb9a060e54bf8d69288fbee4904", parents, signature, signature, "commit "+strconv.Itoa(i))
+		sha, err := currentRepository.WriteObject(commit)
+		assert.Nil(b, err)
+		parents = []string{sha}
+	}
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		commits, err := currentRepository.RevList(RevListOptions{Include: parents})
+		assert.Nil(b, err)
+		assert.Equal(b, 100000, len(commits))
+	}
+}
+
+func revCommitShas(commits []RevCommit) []string {
+	shas := make([]string, 0, len(commits))
+	for _, commit := range commits {
+		shas = append(shas, commit.Sha)
+	}
+	return shas
+}
+
+func TestRepository_RepackAndMultiPackIndex(t *testing.T) {
+	currentRepository := InitializeRepository(t.TempDir(), false)
+	writeFileCommit := func(parent string, content string, date int) string {
+		blobSha, err := currentRepository.WriteObject(objects.CreateBlobObject([]byte(content)))
+		assert.Nil(t, err)
+		treeSha, err := currentRepository.WriteObject(&objects.Object{Type: objects.TREE,
+			SerializableGitObject: objects.TreeObject{Entries: []objects.TreeEntry{{Mode: objects.TREE_MODE_FILE, Sha: blobSha, Path: "a.txt"}}}})
+		assert.Nil(t, err)
+		signature := "Jaime <j@j.com> " + strconv.Itoa(date) + " +0000"
+		sha, err := currentRepository.WriteObject(objects.CreateCommitObject(treeSha, parent, signature, signature, "commit"))
+		assert.Nil(t, err)
+		return sha
+	}
+
+	first := writeFileCommit(objects.NO_PARENT_COMMIT_SHA, "1", 1)
+	second := writeFileCommit(first, "2", 2)
+	currentRepository.WriteRef(objects.Reference{NamePath: "heads/master", Value: second})
+
+	packed, err := currentRepository.RepackLooseObjects()
+	assert.Nil(t, err)
+	assert.Equal(t, 6, packed)
+	shas, err := currentRepository.looseObjectShas()
+	assert.Nil(t, err)
+	assert.Empty(t, shas)
+	commit, err := currentRepository.ReadCommitObject(second[:7])
+	assert.Nil(t, err)
+	assert.Equal(t, []string{first}, commit.Parents)
+
+	third := writeFileCommit(second, "3", 3) //Loose, not in the bitmaps
+	count, err := currentRepository.WriteMultiPackIndex(true)
+	assert.Nil(t, err)
+	assert.Equal(t, 6, count)
+	assert.Nil(t, currentRepository.VerifyMultiPackIndex())
+	assert.True(t, currentRepository.HasObject(first))
+	assert.True(t, currentRepository.HasObject(third))
+
+	reachable, found, err := currentRepository.ReachableObjectsWithBitmaps([]string{second}, []string{first})
+	assert.Nil(t, err)
+	assert.True(t, found)
+	secondCommit, _ := currentRepository.ReadCommitObject(second)
+	secondTree, _ := currentRepository.ReadTreeObject(secondCommit.Tree)
+	assert.ElementsMatch(t, []string{second, secondCommit.Tree, secondTree.Entries[0].Sha}, reachable)
+
+	_, found, err = currentRepository.ReachableObjectsWithBitmaps([]string{third}, []string{})
+	assert.Nil(t, err)
+	assert.False(t, found)
+}
+
+func TestRepository_Blame(t *testing.T) {
+	currentRepository := InitializeRepository(t.TempDir(), false)
+	writeFileCommit := func(parent string, path string, content string, date int) string {
+		blobSha, err := currentRepository.WriteObject(objects.CreateBlobObject([]byte(content)))
+		assert.Nil(t, err)
+		treeSha, err := currentRepository.WriteObject(&objects.Object{Type: objects.TREE,
+			SerializableGitObject: objects.TreeObject{Entries: []objects.TreeEntry{{Mode: objects.TREE_MODE_FILE, Sha: blobSha, Path: path}}}})
+		assert.Nil(t, err)
+		signature := "Jaime <j@j.com> " + strconv.Itoa(date) + " +0000"
+		sha, err := currentRepository.WriteObject(objects.CreateCommitObject(treeSha, parent, signature, signature, "commit"))
+		assert.Nil(t, err)
+		return sha
+	}
+
+	first := writeFileCommit(objects.NO_PARENT_COMMIT_SHA, "a.txt", "1\n2\n3\n", 1)
+	second := writeFileCommit(first, "a.txt", "1\ntwo\n3\n", 2)
+	renamed := writeFileCommit(second, "b.txt", "1\ntwo\n3\n4\n", 3)
+
+	lines, err := currentRepository.Blame(renamed, "b.txt", []byte("1\ntwo\n3\n4\n5\n"))
+
+	assert.Nil(t, err)
+	assert.Equal(t, 5, len(lines))
+	assert.Equal(t, []string{first, second, first, renamed, "0000000000000000000000000000000000000000"},
+		[]string{lines[0].Sha, lines[1].Sha, lines[2].Sha, lines[3].Sha, lines[4].Sha})
+	assert.Equal(t, "a.txt", lines[1].Path)
+	assert.Equal(t, "b.txt", lines[3].Path)
+	assert.True(t, lines[0].Boundary)
+	assert.Equal(t, "3", lines[2].Content)
+	assert.Equal(t, 3, lines[2].OriginalLine)
+}
+
+func TestRepository_ResolveObjectNameWithPath(t *testing.T) {
+	currentRepository := InitializeRepository(t.TempDir(), false)
+	blobSha, err := currentRepository.WriteObject(objects.CreateBlobObject([]byte("content")))
+	assert.Nil(t, err)
+	dirSha, err := currentRepository.WriteObject(&objects.Object{Type: objects.TREE,
+		SerializableGitObject: objects.TreeObject{Entries: []objects.TreeEntry{{Mode: objects.TREE_MODE_FILE, Sha: blobSha, Path: "file.txt"}}}})
+	assert.Nil(t, err)
+	rootSha, err := currentRepository.WriteObject(&objects.Object{Type: objects.TREE,
+		SerializableGitObject: objects.TreeObject{Entries: []objects.TreeEntry{{Mode: objects.TREE_MODE_DIR, Sha: dirSha, Path: "dir"}}}})
+	assert.Nil(t, err)
+	commitSha, err := currentRepository.WriteObject(objects.CreateCommitObject(rootSha, objects.NO_PARENT_COMMIT_SHA, "Jaime <j@j.com> 1 +0000", "Jaime <j@j.com> 1 +0000", "commit"))
+	assert.Nil(t, err)
+
+	sha, _, err := currentRepository.ResolveObjectName(commitSha+":dir/file.txt", objects.ANY)
+	assert.Nil(t, err)
+	assert.Equal(t, blobSha, sha)
+
+	sha, _, err = currentRepository.ResolveObjectName(commitSha+":dir", objects.TREE)
+	assert.Nil(t, err)
+	assert.Equal(t, dirSha, sha)
+
+	sha, _, err = currentRepository.ResolveObjectName(commitSha+":", objects.ANY)
+	assert.Nil(t, err)
+	assert.Equal(t, rootSha, sha)
+
+	_, _, err = currentRepository.ResolveObjectName(commitSha+":missing", objects.ANY)
+	assert.NotNil(t, err)
+}
+
+func TestRepository_RunHook(t *testing.T) {
+	currentRepository := InitializeRepository(t.TempDir(), false)
+	assert.Nil(t, currentRepository.RunHook(PRE_COMMIT_HOOK, HookOptions{}))
+
+	assert.Nil(t, os.MkdirAll(utils.Path(currentRepository.CommonDir, HOOKS_DIR), os.ModePerm))
+	script := "#!/bin/sh\necho \"$1 $GIT_TEST_VALUE $(cat)\" > result\nexit 3\n"
+	assert.Nil(t, os.WriteFile(currentRepository.HookPath("commit-msg"), []byte(script), 0755))
+
+	err := currentRepository.RunHook(COMMIT_MSG_HOOK, HookOptions{Args: []string{"argument"}, Env: []string{"GIT_TEST_VALUE=value"}, Stdin: "input"})
+
+	assert.Equal(t, HookError{Name: "commit-msg", ExitCode: 3}, err)
+	assert.True(t, IsHookError(err))
+	result, _ := os.ReadFile(utils.Path(currentRepository.WorkTree, "result"))
+	assert.Equal(t, "argument value input\n", string(result))
+
+	assert.Nil(t, os.Chmod(currentRepository.HookPath("commit-msg"), 0644)) //Not executable hooks are ignored
+	assert.Nil(t, currentRepository.RunHook(COMMIT_MSG_HOOK, HookOptions{}))
+}
+
+func TestRepository_ReferenceTransactionHook(t *testing.T) {
+	currentRepository := InitializeRepository(t.TempDir(), false)
+	assert.Nil(t, os.MkdirAll(currentRepository.HooksDir(), os.ModePerm))
+	script := "#!/bin/sh\necho \"$1 $(cat)\" >> transaction\ntest \"$1\" != prepared || ! grep -q refs/heads/blocked transaction\n"
+	assert.Nil(t, os.WriteFile(currentRepository.HookPath(REFERENCE_TRANSACTION_HOOK), []byte(script), 0755))
+	sha := "4b825dc642cb6eb9a060e54bf8d69288fbee4904"
+
+	assert.Nil(t, currentRepository.UpdateRefsAtomically([]RefUpdate{{Name: "refs/heads/a", OldValue: objects.NO_PARENT_COMMIT_SHA, NewValue: sha}}))
+	assert.NotNil(t, currentRepository.UpdateRefsAtomically([]RefUpdate{{Name: "refs/heads/blocked", OldValue: objects.NO_PARENT_COMMIT_SHA, NewValue: sha}}))
+
+	transaction, _ := os.ReadFile(utils.Path(currentRepository.WorkTree, "transaction"))
+	zero := objects.NO_PARENT_COMMIT_SHA
+	assert.Equal(t, "prepared "+zero+" "+sha+" refs/heads/a\ncommitted "+zero+" "+sha+" refs/heads/a\n"+
+		"prepared "+zero+" "+sha+" refs/heads/blocked\naborted "+zero+" "+sha+" refs/heads/blocked\n", string(transaction))
+	assert.False(t, utils.CheckFileOrDirExists(currentRepository.RefPath("refs/heads/blocked")))
+
+	assert.Nil(t, currentRepository.Config.Set(config.LOCAL_SCOPE, "core.hooksPath", "custom-hooks"))
+	assert.Equal(t, utils.Path(currentRepository.WorkTree, "custom-hooks"), currentRepository.HooksDir())
+}
+
+func TestRepository_UpdateRefsAtomicallyRollback(t *testing.T) {
+	currentRepository := InitializeRepository(t.TempDir(), false)
+	zero, oldSha, newSha := objects.NO_PARENT_COMMIT_SHA, "4b825dc642cb6eb9a060e54bf8d69288fbee4904", "e69de29bb2d1d6434b8b29ae775ad8c2e48c5391"
+	assert.Nil(t, currentRepository.UpdateRefsAtomically([]RefUpdate{{Name: "refs/heads/existing", OldValue: zero, NewValue: oldSha},
+		{Name: "refs/heads/deleted", OldValue: zero, NewValue: oldSha}}))
+
+	assert.Nil(t, os.MkdirAll(currentRepository.HooksDir(), os.ModePerm))
+	script := "#!/bin/sh\necho \"$1\" >> transaction\ntest \"$1\" != prepared || mkdir -p \"$GIT_DIR/refs/heads/blocked/ref\"\n"
+	assert.Nil(t, os.WriteFile(currentRepository.HookPath(REFERENCE_TRANSACTION_HOOK), []byte(script), 0755))
+
+	err := currentRepository.UpdateRefsAtomically([]RefUpdate{{Name: "refs/heads/created", OldValue: zero, NewValue: newSha},
+		{Name: "refs/heads/existing", OldValue: oldSha, NewValue: newSha}, {Name: "refs/heads/deleted", OldValue: oldSha, NewValue: zero},
+		{Name: "refs/heads/blocked", OldValue: zero, NewValue: newSha}})
+
+	assert.NotNil(t, err)
+	assert.NotContains(t, err.Error(), "could not be restored")
+	assert.False(t, utils.CheckFileOrDirExists(currentRepository.RefPath("refs/heads/created")))
+	for _, name := range []string{"refs/heads/existing", "refs/heads/deleted"} {
+		ref, err := currentRepository.ResolveRef(name)
+		assert.Nil(t, err)
+		assert.Equal(t, oldSha, ref.Value)
+		assert.False(t, utils.CheckFileOrDirExists(currentRepository.RefPath(name)+".lock"))
+	}
+	transaction, _ := os.ReadFile(utils.Path(currentRepository.WorkTree, "transaction"))
+	assert.Equal(t, "prepared\naborted\n", string(transaction))
+
+	//Refs locked by someone else cannot be restored
+	assert.Nil(t, os.WriteFile(currentRepository.RefPath("refs/heads/existing")+".lock", []byte{}, 0644))
+	assert.Equal(t, []string{"refs/heads/existing"}, currentRepository.restoreRefs([]RefUpdate{{Name: "refs/heads/existing", OldValue: zero, NewValue: oldSha}}))
+}
+
+func TestRepository_Fsck(t *testing.T) {
+	currentRepository := InitializeRepository(t.TempDir(), false)
+	blobSha, err := currentRepository.WriteObject(objects.CreateBlobObject([]byte("content")))
+	assert.Nil(t, err)
+	missingSha := "0123456789012345678901234567890123456789"
+	treeSha, err := currentRepository.WriteObject(&objects.Object{Type: objects.TREE, SerializableGitObject: objects.TreeObject{Entries: []objects.TreeEntry{
+		{Mode: objects.TREE_MODE_FILE, Sha: blobSha, Path: "file.txt"}, {Mode: objects.TREE_MODE_FILE, Sha: missingSha, Path: "missing.txt"}}}})
+	assert.Nil(t, err)
+	commitSha, err := currentRepository.WriteObject(objects.CreateCommitObject(treeSha, objects.NO_PARENT_COMMIT_SHA, "Jaime <j@j.com> 1 +0000", "Jaime <j@j.com> 1 +0000", "commit"))
+	assert.Nil(t, err)
+	danglingSha, err := currentRepository.WriteObject(objects.CreateCommitObject(treeSha, commitSha, "Jaime <j@j.com> 2 +0000", "Jaime <j@j.com> 2 +0000", "dangling"))
+	assert.Nil(t, err)
+	assert.Nil(t, currentRepository.UpdateRefsAtomically([]RefUpdate{{Name: "refs/heads/master", OldValue: objects.NO_PARENT_COMMIT_SHA, NewValue: commitSha}}))
+
+	report, err := currentRepository.Fsck(FsckOptions{Dangling: true})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []FsckProblem{
+		{Kind: FSCK_MISSING, Message: "broken link from tree " + treeSha + "\n              to blob " + missingSha},
+		{Kind: FSCK_MISSING, Message: "missing blob " + missingSha},
+		{Kind: FSCK_DANGLING, Message: "dangling commit " + danglingSha},
+	}, report.Problems)
+	assert.Equal(t, FSCK_MISSING|FSCK_DANGLING, report.ExitCode())
+
+	blobPath := utils.Paths(currentRepository.CommonDir, "objects", blobSha[:2], blobSha[2:])
+	assert.Nil(t, os.Chmod(blobPath, 0644))
+	assert.Nil(t, os.WriteFile(blobPath, []byte("corrupt"), 0644))
+	report, err = currentRepository.Fsck(FsckOptions{Unreachable: true})
+
+	assert.Nil(t, err)
+	assert.Equal(t, FSCK_CORRUPT|FSCK_MISSING|FSCK_UNREACHABLE, report.ExitCode())
+	assert.Contains(t, report.Problems, FsckProblem{Kind: FSCK_UNREACHABLE, Message: "unreachable commit " + danglingSha})
+}
+
+func TestRepository_CopyObjectsSkipsTemporaryFiles(t *testing.T) {
+	source := InitializeRepository(t.TempDir(), false)
+	sha, err := source.WriteObject(objects.CreateBlobObject([]byte("content")))
+	assert.Nil(t, err)
+	prefixDir := utils.Paths(source.CommonDir, "objects", sha[:2])
+	assert.Nil(t, os.WriteFile(utils.Path(prefixDir, "tmp_obj_123456"), []byte("partial"), 0644))
+	assert.Nil(t, os.WriteFile(utils.Path(prefixDir, strings.Repeat("z", 38)), []byte("not hex"), 0644))
+
+	destination := InitializeRepository(t.TempDir(), false)
+	assert.Nil(t, destination.copyObjectsFrom(source))
+
+	assert.True(t, destination.HasObject(sha))
+	copied, err := os.ReadDir(utils.Paths(destination.CommonDir, "objects", sha[:2]))
+	assert.Nil(t, err)
+	assert.Len(t, copied, 1)
+	assert.Equal(t, sha[2:], copied[0].Name())
+}
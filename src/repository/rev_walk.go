@@ -0,0 +1,180 @@
+package repository
+
+import (
+	"container/heap"
+	"git/src/objects"
+)
+
+type RevCommit struct {
+	Sha    string
+	Commit objects.CommitObject
+}
+
+// RevListOptions Commits reachable from Include and not reachable from any of Exclude are listed
+type RevListOptions struct {
+	Include   []string
+	Exclude   []string
+	TopoOrder bool //No parent is listed before all its children, and lines of history are not interleaved
+	MaxCount  int  //The walk stops once this number of commits is listed. 0 if there is no limit. Ignored with TopoOrder
+}
+
+// RevList Walks the commit graph following every parent. Commits are returned newest first by committer date,
+// or in topological order if options.TopoOrder is set. With MaxCount the walk uses the commit graph file if it is
+// present, so only the listed commits are read. Without it every queued commit is listed, so commits are read once
+// when they are queued and their parents are taken from them instead of being decoded from the graph too
+func (r *Repository) RevList(options RevListOptions) ([]RevCommit, error) {
+	excluded := make(map[string]bool)
+	for _, sha := range options.Exclude {
+		reachable, err := r.GetReachableCommits(sha)
+		if err != nil {
+			return nil, err
+		}
+		for reachableSha, _ := range reachable {
+			excluded[reachableSha] = true
+		}
+	}
+
+	readsEveryCommit := options.MaxCount <= 0
+	queue := make(commitQueue, 0, 64)
+	seen := make(map[string]bool)
+	push := func(sha string) error {
+		if seen[sha] || excluded[sha] {
+			return nil
+		}
+		seen[sha] = true
+		queued := &queuedCommit{RevCommit: RevCommit{Sha: sha}, order: len(seen)}
+		if readsEveryCommit {
+			commit, err := r.ReadCommitObject(sha)
+			if err != nil {
+				return err
+			}
+			queued.Commit, queued.read = commit, true
+			queued.parents, queued.when = commit.Parents, commit.CommitTime()
+		} else {
+			node, commit, err := r.readCommitNodeOrObject(sha)
+			if err != nil {
+				return err
+			}
+			queued.parents, queued.when = node.Parents, node.CommitTime
+			if commit != nil {
+				queued.Commit, queued.read = *commit, true
+			}
+		}
+		heap.Push(&queue, queued)
+		return nil
+	}
+
+	for _, sha := range options.Include {
+		if err := push(sha); err != nil {
+			return nil, err
+		}
+	}
+
+	listed := make([]*queuedCommit, 0, 64) //The commits are copied to the result once, growing it would copy them again
+	for queue.Len() > 0 {
+		if options.MaxCount > 0 && len(listed) >= options.MaxCount && !options.TopoOrder {
+			break
+		}
+		actual := heap.Pop(&queue).(*queuedCommit)
+		if !actual.read {
+			commit, err := r.ReadCommitObject(actual.Sha)
+			if err != nil {
+				return nil, err
+			}
+			actual.Commit = commit
+		}
+		listed = append(listed, actual)
+
+		for _, parent := range actual.parents {
+			if err := push(parent); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	result := make([]RevCommit, len(listed))
+	for i, queued := range listed {
+		result[i] = queued.RevCommit
+	}
+	if options.TopoOrder {
+		return sortTopologically(result), nil
+	}
+
+	return result, nil
+}
+
+// commits are sorted by date. A commit is listed once all its children have been. Like git, the history of the last
+// parent of a merge is listed before the one of the first parent
+func sortTopologically(commits []RevCommit) []RevCommit {
+	bySha := make(map[string]RevCommit)
+	pendingChildren := make(map[string]int)
+	for _, commit := range commits {
+		bySha[commit.Sha] = commit
+	}
+	for _, commit := range commits {
+		for _, parent := range commit.Commit.Parents {
+			if _, listed := bySha[parent]; listed {
+				pendingChildren[parent]++
+			}
+		}
+	}
+
+	ready := make([]string, 0) //Stack, the last one is listed first
+	for i := len(commits) - 1; i >= 0; i-- {
+		if pendingChildren[commits[i].Sha] == 0 {
+			ready = append(ready, commits[i].Sha)
+		}
+	}
+
+	result := make([]RevCommit, 0, len(commits))
+	for len(ready) > 0 {
+		commit := bySha[ready[len(ready)-1]]
+		ready = ready[:len(ready)-1]
+		result = append(result, commit)
+
+		for _, parent := range commit.Commit.Parents {
+			if _, listed := bySha[parent]; !listed {
+				continue
+			}
+			pendingChildren[parent]--
+			if pendingChildren[parent] == 0 {
+				ready = append(ready, parent)
+			}
+		}
+	}
+
+	return result
+}
+
+type queuedCommit struct {
+	RevCommit
+	parents []string //Used by RevList, which reads Commit once the commit is listed
+	read    bool     //Commit was read when the commit was queued, since it is not in the commit graph
+	when    int64
+	order   int //Insertion order. Breaks ties between commits with the same date
+}
+
+// commitQueue Priority queue (container/heap) with the newest commit first. It holds pointers, so the commits are not
+// copied when the heap moves them
+type commitQueue []*queuedCommit
+
+func (q commitQueue) Len() int { return len(q) }
+
+func (q commitQueue) Less(i, j int) bool {
+	if q[i].when != q[j].when {
+		return q[i].when > q[j].when
+	}
+	return q[i].order < q[j].order
+}
+
+func (q commitQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *commitQueue) Push(x any) { *q = append(*q, x.(*queuedCommit)) }
+
+func (q *commitQueue) Pop() any {
+	old := *q
+	last := old[len(old)-1]
+	old[len(old)-1] = nil
+	*q = old[:len(old)-1]
+	return last
+}
@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"git/src/utils"
+	"os"
+	"sort"
+	"strings"
+)
+
+// ShallowPath File listing the shallow commits, one sha per line. Their parents are not in the repository, so they are
+// treated as root commits (grafts)
+func (r *Repository) ShallowPath() string {
+	return utils.Path(r.CommonDir, "shallow")
+}
+
+// ReadShallowCommits Returns an empty map if the repository is not shallow. The file is read once
+func (r *Repository) ReadShallowCommits() (map[string]bool, error) {
+	if r.shallowCommits != nil {
+		return r.shallowCommits, nil
+	}
+
+	shallowCommits := make(map[string]bool)
+	content, err := os.ReadFile(r.ShallowPath())
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			shallowCommits[line] = true
+		}
+	}
+
+	r.shallowCommits = shallowCommits
+	return shallowCommits, nil
+}
+
+// ShallowUpdate Changes of the shallow commits sent by the server when the history is deepened
+type ShallowUpdate struct {
+	Shallows   []string //New shallow commits
+	Unshallows []string //Commits that are not shallow anymore, their parents were fetched
+}
+
+// WriteShallowCommits The file is removed if there are no shallow commits
+func (r *Repository) WriteShallowCommits(shallowCommits map[string]bool) error {
+	shas := sortedShaSet(shallowCommits)
+
+	r.shallowCommits = nil
+	if len(shas) == 0 {
+		if err := os.Remove(r.ShallowPath()); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	return os.WriteFile(r.ShallowPath(), []byte(strings.Join(shas, "\n")+"\n"), 0666)
+}
+
+func (r *Repository) IsShallow() bool {
+	shallowCommits, _ := r.ReadShallowCommits()
+	return len(shallowCommits) > 0
+}
+
+func (r *Repository) IsShallowCommit(sha string) bool {
+	shallowCommits, _ := r.ReadShallowCommits()
+	return shallowCommits[sha]
+}
+
+// UpdateShallowCommits Applies the changes of a fetch. The objects must be written before, so the shallow file never points
+// to missing commits
+func (r *Repository) UpdateShallowCommits(shallowUpdate ShallowUpdate) error {
+	if len(shallowUpdate.Shallows) == 0 && len(shallowUpdate.Unshallows) == 0 {
+		return nil
+	}
+	current, err := r.ReadShallowCommits()
+	if err != nil {
+		return err
+	}
+
+	shallowCommits := make(map[string]bool)
+	for sha, _ := range current {
+		shallowCommits[sha] = true
+	}
+	for _, sha := range shallowUpdate.Shallows {
+		shallowCommits[sha] = true
+	}
+	for _, sha := range shallowUpdate.Unshallows {
+		delete(shallowCommits, sha)
+	}
+	return r.WriteShallowCommits(shallowCommits)
+}
+
+func sortedShaSet(shaSet map[string]bool) []string {
+	shas := make([]string, 0, len(shaSet))
+	for sha, _ := range shaSet {
+		shas = append(shas, sha)
+	}
+	sort.Strings(shas)
+	return shas
+}
@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"errors"
+	"git/src/objects"
+	"git/src/signing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const SIGNING_FORMAT_SSH = "ssh"
+
+// GetSigningKey Returns the key in user.signingKey, unless key is not empty. Only ssh keys are supported (gpg.format = ssh)
+func (r *Repository) GetSigningKey(key string) (ssh.Signer, error) {
+	if format := r.Config.String("gpg.format"); format != "" && format != SIGNING_FORMAT_SSH {
+		return nil, errors.New("unsupported signing format " + format + ", only " + SIGNING_FORMAT_SSH + " is supported")
+	}
+	if key == "" {
+		key = r.Config.String("user.signingKey")
+	}
+	if key == "" {
+		return nil, errors.New("user.signingKey needs to be set for ssh signing")
+	}
+
+	return signing.LoadSigningKey(key)
+}
+
+// SignByDefault Returns true if <command>.gpgSign is enabled (commit.gpgSign or tag.gpgSign)
+func (r *Repository) SignByDefault(command string) bool {
+	return r.Config.MustBool(command+".gpgSign", false)
+}
+
+// SignCommit Adds the gpgsig header with the signature of the commit
+func SignCommit(commit *objects.Object, signer ssh.Signer) (*objects.Object, error) {
+	payload, _ := commit.SerializableGitObject.(objects.CommitObject).SignedPayload()
+	signature, err := signing.Sign(signer, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return objects.SignCommitObject(commit, signature), nil
+}
+
+// SignTag Appends the signature of the tag to its message
+func SignTag(tag *objects.Object, signer ssh.Signer) (*objects.Object, error) {
+	payload, _ := tag.SerializableGitObject.(objects.TagObject).SignedPayload()
+	signature, err := signing.Sign(signer, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return objects.SignTagObject(tag, signature), nil
+}
+
+// VerifySignature Checks the signature against the keys of gpg.ssh.allowedSignersFile. signatureTime is the committer or
+// tagger date, checked against the validity of the key like git does with ssh-keygen -Overify-time
+func (r *Repository) VerifySignature(payload []byte, signature string, signatureTime time.Time) signing.Verification {
+	allowedSigners := make([]signing.AllowedSigner, 0)
+	if path := r.Config.String("gpg.ssh.allowedSignersFile"); path != "" {
+		var err error
+		if allowedSigners, err = signing.ReadAllowedSigners(path); err != nil {
+			return signing.Verification{Error: errors.New("cannot read allowed signers file: " + err.Error())}
+		}
+	}
+
+	return signing.VerifyWithAllowedSigners(payload, signature, allowedSigners, signatureTime)
+}
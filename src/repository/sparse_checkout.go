@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"git/src/config"
+	"git/src/sparse"
+	"git/src/utils"
+	"os"
+	"strconv"
+)
+
+// SparseCheckoutPath Each work tree has its own patterns
+func (r *Repository) SparseCheckoutPath() string {
+	return utils.Paths(r.GitDir, "info", "sparse-checkout")
+}
+
+// ReadSparsePatterns Returns nil if core.sparseCheckout is disabled. Cone mode is used unless core.sparseCheckoutCone is false.
+// A missing patterns file includes all the files
+func (r *Repository) ReadSparsePatterns() (*sparse.Patterns, error) {
+	if !r.Config.MustBool("core.sparseCheckout", false) {
+		return nil, nil
+	}
+
+	cone := r.Config.MustBool("core.sparseCheckoutCone", true)
+	content, err := os.ReadFile(r.SparseCheckoutPath())
+	if os.IsNotExist(err) {
+		return sparse.Parse([]byte("/*\n"), false), nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return sparse.Parse(content, cone), nil
+}
+
+func (r *Repository) WriteSparsePatterns(patterns *sparse.Patterns) error {
+	if err := os.MkdirAll(utils.Path(r.GitDir, "info"), 0777); err != nil {
+		return err
+	}
+	return os.WriteFile(r.SparseCheckoutPath(), patterns.Serialize(), 0666)
+}
+
+// SetSparseCheckoutConfig Sets core.sparseCheckout and core.sparseCheckoutCone in the worktree config, so other work trees are
+// not affected. extensions.worktreeConfig is enabled if there are linked work trees
+func (r *Repository) SetSparseCheckoutConfig(enabled bool, cone bool) error {
+	if _, err := r.Config.ScopePath(config.WORKTREE_SCOPE); err != nil {
+		if err := r.enableWorktreeConfig(); err != nil {
+			return err
+		}
+	}
+
+	if err := r.Config.Set(config.WORKTREE_SCOPE, "core.sparseCheckout", strconv.FormatBool(enabled)); err != nil {
+		return err
+	}
+	if !enabled {
+		if err := r.Config.Unset(config.WORKTREE_SCOPE, "core.sparseCheckoutCone"); err != nil && err != config.ErrNotFound {
+			return err
+		}
+		return nil
+	}
+	return r.Config.Set(config.WORKTREE_SCOPE, "core.sparseCheckoutCone", strconv.FormatBool(cone))
+}
+
+// The config is loaded again, since the worktree config file becomes one of its sources
+func (r *Repository) enableWorktreeConfig() error {
+	if err := r.Config.Set(config.LOCAL_SCOPE, "core.repositoryformatversion", "1"); err != nil {
+		return err
+	}
+	if err := r.Config.Set(config.LOCAL_SCOPE, "extensions.worktreeConfig", "true"); err != nil {
+		return err
+	}
+
+	repositoryConfig, err := loadConfig(r.GitDir, r.CommonDir)
+	if err != nil {
+		return err
+	}
+	r.Config = repositoryConfig
+	return nil
+}
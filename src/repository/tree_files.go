@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"errors"
+	"git/src/objects"
+	"git/src/utils"
+	"strings"
+)
+
+// ReadTreeFiles Returns every file of the tree and its subtrees (gitlinks included, directories excluded) keyed by their path
+func (r *Repository) ReadTreeFiles(treeSha string) (map[string]objects.TreeEntry, error) {
+	files := make(map[string]objects.TreeEntry)
+	err := r.readTreeFilesRecursive(treeSha, "", files)
+
+	return files, err
+}
+
+func (r *Repository) readTreeFilesRecursive(treeSha string, prevPath string, files map[string]objects.TreeEntry) error {
+	treeObject, err := r.ReadTreeObject(treeSha)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range treeObject.Entries {
+		path := entry.Path
+		if prevPath != "" {
+			path = utils.Path(prevPath, entry.Path)
+		}
+
+		if !entry.IsDir() {
+			files[path] = entry
+		} else if err := r.readTreeFilesRecursive(entry.Sha, path, files); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// FindTreeEntry Returns the entry of the file or directory at path (separated by /) inside the tree. An empty path is the tree itself
+func (r *Repository) FindTreeEntry(treeSha string, path string) (objects.TreeEntry, error) {
+	entry := objects.TreeEntry{Mode: objects.TREE_MODE_DIR, Sha: treeSha}
+
+	for _, name := range strings.Split(strings.Trim(path, "/"), "/") {
+		if name == "" {
+			continue
+		}
+		if !entry.IsDir() {
+			return objects.TreeEntry{}, errors.New("path '" + path + "' does not exist")
+		}
+
+		treeObject, err := r.ReadTreeObject(entry.Sha)
+		if err != nil {
+			return objects.TreeEntry{}, err
+		}
+
+		found := false
+		for _, child := range treeObject.Entries {
+			if child.Path == name {
+				entry, found = child, true
+				break
+			}
+		}
+		if !found {
+			return objects.TreeEntry{}, errors.New("path '" + path + "' does not exist")
+		}
+	}
+
+	return entry, nil
+}
@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"git/src/config"
+	"strings"
+)
+
+// Upstream Branch tracked by a local branch. Stored in config as branch.<name>.remote and branch.<name>.merge
+type Upstream struct {
+	Remote  string
+	Merge   string //Ref name in the remote. Example: refs/heads/master
+	RefName string //Local ref with the last known value of Merge. Example: refs/remotes/origin/master
+}
+
+// ShortName Example: origin/master
+func (u Upstream) ShortName() string {
+	if u.Remote == "." {
+		return strings.TrimPrefix(u.Merge, "refs/heads/")
+	}
+
+	return strings.TrimPrefix(u.RefName, "refs/remotes/")
+}
+
+// GetUpstream Returns false if the branch doesnt track any branch
+func (r *Repository) GetUpstream(branch string) (Upstream, bool) {
+	remote := r.Config.String("branch." + branch + ".remote")
+	merge := r.Config.String("branch." + branch + ".merge")
+	if remote == "" || merge == "" {
+		return Upstream{}, false
+	}
+
+	refName := merge
+	if remote != "." { //Remote "." means the upstream is a local branch
+		refName = "refs/remotes/" + remote + "/" + strings.TrimPrefix(merge, "refs/heads/")
+	}
+
+	return Upstream{Remote: remote, Merge: merge, RefName: refName}, true
+}
+
+// SetUpstream Writes the upstream in the local config
+func (r *Repository) SetUpstream(branch string, remote string, merge string) error {
+	if err := r.Config.Set(config.LOCAL_SCOPE, "branch."+branch+".remote", remote); err != nil {
+		return err
+	}
+	return r.Config.Set(config.LOCAL_SCOPE, "branch."+branch+".merge", merge)
+}
@@ -0,0 +1,202 @@
+package repository
+
+import (
+	"errors"
+	"git/src/utils"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Linked work trees metadata is stored in <CommonDir>/worktrees/<name>:
+//   - HEAD and index of the work tree
+//   - gitdir: absolute path to the .git file of the work tree
+//   - commondir: path to the shared git dir (relative to the metadata dir)
+//
+// The work tree contains a .git file with content: gitdir: <CommonDir>/worktrees/<name>
+
+type Worktree struct {
+	Name     string //Empty in the main work tree
+	Path     string
+	GitDir   string
+	Head     string //Content of HEAD: ref: refs/heads/<branch> or sha
+	Main     bool
+	Bare     bool
+	Prunable bool //Its work tree path doesnt exist anymore
+}
+
+func (w Worktree) GetBranch() (string, bool) {
+	if strings.HasPrefix(w.Head, "ref: refs/heads/") {
+		return strings.TrimPrefix(w.Head, "ref: refs/heads/"), true
+	}
+
+	return "", false
+}
+
+// AddWorktree Creates the metadata and the .git file of a new work tree. head is the content of its HEAD file.
+// The returned repository can be used to checkout files in the new work tree
+func (r *Repository) AddWorktree(path string, head string) (*Repository, error) {
+	absolutePath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	if entries, err := os.ReadDir(absolutePath); err == nil && len(entries) > 0 {
+		return nil, errors.New("fatal: '" + path + "' already exists")
+	}
+	if err := os.MkdirAll(absolutePath, os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	name := r.getNewWorktreeName(filepath.Base(absolutePath))
+	worktreeGitDir := utils.Paths(r.CommonDir, "worktrees", name)
+	if err := os.MkdirAll(worktreeGitDir, os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	filesToCreate := map[string]string{
+		utils.Path(worktreeGitDir, "HEAD"):      head + "\n",
+		utils.Path(worktreeGitDir, "commondir"): "../..\n",
+		utils.Path(worktreeGitDir, "gitdir"):    utils.Path(absolutePath, ".git") + "\n",
+		utils.Path(absolutePath, ".git"):        "gitdir: " + worktreeGitDir + "\n",
+	}
+	for filePath, content := range filesToCreate {
+		if err := os.WriteFile(filePath, []byte(content), 0666); err != nil {
+			return nil, err
+		}
+	}
+
+	return LoadRepository(absolutePath)
+}
+
+// Same name as the directory. If it is already used, a number is appended
+func (r *Repository) getNewWorktreeName(baseName string) string {
+	name := baseName
+
+	for i := 1; utils.CheckFileOrDirExists(utils.Paths(r.CommonDir, "worktrees", name)); i++ {
+		name = baseName + strconv.Itoa(i)
+	}
+
+	return name
+}
+
+// ListWorktrees The main work tree is always the first one
+func (r *Repository) ListWorktrees() ([]Worktree, error) {
+	worktrees := []Worktree{r.getMainWorktree()}
+
+	entries, err := os.ReadDir(utils.Path(r.CommonDir, "worktrees"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		worktrees = append(worktrees, r.readLinkedWorktree(name))
+	}
+
+	return worktrees, nil
+}
+
+func (r *Repository) getMainWorktree() Worktree {
+	head, _ := os.ReadFile(utils.Path(r.CommonDir, "HEAD"))
+	bare := r.Config.MustBool("core.bare", false)
+	path := filepath.Dir(r.CommonDir)
+	if bare {
+		path = r.CommonDir
+	}
+
+	return Worktree{
+		Path:   path,
+		GitDir: r.CommonDir,
+		Head:   utils.SanitizePath(string(head)),
+		Main:   true,
+		Bare:   bare,
+	}
+}
+
+func (r *Repository) readLinkedWorktree(name string) Worktree {
+	worktreeGitDir := utils.Paths(r.CommonDir, "worktrees", name)
+	head, _ := os.ReadFile(utils.Path(worktreeGitDir, "HEAD"))
+	gitFilePath, _ := os.ReadFile(utils.Path(worktreeGitDir, "gitdir"))
+	path := filepath.Dir(utils.SanitizePath(string(gitFilePath)))
+
+	return Worktree{
+		Name:     name,
+		Path:     path,
+		GitDir:   worktreeGitDir,
+		Head:     utils.SanitizePath(string(head)),
+		Prunable: len(gitFilePath) == 0 || !utils.CheckFileOrDirExists(utils.SanitizePath(string(gitFilePath))),
+	}
+}
+
+// FindWorktreeWithBranch Returns the work tree that has the branch checked out
+func (r *Repository) FindWorktreeWithBranch(branch string) (Worktree, bool) {
+	worktrees, _ := r.ListWorktrees()
+
+	for _, worktree := range worktrees {
+		if checkedOutBranch, isBranch := worktree.GetBranch(); isBranch && checkedOutBranch == branch && !worktree.Bare {
+			return worktree, true
+		}
+	}
+
+	return Worktree{}, false
+}
+
+// FindWorktreeByPath path can be the work tree path or its name
+func (r *Repository) FindWorktreeByPath(path string) (Worktree, error) {
+	absolutePath, _ := filepath.Abs(path)
+	worktrees, err := r.ListWorktrees()
+	if err != nil {
+		return Worktree{}, err
+	}
+
+	for _, worktree := range worktrees[1:] {
+		if worktree.Path == absolutePath || worktree.Name == path {
+			return worktree, nil
+		}
+	}
+
+	return Worktree{}, errors.New("fatal: '" + path + "' is not a working tree")
+}
+
+// RemoveWorktree Deletes the work tree files and its metadata
+func (r *Repository) RemoveWorktree(worktree Worktree) error {
+	if worktree.Main {
+		return errors.New("fatal: '" + worktree.Path + "' is a main working tree")
+	}
+	if err := os.RemoveAll(worktree.Path); err != nil {
+		return err
+	}
+
+	return os.RemoveAll(worktree.GitDir)
+}
+
+// PruneWorktrees Deletes the metadata of work trees whose directory doesnt exist anymore. Returns the pruned names
+func (r *Repository) PruneWorktrees() ([]string, error) {
+	worktrees, err := r.ListWorktrees()
+	if err != nil {
+		return nil, err
+	}
+
+	pruned := make([]string, 0)
+	for _, worktree := range worktrees[1:] {
+		if !worktree.Prunable {
+			continue
+		}
+		if err := os.RemoveAll(worktree.GitDir); err != nil {
+			return pruned, err
+		}
+
+		pruned = append(pruned, worktree.Name)
+	}
+
+	return pruned, nil
+}
@@ -0,0 +1,151 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"git/src/packfile"
+	"git/src/protocol"
+	"git/src/repository"
+	"io"
+	"strings"
+)
+
+// Request format: <old sha> <new sha> <ref name>[\x00capabilities]\n ... 0000 [packfile]
+// Response format (report-status): unpack (ok|<error>)\n (ok <ref name>|ng <ref name> <reason>)\n ... 0000
+// All refs are updated atomically: if one of them cannot be updated, none of them are
+// Hooks pre-receive and update can reject the refs. post-receive is run after the refs are updated
+func receivePack(writer io.Writer, body io.Reader, currentRepository *repository.Repository) error {
+	commands, err := readReceivePackCommands(body)
+	if err != nil {
+		return err
+	}
+
+	unpackErr := unpackObjects(body, currentRepository, commands)
+	rejectedRefs := rejectAllRefs(commands, unpackErr)
+	if unpackErr == nil {
+		rejectedRefs = updateRefs(currentRepository, commands)
+	}
+
+	report := bytes.Buffer{}
+	if unpackErr != nil {
+		protocol.WritePktLine(&report, "unpack "+unpackErr.Error()+"\n")
+	} else {
+		protocol.WritePktLine(&report, "unpack ok\n")
+	}
+	for _, command := range commands {
+		if reason, rejected := rejectedRefs[command.Name]; rejected {
+			protocol.WritePktLine(&report, "ng "+command.Name+" "+reason+"\n")
+		} else {
+			protocol.WritePktLine(&report, "ok "+command.Name+"\n")
+		}
+	}
+	protocol.WriteFlush(&report)
+
+	_, err = writer.Write(report.Bytes())
+	return err
+}
+
+func readReceivePackCommands(body io.Reader) ([]repository.RefUpdate, error) {
+	lines, err := protocol.ReadPktLinesUntilFlush(body)
+	if err != nil {
+		return nil, err
+	}
+
+	commands := make([]repository.RefUpdate, 0, len(lines))
+	for _, line := range lines {
+		line, _, _ = strings.Cut(line, "\x00") //Capabilities
+
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return nil, errors.New("Invalid receive-pack command: " + line)
+		}
+
+		commands = append(commands, repository.RefUpdate{OldValue: fields[0], NewValue: fields[1], Name: fields[2]})
+	}
+
+	return commands, nil
+}
+
+// The packfile is only sent if some command is not a delete
+func unpackObjects(body io.Reader, currentRepository *repository.Repository, commands []repository.RefUpdate) error {
+	onlyDeletes := true
+	for _, command := range commands {
+		onlyDeletes = onlyDeletes && command.IsDelete()
+	}
+	if onlyDeletes {
+		return nil
+	}
+
+	packObjects, err := packfile.ReadPack(body, func(sha string) (packfile.PackObject, bool) {
+		rawObject, err := currentRepository.ReadRawObject(sha)
+		if err != nil {
+			return packfile.PackObject{}, false
+		}
+		return packfile.PackObject{Type: rawObject.Type, Sha: sha, Data: rawObject.SerializableGitObject.Serialize()}, true
+	})
+	if err != nil {
+		return err
+	}
+
+	return currentRepository.WritePackObjects(packObjects)
+}
+
+// Returns ref name -> reason of the refs that have been rejected. If a ref is rejected, none of them are updated
+func updateRefs(currentRepository *repository.Repository, commands []repository.RefUpdate) map[string]string {
+	for _, command := range commands {
+		if !strings.HasPrefix(command.Name, "refs/") || strings.Contains(command.Name, "..") || strings.HasSuffix(command.Name, ".lock") {
+			return rejectAllRefs(commands, errors.New("invalid ref name "+command.Name))
+		}
+		if !command.IsDelete() && !currentRepository.HasObject(command.NewValue) {
+			return rejectAllRefs(commands, errors.New("missing necessary objects"))
+		}
+	}
+
+	hookInput := repository.FormatRefUpdatesHookInput(commands)
+	if err := currentRepository.RunHook(repository.PRE_RECEIVE_HOOK, repository.HookOptions{Stdin: hookInput}); err != nil {
+		return rejectAllRefs(commands, errors.New("pre-receive hook declined"))
+	}
+	if rejectedRefs := runUpdateHooks(currentRepository, commands); len(rejectedRefs) > 0 {
+		return rejectedRefs
+	}
+
+	if err := currentRepository.UpdateRefsAtomically(commands); err != nil {
+		return rejectAllRefs(commands, errors.New("atomic push failed: "+err.Error()))
+	}
+
+	currentRepository.RunHook(repository.POST_RECEIVE_HOOK, repository.HookOptions{Stdin: hookInput}) //Refs are already updated
+	return map[string]string{}
+}
+
+// The update hook is run once per ref. Refs not rejected by it fail too, since the update is atomic
+func runUpdateHooks(currentRepository *repository.Repository, commands []repository.RefUpdate) map[string]string {
+	rejectedRefs := make(map[string]string)
+	for _, command := range commands {
+		if err := currentRepository.RunHook(repository.UPDATE_HOOK, repository.HookOptions{Args: []string{command.Name, command.OldValue, command.NewValue}}); err != nil {
+			rejectedRefs[command.Name] = "hook declined"
+		}
+	}
+
+	if len(rejectedRefs) > 0 {
+		for _, command := range commands {
+			if _, rejected := rejectedRefs[command.Name]; !rejected {
+				rejectedRefs[command.Name] = "atomic push failure"
+			}
+		}
+	}
+
+	return rejectedRefs
+}
+
+// Returns an empty map if err is nil
+func rejectAllRefs(commands []repository.RefUpdate, err error) map[string]string {
+	rejectedRefs := make(map[string]string)
+	if err == nil {
+		return rejectedRefs
+	}
+
+	for _, command := range commands {
+		rejectedRefs[command.Name] = err.Error()
+	}
+	return rejectedRefs
+}
@@ -0,0 +1,187 @@
+package server
+
+import (
+	"compress/gzip"
+	"git/src/objects"
+	"git/src/protocol"
+	"git/src/repository"
+	"git/src/utils"
+	"io"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	UPLOAD_PACK_SERVICE  = "git-upload-pack"
+	RECEIVE_PACK_SERVICE = "git-receive-pack"
+)
+
+// Url format: /<repository path relative to RootPath>/(info/refs|git-upload-pack|git-receive-pack)
+var smartHttpUrlRegex = regexp.MustCompile(`^(.*?)/(info/refs|git-upload-pack|git-receive-pack)$`)
+
+// Server Serves every repository under RootPath using the git smart HTTP protocol (version 0)
+type Server struct {
+	RootPath string
+}
+
+func CreateServer(rootPath string) *Server {
+	return &Server{RootPath: rootPath}
+}
+
+func (s *Server) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	matches := smartHttpUrlRegex.FindStringSubmatch(request.URL.Path)
+	if matches == nil {
+		http.NotFound(writer, request)
+		return
+	}
+
+	currentRepository, err := s.findRepository(matches[1])
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	writer.Header().Set("Cache-Control", "no-cache")
+
+	switch endpoint := matches[2]; {
+	case endpoint == "info/refs" && request.Method == http.MethodGet:
+		s.handleInfoRefs(writer, request, currentRepository)
+	case endpoint == UPLOAD_PACK_SERVICE && request.Method == http.MethodPost:
+		s.handleServiceRequest(writer, request, currentRepository, UPLOAD_PACK_SERVICE)
+	case endpoint == RECEIVE_PACK_SERVICE && request.Method == http.MethodPost:
+		s.handleServiceRequest(writer, request, currentRepository, RECEIVE_PACK_SERVICE)
+	default:
+		http.Error(writer, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) findRepository(urlPath string) (*repository.Repository, error) {
+	rootPath, err := filepath.Abs(s.RootPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Cleaning it as an absolute path removes any ".." that could escape RootPath
+	repositoryPath := utils.Path(rootPath, filepath.Clean("/"+urlPath))
+
+	if currentRepository, err := repository.LoadRepository(repositoryPath); err == nil {
+		return currentRepository, nil
+	}
+
+	return repository.LoadRepository(strings.TrimSuffix(repositoryPath, ".git"))
+}
+
+func (s *Server) handleInfoRefs(writer http.ResponseWriter, request *http.Request, currentRepository *repository.Repository) {
+	service := request.URL.Query().Get("service")
+	if service != UPLOAD_PACK_SERVICE && service != RECEIVE_PACK_SERVICE {
+		http.Error(writer, "Only smart HTTP protocol is supported", http.StatusForbidden)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/x-"+service+"-advertisement")
+
+	protocol.WritePktLine(writer, "# service="+service+"\n")
+	protocol.WriteFlush(writer)
+	writeRefsAdvertisement(writer, currentRepository, service)
+}
+
+func (s *Server) handleServiceRequest(writer http.ResponseWriter, request *http.Request, currentRepository *repository.Repository, service string) {
+	if request.Header.Get("Content-Type") != "application/x-"+service+"-request" {
+		http.Error(writer, "Invalid content type", http.StatusBadRequest)
+		return
+	}
+
+	body, err := getRequestBody(request)
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer body.Close()
+
+	writer.Header().Set("Content-Type", "application/x-"+service+"-result")
+
+	if service == UPLOAD_PACK_SERVICE {
+		err = uploadPack(writer, body, currentRepository)
+	} else {
+		err = receivePack(writer, body, currentRepository)
+	}
+
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func getRequestBody(request *http.Request) (io.ReadCloser, error) {
+	if request.Header.Get("Content-Encoding") == "gzip" {
+		return gzip.NewReader(request.Body)
+	}
+
+	return request.Body, nil
+}
+
+// Format of every line: <sha> <ref name>. The first line contains the capabilities after a \x00
+// Annotated tags are followed by a line with its peeled value: <sha> <ref name>^{}
+func writeRefsAdvertisement(writer io.Writer, currentRepository *repository.Repository, service string) {
+	refs, _ := currentRepository.GetAllRefs()
+	refNames := make([]string, 0, len(refs))
+	for refName, _ := range refs {
+		refNames = append(refNames, refName)
+	}
+	sort.Strings(refNames)
+
+	capabilities := getCapabilities(currentRepository, service)
+	lines := make([]string, 0)
+
+	if head, err := currentRepository.ResolveRef("HEAD"); err == nil && service == UPLOAD_PACK_SERVICE {
+		lines = append(lines, head.Value+" HEAD")
+	}
+	for _, refName := range refNames {
+		refValue := refs[refName].Value
+		lines = append(lines, refValue+" "+refName)
+
+		if peeled, isTag := peelTag(currentRepository, refValue); isTag {
+			lines = append(lines, peeled+" "+refName+"^{}")
+		}
+	}
+
+	if len(lines) == 0 {
+		lines = append(lines, objects.NO_PARENT_COMMIT_SHA+" capabilities^{}")
+	}
+
+	for i, line := range lines {
+		if i == 0 {
+			line = line + "\x00" + capabilities
+		}
+
+		protocol.WritePktLine(writer, line+"\n")
+	}
+
+	protocol.WriteFlush(writer)
+}
+
+func getCapabilities(currentRepository *repository.Repository, service string) string {
+	if service == RECEIVE_PACK_SERVICE {
+		return "report-status delete-refs atomic ofs-delta agent=git/jaime"
+	}
+
+	//Any object can be wanted, so partial clones can fetch the blobs they miss
+	capabilities := "ofs-delta shallow deepen-since deepen-relative filter allow-tip-sha1-in-want allow-reachable-sha1-in-want agent=git/jaime"
+	if branch, detached, err := currentRepository.GetActiveBranch(); err == nil && !detached {
+		capabilities = "symref=HEAD:refs/heads/" + branch + " " + capabilities
+	}
+
+	return capabilities
+}
+
+func peelTag(currentRepository *repository.Repository, sha string) (string, bool) {
+	object, err := currentRepository.ReadRawObject(sha)
+	if err != nil || object.Type != objects.TAG {
+		return "", false
+	}
+
+	peeled, _, err := currentRepository.ResolveObjectName(sha, objects.COMMIT)
+	return peeled, err == nil
+}
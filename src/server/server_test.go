@@ -0,0 +1,276 @@
+package server
+
+import (
+	"bytes"
+	"git/src/objects"
+	"git/src/packfile"
+	"git/src/protocol"
+	"git/src/repository"
+	"git/src/utils"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_InfoRefsAndUploadPack(t *testing.T) {
+	rootPath, currentRepository := createServedRepository(t)
+	commitSha := createCommit(t, currentRepository, "hello\n", objects.NO_PARENT_COMMIT_SHA)
+	httpServer := httptest.NewServer(CreateServer(rootPath))
+	defer httpServer.Close()
+
+	response, err := http.Get(httpServer.URL + "/repo/info/refs?service=git-upload-pack")
+	assert.Nil(t, err)
+	body, _ := io.ReadAll(response.Body)
+
+	assert.Equal(t, "application/x-git-upload-pack-advertisement", response.Header.Get("Content-Type"))
+	assert.True(t, strings.HasPrefix(string(body), "001e# service=git-upload-pack\n0000"))
+	assert.Contains(t, string(body), commitSha+" HEAD\x00symref=HEAD:refs/heads/master")
+	assert.Contains(t, string(body), commitSha+" refs/heads/master\n")
+
+	request := bytes.Buffer{}
+	protocol.WritePktLine(&request, "want "+commitSha+" ofs-delta\n")
+	protocol.WriteFlush(&request)
+	protocol.WritePktLine(&request, "done\n")
+	response, err = http.Post(httpServer.URL+"/repo/git-upload-pack", "application/x-git-upload-pack-request", &request)
+	assert.Nil(t, err)
+
+	nak, err := protocol.ReadPktLine(response.Body)
+	assert.Nil(t, err)
+	assert.Equal(t, "NAK", nak.String())
+
+	packObjects, err := packfile.ReadPack(response.Body, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(packObjects)) //Commit, tree and blob
+}
+
+func TestServer_ReceivePackUpdatesRefsAtomically(t *testing.T) {
+	rootPath, currentRepository := createServedRepository(t)
+	firstCommitSha := createCommit(t, currentRepository, "first\n", objects.NO_PARENT_COMMIT_SHA)
+	httpServer := httptest.NewServer(CreateServer(rootPath))
+	defer httpServer.Close()
+
+	blob := packfile.CreatePackObject(objects.BLOB, []byte("pushed\n"))
+	tree := packfile.CreatePackObject(objects.TREE, objects.TreeObject{Entries: []objects.TreeEntry{{Mode: objects.TREE_MODE_FILE, Sha: blob.Sha, Path: "a.txt"}}}.Serialize())
+	commit := packfile.CreatePackObject(objects.COMMIT, objects.CreateCommitObject(tree.Sha, firstCommitSha, "A <a@a.com> 0 +0000", "A <a@a.com> 0 +0000", "pushed").SerializableGitObject.Serialize())
+
+	// Second command has a stale old value, so none of the refs must be updated
+	request := bytes.Buffer{}
+	protocol.WritePktLine(&request, objects.NO_PARENT_COMMIT_SHA+" "+commit.Sha+" refs/heads/feature\x00report-status atomic\n")
+	protocol.WritePktLine(&request, commit.Sha+" "+commit.Sha+" refs/heads/master\n")
+	protocol.WriteFlush(&request)
+	packfile.WritePack(&request, []packfile.PackObject{blob, tree, commit})
+
+	report := postReceivePack(t, httpServer.URL, request.Bytes())
+
+	assert.Equal(t, "unpack ok", report[0])
+	assert.True(t, strings.HasPrefix(report[1], "ng refs/heads/feature"))
+	assert.True(t, strings.HasPrefix(report[2], "ng refs/heads/master"))
+	assert.False(t, utils.CheckFileOrDirExists(utils.Paths(currentRepository.GitDir, "refs", "heads", "feature")))
+	assert.True(t, currentRepository.HasObject(commit.Sha))
+
+	request = bytes.Buffer{}
+	protocol.WritePktLine(&request, objects.NO_PARENT_COMMIT_SHA+" "+commit.Sha+" refs/heads/feature\x00report-status\n")
+	protocol.WritePktLine(&request, firstCommitSha+" "+commit.Sha+" refs/heads/master\n")
+	protocol.WriteFlush(&request)
+	packfile.WritePack(&request, []packfile.PackObject{})
+
+	report = postReceivePack(t, httpServer.URL, request.Bytes())
+
+	assert.Equal(t, []string{"unpack ok", "ok refs/heads/feature", "ok refs/heads/master"}, report)
+	master, _ := currentRepository.ResolveRef("refs/heads/master")
+	feature, _ := currentRepository.ResolveRef("refs/heads/feature")
+	assert.Equal(t, commit.Sha, master.Value)
+	assert.Equal(t, commit.Sha, feature.Value)
+}
+
+func TestServer_ReceivePackHooks(t *testing.T) {
+	rootPath, currentRepository := createServedRepository(t)
+	firstCommitSha := createCommit(t, currentRepository, "first\n", objects.NO_PARENT_COMMIT_SHA)
+	httpServer := httptest.NewServer(CreateServer(rootPath))
+	defer httpServer.Close()
+
+	hooksDir := utils.Path(currentRepository.CommonDir, repository.HOOKS_DIR)
+	assert.Nil(t, os.MkdirAll(hooksDir, os.ModePerm))
+	assert.Nil(t, os.WriteFile(utils.Path(hooksDir, repository.UPDATE_HOOK), []byte("#!/bin/sh\ntest \"$1\" != refs/heads/blocked\n"), 0755))
+	assert.Nil(t, os.WriteFile(utils.Path(hooksDir, repository.POST_RECEIVE_HOOK), []byte("#!/bin/sh\ncat > received\n"), 0755))
+
+	request := bytes.Buffer{}
+	protocol.WritePktLine(&request, objects.NO_PARENT_COMMIT_SHA+" "+firstCommitSha+" refs/heads/blocked\x00report-status\n")
+	protocol.WritePktLine(&request, objects.NO_PARENT_COMMIT_SHA+" "+firstCommitSha+" refs/heads/feature\n")
+	protocol.WriteFlush(&request)
+	packfile.WritePack(&request, []packfile.PackObject{})
+
+	report := postReceivePack(t, httpServer.URL, request.Bytes())
+
+	assert.Equal(t, []string{"unpack ok", "ng refs/heads/blocked hook declined", "ng refs/heads/feature atomic push failure"}, report)
+	assert.False(t, utils.CheckFileOrDirExists(utils.Path(currentRepository.WorkTree, "received")))
+
+	request = bytes.Buffer{}
+	protocol.WritePktLine(&request, objects.NO_PARENT_COMMIT_SHA+" "+firstCommitSha+" refs/heads/feature\x00report-status\n")
+	protocol.WriteFlush(&request)
+	packfile.WritePack(&request, []packfile.PackObject{})
+
+	report = postReceivePack(t, httpServer.URL, request.Bytes())
+
+	assert.Equal(t, []string{"unpack ok", "ok refs/heads/feature"}, report)
+	received, _ := os.ReadFile(utils.Path(currentRepository.WorkTree, "received"))
+	assert.Equal(t, objects.NO_PARENT_COMMIT_SHA+" "+firstCommitSha+" refs/heads/feature\n", string(received))
+}
+
+func TestServer_StockGitClone(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	rootPath, currentRepository := createServedRepository(t)
+	createCommit(t, currentRepository, "cloned\n", objects.NO_PARENT_COMMIT_SHA)
+	httpServer := httptest.NewServer(CreateServer(rootPath))
+	defer httpServer.Close()
+
+	clonePath := utils.Path(t.TempDir(), "clone")
+	output, err := exec.Command("git", "clone", httpServer.URL+"/repo", clonePath).CombinedOutput()
+
+	assert.Nil(t, err, string(output))
+	content, err := os.ReadFile(utils.Path(clonePath, "a.txt"))
+	assert.Nil(t, err)
+	assert.Equal(t, "cloned\n", string(content))
+}
+
+func TestServer_PartialClone(t *testing.T) {
+	rootPath, currentRepository := createServedRepository(t)
+	firstCommitSha := createCommit(t, currentRepository, "first\n", objects.NO_PARENT_COMMIT_SHA)
+	secondCommitSha := createCommit(t, currentRepository, "second version\n", firstCommitSha)
+	firstBlobSha := objects.CreateBlobObject([]byte("first\n")).Sha()
+	httpServer := httptest.NewServer(CreateServer(rootPath))
+	defer httpServer.Close()
+
+	limit, err := protocol.ParseObjectFilter("blob:limit=10")
+	assert.Nil(t, err)
+	packObjects, err := CollectObjectsToSend(currentRepository, UploadPackRequest{Wants: []string{secondCommitSha}, Filter: limit}, ShallowBoundary{})
+	assert.Nil(t, err)
+	assert.Equal(t, 5, len(packObjects)) //Commits, trees and the first blob
+
+	filter, err := protocol.ParseObjectFilter("blob:none")
+	assert.Nil(t, err)
+	clonePath := utils.Path(t.TempDir(), "clone")
+	cloned, err := repository.CloneHttpRepository(httpServer.URL+"/repo", clonePath, utils.Path(clonePath, ".git"), filter, protocol.DeepenOptions{})
+	assert.Nil(t, err)
+
+	assert.Equal(t, repository.DEFAULT_REMOTE_NAME, cloned.PromisorRemote())
+	assert.Equal(t, "blob:none", cloned.Config.String("remote.origin.partialclonefilter"))
+	head, err := cloned.ResolveRef("HEAD")
+	assert.Nil(t, err)
+	assert.Equal(t, secondCommitSha, head.Value)
+	assert.True(t, cloned.HasObject(firstCommitSha))
+	assert.False(t, cloned.HasObject(firstBlobSha))
+
+	blob, err := cloned.ReadBlobObject(firstBlobSha)
+	assert.Nil(t, err)
+	assert.Equal(t, "first\n", string(blob.Data))
+	assert.True(t, cloned.HasObject(firstBlobSha))
+}
+
+func TestServer_ShallowCloneAndDeepen(t *testing.T) {
+	rootPath, currentRepository := createServedRepository(t)
+	firstCommitSha := createCommit(t, currentRepository, "first\n", objects.NO_PARENT_COMMIT_SHA)
+	secondCommitSha := createCommit(t, currentRepository, "second\n", firstCommitSha)
+	thirdCommitSha := createCommit(t, currentRepository, "third\n", secondCommitSha)
+	httpServer := httptest.NewServer(CreateServer(rootPath))
+	defer httpServer.Close()
+
+	noFilter, _ := protocol.ParseObjectFilter("")
+	clonePath := utils.Path(t.TempDir(), "clone")
+	cloned, err := repository.CloneHttpRepository(httpServer.URL+"/repo", clonePath, utils.Path(clonePath, ".git"), noFilter,
+		protocol.DeepenOptions{Depth: 1})
+	assert.Nil(t, err)
+
+	assert.True(t, cloned.IsShallowCommit(thirdCommitSha))
+	assert.False(t, cloned.HasObject(secondCommitSha))
+	commit, err := cloned.ReadCommitObject(thirdCommitSha)
+	assert.Nil(t, err)
+	assert.False(t, commit.HasParent())
+
+	assert.Nil(t, cloned.FetchHttpRemote(repository.DEFAULT_REMOTE_NAME, protocol.DeepenOptions{Depth: 1, Relative: true}))
+	shallowCommits, err := cloned.ReadShallowCommits()
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]bool{secondCommitSha: true}, shallowCommits)
+	assert.False(t, cloned.HasObject(firstCommitSha))
+
+	assert.Nil(t, cloned.FetchHttpRemote(repository.DEFAULT_REMOTE_NAME, protocol.DeepenOptions{Depth: protocol.INFINITE_DEPTH}))
+	assert.False(t, cloned.IsShallow())
+	assert.False(t, utils.CheckFileOrDirExists(cloned.ShallowPath()))
+	assert.True(t, cloned.HasObject(firstCommitSha))
+}
+
+func TestServer_ShallowCloneWithAnnotatedTag(t *testing.T) {
+	rootPath, currentRepository := createServedRepository(t)
+	firstCommitSha := createCommit(t, currentRepository, "first\n", objects.NO_PARENT_COMMIT_SHA)
+	secondCommitSha := createCommit(t, currentRepository, "second\n", firstCommitSha)
+	thirdCommitSha := createCommit(t, currentRepository, "third\n", secondCommitSha)
+	tagSha, err := currentRepository.WriteObject(objects.CreateTagObject(secondCommitSha, objects.COMMIT, "v1", "A <a@a.com> 0 +0000", "v1\n"))
+	assert.Nil(t, err)
+	assert.Nil(t, currentRepository.UpdateRefsAtomically([]repository.RefUpdate{{Name: "refs/tags/v1", OldValue: objects.NO_PARENT_COMMIT_SHA, NewValue: tagSha}}))
+	httpServer := httptest.NewServer(CreateServer(rootPath))
+	defer httpServer.Close()
+
+	noFilter, _ := protocol.ParseObjectFilter("")
+	clonePath := utils.Path(t.TempDir(), "clone")
+	cloned, err := repository.CloneHttpRepository(httpServer.URL+"/repo", clonePath, utils.Path(clonePath, ".git"), noFilter,
+		protocol.DeepenOptions{Depth: 1})
+	assert.Nil(t, err)
+
+	shallowCommits, err := cloned.ReadShallowCommits()
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]bool{secondCommitSha: true, thirdCommitSha: true}, shallowCommits)
+	assert.True(t, cloned.HasObject(tagSha))
+	assert.False(t, cloned.HasObject(firstCommitSha))
+
+	if _, err := exec.LookPath("git"); err == nil {
+		stockClonePath := utils.Path(t.TempDir(), "stock")
+		output, err := exec.Command("git", "clone", "--depth", "1", "--no-single-branch", httpServer.URL+"/repo", stockClonePath).CombinedOutput()
+		assert.Nil(t, err, string(output))
+		shallowFile, err := os.ReadFile(utils.Paths(stockClonePath, ".git", "shallow"))
+		assert.Nil(t, err)
+		assert.ElementsMatch(t, []string{secondCommitSha, thirdCommitSha}, strings.Fields(string(shallowFile)))
+	}
+}
+
+func createServedRepository(t *testing.T) (string, *repository.Repository) {
+	rootPath := t.TempDir()
+	repositoryPath := utils.Path(rootPath, "repo")
+	assert.Nil(t, os.Mkdir(repositoryPath, os.ModePerm))
+
+	return rootPath, repository.InitializeRepository(repositoryPath, false)
+}
+
+func createCommit(t *testing.T, currentRepository *repository.Repository, content string, parent string) string {
+	blobSha, err := currentRepository.WriteObject(objects.CreateBlobObject([]byte(content)))
+	assert.Nil(t, err)
+	treeSha, err := currentRepository.WriteObject(&objects.Object{
+		Type:                  objects.TREE,
+		SerializableGitObject: objects.TreeObject{Entries: []objects.TreeEntry{{Mode: objects.TREE_MODE_FILE, Sha: blobSha, Path: "a.txt"}}},
+	})
+	assert.Nil(t, err)
+	commitSha, err := currentRepository.WriteObject(objects.CreateCommitObject(treeSha, parent, "A <a@a.com> 0 +0000", "A <a@a.com> 0 +0000", "commit"))
+	assert.Nil(t, err)
+
+	assert.Nil(t, currentRepository.UpdateRefsAtomically([]repository.RefUpdate{{Name: "refs/heads/master", OldValue: parent, NewValue: commitSha}}))
+
+	return commitSha
+}
+
+func postReceivePack(t *testing.T, url string, body []byte) []string {
+	response, err := http.Post(url+"/repo/git-receive-pack", "application/x-git-receive-pack-request", bytes.NewReader(body))
+	assert.Nil(t, err)
+
+	report, err := protocol.ReadPktLinesUntilFlush(response.Body)
+	assert.Nil(t, err)
+
+	return report
+}
@@ -0,0 +1,181 @@
+package server
+
+import (
+	"git/src/objects"
+	"git/src/protocol"
+	"git/src/repository"
+	"io"
+	"sort"
+)
+
+// ShallowBoundary Shallows: commits whose parents are not sent. Unshallows: shallow commits of the client whose parents are sent
+type ShallowBoundary struct {
+	Shallows   map[string]bool
+	Unshallows map[string]bool
+}
+
+// GetShallowBoundary Calculates the boundary of the history requested with deepen, deepen-since or deepen-relative
+// With depth n the commits n generations away from the wants (or from the shallow commits of the client if relative)
+// become shallow. With a date the commits that have a parent older than it become shallow. The shallow commits of the
+// server are always part of the boundary, because their parents are not in the repository
+func GetShallowBoundary(currentRepository *repository.Repository, request UploadPackRequest) (ShallowBoundary, error) {
+	boundary := ShallowBoundary{Shallows: make(map[string]bool), Unshallows: make(map[string]bool)}
+
+	var err error
+	if request.Deepen.Since != 0 {
+		err = walkDeepenSince(currentRepository, request, boundary)
+	} else {
+		err = walkDeepenDepth(currentRepository, request, boundary)
+	}
+	if err != nil {
+		return boundary, err
+	}
+
+	for sha, _ := range request.ClientShallows {
+		if boundary.Unshallows[sha] {
+			delete(boundary.Shallows, sha)
+		}
+	}
+	return boundary, nil
+}
+
+// Breadth first walk. The wants are at depth 1, or the shallow commits of the client are at depth 0 if relative.
+// Commits at the requested depth that have parents are the boundary
+func walkDeepenDepth(currentRepository *repository.Repository, request UploadPackRequest, boundary ShallowBoundary) error {
+	depths := make(map[string]int)
+	pending := make([]string, 0)
+	if request.Deepen.Relative {
+		for sha, _ := range request.ClientShallows {
+			if currentRepository.HasObject(sha) {
+				depths[sha] = 0
+				pending = append(pending, sha)
+			}
+		}
+	} else {
+		for _, sha := range peelWantsToCommits(currentRepository, request.Wants) {
+			if _, visited := depths[sha]; !visited { //A tag and a branch can point to the same commit
+				depths[sha] = 1
+				pending = append(pending, sha)
+			}
+		}
+	}
+
+	for len(pending) > 0 {
+		sha := pending[0]
+		pending = pending[1:]
+
+		commit, err := currentRepository.ReadCommitObject(sha)
+		if err != nil {
+			return err
+		}
+		if currentRepository.IsShallowCommit(sha) {
+			boundary.Shallows[sha] = true
+			continue
+		}
+		if len(commit.Parents) == 0 {
+			continue
+		}
+		if depths[sha] >= request.Deepen.Depth {
+			boundary.Shallows[sha] = true
+			continue
+		}
+
+		if request.ClientShallows[sha] {
+			boundary.Unshallows[sha] = true
+		}
+		for _, parent := range commit.Parents {
+			if _, visited := depths[parent]; !visited {
+				depths[parent] = depths[sha] + 1
+				pending = append(pending, parent)
+			}
+		}
+	}
+
+	return nil
+}
+
+// The wants are always sent. Parents committed before the date are not, so their children are the boundary
+func walkDeepenSince(currentRepository *repository.Repository, request UploadPackRequest, boundary ShallowBoundary) error {
+	visited := make(map[string]bool)
+	pending := peelWantsToCommits(currentRepository, request.Wants)
+
+	for len(pending) > 0 {
+		sha := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if visited[sha] {
+			continue
+		}
+		visited[sha] = true
+
+		commit, err := currentRepository.ReadCommitObject(sha)
+		if err != nil {
+			return err
+		}
+		if currentRepository.IsShallowCommit(sha) {
+			boundary.Shallows[sha] = true
+			continue
+		}
+
+		includedParents := make([]string, 0, len(commit.Parents))
+		for _, parent := range commit.Parents {
+			parentCommit, err := currentRepository.ReadCommitObject(parent)
+			if err != nil {
+				return err
+			}
+			if parentCommit.CommitTime() >= request.Deepen.Since {
+				includedParents = append(includedParents, parent)
+			}
+		}
+
+		if len(includedParents) < len(commit.Parents) {
+			boundary.Shallows[sha] = true
+			continue
+		}
+		if request.ClientShallows[sha] && len(commit.Parents) > 0 {
+			boundary.Unshallows[sha] = true
+		}
+		pending = append(pending, includedParents...)
+	}
+
+	return nil
+}
+
+// Annotated tags are peeled, so the boundary has commits. Wants that are not commits have no history to cut
+func peelWantsToCommits(currentRepository *repository.Repository, wants []string) []string {
+	commits := make([]string, 0, len(wants))
+	for _, want := range wants {
+		if commitSha, _, err := currentRepository.ResolveObjectName(want, objects.COMMIT); err == nil {
+			commits = append(commits, commitSha)
+		}
+	}
+	return commits
+}
+
+// Format: [shallow <sha>\n ...] [unshallow <sha>\n ...] 0000. Commits that already are shallow in the client are not sent
+func (b ShallowBoundary) write(writer io.Writer, clientShallows map[string]bool) error {
+	lines := make([]string, 0, len(b.Shallows)+len(b.Unshallows))
+	for _, sha := range sortedShas(b.Shallows) {
+		if !clientShallows[sha] {
+			lines = append(lines, "shallow "+sha+"\n")
+		}
+	}
+	for _, sha := range sortedShas(b.Unshallows) {
+		lines = append(lines, "unshallow "+sha+"\n")
+	}
+
+	for _, line := range lines {
+		if err := protocol.WritePktLine(writer, line); err != nil {
+			return err
+		}
+	}
+	return protocol.WriteFlush(writer)
+}
+
+func sortedShas(shas map[string]bool) []string {
+	result := make([]string, 0, len(shas))
+	for sha, _ := range shas {
+		result = append(result, sha)
+	}
+	sort.Strings(result)
+	return result
+}
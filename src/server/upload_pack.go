@@ -0,0 +1,245 @@
+package server
+
+import (
+	"errors"
+	"git/src/objects"
+	"git/src/packfile"
+	"git/src/protocol"
+	"git/src/repository"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// UploadPackRequest Wants, haves and options sent by the client
+type UploadPackRequest struct {
+	Wants          []string
+	Haves          []string
+	ClientShallows map[string]bool //Shallow commits of the client. It doesnt have their parents
+	Filter         protocol.ObjectFilter
+	Deepen         protocol.DeepenOptions
+	Done           bool
+	flushes        int //The wants end with a flush. If the haves end with another one an ACK or NAK is expected
+}
+
+// Request format: want <sha> [capabilities]\n ... [shallow <sha>\n ...] [deepen <depth>\n | deepen-since <time>\n]
+// [filter <filter spec>\n] 0000 [have <sha>\n ...] (done\n | 0000)
+// Every request is stateless, so the client sends all wants and haves again in each negotiation round.
+// Multi ack is not supported: the server answers with the first common have (ACK) or NAK. Once done is received the packfile is sent.
+// Shallow requests are answered first with the shallow and unshallow commits of the client and a flush. If the request ends
+// after the wants (first shallow round) nothing else is sent
+func uploadPack(writer io.Writer, body io.Reader, currentRepository *repository.Repository) error {
+	request, err := readUploadPackRequest(body)
+	if err != nil {
+		return err
+	}
+	if len(request.Wants) == 0 {
+		return errors.New("No wants received")
+	}
+	for _, want := range request.Wants {
+		if !currentRepository.HasObject(want) {
+			return errors.New("Not our ref " + want)
+		}
+	}
+
+	boundary := ShallowBoundary{Shallows: map[string]bool{}, Unshallows: map[string]bool{}}
+	if !request.Deepen.IsEmpty() {
+		if boundary, err = GetShallowBoundary(currentRepository, request); err != nil {
+			return err
+		}
+		if err := boundary.write(writer, request.ClientShallows); err != nil {
+			return err
+		}
+	}
+
+	if !request.Done && request.flushes < 2 {
+		return nil
+	}
+
+	commonHaves := make([]string, 0)
+	for _, have := range request.Haves {
+		if currentRepository.HasObject(have) {
+			commonHaves = append(commonHaves, have)
+		}
+	}
+
+	if !request.Done {
+		if len(commonHaves) > 0 {
+			return protocol.WritePktLine(writer, "ACK "+commonHaves[0]+"\n")
+		}
+		return protocol.WritePktLine(writer, "NAK\n")
+	}
+
+	if len(commonHaves) > 0 {
+		protocol.WritePktLine(writer, "ACK "+commonHaves[0]+"\n")
+	} else {
+		protocol.WritePktLine(writer, "NAK\n")
+	}
+
+	request.Haves = commonHaves
+	packObjects, err := CollectObjectsToSend(currentRepository, request, boundary)
+	if err != nil {
+		return err
+	}
+
+	return packfile.WritePack(writer, packObjects)
+}
+
+func readUploadPackRequest(body io.Reader) (UploadPackRequest, error) {
+	request := UploadPackRequest{Wants: make([]string, 0), Haves: make([]string, 0), ClientShallows: make(map[string]bool)}
+	request.Filter, _ = protocol.ParseObjectFilter("")
+
+	for {
+		line, err := protocol.ReadPktLine(body)
+		if err == io.EOF {
+			return request, nil
+		}
+		if err != nil {
+			return request, err
+		}
+		if line.Flush {
+			request.flushes++
+			continue
+		}
+
+		fields := strings.Fields(line.String())
+		switch {
+		case len(fields) >= 2 && fields[0] == "want":
+			request.Wants = append(request.Wants, fields[1])
+			for _, capability := range fields[2:] {
+				request.Deepen.Relative = request.Deepen.Relative || capability == "deepen-relative"
+			}
+		case len(fields) >= 2 && fields[0] == "have":
+			request.Haves = append(request.Haves, fields[1])
+		case len(fields) == 2 && fields[0] == "shallow":
+			request.ClientShallows[fields[1]] = true
+		case len(fields) == 2 && fields[0] == "deepen":
+			if request.Deepen.Depth, err = strconv.Atoi(fields[1]); err != nil || request.Deepen.Depth <= 0 {
+				return request, errors.New("Invalid deepen: " + fields[1])
+			}
+		case len(fields) == 2 && fields[0] == "deepen-since":
+			if request.Deepen.Since, err = strconv.ParseInt(fields[1], 10, 64); err != nil {
+				return request, errors.New("Invalid deepen-since: " + fields[1])
+			}
+		case len(fields) == 2 && fields[0] == "filter":
+			if request.Filter, err = protocol.ParseObjectFilter(fields[1]); err != nil {
+				return request, err
+			}
+		case len(fields) == 1 && fields[0] == "done":
+			request.Done = true
+			return request, nil
+		}
+	}
+}
+
+// CollectObjectsToSend Returns every object reachable from the wants that is not reachable from the haves. They are counted
+// with the reachability bitmaps if there are no filter or shallow commits, otherwise the graph is walked. Blobs omitted by the
+// filter are not sent unless they are wanted. The parents of the shallows of the boundary are not sent, and neither are the
+// parents of the shallow commits of the client, unless they are unshallowed. The client already has the unshallowed
+// commits, so the walk also starts from their parents
+func CollectObjectsToSend(currentRepository *repository.Repository, request UploadPackRequest, boundary ShallowBoundary) ([]packfile.PackObject, error) {
+	if request.Filter.IsEmpty() && request.Deepen.IsEmpty() && len(request.ClientShallows) == 0 {
+		shas, found, err := currentRepository.ReachableObjectsWithBitmaps(request.Wants, request.Haves)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			return readPackObjects(currentRepository, shas)
+		}
+	}
+
+	alreadyInClient := make(map[string]bool)
+	noFilter, _ := protocol.ParseObjectFilter("")
+	if _, err := walkObjects(currentRepository, request.Haves, alreadyInClient, noFilter, request.ClientShallows); err != nil {
+		return nil, err
+	}
+
+	stopCommits := make(map[string]bool)
+	for sha, _ := range boundary.Shallows {
+		stopCommits[sha] = true
+	}
+	for sha, _ := range request.ClientShallows {
+		if !boundary.Unshallows[sha] {
+			stopCommits[sha] = true
+		}
+	}
+
+	startShas := append(make([]string, 0, len(request.Wants)), request.Wants...)
+	for _, sha := range sortedShas(boundary.Unshallows) {
+		commit, err := currentRepository.ReadCommitObject(sha)
+		if err != nil {
+			return nil, err
+		}
+		startShas = append(startShas, commit.Parents...)
+	}
+
+	return walkObjects(currentRepository, startShas, alreadyInClient, request.Filter, stopCommits)
+}
+
+func readPackObjects(currentRepository *repository.Repository, shas []string) ([]packfile.PackObject, error) {
+	result := make([]packfile.PackObject, 0, len(shas))
+	for _, sha := range shas {
+		rawObject, err := currentRepository.ReadRawObject(sha)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, packfile.PackObject{Type: rawObject.Type, Sha: sha, Data: rawObject.SerializableGitObject.Serialize()})
+	}
+	return result, nil
+}
+
+// Objects already present in visited are not returned. Returned objects are added to visited. The parents of the stop commits
+// are not walked
+func walkObjects(currentRepository *repository.Repository, startShas []string, visited map[string]bool, filter protocol.ObjectFilter,
+	stopCommits map[string]bool) ([]packfile.PackObject, error) {
+	result := make([]packfile.PackObject, 0)
+	pending := append(make([]string, 0, len(startShas)), startShas...)
+	wanted := make(map[string]bool)
+	for _, sha := range startShas {
+		wanted[sha] = true
+	}
+
+	for len(pending) > 0 {
+		sha := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+
+		if visited[sha] {
+			continue
+		}
+		visited[sha] = true
+
+		rawObject, err := currentRepository.ReadRawObject(sha)
+		if err != nil {
+			return nil, err
+		}
+		body := rawObject.SerializableGitObject.Serialize()
+		if rawObject.Type == objects.BLOB && !wanted[sha] && !filter.IncludesBlob(len(body)) {
+			continue
+		}
+		result = append(result, packfile.PackObject{Type: rawObject.Type, Sha: sha, Data: body})
+
+		parsedObject, err := objects.DeserializeObjectBody(rawObject.Type, body)
+		if err != nil {
+			return nil, err
+		}
+
+		switch parsedObject.Type {
+		case objects.COMMIT:
+			commit := parsedObject.SerializableGitObject.(objects.CommitObject)
+			pending = append(pending, commit.Tree)
+			if !stopCommits[sha] && !currentRepository.IsShallowCommit(sha) {
+				pending = append(pending, commit.Parents...)
+			}
+		case objects.TREE:
+			for _, entry := range parsedObject.SerializableGitObject.(objects.TreeObject).Entries {
+				if !entry.IsGitlink() { //Submodule commits belong to other repositories
+					pending = append(pending, entry.Sha)
+				}
+			}
+		case objects.TAG:
+			pending = append(pending, parsedObject.SerializableGitObject.(objects.TagObject).Object)
+		}
+	}
+
+	return result, nil
+}
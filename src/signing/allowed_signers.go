@@ -0,0 +1,205 @@
+package signing
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// AllowedSigner Line of the allowed signers file. Format: <principal>[,<principal>...] [<options>] <key type> <base64 key>
+type AllowedSigner struct {
+	Principals  []string
+	Namespaces  []string
+	ValidAfter  time.Time //Zero if the key has no start of validity
+	ValidBefore time.Time //Zero if the key doesnt expire
+	PublicKey   ssh.PublicKey
+}
+
+// Verification Result of checking a signature against the allowed signers
+type Verification struct {
+	Good        bool
+	Principal   string //Empty if the key is not in the allowed signers
+	KeyType     string
+	Fingerprint string
+	Error       error
+}
+
+// ReadAllowedSigners Parses the allowed signers file (gpg.ssh.allowedSignersFile). Empty lines and # comments are skipped
+func ReadAllowedSigners(path string) ([]AllowedSigner, error) {
+	path, err := expandHome(path)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	allowedSigners := make([]AllowedSigner, 0)
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		allowedSigner, err := ParseAllowedSigner(line)
+		if err != nil {
+			return nil, errors.New(path + ":" + strconv.Itoa(lineNumber) + ": " + err.Error())
+		}
+		allowedSigners = append(allowedSigners, allowedSigner)
+	}
+
+	return allowedSigners, scanner.Err()
+}
+
+// ParseAllowedSigner Parses one line of the allowed signers file. The options namespaces, valid-after and valid-before are
+// supported. Others, like cert-authority, are rejected, since ignoring them would trust keys in a way the file doesnt allow
+func ParseAllowedSigner(line string) (AllowedSigner, error) {
+	fields := strings.SplitN(strings.TrimSpace(line), " ", 2)
+	if len(fields) != 2 {
+		return AllowedSigner{}, errors.New("missing public key")
+	}
+
+	publicKey, _, options, _, err := ssh.ParseAuthorizedKey([]byte(fields[1]))
+	if err != nil {
+		return AllowedSigner{}, err
+	}
+
+	allowedSigner := AllowedSigner{Principals: strings.Split(fields[0], ","), PublicKey: publicKey}
+	for _, option := range options {
+		name, value, _ := strings.Cut(option, "=")
+		value = strings.Trim(value, "\"")
+
+		switch strings.ToLower(name) {
+		case "namespaces":
+			allowedSigner.Namespaces = strings.Split(value, ",")
+		case "valid-after":
+			allowedSigner.ValidAfter, err = parseValidityTime(value)
+		case "valid-before":
+			allowedSigner.ValidBefore, err = parseValidityTime(value)
+		default:
+			err = errors.New("unsupported option " + name)
+		}
+		if err != nil {
+			return AllowedSigner{}, err
+		}
+	}
+
+	return allowedSigner, nil
+}
+
+// Formats of ssh-keygen: YYYYMMDD, YYYYMMDDHHMM or YYYYMMDDHHMMSS, in local time unless it ends with Z
+func parseValidityTime(value string) (time.Time, error) {
+	location := time.Local
+	if strings.HasSuffix(value, "Z") || strings.HasSuffix(value, "z") {
+		value, location = value[:len(value)-1], time.UTC
+	}
+
+	layouts := map[int]string{8: "20060102", 12: "200601021504", 14: "20060102150405"}
+	layout, validLength := layouts[len(value)]
+	if !validLength {
+		return time.Time{}, errors.New("invalid time " + value)
+	}
+	parsed, err := time.ParseInLocation(layout, value, location)
+	if err != nil {
+		return time.Time{}, errors.New("invalid time " + value)
+	}
+
+	return parsed, nil
+}
+
+// VerifyWithAllowedSigners Checks the signature and looks for its key in the allowed signers. A valid signature made by
+// an unknown key is not good, neither is one made at signatureTime (the committer or tagger date) outside the validity
+// of the key. A zero signatureTime skips the validity check
+func VerifyWithAllowedSigners(data []byte, armoredSignature string, allowedSigners []AllowedSigner, signatureTime time.Time) Verification {
+	publicKey, err := Verify(data, armoredSignature)
+	if publicKey == nil {
+		return Verification{Error: err}
+	}
+
+	verification := Verification{KeyType: publicKey.Type(), Fingerprint: ssh.FingerprintSHA256(publicKey), Error: err}
+	if err != nil {
+		return verification
+	}
+
+	verification.Error = errors.New("no principal matched")
+	for _, allowedSigner := range allowedSigners {
+		if !allowedSigner.allowsNamespace(SIGNATURE_NAMESPACE) || string(allowedSigner.PublicKey.Marshal()) != string(publicKey.Marshal()) {
+			continue
+		}
+		if err := allowedSigner.checkValidity(signatureTime); err != nil {
+			verification.Error = err
+			continue
+		}
+
+		verification.Good = true
+		verification.Principal = strings.Join(allowedSigner.Principals, ",")
+		verification.Error = nil
+		return verification
+	}
+
+	return verification
+}
+
+func (a AllowedSigner) checkValidity(signatureTime time.Time) error {
+	if signatureTime.IsZero() {
+		return nil
+	}
+	if (!a.ValidAfter.IsZero() && signatureTime.Before(a.ValidAfter)) || (!a.ValidBefore.IsZero() && signatureTime.After(a.ValidBefore)) {
+		return errors.New("key not valid at the signature time")
+	}
+	return nil
+}
+
+func (a AllowedSigner) allowsNamespace(namespace string) bool {
+	if len(a.Namespaces) == 0 {
+		return true
+	}
+	for _, allowed := range a.Namespaces {
+		if allowed == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// String Same messages as ssh-keygen -Y verify
+func (v Verification) String() string {
+	switch {
+	case v.Good:
+		return "Good \"" + SIGNATURE_NAMESPACE + "\" signature for " + v.Principal + " with " + keyTypeName(v.KeyType) + " key " + v.Fingerprint
+	case v.Fingerprint != "" && v.Error != nil && v.Error.Error() == "no principal matched":
+		return "Good \"" + SIGNATURE_NAMESPACE + "\" signature with " + keyTypeName(v.KeyType) + " key " + v.Fingerprint + "\nNo principal matched."
+	case v.Fingerprint != "" && v.Error != nil && v.Error.Error() == "key not valid at the signature time":
+		return "Good \"" + SIGNATURE_NAMESPACE + "\" signature with " + keyTypeName(v.KeyType) + " key " + v.Fingerprint + "\nKey not valid at the signature time."
+	case v.Fingerprint != "":
+		return "Bad signature with " + keyTypeName(v.KeyType) + " key " + v.Fingerprint
+	default:
+		return "Could not verify signature: " + v.Error.Error()
+	}
+}
+
+// Example: ssh-ed25519 -> ED25519, ecdsa-sha2-nistp256 -> ECDSA
+func keyTypeName(keyType string) string {
+	switch {
+	case keyType == ssh.KeyAlgoED25519:
+		return "ED25519"
+	case keyType == ssh.KeyAlgoRSA:
+		return "RSA"
+	case strings.HasPrefix(keyType, "ecdsa-"):
+		return "ECDSA"
+	case strings.HasPrefix(keyType, "sk-ssh-ed25519"):
+		return "ED25519-SK"
+	case strings.HasPrefix(keyType, "sk-ecdsa"):
+		return "ECDSA-SK"
+	}
+	return strings.ToUpper(keyType)
+}
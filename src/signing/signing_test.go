@@ -0,0 +1,105 @@
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestSigning_SignVerify(t *testing.T) {
+	_, privateKey, _ := ed25519.GenerateKey(rand.Reader)
+	signer, err := ssh.NewSignerFromKey(privateKey)
+	assert.Nil(t, err)
+
+	signature, err := Sign(signer, []byte("tree abc\n\nmessage"))
+	assert.Nil(t, err)
+	assert.True(t, IsSignature(signature))
+
+	publicKey, err := Verify([]byte("tree abc\n\nmessage"), signature)
+	assert.Nil(t, err)
+	assert.Equal(t, signer.PublicKey().Marshal(), publicKey.Marshal())
+
+	_, err = Verify([]byte("tree abc\n\nchanged"), signature)
+	assert.NotNil(t, err)
+}
+
+func TestSigning_VerifyWithAllowedSigners(t *testing.T) {
+	_, privateKey, _ := ed25519.GenerateKey(rand.Reader)
+	signer, _ := ssh.NewSignerFromKey(privateKey)
+	signature, _ := Sign(signer, []byte("data"))
+
+	path := filepath.Join(t.TempDir(), "allowed_signers")
+	authorizedKey := string(ssh.MarshalAuthorizedKey(signer.PublicKey()))
+	assert.Nil(t, os.WriteFile(path, []byte("# comment\n\njaime@example.com,jaime namespaces=\"git\" "+authorizedKey), 0644))
+
+	allowedSigners, err := ReadAllowedSigners(path)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(allowedSigners))
+	assert.Equal(t, []string{"jaime@example.com", "jaime"}, allowedSigners[0].Principals)
+	assert.Equal(t, []string{"git"}, allowedSigners[0].Namespaces)
+
+	verification := VerifyWithAllowedSigners([]byte("data"), signature, allowedSigners, time.Now())
+	assert.True(t, verification.Good)
+	assert.Equal(t, "Good \"git\" signature for jaime@example.com,jaime with ED25519 key "+ssh.FingerprintSHA256(signer.PublicKey()), verification.String())
+
+	verification = VerifyWithAllowedSigners([]byte("data"), signature, []AllowedSigner{}, time.Now())
+	assert.False(t, verification.Good)
+	assert.Equal(t, ssh.FingerprintSHA256(signer.PublicKey()), verification.Fingerprint)
+}
+
+func TestSigning_AllowedSignerValidity(t *testing.T) {
+	_, privateKey, _ := ed25519.GenerateKey(rand.Reader)
+	signer, _ := ssh.NewSignerFromKey(privateKey)
+	signature, _ := Sign(signer, []byte("data"))
+	authorizedKey := string(ssh.MarshalAuthorizedKey(signer.PublicKey()))
+
+	allowedSigner, err := ParseAllowedSigner("jaime valid-after=\"20240101\",valid-before=\"20250630120000Z\" " + authorizedKey)
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local), allowedSigner.ValidAfter)
+	assert.Equal(t, time.Date(2025, 6, 30, 12, 0, 0, 0, time.UTC), allowedSigner.ValidBefore)
+	allowedSigners := []AllowedSigner{allowedSigner}
+
+	verification := VerifyWithAllowedSigners([]byte("data"), signature, allowedSigners, time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC))
+	assert.True(t, verification.Good)
+	assert.Equal(t, "jaime", verification.Principal)
+
+	for _, signatureTime := range []time.Time{time.Date(2023, 12, 30, 0, 0, 0, 0, time.UTC), time.Date(2025, 6, 30, 12, 0, 1, 0, time.UTC)} {
+		verification = VerifyWithAllowedSigners([]byte("data"), signature, allowedSigners, signatureTime)
+		assert.False(t, verification.Good)
+		assert.Equal(t, "Good \"git\" signature with ED25519 key "+ssh.FingerprintSHA256(signer.PublicKey())+"\nKey not valid at the signature time.",
+			verification.String())
+	}
+
+	//A later line of the same key can still match
+	unlimited, err := ParseAllowedSigner("jaime-new " + authorizedKey)
+	assert.Nil(t, err)
+	verification = VerifyWithAllowedSigners([]byte("data"), signature, append(allowedSigners, unlimited), time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	assert.True(t, verification.Good)
+	assert.Equal(t, "jaime-new", verification.Principal)
+}
+
+func TestSigning_AllowedSignerUnsupportedOptions(t *testing.T) {
+	_, privateKey, _ := ed25519.GenerateKey(rand.Reader)
+	signer, _ := ssh.NewSignerFromKey(privateKey)
+	authorizedKey := string(ssh.MarshalAuthorizedKey(signer.PublicKey()))
+
+	_, err := ParseAllowedSigner("*@example.com cert-authority " + authorizedKey)
+	assert.EqualError(t, err, "unsupported option cert-authority")
+	_, err = ParseAllowedSigner("jaime no-touch-required " + authorizedKey)
+	assert.EqualError(t, err, "unsupported option no-touch-required")
+	_, err = ParseAllowedSigner("jaime valid-before=\"2025\" " + authorizedKey)
+	assert.EqualError(t, err, "invalid time 2025")
+	_, err = ParseAllowedSigner("jaime valid-after=\"20251340\" " + authorizedKey)
+	assert.EqualError(t, err, "invalid time 20251340")
+
+	path := filepath.Join(t.TempDir(), "allowed_signers")
+	assert.Nil(t, os.WriteFile(path, []byte("jaime "+authorizedKey+"*@example.com cert-authority "+authorizedKey), 0644))
+	_, err = ReadAllowedSigners(path)
+	assert.EqualError(t, err, path+":2: unsupported option cert-authority")
+}
@@ -0,0 +1,185 @@
+package signing
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Format of ssh-keygen -Y sign (PROTOCOL.sshsig in OpenSSH)
+const (
+	SIGNATURE_MAGIC     = "SSHSIG"
+	SIGNATURE_VERSION   = 1
+	SIGNATURE_NAMESPACE = "git"
+	SIGNATURE_HASH      = "sha512"
+	SIGNATURE_BEGIN     = "-----BEGIN SSH SIGNATURE-----"
+	SIGNATURE_END       = "-----END SSH SIGNATURE-----"
+	ARMOR_LINE_LENGTH   = 70
+)
+
+// sshSignature Fields of the signature blob
+type sshSignature struct {
+	Version       uint32
+	PublicKey     []byte
+	Namespace     string
+	Reserved      string
+	HashAlgorithm string
+	Signature     []byte
+}
+
+// LoadSigningKey Reads an unencrypted OpenSSH private key. If path is a public key (.pub), its private key is read
+func LoadSigningKey(path string) (ssh.Signer, error) {
+	path, err := expandHome(path)
+	if err != nil {
+		return nil, err
+	}
+
+	privateKey, err := os.ReadFile(strings.TrimSuffix(path, ".pub"))
+	if err != nil {
+		return nil, errors.New("cannot read signing key " + path + ": " + err.Error())
+	}
+
+	signer, err := ssh.ParsePrivateKey(privateKey)
+	if err != nil {
+		return nil, errors.New("cannot load signing key " + path + ": " + err.Error())
+	}
+
+	return signer, nil
+}
+
+// Sign Returns the armored SSH signature of data in the "git" namespace. RSA keys sign with rsa-sha2-512
+func Sign(signer ssh.Signer, data []byte) (string, error) {
+	signedData := getSignedData(SIGNATURE_NAMESPACE, SIGNATURE_HASH, data)
+
+	var signature *ssh.Signature
+	var err error
+	if algorithmSigner, isAlgorithmSigner := signer.(ssh.AlgorithmSigner); isAlgorithmSigner && signer.PublicKey().Type() == ssh.KeyAlgoRSA {
+		signature, err = algorithmSigner.SignWithAlgorithm(rand.Reader, signedData, ssh.KeyAlgoRSASHA512)
+	} else {
+		signature, err = signer.Sign(rand.Reader, signedData)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	blob := append([]byte(SIGNATURE_MAGIC), ssh.Marshal(sshSignature{
+		Version:       SIGNATURE_VERSION,
+		PublicKey:     signer.PublicKey().Marshal(),
+		Namespace:     SIGNATURE_NAMESPACE,
+		HashAlgorithm: SIGNATURE_HASH,
+		Signature:     ssh.Marshal(signature),
+	})...)
+
+	return armor(blob), nil
+}
+
+// Verify Checks that the armored signature is valid for data. Returns the public key that made it
+func Verify(data []byte, armoredSignature string) (ssh.PublicKey, error) {
+	blob, err := unarmor(armoredSignature)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(blob, []byte(SIGNATURE_MAGIC)) {
+		return nil, errors.New("invalid signature: missing " + SIGNATURE_MAGIC + " preamble")
+	}
+
+	var parsed sshSignature
+	if err := ssh.Unmarshal(blob[len(SIGNATURE_MAGIC):], &parsed); err != nil {
+		return nil, errors.New("invalid signature: " + err.Error())
+	}
+	if parsed.Version != SIGNATURE_VERSION {
+		return nil, errors.New("unsupported signature version")
+	}
+	if parsed.Namespace != SIGNATURE_NAMESPACE {
+		return nil, errors.New("signature namespace is " + parsed.Namespace + ", expected " + SIGNATURE_NAMESPACE)
+	}
+	if parsed.HashAlgorithm != "sha512" && parsed.HashAlgorithm != "sha256" {
+		return nil, errors.New("unsupported hash algorithm " + parsed.HashAlgorithm)
+	}
+
+	publicKey, err := ssh.ParsePublicKey(parsed.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	signature := &ssh.Signature{}
+	if err := ssh.Unmarshal(parsed.Signature, signature); err != nil {
+		return nil, errors.New("invalid signature: " + err.Error())
+	}
+
+	if err := publicKey.Verify(getSignedData(parsed.Namespace, parsed.HashAlgorithm, data), signature); err != nil {
+		return publicKey, errors.New("bad signature")
+	}
+
+	return publicKey, nil
+}
+
+// IsSignature Returns true if the text contains an armored SSH signature
+func IsSignature(text string) bool {
+	return strings.Contains(text, SIGNATURE_BEGIN)
+}
+
+// Data signed by the key: magic, namespace, reserved, hash algorithm and hash of the message
+func getSignedData(namespace string, hashAlgorithm string, data []byte) []byte {
+	var hash []byte
+	if hashAlgorithm == "sha256" {
+		sum := sha256.Sum256(data)
+		hash = sum[:]
+	} else {
+		sum := sha512.Sum512(data)
+		hash = sum[:]
+	}
+
+	return append([]byte(SIGNATURE_MAGIC), ssh.Marshal(struct {
+		Namespace     string
+		Reserved      string
+		HashAlgorithm string
+		Hash          []byte
+	}{namespace, "", hashAlgorithm, hash})...)
+}
+
+func armor(blob []byte) string {
+	encoded := base64.StdEncoding.EncodeToString(blob)
+	var result strings.Builder
+
+	result.WriteString(SIGNATURE_BEGIN + "\n")
+	for len(encoded) > ARMOR_LINE_LENGTH {
+		result.WriteString(encoded[:ARMOR_LINE_LENGTH] + "\n")
+		encoded = encoded[ARMOR_LINE_LENGTH:]
+	}
+	result.WriteString(encoded + "\n")
+	result.WriteString(SIGNATURE_END + "\n")
+
+	return result.String()
+}
+
+func unarmor(armored string) ([]byte, error) {
+	start := strings.Index(armored, SIGNATURE_BEGIN)
+	end := strings.Index(armored, SIGNATURE_END)
+	if start == -1 || end < start {
+		return nil, errors.New("invalid signature: not an armored SSH signature")
+	}
+
+	encoded := strings.Join(strings.Fields(armored[start+len(SIGNATURE_BEGIN):end]), "")
+	return base64.StdEncoding.DecodeString(encoded)
+}
+
+// Paths in the config can start with ~/
+func expandHome(path string) (string, error) {
+	if !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, path[2:]), nil
+}
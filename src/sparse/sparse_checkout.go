@@ -0,0 +1,198 @@
+package sparse
+
+import (
+	"git/src/utils"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Patterns Content of info/sparse-checkout. Only the files matching them are checked out, the rest get the skip-worktree bit.
+// In cone mode the patterns are directories: their files are included recursively, and so are the files at the root and
+// the files directly inside their parents. Format:
+//
+//	/*
+//	!/*/
+//	/<parent>/
+//	!/<parent>/*/
+//	/<parent>/<dir>/
+//
+// Otherwise they are gitignore-like patterns, where the last matching one decides (! excludes)
+type Patterns struct {
+	Cone          bool
+	RecursiveDirs map[string]bool
+	ParentDirs    map[string]bool
+	Lines         []string //Patterns of non cone mode
+}
+
+// Parse Lines that are not cone patterns make cone mode fall back to the non cone rules
+func Parse(content []byte, cone bool) *Patterns {
+	lines := make([]string, 0)
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+
+	if cone {
+		if patterns, isCone := parseCone(lines); isCone {
+			return patterns
+		}
+	}
+	return &Patterns{Lines: lines}
+}
+
+func parseCone(lines []string) (*Patterns, bool) {
+	patterns := &Patterns{Cone: true, RecursiveDirs: make(map[string]bool), ParentDirs: make(map[string]bool)}
+	if len(lines) < 2 || lines[0] != "/*" || lines[1] != "!/*/" {
+		return nil, false
+	}
+
+	for _, line := range lines[2:] {
+		if dir, isParent := strings.CutPrefix(line, "!/"); isParent {
+			dir, isParent = strings.CutSuffix(dir, "/*/")
+			if !isParent || !patterns.RecursiveDirs[dir] {
+				return nil, false
+			}
+			delete(patterns.RecursiveDirs, dir)
+			patterns.ParentDirs[dir] = true
+		} else if strings.HasPrefix(line, "/") && strings.HasSuffix(line, "/") && len(line) > 2 && !strings.ContainsAny(line, "*?[") {
+			patterns.RecursiveDirs[strings.Trim(line, "/")] = true
+		} else {
+			return nil, false
+		}
+	}
+
+	return patterns, true
+}
+
+// ConePatterns Includes the directories recursively. Directories inside other ones are redundant and removed
+func ConePatterns(dirs []string) *Patterns {
+	patterns := &Patterns{Cone: true, RecursiveDirs: make(map[string]bool), ParentDirs: make(map[string]bool)}
+
+	for _, dir := range dirs {
+		dir = strings.Trim(filepath.ToSlash(filepath.Clean(dir)), "/")
+		if dir != "" && dir != "." {
+			patterns.RecursiveDirs[dir] = true
+		}
+	}
+	for dir, _ := range patterns.RecursiveDirs {
+		for parent := parentDir(dir); parent != ""; parent = parentDir(parent) {
+			if patterns.RecursiveDirs[parent] {
+				delete(patterns.RecursiveDirs, dir)
+			}
+		}
+	}
+	for dir, _ := range patterns.RecursiveDirs {
+		for parent := parentDir(dir); parent != ""; parent = parentDir(parent) {
+			patterns.ParentDirs[parent] = true
+		}
+	}
+
+	return patterns
+}
+
+// Dirs Recursive directories in cone mode, sorted. The patterns in non cone mode
+func (p *Patterns) Dirs() []string {
+	if !p.Cone {
+		return p.Lines
+	}
+
+	dirs := make([]string, 0, len(p.RecursiveDirs))
+	for dir, _ := range p.RecursiveDirs {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+
+	return dirs
+}
+
+func (p *Patterns) Serialize() []byte {
+	if !p.Cone {
+		return []byte(strings.Join(p.Lines, "\n") + "\n")
+	}
+
+	dirs := make([]string, 0, len(p.RecursiveDirs)+len(p.ParentDirs))
+	for dir, _ := range p.RecursiveDirs {
+		dirs = append(dirs, dir)
+	}
+	for dir, _ := range p.ParentDirs {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+
+	var content strings.Builder
+	content.WriteString("/*\n!/*/\n")
+	for _, dir := range dirs {
+		content.WriteString("/" + dir + "/\n")
+		if p.ParentDirs[dir] {
+			content.WriteString("!/" + dir + "/*/\n")
+		}
+	}
+
+	return []byte(content.String())
+}
+
+// Matches Returns true if the file (path relative to the work tree) is checked out. Nil patterns (sparse checkout disabled) match all
+func (p *Patterns) Matches(path string) bool {
+	if p == nil {
+		return true
+	}
+
+	dir := parentDir(path)
+	if !p.Cone {
+		return p.matchesLines(path)
+	}
+	if dir == "" || p.ParentDirs[dir] {
+		return true
+	}
+	for ; dir != ""; dir = parentDir(dir) {
+		if p.RecursiveDirs[dir] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Patterns ending with / match directories, so all the files inside them. Patterns starting with / or containing / are
+// relative to the root, the rest match the name of the file or of some directory containing it
+func (p *Patterns) matchesLines(path string) bool {
+	matches := false
+
+	for _, line := range p.Lines {
+		pattern, negated := strings.CutPrefix(line, "!")
+		pattern, onlyDirs := strings.CutSuffix(pattern, "/")
+		anchored := strings.Contains(pattern, "/")
+		pattern = strings.TrimPrefix(pattern, "/")
+
+		candidates := []string{path}
+		if onlyDirs {
+			candidates = []string{}
+		}
+		for dir := parentDir(path); dir != ""; dir = parentDir(dir) {
+			candidates = append(candidates, dir)
+		}
+
+		for _, candidate := range candidates {
+			if !anchored {
+				candidate = filepath.Base(candidate)
+			}
+			if utils.WildMatch(pattern, candidate, false) {
+				matches = !negated
+				break
+			}
+		}
+	}
+
+	return matches
+}
+
+func parentDir(path string) string {
+	index := strings.LastIndex(path, "/")
+	if index == -1 {
+		return ""
+	}
+	return path[:index]
+}
@@ -0,0 +1,41 @@
+package sparse
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSparse_ConePatterns(t *testing.T) {
+	patterns := ConePatterns([]string{"src/lib", "docs/", "src/lib/internal"})
+
+	assert.Equal(t, []string{"docs", "src/lib"}, patterns.Dirs())
+	assert.Equal(t, "/*\n!/*/\n/docs/\n/src/\n!/src/*/\n/src/lib/\n", string(patterns.Serialize()))
+	assert.Equal(t, patterns, Parse(patterns.Serialize(), true))
+
+	assert.True(t, patterns.Matches("README.md"))
+	assert.True(t, patterns.Matches("src/main.go"))
+	assert.True(t, patterns.Matches("src/lib/internal/a.go"))
+	assert.True(t, patterns.Matches("docs/guide/index.md"))
+	assert.False(t, patterns.Matches("src/cmd/main.go"))
+	assert.False(t, patterns.Matches("tests/a_test.go"))
+
+	var disabled *Patterns
+	assert.True(t, disabled.Matches("tests/a_test.go"))
+}
+
+func TestSparse_NonConePatterns(t *testing.T) {
+	patterns := Parse([]byte("# comment\n*.md\n/src/\n!src/vendor/\n"), true)
+
+	assert.False(t, patterns.Cone)
+	assert.Equal(t, []string{"*.md", "/src/", "!src/vendor/"}, patterns.Dirs())
+	assert.True(t, patterns.Matches("README.md"))
+	assert.True(t, patterns.Matches("docs/guide.md"))
+	assert.True(t, patterns.Matches("src/main.go"))
+	assert.False(t, patterns.Matches("src/vendor/lib.go"))
+	assert.False(t, patterns.Matches("main.go"))
+
+	rootFiles := Parse([]byte("/*\n!/*/\n"), false)
+	assert.True(t, rootFiles.Matches("main.go"))
+	assert.False(t, rootFiles.Matches("src/main.go"))
+}
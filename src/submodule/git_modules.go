@@ -0,0 +1,101 @@
+package submodule
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+const GIT_MODULES_FILE_NAME = ".gitmodules"
+
+type Submodule struct {
+	Name string
+	Path string //Relative to the work tree of the superproject
+	Url  string
+}
+
+// GitModules Content of .gitmodules. Format:
+// [submodule "<name>"]
+//
+//	path = <path>
+//	url = <url>
+type GitModules struct {
+	Submodules []Submodule
+}
+
+func Deserialize(reader io.Reader) (GitModules, error) {
+	bytes, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return GitModules{}, err
+	}
+
+	iniFile, err := ini.Load(bytes)
+	if err != nil {
+		return GitModules{}, err
+	}
+
+	submodules := make([]Submodule, 0)
+	for _, section := range iniFile.Sections() {
+		name, isSubmodule := getSubmoduleName(section.Name())
+		if !isSubmodule {
+			continue
+		}
+		if !section.HasKey("path") || !section.HasKey("url") {
+			return GitModules{}, errors.New("Submodule " + name + " must have path and url")
+		}
+
+		submodules = append(submodules, Submodule{
+			Name: name,
+			Path: section.Key("path").String(),
+			Url:  section.Key("url").String(),
+		})
+	}
+
+	return GitModules{Submodules: submodules}, nil
+}
+
+// Section names have the format: submodule "<name>"
+func getSubmoduleName(sectionName string) (string, bool) {
+	if !strings.HasPrefix(sectionName, "submodule ") {
+		return "", false
+	}
+
+	return strings.Trim(strings.TrimPrefix(sectionName, "submodule "), "\""), true
+}
+
+func (g *GitModules) Serialize() []byte {
+	result := ""
+
+	for _, submodule := range g.Submodules {
+		result = result + "[submodule \"" + submodule.Name + "\"]\n"
+		result = result + "\tpath = " + submodule.Path + "\n"
+		result = result + "\turl = " + submodule.Url + "\n"
+	}
+
+	return []byte(result)
+}
+
+// Add Replaces the submodule with the same name if it already exists
+func (g *GitModules) Add(submodule Submodule) {
+	for i, actual := range g.Submodules {
+		if actual.Name == submodule.Name {
+			g.Submodules[i] = submodule
+			return
+		}
+	}
+
+	g.Submodules = append(g.Submodules, submodule)
+}
+
+func (g *GitModules) FindByPath(path string) (Submodule, bool) {
+	for _, submodule := range g.Submodules {
+		if submodule.Path == path {
+			return submodule, true
+		}
+	}
+
+	return Submodule{}, false
+}
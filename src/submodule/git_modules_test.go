@@ -0,0 +1,37 @@
+package submodule
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGitModules_Deserialize(t *testing.T) {
+	gitModulesBytes := []byte("[submodule \"lib\"]\n" +
+		"\tpath = vendor/lib\n" +
+		"\turl = ../lib.git\n" +
+		"[submodule \"docs\"]\n" +
+		"\tpath = docs\n" +
+		"\turl = /srv/docs\n")
+
+	gitModules, err := Deserialize(bytes.NewReader(gitModulesBytes))
+
+	assert.Nil(t, err)
+	assert.Equal(t, []Submodule{
+		{Name: "lib", Path: "vendor/lib", Url: "../lib.git"},
+		{Name: "docs", Path: "docs", Url: "/srv/docs"},
+	}, gitModules.Submodules)
+
+	submodule, found := gitModules.FindByPath("docs")
+	assert.True(t, found)
+	assert.Equal(t, "docs", submodule.Name)
+
+	assert.Equal(t, gitModulesBytes, gitModules.Serialize())
+}
+
+func TestGitModules_DeserializeMissingUrl(t *testing.T) {
+	_, err := Deserialize(bytes.NewReader([]byte("[submodule \"lib\"]\n\tpath = lib\n")))
+
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"Mon Jan 2 15:04:05 2006 -0700",
+	time.RFC1123Z,
+}
+
+var dateUnits = map[string]time.Duration{
+	"second": time.Second,
+	"minute": time.Minute,
+	"hour":   time.Hour,
+	"day":    24 * time.Hour,
+	"week":   7 * 24 * time.Hour,
+	"month":  30 * 24 * time.Hour,
+	"year":   365 * 24 * time.Hour,
+}
+
+// ParseDate Dates used in --since and --until. Accepts absolute dates (2006-01-02, 2006-01-02 15:04:05, RFC3339...),
+// unix timestamps, "now", "yesterday" and relative dates like "2 weeks ago" or "3.days"
+func ParseDate(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+
+	for _, layout := range dateLayouts {
+		if parsed, err := time.ParseInLocation(layout, value, now.Location()); err == nil {
+			return parsed, nil
+		}
+	}
+	if timestamp, err := strconv.ParseInt(strings.TrimPrefix(value, "@"), 10, 64); err == nil {
+		return time.Unix(timestamp, 0), nil
+	}
+
+	switch strings.ToLower(value) {
+	case "now":
+		return now, nil
+	case "yesterday":
+		return now.Add(-dateUnits["day"]), nil
+	}
+
+	fields := strings.Fields(strings.ReplaceAll(strings.ToLower(value), ".", " "))
+	if len(fields) == 2 || (len(fields) == 3 && fields[2] == "ago") {
+		amount, err := strconv.Atoi(fields[0])
+		unit, validUnit := dateUnits[strings.TrimSuffix(fields[1], "s")]
+		if err == nil && validUnit {
+			return now.Add(-time.Duration(amount) * unit), nil
+		}
+	}
+
+	return time.Time{}, errors.New("invalid date: " + value)
+}
+
+// FormatRelativeDate Format: "<n> <units> ago", like git --date=relative
+func FormatRelativeDate(date time.Time, now time.Time) string {
+	seconds := int64(now.Sub(date).Seconds())
+	if seconds < 0 {
+		return "in the future"
+	}
+
+	switch {
+	case seconds < 90:
+		return pluralize(seconds, "second") + " ago"
+	case seconds < 90*60:
+		return pluralize((seconds+30)/60, "minute") + " ago"
+	}
+
+	hours := (seconds + 30*60) / 3600
+	if hours < 36 {
+		return pluralize(hours, "hour") + " ago"
+	}
+
+	days := (seconds + 12*3600) / 86400
+	switch {
+	case days < 14:
+		return pluralize(days, "day") + " ago"
+	case days < 70:
+		return pluralize((days+3)/7, "week") + " ago"
+	case days < 365:
+		return pluralize((days+15)/30, "month") + " ago"
+	}
+
+	years := days / 365
+	if months := ((days % 365) + 15) / 30; years < 5 && months > 0 {
+		return pluralize(years, "year") + ", " + pluralize(months, "month") + " ago"
+	}
+
+	return pluralize((days+183)/365, "year") + " ago"
+}
+
+func pluralize(amount int64, unit string) string {
+	if amount == 1 {
+		return "1 " + unit
+	}
+
+	return strconv.FormatInt(amount, 10) + " " + unit + "s"
+}
@@ -1,24 +1,37 @@
 package utils
 
 type NavigationMap[K comparable, V any] struct {
-	internalMap        map[K]V
-	keysInsertionOrder []K
+	internalMap          map[K][]V
+	keysInsertionOrder   []K
+	valuesInsertionOrder []V
 }
 
 func CreateNavigationMap[K comparable, V any]() *NavigationMap[K, V] {
 	return &NavigationMap[K, V]{
-		internalMap:        make(map[K]V),
-		keysInsertionOrder: make([]K, 0),
+		internalMap:          make(map[K][]V),
+		keysInsertionOrder:   make([]K, 0),
+		valuesInsertionOrder: make([]V, 0),
 	}
 }
 
+// Put Keys can be repeated (Ex: parent in merge commits). Get will return the first value inserted
 func (n *NavigationMap[K, V]) Put(key K, value V) {
-	n.internalMap[key] = value
+	n.internalMap[key] = append(n.internalMap[key], value)
 	n.keysInsertionOrder = append(n.keysInsertionOrder, key)
+	n.valuesInsertionOrder = append(n.valuesInsertionOrder, value)
 }
 
 func (n *NavigationMap[K, V]) Get(key K) V {
-	return n.internalMap[key]
+	var empty V
+	if values := n.internalMap[key]; len(values) > 0 {
+		return values[0]
+	}
+
+	return empty
+}
+
+func (n *NavigationMap[K, V]) GetAll(key K) []V {
+	return n.internalMap[key][:]
 }
 
 func (n *NavigationMap[K, V]) ContainsAll(keys ...K) bool {
@@ -35,6 +48,13 @@ func (n *NavigationMap[K, V]) Keys() []K {
 	return n.keysInsertionOrder[:]
 }
 
+// ForEach Iterates over every key value pair in insertion order, including repeated keys
+func (n *NavigationMap[K, V]) ForEach(consumer func(key K, value V)) {
+	for i, key := range n.keysInsertionOrder {
+		consumer(key, n.valuesInsertionOrder[i])
+	}
+}
+
 func (n *NavigationMap[K, V]) Size() int {
 	return len(n.keysInsertionOrder)
 }
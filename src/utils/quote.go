@@ -0,0 +1,39 @@
+package utils
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Escapes of quote_c_style in git. Other control characters are written in octal
+var cQuoteEscapes = map[byte]string{'\a': "\\a", '\b': "\\b", '\t': "\\t", '\n': "\\n", '\v': "\\v", '\f': "\\f", '\r': "\\r",
+	'"': "\\\"", '\\': "\\\\"}
+
+// QuotePath Quotes the path like git does when core.quotePath is enabled (quoteNonASCII): if it has control characters,
+// '"', '\' or bytes over 0x7F, it is enclosed in double quotes with C escapes and the rest of those bytes in octal.
+// Ex: "tab\there" or "\303\251.txt". Other paths are returned as they are
+func QuotePath(path string, quoteNonASCII bool) string {
+	var quoted strings.Builder
+	mustQuote := false
+
+	for i := 0; i < len(path); i++ {
+		char := path[i]
+		escape, named := cQuoteEscapes[char]
+		switch {
+		case named:
+			quoted.WriteString(escape)
+		case char < 0x20 || char == 0x7F || (char >= 0x80 && quoteNonASCII):
+			octal := strconv.FormatInt(int64(char), 8)
+			quoted.WriteString("\\" + strings.Repeat("0", 3-len(octal)) + octal)
+		default:
+			quoted.WriteByte(char)
+			continue
+		}
+		mustQuote = true
+	}
+
+	if !mustQuote {
+		return path
+	}
+	return "\"" + quoted.String() + "\""
+}
@@ -121,9 +121,10 @@ func ExitError(message string) {
 	os.Exit(1)
 }
 
+var gitHashRegex = regexp.MustCompile("^[0-9A-Fa-f]{4,40}$")
+
 func IsValidGitHash(hash string) bool {
-	match, err := regexp.MatchString("^[0-9A-Fa-f]{4,40}$", hash)
-	return err == nil && match
+	return gitHashRegex.MatchString(hash)
 }
 
 func CheckFileOrDirExists(path string) bool {
@@ -0,0 +1,100 @@
+package utils
+
+import (
+	"regexp"
+	"strings"
+)
+
+// WildMatch Matches path against a git wildcard pattern. "*" and "?" dont match "/", "[...]" are character classes
+// ("!" or "^" negates them) and "**" between slashes matches any number of directories (Ex: a/**/b matches a/b and a/x/y/b)
+func WildMatch(pattern string, path string, ignoreCase bool) bool {
+	return wildMatch(wildcardToRegex(pattern, true), path, ignoreCase)
+}
+
+// WildMatchText Same as WildMatch but "*" and "?" also match "/", like wildmatch without WM_PATHNAME. Used for names that
+// are not paths, like the patterns of tag -l (Ex: release* matches release/1.0)
+func WildMatchText(pattern string, text string, ignoreCase bool) bool {
+	return wildMatch(wildcardToRegex(pattern, false), text, ignoreCase)
+}
+
+func wildMatch(regex string, text string, ignoreCase bool) bool {
+	expression := "^" + regex + "$"
+	if ignoreCase {
+		expression = "(?i)" + expression
+	}
+
+	matcher, err := regexp.Compile(expression)
+	return err == nil && matcher.MatchString(text)
+}
+
+// Without pathname, "*" and "?" match any character, so "**" is the same as "*"
+func wildcardToRegex(pattern string, pathname bool) string {
+	var result strings.Builder
+	anyCharacters, anyCharacter := "[^/]*", "[^/]"
+	if !pathname {
+		anyCharacters, anyCharacter = ".*", "."
+	}
+
+	for i := 0; i < len(pattern); i++ {
+		switch char := pattern[i]; {
+		case char == '\\' && i+1 < len(pattern):
+			i++
+			result.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		case char == '*' && strings.HasPrefix(pattern[i:], "**") && pathname:
+			atStart := i == 0 || pattern[i-1] == '/'
+			i++
+			switch {
+			case atStart && i+1 < len(pattern) && pattern[i+1] == '/':
+				result.WriteString("(?:.*/)?")
+				i++
+			case atStart && i+1 == len(pattern):
+				result.WriteString(".*")
+			default:
+				result.WriteString(anyCharacters)
+			}
+		case char == '*':
+			result.WriteString(anyCharacters)
+		case char == '?':
+			result.WriteString(anyCharacter)
+		case char == '[':
+			class, length := wildcardClassToRegex(pattern[i:])
+			result.WriteString(class)
+			i += length - 1
+		default:
+			result.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+
+	return result.String()
+}
+
+// Returns the regex of the class at the start of pattern and its length. A "]" just after the opening bracket is part of the class
+func wildcardClassToRegex(pattern string) (string, int) {
+	var class strings.Builder
+	class.WriteString("[")
+
+	i := 1
+	if i < len(pattern) && (pattern[i] == '!' || pattern[i] == '^') {
+		class.WriteString("^")
+		i++
+	}
+	for start := i; i < len(pattern); i++ {
+		switch {
+		case pattern[i] == ']' && i > start:
+			return class.String() + "]", i + 1
+		case pattern[i] == '[' && strings.HasPrefix(pattern[i:], "[:") && strings.Contains(pattern[i+2:], ":]"):
+			end := i + 2 + strings.Index(pattern[i+2:], ":]") + 2
+			class.WriteString(pattern[i:end])
+			i = end - 1
+		case pattern[i] == '\\' && i+1 < len(pattern):
+			i++
+			class.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		case pattern[i] == '\\' || pattern[i] == '[' || pattern[i] == ']':
+			class.WriteString("\\" + pattern[i:i+1])
+		default:
+			class.WriteByte(pattern[i])
+		}
+	}
+
+	return "\\[", 1 //Unclosed bracket is a literal
+}